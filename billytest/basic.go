@@ -0,0 +1,1167 @@
+package billytest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	. "github.com/go-git/go-billy/v6" //nolint
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eachBasicFS(t *testing.T, factory Factory, test func(t *testing.T, fs Basic)) {
+	t.Helper()
+	test(t, factory(t))
+}
+
+func testCreate(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", f.Name())
+		require.NoError(t, f.Close())
+	})
+}
+
+func testCreateDepth(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		if explicitDirs(fs) {
+			_, err := fs.Create("bar/foo")
+			require.ErrorIs(t, err, os.ErrNotExist)
+			mkdirParent(t, fs, "bar/foo")
+		}
+
+		f, err := fs.Create("bar/foo")
+		require.NoError(t, err)
+		assert.Equal(t, fs.Join("bar", "foo"), f.Name())
+		require.NoError(t, f.Close())
+	})
+}
+
+func testCreateDepthAbsolute(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		if explicitDirs(fs) {
+			_, err := fs.Create("/bar/foo")
+			require.ErrorIs(t, err, os.ErrNotExist)
+			mkdirParent(t, fs, "/bar/foo")
+		}
+
+		f, err := fs.Create("/bar/foo")
+		require.NoError(t, err)
+		assert.Equal(t, fs.Join("bar", "foo"), f.Name())
+		require.NoError(t, f.Close())
+	})
+}
+
+func testFileNames(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		opened := "/foo/../bar/./baz"
+		mkdirParent(t, fs, opened)
+		f, err := fs.Create(opened)
+		require.NoError(t, err)
+		assert.Equal(t, fs.Join("bar", "baz"), f.Name())
+		assert.Equal(t, opened, f.OpenedPath())
+		require.NoError(t, f.Close())
+
+		f, err = fs.Open(f.Name())
+		require.NoError(t, err)
+		assert.Equal(t, fs.Join("bar", "baz"), f.Name())
+		assert.Equal(t, fs.Join("bar", "baz"), f.OpenedPath())
+		require.NoError(t, f.Close())
+	})
+}
+
+func testCreateOverwrite(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		for i := 0; i < 3; i++ {
+			f, err := fs.Create("foo")
+			require.NoError(t, err)
+
+			l, err := f.Write([]byte(fmt.Sprintf("foo%d", i)))
+			require.NoError(t, err)
+			assert.Equal(t, 4, l)
+
+			err = f.Close()
+			require.NoError(t, err)
+		}
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		wrote, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "foo2", string(wrote))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testCreateAndClose(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("foo"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		f, err = fs.Open(f.Name())
+		require.NoError(t, err)
+
+		wrote, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(wrote))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testOpen(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", f.Name())
+		require.NoError(t, f.Close())
+
+		f, err = fs.Open("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", f.Name())
+		require.NoError(t, f.Close())
+	})
+}
+
+func testOpenNotExists(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		f, err := fs.Open("not-exists")
+		assert.NotNil(t, err)
+		assert.Nil(t, f)
+	})
+}
+
+func testOpenFile(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		defaultMode := os.FileMode(0666)
+
+		f, err := fs.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultMode)
+		require.NoError(t, err)
+		testWriteClose(t, f, "foo1")
+
+		// Truncate if it exists
+		f, err = fs.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testWriteClose(t, f, "foo1overwritten")
+
+		// Read-only if it exists
+		f, err = fs.OpenFile("foo1", os.O_RDONLY, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testReadClose(t, f, "foo1overwritten")
+
+		// Create when it does exist
+		f, err = fs.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testWriteClose(t, f, "bar")
+
+		f, err = fs.OpenFile("foo1", os.O_RDONLY, defaultMode)
+		require.NoError(t, err)
+		testReadClose(t, f, "bar")
+	})
+}
+
+func testOpenFileNoTruncate(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		defaultMode := os.FileMode(0666)
+
+		// Create when it does not exist
+		f, err := fs.OpenFile("foo1", os.O_CREATE|os.O_WRONLY, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testWriteClose(t, f, "foo1")
+
+		f, err = fs.OpenFile("foo1", os.O_RDONLY, defaultMode)
+		require.NoError(t, err)
+		testReadClose(t, f, "foo1")
+
+		// Create when it does exist
+		f, err = fs.OpenFile("foo1", os.O_CREATE|os.O_WRONLY, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testWriteClose(t, f, "bar")
+
+		f, err = fs.OpenFile("foo1", os.O_RDONLY, defaultMode)
+		require.NoError(t, err)
+		testReadClose(t, f, "bar1")
+	})
+}
+
+func testOpenFileAppend(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		defaultMode := os.FileMode(0666)
+
+		f, err := fs.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testWriteClose(t, f, "foo1")
+
+		f, err = fs.OpenFile("foo1", os.O_WRONLY|os.O_APPEND, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+		testWriteClose(t, f, "bar1")
+
+		f, err = fs.OpenFile("foo1", os.O_RDONLY, defaultMode)
+		require.NoError(t, err)
+		testReadClose(t, f, "foo1bar1")
+	})
+}
+
+func testOpenFileReadWrite(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		defaultMode := os.FileMode(0666)
+
+		f, err := fs.OpenFile("foo1", os.O_CREATE|os.O_TRUNC|os.O_RDWR, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+
+		written, err := f.Write([]byte("foobar"))
+		assert.Equal(t, 6, written)
+		require.NoError(t, err)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		written, err = f.Write([]byte("qux"))
+		assert.Equal(t, 3, written)
+		require.NoError(t, err)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		testReadClose(t, f, "quxbar")
+	})
+}
+
+func testOpenFileWithModes(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		f, err := fs.OpenFile("foo", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, customMode)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		fi, err := fs.Stat("foo")
+		require.NoError(t, err)
+		assert.Equal(t, customMode, fi.Mode())
+	})
+}
+
+func testOpenFileFlags(t *testing.T, factory Factory) {
+	tests := []struct {
+		name    string
+		exists  bool
+		flag    int
+		wantErr error
+		read    string // expected content read right after open, if readable
+		want    string // expected content after writing "bar", if writable
+	}{
+		{name: "rdonly missing", flag: os.O_RDONLY, wantErr: os.ErrNotExist},
+		{name: "wronly missing", flag: os.O_WRONLY, wantErr: os.ErrNotExist},
+		{name: "create", flag: os.O_WRONLY | os.O_CREATE, want: "bar"},
+		{name: "create excl", flag: os.O_RDWR | os.O_CREATE | os.O_EXCL, want: "bar"},
+		{name: "create excl existing", exists: true, flag: os.O_RDWR | os.O_CREATE | os.O_EXCL, wantErr: os.ErrExist},
+		{name: "excl without create", exists: true, flag: os.O_RDONLY | os.O_EXCL, read: "foo", want: "foo"},
+		{name: "rdonly", exists: true, flag: os.O_RDONLY, read: "foo", want: "foo"},
+		{name: "wronly", exists: true, flag: os.O_WRONLY, want: "bar"},
+		{name: "rdwr", exists: true, flag: os.O_RDWR, read: "foo", want: "foobar"},
+		{name: "wronly trunc", exists: true, flag: os.O_WRONLY | os.O_TRUNC, want: "bar"},
+		{name: "rdwr trunc", exists: true, flag: os.O_RDWR | os.O_TRUNC, want: "bar"},
+		{name: "wronly append", exists: true, flag: os.O_WRONLY | os.O_APPEND, want: "foobar"},
+		{name: "rdwr append", exists: true, flag: os.O_RDWR | os.O_APPEND, read: "foo", want: "foobar"},
+		{name: "rdonly append", exists: true, flag: os.O_RDONLY | os.O_APPEND, read: "foo", want: "foo"},
+		{name: "rdonly trunc", exists: true, flag: os.O_RDONLY | os.O_TRUNC, wantErr: os.ErrInvalid},
+		{name: "wronly rdwr", exists: true, flag: os.O_WRONLY | os.O_RDWR, wantErr: os.ErrInvalid},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+				t.Helper()
+
+				before := ""
+				if tc.exists {
+					before = "foo"
+					require.NoError(t, util.WriteFile(fs, "foo", []byte(before), 0o644))
+				}
+
+				f, err := fs.OpenFile("foo", tc.flag, 0o644)
+				if tc.wantErr != nil {
+					require.ErrorIs(t, err, tc.wantErr)
+					assert.Nil(t, f)
+
+					if tc.exists {
+						content, err := util.ReadFile(fs, "foo")
+						require.NoError(t, err)
+						assert.Equal(t, before, string(content))
+					}
+					return
+				}
+				require.NoError(t, err)
+
+				readable := tc.flag&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY
+				writable := tc.flag&(os.O_WRONLY|os.O_RDWR) != os.O_RDONLY
+
+				content, err := io.ReadAll(f)
+				if readable {
+					require.NoError(t, err)
+					assert.Equal(t, tc.read, string(content))
+				} else {
+					assert.Error(t, err)
+				}
+
+				_, err = f.Write([]byte("bar"))
+				if writable {
+					require.NoError(t, err)
+				} else {
+					assert.Error(t, err)
+				}
+				require.NoError(t, f.Close())
+
+				content, err = util.ReadFile(fs, "foo")
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, string(content))
+			})
+		})
+	}
+}
+
+func testOpenFileAppendIgnoresOffset(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		f, err := fs.OpenFile("foo", os.O_RDWR|os.O_APPEND, 0o644)
+		require.NoError(t, err)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("bar"))
+		require.NoError(t, err)
+
+		_, err = f.WriteAt([]byte("qux"), 0)
+		assert.Error(t, err)
+		require.NoError(t, f.Close())
+
+		content, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foobar", string(content))
+	})
+}
+
+func testPathErrors(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		assertPathError := func(err error, op string) {
+			t.Helper()
+			var perr *iofs.PathError
+			require.ErrorAs(t, err, &perr, op)
+			assert.NotEmpty(t, perr.Op, op)
+			assert.NotEmpty(t, perr.Path, op)
+		}
+
+		_, err := fs.Open("missing")
+		assertPathError(err, "open")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		_, err = fs.OpenFile("missing", os.O_RDONLY, 0)
+		assertPathError(err, "openfile")
+
+		_, err = fs.Stat("missing")
+		assertPathError(err, "stat")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		err = fs.Remove("missing")
+		assertPathError(err, "remove")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+		_, err = fs.OpenFile("foo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		assertPathError(err, "open excl")
+		assert.ErrorIs(t, err, os.ErrExist)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+		_, err = f.Write([]byte("foo"))
+		assertPathError(err, "write")
+		require.NoError(t, f.Close())
+
+		_, err = f.Read(make([]byte, 1))
+		assertPathError(err, "read closed")
+		assert.ErrorIs(t, err, os.ErrClosed)
+
+		err = fs.Rename("missing", "bar")
+		var lerr *os.LinkError
+		require.ErrorAs(t, err, &lerr)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func testWriteClose(t *testing.T, f File, content string) {
+	t.Helper()
+
+	written, err := f.Write([]byte(content))
+	assert.Equal(t, len(content), written)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func testReadClose(t *testing.T, f File, content string) {
+	t.Helper()
+	read, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, string(read), content)
+	require.NoError(t, f.Close())
+}
+
+func testFileWrite(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		n, err := f.Write([]byte("foo"))
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		all, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(all))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testFileWriteClose(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		require.NoError(t, f.Close())
+
+		_, err = f.Write([]byte("foo"))
+		assert.NotNil(t, err)
+	})
+}
+
+func testFileRead(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("foo"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		all, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(all))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testFileSync(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("foo"))
+		require.NoError(t, err)
+		require.NoError(t, f.Sync())
+		require.NoError(t, f.Close())
+
+		assert.ErrorIs(t, f.Sync(), os.ErrClosed)
+
+		content, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+	})
+}
+
+func testFileClosed(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("foo"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = io.ReadAll(f)
+		assert.NotNil(t, err)
+	})
+}
+
+func testFileNonRead(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("foo"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.OpenFile("foo", os.O_WRONLY, 0)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(f)
+		assert.NotNil(t, err)
+
+		require.NoError(t, f.Close())
+	})
+}
+
+func testFileSeekstart(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		testFileSeek(t, fs, 10, io.SeekStart)
+	})
+}
+
+func testFileSeekCurrent(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		testFileSeek(t, fs, 5, io.SeekCurrent)
+	})
+}
+
+func testFileSeekEnd(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		testFileSeek(t, fs, -26, io.SeekEnd)
+	})
+}
+
+func testFileSeek(t *testing.T, fs Basic, offset int64, whence int) {
+	t.Helper()
+	err := util.WriteFile(fs, "foo", []byte("0123456789abcdefghijklmnopqrstuvwxyz"), 0644)
+	require.NoError(t, err)
+
+	f, err := fs.Open("foo")
+	require.NoError(t, err)
+
+	some := make([]byte, 5)
+	_, err = f.Read(some)
+	require.NoError(t, err)
+	assert.Equal(t, "01234", string(some))
+
+	p, err := f.Seek(offset, whence)
+	require.NoError(t, err)
+	assert.Equal(t, 10, int(p))
+
+	all, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Len(t, all, 26)
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyz", string(all))
+	require.NoError(t, f.Close())
+}
+
+func testSeekToEndAndWrite(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		defaultMode := os.FileMode(0666)
+
+		f, err := fs.OpenFile("foo1", os.O_CREATE|os.O_TRUNC|os.O_RDWR, defaultMode)
+		require.NoError(t, err)
+		assert.Equal(t, "foo1", f.Name())
+
+		_, err = f.Seek(10, io.SeekEnd)
+		require.NoError(t, err)
+
+		n, err := f.Write([]byte(`TEST`))
+		require.NoError(t, err)
+		assert.Equal(t, 4, n)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		testReadClose(t, f, "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00TEST")
+	})
+}
+
+func testFileSeekClosed(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("foo"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = f.Seek(0, 0)
+		assert.NotNil(t, err)
+	})
+}
+
+func testFileCloseTwice(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		require.NoError(t, f.Close())
+		assert.Error(t, f.Close())
+	})
+}
+
+func testStat(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		mkdirParent(t, fs, "foo/bar")
+		err := util.WriteFile(fs, "foo/bar", []byte("foo"), customMode)
+		require.NoError(t, err)
+
+		fi, err := fs.Stat("foo/bar")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", fi.Name())
+		assert.Equal(t, int64(3), fi.Size())
+		assert.Equal(t, customMode, fi.Mode())
+		assert.False(t, fi.ModTime().IsZero())
+		assert.False(t, fi.IsDir())
+	})
+}
+
+func testStatNonExistent(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		fi, err := fs.Stat("non-existent")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Nil(t, fi)
+	})
+}
+
+func testRename(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", nil, 0644)
+		require.NoError(t, err)
+
+		err = fs.Rename("foo", "bar")
+		require.NoError(t, err)
+
+		foo, err := fs.Stat("foo")
+		assert.Nil(t, foo)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		bar, err := fs.Stat("bar")
+		require.NoError(t, err)
+		assert.NotNil(t, bar)
+	})
+}
+
+func testOpenAndWrite(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", nil, 0644)
+		require.NoError(t, err)
+
+		foo, err := fs.Open("foo")
+		assert.NotNil(t, foo)
+		require.NoError(t, err)
+
+		n, err := foo.Write([]byte("foo"))
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, n)
+
+		require.NoError(t, foo.Close())
+	})
+}
+
+func testOpenAndStat(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("foo"), 0644)
+		require.NoError(t, err)
+
+		foo, err := fs.Open("foo")
+		assert.NotNil(t, foo)
+		assert.Equal(t, "foo", foo.Name())
+		require.NoError(t, err)
+		require.NoError(t, foo.Close())
+
+		stat, err := fs.Stat("foo")
+		assert.NotNil(t, stat)
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", stat.Name())
+		assert.Equal(t, int64(3), stat.Size())
+	})
+}
+
+func testRemove(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		err = fs.Remove("foo")
+		require.NoError(t, err)
+	})
+}
+
+func testRemoveNonExisting(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := fs.Remove("NON-EXISTING")
+		assert.NotNil(t, err)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func testRemoveEmptyDir(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		mkdirParent(t, fs, "dir/foo")
+		err := util.WriteFile(fs, "dir/foo", nil, 0644)
+		require.NoError(t, err)
+		require.NoError(t, fs.Remove("dir/foo"))
+
+		require.NoError(t, fs.Remove("dir"))
+		_, err = fs.Stat("dir")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func testRootPaths(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+
+		// The root must not be removed even when empty.
+		for _, path := range rootPaths {
+			require.ErrorIs(t, fs.Remove(path), os.ErrInvalid, "%q", path)
+		}
+
+		err := util.WriteFile(fs, "foo", nil, 0o644)
+		require.NoError(t, err)
+
+		for _, path := range rootPaths {
+			fi, err := fs.Stat(path)
+			require.NoError(t, err, "%q", path)
+			assert.True(t, fi.IsDir(), "%q", path)
+
+			require.ErrorIs(t, fs.Remove(path), os.ErrInvalid, "%q", path)
+			require.ErrorIs(t, fs.Rename(path, "bar"), os.ErrInvalid, "%q", path)
+
+			_, err = fs.Create(path)
+			require.Error(t, err, "%q", path)
+		}
+
+		_, err = fs.Stat("foo")
+		require.NoError(t, err)
+	})
+}
+
+func testTrailingSeparator(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		mkdirParent(t, fs, "dir/foo")
+		err := util.WriteFile(fs, "dir/foo", nil, 0o644)
+		require.NoError(t, err)
+
+		fi, err := fs.Stat("dir/")
+		require.NoError(t, err)
+		assert.Equal(t, "dir", fi.Name())
+		assert.True(t, fi.IsDir())
+
+		require.NoError(t, fs.Remove("dir/foo"))
+		require.NoError(t, fs.Remove("dir/"))
+		_, err = fs.Stat("dir")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func testRemoveNotEmptyDir(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		mkdirParent(t, fs, "dir/foo")
+		err := util.WriteFile(fs, "dir/foo", nil, 0644)
+		require.NoError(t, err)
+
+		err = fs.Remove("dir")
+		var perr *os.PathError
+		require.ErrorAs(t, err, &perr)
+		// Windows and Plan 9 report it with errors of their own.
+		if runtime.GOOS != "windows" && runtime.GOOS != "plan9" {
+			assert.ErrorIs(t, err, errno.ENOTEMPTY)
+		}
+
+		_, err = fs.Stat("dir/foo")
+		require.NoError(t, err)
+	})
+}
+
+func testJoin(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		assert.Equal(t, fs.Join("foo", "bar"), fmt.Sprintf("foo%cbar", filepath.Separator))
+	})
+}
+
+func testReadAtOnReadWrite(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+		_, err = f.Write([]byte("abcdefg"))
+		require.NoError(t, err)
+
+		b := make([]byte, 3)
+		n, err := f.ReadAt(b, 2)
+		require.NoError(t, err)
+		assert.Equal(t, n, 3)
+		assert.Equal(t, string(b), "cde")
+		require.NoError(t, f.Close())
+	})
+}
+
+func testReadAtOnReadOnly(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("abcdefg"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		b := make([]byte, 3)
+		n, err := f.ReadAt(b, 2)
+		require.NoError(t, err)
+		assert.Equal(t, n, 3)
+		assert.Equal(t, string(b), "cde")
+		require.NoError(t, f.Close())
+	})
+}
+
+func testReadAtEOF(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("TEST"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		b := make([]byte, 5)
+		n, err := f.ReadAt(b, 0)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, n, 4)
+		assert.Equal(t, string(b), "TEST\x00")
+
+		err = f.Close()
+		require.NoError(t, err)
+	})
+}
+
+func testReadAtOffset(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("TEST"), 0644)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		o, err := f.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, o, int64(0))
+
+		b := make([]byte, 4)
+		n, err := f.ReadAt(b, 0)
+		require.NoError(t, err)
+		assert.Equal(t, n, 4)
+		assert.Equal(t, string(b), "TEST")
+
+		o, err = f.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, o, int64(0))
+
+		err = f.Close()
+		require.NoError(t, err)
+	})
+}
+
+func testWriteAt(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+		_, err = f.Write([]byte("abcdefg"))
+		require.NoError(t, err)
+
+		n, err := f.WriteAt([]byte("XY"), 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		o, err := f.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), o)
+		require.NoError(t, f.Close())
+
+		content, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "abXYefg", string(content))
+	})
+}
+
+func testWriteAtPastEnd(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		_, err = f.WriteAt([]byte("bar"), 3)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		content, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "\x00\x00\x00bar", string(content))
+	})
+}
+
+// testConcurrentReadWriteAt checks that ReadAt and WriteAt can be called
+// concurrently on the same handle, as with *os.File, without moving its
+// offset.
+func testConcurrentReadWriteAt(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		const workers, size = 8, 1 << 10
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 2*workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				b := bytes.Repeat([]byte{byte('a' + i)}, size)
+				if _, err := f.WriteAt(b, int64(i*size)); err != nil {
+					errs <- err
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				b := make([]byte, size)
+				if _, err := f.ReadAt(b, int64(i*size)); err != nil {
+					errs <- err
+					return
+				}
+				if want := bytes.Repeat([]byte{byte('a' + i)}, size); !bytes.Equal(b, want) {
+					errs <- fmt.Errorf("chunk %d: got %q", i, b[:8])
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+
+		o, err := f.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), o)
+		require.NoError(t, f.Close())
+	})
+}
+
+func testReadWriteLargeFile(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		size := 1 << 20
+
+		n, err := f.Write(bytes.Repeat([]byte("F"), size))
+		require.NoError(t, err)
+		assert.Equal(t, n, size)
+
+		require.NoError(t, f.Close())
+
+		f, err = fs.Open("foo")
+		require.NoError(t, err)
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, len(b), size)
+		require.NoError(t, f.Close())
+	})
+}
+
+func testWriteFile(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		err := util.WriteFile(fs, "foo", []byte("bar"), 0777)
+		require.NoError(t, err)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		wrote, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, string(wrote), "bar")
+
+		require.NoError(t, f.Close())
+	})
+}
+
+func testTruncate(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		for _, sz := range []int64{4, 7, 2, 30, 0, 1} {
+			err = f.Truncate(sz)
+			require.NoError(t, err)
+
+			bs, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, len(bs), int(sz))
+
+			_, err = f.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, f.Close())
+	})
+}
+
+func testFileStat(t *testing.T, factory Factory) {
+	eachBasicFS(t, factory, func(t *testing.T, fs Basic) {
+		t.Helper()
+		mkdirParent(t, fs, "dir/foo")
+		f, err := fs.Create("dir/foo")
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("foo"))
+		require.NoError(t, err)
+
+		fi, err := f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, "foo", fi.Name())
+		assert.Equal(t, int64(3), fi.Size())
+		assert.False(t, fi.IsDir())
+
+		require.NoError(t, f.Truncate(10))
+		fi, err = f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), fi.Size())
+		require.NoError(t, f.Close())
+
+		f, err = fs.Open("dir/foo")
+		require.NoError(t, err)
+		fi, err = f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), fi.Size())
+		require.NoError(t, f.Close())
+	})
+}
+
+var basicTests = []namedTest{
+	{"Create", testCreate},
+	{"CreateDepth", testCreateDepth},
+	{"CreateDepthAbsolute", testCreateDepthAbsolute},
+	{"CreateOverwrite", testCreateOverwrite},
+	{"FileNames", testFileNames},
+	{"CreateAndClose", testCreateAndClose},
+	{"Open", testOpen},
+	{"OpenNotExists", testOpenNotExists},
+	{"OpenFile", testOpenFile},
+	{"OpenFileNoTruncate", testOpenFileNoTruncate},
+	{"OpenFileAppend", testOpenFileAppend},
+	{"OpenFileReadWrite", testOpenFileReadWrite},
+	{"OpenFileWithModes", testOpenFileWithModes},
+	{"OpenFileFlags", testOpenFileFlags},
+	{"OpenFileAppendIgnoresOffset", testOpenFileAppendIgnoresOffset},
+	{"PathErrors", testPathErrors},
+	{"FileWrite", testFileWrite},
+	{"FileWriteClose", testFileWriteClose},
+	{"FileRead", testFileRead},
+	{"FileSync", testFileSync},
+	{"FileClosed", testFileClosed},
+	{"FileNonRead", testFileNonRead},
+	{"FileSeekstart", testFileSeekstart},
+	{"FileSeekCurrent", testFileSeekCurrent},
+	{"FileSeekEnd", testFileSeekEnd},
+	{"SeekToEndAndWrite", testSeekToEndAndWrite},
+	{"FileSeekClosed", testFileSeekClosed},
+	{"FileCloseTwice", testFileCloseTwice},
+	{"Stat", testStat},
+	{"StatNonExistent", testStatNonExistent},
+	{"Rename", testRename},
+	{"OpenAndWrite", testOpenAndWrite},
+	{"OpenAndStat", testOpenAndStat},
+	{"Remove", testRemove},
+	{"RemoveNonExisting", testRemoveNonExisting},
+	{"RemoveEmptyDir", testRemoveEmptyDir},
+	{"RemoveNotEmptyDir", testRemoveNotEmptyDir},
+	{"RootPaths", testRootPaths},
+	{"TrailingSeparator", testTrailingSeparator},
+	{"Join", testJoin},
+	{"ReadAtOnReadWrite", testReadAtOnReadWrite},
+	{"ReadAtOnReadOnly", testReadAtOnReadOnly},
+	{"ReadAtEOF", testReadAtEOF},
+	{"ReadAtOffset", testReadAtOffset},
+	{"WriteAt", testWriteAt},
+	{"WriteAtPastEnd", testWriteAtPastEnd},
+	{"ConcurrentReadWriteAt", testConcurrentReadWriteAt},
+	{"ReadWriteLargeFile", testReadWriteLargeFile},
+	{"WriteFile", testWriteFile},
+	{"Truncate", testTruncate},
+	{"FileStat", testFileStat},
+}
+
+// RunBasic runs the conformance tests of the billy.Basic interface against the
+// filesystems returned by factory.
+func RunBasic(t *testing.T, factory Factory) {
+	run(t, factory, basicTests)
+}
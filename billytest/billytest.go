@@ -0,0 +1,95 @@
+// Package billytest provides a conformance test suite for billy filesystems.
+//
+// The suite is the same one used to test the implementations in this
+// module, so third-party implementations can verify that they behave like
+// them:
+//
+//	func TestConformance(t *testing.T) {
+//		billytest.RunBasic(t, func(t *testing.T) billy.Filesystem {
+//			return myfs.New(t.TempDir())
+//		})
+//	}
+//
+// Each Run function covers one of the billy interfaces. Filesystems which
+// implement only some of them can be wrapped with polyfill.New, and tested
+// with the Run functions of the interfaces they do implement.
+//
+// The package is a module of its own, so that the dependencies of the suite
+// are not required by the users of billy. The test package in it runs the
+// suite against the filesystems and wrappers of billy, and its subpackages
+// test the helpers with the mock filesystems of the mock package.
+package billytest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new empty filesystem. It is called once per test, so
+// the tests do not interfere with each other. t can be used to create
+// temporary dirs, or to register cleanup functions.
+type Factory func(t *testing.T) billy.Filesystem
+
+type namedTest struct {
+	name string
+	fn   func(t *testing.T, factory Factory)
+}
+
+func run(t *testing.T, factory Factory, tests []namedTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, factory)
+		})
+	}
+}
+
+// RunAll runs all the conformance tests against the filesystems returned by
+// factory.
+func RunAll(t *testing.T, factory Factory) {
+	t.Helper()
+
+	t.Run("Basic", func(t *testing.T) { RunBasic(t, factory) })
+	t.Run("Dir", func(t *testing.T) { RunDir(t, factory) })
+	t.Run("Symlink", func(t *testing.T) { RunSymlink(t, factory) })
+	t.Run("Renamer", func(t *testing.T) { RunRenamer(t, factory) })
+	t.Run("Chroot", func(t *testing.T) { RunChroot(t, factory) })
+	t.Run("TempFile", func(t *testing.T) { RunTempFile(t, factory) })
+	t.Run("Filesystem", func(t *testing.T) { RunFilesystem(t, factory) })
+	t.Run("Capabilities", func(t *testing.T) { RunCapabilities(t, factory) })
+}
+
+// rootPaths are the paths naming the root of a filesystem, which billy
+// treats alike: the empty path is the same as ".".
+var rootPaths = []string{"", ".", "/", "./"}
+
+// explicitDirs reports whether fs requires the parent dir of a file to exist
+// to create it, see billy.PathProperties.
+func explicitDirs(fs billy.Basic) bool {
+	return billy.Introspect(fs).ExplicitDirs
+}
+
+// mkdirExplicit creates dir if fs requires the parent dirs to exist, so that
+// the tests which are not about the creation of the missing parents run
+// against the filesystems in both modes.
+func mkdirExplicit(t *testing.T, fs billy.Basic, dir string) {
+	t.Helper()
+
+	if !explicitDirs(fs) {
+		return
+	}
+
+	d, ok := fs.(billy.Dir)
+	require.True(t, ok, "filesystems with explicit dirs must implement billy.Dir")
+	require.NoError(t, d.MkdirAll(dir, 0o755))
+}
+
+// mkdirParent is like mkdirExplicit, for the parent dir of name.
+func mkdirParent(t *testing.T, fs billy.Basic, name string) {
+	t.Helper()
+	mkdirExplicit(t, fs, filepath.Dir(name))
+}
@@ -0,0 +1,178 @@
+package billytest
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eachCapability runs test against a new filesystem if it reports the
+// capability c, skipping it otherwise.
+func eachCapability(t *testing.T, factory Factory, c billy.Capability, test func(t *testing.T, fs billy.Filesystem)) {
+	t.Helper()
+
+	fs := factory(t)
+	if !billy.CapabilityCheck(fs, c) {
+		t.Skip("capability not reported by the filesystem")
+	}
+
+	test(t, fs)
+}
+
+func testWriteCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability, func(t *testing.T, fs billy.Filesystem) {
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("foo"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	})
+}
+
+func testReadCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.ReadCapability, func(t *testing.T, fs billy.Filesystem) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testReadAndWriteCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.ReadAndWriteCapability|billy.SeekCapability, func(t *testing.T, fs billy.Filesystem) {
+		f, err := fs.OpenFile("foo", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("foo"))
+		require.NoError(t, err)
+
+		_, err = f.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testSeekCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.ReadCapability|billy.SeekCapability, func(t *testing.T, fs billy.Filesystem) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foobar"), 0o644))
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		off, err := f.Seek(3, io.SeekStart)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), off)
+
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(content))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testTruncateCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.TruncateCapability, func(t *testing.T, fs billy.Filesystem) {
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("foobar"))
+		require.NoError(t, err)
+		require.NoError(t, f.Truncate(3))
+		require.NoError(t, f.Close())
+
+		fi, err := fs.Stat("foo")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), fi.Size())
+	})
+}
+
+func testLockCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.LockCapability, func(t *testing.T, fs billy.Filesystem) {
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		require.NoError(t, f.Lock())
+		require.NoError(t, f.Unlock())
+		require.NoError(t, f.Close())
+	})
+}
+
+func testSymlinkCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.SymlinkCapability, func(t *testing.T, fs billy.Filesystem) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+		require.NoError(t, fs.Symlink("foo", "bar"))
+
+		fi, err := fs.Lstat("bar")
+		require.NoError(t, err)
+		assert.NotZero(t, fi.Mode()&os.ModeSymlink)
+
+		b, err := util.ReadFile(fs, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(b))
+	})
+}
+
+func testChangeCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.ChangeCapability, func(t *testing.T, fs billy.Filesystem) {
+		change, ok := fs.(billy.Change)
+		require.True(t, ok, "ChangeCapability reported without the Change interface")
+
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, change.Chtimes("foo", mtime, mtime))
+
+		fi, err := fs.Stat("foo")
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()), "got %v", fi.ModTime())
+	})
+}
+
+func testRemoveAllCapability(t *testing.T, factory Factory) {
+	eachCapability(t, factory, billy.WriteCapability|billy.RemoveAllCapability, func(t *testing.T, fs billy.Filesystem) {
+		remover, ok := fs.(interface{ RemoveAll(path string) error })
+		require.True(t, ok, "RemoveAllCapability reported without a RemoveAll method")
+
+		require.NoError(t, fs.MkdirAll(fs.Join("foo", "bar"), 0o755))
+		require.NoError(t, util.WriteFile(fs, fs.Join("foo", "bar", "baz"), []byte("baz"), 0o644))
+		require.NoError(t, remover.RemoveAll("foo"))
+
+		_, err := fs.Stat("foo")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+var capabilitiesTests = []namedTest{
+	{"Write", testWriteCapability},
+	{"Read", testReadCapability},
+	{"ReadAndWrite", testReadAndWriteCapability},
+	{"Seek", testSeekCapability},
+	{"Truncate", testTruncateCapability},
+	{"Lock", testLockCapability},
+	{"Symlink", testSymlinkCapability},
+	{"Change", testChangeCapability},
+	{"RemoveAll", testRemoveAllCapability},
+}
+
+// RunCapabilities checks that the filesystems returned by factory support
+// the capabilities they report through the billy.Capable interface. The
+// tests of the capabilities which are not reported are skipped.
+func RunCapabilities(t *testing.T, factory Factory) {
+	run(t, factory, capabilitiesTests)
+}
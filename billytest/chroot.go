@@ -0,0 +1,205 @@
+package billytest
+
+import (
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	. "github.com/go-git/go-billy/v6" //nolint
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chrootFS interface {
+	Basic
+	Chroot
+}
+
+func eachChrootFS(t *testing.T, factory Factory, test func(t *testing.T, fs chrootFS)) {
+	t.Helper()
+	test(t, factory(t))
+}
+
+func testCreateWithChroot(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		mkdirExplicit(t, fs, "foo")
+		chroot, _ := fs.Chroot("foo")
+		f, err := chroot.Create("bar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		assert.Equal(t, f.Name(), "bar")
+
+		f, err = fs.Open("foo/bar")
+		require.NoError(t, err)
+		assert.Equal(t, f.Name(), fs.Join("foo", "bar"))
+		require.NoError(t, f.Close())
+	})
+}
+
+func testOpenWithChroot(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		mkdirExplicit(t, fs, "foo")
+		chroot, _ := fs.Chroot("foo")
+		f, err := chroot.Create("bar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		assert.Equal(t, f.Name(), "bar")
+
+		f, err = chroot.Open("bar")
+		require.NoError(t, err)
+		assert.Equal(t, f.Name(), "bar")
+		require.NoError(t, f.Close())
+	})
+}
+
+func testOpenOutOffBoundary(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		err := util.WriteFile(fs, "bar", nil, 0644)
+		require.NoError(t, err)
+
+		chroot, _ := fs.Chroot("foo")
+		f, err := chroot.Open("../bar")
+		assert.ErrorIs(t, err, ErrCrossedBoundary)
+		assert.Nil(t, f)
+	})
+}
+
+func testStatOutOffBoundary(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		err := util.WriteFile(fs, "bar", nil, 0644)
+		require.NoError(t, err)
+
+		chroot, _ := fs.Chroot("foo")
+		f, err := chroot.Stat("../bar")
+		assert.ErrorIs(t, err, ErrCrossedBoundary)
+		assert.Nil(t, f)
+	})
+}
+
+func testStatWithChroot(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		files := []string{"foo", "bar", "qux/baz", "qux/qux"}
+		for _, name := range files {
+			mkdirParent(t, fs, name)
+			err := util.WriteFile(fs, name, nil, 0644)
+			require.NoError(t, err)
+		}
+
+		// Some implementations detect directories based on a prefix
+		// for all files; it's easy to miss path separator handling there.
+		fi, err := fs.Stat("qu")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Nil(t, fi)
+
+		fi, err = fs.Stat("qux")
+		require.NoError(t, err)
+		assert.Equal(t, fi.Name(), "qux")
+		assert.True(t, fi.IsDir())
+
+		qux, _ := fs.Chroot("qux")
+
+		fi, err = qux.Stat("baz")
+		require.NoError(t, err)
+		assert.Equal(t, fi.Name(), "baz")
+		assert.False(t, fi.IsDir())
+
+		fi, err = qux.Stat("/baz")
+		require.NoError(t, err)
+		assert.Equal(t, fi.Name(), "baz")
+		assert.False(t, fi.IsDir())
+	})
+}
+
+func testRenameOutOffBoundary(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		mkdirParent(t, fs, "foo/foo")
+		err := util.WriteFile(fs, "foo/foo", nil, 0644)
+		require.NoError(t, err)
+
+		err = util.WriteFile(fs, "bar", nil, 0644)
+		require.NoError(t, err)
+
+		chroot, _ := fs.Chroot("foo")
+		err = chroot.Rename("../bar", "foo")
+		assert.ErrorIs(t, err, ErrCrossedBoundary)
+
+		err = chroot.Rename("foo", "../bar")
+		assert.ErrorIs(t, err, ErrCrossedBoundary)
+	})
+}
+
+func testRemoveOutOffBoundary(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		err := util.WriteFile(fs, "bar", nil, 0644)
+		require.NoError(t, err)
+
+		chroot, _ := fs.Chroot("foo")
+		err = chroot.Remove("../bar")
+		assert.ErrorIs(t, err, ErrCrossedBoundary)
+	})
+}
+
+func testBoundaryPathError(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		chroot, _ := fs.Chroot("foo")
+
+		_, err := chroot.Stat("../bar")
+		var perr *iofs.PathError
+		require.ErrorAs(t, err, &perr)
+		assert.Equal(t, "stat", perr.Op)
+		assert.Equal(t, "../bar", perr.Path)
+		assert.ErrorIs(t, err, ErrCrossedBoundary)
+
+		var berr *BoundaryError
+		require.ErrorAs(t, err, &berr)
+		assert.Equal(t, "../bar", berr.Path)
+		assert.Equal(t, chroot.Root(), berr.Base)
+	})
+}
+
+func testRoot(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+		assert.NotEmpty(t, fs.Root())
+	})
+}
+
+func testChrootRootPaths(t *testing.T, factory Factory) {
+	eachChrootFS(t, factory, func(t *testing.T, fs chrootFS) {
+		t.Helper()
+
+		for _, path := range rootPaths {
+			sub, err := fs.Chroot(path)
+			require.NoError(t, err, "%q", path)
+			assert.Equal(t, fs.Root(), sub.Root(), "%q", path)
+		}
+	})
+}
+
+var chrootTests = []namedTest{
+	{"CreateWithChroot", testCreateWithChroot},
+	{"OpenWithChroot", testOpenWithChroot},
+	{"OpenOutOffBoundary", testOpenOutOffBoundary},
+	{"StatOutOffBoundary", testStatOutOffBoundary},
+	{"StatWithChroot", testStatWithChroot},
+	{"RenameOutOffBoundary", testRenameOutOffBoundary},
+	{"RemoveOutOffBoundary", testRemoveOutOffBoundary},
+	{"BoundaryPathError", testBoundaryPathError},
+	{"Root", testRoot},
+	{"RootPaths", testChrootRootPaths},
+}
+
+// RunChroot runs the conformance tests of the billy.Chroot interface against the
+// filesystems returned by factory.
+func RunChroot(t *testing.T, factory Factory) {
+	run(t, factory, chrootTests)
+}
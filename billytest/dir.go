@@ -1,7 +1,6 @@
-package test
+package billytest
 
 import (
-	"fmt"
 	"os"
 	"strconv"
 	"testing"
@@ -17,16 +16,13 @@ type dirFS interface {
 	Dir
 }
 
-func eachDirFS(t *testing.T, test func(t *testing.T, fs dirFS)) {
-	for _, fs := range allFS(t.TempDir) {
-		t.Run(fmt.Sprintf("%T", fs), func(t *testing.T) {
-			test(t, fs)
-		})
-	}
+func eachDirFS(t *testing.T, factory Factory, test func(t *testing.T, fs dirFS)) {
+	t.Helper()
+	test(t, factory(t))
 }
 
-func TestDir_MkdirAll(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirMkdirAll(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("empty", os.FileMode(0755))
 		require.NoError(t, err)
 
@@ -36,8 +32,8 @@ func TestDir_MkdirAll(t *testing.T) {
 	})
 }
 
-func TestDir_MkdirAllNested(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirMkdirAllNested(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("foo/bar/baz", os.FileMode(0755))
 		require.NoError(t, err)
 
@@ -55,8 +51,8 @@ func TestDir_MkdirAllNested(t *testing.T) {
 	})
 }
 
-func TestDir_MkdirAllIdempotent(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirMkdirAllIdempotent(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("empty", 0755)
 		require.NoError(t, err)
 		fi, err := fs.Stat("empty")
@@ -72,11 +68,17 @@ func TestDir_MkdirAllIdempotent(t *testing.T) {
 	})
 }
 
-func TestDir_MkdirAllAndCreate(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirMkdirAllAndCreate(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("dir", os.FileMode(0755))
 		require.NoError(t, err)
 
+		if explicitDirs(fs) {
+			_, err := fs.Create("dir/bar/foo")
+			require.ErrorIs(t, err, os.ErrNotExist)
+			mkdirParent(t, fs, "dir/bar/foo")
+		}
+
 		f, err := fs.Create("dir/bar/foo")
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
@@ -87,8 +89,9 @@ func TestDir_MkdirAllAndCreate(t *testing.T) {
 	})
 }
 
-func TestDir_MkdirAllWithExistingFile(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirMkdirAllWithExistingFile(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
+		mkdirParent(t, fs, "dir/foo")
 		f, err := fs.Create("dir/foo")
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
@@ -102,8 +105,8 @@ func TestDir_MkdirAllWithExistingFile(t *testing.T) {
 	})
 }
 
-func TestDir_StatDir(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirStatDir(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("foo/bar", 0755)
 		require.NoError(t, err)
 
@@ -116,10 +119,11 @@ func TestDir_StatDir(t *testing.T) {
 	})
 }
 
-func TestDir_StatDeep(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirStatDeep(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 		for _, name := range files {
+			mkdirParent(t, fs, name)
 			err := util.WriteFile(fs, name, nil, 0644)
 			require.NoError(t, err)
 		}
@@ -142,10 +146,11 @@ func TestDir_StatDeep(t *testing.T) {
 	})
 }
 
-func TestDir_ReadDir(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirReadDir(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 		for _, name := range files {
+			mkdirParent(t, fs, name)
 			err := util.WriteFile(fs, name, nil, 0644)
 			require.NoError(t, err)
 		}
@@ -160,8 +165,56 @@ func TestDir_ReadDir(t *testing.T) {
 	})
 }
 
-func TestDir_ReadDirNested(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirReadDirRootPaths(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
+		files := []string{"foo", "qux/baz"}
+		for _, name := range files {
+			mkdirParent(t, fs, name)
+			err := util.WriteFile(fs, name, nil, 0644)
+			require.NoError(t, err)
+		}
+
+		for _, path := range rootPaths {
+			require.NoError(t, fs.MkdirAll(path, 0755), "%q", path)
+
+			infos, err := fs.ReadDir(path)
+			require.NoError(t, err, "%q", path)
+			require.Len(t, infos, 2, "%q", path)
+			assert.Equal(t, "foo", infos[0].Name())
+			assert.Equal(t, "qux", infos[1].Name())
+		}
+
+		infos, err := fs.ReadDir("qux/")
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.Equal(t, "baz", infos[0].Name())
+	})
+}
+
+func testDirReadDirSorted(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
+		names := []string{"qux", "foo", "Zed", "bar", "a.b", "a", "a-b", "10", "9"}
+		mkdirExplicit(t, fs, "dir")
+		for _, name := range names {
+			err := util.WriteFile(fs, fs.Join("dir", name), nil, 0644)
+			require.NoError(t, err)
+		}
+		require.NoError(t, fs.MkdirAll(fs.Join("dir", "baz"), 0755))
+
+		infos, err := fs.ReadDir("dir")
+		require.NoError(t, err)
+
+		got := make([]string, 0, len(infos))
+		for _, fi := range infos {
+			got = append(got, fi.Name())
+		}
+
+		assert.Equal(t, []string{"10", "9", "Zed", "a", "a-b", "a.b", "bar", "baz", "foo", "qux"}, got)
+	})
+}
+
+func testDirReadDirNested(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		maxNestedDirs := 100
 		path := "/"
 		for i := 0; i <= maxNestedDirs; i++ {
@@ -170,6 +223,7 @@ func TestDir_ReadDirNested(t *testing.T) {
 
 		files := []string{fs.Join(path, "f1"), fs.Join(path, "f2")}
 		for _, name := range files {
+			mkdirParent(t, fs, name)
 			err := util.WriteFile(fs, name, nil, 0644)
 			require.NoError(t, err)
 		}
@@ -189,8 +243,8 @@ func TestDir_ReadDirNested(t *testing.T) {
 	})
 }
 
-func TestDir_ReadDirWithMkDirAll(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirReadDirWithMkDirAll(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("qux", 0755)
 		require.NoError(t, err)
 
@@ -211,8 +265,8 @@ func TestDir_ReadDirWithMkDirAll(t *testing.T) {
 	})
 }
 
-func TestDir_ReadDirFileInfo(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirReadDirFileInfo(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := util.WriteFile(fs, "foo", []byte{'F', 'O', 'O'}, 0644)
 		require.NoError(t, err)
 
@@ -226,10 +280,11 @@ func TestDir_ReadDirFileInfo(t *testing.T) {
 	})
 }
 
-func TestDir_ReadDirFileInfoDirs(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirReadDirFileInfoDirs(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		files := []string{"qux/baz/foo"}
 		for _, name := range files {
+			mkdirParent(t, fs, name)
 			err := util.WriteFile(fs, name, []byte{'F', 'O', 'O'}, 0644)
 			require.NoError(t, err)
 		}
@@ -250,11 +305,17 @@ func TestDir_ReadDirFileInfoDirs(t *testing.T) {
 	})
 }
 
-func TestDir_RenameToDir(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirRenameToDir(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := util.WriteFile(fs, "foo", nil, 0644)
 		require.NoError(t, err)
 
+		if explicitDirs(fs) {
+			err = fs.Rename("foo", "bar/qux")
+			require.ErrorIs(t, err, os.ErrNotExist)
+			mkdirParent(t, fs, "bar/qux")
+		}
+
 		err = fs.Rename("foo", "bar/qux")
 		require.NoError(t, err)
 
@@ -272,8 +333,8 @@ func TestDir_RenameToDir(t *testing.T) {
 	})
 }
 
-func TestDir_RenameDir(t *testing.T) {
-	eachDirFS(t, func(t *testing.T, fs dirFS) {
+func testDirRenameDir(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
 		err := fs.MkdirAll("foo", 0755)
 		require.NoError(t, err)
 
@@ -300,3 +361,61 @@ func TestDir_RenameDir(t *testing.T) {
 		assert.NotNil(t, bar)
 	})
 }
+
+func testDirSyncDir(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
+		require.NoError(t, fs.MkdirAll("foo", 0o755))
+
+		d, ok := fs.(DirSyncer)
+		require.True(t, ok)
+		require.NoError(t, d.SyncDir("foo"))
+		require.NoError(t, d.SyncDir(""))
+
+		assert.ErrorIs(t, d.SyncDir("bar"), os.ErrNotExist)
+	})
+}
+
+func testDirRenameDurable(t *testing.T, factory Factory) {
+	eachDirFS(t, factory, func(t *testing.T, fs dirFS) {
+		mkdirParent(t, fs, "foo/bar")
+		mkdirParent(t, fs, "qux/baz")
+		require.NoError(t, util.WriteFile(fs, "foo/bar", []byte("foo"), 0o644))
+
+		err := util.RenameDurable(fs, "foo/bar", "qux/baz")
+		require.NoError(t, err)
+
+		content, err := util.ReadFile(fs, "qux/baz")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+
+		_, err = fs.Stat("foo/bar")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+var dirTests = []namedTest{
+	{"MkdirAll", testDirMkdirAll},
+	{"MkdirAllNested", testDirMkdirAllNested},
+	{"MkdirAllIdempotent", testDirMkdirAllIdempotent},
+	{"MkdirAllAndCreate", testDirMkdirAllAndCreate},
+	{"MkdirAllWithExistingFile", testDirMkdirAllWithExistingFile},
+	{"StatDir", testDirStatDir},
+	{"StatDeep", testDirStatDeep},
+	{"ReadDir", testDirReadDir},
+	{"ReadDirRootPaths", testDirReadDirRootPaths},
+	{"ReadDirSorted", testDirReadDirSorted},
+	{"ReadDirNested", testDirReadDirNested},
+	{"ReadDirWithMkDirAll", testDirReadDirWithMkDirAll},
+	{"ReadDirFileInfo", testDirReadDirFileInfo},
+	{"ReadDirFileInfoDirs", testDirReadDirFileInfoDirs},
+	{"RenameToDir", testDirRenameToDir},
+	{"RenameDir", testDirRenameDir},
+	{"SyncDir", testDirSyncDir},
+	{"RenameDurable", testDirRenameDurable},
+}
+
+// RunDir runs the conformance tests of the billy.Dir interface against the
+// filesystems returned by factory.
+func RunDir(t *testing.T, factory Factory) {
+	run(t, factory, dirTests)
+}
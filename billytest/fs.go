@@ -1,9 +1,7 @@
-package test
+package billytest
 
 import (
-	"fmt"
 	"os"
-	"runtime"
 	"testing"
 
 	. "github.com/go-git/go-billy/v6" //nolint
@@ -12,19 +10,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func eachFS(t *testing.T, test func(t *testing.T, fs Filesystem)) {
-	for _, fs := range allFS(t.TempDir) {
-		t.Run(fmt.Sprintf("%T", fs), func(t *testing.T) {
-			test(t, fs)
-		})
-	}
+func eachFS(t *testing.T, factory Factory, test func(t *testing.T, fs Filesystem)) {
+	t.Helper()
+	test(t, factory(t))
 }
 
-func TestFS_SymlinkToDir(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSSymlinkToDir(t *testing.T, factory Factory) {
+	eachCapability(t, factory, SymlinkCapability, func(t *testing.T, fs Filesystem) {
 		err := fs.MkdirAll("dir", 0755)
 		require.NoError(t, err)
 
@@ -38,11 +30,9 @@ func TestFS_SymlinkToDir(t *testing.T) {
 	})
 }
 
-func TestFS_SymlinkReadDir(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSSymlinkReadDir(t *testing.T, factory Factory) {
+	eachCapability(t, factory, SymlinkCapability, func(t *testing.T, fs Filesystem) {
+		mkdirParent(t, fs, "dir/file")
 		err := util.WriteFile(fs, "dir/file", []byte("foo"), 0644)
 		require.NoError(t, err)
 
@@ -59,8 +49,8 @@ func TestFS_SymlinkReadDir(t *testing.T) {
 	})
 }
 
-func TestFS_CreateWithExistantDir(t *testing.T) {
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSCreateWithExistantDir(t *testing.T, factory Factory) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		err := fs.MkdirAll("foo", 0644)
 		require.NoError(t, err)
 
@@ -70,10 +60,11 @@ func TestFS_CreateWithExistantDir(t *testing.T) {
 	})
 }
 
-func TestFS_ReadDirWithChroot(t *testing.T) {
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSReadDirWithChroot(t *testing.T, factory Factory) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 		for _, name := range files {
+			mkdirParent(t, fs, name)
 			err := util.WriteFile(fs, name, nil, 0644)
 			require.NoError(t, err)
 		}
@@ -86,11 +77,9 @@ func TestFS_ReadDirWithChroot(t *testing.T) {
 	})
 }
 
-func TestFS_SymlinkWithChrootBasic(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSSymlinkWithChrootBasic(t *testing.T, factory Factory) {
+	eachCapability(t, factory, SymlinkCapability, func(t *testing.T, fs Filesystem) {
+		mkdirExplicit(t, fs, "qux")
 		qux, _ := fs.Chroot("/qux")
 
 		err := util.WriteFile(qux, "file", nil, 0644)
@@ -109,11 +98,9 @@ func TestFS_SymlinkWithChrootBasic(t *testing.T) {
 	})
 }
 
-func TestFS_SymlinkWithChrootCrossBounders(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSSymlinkWithChrootCrossBounders(t *testing.T, factory Factory) {
+	eachCapability(t, factory, SymlinkCapability, func(t *testing.T, fs Filesystem) {
+		mkdirExplicit(t, fs, "qux/qux")
 		qux, _ := fs.Chroot("/qux")
 		err := util.WriteFile(fs, "file", []byte("foo"), customMode)
 		require.NoError(t, err)
@@ -127,11 +114,9 @@ func TestFS_SymlinkWithChrootCrossBounders(t *testing.T) {
 	})
 }
 
-func TestFS_ReadDirWithLink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSReadDirWithLink(t *testing.T, factory Factory) {
+	eachCapability(t, factory, SymlinkCapability, func(t *testing.T, fs Filesystem) {
+		mkdirParent(t, fs, "foo/bar")
 		err := util.WriteFile(fs, "foo/bar", []byte("foo"), customMode)
 		require.NoError(t, err)
 
@@ -144,14 +129,14 @@ func TestFS_ReadDirWithLink(t *testing.T) {
 	})
 }
 
-func TestFS_RemoveAllNonExistent(t *testing.T) {
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSRemoveAllNonExistent(t *testing.T, factory Factory) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		require.NoError(t, util.RemoveAll(fs, "non-existent"))
 	})
 }
 
-func TestFS_RemoveAllEmptyDir(t *testing.T) {
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSRemoveAllEmptyDir(t *testing.T, factory Factory) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		require.NoError(t, fs.MkdirAll("empty", os.FileMode(0755)))
 		require.NoError(t, util.RemoveAll(fs, "empty"))
 		_, err := fs.Stat("empty")
@@ -160,7 +145,7 @@ func TestFS_RemoveAllEmptyDir(t *testing.T) {
 	})
 }
 
-func TestFS_RemoveAll(t *testing.T) {
+func testFSRemoveAll(t *testing.T, factory Factory) {
 	fnames := []string{
 		"foo/1",
 		"foo/2",
@@ -172,8 +157,9 @@ func TestFS_RemoveAll(t *testing.T) {
 		"foo/bar/baz/qux/3",
 	}
 
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		for _, fname := range fnames {
+			mkdirParent(t, fs, fname)
 			err := util.WriteFile(fs, fname, nil, 0644)
 			require.NoError(t, err)
 		}
@@ -187,7 +173,7 @@ func TestFS_RemoveAll(t *testing.T) {
 	})
 }
 
-func TestFS_RemoveAllRelative(t *testing.T) {
+func testFSRemoveAllRelative(t *testing.T, factory Factory) {
 	fnames := []string{
 		"foo/1",
 		"foo/2",
@@ -199,8 +185,9 @@ func TestFS_RemoveAllRelative(t *testing.T) {
 		"foo/bar/baz/qux/3",
 	}
 
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		for _, fname := range fnames {
+			mkdirParent(t, fs, fname)
 			err := util.WriteFile(fs, fname, nil, 0644)
 			require.NoError(t, err)
 		}
@@ -214,8 +201,8 @@ func TestFS_RemoveAllRelative(t *testing.T) {
 	})
 }
 
-func TestFS_ReadDir(t *testing.T) {
-	eachFS(t, func(t *testing.T, fs Filesystem) {
+func testFSReadDir(t *testing.T, factory Factory) {
+	eachFS(t, factory, func(t *testing.T, fs Filesystem) {
 		err := fs.MkdirAll("qux", 0755)
 		require.NoError(t, err)
 
@@ -234,3 +221,24 @@ func TestFS_ReadDir(t *testing.T) {
 		assert.Len(t, info, 2)
 	})
 }
+
+var fsTests = []namedTest{
+	{"SymlinkToDir", testFSSymlinkToDir},
+	{"SymlinkReadDir", testFSSymlinkReadDir},
+	{"CreateWithExistantDir", testFSCreateWithExistantDir},
+	{"ReadDirWithChroot", testFSReadDirWithChroot},
+	{"SymlinkWithChrootBasic", testFSSymlinkWithChrootBasic},
+	{"SymlinkWithChrootCrossBounders", testFSSymlinkWithChrootCrossBounders},
+	{"ReadDirWithLink", testFSReadDirWithLink},
+	{"RemoveAllNonExistent", testFSRemoveAllNonExistent},
+	{"RemoveAllEmptyDir", testFSRemoveAllEmptyDir},
+	{"RemoveAll", testFSRemoveAll},
+	{"RemoveAllRelative", testFSRemoveAllRelative},
+	{"ReadDir", testFSReadDir},
+}
+
+// RunFilesystem runs the conformance tests of the billy.Filesystem interface against the
+// filesystems returned by factory.
+func RunFilesystem(t *testing.T, factory Factory) {
+	run(t, factory, fsTests)
+}
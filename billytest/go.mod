@@ -0,0 +1,23 @@
+module github.com/go-git/go-billy/v6/billytest
+
+// go-git supports the last 3 stable Go versions.
+go 1.24
+
+require (
+	github.com/go-git/go-billy/v6 v6.0.0
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	github.com/cyphar/filepath-securejoin v0.4.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The suite is developed along with the filesystems it tests. The replace
+// directive only applies when working in this tree: the modules requiring
+// billytest get the billy release required above, or a later one. Bump it to
+// the first release shipping the APIs the suite starts relying on.
+replace github.com/go-git/go-billy/v6 => ../
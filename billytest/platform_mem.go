@@ -0,0 +1,10 @@
+//go:build wasip1 || wasm || js
+
+package billytest
+
+import "io/fs"
+
+var (
+	customMode            fs.FileMode = 0o600
+	expectedSymlinkTarget             = "/dir/file"
+)
@@ -0,0 +1,11 @@
+//go:build !windows && !wasip1 && !js && !wasp
+// +build !windows,!wasip1,!js,!wasp
+
+package billytest
+
+import "io/fs"
+
+var (
+	customMode            fs.FileMode = 0o755
+	expectedSymlinkTarget             = "/dir/file"
+)
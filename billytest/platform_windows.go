@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package billytest
+
+import "io/fs"
+
+var (
+	customMode            fs.FileMode = 0o666
+	expectedSymlinkTarget             = "\\dir\\file"
+)
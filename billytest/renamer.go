@@ -0,0 +1,148 @@
+package billytest
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	. "github.com/go-git/go-billy/v6" //nolint
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type renamerFS interface {
+	Filesystem
+	Renamer
+}
+
+// eachRenamerFS runs test against a new filesystem if it implements Renamer,
+// skipping it otherwise, or if the platform does not support it.
+func eachRenamerFS(t *testing.T, factory Factory, test func(t *testing.T, fs renamerFS)) {
+	t.Helper()
+
+	fs, ok := factory(t).(renamerFS)
+	if !ok {
+		t.Skip("billy.Renamer not implemented by the filesystem")
+	}
+
+	if err := fs.RenameExchange("probe-a", "probe-b"); errors.Is(err, ErrNotSupported) {
+		t.Skip("billy.Renamer not supported by the filesystem")
+	}
+
+	test(t, fs)
+}
+
+func testRenameNoReplace(t *testing.T, factory Factory) {
+	eachRenamerFS(t, factory, func(t *testing.T, fs renamerFS) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		err := fs.RenameNoReplace("foo", "bar")
+		require.NoError(t, err)
+
+		_, err = fs.Stat("foo")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		data, err := util.ReadFile(fs, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(data))
+	})
+}
+
+func testRenameNoReplaceExisting(t *testing.T, factory Factory) {
+	eachRenamerFS(t, factory, func(t *testing.T, fs renamerFS) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+		require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+
+		err := fs.RenameNoReplace("foo", "bar")
+		assert.ErrorIs(t, err, os.ErrExist)
+
+		data, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(data))
+
+		data, err = util.ReadFile(fs, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(data))
+	})
+}
+
+func testRenameNoReplaceNonExistent(t *testing.T, factory Factory) {
+	eachRenamerFS(t, factory, func(t *testing.T, fs renamerFS) {
+		err := fs.RenameNoReplace("foo", "bar")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func testRenameExchange(t *testing.T, factory Factory) {
+	eachRenamerFS(t, factory, func(t *testing.T, fs renamerFS) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+		require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+
+		err := fs.RenameExchange("foo", "bar")
+		require.NoError(t, err)
+
+		data, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(data))
+
+		data, err = util.ReadFile(fs, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(data))
+	})
+}
+
+func testRenameExchangeDir(t *testing.T, factory Factory) {
+	eachRenamerFS(t, factory, func(t *testing.T, fs renamerFS) {
+		mkdirParent(t, fs, "dir/qux")
+		require.NoError(t, util.WriteFile(fs, "dir/qux", []byte("qux"), 0o644))
+		require.NoError(t, util.WriteFile(fs, "file", []byte("file"), 0o644))
+
+		err := fs.RenameExchange("dir", "file")
+		require.NoError(t, err)
+
+		fi, err := fs.Stat("file")
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		data, err := util.ReadFile(fs, "file/qux")
+		require.NoError(t, err)
+		assert.Equal(t, "qux", string(data))
+
+		data, err = util.ReadFile(fs, "dir")
+		require.NoError(t, err)
+		assert.Equal(t, "file", string(data))
+
+		_, err = fs.Stat("dir/qux")
+		assert.Error(t, err)
+	})
+}
+
+func testRenameExchangeNonExistent(t *testing.T, factory Factory) {
+	eachRenamerFS(t, factory, func(t *testing.T, fs renamerFS) {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		err := fs.RenameExchange("foo", "bar")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		data, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(data))
+	})
+}
+
+var renamerTests = []namedTest{
+	{"RenameNoReplace", testRenameNoReplace},
+	{"RenameNoReplaceExisting", testRenameNoReplaceExisting},
+	{"RenameNoReplaceNonExistent", testRenameNoReplaceNonExistent},
+	{"RenameExchange", testRenameExchange},
+	{"RenameExchangeDir", testRenameExchangeDir},
+	{"RenameExchangeNonExistent", testRenameExchangeNonExistent},
+}
+
+// RunRenamer runs the conformance tests of the billy.Renamer interface
+// against the filesystems returned by factory. They are skipped if the
+// filesystems do not implement it, or return billy.ErrNotSupported.
+func RunRenamer(t *testing.T, factory Factory) {
+	run(t, factory, renamerTests)
+}
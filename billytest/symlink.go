@@ -1,13 +1,12 @@
-package test
+package billytest
 
 import (
-	"fmt"
 	"io"
 	"os"
-	"runtime"
 	"testing"
 
 	. "github.com/go-git/go-billy/v6" //nolint
+	"github.com/go-git/go-billy/v6/internal/errno"
 	"github.com/go-git/go-billy/v6/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,20 +17,15 @@ type symlinkFS interface {
 	Symlink
 }
 
-func eachSymlinkFS(t *testing.T, test func(t *testing.T, fs symlinkFS)) {
-	for _, fs := range allFS(t.TempDir) {
-		t.Run(fmt.Sprintf("%T", fs), func(t *testing.T) {
-			test(t, fs)
-		})
-	}
+func eachSymlinkFS(t *testing.T, factory Factory, test func(t *testing.T, fs symlinkFS)) {
+	t.Helper()
+	eachCapability(t, factory, SymlinkCapability, func(t *testing.T, fs Filesystem) {
+		test(t, fs)
+	})
 }
 
-func TestSymlink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testSymlink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := util.WriteFile(fs, "file", nil, 0644)
 		require.NoError(t, err)
 
@@ -48,12 +42,10 @@ func TestSymlink(t *testing.T) {
 	})
 }
 
-func TestSymlinkCrossDirs(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testSymlinkCrossDirs(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "foo/file")
+		mkdirParent(t, fs, "bar/link")
 		err := util.WriteFile(fs, "foo/file", nil, 0644)
 		require.NoError(t, err)
 
@@ -66,12 +58,8 @@ func TestSymlinkCrossDirs(t *testing.T) {
 	})
 }
 
-func TestSymlinkNested(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testSymlinkNested(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := util.WriteFile(fs, "file", []byte("hello world!"), 0644)
 		require.NoError(t, err)
 
@@ -88,12 +76,8 @@ func TestSymlinkNested(t *testing.T) {
 	})
 }
 
-func TestSymlinkWithNonExistentdTarget(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testSymlinkWithNonExistentdTarget(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := fs.Symlink("file", "link")
 		require.NoError(t, err)
 
@@ -102,12 +86,8 @@ func TestSymlinkWithNonExistentdTarget(t *testing.T) {
 	})
 }
 
-func TestSymlinkWithExistingLink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testSymlinkWithExistingLink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := util.WriteFile(fs, "link", nil, 0644)
 		require.NoError(t, err)
 
@@ -116,12 +96,9 @@ func TestSymlinkWithExistingLink(t *testing.T) {
 	})
 }
 
-func TestOpenWithSymlinkToRelativePath(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testOpenWithSymlinkToRelativePath(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "dir/file")
 		err := util.WriteFile(fs, "dir/file", []byte("foo"), 0644)
 		require.NoError(t, err)
 
@@ -138,15 +115,9 @@ func TestOpenWithSymlinkToRelativePath(t *testing.T) {
 	})
 }
 
-func TestOpenWithSymlinkToAbsolutePath(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	if runtime.GOOS == "wasip1" {
-		t.Skip("skipping on wasip1")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testOpenWithSymlinkToAbsolutePath(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "dir/file")
 		err := util.WriteFile(fs, "dir/file", []byte("foo"), 0644)
 		require.NoError(t, err)
 
@@ -163,12 +134,8 @@ func TestOpenWithSymlinkToAbsolutePath(t *testing.T) {
 	})
 }
 
-func TestReadlink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testReadlink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := util.WriteFile(fs, "file", nil, 0644)
 		require.NoError(t, err)
 
@@ -177,12 +144,9 @@ func TestReadlink(t *testing.T) {
 	})
 }
 
-func TestReadlinkWithRelativePath(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testReadlinkWithRelativePath(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "dir/file")
 		err := util.WriteFile(fs, "dir/file", nil, 0644)
 		require.NoError(t, err)
 
@@ -195,15 +159,9 @@ func TestReadlinkWithRelativePath(t *testing.T) {
 	})
 }
 
-func TestReadlinkWithAbsolutePath(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-	if runtime.GOOS == "wasip1" {
-		t.Skip("skipping on wasip1")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testReadlinkWithAbsolutePath(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "dir/file")
 		err := util.WriteFile(fs, "dir/file", nil, 0644)
 		require.NoError(t, err)
 
@@ -216,12 +174,8 @@ func TestReadlinkWithAbsolutePath(t *testing.T) {
 	})
 }
 
-func TestReadlinkWithNonExistentTarget(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testReadlinkWithNonExistentTarget(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := fs.Symlink("file", "link")
 		require.NoError(t, err)
 
@@ -231,23 +185,16 @@ func TestReadlinkWithNonExistentTarget(t *testing.T) {
 	})
 }
 
-func TestReadlinkWithNonExistentLink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testReadlinkWithNonExistentLink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		_, err := fs.Readlink("link")
 		assert.Equal(t, os.IsNotExist(err), true)
 	})
 }
 
-func TestStatLink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testStatLink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "foo/bar")
 		err := util.WriteFile(fs, "foo/bar", []byte("foo"), customMode)
 		require.NoError(t, err)
 
@@ -264,8 +211,9 @@ func TestStatLink(t *testing.T) {
 	})
 }
 
-func TestLstat(t *testing.T) {
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testLstat(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "foo/bar")
 		err := util.WriteFile(fs, "foo/bar", []byte("foo"), customMode)
 		require.NoError(t, err)
 
@@ -279,12 +227,9 @@ func TestLstat(t *testing.T) {
 	})
 }
 
-func TestLstatLink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testLstatLink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		mkdirParent(t, fs, "foo/bar")
 		err := util.WriteFile(fs, "foo/bar", []byte("fosddddaaao"), customMode)
 		require.NoError(t, err)
 		err = fs.Symlink("bar", "foo/qux")
@@ -299,12 +244,24 @@ func TestLstatLink(t *testing.T) {
 	})
 }
 
-func TestRenameWithSymlink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
+func testLstatDanglingLink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		err := fs.Symlink("missing", "link")
+		require.NoError(t, err)
+
+		fi, err := fs.Lstat("link")
+		require.NoError(t, err)
+		assert.Equal(t, "link", fi.Name())
+		assert.NotZero(t, fi.Mode()&os.ModeSymlink)
+		assert.False(t, fi.IsDir())
+
+		_, err = fs.Stat("link")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
 
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testRenameWithSymlink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := fs.Symlink("file", "link")
 		require.NoError(t, err)
 
@@ -316,12 +273,8 @@ func TestRenameWithSymlink(t *testing.T) {
 	})
 }
 
-func TestRemoveWithSymlink(t *testing.T) {
-	if runtime.GOOS == "plan9" {
-		t.Skip("skipping on Plan 9; symlinks are not supported")
-	}
-
-	eachSymlinkFS(t, func(t *testing.T, fs symlinkFS) {
+func testRemoveWithSymlink(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
 		err := util.WriteFile(fs, "file", []byte("foo"), 0644)
 		require.NoError(t, err)
 
@@ -341,3 +294,55 @@ func TestRemoveWithSymlink(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func testSymlinkLoop(t *testing.T, factory Factory) {
+	eachSymlinkFS(t, factory, func(t *testing.T, fs symlinkFS) {
+		err := fs.Symlink("self", "self")
+		require.NoError(t, err)
+
+		err = fs.Symlink("b", "a")
+		require.NoError(t, err)
+
+		err = fs.Symlink("a", "b")
+		require.NoError(t, err)
+
+		for _, name := range []string{"self", "a"} {
+			_, err = fs.Stat(name)
+			assert.ErrorIs(t, err, errno.ELOOP, name)
+
+			_, err = fs.Open(name)
+			assert.ErrorIs(t, err, errno.ELOOP, name)
+
+			_, err = fs.Lstat(name)
+			assert.NoError(t, err, name)
+		}
+	})
+}
+
+var symlinkTests = []namedTest{
+	{"Symlink", testSymlink},
+	{"SymlinkCrossDirs", testSymlinkCrossDirs},
+	{"SymlinkNested", testSymlinkNested},
+	{"SymlinkWithNonExistentdTarget", testSymlinkWithNonExistentdTarget},
+	{"SymlinkWithExistingLink", testSymlinkWithExistingLink},
+	{"SymlinkLoop", testSymlinkLoop},
+	{"OpenWithSymlinkToRelativePath", testOpenWithSymlinkToRelativePath},
+	{"OpenWithSymlinkToAbsolutePath", testOpenWithSymlinkToAbsolutePath},
+	{"Readlink", testReadlink},
+	{"ReadlinkWithRelativePath", testReadlinkWithRelativePath},
+	{"ReadlinkWithAbsolutePath", testReadlinkWithAbsolutePath},
+	{"ReadlinkWithNonExistentTarget", testReadlinkWithNonExistentTarget},
+	{"ReadlinkWithNonExistentLink", testReadlinkWithNonExistentLink},
+	{"StatLink", testStatLink},
+	{"Lstat", testLstat},
+	{"LstatLink", testLstatLink},
+	{"LstatDanglingLink", testLstatDanglingLink},
+	{"RenameWithSymlink", testRenameWithSymlink},
+	{"RemoveWithSymlink", testRemoveWithSymlink},
+}
+
+// RunSymlink runs the conformance tests of the billy.Symlink interface against the
+// filesystems returned by factory.
+func RunSymlink(t *testing.T, factory Factory) {
+	run(t, factory, symlinkTests)
+}
@@ -0,0 +1,199 @@
+package billytest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tempFS interface {
+	billy.Basic
+	billy.TempFile
+}
+
+func eachTempFS(t *testing.T, factory Factory, test func(t *testing.T, fs tempFS)) {
+	t.Helper()
+	test(t, factory(t))
+}
+
+func testTempFile(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		f, err := fs.TempFile("", "bar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		assert.NotEqual(t, strings.Index(f.Name(), "bar"), -1)
+	})
+}
+
+func testTempFileWithPath(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "foo")
+		f, err := fs.TempFile("foo", "bar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		assert.True(t, strings.HasPrefix(f.Name(), fs.Join("foo", "bar")))
+		assert.Equal(t, f.Name(), f.OpenedPath())
+	})
+}
+
+func testTempFileFullWithPath(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "foo")
+		f, err := fs.TempFile("/foo", "bar")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		assert.NotEqual(t, strings.Index(f.Name(), fs.Join("foo", "bar")), -1)
+	})
+}
+
+func testRemoveTempFile(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "test-dir")
+		f, err := fs.TempFile("test-dir", "test-prefix")
+		require.NoError(t, err)
+
+		fn := f.Name()
+		require.NoError(t, f.Close())
+		require.NoError(t, fs.Remove(fn))
+	})
+}
+
+func testRenameTempFile(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "test-dir")
+		f, err := fs.TempFile("test-dir", "test-prefix")
+		require.NoError(t, err)
+
+		fn := f.Name()
+		require.NoError(t, f.Close())
+		require.NoError(t, fs.Rename(fn, "other-path"))
+	})
+}
+
+func testTempFileMany(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "test-dir")
+		for i := 0; i < 1024; i++ {
+			var files []billy.File
+
+			for j := 0; j < 100; j++ {
+				f, err := fs.TempFile("test-dir", "test-prefix")
+				require.NoError(t, err)
+				files = append(files, f)
+			}
+
+			for _, f := range files {
+				require.NoError(t, f.Close())
+				require.NoError(t, fs.Remove(f.Name()))
+			}
+		}
+	})
+}
+
+func testTempFileManyWithUtil(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "test-dir")
+		for i := 0; i < 1024; i++ {
+			var files []billy.File
+
+			for j := 0; j < 100; j++ {
+				f, err := util.TempFile(fs, "test-dir", "test-prefix")
+				require.NoError(t, err)
+				files = append(files, f)
+			}
+
+			for _, f := range files {
+				require.NoError(t, f.Close())
+				require.NoError(t, fs.Remove(f.Name()))
+			}
+		}
+	})
+}
+
+func testTempDir(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		if explicitDirs(fs) {
+			_, err := fs.TempDir("foo", "bar")
+			require.ErrorIs(t, err, os.ErrNotExist)
+			mkdirExplicit(t, fs, "foo")
+		}
+
+		name, err := fs.TempDir("foo", "bar")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(name, fs.Join("foo", "bar")))
+
+		fi, err := fs.Stat(name)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		other, err := fs.TempDir("foo", "bar")
+		require.NoError(t, err)
+		assert.NotEqual(t, name, other)
+	})
+}
+
+func testCreateTemp(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "foo")
+		f, err := fs.CreateTemp("foo", "bar*.txt")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		assert.True(t, strings.HasPrefix(f.Name(), fs.Join("foo", "bar")))
+		assert.True(t, strings.HasSuffix(f.Name(), ".txt"))
+		assert.NotEqual(t, fs.Join("foo", "bar.txt"), f.Name())
+		assert.Equal(t, f.Name(), f.OpenedPath())
+
+		fi, err := fs.Stat(f.Name())
+		require.NoError(t, err)
+		assert.True(t, fi.Mode().IsRegular())
+
+		_, err = fs.CreateTemp("foo", "bar/*")
+		assert.Error(t, err)
+	})
+}
+
+func testMkdirTemp(t *testing.T, factory Factory) {
+	eachTempFS(t, factory, func(t *testing.T, fs tempFS) {
+		mkdirExplicit(t, fs, "foo")
+		name, err := fs.MkdirTemp("foo", "bar*.d")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(name, fs.Join("foo", "bar")))
+		assert.True(t, strings.HasSuffix(name, ".d"))
+
+		fi, err := fs.Stat(name)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		other, err := fs.MkdirTemp("foo", "bar*.d")
+		require.NoError(t, err)
+		assert.NotEqual(t, name, other)
+	})
+}
+
+var tempfileTests = []namedTest{
+	{"TempFile", testTempFile},
+	{"TempFileWithPath", testTempFileWithPath},
+	{"TempFileFullWithPath", testTempFileFullWithPath},
+	{"RemoveTempFile", testRemoveTempFile},
+	{"RenameTempFile", testRenameTempFile},
+	{"TempFileMany", testTempFileMany},
+	{"TempFileManyWithUtil", testTempFileManyWithUtil},
+	{"TempDir", testTempDir},
+	{"CreateTemp", testCreateTemp},
+	{"MkdirTemp", testMkdirTemp},
+}
+
+// RunTempFile runs the conformance tests of the billy.TempFile interface against the
+// filesystems returned by factory.
+func RunTempFile(t *testing.T, factory Factory) {
+	run(t, factory, tempfileTests)
+}
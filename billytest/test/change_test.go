@@ -0,0 +1,186 @@
+package test
+
+import (
+	"io/fs"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/billytest"
+	"github.com/go-git/go-billy/v6/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eachChange runs fn over every filesystem and wrapper supporting
+// billy.Change, checking that the others fail with billy.ErrNotSupported.
+func eachChange(t *testing.T, fn func(t *testing.T, name string, fs billy.Filesystem)) {
+	for _, m := range []map[string]billytest.Factory{factories, wrappers} {
+		for name, factory := range m {
+			t.Run(name, func(t *testing.T) {
+				fs := factory(t)
+				require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+				err := util.Chmod(fs, "foo", 0o644)
+				if !billy.Supports(fs, billy.ChangeFeature) {
+					require.ErrorIs(t, err, billy.ErrNotSupported)
+					t.Skip("billy.Change not supported")
+				}
+				require.NoError(t, err)
+
+				fn(t, name, fs)
+			})
+		}
+	}
+}
+
+// osWindows reports whether the filesystem name is backed by the OS on
+// Windows, where only the read-only bit of the mode can be changed and files
+// have no numeric owner.
+func osWindows(name string) bool {
+	return runtime.GOOS == "windows" && strings.HasPrefix(name, "osfs")
+}
+
+// owner returns the ids of the owner of fi, if its filesystem reports them
+// as a *billy.FileStat.
+func owner(fi fs.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*billy.FileStat)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return st.UID, st.GID, true
+}
+
+func TestChmod(t *testing.T) {
+	eachChange(t, func(t *testing.T, name string, fs billy.Filesystem) {
+		if osWindows(name) {
+			t.Skip("permission bits are not supported on windows")
+		}
+
+		for _, mode := range []os.FileMode{0o600, 0o755, 0o400} {
+			require.NoError(t, util.Chmod(fs, "foo", mode))
+
+			fi, err := fs.Stat("foo")
+			require.NoError(t, err)
+			assert.Equal(t, mode, fi.Mode().Perm())
+			assert.True(t, fi.Mode().IsRegular())
+		}
+
+		require.NoError(t, fs.MkdirAll("dir", 0o755))
+		require.NoError(t, util.Chmod(fs, "dir", 0o700))
+		fi, err := fs.Stat("dir")
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+		assert.Equal(t, os.FileMode(0o700), fi.Mode().Perm())
+
+		err = util.Chmod(fs, "missing", 0o600)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestChtimes(t *testing.T) {
+	eachChange(t, func(t *testing.T, _ string, fs billy.Filesystem) {
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, util.Chtimes(fs, "foo", mtime, mtime))
+
+		fi, err := fs.Stat("foo")
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()), "got %s", fi.ModTime())
+
+		// A zero time leaves the modification time unchanged.
+		require.NoError(t, util.Chtimes(fs, "foo", time.Time{}, time.Time{}))
+		fi, err = fs.Stat("foo")
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()), "got %s", fi.ModTime())
+
+		later := mtime.Add(time.Hour)
+		require.NoError(t, util.Chtimes(fs, "foo", later, later))
+		fi, err = fs.Stat("foo")
+		require.NoError(t, err)
+		assert.True(t, later.Equal(fi.ModTime()), "got %s", fi.ModTime())
+
+		err = util.Chtimes(fs, "missing", mtime, mtime)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestChangeSymlink(t *testing.T) {
+	eachChange(t, func(t *testing.T, name string, fs billy.Filesystem) {
+		if !billy.Supports(fs, billy.SymlinkFeature) {
+			t.Skip("symlinks not supported")
+		}
+		require.NoError(t, fs.Symlink("foo", "link"))
+
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, util.Chtimes(fs, "link", mtime, mtime))
+		fi, err := fs.Stat("foo")
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()), "Chtimes changes the target")
+
+		if !osWindows(name) {
+			require.NoError(t, util.Chmod(fs, "link", 0o600))
+			fi, err = fs.Stat("foo")
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm(), "Chmod changes the target")
+
+			fi, err = fs.Lstat("link")
+			require.NoError(t, err)
+			assert.NotZero(t, fi.Mode()&os.ModeSymlink)
+		}
+
+		err = util.Chmod(fs, "dangling", 0o600)
+		if err == nil {
+			require.NoError(t, fs.Symlink("missing", "dangling"))
+			err = util.Chmod(fs, "dangling", 0o600)
+		}
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestChown(t *testing.T) {
+	eachChange(t, func(t *testing.T, name string, fs billy.Filesystem) {
+		if osWindows(name) {
+			t.Skip("ownership is not supported on windows")
+		}
+		if !billy.Supports(fs, billy.SymlinkFeature) {
+			t.Skip("symlinks not supported")
+		}
+		require.NoError(t, fs.Symlink("foo", "link"))
+
+		fi, err := fs.Stat("foo")
+		require.NoError(t, err)
+		if _, _, ok := owner(fi); !ok {
+			// The OS only lets the owner be changed to itself, unless
+			// privileged, so the ids can not be checked.
+			require.NoError(t, util.Chown(fs, "link", os.Getuid(), os.Getgid()))
+			require.NoError(t, util.Lchown(fs, "link", os.Getuid(), os.Getgid()))
+			return
+		}
+
+		require.NoError(t, util.Chown(fs, "link", 1, 2))
+		fi, err = fs.Stat("foo")
+		require.NoError(t, err)
+		uid, gid, _ := owner(fi)
+		assert.Equal(t, []int{1, 2}, []int{uid, gid}, "Chown changes the target")
+
+		fi, err = fs.Lstat("link")
+		require.NoError(t, err)
+		uid, gid, _ = owner(fi)
+		assert.Equal(t, []int{0, 0}, []int{uid, gid}, "Chown leaves the link unchanged")
+
+		require.NoError(t, util.Lchown(fs, "link", 3, -1))
+		fi, err = fs.Lstat("link")
+		require.NoError(t, err)
+		uid, gid, _ = owner(fi)
+		assert.Equal(t, []int{3, 0}, []int{uid, gid}, "Lchown changes the link")
+
+		fi, err = fs.Stat("foo")
+		require.NoError(t, err)
+		uid, gid, _ = owner(fi)
+		assert.Equal(t, []int{1, 2}, []int{uid, gid}, "Lchown leaves the target unchanged")
+	})
+}
@@ -0,0 +1,27 @@
+//go:build wasip1 || wasm || js
+
+package test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/billytest"
+	"github.com/go-git/go-billy/v6/dbfs"
+	"github.com/go-git/go-billy/v6/memfs"
+)
+
+var factories = map[string]billytest.Factory{
+	"dbfs": func(_ *testing.T) billy.Filesystem {
+		return dbfs.New(dbfs.NewMemoryKV(), dbfs.WithChunkSize(16))
+	},
+	"memfs": func(_ *testing.T) billy.Filesystem {
+		return memfs.New()
+	},
+	"memfs-explicit-dirs": func(_ *testing.T) billy.Filesystem {
+		return memfs.New(memfs.WithExplicitDirs())
+	},
+	"memfs-slash": func(_ *testing.T) billy.Filesystem {
+		return memfs.New(memfs.WithSlashSeparator())
+	},
+}
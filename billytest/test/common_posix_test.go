@@ -0,0 +1,35 @@
+//go:build !windows && !wasip1 && !js && !wasp
+// +build !windows,!wasip1,!js,!wasp
+
+package test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/billytest"
+	"github.com/go-git/go-billy/v6/dbfs"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+)
+
+var factories = map[string]billytest.Factory{
+	"dbfs": func(_ *testing.T) billy.Filesystem {
+		return dbfs.New(dbfs.NewMemoryKV(), dbfs.WithChunkSize(16))
+	},
+	"osfs": func(t *testing.T) billy.Filesystem {
+		return osfs.New(t.TempDir(), osfs.WithChrootOS())
+	},
+	"osfs-explicit-dirs": func(t *testing.T) billy.Filesystem {
+		return osfs.New(t.TempDir(), osfs.WithChrootOS(), osfs.WithExplicitDirs())
+	},
+	"memfs": func(_ *testing.T) billy.Filesystem {
+		return memfs.New()
+	},
+	"memfs-explicit-dirs": func(_ *testing.T) billy.Filesystem {
+		return memfs.New(memfs.WithExplicitDirs())
+	},
+	"memfs-slash": func(_ *testing.T) billy.Filesystem {
+		return memfs.New(memfs.WithSlashSeparator())
+	},
+}
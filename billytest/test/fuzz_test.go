@@ -0,0 +1,176 @@
+//go:build !windows && !wasip1 && !js && !wasp
+// +build !windows,!wasip1,!js,!wasp
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// fuzzPaths is the set of paths the fuzzed operations work on. It is kept
+// small so that operations are likely to interact with each other.
+var fuzzPaths = []string{
+	"a", "b", "c",
+	"a/a", "a/b", "b/a",
+	"a/a/a", "a/b/c",
+}
+
+type fuzzOp func(fs billy.Filesystem, path, other string, data byte) string
+
+// fuzzOps holds the operations applied by FuzzCompare. Each of them returns
+// a description of its observable outcome, which must match between
+// filesystems.
+var fuzzOps = []fuzzOp{
+	func(fs billy.Filesystem, path, _ string, data byte) string {
+		err := util.WriteFile(fs, path, []byte{data}, 0o644)
+		return "write: " + errorKind(err)
+	},
+	func(fs billy.Filesystem, path, _ string, data byte) string {
+		f, err := fs.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return "append: " + errorKind(err)
+		}
+		_, err = f.Write([]byte{data, data})
+		f.Close()
+		return "append: " + errorKind(err)
+	},
+	func(fs billy.Filesystem, path, _ string, _ byte) string {
+		b, err := util.ReadFile(fs, path)
+		return fmt.Sprintf("read: %q %s", b, errorKind(err))
+	},
+	func(fs billy.Filesystem, path, _ string, _ byte) string {
+		return "mkdirall: " + errorKind(fs.MkdirAll(path, 0o755))
+	},
+	func(fs billy.Filesystem, path, _ string, _ byte) string {
+		return "remove: " + errorKind(fs.Remove(path))
+	},
+	func(fs billy.Filesystem, path, other string, _ byte) string {
+		return "rename: " + errorKind(fs.Rename(path, other))
+	},
+	func(fs billy.Filesystem, path, _ string, _ byte) string {
+		fi, err := fs.Stat(path)
+		if err != nil {
+			return "stat: " + errorKind(err)
+		}
+		if fi.IsDir() {
+			return fmt.Sprintf("stat: %s dir", fi.Name())
+		}
+		return fmt.Sprintf("stat: %s %d", fi.Name(), fi.Size())
+	},
+	func(fs billy.Filesystem, path, _ string, _ byte) string {
+		return "readdir: " + listDir(fs, path)
+	},
+	func(fs billy.Filesystem, path, _ string, data byte) string {
+		f, err := fs.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return "truncate: " + errorKind(err)
+		}
+		defer f.Close()
+		return "truncate: " + errorKind(f.Truncate(int64(data%4)))
+	},
+	func(fs billy.Filesystem, path, _ string, _ byte) string {
+		return "removeall: " + errorKind(util.RemoveAll(fs, path))
+	},
+}
+
+// FuzzCompare applies random sequences of operations to both memfs and a
+// BoundOS filesystem, and checks that they behave the same way.
+func FuzzCompare(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 'x', 2, 0, 0, 0})
+	f.Add([]byte{3, 3, 0, 0, 0, 0, 0, 'x', 7, 0, 0, 0, 4, 0, 0, 0})
+	f.Add([]byte{0, 3, 0, 'x', 5, 3, 7, 0, 7, 7, 0, 0, 2, 7, 0, 0})
+	f.Add([]byte{1, 1, 0, 'x', 1, 1, 0, 'y', 8, 1, 0, 5, 2, 1, 0, 0})
+	f.Add([]byte{3, 6, 0, 0, 4, 0, 0, 0, 9, 0, 0, 0, 7, 0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		mem := memfs.New()
+		bound := osfs.New(t.TempDir(), osfs.WithBoundOS())
+
+		var log []string
+		for i := 0; i+3 < len(ops); i += 4 {
+			op := fuzzOps[int(ops[i])%len(fuzzOps)]
+			path := fuzzPaths[int(ops[i+1])%len(fuzzPaths)]
+			other := fuzzPaths[int(ops[i+2])%len(fuzzPaths)]
+
+			want := op(bound, path, other, ops[i+3])
+			got := op(mem, path, other, ops[i+3])
+			log = append(log, fmt.Sprintf("%s %s %s", want, path, other))
+
+			if got != want {
+				t.Fatalf("memfs diverged from osfs:\n\t%s\nmemfs: %s", strings.Join(log, "\n\t"), got)
+			}
+		}
+
+		if got, want := listTree(mem), listTree(bound); got != want {
+			t.Fatalf("memfs tree diverged from osfs:\n\t%s\nosfs:  %s\nmemfs: %s", strings.Join(log, "\n\t"), want, got)
+		}
+	})
+}
+
+// errorKind describes err by the os sentinel it wraps, ignoring how it is
+// wrapped and the message it holds.
+func errorKind(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, os.ErrNotExist):
+		return "not exist"
+	case errors.Is(err, os.ErrExist):
+		return "exist"
+	case errors.Is(err, io.EOF):
+		return "eof"
+	default:
+		return "error"
+	}
+}
+
+func listDir(fs billy.Filesystem, path string) string {
+	infos, err := fs.ReadDir(path)
+	if err != nil {
+		return errorKind(err)
+	}
+
+	entries := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		if fi.IsDir() {
+			entries = append(entries, fi.Name()+"/")
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%s:%d", fi.Name(), fi.Size()))
+	}
+	sort.Strings(entries)
+
+	return strings.Join(entries, " ")
+}
+
+func listTree(fs billy.Filesystem) string {
+	var entries []string
+	_ = util.Walk(fs, "", func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			entries = append(entries, path+" "+errorKind(err))
+			return nil
+		}
+		if fi.IsDir() {
+			entries = append(entries, path+"/")
+			return nil
+		}
+
+		b, err := util.ReadFile(fs, path)
+		entries = append(entries, fmt.Sprintf("%s %q %s", path, b, errorKind(err)))
+		return nil
+	})
+	sort.Strings(entries)
+
+	return strings.Join(entries, " ")
+}
@@ -0,0 +1,15 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v6/billytest"
+)
+
+func TestSuite(t *testing.T) {
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			billytest.RunAll(t, factory)
+		})
+	}
+}
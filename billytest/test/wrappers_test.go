@@ -0,0 +1,106 @@
+package test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/billytest"
+	"github.com/go-git/go-billy/v6/helper/bufferfs"
+	"github.com/go-git/go-billy/v6/helper/cachefs"
+	"github.com/go-git/go-billy/v6/helper/compressfs"
+	"github.com/go-git/go-billy/v6/helper/countingfs"
+	"github.com/go-git/go-billy/v6/helper/encryptfs"
+	"github.com/go-git/go-billy/v6/helper/faultfs"
+	"github.com/go-git/go-billy/v6/helper/interceptfs"
+	"github.com/go-git/go-billy/v6/helper/limit"
+	"github.com/go-git/go-billy/v6/helper/mount"
+	"github.com/go-git/go-billy/v6/helper/policyfs"
+	"github.com/go-git/go-billy/v6/helper/polyfill"
+	"github.com/go-git/go-billy/v6/helper/prefixfs"
+	"github.com/go-git/go-billy/v6/helper/recordfs"
+	"github.com/go-git/go-billy/v6/helper/retryfs"
+	"github.com/go-git/go-billy/v6/helper/temporal"
+	"github.com/go-git/go-billy/v6/memfs"
+)
+
+// wrappers returns the helpers wrapping other filesystems. The files they
+// return must behave like the ones of the wrapped filesystem, including
+// ReadAt and WriteAt.
+var wrappers = map[string]billytest.Factory{
+	"bufferfs": func(_ *testing.T) billy.Filesystem {
+		return bufferfs.New(memfs.New())
+	},
+	"cachefs": func(_ *testing.T) billy.Filesystem {
+		return cachefs.New(memfs.New())
+	},
+	"chroot": func(t *testing.T) billy.Filesystem {
+		fs, err := memfs.New().Chroot("sub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	},
+	"compressfs": func(_ *testing.T) billy.Filesystem {
+		return compressfs.New(memfs.New())
+	},
+	"countingfs": func(_ *testing.T) billy.Filesystem {
+		return countingfs.New(memfs.New())
+	},
+	"encryptfs": func(t *testing.T) billy.Filesystem {
+		block, err := aes.NewCipher(bytes.Repeat([]byte{1}, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encryptfs.New(memfs.New(), aead)
+	},
+	"faultfs": func(_ *testing.T) billy.Filesystem {
+		return faultfs.New(memfs.New())
+	},
+	"interceptfs": func(_ *testing.T) billy.Filesystem {
+		return interceptfs.New(memfs.New(), interceptfs.Hooks{})
+	},
+	"mount": func(_ *testing.T) billy.Filesystem {
+		return polyfill.New(mount.New(memfs.New(), "/mnt", memfs.New()))
+	},
+	"polyfill": func(_ *testing.T) billy.Filesystem {
+		return polyfill.New(struct{ billy.Basic }{memfs.New()})
+	},
+	"limit": func(_ *testing.T) billy.Filesystem {
+		return limit.New(memfs.New())
+	},
+	"policyfs": func(_ *testing.T) billy.Filesystem {
+		return policyfs.New(memfs.New(), policyfs.Allow("*", policyfs.All))
+	},
+	"prefixfs": func(t *testing.T) billy.Filesystem {
+		fs, err := prefixfs.New(memfs.New(), "/virtual/repo").Chroot("virtual/repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	},
+	"recordfs": func(_ *testing.T) billy.Filesystem {
+		return recordfs.New(memfs.New(), io.Discard)
+	},
+	"retryfs": func(_ *testing.T) billy.Filesystem {
+		return retryfs.New(memfs.New())
+	},
+	"temporal": func(_ *testing.T) billy.Filesystem {
+		return temporal.New(memfs.New(), "tmp")
+	},
+}
+
+func TestWrappers(t *testing.T) {
+	for name, factory := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			billytest.RunBasic(t, factory)
+		})
+	}
+}
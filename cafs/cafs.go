@@ -0,0 +1,160 @@
+// Package cafs provides a content-addressable store on top of any
+// billy.Filesystem, where contents are written once and addressed by their
+// SHA-256 hash.
+package cafs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// tempPattern is the pattern of the temp files contents are written to, at
+// the root of the store, before being moved to their path.
+const tempPattern = ".put-*"
+
+// ErrCorrupted is returned when the content stored for a hash does not match
+// it.
+var ErrCorrupted = errors.New("content does not match its hash")
+
+// Hash is the SHA-256 hash addressing a content.
+type Hash [sha256.Size]byte
+
+// ParseHash parses the hexadecimal representation of a hash.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if hex.DecodedLen(len(s)) != len(h) {
+		return h, fmt.Errorf("invalid hash %q: wrong length", s)
+	}
+
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid hash %q: %w", s, err)
+	}
+
+	return h, nil
+}
+
+// String returns the hexadecimal representation of h.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Store is a content-addressable store. Contents are stored in files named
+// after their hash, sharded in two levels of directories named after its
+// first two bytes, such as ab/cd/abcd..., so no directory grows too large.
+//
+// A Store is safe for concurrent use, as long as the filesystem is.
+type Store struct {
+	fs billy.Filesystem
+}
+
+// New returns a new Store keeping its contents in fs.
+func New(fs billy.Filesystem) *Store {
+	return &Store{fs: fs}
+}
+
+// Path returns the path of the content addressed by h, in the filesystem of
+// the store.
+func (s *Store) Path(h Hash) string {
+	name := h.String()
+	return s.fs.Join(name[0:2], name[2:4], name)
+}
+
+// Put stores the content read from r until io.EOF, returning its hash.
+// Storing a content already stored is not an error, and leaves it as is.
+//
+// The content is written to a temp file first and moved to its path once
+// complete, so it is never visible partially written.
+func (s *Store) Put(r io.Reader) (h Hash, err error) {
+	f, err := s.fs.CreateTemp(".", tempPattern)
+	if err != nil {
+		return h, err
+	}
+
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = s.fs.Remove(tmp)
+		}
+	}()
+
+	sum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, sum), r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return h, err
+	}
+
+	copy(h[:], sum.Sum(nil))
+	name := h.String()
+	if err := s.fs.MkdirAll(s.fs.Join(name[0:2], name[2:4]), 0o755); err != nil {
+		return h, err
+	}
+
+	path := s.Path(h)
+	err = util.RenameNoReplace(s.fs, tmp, path)
+	switch {
+	case errors.Is(err, os.ErrExist):
+		return h, s.fs.Remove(tmp)
+	case errors.Is(err, billy.ErrNotSupported):
+		// The content is the same if it exists, so it can be replaced.
+		return h, s.fs.Rename(tmp, path)
+	default:
+		return h, err
+	}
+}
+
+// Open opens the content addressed by h for reading. It fails with an error
+// wrapping os.ErrNotExist if the content is not stored.
+//
+// The content is not checked against h while read; see Verify.
+func (s *Store) Open(h Hash) (billy.File, error) {
+	return s.fs.Open(s.Path(h))
+}
+
+// Has reports whether the content addressed by h is stored.
+func (s *Store) Has(h Hash) (bool, error) {
+	_, err := s.fs.Stat(s.Path(h))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Remove removes the content addressed by h. It fails with an error wrapping
+// os.ErrNotExist if the content is not stored.
+func (s *Store) Remove(h Hash) error {
+	return s.fs.Remove(s.Path(h))
+}
+
+// Verify reads the content addressed by h, failing with ErrCorrupted if its
+// hash does not match h.
+func (s *Store) Verify(h Hash) error {
+	f, err := s.Open(h)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return err
+	}
+
+	var got Hash
+	copy(got[:], sum.Sum(nil))
+	if got != h {
+		return &os.PathError{Op: "verify", Path: s.Path(h), Err: ErrCorrupted}
+	}
+
+	return nil
+}
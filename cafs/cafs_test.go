@@ -0,0 +1,117 @@
+package cafs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// fooHash is the SHA-256 hash of "foo".
+const fooHash = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestParseHash(t *testing.T) {
+	h, err := ParseHash(fooHash)
+	require.NoError(t, err)
+	assert.Equal(t, fooHash, h.String())
+
+	_, err = ParseHash("2c26")
+	require.Error(t, err)
+
+	_, err = ParseHash(strings.Repeat("x", len(fooHash)))
+	require.Error(t, err)
+}
+
+func TestPutOpen(t *testing.T) {
+	for name, fs := range map[string]billy.Filesystem{
+		"memfs": memfs.New(),
+		"osfs":  osfs.New(t.TempDir()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := New(fs)
+
+			h, err := s.Put(strings.NewReader("foo"))
+			require.NoError(t, err)
+			assert.Equal(t, fooHash, h.String())
+			assert.Equal(t, filepath.Join("2c", "26", fooHash), s.Path(h))
+
+			f, err := s.Open(h)
+			require.NoError(t, err)
+			content, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, "foo", string(content))
+			require.NoError(t, f.Close())
+
+			again, err := s.Put(strings.NewReader("foo"))
+			require.NoError(t, err)
+			assert.Equal(t, h, again)
+
+			names, err := util.ReadDirNames(fs, ".", 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"2c"}, names)
+		})
+	}
+}
+
+func TestHasRemove(t *testing.T) {
+	s := New(memfs.New())
+
+	h, err := ParseHash(fooHash)
+	require.NoError(t, err)
+
+	ok, err := s.Has(h)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = s.Open(h)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = s.Put(strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	ok, err = s.Has(h)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, s.Remove(h))
+	require.ErrorIs(t, s.Remove(h), os.ErrNotExist)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestPutError(t *testing.T) {
+	fs := memfs.New()
+	s := New(fs)
+
+	_, err := s.Put(io.MultiReader(strings.NewReader("foo"), failingReader{}))
+	require.ErrorContains(t, err, "failed")
+
+	names, err := util.ReadDirNames(fs, ".", 0)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestVerify(t *testing.T) {
+	fs := memfs.New()
+	s := New(fs)
+
+	h, err := s.Put(strings.NewReader("foo"))
+	require.NoError(t, err)
+	require.NoError(t, s.Verify(h))
+
+	require.NoError(t, util.WriteFile(fs, s.Path(h), []byte("bar"), 0o644))
+	require.ErrorIs(t, s.Verify(h), ErrCorrupted)
+}
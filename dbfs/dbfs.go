@@ -0,0 +1,581 @@
+// Package dbfs provides a billy filesystem kept in a key-value store, such as
+// a database, with support for transactions spanning several files.
+//
+// Files are stored as their metadata and their contents split in chunks, in
+// any store implementing the KV interface. NewMemoryKV returns one kept in
+// memory; implementing it on top of SQLite or bbolt gives a durable
+// filesystem kept in a single file.
+package dbfs // import "github.com/go-git/go-billy/v6/dbfs"
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+const separator = string(filepath.Separator)
+
+// defaultChunkSize is the size of the chunks the contents of the files are
+// split in, unless set with WithChunkSize.
+const defaultChunkSize = 64 << 10
+
+// Option configures a DB filesystem.
+type Option func(*options)
+
+type options struct {
+	chunkSize int64
+}
+
+// WithChunkSize sets the size of the chunks the contents of the files are
+// split in, 64KiB by default. Writes rewrite the whole chunks they touch, so
+// smaller chunks favour small random writes, and larger ones large files.
+func WithChunkSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.chunkSize = int64(n)
+		}
+	}
+}
+
+// DB is a filesystem kept in a KV store. Each of its operations runs in its
+// own transaction of the store; use Begin to make several of them at once.
+//
+// A DB is safe for concurrent use as far as its KV store is, but its files
+// are not.
+type DB struct {
+	*filesystem
+	kv KV
+}
+
+// New returns a new DB filesystem kept in kv.
+func New(kv KV, opts ...Option) billy.Filesystem {
+	o := &options{chunkSize: defaultChunkSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	db := &DB{kv: kv}
+	db.filesystem = &filesystem{chunkSize: o.chunkSize, run: db.run}
+	return db
+}
+
+func (db *DB) String() string {
+	return "dbfs"
+}
+
+func (db *DB) run(fn func(*store) error) error {
+	tx, err := db.kv.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&store{tx: tx, chunkSize: db.chunkSize}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Begin starts a transaction, returned as a filesystem whose changes are
+// only visible to itself until they are applied all at once by Commit, or
+// discarded by Rollback. Its operations fail with ErrTxDone afterwards,
+// including the ones made on the files it opened.
+//
+// The isolation from the other transactions is the one provided by the KV
+// store. A Tx is not safe for concurrent use.
+func (db *DB) Begin() (*Tx, error) {
+	kvtx, err := db.kv.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{tx: kvtx}
+	tx.filesystem = &filesystem{chunkSize: db.chunkSize, run: tx.run}
+	return tx, nil
+}
+
+// Tx is a filesystem made of the changes of a transaction. See DB.Begin.
+type Tx struct {
+	*filesystem
+	tx   KVTx
+	done bool
+}
+
+// Commit applies the changes made through the transaction.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	return tx.tx.Commit()
+}
+
+// Rollback discards the changes made through the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	return tx.tx.Rollback()
+}
+
+func (tx *Tx) String() string {
+	return "dbfs.Tx"
+}
+
+func (tx *Tx) run(fn func(*store) error) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	return fn(&store{tx: tx.tx, chunkSize: tx.chunkSize})
+}
+
+// filesystem implements the operations of DB and Tx, running them through
+// run.
+type filesystem struct {
+	run       func(fn func(*store) error) error
+	chunkSize int64
+}
+
+func (fs *filesystem) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *filesystem) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *filesystem) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	if err := openflag.Validate(flag); err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	p := clean(filename)
+	var real string
+	err := fs.run(func(s *store) error {
+		if openflag.Create(flag) {
+			dir, _ := split(p)
+			if err := s.mkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+
+		var n *node
+		var err error
+		real, n, err = s.resolve(p, true)
+		switch {
+		case err != nil:
+			return err
+		case n == nil && !openflag.Create(flag):
+			return os.ErrNotExist
+		case n == nil:
+			return s.put(real, &node{Mode: perm & os.ModePerm, ModTime: time.Now()})
+		case openflag.Exclusive(flag):
+			return os.ErrExist
+		case n.Mode.IsDir():
+			return syscall.EISDIR
+		case openflag.Truncate(flag) && openflag.Writable(flag):
+			return s.truncate(real, n, 0)
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	return &file{
+		fs:         fs,
+		name:       filepath.FromSlash(p),
+		openedPath: filename,
+		path:       real,
+		flag:       flag,
+	}, nil
+}
+
+func (fs *filesystem) Stat(filename string) (os.FileInfo, error) {
+	return fs.stat("stat", filename, true)
+}
+
+func (fs *filesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.stat("lstat", filename, false)
+}
+
+func (fs *filesystem) stat(op, filename string, follow bool) (os.FileInfo, error) {
+	p := clean(filename)
+	var n *node
+	err := fs.run(func(s *store) error {
+		var err error
+		_, n, err = s.resolve(p, follow)
+		if err == nil && n == nil {
+			err = os.ErrNotExist
+		}
+		return err
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: filename, Err: err}
+	}
+
+	return newFileInfo(p, n), nil
+}
+
+func (fs *filesystem) Rename(from, to string) error {
+	fromPath, toPath := clean(from), clean(to)
+	err := fs.run(func(s *store) error {
+		fromReal, fromNode, err := s.resolve(fromPath, false)
+		switch {
+		case err != nil:
+			return err
+		case fromNode == nil:
+			return os.ErrNotExist
+		case fromReal == "":
+			return os.ErrInvalid
+		}
+
+		dir, _ := split(toPath)
+		if err := s.mkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		toReal, toNode, err := s.resolve(toPath, false)
+		switch {
+		case err != nil:
+			return err
+		case toReal == fromReal:
+			return nil
+		case toReal == "" || fromNode.Mode.IsDir() && strings.HasPrefix(toReal, fromReal+"/"):
+			return os.ErrInvalid
+		}
+
+		if toNode != nil {
+			if toNode.Mode.IsDir() {
+				if !fromNode.Mode.IsDir() {
+					return syscall.EISDIR
+				}
+				if empty, err := s.isEmpty(toReal); err != nil || !empty {
+					if err == nil {
+						err = errno.ENOTEMPTY
+					}
+					return err
+				}
+			} else if fromNode.Mode.IsDir() {
+				return syscall.ENOTDIR
+			}
+
+			if err := s.remove(toReal, toNode); err != nil {
+				return err
+			}
+		}
+
+		return s.move(fromReal, fromNode, toReal)
+	})
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	return nil
+}
+
+func (fs *filesystem) Remove(filename string) error {
+	p := clean(filename)
+	err := fs.run(func(s *store) error {
+		real, n, err := s.resolve(p, false)
+		switch {
+		case err != nil:
+			return err
+		case n == nil:
+			return os.ErrNotExist
+		case real == "":
+			return os.ErrInvalid
+		}
+
+		if n.Mode.IsDir() {
+			empty, err := s.isEmpty(real)
+			if err != nil {
+				return err
+			}
+			if !empty {
+				return errno.ENOTEMPTY
+			}
+		}
+
+		return s.remove(real, n)
+	})
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: filename, Err: err}
+	}
+
+	return nil
+}
+
+func (fs *filesystem) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.CreateTemp(dir, prefix+"*")
+}
+
+func (fs *filesystem) TempDir(dir, prefix string) (string, error) {
+	return fs.MkdirTemp(dir, prefix+"*")
+}
+
+func (fs *filesystem) CreateTemp(dir, pattern string) (billy.File, error) {
+	return util.CreateTemp(fs, dir, pattern)
+}
+
+func (fs *filesystem) MkdirTemp(dir, pattern string) (string, error) {
+	return util.MkdirTemp(fs, dir, pattern)
+}
+
+func (fs *filesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	p := clean(dirname)
+	var infos []os.FileInfo
+	err := fs.run(func(s *store) error {
+		real, err := s.resolveDir(p)
+		if err != nil {
+			return err
+		}
+
+		return s.children(real, func(name string, n *node) error {
+			infos = append(infos, newFileInfo(name, n))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: dirname, Err: err}
+	}
+
+	return infos, nil
+}
+
+// SyncDir implements the billy.DirSyncer interface. It only checks that
+// dirname is a directory, as every change is committed to the store, or to
+// the transaction, as it is made.
+func (fs *filesystem) SyncDir(dirname string) error {
+	p := clean(dirname)
+	err := fs.run(func(s *store) error {
+		_, err := s.resolveDir(p)
+		return err
+	})
+	if err != nil {
+		return &os.PathError{Op: "sync", Path: dirname, Err: err}
+	}
+
+	return nil
+}
+
+func (fs *filesystem) MkdirAll(filename string, perm fs.FileMode) error {
+	p := clean(filename)
+	err := fs.run(func(s *store) error {
+		return s.mkdirAll(p, perm)
+	})
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: filename, Err: err}
+	}
+
+	return nil
+}
+
+func (fs *filesystem) Symlink(target, link string) error {
+	p := clean(link)
+	err := fs.run(func(s *store) error {
+		dir, _ := split(p)
+		if err := s.mkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		real, n, err := s.resolve(p, false)
+		switch {
+		case err != nil:
+			return err
+		case n != nil:
+			return os.ErrExist
+		}
+
+		return s.put(real, &node{
+			Mode:    os.ModeSymlink | 0o777,
+			ModTime: time.Now(),
+			Target:  filepath.ToSlash(target),
+		})
+	})
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: err}
+	}
+
+	return nil
+}
+
+func (fs *filesystem) Readlink(link string) (string, error) {
+	p := clean(link)
+	var target string
+	err := fs.run(func(s *store) error {
+		_, n, err := s.resolve(p, false)
+		switch {
+		case err != nil:
+			return err
+		case n == nil:
+			return os.ErrNotExist
+		case n.Mode&os.ModeSymlink == 0:
+			return os.ErrInvalid
+		}
+
+		target = filepath.FromSlash(n.Target)
+		return nil
+	})
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: err}
+	}
+
+	return target, nil
+}
+
+// chmodMask holds the mode bits which can be changed by Chmod, as in os.Chmod.
+const chmodMask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Chmod implements the billy.Change interface. As the other methods of
+// billy.Change, it fails with os.ErrInvalid on the root dir, whose metadata
+// is not stored.
+func (fs *filesystem) Chmod(name string, mode fs.FileMode) error {
+	return fs.change("chmod", name, true, func(n *node) {
+		n.Mode = n.Mode&^chmodMask | mode&chmodMask
+	})
+}
+
+// Lchown implements the billy.Change interface. The owner is reported by the
+// Sys method of the FileInfo of the file, as a *billy.FileStat.
+func (fs *filesystem) Lchown(name string, uid, gid int) error {
+	return fs.change("lchown", name, false, func(n *node) {
+		n.chown(uid, gid)
+	})
+}
+
+// Chown implements the billy.Change interface. The owner is reported by the
+// Sys method of the FileInfo of the file, as a *billy.FileStat.
+func (fs *filesystem) Chown(name string, uid, gid int) error {
+	return fs.change("chown", name, true, func(n *node) {
+		n.chown(uid, gid)
+	})
+}
+
+// Chtimes implements the billy.Change interface. Only the modification time
+// is kept, and it is left unchanged if mtime is the zero time.
+func (fs *filesystem) Chtimes(name string, _ time.Time, mtime time.Time) error {
+	return fs.change("chtimes", name, true, func(n *node) {
+		if !mtime.IsZero() {
+			n.ModTime = mtime
+		}
+	})
+}
+
+// change applies fn to the node of name, following symlinks if follow is
+// set, and stores it.
+func (fs *filesystem) change(op, name string, follow bool, fn func(*node)) error {
+	p := clean(name)
+	err := fs.run(func(s *store) error {
+		real, n, err := s.resolve(p, follow)
+		switch {
+		case err != nil:
+			return err
+		case n == nil:
+			return os.ErrNotExist
+		case real == "":
+			return os.ErrInvalid
+		}
+
+		fn(n)
+		return s.put(real, n)
+	})
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return nil
+}
+
+// Chroot returns a filesystem rooted at path, which is relative to the root
+// even when empty or absolute, so "", "." and "/" all return the root.
+func (fs *filesystem) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, fs.Join(separator, clean(path))), nil
+}
+
+func (fs *filesystem) Root() string {
+	return separator
+}
+
+// Capabilities implements the Capable interface.
+func (fs *filesystem) Capabilities() billy.Capability {
+	return billy.WriteCapability |
+		billy.ReadCapability |
+		billy.ReadAndWriteCapability |
+		billy.SeekCapability |
+		billy.TruncateCapability |
+		billy.SymlinkCapability |
+		billy.ChangeCapability
+}
+
+// clean returns filename as a path of the store: cleaned, slash separated,
+// and relative to the root, which is the empty path.
+func clean(filename string) string {
+	p := path.Clean("/" + filepath.ToSlash(filename))
+	return strings.TrimPrefix(p, "/")
+}
+
+type fileInfo struct {
+	name string
+	n    *node
+}
+
+func newFileInfo(p string, n *node) *fileInfo {
+	_, name := split(p)
+	if p == "" {
+		name = separator
+	}
+
+	return &fileInfo{name: name, n: n}
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fileInfo) Size() int64 {
+	if fi.n.Mode&fs.ModeSymlink != 0 {
+		// Like lstat(2), the size of a symlink is the length of its target.
+		return int64(len(fi.n.Target))
+	}
+
+	return fi.n.Size
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	return fi.n.Mode
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.n.ModTime
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.n.Mode.IsDir()
+}
+
+// Sys returns the owner of the file as a *billy.FileStat. Nlink is always 1,
+// as dbfs does not support hard links.
+func (fi *fileInfo) Sys() interface{} {
+	return &billy.FileStat{UID: fi.n.UID, GID: fi.n.GID, Nlink: 1}
+}
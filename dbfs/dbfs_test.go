@@ -0,0 +1,181 @@
+package dbfs
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestTxCommit(t *testing.T) {
+	db := New(NewMemoryKV()).(*DB)
+	require.NoError(t, util.WriteFile(db, "foo", []byte("foo"), 0o644))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(tx, "refs/heads/main", []byte("main"), 0o644))
+	require.NoError(t, tx.Rename("foo", "bar"))
+
+	// The changes are only visible to the transaction until committed.
+	_, err = db.Stat("refs/heads/main")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = db.Stat("foo")
+	require.NoError(t, err)
+
+	content, err := util.ReadFile(tx, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	require.NoError(t, tx.Commit())
+
+	content, err = util.ReadFile(db, "refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, "main", string(content))
+	_, err = db.Stat("foo")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.ErrorIs(t, tx.Commit(), ErrTxDone)
+	_, err = tx.Stat("bar")
+	require.ErrorIs(t, err, ErrTxDone)
+}
+
+func TestTxRollback(t *testing.T) {
+	db := New(NewMemoryKV()).(*DB)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+
+	f, err := tx.Create("foo")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("foo"))
+	require.NoError(t, err)
+
+	require.NoError(t, tx.Rollback())
+	require.ErrorIs(t, tx.Rollback(), ErrTxDone)
+
+	_, err = f.Write([]byte("bar"))
+	require.ErrorIs(t, err, ErrTxDone)
+
+	_, err = db.Stat("foo")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestChunks(t *testing.T) {
+	fs := New(NewMemoryKV(), WithChunkSize(4))
+
+	data := []byte(strings.Repeat("0123456789", 5))
+	require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+
+	f, err := fs.OpenFile("foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteAt([]byte("abcdef"), 7)
+	require.NoError(t, err)
+	copy(data[7:], "abcdef")
+
+	b := make([]byte, 10)
+	n, err := f.ReadAt(b, 5)
+	require.NoError(t, err)
+	assert.Equal(t, data[5:15], b[:n])
+
+	// Shrinking drops the chunks past the end, and growing back reads
+	// zeros.
+	require.NoError(t, f.Truncate(9))
+	require.NoError(t, f.Truncate(20))
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, append(data[:9:9], make([]byte, 11)...), content)
+
+	n, err = f.ReadAt(b, 15)
+	require.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, make([]byte, 5), b[:n])
+}
+
+func TestRenameDir(t *testing.T) {
+	fs := New(NewMemoryKV(), WithChunkSize(2))
+	require.NoError(t, util.WriteFile(fs, "a/b/c", []byte("abc"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "a/d", []byte("d"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "ab", []byte("ab"), 0o644))
+
+	require.NoError(t, fs.Rename("a", "x/y"))
+
+	for name, want := range map[string]string{"x/y/b/c": "abc", "x/y/d": "d", "ab": "ab"} {
+		content, err := util.ReadFile(fs, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, string(content), name)
+	}
+
+	_, err := fs.Stat("a")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.ErrorIs(t, fs.Rename("x", "x/y/z"), os.ErrInvalid)
+}
+
+func TestChangeTx(t *testing.T) {
+	db := New(NewMemoryKV()).(*DB)
+	require.NoError(t, util.WriteFile(db, "foo", nil, 0o644))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.Chmod("foo", 0o600))
+
+	fi, err := db.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), fi.Mode())
+
+	require.NoError(t, tx.Commit())
+	fi, err = db.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode())
+
+	require.ErrorIs(t, db.Chmod("/", 0o700), os.ErrInvalid)
+}
+
+func TestMemoryKV(t *testing.T) {
+	kv := NewMemoryKV()
+
+	tx, err := kv.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.Put("b", []byte("b")))
+	require.NoError(t, tx.Put("a", []byte("a")))
+	require.NoError(t, tx.Put("c", []byte("c")))
+	require.NoError(t, tx.Commit())
+
+	tx, err = kv.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete("b"))
+	require.NoError(t, tx.Put("aa", []byte("aa")))
+
+	var keys []string
+	err = tx.Scan("", func(key string, value []byte) error {
+		assert.Equal(t, key, string(value))
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "aa", "c"}, keys)
+
+	v, ok, err := tx.Get("b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, v)
+
+	require.NoError(t, tx.Rollback())
+	_, _, err = tx.Get("a")
+	require.ErrorIs(t, err, ErrTxDone)
+
+	tx, err = kv.Begin()
+	require.NoError(t, err)
+	v, ok, err = tx.Get("b")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("b"), v)
+}
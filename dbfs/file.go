@@ -0,0 +1,221 @@
+package dbfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+)
+
+// file is an open file of a DB or a Tx. Each of its operations runs through
+// the filesystem which opened it, so it is bound to its transaction.
+type file struct {
+	fs         *filesystem
+	name       string
+	openedPath string
+	// path is the path of the file in the store, once resolved.
+	path     string
+	flag     int
+	position int64
+	isClosed bool
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) OpenedPath() string {
+	return f.openedPath
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.position)
+	f.position += int64(n)
+
+	if errors.Is(err, io.EOF) && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	if f.isClosed {
+		return 0, f.pathError("read", os.ErrClosed)
+	}
+
+	if !openflag.Readable(f.flag) {
+		return 0, f.pathError("read", errno.EBADF)
+	}
+
+	var n int
+	err := f.run(func(s *store, nd *node) error {
+		var err error
+		n, err = s.readAt(f.path, nd, b, off)
+		return err
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, f.pathError("read", err)
+	}
+
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return f.write(p, -1)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if openflag.Append(f.flag) {
+		return 0, f.pathError("writeat", openflag.ErrWriteAtInAppendMode)
+	}
+
+	return f.write(p, off)
+}
+
+// write writes p at off, or at the position of the file, which it advances,
+// if off is negative.
+func (f *file) write(p []byte, off int64) (int, error) {
+	if f.isClosed {
+		return 0, f.pathError("write", os.ErrClosed)
+	}
+
+	if !openflag.Writable(f.flag) {
+		return 0, f.pathError("write", errno.EBADF)
+	}
+
+	var n int
+	err := f.run(func(s *store, nd *node) error {
+		pos := off
+		if pos < 0 {
+			pos = f.position
+			if openflag.Append(f.flag) {
+				// Writes always go to the end of the file, even if it
+				// was extended by another handle since the last write.
+				pos = nd.Size
+			}
+		}
+
+		var err error
+		n, err = s.writeAt(f.path, nd, p, pos)
+		if off < 0 {
+			f.position = pos + int64(n)
+		}
+		return err
+	})
+	if err != nil {
+		return n, f.pathError("write", err)
+	}
+
+	return n, nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.isClosed {
+		return 0, f.pathError("seek", os.ErrClosed)
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		f.position += offset
+	case io.SeekStart:
+		f.position = offset
+	case io.SeekEnd:
+		err := f.run(func(_ *store, nd *node) error {
+			f.position = nd.Size + offset
+			return nil
+		})
+		if err != nil {
+			return 0, f.pathError("seek", err)
+		}
+	}
+
+	return f.position, nil
+}
+
+// Truncate changes the size of the file. Like os.File, it fails with EINVAL
+// if the file is not open for writing.
+func (f *file) Truncate(size int64) error {
+	if f.isClosed {
+		return f.pathError("truncate", os.ErrClosed)
+	}
+
+	if size < 0 || !openflag.Writable(f.flag) {
+		return f.pathError("truncate", syscall.EINVAL)
+	}
+
+	err := f.run(func(s *store, nd *node) error {
+		return s.truncate(f.path, nd, size)
+	})
+	if err != nil {
+		return f.pathError("truncate", err)
+	}
+
+	return nil
+}
+
+// Sync does nothing, as every write is committed to the store, or to the
+// transaction of the file, as it is made.
+func (f *file) Sync() error {
+	if f.isClosed {
+		return f.pathError("sync", os.ErrClosed)
+	}
+
+	return nil
+}
+
+func (f *file) Close() error {
+	if f.isClosed {
+		return f.pathError("close", os.ErrClosed)
+	}
+
+	f.isClosed = true
+	return nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := f.run(func(_ *store, nd *node) error {
+		fi = newFileInfo(f.path, nd)
+		return nil
+	})
+	if err != nil {
+		return nil, f.pathError("stat", err)
+	}
+
+	return fi, nil
+}
+
+// Lock does nothing in dbfs.
+func (f *file) Lock() error {
+	return nil
+}
+
+// Unlock does nothing in dbfs.
+func (f *file) Unlock() error {
+	return nil
+}
+
+// run calls fn with the current node of the file, within a transaction. It
+// fails with os.ErrNotExist if the file was removed since it was opened.
+func (f *file) run(fn func(*store, *node) error) error {
+	return f.fs.run(func(s *store) error {
+		nd, err := s.get(f.path)
+		if err != nil {
+			return err
+		}
+		if nd == nil {
+			return os.ErrNotExist
+		}
+
+		return fn(s, nd)
+	})
+}
+
+// pathError returns err wrapped in an *os.PathError for the file.
+func (f *file) pathError(op string, err error) error {
+	return &os.PathError{Op: op, Path: f.name, Err: err}
+}
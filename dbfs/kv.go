@@ -0,0 +1,174 @@
+package dbfs
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTxDone is returned by the operations made on a transaction which has
+// already been committed or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// KV is the key-value store a DB keeps its files in. It can be implemented
+// on top of any store supporting transactions, such as SQLite or bbolt, to
+// keep the whole filesystem in a single file.
+type KV interface {
+	// Begin starts a transaction.
+	Begin() (KVTx, error)
+}
+
+// KVTx is a transaction of a KV store. Its changes are visible to itself,
+// and are applied to the store all at once by Commit. A KVTx is not used
+// concurrently.
+type KVTx interface {
+	// Get returns the value of key, and whether it exists.
+	Get(key string) ([]byte, bool, error)
+	// Put sets the value of key.
+	Put(key string, value []byte) error
+	// Delete deletes key. Deleting a key which does not exist is not an
+	// error.
+	Delete(key string) error
+	// Scan calls fn with the keys starting with prefix and their values, in
+	// the byte order of the keys, stopping at the first error fn returns.
+	Scan(prefix string, fn func(key string, value []byte) error) error
+	// Commit applies the changes of the transaction to the store.
+	Commit() error
+	// Rollback discards the changes of the transaction.
+	Rollback() error
+}
+
+// memoryKV is a KV store kept in memory.
+type memoryKV struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryKV returns a new KV store kept in memory. Its transactions see
+// the changes committed by the others as soon as they are, so they are
+// atomic but not isolated from each other; concurrent transactions changing
+// the same keys are applied in the order they are committed.
+func NewMemoryKV() KV {
+	return &memoryKV{data: make(map[string][]byte)}
+}
+
+func (kv *memoryKV) Begin() (KVTx, error) {
+	return &memoryTx{kv: kv, writes: make(map[string][]byte)}, nil
+}
+
+// memoryTx buffers the changes of a transaction until it is committed. A
+// nil value in writes marks a deleted key.
+type memoryTx struct {
+	kv     *memoryKV
+	writes map[string][]byte
+	done   bool
+}
+
+func (tx *memoryTx) Get(key string) ([]byte, bool, error) {
+	if tx.done {
+		return nil, false, ErrTxDone
+	}
+
+	v, ok := tx.writes[key]
+	if !ok {
+		tx.kv.mu.RLock()
+		v = tx.kv.data[key]
+		tx.kv.mu.RUnlock()
+	}
+
+	if v == nil {
+		return nil, false, nil
+	}
+
+	return clone(v), true, nil
+}
+
+func (tx *memoryTx) Put(key string, value []byte) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.writes[key] = clone(value)
+	return nil
+}
+
+func (tx *memoryTx) Delete(key string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.writes[key] = nil
+	return nil
+}
+
+func (tx *memoryTx) Scan(prefix string, fn func(key string, value []byte) error) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	values := make(map[string][]byte)
+	tx.kv.mu.RLock()
+	for k, v := range tx.kv.data {
+		if strings.HasPrefix(k, prefix) {
+			values[k] = v
+		}
+	}
+	tx.kv.mu.RUnlock()
+
+	for k, v := range tx.writes {
+		if strings.HasPrefix(k, prefix) {
+			values[k] = v
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for k, v := range values {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := fn(k, clone(values[k])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *memoryTx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	tx.kv.mu.Lock()
+	defer tx.kv.mu.Unlock()
+
+	for k, v := range tx.writes {
+		if v == nil {
+			delete(tx.kv.data, k)
+		} else {
+			tx.kv.data[k] = v
+		}
+	}
+
+	return nil
+}
+
+func (tx *memoryTx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	return nil
+}
+
+// clone returns a copy of b, which is never nil.
+func clone(b []byte) []byte {
+	return append(make([]byte, 0, len(b)), b...)
+}
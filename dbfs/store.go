@@ -0,0 +1,418 @@
+package dbfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v6/internal/errno"
+)
+
+// The metadata of the files are kept under metaPrefix, keyed by their parent
+// dir and their name separated by a NUL byte, so the children of a dir are
+// the keys starting with its path and a NUL byte. Their contents are kept
+// under chunkPrefix, split in chunks keyed by their path and the index of
+// the chunk in hexadecimal.
+const (
+	metaPrefix  = "m:"
+	chunkPrefix = "c:"
+)
+
+// maxSymlinks is the number of symlinks followed when resolving a path, past
+// which it fails with ELOOP, as on Linux.
+const maxSymlinks = 40
+
+// node is the metadata of a file, dir or symlink.
+type node struct {
+	Mode    fs.FileMode `json:"mode"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime time.Time   `json:"mtime"`
+	Target  string      `json:"target,omitempty"`
+	UID     int         `json:"uid,omitempty"`
+	GID     int         `json:"gid,omitempty"`
+}
+
+// chown changes the owner of the node, leaving the ids which are -1
+// unchanged, as os.Chown does.
+func (n *node) chown(uid, gid int) {
+	if uid != -1 {
+		n.UID = uid
+	}
+	if gid != -1 {
+		n.GID = gid
+	}
+}
+
+// root is the node of the root dir, which is not stored.
+var root = &node{Mode: fs.ModeDir | 0o755}
+
+// store implements the operations of the filesystem over a transaction.
+// Paths are clean, slash separated and relative to the root, which is the
+// empty path.
+type store struct {
+	tx        KVTx
+	chunkSize int64
+}
+
+func metaKey(p string) string {
+	dir, name := split(p)
+	return metaPrefix + dir + "\x00" + name
+}
+
+func childrenPrefix(dir string) string {
+	return metaPrefix + dir + "\x00"
+}
+
+func chunkKey(p string, i int64) string {
+	return fmt.Sprintf("%s%s\x00%016x", chunkPrefix, p, i)
+}
+
+// split splits p into its parent dir and its name.
+func split(p string) (string, string) {
+	i := strings.LastIndexByte(p, '/')
+	if i < 0 {
+		return "", p
+	}
+
+	return p[:i], p[i+1:]
+}
+
+func join(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+
+	return dir + "/" + name
+}
+
+func (s *store) get(p string) (*node, error) {
+	if p == "" {
+		return root, nil
+	}
+
+	v, ok, err := s.tx.Get(metaKey(p))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	n := &node{}
+	if err := json.Unmarshal(v, n); err != nil {
+		return nil, fmt.Errorf("corrupted metadata of %q: %w", p, err)
+	}
+
+	return n, nil
+}
+
+func (s *store) put(p string, n *node) error {
+	v, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.Put(metaKey(p), v)
+}
+
+// resolve returns the path p resolves to once the symlinks among its parents
+// are followed, and the last element too if follow is set, along with its
+// node. The node is nil if the last element does not exist.
+func (s *store) resolve(p string, follow bool) (string, *node, error) {
+	if p == "" {
+		return "", root, nil
+	}
+
+	links := 0
+	elems := strings.Split(p, "/")
+	cur := ""
+	for i := 0; i < len(elems); i++ {
+		next := join(cur, elems[i])
+		n, err := s.get(next)
+		if err != nil {
+			return "", nil, err
+		}
+
+		last := i == len(elems)-1
+		if n == nil {
+			if last {
+				return next, nil, nil
+			}
+			return "", nil, os.ErrNotExist
+		}
+
+		if n.Mode&fs.ModeSymlink != 0 && (!last || follow) {
+			if links++; links > maxSymlinks {
+				return "", nil, errno.ELOOP
+			}
+
+			base := "/" + cur
+			if path.IsAbs(n.Target) {
+				base = "/"
+			}
+
+			rest := path.Join(append([]string{base, n.Target}, elems[i+1:]...)...)
+			if rest = strings.TrimPrefix(rest, "/"); rest == "" {
+				return "", root, nil
+			}
+
+			elems, cur, i = strings.Split(rest, "/"), "", -1
+			continue
+		}
+
+		if !last && !n.Mode.IsDir() {
+			return "", nil, syscall.ENOTDIR
+		}
+
+		cur = next
+		if last {
+			return cur, n, nil
+		}
+	}
+
+	return cur, nil, nil
+}
+
+// resolveDir returns the path the dir p resolves to, following symlinks.
+func (s *store) resolveDir(p string) (string, error) {
+	real, n, err := s.resolve(p, true)
+	switch {
+	case err != nil:
+		return "", err
+	case n == nil:
+		return "", os.ErrNotExist
+	case !n.Mode.IsDir():
+		return "", syscall.ENOTDIR
+	default:
+		return real, nil
+	}
+}
+
+// mkdirAll creates the dir p along with its parents, following symlinks.
+func (s *store) mkdirAll(p string, perm fs.FileMode) error {
+	if p == "" {
+		return nil
+	}
+
+	cur := ""
+	for _, elem := range strings.Split(p, "/") {
+		real, n, err := s.resolve(join(cur, elem), true)
+		if err != nil {
+			return err
+		}
+
+		if n == nil {
+			n = &node{Mode: fs.ModeDir | perm&fs.ModePerm, ModTime: time.Now()}
+			if err := s.put(real, n); err != nil {
+				return err
+			}
+		}
+
+		if !n.Mode.IsDir() {
+			return syscall.ENOTDIR
+		}
+		cur = real
+	}
+
+	return nil
+}
+
+// children calls fn with the names and nodes of the children of dir, in
+// order.
+func (s *store) children(dir string, fn func(name string, n *node) error) error {
+	prefix := childrenPrefix(dir)
+	return s.tx.Scan(prefix, func(key string, v []byte) error {
+		n := &node{}
+		if err := json.Unmarshal(v, n); err != nil {
+			return fmt.Errorf("corrupted metadata of %q: %w", key, err)
+		}
+
+		return fn(key[len(prefix):], n)
+	})
+}
+
+// errStop stops scanning the children of a dir.
+var errStop = errors.New("stop")
+
+func (s *store) isEmpty(dir string) (bool, error) {
+	empty := true
+	err := s.children(dir, func(string, *node) error {
+		empty = false
+		return errStop
+	})
+	if errors.Is(err, errStop) {
+		err = nil
+	}
+
+	return empty, err
+}
+
+// chunks returns the number of chunks holding size bytes.
+func (s *store) chunks(size int64) int64 {
+	return (size + s.chunkSize - 1) / s.chunkSize
+}
+
+func (s *store) readAt(p string, n *node, b []byte, off int64) (int, error) {
+	if off >= n.Size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(b))
+	if end > n.Size {
+		end = n.Size
+	}
+
+	for pos := off; pos < end; {
+		i, co := pos/s.chunkSize, pos%s.chunkSize
+		want := s.chunkSize - co
+		if want > end-pos {
+			want = end - pos
+		}
+
+		chunk, _, err := s.tx.Get(chunkKey(p, i))
+		if err != nil {
+			return int(pos - off), err
+		}
+
+		// Chunks are missing or short past the end of sparse files, which
+		// read as zeros.
+		dst := b[pos-off : pos-off+want]
+		copied := 0
+		if co < int64(len(chunk)) {
+			copied = copy(dst, chunk[co:])
+		}
+		clear(dst[copied:])
+
+		pos += want
+	}
+
+	read := int(end - off)
+	if read < len(b) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+func (s *store) writeAt(p string, n *node, b []byte, off int64) (int, error) {
+	end := off + int64(len(b))
+	for pos := off; pos < end; {
+		i, co := pos/s.chunkSize, pos%s.chunkSize
+		want := s.chunkSize - co
+		if want > end-pos {
+			want = end - pos
+		}
+
+		chunk, _, err := s.tx.Get(chunkKey(p, i))
+		if err != nil {
+			return int(pos - off), err
+		}
+
+		if need := co + want; int64(len(chunk)) < need {
+			chunk = append(chunk, make([]byte, need-int64(len(chunk)))...)
+		}
+		copy(chunk[co:], b[pos-off:pos-off+want])
+
+		if err := s.tx.Put(chunkKey(p, i), chunk); err != nil {
+			return int(pos - off), err
+		}
+
+		pos += want
+	}
+
+	if end > n.Size {
+		n.Size = end
+	}
+	n.ModTime = time.Now()
+
+	return len(b), s.put(p, n)
+}
+
+func (s *store) truncate(p string, n *node, size int64) error {
+	if size < n.Size {
+		keep := s.chunks(size)
+		for i := keep; i < s.chunks(n.Size); i++ {
+			if err := s.tx.Delete(chunkKey(p, i)); err != nil {
+				return err
+			}
+		}
+
+		if rest := size % s.chunkSize; rest != 0 {
+			chunk, ok, err := s.tx.Get(chunkKey(p, keep-1))
+			if err != nil {
+				return err
+			}
+
+			if ok && int64(len(chunk)) > rest {
+				if err := s.tx.Put(chunkKey(p, keep-1), chunk[:rest]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	n.Size = size
+	n.ModTime = time.Now()
+
+	return s.put(p, n)
+}
+
+// remove removes the node n at p, along with its contents.
+func (s *store) remove(p string, n *node) error {
+	for i := int64(0); i < s.chunks(n.Size); i++ {
+		if err := s.tx.Delete(chunkKey(p, i)); err != nil {
+			return err
+		}
+	}
+
+	return s.tx.Delete(metaKey(p))
+}
+
+// move moves the node n from p to the free path to, along with its contents
+// or children.
+func (s *store) move(p string, n *node, to string) error {
+	if err := s.put(to, n); err != nil {
+		return err
+	}
+
+	if n.Mode.IsDir() {
+		type child struct {
+			name string
+			n    *node
+		}
+
+		var children []child
+		err := s.children(p, func(name string, n *node) error {
+			children = append(children, child{name, n})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range children {
+			if err := s.move(join(p, c.name), c.n, join(to, c.name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := int64(0); i < s.chunks(n.Size); i++ {
+		chunk, ok, err := s.tx.Get(chunkKey(p, i))
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if err := s.tx.Put(chunkKey(to, i), chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.remove(p, n)
+}
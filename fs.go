@@ -1,9 +1,12 @@
 package billy
 
 import (
+	"crypto"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"os"
 	"time"
 )
 
@@ -11,8 +14,75 @@ var (
 	ErrReadOnly        = errors.New("read-only filesystem")
 	ErrNotSupported    = errors.New("feature not supported")
 	ErrCrossedBoundary = errors.New("chroot boundary crossed")
+	ErrNoXattr         = errors.New("extended attribute not found")
+	ErrNameTooLong     = errors.New("file name too long")
 )
 
+// BoundaryError is the error reported when a path crosses the boundary of a
+// filesystem, such as the root of a chroot, naming both of them. It is
+// usually wrapped in an *fs.PathError for the operation, and it wraps
+// ErrCrossedBoundary, so errors.Is checks against it keep working.
+type BoundaryError struct {
+	// Base is the boundary crossed, such as the root of the chroot.
+	Base string
+	// Path is the path crossing it.
+	Path string
+	// Err is ErrCrossedBoundary, or an error wrapping it.
+	Err error
+}
+
+func (e *BoundaryError) Error() string {
+	return fmt.Sprintf("%s is outside of %s: %v", e.Path, e.Base, e.Err)
+}
+
+func (e *BoundaryError) Unwrap() error {
+	return e.Err
+}
+
+// NameError is the error reported when a path, or one of its elements, is
+// longer than allowed by the NameLimits of a filesystem. It is usually
+// wrapped in an *fs.PathError for the operation, and it wraps
+// ErrNameTooLong.
+type NameError struct {
+	// Path is the path rejected.
+	Path string
+	// Name is the element of Path which is too long, or empty if Path as a
+	// whole is.
+	Name string
+	// Max is the limit exceeded, in bytes.
+	Max int
+}
+
+func (e *NameError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%s: name %s is longer than %d bytes: %v", e.Path, e.Name, e.Max, ErrNameTooLong)
+	}
+
+	return fmt.Sprintf("%s is longer than %d bytes: %v", e.Path, e.Max, ErrNameTooLong)
+}
+
+func (e *NameError) Unwrap() error {
+	return ErrNameTooLong
+}
+
+// WrapPathError returns err wrapped in an *fs.PathError holding op and path.
+// Billy filesystems report the errors of the operations on a path this way,
+// as the os package does, so that callers can rely on errors.As to find the
+// path involved. If err is nil, or it already wraps an *fs.PathError, it is
+// returned unchanged.
+func WrapPathError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		return err
+	}
+
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
+
 // Capability holds the supported features of a billy filesystem. This does
 // not mean that the capability has to be supported by the underlying storage.
 // For example, a billy filesystem may support WriteCapability but the
@@ -32,6 +102,15 @@ const (
 	TruncateCapability
 	// LockCapability is the ability to lock a file.
 	LockCapability
+	// SymlinkCapability is the ability to create symbolic links, and to
+	// follow them when resolving paths.
+	SymlinkCapability
+	// ChangeCapability is the ability to change the metadata of files, such
+	// as their mode, owner and times, through the Change interface.
+	ChangeCapability
+	// RemoveAllCapability is the ability to remove a whole tree natively,
+	// with a RemoveAll(path string) error method, as used by util.RemoveAll.
+	RemoveAllCapability
 
 	// DefaultCapabilities lists all capable features supported by filesystems
 	// without Capability interface. This list should not be changed until a
@@ -43,12 +122,23 @@ const (
 	// AllCapabilities lists all capable features.
 	AllCapabilities Capability = WriteCapability | ReadCapability |
 		ReadAndWriteCapability | SeekCapability | TruncateCapability |
-		LockCapability
+		LockCapability | SymlinkCapability | ChangeCapability |
+		RemoveAllCapability
 )
 
 // Filesystem abstract the operations in a storage-agnostic interface.
 // Each method implementation mimics the behavior of the equivalent functions
 // at the os package from the standard library.
+//
+// Paths are relative to the root of the filesystem, even when they start
+// with a separator, which refers to that root and not to the root of the
+// host. The util.Abs and util.Rel functions convert between these paths and
+// the paths in the underlying storage.
+//
+// The empty path is the same as ".", so "", "." and "/" all name the root,
+// which can be neither removed nor renamed: doing so fails with an error
+// wrapping os.ErrInvalid. Paths are cleaned, so trailing separators are
+// ignored, as in "dir/".
 type Filesystem interface {
 	Basic
 	TempFile
@@ -90,21 +180,44 @@ type Basic interface {
 
 type TempFile interface {
 	// TempFile creates a new temporary file in the directory dir with a name
-	// beginning with prefix, opens the file for reading and writing, and
-	// returns the resulting *os.File. If dir is the empty string, TempFile
-	// uses the default directory for temporary files (see os.TempDir).
-	// Multiple programs calling TempFile simultaneously will not choose the
-	// same file. The caller can use f.Name() to find the pathname of the file.
-	// It is the caller's responsibility to remove the file when no longer
-	// needed.
+	// beginning with prefix, as CreateTemp does with the pattern prefix+"*".
+	//
+	// Deprecated: use CreateTemp, which also allows a suffix.
 	TempFile(dir, prefix string) (File, error)
+	// TempDir creates a new temporary directory in the directory dir with a
+	// name beginning with prefix, as MkdirTemp does with the pattern
+	// prefix+"*".
+	//
+	// Deprecated: use MkdirTemp, which also allows a suffix.
+	TempDir(dir, prefix string) (string, error)
+	// CreateTemp creates a new temporary file in the directory dir, opens the
+	// file for reading and writing, and returns the resulting file. The
+	// filename is generated by taking pattern and adding a random string to
+	// the end. If pattern includes a "*", the random string replaces the last
+	// "*". If dir is the empty string, CreateTemp uses the default directory
+	// for temporary files of the filesystem. Multiple programs calling
+	// CreateTemp simultaneously will not choose the same file. The caller can
+	// use f.Name() to find the pathname of the file. It is the caller's
+	// responsibility to remove the file when no longer needed.
+	CreateTemp(dir, pattern string) (File, error)
+	// MkdirTemp creates a new temporary directory in the directory dir and
+	// returns the path of the new directory. The name of the directory is
+	// generated from pattern, as the one of the files created by CreateTemp.
+	// If dir is the empty string, MkdirTemp uses the default directory for
+	// temporary files of the filesystem. Multiple programs calling MkdirTemp
+	// simultaneously will not choose the same directory. It is the caller's
+	// responsibility to remove the directory when no longer needed.
+	MkdirTemp(dir, pattern string) (string, error)
 }
 
 // Dir abstract the dir related operations in a storage-agnostic interface as
 // an extension to the Basic interface.
 type Dir interface {
 	// ReadDir reads the directory named by dirname and returns a list of
-	// directory entries sorted by filename.
+	// directory entries sorted by filename, in lexical byte order, so the
+	// listing is the same regardless of the implementation. Filesystems may
+	// provide an option to skip the sorting, such as the WithUnsortedReadDir
+	// options of memfs and osfs, for callers which do not depend on it.
 	ReadDir(path string) ([]fs.FileInfo, error)
 	// MkdirAll creates a directory named path, along with any necessary
 	// parents, and returns nil, or else returns an error. The permission bits
@@ -113,6 +226,139 @@ type Dir interface {
 	MkdirAll(filename string, perm fs.FileMode) error
 }
 
+// DirNames is implemented by filesystems able to list the names of the
+// entries of a directory without building a FileInfo for each of them, which
+// is considerably cheaper for directories holding a large number of files.
+type DirNames interface {
+	// ReadDirNames reads the directory named by path and returns up to n
+	// names of its entries. If n <= 0, all the names are returned. The names
+	// are not guaranteed to be sorted.
+	ReadDirNames(path string, n int) ([]string, error)
+}
+
+// DirEntries is implemented by filesystems able to list the entries of a
+// directory without describing each of them upfront. The type of an entry is
+// known from the listing itself, and its FileInfo is only looked up when the
+// Info method of the entry is called.
+type DirEntries interface {
+	// ReadDirEntries reads the directory named by path and returns its
+	// entries sorted by filename.
+	ReadDirEntries(path string) ([]fs.DirEntry, error)
+}
+
+// BatchStater is implemented by filesystems able to stat many files at once
+// more cheaply than one at a time, such as by looking up the files of the
+// same directory through a single handle of the directory.
+type BatchStater interface {
+	// StatMany returns the FileInfo of each of the named files, as Stat
+	// does, along with the error of each of them, at the same index.
+	StatMany(paths []string) ([]os.FileInfo, []error)
+}
+
+// DirOpener is implemented by filesystems able to list a directory
+// incrementally, keeping memory usage bounded regardless of the number of
+// entries in the directory.
+type DirOpener interface {
+	// OpenDir opens the directory named by path for iteration. The caller
+	// must close the returned DirIter once done with it.
+	OpenDir(path string) (DirIter, error)
+}
+
+// DirIter iterates over the entries of a directory.
+type DirIter interface {
+	// Next returns the next entry of the directory. Once all the entries
+	// have been returned, Next returns io.EOF. The entries are not
+	// guaranteed to be sorted.
+	Next() (fs.DirEntry, error)
+	// Close releases the resources held by the iterator.
+	Close() error
+}
+
+// ReadDirFile is implemented by the files returned when a directory is opened
+// read-only, as os.Open does, so that its entries can be listed through the
+// handle. Reading from a directory fails with syscall.EISDIR.
+type ReadDirFile interface {
+	File
+	// ReadDir reads the entries of the directory, with the semantics of
+	// os.File.ReadDir: if n > 0, it returns at most n entries, and io.EOF
+	// once all of them have been returned. If n <= 0, it returns all the
+	// remaining entries.
+	ReadDir(n int) ([]fs.DirEntry, error)
+}
+
+// DirSyncer is implemented by filesystems able to commit the entries of a
+// directory to stable storage. After a file is created or renamed, its
+// parent directory must be synced as well for the change to survive a crash.
+type DirSyncer interface {
+	// SyncDir commits the entries of the directory named by path to stable
+	// storage.
+	SyncDir(path string) error
+}
+
+// Renamer is implemented by filesystems able to rename files atomically with
+// stronger guarantees than Rename, such as creating a file only if it does
+// not exist yet.
+type Renamer interface {
+	// RenameNoReplace renames oldpath to newpath, failing with an error
+	// wrapping os.ErrExist if newpath already exists. The check and the
+	// rename happen atomically.
+	RenameNoReplace(oldpath, newpath string) error
+	// RenameExchange atomically swaps oldpath and newpath, which must both
+	// exist. They can be of different types, such as a file and a dir.
+	RenameExchange(oldpath, newpath string) error
+}
+
+// Hasher is implemented by filesystems able to compute the checksum of a
+// file cheaply, without reading it fully, such as object stores keeping it
+// along with the object.
+type Hasher interface {
+	// HashFile returns the checksum of the content of the named file,
+	// computed with h. It fails with an error wrapping ErrNotSupported if h
+	// cannot be computed natively, so callers can fall back to reading the
+	// file.
+	HashFile(path string, h crypto.Hash) ([]byte, error)
+}
+
+// Copier is implemented by filesystems able to copy a file natively, without
+// reading it through the client, such as object stores copying objects on the
+// server side.
+type Copier interface {
+	// CopyFile copies the content of the file src to dst, creating dst or
+	// truncating it if it exists. It fails with an error wrapping
+	// ErrNotSupported if the file cannot be copied natively, so callers
+	// can fall back to reading it.
+	CopyFile(src, dst string) error
+}
+
+// Mapper is implemented by filesystems able to map the content of a file in
+// memory, such as with mmap on the OS, sparing readers of large files, such
+// as packfiles, from copying it.
+type Mapper interface {
+	// Mmap maps the content of the named file in memory, read-only. Changes
+	// made to the file while mapped may or may not be visible through the
+	// mapping.
+	Mmap(path string) (Mapping, error)
+}
+
+// Mapping is the content of a file mapped in memory, as returned by Mapper.
+// It must be closed to release the memory.
+type Mapping interface {
+	// Bytes returns the content of the file. It must not be modified, and
+	// can only be used until the mapping is closed.
+	Bytes() []byte
+	io.ReaderAt
+	io.Closer
+}
+
+// Umasker is implemented by filesystems clearing the permission bits of a
+// umask from the files and dirs they create, as the OS does with the umask
+// of the process, when they know it upfront.
+type Umasker interface {
+	// Umask returns the permission bits cleared from the mode of the files
+	// and dirs created.
+	Umask() fs.FileMode
+}
+
 // Symlink abstract the symlink related operations in a storage-agnostic
 // interface as an extension to the Basic interface.
 type Symlink interface {
@@ -148,6 +394,81 @@ type Change interface {
 	Chtimes(name string, atime time.Time, mtime time.Time) error
 }
 
+// SymlinkChange is implemented by filesystems able to change the mode and
+// times of symlinks themselves, rather than of their targets, as an extension
+// to the Change interface. Not every platform allows it: the mode of a
+// symlink cannot be changed on Linux, so Lchmod fails there with an error
+// wrapping ErrNotSupported when name is a symlink.
+type SymlinkChange interface {
+	// Lchmod changes the mode of the named file to mode. If the file is a
+	// symbolic link, it changes the mode of the link itself.
+	Lchmod(name string, mode fs.FileMode) error
+	// Lchtimes changes the access and modification times of the named
+	// file. If the file is a symbolic link, it changes the times of the link
+	// itself. The zero time.Time value leaves the corresponding time
+	// unchanged.
+	Lchtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// FileChange is implemented by the files able to change their own metadata,
+// like fchmod and futimens, as an extension to the File interface. Unlike
+// Change, it operates on the open file itself, so it is not affected by the
+// file being renamed or replaced since it was opened.
+type FileChange interface {
+	// Chmod changes the mode of the file to mode.
+	Chmod(mode fs.FileMode) error
+	// Chtimes changes the access and modification times of the file. The
+	// zero time.Time value leaves the corresponding time unchanged.
+	//
+	// The underlying filesystem may truncate or round the values to a less
+	// precise time unit.
+	Chtimes(atime time.Time, mtime time.Time) error
+}
+
+// RawFile is implemented by the files backed by a file of the OS, as an
+// extension to the File interface, giving access to it for the operations
+// billy does not cover, such as mmap, sendfile, flock or fadvise.
+type RawFile interface {
+	// RawFile returns the *os.File backing the file, or an error wrapping
+	// ErrNotSupported if there is none, such as for wrappers of in-memory
+	// files. The *os.File is owned by the file: it must not be closed, and
+	// can only be used until the file is closed.
+	RawFile() (*os.File, error)
+}
+
+// FileStat holds the ownership and link count of a file. It is returned by
+// the Sys method of the FileInfo of filesystems which emulate them instead of
+// relying on the OS, such as memfs, playing the role of the *syscall.Stat_t
+// returned by the OS on Unix.
+type FileStat struct {
+	// UID is the numeric user id of the owner of the file.
+	UID int
+	// GID is the numeric group id of the owner of the file.
+	GID int
+	// Nlink is the number of hard links to the file.
+	Nlink uint64
+}
+
+// Xattr abstract the extended attributes related operations in a
+// storage-agnostic interface as an extension to the Basic interface.
+// Extended attributes are name:value pairs associated with a file, which can
+// be used to store metadata, such as checksums, alongside the file contents.
+type Xattr interface {
+	// GetXattr returns the value of the extended attribute name of the named
+	// file. If the attribute does not exist, an error wrapping ErrNoXattr is
+	// returned.
+	GetXattr(path, name string) ([]byte, error)
+	// SetXattr sets the value of the extended attribute name of the named
+	// file, creating the attribute if it does not exist.
+	SetXattr(path, name string, value []byte) error
+	// ListXattr returns the names of the extended attributes of the named
+	// file.
+	ListXattr(path string) ([]string, error)
+	// RemoveXattr removes the extended attribute name of the named file. If
+	// the attribute does not exist, an error wrapping ErrNoXattr is returned.
+	RemoveXattr(path, name string) error
+}
+
 // Chroot abstract the chroot related operations in a storage-agnostic interface
 // as an extension to the Basic interface.
 type Chroot interface {
@@ -159,13 +480,77 @@ type Chroot interface {
 	Root() string
 }
 
-// File represent a file, being a subset of the os.File
+// PathMapper maps the paths given to the wrappers resolving them within a
+// tree of their own, such as chroot, mount and prefixfs, which accept one
+// with their WithPathMapper option. It allows custom rules, such as folding
+// the case of the paths or trimming their trailing dots and spaces as
+// Windows does, to be applied consistently by every wrapper.
+type PathMapper interface {
+	// MapPath returns the path to use in place of path. It is called
+	// before path is cleaned, so it may be relative, absolute, or use any
+	// separator. Wrappers may map a path more than once, so the mapping
+	// must be idempotent.
+	MapPath(path string) string
+}
+
+// PathMapperFunc is a func used as a PathMapper.
+type PathMapperFunc func(path string) string
+
+// MapPath implements the PathMapper interface, calling f.
+func (f PathMapperFunc) MapPath(path string) string {
+	return f(path)
+}
+
+// NameLimits bounds the length of the paths accepted by a filesystem, so that
+// overlong paths are rejected up front with a *NameError, the same way on
+// every platform, instead of failing deep inside an operation. Filesystems
+// accept them with their WithNameLimits option. Lengths are in bytes, and
+// zero means no limit.
+type NameLimits struct {
+	// MaxName bounds the length of every element of a path.
+	MaxName int
+	// MaxPath bounds the length of a whole path, once cleaned and made
+	// relative to the root of the filesystem.
+	MaxPath int
+}
+
+// DefaultNameLimits are the limits of most filesystems: 255 bytes for a
+// name, as NAME_MAX, and 4096 for a path, as PATH_MAX on Linux.
+var DefaultNameLimits = NameLimits{MaxName: 255, MaxPath: 4096}
+
+// Composite is implemented by the filesystems built on top of others, such as
+// the wrappers in the helper packages, so a stack of filesystems can be
+// inspected, see util.DumpStack. Filesystems composing others usually
+// implement fmt.Stringer too, describing themselves along with their root.
+type Composite interface {
+	// Layers returns the filesystems this one is built on, in the order they
+	// are looked up.
+	Layers() []Basic
+}
+
+// File represent a file, being a subset of the os.File. Every File returned
+// by a billy filesystem implements all of its methods, so callers don't need
+// type assertions to reach e.g. ReadAt or Truncate: operations a file doesn't
+// support, such as writing to a file opened read-only, fail with an error
+// instead.
 type File interface {
+	// File provides Read, Close and Stat. Stat describes the open file,
+	// reporting the base name of the file as its name, like os.File does.
 	fs.File
 
-	// Name returns the name of the file as presented to Open.
+	// Name returns the name of the file, cleaned and relative to the root of
+	// the filesystem which opened it, so that it can be passed back to the
+	// filesystem to open the same file again. Files created outside of the
+	// root, such as the temp files of osfs.BoundOS in the OS temp dir, are
+	// named by their absolute path instead.
 	Name() string
+	// OpenedPath returns the path exactly as presented to the method which
+	// opened the file. For temp files it is the same as Name.
+	OpenedPath() string
 	io.Writer
+	// WriterAt and ReaderAt neither use nor move the offset of the file. As
+	// with *os.File, they are safe for concurrent use on the same file,
+	// unlike the other methods.
 	io.WriterAt
 	io.ReaderAt
 	io.Seeker
@@ -176,6 +561,9 @@ type File interface {
 	Unlock() error
 	// Truncate the file.
 	Truncate(size int64) error
+	// Sync commits the current contents of the file to stable storage.
+	// Implementations without stable storage return nil.
+	Sync() error
 }
 
 // Capable interface can return the available features of a filesystem.
@@ -185,14 +573,47 @@ type Capable interface {
 }
 
 // Capabilities returns the features supported by a filesystem. If the FS
-// does not implement Capable interface it returns all features.
+// does not implement Capable interface it returns DefaultCapabilities, along
+// with SymlinkCapability if it implements the Symlink interface,
+// ChangeCapability if it implements the Change interface, and
+// RemoveAllCapability if it has a RemoveAll method.
 func Capabilities(fs Basic) Capability {
 	capable, ok := fs.(Capable)
-	if !ok {
-		return DefaultCapabilities
+	if ok {
+		return capable.Capabilities()
+	}
+
+	c := DefaultCapabilities
+	if _, ok := fs.(Symlink); ok {
+		c |= SymlinkCapability
 	}
+	if _, ok := fs.(Change); ok {
+		c |= ChangeCapability
+	}
+	if _, ok := fs.(removerAll); ok {
+		c |= RemoveAllCapability
+	}
+
+	return c
+}
 
-	return capable.Capabilities()
+type removerAll interface {
+	RemoveAll(path string) error
+}
+
+// WrapperCapabilities returns the capabilities of wrapper, a filesystem
+// wrapping others whose capabilities are c, for it to report from its
+// Capabilities method. They are c, with RemoveAllCapability set if and only
+// if wrapper has a RemoveAll method, as Capabilities does for the
+// filesystems which are not Capable. The wrappers without one thus have the
+// trees removed entry by entry through their Remove method, seeing every
+// entry removed.
+func WrapperCapabilities(wrapper Basic, c Capability) Capability {
+	if _, ok := wrapper.(removerAll); ok {
+		return c | RemoveAllCapability
+	}
+
+	return c &^ RemoveAllCapability
 }
 
 // CapabilityCheck tests the filesystem for the provided capabilities and
@@ -201,3 +622,145 @@ func CapabilityCheck(fs Basic, capabilities Capability) bool {
 	fsCaps := Capabilities(fs)
 	return fsCaps&capabilities == capabilities
 }
+
+// Feature is an optional feature of a filesystem, most of them being one of
+// the optional interfaces extending Basic. See Supports.
+type Feature int
+
+const (
+	// TempFileFeature is the TempFile interface.
+	TempFileFeature Feature = iota + 1
+	// DirFeature is the Dir interface.
+	DirFeature
+	// SymlinkFeature is the Symlink interface, along with SymlinkCapability.
+	SymlinkFeature
+	// ChrootFeature is the Chroot interface.
+	ChrootFeature
+	// ChangeFeature is the Change interface.
+	ChangeFeature
+	// XattrFeature is the Xattr interface.
+	XattrFeature
+	// RenamerFeature is the Renamer interface.
+	RenamerFeature
+	// HasherFeature is the Hasher interface, computing checksums natively.
+	HasherFeature
+	// DirSyncerFeature is the DirSyncer interface.
+	DirSyncerFeature
+	// RemoveAllFeature is a RemoveAll(path string) error method, removing a
+	// tree natively, as used by util.RemoveAll.
+	RemoveAllFeature
+	// LockFeature is the ability to lock files, LockCapability.
+	LockFeature
+	// MapperFeature is the Mapper interface, mapping files in memory
+	// natively.
+	MapperFeature
+	// CopierFeature is the Copier interface, copying files natively.
+	CopierFeature
+)
+
+// FeatureReporter is implemented by the filesystems, usually wrappers, which
+// implement optional interfaces only as far as the filesystems they wrap do,
+// failing with ErrNotSupported otherwise.
+type FeatureReporter interface {
+	// Supports reports whether the optional feature f, whose interface is
+	// implemented by the filesystem, can actually be used.
+	Supports(f Feature) bool
+}
+
+// Supports reports whether the optional feature f of fs can be used. It
+// checks that fs implements the interface of f, and the Capability bits
+// involved, if any, and then asks fs if it implements FeatureReporter, so
+// that wrappers report the features of the filesystems they wrap.
+func Supports(fs Basic, f Feature) bool {
+	var ok bool
+	switch f {
+	case TempFileFeature:
+		_, ok = fs.(TempFile)
+	case DirFeature:
+		_, ok = fs.(Dir)
+	case SymlinkFeature:
+		_, ok = fs.(Symlink)
+		ok = ok && CapabilityCheck(fs, SymlinkCapability)
+	case ChrootFeature:
+		_, ok = fs.(Chroot)
+	case ChangeFeature:
+		_, ok = fs.(Change)
+	case XattrFeature:
+		_, ok = fs.(Xattr)
+	case RenamerFeature:
+		_, ok = fs.(Renamer)
+	case HasherFeature:
+		_, ok = fs.(Hasher)
+	case DirSyncerFeature:
+		_, ok = fs.(DirSyncer)
+	case RemoveAllFeature:
+		_, ok = fs.(removerAll)
+	case LockFeature:
+		ok = CapabilityCheck(fs, LockCapability)
+	case MapperFeature:
+		_, ok = fs.(Mapper)
+	case CopierFeature:
+		_, ok = fs.(Copier)
+	}
+
+	if !ok {
+		return false
+	}
+
+	if r, isReporter := fs.(FeatureReporter); isReporter {
+		return r.Supports(f)
+	}
+
+	return true
+}
+
+// PathProperties describes the naming rules of a billy filesystem, so callers
+// can decide how to name files without probing the storage.
+type PathProperties struct {
+	// CaseSensitive reports whether names that only differ in case refer to
+	// different files.
+	CaseSensitive bool
+	// MaxPathLength is the maximum length in bytes of a full path, or zero if
+	// the filesystem does not impose a limit.
+	MaxPathLength int
+	// MaxNameLength is the maximum length in bytes of a single path element,
+	// or zero if the filesystem does not impose a limit.
+	MaxNameLength int
+	// InvalidChars lists the characters that cannot be used in a file name,
+	// apart from the path separator.
+	InvalidChars string
+	// ExplicitDirs reports whether, as on POSIX, the parent dir of a file
+	// must exist for Create, OpenFile with os.O_CREATE, Symlink and Rename
+	// to create it, failing with os.ErrNotExist otherwise. Filesystems
+	// without it create the missing parents, as MkdirAll does.
+	ExplicitDirs bool
+	// Separator is the separator of the path elements, or zero if it is the
+	// one of the OS, os.PathSeparator. Filesystems which are not backed by
+	// the OS may use the same one regardless of the OS, such as memfs with
+	// WithSlashSeparator.
+	Separator byte
+}
+
+// DefaultPathProperties are the path properties assumed for filesystems
+// without Introspectable interface.
+var DefaultPathProperties = PathProperties{
+	CaseSensitive: true,
+	InvalidChars:  "\x00",
+}
+
+// Introspectable interface can describe the naming rules of a filesystem.
+type Introspectable interface {
+	// PathProperties returns the path related properties of a filesystem.
+	PathProperties() PathProperties
+}
+
+// Introspect returns the path properties of a filesystem. If the FS does not
+// implement Introspectable interface it returns DefaultPathProperties.
+func Introspect(fs Basic) PathProperties {
+	i, ok := fs.(Introspectable)
+	if !ok {
+		return DefaultPathProperties
+	}
+
+	return i.PathProperties()
+}
@@ -1,11 +1,16 @@
 package billy_test
 
 import (
+	"io/fs"
+	"os"
 	"testing"
+	"time"
 
 	. "github.com/go-git/go-billy/v6" //nolint
+	"github.com/go-git/go-billy/v6/helper/chroot"
 	"github.com/go-git/go-billy/v6/internal/test"
-	"github.com/stretchr/testify/assert"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/memfs"
 )
 
 func TestCapabilities(t *testing.T) {
@@ -30,4 +35,89 @@ func TestCapabilities(t *testing.T) {
 
 	dummy := new(test.BasicMock)
 	assert.Equal(t, Capabilities(dummy), DefaultCapabilities)
+
+	symlinks := new(test.SymlinkMock)
+	assert.Equal(t, Capabilities(symlinks), DefaultCapabilities|SymlinkCapability)
+
+	assert.Equal(t, DefaultCapabilities|ChangeCapability, Capabilities(new(changeFs)))
+	assert.Equal(t, DefaultCapabilities|RemoveAllCapability, Capabilities(new(removeAllFs)))
+}
+
+type changeFs struct{ test.BasicMock }
+
+func (*changeFs) Chmod(string, fs.FileMode) error            { return nil }
+func (*changeFs) Lchown(string, int, int) error              { return nil }
+func (*changeFs) Chown(string, int, int) error               { return nil }
+func (*changeFs) Chtimes(string, time.Time, time.Time) error { return nil }
+
+type removeAllFs struct{ test.BasicMock }
+
+func (*removeAllFs) RemoveAll(string) error { return nil }
+
+func TestSupports(t *testing.T) {
+	dummy := new(test.BasicMock)
+	assert.False(t, Supports(dummy, DirFeature))
+	assert.False(t, Supports(dummy, ChangeFeature))
+	assert.True(t, Supports(dummy, LockFeature))
+	assert.False(t, Supports(new(test.NoLockCapFs), LockFeature))
+
+	symlinks := new(test.SymlinkMock)
+	assert.True(t, Supports(symlinks, SymlinkFeature))
+	assert.False(t, Supports(symlinks, TempFileFeature))
+
+	// Wrappers implement every optional interface, but only support the
+	// features of the filesystems they wrap.
+	fs := chroot.New(dummy, "/foo")
+	assert.True(t, Supports(fs, ChrootFeature))
+	assert.False(t, Supports(fs, SymlinkFeature))
+	assert.False(t, Supports(fs, ChangeFeature))
+	assert.False(t, Supports(fs, TempFileFeature))
+	assert.False(t, Supports(fs, MapperFeature))
+
+	m := memfs.New()
+	for _, f := range []Feature{
+		TempFileFeature, DirFeature, SymlinkFeature, ChrootFeature,
+		ChangeFeature, XattrFeature, RenamerFeature, MapperFeature,
+	} {
+		assert.True(t, Supports(m, f), "feature %d", f)
+	}
+	assert.False(t, Supports(m, Feature(0)))
+}
+
+func TestIntrospect(t *testing.T) {
+	dummy := new(test.BasicMock)
+	assert.Equal(t, DefaultPathProperties, Introspect(dummy))
+
+	fs := new(test.CaseInsensitiveFs)
+	props := Introspect(fs)
+	assert.False(t, props.CaseSensitive)
+	assert.Equal(t, 260, props.MaxPathLength)
+	assert.Equal(t, 255, props.MaxNameLength)
+}
+
+func TestWrapPathError(t *testing.T) {
+	assert.NoError(t, WrapPathError("open", "foo", nil))
+
+	err := WrapPathError("open", "foo", os.ErrNotExist)
+	var perr *fs.PathError
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, "open", perr.Op)
+	assert.Equal(t, "foo", perr.Path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	orig := &fs.PathError{Op: "stat", Path: "bar", Err: os.ErrNotExist}
+	assert.Same(t, orig, WrapPathError("open", "foo", orig))
+}
+
+type removeAllMock struct {
+	test.BasicMock
+}
+
+func (*removeAllMock) RemoveAll(string) error { return nil }
+
+func TestWrapperCapabilities(t *testing.T) {
+	caps := ReadCapability | RemoveAllCapability
+	assert.Equal(t, ReadCapability, WrapperCapabilities(new(test.BasicMock), caps))
+	assert.Equal(t, caps, WrapperCapabilities(new(removeAllMock), ReadCapability))
+	assert.Equal(t, caps, WrapperCapabilities(new(removeAllMock), caps))
 }
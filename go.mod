@@ -1,16 +1,9 @@
 module github.com/go-git/go-billy/v6
 
 // go-git supports the last 3 stable Go versions.
-go 1.21
+go 1.24
 
 require (
 	github.com/cyphar/filepath-securejoin v0.4.1
-	github.com/stretchr/testify v1.10.0
 	golang.org/x/sys v0.29.0
 )
-
-require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
-)
@@ -0,0 +1,361 @@
+// Package bufferfs provides a billy filesystem wrapper which buffers the
+// writes to each file in memory, and writes them to the underlying
+// filesystem at once, reducing the number of calls made to slow backends.
+package bufferfs
+
+import (
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// DefaultMaxBufferedBytes is the maximum number of bytes buffered at once by
+// a Buffer filesystem, unless set with WithMaxBufferedBytes.
+const DefaultMaxBufferedBytes = 4 << 20
+
+// Option configures a Buffer filesystem.
+type Option func(*state)
+
+// WithMaxBufferedBytes limits the number of bytes buffered at once across all
+// the files opened through the filesystem. Once reached, files flush their
+// buffers before buffering more data, and writes larger than n go directly
+// to the underlying file. A value of n <= 0 disables buffering.
+func WithMaxBufferedBytes(n int64) Option {
+	return func(s *state) {
+		s.max = n
+	}
+}
+
+// state is shared by a Buffer and all the filesystems returned by its Chroot
+// method, so the limit applies to the whole tree.
+type state struct {
+	max      int64
+	buffered atomic.Int64
+}
+
+// Buffer is a helper that buffers the writes done over any billy.Filesystem.
+//
+// The writes made to a file with Write are kept in memory until the file is
+// closed, synced or unlocked, or until any other operation is done on it,
+// such as reading, seeking or truncating it. Until then, they are not
+// visible to other handles of the file nor to the filesystem, so Stat may
+// report an outdated size. Errors of buffered writes are reported by the
+// operation flushing them.
+type Buffer struct {
+	billy.Filesystem
+	s *state
+}
+
+// New creates a new filesystem wrapping up fs, which buffers the writes to
+// the files opened through it.
+func New(fs billy.Filesystem, opts ...Option) billy.Filesystem {
+	s := &state{max: DefaultMaxBufferedBytes}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return &Buffer{Filesystem: fs, s: s}
+}
+
+// Layers implements billy.Composite.
+func (h *Buffer) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *Buffer) String() string {
+	return "bufferfs"
+}
+
+// Buffered returns the number of bytes currently buffered, not yet written
+// to the underlying filesystem.
+func (h *Buffer) Buffered() int64 {
+	return h.s.buffered.Load()
+}
+
+func (h *Buffer) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Buffer) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file. Only the files opened for writing are
+// buffered, the others are returned as is.
+func (h *Buffer) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	f, err := h.Filesystem.OpenFile(filename, flag, perm)
+	if err != nil || !openflag.Writable(flag) {
+		return f, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), s: h.s}, nil
+}
+
+func (h *Buffer) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := h.Filesystem.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), s: h.s}, nil
+}
+
+func (h *Buffer) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := h.Filesystem.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), s: h.s}, nil
+}
+
+func (h *Buffer) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Buffer{Filesystem: fs, s: h.s}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Buffer) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Buffer) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Buffer) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (h *Buffer) ReadDirNames(path string, n int) ([]string, error) {
+	return util.ReadDirNames(h.Filesystem, path, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (h *Buffer) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	return util.ReadDirEntries(h.Filesystem, path)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (h *Buffer) OpenDir(path string) (billy.DirIter, error) {
+	return util.OpenDir(h.Filesystem, path)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (h *Buffer) SyncDir(path string) error {
+	return util.SyncDir(h.Filesystem, path)
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (h *Buffer) RenameNoReplace(from, to string) error {
+	return util.RenameNoReplace(h.Filesystem, from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (h *Buffer) RenameExchange(from, to string) error {
+	return util.RenameExchange(h.Filesystem, from, to)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Buffer) Chmod(name string, mode fs.FileMode) error {
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Buffer) Lchown(name string, uid, gid int) error {
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Buffer) Chown(name string, uid, gid int) error {
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface. The writes still buffered
+// by the files open for writing are flushed afterwards, so they may update
+// the modification time again.
+func (h *Buffer) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+// reserve accounts for n more buffered bytes, reporting whether they fit
+// within the limit.
+func (s *state) reserve(n int) bool {
+	if b := s.buffered.Add(int64(n)); b > s.max {
+		s.buffered.Add(int64(-n))
+		return false
+	}
+
+	return true
+}
+
+type file struct {
+	*wrappedfile.File
+	s      *state
+	buf    []byte
+	closed bool
+	// raw is set once the *os.File of the wrapped file is handed out, so
+	// the writes are no longer buffered, as they could be reordered with
+	// the ones made on it.
+	raw bool
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.closed || f.raw {
+		return f.File.Write(p)
+	}
+
+	if !f.s.reserve(len(p)) {
+		if err := f.flush(); err != nil {
+			return 0, err
+		}
+
+		if !f.s.reserve(len(p)) {
+			return f.File.Write(p)
+		}
+	}
+
+	f.buf = append(f.buf, p...)
+	return len(p), nil
+}
+
+// flush writes the buffered data to the underlying file. The data which could
+// not be written is kept, to be retried by the next flush.
+func (f *file) flush() error {
+	if len(f.buf) == 0 {
+		return nil
+	}
+
+	n, err := f.File.Write(f.buf)
+	f.s.buffered.Add(int64(-n))
+	f.buf = f.buf[n:]
+	if len(f.buf) == 0 {
+		f.buf = nil
+	}
+
+	return err
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if err := f.flush(); err != nil {
+		return 0, err
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.flush(); err != nil {
+		return 0, err
+	}
+
+	return f.File.ReadAt(p, off)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.flush(); err != nil {
+		return 0, err
+	}
+
+	return f.File.WriteAt(p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if err := f.flush(); err != nil {
+		return 0, err
+	}
+
+	return f.File.Seek(offset, whence)
+}
+
+func (f *file) Truncate(size int64) error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	return f.File.Truncate(size)
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if err := f.flush(); err != nil {
+		return nil, err
+	}
+
+	return f.File.Stat()
+}
+
+// Unlock writes the buffered data before unlocking the file, so it is
+// protected by the lock.
+func (f *file) Unlock() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	return f.File.Unlock()
+}
+
+// Chtimes writes the buffered data before changing the times of the file,
+// so the writes don't update them afterwards.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	return f.File.Chtimes(atime, mtime)
+}
+
+// RawFile writes the buffered data before returning the *os.File of the
+// wrapped file, and stops buffering the writes.
+func (f *file) RawFile() (*os.File, error) {
+	if err := f.flush(); err != nil {
+		return nil, err
+	}
+
+	raw, err := f.File.RawFile()
+	if err == nil {
+		f.raw = true
+	}
+
+	return raw, err
+}
+
+func (f *file) Sync() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	return f.File.Sync()
+}
+
+// Close writes the buffered data and closes the file. The file is closed
+// even if the data could not be written, in which case the data is dropped.
+func (f *file) Close() error {
+	if f.closed {
+		return f.File.Close()
+	}
+
+	err := f.flush()
+	f.closed = true
+	f.s.buffered.Add(int64(-len(f.buf)))
+	f.buf = nil
+
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,204 @@
+package bufferfs
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// countingFS counts the writes made to the files it opens.
+type countingFS struct {
+	billy.Filesystem
+	writes int
+}
+
+func (c *countingFS) Create(filename string) (billy.File, error) {
+	f, err := c.Filesystem.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingFile{File: f, fs: c}, nil
+}
+
+type countingFile struct {
+	billy.File
+	fs *countingFS
+}
+
+func (f *countingFile) Write(p []byte) (int, error) {
+	f.fs.writes++
+	return f.File.Write(p)
+}
+
+func (c *countingFS) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	f, err := c.Filesystem.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingFile{File: f, fs: c}, nil
+}
+
+func TestWritesBufferedUntilClose(t *testing.T) {
+	under := &countingFS{Filesystem: memfs.New()}
+	fs := New(under)
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+
+	for _, s := range []string{"foo", "bar", "baz"} {
+		n, err := f.Write([]byte(s))
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+	}
+
+	assert.Equal(t, 0, under.writes)
+	assert.Equal(t, int64(9), fs.(*Buffer).Buffered())
+
+	content, err := util.ReadFile(under, "foo")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+
+	require.NoError(t, f.Close())
+	assert.Equal(t, 1, under.writes)
+	assert.Equal(t, int64(0), fs.(*Buffer).Buffered())
+
+	content, err = util.ReadFile(under, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foobarbaz", string(content))
+}
+
+func TestSyncFlushes(t *testing.T) {
+	under := memfs.New()
+	fs := New(under)
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, f.Sync())
+
+	content, err := util.ReadFile(under, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+}
+
+func TestOperationsFlush(t *testing.T) {
+	fs := New(memfs.New())
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foobar"))
+	require.NoError(t, err)
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), fi.Size())
+
+	_, err = f.WriteAt([]byte("qux"), 0)
+	require.NoError(t, err)
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "quxbar", string(content))
+}
+
+func TestMaxBufferedBytes(t *testing.T) {
+	under := &countingFS{Filesystem: memfs.New()}
+	fs := New(under, WithMaxBufferedBytes(4))
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, under.writes)
+
+	// Flushes "foo" to make room for "ba".
+	_, err = f.Write([]byte("ba"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, under.writes)
+
+	// Flushes "ba", and does not fit in the buffer anyway.
+	_, err = f.Write([]byte("rbazqux"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, under.writes)
+	assert.Equal(t, int64(0), fs.(*Buffer).Buffered())
+
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(under, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foobarbazqux", string(content))
+}
+
+func TestBufferingDisabled(t *testing.T) {
+	under := &countingFS{Filesystem: memfs.New()}
+	fs := New(under, WithMaxBufferedBytes(0))
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, under.writes)
+}
+
+func TestReadOnlyNotWrapped(t *testing.T) {
+	under := memfs.New()
+	require.NoError(t, util.WriteFile(under, "foo", []byte("foo"), 0o644))
+
+	f, err := New(under).Open("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, ok := f.(*file)
+	assert.False(t, ok)
+}
+
+func TestChrootSharesLimit(t *testing.T) {
+	under := memfs.New()
+	fs := New(under, WithMaxBufferedBytes(4))
+
+	sub, err := fs.Chroot("sub")
+	require.NoError(t, err)
+
+	f1, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f1.Close()
+
+	f2, err := sub.Create("foo")
+	require.NoError(t, err)
+	defer f2.Close()
+
+	_, err = f1.Write([]byte("foo"))
+	require.NoError(t, err)
+	// The buffer is full with the data of f1, so it is written directly.
+	_, err = f2.Write([]byte("ba"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), sub.(*Buffer).Buffered())
+
+	content, err := util.ReadFile(under, "sub/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "ba", string(content))
+}
+
+func TestCapabilities(t *testing.T) {
+	fs := New(memfs.New())
+	assert.Equal(t, billy.Capabilities(memfs.New())&^billy.RemoveAllCapability, billy.Capabilities(fs))
+}
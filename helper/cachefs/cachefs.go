@@ -0,0 +1,580 @@
+// Package cachefs provides a billy filesystem wrapper which caches the
+// contents of the files read from the underlying filesystem in memory, so
+// files read repeatedly are only fetched once from slow backends.
+package cachefs
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+const (
+	// DefaultMaxSize is the maximum number of bytes cached at once, unless
+	// set with WithMaxSize.
+	DefaultMaxSize = 64 << 20
+	// DefaultMaxFileSize is the size of the largest file cached, unless set
+	// with WithMaxFileSize.
+	DefaultMaxFileSize = 8 << 20
+)
+
+// Option configures a Cache filesystem.
+type Option func(*cache)
+
+// WithMaxSize limits the number of bytes cached at once. Once reached, the
+// least recently used files are evicted from the cache.
+func WithMaxSize(n int64) Option {
+	return func(c *cache) {
+		c.maxSize = n
+	}
+}
+
+// WithMaxFileSize sets the size of the largest file cached. Larger files are
+// read directly from the underlying filesystem.
+func WithMaxFileSize(n int64) Option {
+	return func(c *cache) {
+		c.maxFileSize = n
+	}
+}
+
+// Cache is a helper that caches the contents of the files read through it.
+//
+// Files opened read-only are read completely from the underlying filesystem
+// the first time, and served from memory afterwards. Writing, truncating,
+// renaming, removing or changing the metadata of a file through the Cache
+// evicts it from the cache, but changes made to the underlying filesystem by
+// other means are not seen until the file is evicted. Files are cached under
+// the path they resolve to once symlinks are evaluated, so that changing a
+// file through a symlink evicts it too.
+type Cache struct {
+	billy.Filesystem
+	c    *cache
+	base string
+}
+
+// New creates a new filesystem wrapping up fs, which caches the contents of
+// the files read through it.
+func New(fs billy.Filesystem, opts ...Option) billy.Filesystem {
+	c := &cache{
+		maxSize:     DefaultMaxSize,
+		maxFileSize: DefaultMaxFileSize,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		fills:       make(map[string]*struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return &Cache{Filesystem: fs, c: c}
+}
+
+// Layers implements billy.Composite.
+func (h *Cache) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+// String describes the cache along with the dir it was chrooted to, if any.
+func (h *Cache) String() string {
+	if h.base == "" {
+		return "cachefs"
+	}
+
+	return "cachefs(" + h.base + ")"
+}
+
+// Size returns the number of bytes currently cached.
+func (h *Cache) Size() int64 {
+	h.c.mu.Lock()
+	defer h.c.mu.Unlock()
+
+	return h.c.size
+}
+
+func (h *Cache) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Cache) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (h *Cache) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	key := h.followKey(filename)
+	if openflag.Writable(flag) || openflag.Truncate(flag) {
+		h.c.remove(key)
+
+		f, err := h.Filesystem.OpenFile(filename, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		return &file{File: wrappedfile.Wrap(f), c: h.c, key: key}, nil
+	}
+
+	if e, ok := h.c.get(key); ok {
+		return newCachedFile(e, h.name(filename), filename), nil
+	}
+
+	// The fill is registered before opening the file, so that the changes
+	// made to it from then on cancel it.
+	fill := h.c.begin(key)
+	f, err := h.Filesystem.OpenFile(filename, flag, perm)
+	if err != nil {
+		h.c.end(key, fill)
+		return nil, err
+	}
+
+	return h.load(f, key, fill, filename)
+}
+
+// load reads f into the cache, if it fits. Otherwise f is returned as is.
+func (h *Cache) load(f billy.File, key string, fill *struct{}, filename string) (billy.File, error) {
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > h.c.maxFileSize {
+		h.c.end(key, fill)
+		return f, nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		h.c.end(key, fill)
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		h.c.end(key, fill)
+		return nil, err
+	}
+
+	e := &entry{key: key, info: fi, data: data}
+	h.c.add(e, fill)
+
+	return newCachedFile(e, h.name(filename), filename), nil
+}
+
+// The keys of the renamed and removed paths are computed before the
+// operation, as they can not be resolved afterwards.
+
+func (h *Cache) Rename(from, to string) error {
+	defer h.c.removeTree(h.linkKey(from))
+	defer h.c.removeTree(h.linkKey(to))
+
+	return h.Filesystem.Rename(from, to)
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (h *Cache) RenameNoReplace(from, to string) error {
+	defer h.c.removeTree(h.linkKey(from))
+	defer h.c.removeTree(h.linkKey(to))
+
+	return util.RenameNoReplace(h.Filesystem, from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (h *Cache) RenameExchange(from, to string) error {
+	defer h.c.removeTree(h.linkKey(from))
+	defer h.c.removeTree(h.linkKey(to))
+
+	return util.RenameExchange(h.Filesystem, from, to)
+}
+
+func (h *Cache) Remove(filename string) error {
+	defer h.c.remove(h.linkKey(filename))
+
+	return h.Filesystem.Remove(filename)
+}
+
+func (h *Cache) Symlink(target, link string) error {
+	defer h.c.remove(h.linkKey(link))
+
+	return h.Filesystem.Symlink(target, link)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Cache) Chmod(name string, mode fs.FileMode) error {
+	defer h.c.remove(h.followKey(name))
+
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Cache) Lchown(name string, uid, gid int) error {
+	defer h.c.remove(h.linkKey(name))
+
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Cache) Chown(name string, uid, gid int) error {
+	defer h.c.remove(h.followKey(name))
+
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Cache) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	defer h.c.remove(h.followKey(name))
+
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+func (h *Cache) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{Filesystem: fs, c: h.c, base: h.key(path)}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Cache) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Cache) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Cache) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (h *Cache) ReadDirNames(path string, n int) ([]string, error) {
+	return util.ReadDirNames(h.Filesystem, path, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (h *Cache) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	return util.ReadDirEntries(h.Filesystem, path)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (h *Cache) OpenDir(path string) (billy.DirIter, error) {
+	return util.OpenDir(h.Filesystem, path)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (h *Cache) SyncDir(path string) error {
+	return util.SyncDir(h.Filesystem, path)
+}
+
+// name returns the name of the file opened as path, as defined by
+// billy.File.
+func (h *Cache) name(path string) string {
+	path = filepath.Join(string(filepath.Separator), path)
+	if path == string(filepath.Separator) {
+		return "."
+	}
+
+	return strings.TrimPrefix(path, string(filepath.Separator))
+}
+
+// followKey returns the cache key of the file path resolves to, once every
+// symlink is evaluated. Paths which cannot be resolved, such as the ones of
+// files not created yet, are resolved as linkKey does.
+func (h *Cache) followKey(path string) string {
+	if resolved, err := util.EvalSymlinks(h.Filesystem, path); err == nil {
+		return h.key(resolved)
+	}
+
+	return h.linkKey(path)
+}
+
+// linkKey returns the cache key of path once the symlinks of its parent dirs
+// are evaluated, leaving its last element as is, as the operations on
+// symlinks themselves do.
+func (h *Cache) linkKey(path string) string {
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir != "" {
+		if resolved, err := util.EvalSymlinks(h.Filesystem, dir); err == nil {
+			dir = resolved
+		}
+	}
+
+	return h.key(filepath.Join(dir, name))
+}
+
+// key returns the cache key of path, which is relative to the root of the
+// Cache returned by New, so the filesystems returned by Chroot share it.
+func (h *Cache) key(path string) string {
+	path = filepath.Join(string(filepath.Separator), h.base, path)
+	return strings.TrimPrefix(path, string(filepath.Separator))
+}
+
+// cache is shared by a Cache and all the filesystems returned by its Chroot
+// method.
+type cache struct {
+	maxSize     int64
+	maxFileSize int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+	// fills holds the fills in progress by key, which are cancelled by the
+	// evictions of their key, so that contents read before a change are not
+	// added afterwards.
+	fills map[string]*struct{}
+}
+
+type entry struct {
+	key  string
+	info fs.FileInfo
+	data []byte
+}
+
+func (c *cache) get(key string) (*entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return el.Value.(*entry), true
+}
+
+// begin registers a fill of key, returning the token to add its entry with.
+func (c *cache) begin(key string) *struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fill := new(struct{})
+	c.fills[key] = fill
+	return fill
+}
+
+// end unregisters a fill of key which is not added to the cache.
+func (c *cache) end(key string, fill *struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fills[key] == fill {
+		delete(c.fills, key)
+	}
+}
+
+// add adds e to the cache, unless its fill was cancelled by an eviction, or
+// overtaken by another fill of the same key.
+func (c *cache) add(e *entry, fill *struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fills[e.key] != fill {
+		return
+	}
+	delete(c.fills, e.key)
+
+	c.removeLocked(e.key)
+	if int64(len(e.data)) > c.maxSize {
+		return
+	}
+
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += int64(len(e.data))
+
+	for c.size > c.maxSize {
+		c.removeLocked(c.lru.Back().Value.(*entry).key)
+	}
+}
+
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(key)
+}
+
+// removeTree removes key and every entry under it.
+func (c *cache) removeTree(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := key + string(filepath.Separator)
+	for k := range c.entries {
+		if k == key || key == "" || strings.HasPrefix(k, prefix) {
+			c.removeLocked(k)
+		}
+	}
+	for k := range c.fills {
+		if k == key || key == "" || strings.HasPrefix(k, prefix) {
+			delete(c.fills, k)
+		}
+	}
+}
+
+func (c *cache) removeLocked(key string) {
+	delete(c.fills, key)
+
+	el, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	c.lru.Remove(el)
+	delete(c.entries, key)
+	c.size -= int64(len(el.Value.(*entry).data))
+}
+
+// file is a file opened for writing, which evicts its cache entry on every
+// change, cancelling the fills in progress, so the entry can not be filled
+// with outdated contents.
+type file struct {
+	*wrappedfile.File
+	c   *cache
+	key string
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	defer f.c.remove(f.key)
+	return f.File.Write(p)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	defer f.c.remove(f.key)
+	return f.File.WriteAt(p, off)
+}
+
+func (f *file) Truncate(size int64) error {
+	defer f.c.remove(f.key)
+	return f.File.Truncate(size)
+}
+
+func (f *file) Close() error {
+	defer f.c.remove(f.key)
+	return f.File.Close()
+}
+
+// Chmod implements the billy.FileChange interface, if the wrapped file does.
+func (f *file) Chmod(mode fs.FileMode) error {
+	defer f.c.remove(f.key)
+	return f.File.Chmod(mode)
+}
+
+// Chtimes implements the billy.FileChange interface, if the wrapped file
+// does.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	defer f.c.remove(f.key)
+	return f.File.Chtimes(atime, mtime)
+}
+
+// RawFile implements the billy.RawFile interface. It always fails with an
+// error wrapping billy.ErrNotSupported, as the writes made on the *os.File
+// could not evict the cache entry.
+func (f *file) RawFile() (*os.File, error) {
+	return nil, &os.PathError{Op: "rawfile", Path: f.Name(), Err: billy.ErrNotSupported}
+}
+
+// cachedFile is a read-only file served from a cache entry.
+type cachedFile struct {
+	*bytes.Reader
+	e          *entry
+	name       string
+	openedPath string
+	closed     bool
+}
+
+func newCachedFile(e *entry, name, openedPath string) *cachedFile {
+	return &cachedFile{
+		Reader:     bytes.NewReader(e.data),
+		e:          e,
+		name:       name,
+		openedPath: openedPath,
+	}
+}
+
+func (f *cachedFile) Name() string {
+	return f.name
+}
+
+func (f *cachedFile) OpenedPath() string {
+	return f.openedPath
+}
+
+func (f *cachedFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, f.pathError("read", os.ErrClosed)
+	}
+
+	return f.Reader.Read(p)
+}
+
+func (f *cachedFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.pathError("read", os.ErrClosed)
+	}
+
+	return f.Reader.ReadAt(p, off)
+}
+
+func (f *cachedFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, f.pathError("seek", os.ErrClosed)
+	}
+
+	return f.Reader.Seek(offset, whence)
+}
+
+func (f *cachedFile) Write(_ []byte) (int, error) {
+	return 0, f.pathError("write", errno.EBADF)
+}
+
+func (f *cachedFile) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, f.pathError("write", errno.EBADF)
+}
+
+func (f *cachedFile) Truncate(_ int64) error {
+	return f.pathError("truncate", errno.EBADF)
+}
+
+func (f *cachedFile) Stat() (fs.FileInfo, error) {
+	return f.e.info, nil
+}
+
+// Lock is a no-op, as the contents of the file can not change.
+func (f *cachedFile) Lock() error {
+	return nil
+}
+
+// Unlock is a no-op, as the contents of the file can not change.
+func (f *cachedFile) Unlock() error {
+	return nil
+}
+
+func (f *cachedFile) Sync() error {
+	return nil
+}
+
+func (f *cachedFile) Close() error {
+	if f.closed {
+		return f.pathError("close", os.ErrClosed)
+	}
+
+	f.closed = true
+	return nil
+}
+
+func (f *cachedFile) pathError(op string, err error) error {
+	return &os.PathError{Op: op, Path: f.name, Err: err}
+}
@@ -0,0 +1,290 @@
+package cachefs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// countingFS counts the files opened through it, calling onOpen, if set,
+// after opening them.
+type countingFS struct {
+	billy.Filesystem
+	opens  int
+	onOpen func()
+}
+
+func (c *countingFS) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	c.opens++
+	f, err := c.Filesystem.OpenFile(filename, flag, perm)
+	if c.onOpen != nil {
+		c.onOpen()
+	}
+
+	return f, err
+}
+
+func newTestFS(t *testing.T, opts ...Option) (*countingFS, billy.Filesystem) {
+	t.Helper()
+
+	under := &countingFS{Filesystem: memfs.New()}
+	require.NoError(t, util.WriteFile(under, "foo", []byte("foo"), 0o644))
+	under.opens = 0
+
+	return under, New(under, opts...)
+}
+
+func TestOpenCached(t *testing.T) {
+	under, fs := newTestFS(t)
+
+	for i := 0; i < 3; i++ {
+		content, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+	}
+
+	assert.Equal(t, 1, under.opens)
+	assert.Equal(t, int64(3), fs.(*Cache).Size())
+}
+
+func TestCachedFile(t *testing.T) {
+	_, fs := newTestFS(t)
+	_, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+
+	f, err := fs.Open("/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", f.Name())
+	assert.Equal(t, "/foo", f.OpenedPath())
+
+	b := make([]byte, 2)
+	n, err := f.ReadAt(b, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "oo", string(b[:n]))
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+
+	_, err = f.Write([]byte("bar"))
+	assert.Error(t, err)
+
+	require.NoError(t, f.Close())
+	_, err = f.Read(b)
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestWriteInvalidates(t *testing.T) {
+	_, fs := newTestFS(t)
+	_, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+
+	f, err := fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+
+	_, err = util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("bar"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foobar", string(content))
+}
+
+func TestRenameAndRemoveInvalidate(t *testing.T) {
+	_, fs := newTestFS(t)
+	require.NoError(t, util.WriteFile(fs, "dir/bar", []byte("bar"), 0o644))
+
+	_, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	_, err = util.ReadFile(fs, "dir/bar")
+	require.NoError(t, err)
+
+	require.NoError(t, fs.Rename("dir", "moved"))
+	_, err = fs.Open("dir/bar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, fs.Remove("foo"))
+	_, err = fs.Open("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Equal(t, int64(0), fs.(*Cache).Size())
+}
+
+func TestRenameExchangeInvalidates(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+
+	_, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	_, err = util.ReadFile(fs, "bar")
+	require.NoError(t, err)
+
+	require.NoError(t, fs.(billy.Renamer).RenameExchange("foo", "bar"))
+	data, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+
+	err = fs.(billy.Renamer).RenameNoReplace("foo", "bar")
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	require.NoError(t, fs.(billy.Renamer).RenameNoReplace("foo", "qux"))
+	_, err = fs.Open("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestChangeInvalidates(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("foo", "link"))
+
+	for _, name := range []string{"foo", "link"} {
+		_, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, util.Chtimes(fs, name, mtime, mtime), name)
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+		fi, err := f.Stat()
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()), name)
+		require.NoError(t, f.Close())
+
+		require.NoError(t, util.Chtimes(fs, "foo", time.Now(), time.Now()))
+	}
+}
+
+func TestEviction(t *testing.T) {
+	under, fs := newTestFS(t, WithMaxSize(6))
+	require.NoError(t, util.WriteFile(under, "bar", []byte("bar"), 0o644))
+	require.NoError(t, util.WriteFile(under, "baz", []byte("baz"), 0o644))
+	under.opens = 0
+
+	for _, name := range []string{"foo", "bar", "foo", "baz", "foo"} {
+		_, err := util.ReadFile(fs, name)
+		require.NoError(t, err)
+	}
+
+	// bar was the least recently used file when baz was loaded.
+	assert.Equal(t, 3, under.opens)
+	assert.Equal(t, int64(6), fs.(*Cache).Size())
+
+	_, err := util.ReadFile(fs, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, 4, under.opens)
+}
+
+func TestMaxFileSize(t *testing.T) {
+	under, fs := newTestFS(t, WithMaxFileSize(2))
+
+	for i := 0; i < 2; i++ {
+		_, err := util.ReadFile(fs, "foo")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, under.opens)
+	assert.Equal(t, int64(0), fs.(*Cache).Size())
+}
+
+func TestSymlinkCachedUnderTarget(t *testing.T) {
+	under, fs := newTestFS(t)
+	require.NoError(t, fs.Symlink("foo", "link"))
+
+	for _, name := range []string{"link", "link", "foo"} {
+		content, err := util.ReadFile(fs, name)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+	}
+
+	assert.Equal(t, 1, under.opens)
+}
+
+func TestWriteThroughSymlinkInvalidates(t *testing.T) {
+	_, fs := newTestFS(t)
+	_, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+
+	require.NoError(t, fs.Symlink("foo", "link"))
+	f, err := fs.OpenFile("link", os.O_WRONLY|os.O_TRUNC, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+}
+
+func TestWriteThroughSymlinkedDirInvalidates(t *testing.T) {
+	fs := New(osfs.New(t.TempDir()))
+	require.NoError(t, util.WriteFile(fs, "dir/bar", []byte("bar"), 0o644))
+	require.NoError(t, fs.Symlink("dir", "link"))
+
+	_, err := util.ReadFile(fs, "dir/bar")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(fs, "link/bar", []byte("qux"), 0o644))
+
+	content, err := util.ReadFile(fs, "dir/bar")
+	require.NoError(t, err)
+	assert.Equal(t, "qux", string(content))
+
+	require.NoError(t, fs.Remove("link/bar"))
+	_, err = util.ReadFile(fs, "dir/bar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOvertakenFillDropped(t *testing.T) {
+	under, fs := newTestFS(t)
+	under.onOpen = func() {
+		under.onOpen = nil
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("new"), 0o644))
+	}
+
+	_, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), fs.(*Cache).Size())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+	assert.Equal(t, 3, under.opens)
+	assert.Equal(t, int64(3), fs.(*Cache).Size())
+}
+
+func TestChrootSharesCache(t *testing.T) {
+	_, fs := newTestFS(t)
+	require.NoError(t, util.WriteFile(fs, "dir/bar", []byte("bar"), 0o644))
+
+	sub, err := fs.Chroot("dir")
+	require.NoError(t, err)
+
+	f, err := sub.Open("bar")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", f.Name())
+	require.NoError(t, f.Close())
+
+	require.NoError(t, util.WriteFile(fs, "dir/bar", []byte("qux"), 0o644))
+
+	f, err = sub.Open("bar")
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "qux", string(content))
+	require.NoError(t, f.Close())
+}
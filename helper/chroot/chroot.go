@@ -1,48 +1,138 @@
 package chroot
 
 import (
+	"crypto"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/helper/polyfill"
+	"github.com/go-git/go-billy/v6/internal/pathutil"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 // ChrootHelper is a helper to implement billy.Chroot.
 type ChrootHelper struct { //nolint
 	underlying billy.Filesystem
 	base       string
+	opts       []Option
+	// slash is set if the underlying filesystem separates paths with a
+	// forward slash, while the OS does not.
+	slash bool
+
+	boundaryErr error
+	mapper      billy.PathMapper
+	limits      billy.NameLimits
+}
+
+// Option configures a ChrootHelper.
+type Option func(*ChrootHelper)
+
+// WithBoundaryError sets the error returned when a path crosses the chroot
+// boundary, as the Err of a billy.BoundaryError. It defaults to
+// billy.ErrCrossedBoundary. Custom errors should wrap
+// billy.ErrCrossedBoundary, so that callers checking for it with errors.Is
+// keep working.
+func WithBoundaryError(err error) Option {
+	return func(h *ChrootHelper) {
+		h.boundaryErr = err
+	}
+}
+
+// WithPathMapper maps the paths given to the filesystem with m before they
+// are resolved within the chroot, including the paths given to the chroots
+// created from it.
+func WithPathMapper(m billy.PathMapper) Option {
+	return func(h *ChrootHelper) {
+		h.mapper = m
+	}
+}
+
+// WithNameLimits makes the filesystem reject the paths exceeding l, relative
+// to the root of the chroot, with a *billy.NameError. The chroots created
+// from it apply them too, relative to their own root.
+func WithNameLimits(l billy.NameLimits) Option {
+	return func(h *ChrootHelper) {
+		h.limits = l
+	}
 }
 
 // New creates a new filesystem wrapping up the given 'fs'.
 // The created filesystem has its base in the given ChrootHelperectory of the
 // underlying filesystem.
-func New(fs billy.Basic, base string) billy.Filesystem {
-	return &ChrootHelper{
-		underlying: polyfill.New(fs),
-		base:       base,
+func New(fs billy.Basic, base string, opts ...Option) billy.Filesystem {
+	h := &ChrootHelper{
+		underlying:  polyfill.New(fs),
+		base:        base,
+		opts:        opts,
+		boundaryErr: billy.ErrCrossedBoundary,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+
+	h.slash = os.PathSeparator != '/' && billy.Introspect(h.underlying).Separator == '/'
+	return h
 }
 
-func (fs *ChrootHelper) underlyingPath(filename string) (string, error) {
-	if isCrossBoundaries(filename) {
-		return "", billy.ErrCrossedBoundary
+// underlyingPath returns the path of filename in the underlying filesystem.
+// If filename is outside of the chroot, the boundary error is returned in a
+// *billy.BoundaryError, wrapped in an *os.PathError for op, as is the
+// *billy.NameError of the filenames exceeding the name limits.
+func (fs *ChrootHelper) underlyingPath(op, filename string) (string, error) {
+	path := pathutil.Map(fs.mapper, filename)
+	if isCrossBoundaries(path) {
+		err := &billy.BoundaryError{Base: fs.Root(), Path: filename, Err: fs.boundaryErr}
+		return "", &os.PathError{Op: op, Path: filename, Err: err}
+	}
+
+	if err := pathutil.CheckLimits(fs.limits, path); err != nil {
+		return "", &os.PathError{Op: op, Path: filename, Err: err}
 	}
 
-	return fs.Join(fs.Root(), filename), nil
+	return fs.Join(fs.Root(), path), nil
 }
 
+// isCrossBoundaries reports whether path goes above the root of the chroot
+// at any point, walking its elements in a single pass without allocating.
+// Paths such as "foo/../.." or "../foo" cross it, even if they come back
+// into the chroot. As on the OS, ".." stays at the root of rooted paths, so
+// "/../foo" does not cross it.
 func isCrossBoundaries(path string) bool {
-	path = filepath.ToSlash(path)
-	path = filepath.Clean(path)
+	rooted := len(path) > 0 && os.IsPathSeparator(path[0])
+
+	depth := 0
+	for len(path) > 0 {
+		i := 0
+		for i < len(path) && !os.IsPathSeparator(path[i]) {
+			i++
+		}
+
+		elem := path[:i]
+		path = path[min(i+1, len(path)):]
+
+		switch elem {
+		case "", ".":
+		case "..":
+			if depth > 0 {
+				depth--
+			} else if !rooted {
+				return true
+			}
+		default:
+			depth++
+		}
+	}
 
-	return strings.HasPrefix(path, ".."+string(filepath.Separator))
+	return false
 }
 
 func (fs *ChrootHelper) Create(filename string) (billy.File, error) {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPath("open", filename)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +146,7 @@ func (fs *ChrootHelper) Create(filename string) (billy.File, error) {
 }
 
 func (fs *ChrootHelper) Open(filename string) (billy.File, error) {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPath("open", filename)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +160,7 @@ func (fs *ChrootHelper) Open(filename string) (billy.File, error) {
 }
 
 func (fs *ChrootHelper) OpenFile(filename string, flag int, mode fs.FileMode) (billy.File, error) {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPath("open", filename)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +174,7 @@ func (fs *ChrootHelper) OpenFile(filename string, flag int, mode fs.FileMode) (b
 }
 
 func (fs *ChrootHelper) Stat(filename string) (os.FileInfo, error) {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPath("stat", filename)
 	if err != nil {
 		return nil, err
 	}
@@ -92,23 +182,90 @@ func (fs *ChrootHelper) Stat(filename string) (os.FileInfo, error) {
 	return fs.underlying.Stat(fullpath)
 }
 
+// StatMany implements the billy.BatchStater interface, passing the files on
+// to the underlying filesystem in a single batch.
+func (fs *ChrootHelper) StatMany(paths []string) ([]os.FileInfo, []error) {
+	infos := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	fullpaths := make([]string, 0, len(paths))
+	indexes := make([]int, 0, len(paths))
+	for i, path := range paths {
+		fullpath, err := fs.underlyingPath("stat", path)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		fullpaths = append(fullpaths, fullpath)
+		indexes = append(indexes, i)
+	}
+
+	fis, ferrs := util.StatMany(fs.underlying, fullpaths)
+	for j, i := range indexes {
+		infos[i], errs[i] = fis[j], ferrs[j]
+	}
+
+	return infos, errs
+}
+
 func (fs *ChrootHelper) Rename(from, to string) error {
-	var err error
-	from, err = fs.underlyingPath(from)
+	from, to, err := fs.underlyingPaths(from, to)
 	if err != nil {
 		return err
 	}
 
-	to, err = fs.underlyingPath(to)
+	return fs.underlying.Rename(from, to)
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (fs *ChrootHelper) RenameNoReplace(from, to string) error {
+	from, to, err := fs.underlyingPaths(from, to)
 	if err != nil {
 		return err
 	}
 
-	return fs.underlying.Rename(from, to)
+	return util.RenameNoReplace(fs.underlying, from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (fs *ChrootHelper) RenameExchange(from, to string) error {
+	from, to, err := fs.underlyingPaths(from, to)
+	if err != nil {
+		return err
+	}
+
+	return util.RenameExchange(fs.underlying, from, to)
+}
+
+// underlyingPaths returns the underlying paths of the source and target of a
+// rename. The root of the chroot can be neither of them.
+func (fs *ChrootHelper) underlyingPaths(from, to string) (string, string, error) {
+	if pathutil.IsRoot(from) || pathutil.IsRoot(to) {
+		return "", "", &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrInvalid}
+	}
+
+	from, err := fs.underlyingPath("rename", from)
+	if err != nil {
+		return "", "", err
+	}
+
+	to, err = fs.underlyingPath("rename", to)
+	if err != nil {
+		return "", "", err
+	}
+
+	return from, to, nil
 }
 
+// Remove removes the named file or empty dir. As with os.Remove("."), the
+// root of the chroot cannot be removed.
 func (fs *ChrootHelper) Remove(path string) error {
-	fullpath, err := fs.underlyingPath(path)
+	if pathutil.IsRoot(path) {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrInvalid}
+	}
+
+	fullpath, err := fs.underlyingPath("remove", path)
 	if err != nil {
 		return err
 	}
@@ -116,26 +273,92 @@ func (fs *ChrootHelper) Remove(path string) error {
 	return fs.underlying.Remove(fullpath)
 }
 
+// RemoveAll removes path and any children it contains, using the RemoveAll
+// of the underlying filesystem when available. See util.RemoveAll. As with
+// os.RemoveAll("."), the root of the chroot cannot be removed.
+func (fs *ChrootHelper) RemoveAll(path string) error {
+	if pathutil.IsRoot(path) {
+		return &os.PathError{Op: "removeall", Path: path, Err: os.ErrInvalid}
+	}
+
+	fullpath, err := fs.underlyingPath("removeall", path)
+	if err != nil {
+		return err
+	}
+
+	return util.RemoveAll(fs.underlying, fullpath)
+}
+
 func (fs *ChrootHelper) Join(elem ...string) string {
 	return fs.underlying.Join(elem...)
 }
 
+// TempFile creates a temporary file in dir, or in the root of the chroot if
+// dir is empty, so temp files never land outside of it, such as in the temp
+// dir of the OS.
 func (fs *ChrootHelper) TempFile(dir, prefix string) (billy.File, error) {
-	fullpath, err := fs.underlyingPath(dir)
+	return fs.createTemp("tempfile", dir, func(tf billy.TempFile, fullpath string) (billy.File, error) {
+		return tf.TempFile(fullpath, prefix)
+	})
+}
+
+// CreateTemp creates a temporary file in dir, or in the root of the chroot
+// if dir is empty, like TempFile.
+func (fs *ChrootHelper) CreateTemp(dir, pattern string) (billy.File, error) {
+	return fs.createTemp("createtemp", dir, func(tf billy.TempFile, fullpath string) (billy.File, error) {
+		return tf.CreateTemp(fullpath, pattern)
+	})
+}
+
+func (fs *ChrootHelper) createTemp(op, dir string, create func(billy.TempFile, string) (billy.File, error)) (billy.File, error) {
+	fullpath, err := fs.underlyingPath(op, dir)
 	if err != nil {
 		return nil, err
 	}
 
-	f, err := fs.underlying.(billy.TempFile).TempFile(fullpath, prefix)
+	f, err := create(fs.underlying.(billy.TempFile), fullpath)
 	if err != nil {
 		return nil, err
 	}
 
-	return newFile(fs, f, fs.Join(dir, filepath.Base(f.Name()))), nil
+	// Temp files have no path presented by the caller, so both names are
+	// the path relative to the root.
+	name := fs.fileName(fs.Join(dir, filepath.Base(f.Name())))
+	return wrappedfile.New(f, name, name), nil
+}
+
+// TempDir creates a temporary directory in dir, or in the root of the chroot
+// if dir is empty, like TempFile.
+func (fs *ChrootHelper) TempDir(dir, prefix string) (string, error) {
+	return fs.mkdirTemp("tempdir", dir, func(tf billy.TempFile, fullpath string) (string, error) {
+		return tf.TempDir(fullpath, prefix)
+	})
+}
+
+// MkdirTemp creates a temporary directory in dir, or in the root of the
+// chroot if dir is empty, like TempFile.
+func (fs *ChrootHelper) MkdirTemp(dir, pattern string) (string, error) {
+	return fs.mkdirTemp("mkdirtemp", dir, func(tf billy.TempFile, fullpath string) (string, error) {
+		return tf.MkdirTemp(fullpath, pattern)
+	})
+}
+
+func (fs *ChrootHelper) mkdirTemp(op, dir string, mkdir func(billy.TempFile, string) (string, error)) (string, error) {
+	fullpath, err := fs.underlyingPath(op, dir)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := mkdir(fs.underlying.(billy.TempFile), fullpath)
+	if err != nil {
+		return "", err
+	}
+
+	return fs.Join(dir, filepath.Base(name)), nil
 }
 
 func (fs *ChrootHelper) ReadDir(path string) ([]os.FileInfo, error) {
-	fullpath, err := fs.underlyingPath(path)
+	fullpath, err := fs.underlyingPath("readdir", path)
 	if err != nil {
 		return nil, err
 	}
@@ -143,8 +366,83 @@ func (fs *ChrootHelper) ReadDir(path string) ([]os.FileInfo, error) {
 	return fs.underlying.(billy.Dir).ReadDir(fullpath)
 }
 
+// ReadDirNames implements the billy.DirNames interface.
+func (fs *ChrootHelper) ReadDirNames(path string, n int) ([]string, error) {
+	fullpath, err := fs.underlyingPath("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.ReadDirNames(fs.underlying, fullpath, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (fs *ChrootHelper) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	fullpath, err := fs.underlyingPath("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.ReadDirEntries(fs.underlying, fullpath)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (fs *ChrootHelper) SyncDir(path string) error {
+	fullpath, err := fs.underlyingPath("sync", path)
+	if err != nil {
+		return err
+	}
+
+	return util.SyncDir(fs.underlying, fullpath)
+}
+
+// HashFile implements the billy.Hasher interface.
+func (fs *ChrootHelper) HashFile(path string, h crypto.Hash) ([]byte, error) {
+	fullpath, err := fs.underlyingPath("hash", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.HashFile(fs.underlying, fullpath, h)
+}
+
+// Mmap implements the billy.Mapper interface.
+func (fs *ChrootHelper) Mmap(path string) (billy.Mapping, error) {
+	fullpath, err := fs.underlyingPath("mmap", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.Mmap(fs.underlying, fullpath)
+}
+
+// CopyFile implements the billy.Copier interface.
+func (fs *ChrootHelper) CopyFile(src, dst string) error {
+	srcPath, err := fs.underlyingPath("copy", src)
+	if err != nil {
+		return err
+	}
+
+	dstPath, err := fs.underlyingPath("copy", dst)
+	if err != nil {
+		return err
+	}
+
+	return util.CopyFile(fs.underlying, srcPath, dstPath)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (fs *ChrootHelper) OpenDir(path string) (billy.DirIter, error) {
+	fullpath, err := fs.underlyingPath("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.OpenDir(fs.underlying, fullpath)
+}
+
 func (fs *ChrootHelper) MkdirAll(filename string, perm fs.FileMode) error {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPath("mkdir", filename)
 	if err != nil {
 		return err
 	}
@@ -153,7 +451,7 @@ func (fs *ChrootHelper) MkdirAll(filename string, perm fs.FileMode) error {
 }
 
 func (fs *ChrootHelper) Lstat(filename string) (os.FileInfo, error) {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPath("lstat", filename)
 	if err != nil {
 		return nil, err
 	}
@@ -162,15 +460,19 @@ func (fs *ChrootHelper) Lstat(filename string) (os.FileInfo, error) {
 }
 
 func (fs *ChrootHelper) Symlink(target, link string) error {
-	target = filepath.FromSlash(target)
+	if !fs.slash {
+		target = filepath.FromSlash(target)
+	}
 
 	// only rewrite target if it's already absolute
-	if filepath.IsAbs(target) || strings.HasPrefix(target, string(filepath.Separator)) {
+	if fs.isAbs(target) {
 		target = fs.Join(fs.Root(), target)
-		target = filepath.Clean(filepath.FromSlash(target))
+		if !fs.slash {
+			target = filepath.Clean(filepath.FromSlash(target))
+		}
 	}
 
-	link, err := fs.underlyingPath(link)
+	link, err := fs.underlyingPath("symlink", link)
 	if err != nil {
 		return err
 	}
@@ -179,7 +481,7 @@ func (fs *ChrootHelper) Symlink(target, link string) error {
 }
 
 func (fs *ChrootHelper) Readlink(link string) (string, error) {
-	fullpath, err := fs.underlyingPath(link)
+	fullpath, err := fs.underlyingPath("readlink", link)
 	if err != nil {
 		return "", err
 	}
@@ -189,25 +491,187 @@ func (fs *ChrootHelper) Readlink(link string) (string, error) {
 		return "", err
 	}
 
-	if !filepath.IsAbs(target) && !strings.HasPrefix(target, string(filepath.Separator)) {
+	if !fs.isAbs(target) {
 		return target, nil
 	}
 
-	target, err = filepath.Rel(fs.base, target)
+	target, err = fs.rel(fs.base, target)
 	if err != nil {
 		return "", err
 	}
 
+	if fs.slash {
+		return "/" + target, nil
+	}
+
 	return string(os.PathSeparator) + target, nil
 }
 
+// isAbs reports whether path is absolute in the underlying filesystem. Paths
+// starting with the separator are, even on Windows, where filepath.IsAbs also
+// requires a volume name.
+func (fs *ChrootHelper) isAbs(path string) bool {
+	if fs.slash {
+		return strings.HasPrefix(path, "/")
+	}
+
+	return filepath.IsAbs(path) || strings.HasPrefix(path, string(filepath.Separator))
+}
+
+// rel returns target relative to base, as filepath.Rel does, using the
+// separator of the underlying filesystem.
+func (fs *ChrootHelper) rel(base, target string) (string, error) {
+	rel, err := filepath.Rel(base, target)
+	if fs.slash {
+		rel = filepath.ToSlash(rel)
+	}
+
+	return rel, err
+}
+
+// GetXattr implements the billy.Xattr interface.
+func (fs *ChrootHelper) GetXattr(path, name string) ([]byte, error) {
+	fullpath, err := fs.underlyingPath("getxattr", path)
+	if err != nil {
+		return nil, err
+	}
+
+	x, ok := fs.underlying.(billy.Xattr)
+	if !ok {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: billy.ErrNotSupported}
+	}
+
+	return x.GetXattr(fullpath, name)
+}
+
+// SetXattr implements the billy.Xattr interface.
+func (fs *ChrootHelper) SetXattr(path, name string, value []byte) error {
+	fullpath, err := fs.underlyingPath("setxattr", path)
+	if err != nil {
+		return err
+	}
+
+	x, ok := fs.underlying.(billy.Xattr)
+	if !ok {
+		return &os.PathError{Op: "setxattr", Path: path, Err: billy.ErrNotSupported}
+	}
+
+	return x.SetXattr(fullpath, name, value)
+}
+
+// ListXattr implements the billy.Xattr interface.
+func (fs *ChrootHelper) ListXattr(path string) ([]string, error) {
+	fullpath, err := fs.underlyingPath("listxattr", path)
+	if err != nil {
+		return nil, err
+	}
+
+	x, ok := fs.underlying.(billy.Xattr)
+	if !ok {
+		return nil, &os.PathError{Op: "listxattr", Path: path, Err: billy.ErrNotSupported}
+	}
+
+	return x.ListXattr(fullpath)
+}
+
+// RemoveXattr implements the billy.Xattr interface.
+func (fs *ChrootHelper) RemoveXattr(path, name string) error {
+	fullpath, err := fs.underlyingPath("removexattr", path)
+	if err != nil {
+		return err
+	}
+
+	x, ok := fs.underlying.(billy.Xattr)
+	if !ok {
+		return &os.PathError{Op: "removexattr", Path: path, Err: billy.ErrNotSupported}
+	}
+
+	return x.RemoveXattr(fullpath, name)
+}
+
+// Chmod implements the billy.Change interface.
+func (fs *ChrootHelper) Chmod(name string, mode fs.FileMode) error {
+	c, fullpath, err := fs.change("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	return c.Chmod(fullpath, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (fs *ChrootHelper) Lchown(name string, uid, gid int) error {
+	c, fullpath, err := fs.change("lchown", name)
+	if err != nil {
+		return err
+	}
+
+	return c.Lchown(fullpath, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (fs *ChrootHelper) Chown(name string, uid, gid int) error {
+	c, fullpath, err := fs.change("chown", name)
+	if err != nil {
+		return err
+	}
+
+	return c.Chown(fullpath, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (fs *ChrootHelper) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	c, fullpath, err := fs.change("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return c.Chtimes(fullpath, atime, mtime)
+}
+
+// Lchmod implements the billy.SymlinkChange interface. See util.Lchmod.
+func (fs *ChrootHelper) Lchmod(name string, mode fs.FileMode) error {
+	fullpath, err := fs.underlyingPath("lchmod", name)
+	if err != nil {
+		return err
+	}
+
+	return util.Lchmod(fs.underlying, fullpath, mode)
+}
+
+// Lchtimes implements the billy.SymlinkChange interface. See util.Lchtimes.
+func (fs *ChrootHelper) Lchtimes(name string, atime time.Time, mtime time.Time) error {
+	fullpath, err := fs.underlyingPath("lchtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return util.Lchtimes(fs.underlying, fullpath, atime, mtime)
+}
+
+// change returns the underlying billy.Change along with the path of name in
+// it.
+func (fs *ChrootHelper) change(op, name string) (billy.Change, string, error) {
+	fullpath, err := fs.underlyingPath(op, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, ok := fs.underlying.(billy.Change)
+	if !ok {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: billy.ErrNotSupported}
+	}
+
+	return c, fullpath, nil
+}
+
 func (fs *ChrootHelper) Chroot(path string) (billy.Filesystem, error) {
-	fullpath, err := fs.underlyingPath(path)
+	fullpath, err := fs.underlyingPath("chroot", path)
 	if err != nil {
 		return nil, err
 	}
 
-	return New(fs.underlying, fullpath), nil
+	return New(fs.underlying, fullpath, fs.opts...), nil
 }
 
 func (fs *ChrootHelper) Root() string {
@@ -218,26 +682,42 @@ func (fs *ChrootHelper) Underlying() billy.Basic {
 	return fs.underlying
 }
 
+// Layers implements billy.Composite.
+func (fs *ChrootHelper) Layers() []billy.Basic {
+	return []billy.Basic{fs.underlying}
+}
+
+// String describes the chroot along with its base dir in the underlying
+// filesystem.
+func (fs *ChrootHelper) String() string {
+	return "chroot(" + fs.base + ")"
+}
+
 // Capabilities implements the Capable interface.
 func (fs *ChrootHelper) Capabilities() billy.Capability {
-	return billy.Capabilities(fs.underlying)
+	return billy.WrapperCapabilities(fs, billy.Capabilities(fs.underlying))
 }
 
-type file struct {
-	billy.File
-	name string
+// Supports implements the billy.FeatureReporter interface. Chroot is
+// always supported, and the other features are the ones of the underlying
+// filesystem.
+func (fs *ChrootHelper) Supports(f billy.Feature) bool {
+	return f == billy.ChrootFeature || billy.Supports(fs.underlying, f)
 }
 
-func newFile(fs billy.Filesystem, f billy.File, filename string) billy.File {
-	filename = fs.Join(fs.Root(), filename)
-	filename, _ = filepath.Rel(fs.Root(), filename)
+// PathProperties implements the Introspectable interface.
+func (fs *ChrootHelper) PathProperties() billy.PathProperties {
+	return billy.Introspect(fs.underlying)
+}
 
-	return &file{
-		File: f,
-		name: filename,
-	}
+func newFile(fs *ChrootHelper, f billy.File, filename string) billy.File {
+	return wrappedfile.New(f, fs.fileName(filename), filename)
 }
 
-func (f *file) Name() string {
-	return f.name
+// fileName returns the name of the file opened as filename, relative to the
+// root of the chroot.
+func (fs *ChrootHelper) fileName(filename string) string {
+	name := fs.Join(fs.Root(), pathutil.Map(fs.mapper, filename))
+	name, _ = fs.rel(fs.Root(), name)
+	return name
 }
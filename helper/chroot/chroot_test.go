@@ -1,14 +1,19 @@
 package chroot
 
 import (
+	"crypto"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/internal/test"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 func TestCreate(t *testing.T) {
@@ -40,6 +45,107 @@ func TestLeadingPeriodsPathNotCrossedBoundary(t *testing.T) {
 	assert.Equal(t, f.Name(), "..foo")
 }
 
+func TestWithPathMapper(t *testing.T) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo", WithPathMapper(billy.PathMapperFunc(strings.ToLower)))
+	f, err := fs.Create("Bar/QUX")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("bar", "qux"), f.Name())
+	assert.Equal(t, "Bar/QUX", f.OpenedPath())
+
+	// The chroots created from fs map the paths too.
+	sub, err := fs.Chroot("Sub")
+	require.NoError(t, err)
+	_, err = sub.Create("Baz")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/foo/bar/qux", "/foo/sub/baz"}, m.CreateArgs)
+
+	_, err = fs.Create("../Foo")
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "../Foo", perr.Path)
+}
+
+func TestWithNameLimits(t *testing.T) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo", WithNameLimits(billy.NameLimits{MaxName: 3, MaxPath: 8}))
+	_, err := fs.Create("bar/qux")
+	require.NoError(t, err)
+
+	_, err = fs.Create("bar/quux")
+	var nerr *billy.NameError
+	require.ErrorAs(t, err, &nerr)
+	assert.Equal(t, "quux", nerr.Name)
+	assert.Equal(t, 3, nerr.Max)
+	assert.ErrorIs(t, err, billy.ErrNameTooLong)
+
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "open", perr.Op)
+	assert.Equal(t, "bar/quux", perr.Path)
+
+	_, err = fs.Stat("bar/baz/qux")
+	require.ErrorAs(t, err, &nerr)
+	assert.Empty(t, nerr.Name)
+	assert.Equal(t, 8, nerr.Max)
+
+	// The chroots created from fs apply the limits relative to their root.
+	sub, err := fs.Chroot("bar")
+	require.NoError(t, err)
+	_, err = sub.Create("baz/qux")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/foo/bar/qux", "/foo/bar/baz/qux"}, m.CreateArgs)
+}
+
+func TestIsCrossBoundaries(t *testing.T) {
+	tests := []struct {
+		path  string
+		cross bool
+	}{
+		{path: "", cross: false},
+		{path: ".", cross: false},
+		{path: "foo", cross: false},
+		{path: "foo/bar", cross: false},
+		{path: "foo/..", cross: false},
+		{path: "foo/../bar", cross: false},
+		{path: "./foo/./bar/../..", cross: false},
+		{path: "..foo", cross: false},
+		{path: "foo..", cross: false},
+		{path: "foo/..bar/...", cross: false},
+		{path: "/..", cross: false},
+		{path: "/../foo", cross: false},
+		{path: "/foo/../../bar", cross: false},
+		{path: "..", cross: true},
+		{path: "../", cross: true},
+		{path: "../foo", cross: true},
+		{path: "./..", cross: true},
+		{path: "foo/../..", cross: true},
+		{path: "foo/../../bar", cross: true},
+		{path: "foo//..//..//bar", cross: true},
+		{path: "foo/bar/../../../foo/bar", cross: true},
+	}
+
+	for _, tt := range tests {
+		path := filepath.FromSlash(tt.path)
+		assert.Equal(t, tt.cross, isCrossBoundaries(path), "path %q", path)
+	}
+}
+
+func TestCrossedBoundaryMidPath(t *testing.T) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo")
+	for _, path := range []string{"..", "bar/../..", "bar/../../foo/qux"} {
+		_, err := fs.Open(path)
+		assert.ErrorIs(t, err, billy.ErrCrossedBoundary, "path %q", path)
+	}
+	assert.Empty(t, m.OpenArgs)
+}
+
 func TestOpen(t *testing.T) {
 	m := &test.BasicMock{}
 
@@ -141,6 +247,200 @@ func TestRenameErrCrossedBoundary(t *testing.T) {
 	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
 }
 
+type renamerMock struct {
+	test.BasicMock
+	exchangeArgs [][2]string
+}
+
+func (m *renamerMock) RenameNoReplace(from, to string) error {
+	return m.Rename(from, to)
+}
+
+func (m *renamerMock) RenameExchange(from, to string) error {
+	m.exchangeArgs = append(m.exchangeArgs, [2]string{from, to})
+	return nil
+}
+
+func TestRenamer(t *testing.T) {
+	m := &renamerMock{}
+
+	fs := New(m, "/foo").(billy.Renamer)
+	require.NoError(t, fs.RenameNoReplace("bar/qux", "qux/bar"))
+	assert.Equal(t, [][2]string{{"/foo/bar/qux", "/foo/qux/bar"}}, m.RenameArgs)
+
+	require.NoError(t, fs.RenameExchange("bar", "qux"))
+	assert.Equal(t, [][2]string{{"/foo/bar", "/foo/qux"}}, m.exchangeArgs)
+
+	err := fs.RenameNoReplace("bar", "../qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+	err = fs.RenameExchange("../bar", "qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+	err = New(&test.BasicMock{}, "/foo").(billy.Renamer).RenameExchange("bar", "qux")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+type changeMock struct {
+	test.BasicMock
+	changeArgs []string
+}
+
+func (m *changeMock) Chmod(name string, _ os.FileMode) error {
+	m.changeArgs = append(m.changeArgs, "chmod "+name)
+	return nil
+}
+
+func (m *changeMock) Lchown(name string, _, _ int) error {
+	m.changeArgs = append(m.changeArgs, "lchown "+name)
+	return nil
+}
+
+func (m *changeMock) Chown(name string, _, _ int) error {
+	m.changeArgs = append(m.changeArgs, "chown "+name)
+	return nil
+}
+
+func (m *changeMock) Chtimes(name string, _, _ time.Time) error {
+	m.changeArgs = append(m.changeArgs, "chtimes "+name)
+	return nil
+}
+
+func TestChange(t *testing.T) {
+	m := &changeMock{}
+
+	c := New(m, "/foo").(billy.Change)
+	require.NoError(t, c.Chmod("bar", 0o644))
+	require.NoError(t, c.Lchown("bar/qux", 0, 0))
+	require.NoError(t, c.Chown("qux", 0, 0))
+	require.NoError(t, c.Chtimes("qux/bar", time.Time{}, time.Time{}))
+	assert.Equal(t, []string{
+		"chmod /foo/bar",
+		"lchown /foo/bar/qux",
+		"chown /foo/qux",
+		"chtimes /foo/qux/bar",
+	}, m.changeArgs)
+
+	assert.ErrorIs(t, c.Chown("../bar", 0, 0), billy.ErrCrossedBoundary)
+
+	c = New(&test.BasicMock{}, "/foo").(billy.Change)
+	assert.ErrorIs(t, c.Chmod("bar", 0o644), billy.ErrNotSupported)
+}
+
+type removeAllMock struct {
+	test.BasicMock
+	removeAllArgs []string
+}
+
+func (m *removeAllMock) RemoveAll(path string) error {
+	m.removeAllArgs = append(m.removeAllArgs, path)
+	return nil
+}
+
+func TestRemoveAll(t *testing.T) {
+	m := &removeAllMock{}
+
+	fs := New(m, "/foo")
+	require.NoError(t, util.RemoveAll(fs, "bar/qux"))
+	assert.Equal(t, []string{"/foo/bar/qux"}, m.removeAllArgs)
+
+	err := util.RemoveAll(fs, "../foo")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+type hasherMock struct {
+	test.BasicMock
+	hashArgs []string
+}
+
+func (m *hasherMock) HashFile(path string, _ crypto.Hash) ([]byte, error) {
+	m.hashArgs = append(m.hashArgs, path)
+	return []byte("sum"), nil
+}
+
+func TestHashFile(t *testing.T) {
+	m := &hasherMock{}
+
+	fs := New(m, "/foo")
+	sum, err := util.HashFile(fs, "bar/qux", crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sum"), sum)
+	assert.Equal(t, []string{"/foo/bar/qux"}, m.hashArgs)
+
+	_, err = util.HashFile(fs, "../foo", crypto.SHA256)
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+type mapperMock struct {
+	test.BasicMock
+	mmapArgs []string
+}
+
+func (m *mapperMock) Mmap(path string) (billy.Mapping, error) {
+	m.mmapArgs = append(m.mmapArgs, path)
+	return util.NewMapping([]byte("foo"), nil), nil
+}
+
+func TestMmap(t *testing.T) {
+	m := &mapperMock{}
+
+	fs := New(m, "/foo")
+	mapping, err := util.Mmap(fs, "bar/qux")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("foo"), mapping.Bytes())
+	assert.Equal(t, []string{"/foo/bar/qux"}, m.mmapArgs)
+
+	_, err = util.Mmap(fs, "../foo")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+type statManyMock struct {
+	test.BasicMock
+	statManyArgs []string
+}
+
+func (m *statManyMock) StatMany(paths []string) ([]os.FileInfo, []error) {
+	m.statManyArgs = append(m.statManyArgs, paths...)
+	return make([]os.FileInfo, len(paths)), make([]error, len(paths))
+}
+
+func TestStatMany(t *testing.T) {
+	m := &statManyMock{}
+
+	fs := New(m, "/foo")
+	infos, errs := util.StatMany(fs, []string{"bar", "../foo", "qux/baz"})
+	assert.Len(t, infos, 3)
+	require.Len(t, errs, 3)
+	assert.Equal(t, []string{"/foo/bar", "/foo/qux/baz"}, m.statManyArgs)
+
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], billy.ErrCrossedBoundary)
+	assert.NoError(t, errs[2])
+}
+
+type copierMock struct {
+	test.BasicMock
+	copyArgs [][2]string
+}
+
+func (m *copierMock) CopyFile(src, dst string) error {
+	m.copyArgs = append(m.copyArgs, [2]string{src, dst})
+	return nil
+}
+
+func TestCopyFile(t *testing.T) {
+	m := &copierMock{}
+
+	fs := New(m, "/foo")
+	require.NoError(t, util.CopyFile(fs, "bar", "qux/baz"))
+	assert.Equal(t, [][2]string{{"/foo/bar", "/foo/qux/baz"}}, m.copyArgs)
+
+	err := util.CopyFile(fs, "bar", "../qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+	err = util.CopyFile(fs, "../bar", "qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
 func TestRemove(t *testing.T) {
 	m := &test.BasicMock{}
 
@@ -171,6 +471,38 @@ func TestTempFile(t *testing.T) {
 	assert.Equal(t, m.TempFileArgs[0], [2]string{"/foo/bar", "qux"})
 }
 
+func TestTempFileEmptyDir(t *testing.T) {
+	m := &test.TempFileMock{}
+
+	fs := New(m, "/foo")
+	_, err := fs.TempFile("", "qux")
+	require.NoError(t, err)
+	_, err = fs.TempDir("", "qux")
+	require.NoError(t, err)
+
+	assert.Equal(t, [][2]string{{"/foo", "qux"}}, m.TempFileArgs)
+	assert.Equal(t, [][2]string{{"/foo", "qux"}}, m.TempDirArgs)
+}
+
+func TestCreateTemp(t *testing.T) {
+	m := &test.TempFileMock{}
+
+	fs := New(m, "/foo")
+	f, err := fs.CreateTemp("bar", "qux*.txt")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("bar", "temp"), f.Name())
+
+	name, err := fs.MkdirTemp("bar", "qux*")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("bar", "tempdir"), name)
+
+	assert.Equal(t, [][2]string{{"/foo/bar", "qux*.txt"}}, m.CreateTempArgs)
+	assert.Equal(t, [][2]string{{"/foo/bar", "qux*"}}, m.MkdirTempArgs)
+
+	_, err = fs.CreateTemp("../bar", "qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
 func TestTempFileErrCrossedBoundary(t *testing.T) {
 	m := &test.TempFileMock{}
 
@@ -264,8 +596,9 @@ func TestLstatWithBasic(t *testing.T) {
 	m := &test.BasicMock{}
 
 	fs := New(m, "/foo")
-	_, err := fs.Lstat("")
-	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	_, err := fs.Lstat("qux")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/foo/qux"}, m.StatArgs)
 }
 
 func TestSymlink(t *testing.T) {
@@ -303,7 +636,7 @@ func TestSymlinkWithBasic(t *testing.T) {
 
 	fs := New(m, "/foo")
 	err := fs.Symlink("qux", "bar")
-	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, err, os.ErrInvalid)
 }
 
 func TestReadlink(t *testing.T) {
@@ -343,7 +676,7 @@ func TestReadlinkWithBasic(t *testing.T) {
 
 	fs := New(m, "/foo")
 	_, err := fs.Readlink("")
-	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, err, os.ErrInvalid)
 }
 
 func TestCapabilities(t *testing.T) {
@@ -358,5 +691,99 @@ func testCapabilities(t *testing.T, basic billy.Basic) {
 	fs := New(basic, "/foo")
 	capabilities := billy.Capabilities(fs)
 
-	assert.Equal(t, capabilities, baseCapabilities)
+	assert.Equal(t, capabilities, baseCapabilities|billy.RemoveAllCapability)
+}
+
+func TestPathProperties(t *testing.T) {
+	fs := New(new(test.CaseInsensitiveFs), "/foo")
+	assert.Equal(t, billy.Introspect(new(test.CaseInsensitiveFs)), billy.Introspect(fs))
+}
+
+func TestWithBoundaryError(t *testing.T) {
+	boundaryErr := fmt.Errorf("custom: %w", billy.ErrCrossedBoundary)
+	fs := New(&test.BasicMock{}, "/foo", WithBoundaryError(boundaryErr))
+
+	_, err := fs.Open("../foo")
+	assert.ErrorIs(t, err, boundaryErr)
+
+	chroot, err := fs.(billy.Chroot).Chroot("bar")
+	require.NoError(t, err)
+
+	_, err = chroot.Open("../foo")
+	assert.ErrorIs(t, err, boundaryErr)
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+func TestReadDirNames(t *testing.T) {
+	m := &test.DirMock{}
+
+	fs := New(m, "/foo")
+	_, err := fs.(billy.DirNames).ReadDirNames("bar", 0)
+	require.NoError(t, err)
+
+	assert.Len(t, m.ReadDirArgs, 1)
+	assert.Equal(t, m.ReadDirArgs[0], "/foo/bar")
+
+	_, err = fs.(billy.DirNames).ReadDirNames("../foo", 0)
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+func TestOpenDir(t *testing.T) {
+	m := &test.DirMock{}
+
+	fs := New(m, "/foo")
+	_, err := fs.(billy.DirOpener).OpenDir("bar")
+	require.NoError(t, err)
+
+	assert.Len(t, m.ReadDirArgs, 1)
+	assert.Equal(t, m.ReadDirArgs[0], "/foo/bar")
+
+	_, err = fs.(billy.DirOpener).OpenDir("../foo")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+func TestReadDirEntries(t *testing.T) {
+	m := &test.DirMock{}
+
+	fs := New(m, "/foo")
+	_, err := fs.(billy.DirEntries).ReadDirEntries("bar")
+	require.NoError(t, err)
+
+	assert.Len(t, m.ReadDirArgs, 1)
+	assert.Equal(t, m.ReadDirArgs[0], "/foo/bar")
+
+	_, err = fs.(billy.DirEntries).ReadDirEntries("../foo")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+func TestXattrWithBasic(t *testing.T) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo")
+	_, err := fs.(billy.Xattr).GetXattr("bar", "user.foo")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	err = fs.(billy.Xattr).SetXattr("bar", "user.foo", nil)
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	_, err = fs.(billy.Xattr).ListXattr("bar")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	err = fs.(billy.Xattr).RemoveXattr("../bar", "user.foo")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+func TestTempDir(t *testing.T) {
+	m := &test.TempFileMock{}
+
+	fs := New(m, "/foo")
+	name, err := fs.TempDir("bar", "qux")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("bar", "tempdir"), name)
+
+	assert.Len(t, m.TempDirArgs, 1)
+	assert.Equal(t, m.TempDirArgs[0], [2]string{"/foo/bar", "qux"})
+
+	_, err = fs.TempDir("../foo", "qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+	_, err = New(&test.BasicMock{}, "/foo").TempDir("", "")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
 }
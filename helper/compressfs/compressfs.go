@@ -0,0 +1,228 @@
+// Package compressfs provides a billy filesystem wrapper which compresses the
+// contents of the files stored in the filesystem it wraps, in a seekable
+// format, so they can still be read at any offset.
+package compressfs
+
+import (
+	"compress/flate"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// ErrInvalid is wrapped by the errors of the operations on files which are
+// not valid compressed files, such as the ones written outside of the
+// Compress filesystem.
+var ErrInvalid = errors.New("invalid compressed file")
+
+// Option configures a Compress filesystem.
+type Option func(*Compress)
+
+// WithLevel sets the compression level, from flate.BestSpeed to
+// flate.BestCompression. It defaults to flate.DefaultCompression.
+func WithLevel(level int) Option {
+	return func(h *Compress) {
+		h.level = level
+	}
+}
+
+// Compress is a helper that compresses the contents of the files of any
+// billy.Filesystem with DEFLATE.
+//
+// Files are compressed in chunks of 64 KiB, each of them on its own, and
+// indexed at the end of the file, so files opened read-only only decompress
+// the chunks they read. Files opened for writing are decompressed in memory
+// instead, and compressed back to the wrapped filesystem when synced or
+// closed. Until then, their writes are not visible to other handles of the
+// file nor to the filesystem.
+//
+// The sizes reported by Stat, Lstat and ReadDir are the ones of the
+// decompressed contents, read from the end of each file.
+type Compress struct {
+	billy.Filesystem
+	level int
+}
+
+// New creates a new filesystem wrapping up fs, which compresses the contents
+// of its files.
+func New(fs billy.Filesystem, opts ...Option) billy.Filesystem {
+	h := &Compress{Filesystem: fs, level: flate.DefaultCompression}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Layers implements billy.Composite.
+func (h *Compress) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *Compress) String() string {
+	return "compressfs"
+}
+
+func (h *Compress) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Compress) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file. The files open for writing are opened for
+// reading too in the wrapped filesystem, as they are rewritten as a whole,
+// and without os.O_APPEND, which is handled by the file.
+func (h *Compress) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	if !openflag.Writable(flag) {
+		f, err := h.Filesystem.OpenFile(filename, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		return newReadFile(f)
+	}
+
+	uflag := flag&^(os.O_WRONLY|os.O_APPEND) | os.O_RDWR
+	f, err := h.Filesystem.OpenFile(filename, uflag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteFile(f, flag, h.level)
+}
+
+func (h *Compress) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := h.Filesystem.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteFile(f, os.O_RDWR, h.level)
+}
+
+func (h *Compress) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := h.Filesystem.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriteFile(f, os.O_RDWR, h.level)
+}
+
+func (h *Compress) Stat(filename string) (os.FileInfo, error) {
+	fi, err := h.Filesystem.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.fileInfo(filename, fi)
+}
+
+func (h *Compress) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := h.Filesystem.Lstat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.fileInfo(filename, fi)
+}
+
+func (h *Compress) ReadDir(path string) ([]os.FileInfo, error) {
+	entries, err := h.Filesystem.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, fi := range entries {
+		if entries[i], err = h.fileInfo(h.Join(path, fi.Name()), fi); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// fileInfo returns fi, reporting the decompressed size, read from the file
+// at name, if it describes a regular file.
+func (h *Compress) fileInfo(name string, fi os.FileInfo) (os.FileInfo, error) {
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return fi, nil
+	}
+
+	f, err := h.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	size, err := readSize(f, fi.Size())
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return &fileInfo{FileInfo: fi, size: size}, nil
+}
+
+// Chroot returns a new Compress over the chroot of the wrapped filesystem,
+// compressing with the same level as h.
+func (h *Compress) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Compress{Filesystem: fs, level: h.level}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Compress) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Compress) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Compress) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Compress) Chmod(name string, mode fs.FileMode) error {
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Compress) Lchown(name string, uid, gid int) error {
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Compress) Chown(name string, uid, gid int) error {
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Compress) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+// fileInfo reports the size of the decompressed contents of a file.
+type fileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
@@ -0,0 +1,204 @@
+package compressfs
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+func TestReadWrite(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying)
+
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 5000))
+	require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), fi.Size())
+
+	raw, err := underlying.Stat("foo")
+	require.NoError(t, err)
+	assert.Less(t, raw.Size(), int64(len(data))/10)
+}
+
+func TestEmpty(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying)
+
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+
+	fi, err := underlying.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), fi.Size())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+func TestReadAt(t *testing.T) {
+	fs := New(memfs.New(), WithLevel(flate.BestSpeed))
+
+	data := randomData(3*chunkSize + 123)
+	require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+
+	f, err := fs.Open("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		off := r.Int63n(int64(len(data)))
+		p := make([]byte, r.Intn(2*chunkSize))
+
+		n, err := f.ReadAt(p, off)
+		want := min(len(p), len(data)-int(off))
+		assert.Equal(t, want, n)
+		assert.Equal(t, data[off:off+int64(n)], p[:n])
+		if n < len(p) {
+			assert.ErrorIs(t, err, io.EOF)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	pos, err := f.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)-10), pos)
+
+	rest, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, data[len(data)-10:], rest)
+
+	_, err = f.Write([]byte("foo"))
+	assert.Error(t, err)
+}
+
+func TestWriteAt(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, util.WriteFile(fs, "foo", randomData(chunkSize+10), 0o644))
+
+	f, err := fs.OpenFile("foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+
+	_, err = f.WriteAt([]byte("bar"), chunkSize-1)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(chunkSize+5))
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(chunkSize+10), fi.Size(), "not visible until synced")
+
+	require.NoError(t, f.Sync())
+	fi, err = fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(chunkSize+5), fi.Size())
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	want := randomData(chunkSize + 10)[:chunkSize+5]
+	copy(want[chunkSize-1:], "bar")
+	assert.Equal(t, want, content)
+}
+
+func TestAppend(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	f, err := fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("bar"))
+	require.NoError(t, err)
+
+	_, err = f.WriteAt([]byte("baz"), 0)
+	assert.ErrorIs(t, err, openflag.ErrWriteAtInAppendMode)
+
+	_, err = f.Read(make([]byte, 3))
+	assert.Error(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foobar", string(content))
+}
+
+func TestSizes(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, util.WriteFile(fs, "dir/foo", bytes.Repeat([]byte("foo"), 1000), 0o644))
+	require.NoError(t, fs.Symlink("foo", "dir/link"))
+
+	entries, err := fs.ReadDir("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(3000), entries[0].Size())
+
+	fi, err := fs.Lstat("dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+
+	fi, err = fs.Stat("dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3000), fi.Size())
+}
+
+func TestInvalid(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying)
+
+	require.NoError(t, util.WriteFile(underlying, "plain", []byte("not compressed"), 0o644))
+
+	_, err := fs.Open("plain")
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = fs.Stat("plain")
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	require.NoError(t, util.WriteFile(fs, "foo", randomData(chunkSize+10), 0o644))
+	raw, err := util.ReadFile(underlying, "foo")
+	require.NoError(t, err)
+
+	raw[headerSize+10] ^= 0xff
+	require.NoError(t, util.WriteFile(underlying, "foo", raw, 0o644))
+
+	_, err = util.ReadFile(fs, "foo")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestChroot(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying)
+
+	sub, err := fs.Chroot("sub")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(sub, "foo", []byte("foo"), 0o644))
+
+	content, err := util.ReadFile(fs, "sub/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	raw, err := util.ReadFile(underlying, "sub/foo")
+	require.NoError(t, err)
+	assert.Equal(t, magic, raw[:len(magic)])
+}
@@ -0,0 +1,416 @@
+package compressfs
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+)
+
+// readFile is a file open read-only, decompressing the chunks it reads. It
+// keeps the last one it decompressed, so sequential reads decompress every
+// chunk once.
+type readFile struct {
+	billy.File
+	idx *index
+
+	mu       sync.Mutex
+	position int64
+	closed   bool
+	cached   int64
+	chunk    []byte
+}
+
+func newReadFile(f billy.File) (billy.File, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	idx, err := readIndex(f, fi.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, pathError("open", f.Name(), err)
+	}
+
+	return &readFile{File: f, idx: idx, cached: -1}, nil
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.readAt(p, f.position)
+	f.position += int64(n)
+
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *readFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.readAt(p, off)
+}
+
+func (f *readFile) readAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, pathError("read", f.Name(), os.ErrClosed)
+	}
+	if off >= f.idx.size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), f.idx.size)
+	for pos := off; pos < end; {
+		i := pos / chunkSize
+		if i != f.cached {
+			chunk, err := f.idx.readChunk(f.File, i)
+			if err != nil {
+				return int(pos - off), pathError("read", f.Name(), err)
+			}
+
+			f.cached, f.chunk = i, chunk
+		}
+
+		pos += int64(copy(p[pos-off:end-off], f.chunk[pos%chunkSize:]))
+	}
+
+	read := int(end - off)
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+func (f *readFile) Write([]byte) (int, error) {
+	return 0, pathError("write", f.Name(), errno.EBADF)
+}
+
+func (f *readFile) WriteAt([]byte, int64) (int, error) {
+	return 0, pathError("write", f.Name(), errno.EBADF)
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, pathError("seek", f.Name(), os.ErrClosed)
+	}
+
+	pos, err := seek(f.position, f.idx.size, offset, whence)
+	if err != nil {
+		return 0, pathError("seek", f.Name(), err)
+	}
+
+	f.position = pos
+	return pos, nil
+}
+
+// Truncate fails with EINVAL, like os.File does for the files not open for
+// writing.
+func (f *readFile) Truncate(int64) error {
+	return pathError("truncate", f.Name(), syscall.EINVAL)
+}
+
+func (f *readFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{FileInfo: fi, size: f.idx.size}, nil
+}
+
+func (f *readFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	f.chunk = nil
+	return f.File.Close()
+}
+
+// writeFile is a file open for writing, holding its decompressed contents in
+// memory. They are compressed back to the wrapped file by Sync and Close,
+// if modified.
+type writeFile struct {
+	billy.File
+	flag  int
+	level int
+
+	mu       sync.Mutex
+	data     []byte
+	position int64
+	dirty    bool
+	closed   bool
+}
+
+func newWriteFile(f billy.File, flag, level int) (billy.File, error) {
+	data, err := readAll(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, pathError("open", f.Name(), err)
+	}
+
+	return &writeFile{File: f, flag: flag, level: level, data: data}, nil
+}
+
+// readAll returns the decompressed contents of f.
+func readAll(f billy.File) ([]byte, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := readIndex(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, idx.size)
+	for i := range chunks(idx.size) {
+		chunk, err := idx.readChunk(f, i)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, chunk...)
+	}
+
+	return data, nil
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.readAt(p, f.position)
+	f.position += int64(n)
+
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *writeFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.readAt(p, off)
+}
+
+func (f *writeFile) readAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, pathError("read", f.Name(), os.ErrClosed)
+	}
+	if !openflag.Readable(f.flag) {
+		return 0, pathError("read", f.Name(), errno.EBADF)
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pos := f.position
+	if openflag.Append(f.flag) {
+		pos = int64(len(f.data))
+	}
+
+	n, err := f.writeAt(p, pos)
+	f.position = pos + int64(n)
+	return n, err
+}
+
+func (f *writeFile) WriteAt(p []byte, off int64) (int, error) {
+	if openflag.Append(f.flag) {
+		return 0, pathError("writeat", f.Name(), openflag.ErrWriteAtInAppendMode)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.writeAt(p, off)
+}
+
+func (f *writeFile) writeAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, pathError("write", f.Name(), os.ErrClosed)
+	}
+	if off < 0 {
+		return 0, pathError("write", f.Name(), syscall.EINVAL)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+
+	f.dirty = true
+	return copy(f.data[off:], p), nil
+}
+
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, pathError("seek", f.Name(), os.ErrClosed)
+	}
+
+	pos, err := seek(f.position, int64(len(f.data)), offset, whence)
+	if err != nil {
+		return 0, pathError("seek", f.Name(), err)
+	}
+
+	f.position = pos
+	return pos, nil
+}
+
+func (f *writeFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return pathError("truncate", f.Name(), os.ErrClosed)
+	}
+	if size < 0 {
+		return pathError("truncate", f.Name(), syscall.EINVAL)
+	}
+
+	if size > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+
+	f.data = f.data[:size]
+	f.dirty = true
+	return nil
+}
+
+func (f *writeFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &fileInfo{FileInfo: fi, size: int64(len(f.data))}, nil
+}
+
+// flush compresses the contents back to the wrapped file, if modified.
+func (f *writeFile) flush() error {
+	if !f.dirty {
+		return nil
+	}
+
+	if err := write(f.File, f.data, f.level); err != nil {
+		return err
+	}
+
+	f.dirty = false
+	return nil
+}
+
+// Unlock compresses the contents back to the wrapped file before unlocking
+// it, so they are visible to the next ones locking it.
+func (f *writeFile) Unlock() error {
+	f.mu.Lock()
+	err := f.flush()
+	f.mu.Unlock()
+	if err != nil {
+		return pathError("unlock", f.Name(), err)
+	}
+
+	return f.File.Unlock()
+}
+
+func (f *writeFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return pathError("sync", f.Name(), os.ErrClosed)
+	}
+
+	if err := f.flush(); err != nil {
+		return pathError("sync", f.Name(), err)
+	}
+
+	return f.File.Sync()
+}
+
+func (f *writeFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.File.Close()
+	}
+
+	f.closed = true
+	err := f.flush()
+	f.data = nil
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return pathError("close", f.Name(), err)
+	}
+
+	return nil
+}
+
+// seek returns the position resulting from seeking to offset relative to
+// whence, from pos in a file of the given size.
+func seek(pos, size, offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += pos
+	case io.SeekEnd:
+		offset += size
+	}
+
+	if offset < 0 {
+		return 0, syscall.EINVAL
+	}
+
+	return offset, nil
+}
+
+// pathError returns err wrapped in an *os.PathError for the file at path,
+// unless it already is one, as the errors of the wrapped file are.
+func pathError(op, path string, err error) error {
+	if _, ok := err.(*os.PathError); ok {
+		return err
+	}
+
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
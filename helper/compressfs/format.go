@@ -0,0 +1,183 @@
+package compressfs
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// A compressed file is made of a header, its chunks, each one compressed on
+// its own, an index entry for every chunk, and a footer holding the
+// decompressed size of the file as a big endian uint64. An index entry holds
+// the compressed size of its chunk and the CRC-32 of its contents, as DEFLATE
+// has no checksum, both as big endian uint32. An empty file holds nothing at
+// all.
+const (
+	// chunkSize is the decompressed size of every chunk of a file but the
+	// last one.
+	chunkSize  = 64 << 10
+	entrySize  = 8
+	footerSize = 8
+)
+
+// magic starts the header of the compressed files, its last byte being the
+// version of the format.
+var magic = []byte("BCMP\x01")
+
+var headerSize = int64(len(magic))
+
+// chunks returns the number of chunks of a file of the given size.
+func chunks(size int64) int64 {
+	return (size + chunkSize - 1) / chunkSize
+}
+
+// readSize returns the decompressed size of f, whose compressed size is raw.
+func readSize(f io.ReaderAt, raw int64) (int64, error) {
+	if raw == 0 {
+		return 0, nil
+	}
+	if raw < headerSize+footerSize {
+		return 0, ErrInvalid
+	}
+
+	buf := make([]byte, headerSize)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(buf, magic) {
+		return 0, ErrInvalid
+	}
+
+	buf = make([]byte, footerSize)
+	if _, err := f.ReadAt(buf, raw-footerSize); err != nil {
+		return 0, err
+	}
+
+	size := int64(binary.BigEndian.Uint64(buf))
+	if size < 0 || chunks(size)*entrySize > raw-headerSize-footerSize {
+		return 0, ErrInvalid
+	}
+
+	return size, nil
+}
+
+// index locates the chunks of a compressed file.
+type index struct {
+	size int64
+	// offsets holds the offset of every chunk in the compressed file,
+	// followed by the offset where the last one ends.
+	offsets []int64
+	sums    []uint32
+}
+
+// readIndex reads the index of f, whose compressed size is raw.
+func readIndex(f io.ReaderAt, raw int64) (*index, error) {
+	if raw == 0 {
+		return &index{offsets: []int64{headerSize}}, nil
+	}
+
+	size, err := readSize(f, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	count := chunks(size)
+	end := raw - footerSize - count*entrySize
+	buf := make([]byte, count*entrySize)
+	if _, err := f.ReadAt(buf, end); err != nil {
+		return nil, err
+	}
+
+	idx := &index{size: size, offsets: make([]int64, count+1), sums: make([]uint32, count)}
+	idx.offsets[0] = headerSize
+	for i := range count {
+		entry := buf[i*entrySize:]
+		idx.offsets[i+1] = idx.offsets[i] + int64(binary.BigEndian.Uint32(entry))
+		idx.sums[i] = binary.BigEndian.Uint32(entry[4:])
+	}
+
+	if count > 0 && idx.offsets[count] != end {
+		return nil, ErrInvalid
+	}
+
+	return idx, nil
+}
+
+// readChunk returns the decompressed contents of chunk i of f.
+func (idx *index) readChunk(f io.ReaderAt, i int64) ([]byte, error) {
+	want := min(chunkSize, idx.size-i*chunkSize)
+	off, end := idx.offsets[i], idx.offsets[i+1]
+
+	r := flate.NewReader(io.NewSectionReader(f, off, end-off))
+	defer r.Close()
+
+	chunk := make([]byte, want)
+	if _, err := io.ReadFull(r, chunk); err != nil {
+		return nil, invalid(err)
+	}
+
+	// The chunk must end right after its contents.
+	if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		return nil, ErrInvalid
+	}
+	if crc32.ChecksumIEEE(chunk) != idx.sums[i] {
+		return nil, ErrInvalid
+	}
+
+	return chunk, nil
+}
+
+// invalid returns ErrInvalid for the errors of a truncated or corrupted
+// stream, and err for the other ones, such as the ones reading the file.
+func invalid(err error) error {
+	var cerr flate.CorruptInputError
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.As(err, &cerr) {
+		return ErrInvalid
+	}
+
+	return err
+}
+
+// write writes data to f compressed with level, replacing its contents.
+func write(f billy.File, data []byte, level int) error {
+	if len(data) == 0 {
+		return f.Truncate(0)
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(magic)
+	entries := make([]byte, 0, chunks(int64(len(data)))*entrySize+footerSize)
+	for off := 0; off < len(data); off += chunkSize {
+		chunk := data[off:min(off+chunkSize, len(data))]
+		start := buf.Len()
+		w.Reset(&buf)
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		entries = binary.BigEndian.AppendUint32(entries, uint32(buf.Len()-start))
+		entries = binary.BigEndian.AppendUint32(entries, crc32.ChecksumIEEE(chunk))
+	}
+
+	entries = binary.BigEndian.AppendUint64(entries, uint64(len(data)))
+	buf.Write(entries)
+
+	if _, err := f.WriteAt(buf.Bytes(), 0); err != nil {
+		return err
+	}
+
+	return f.Truncate(int64(buf.Len()))
+}
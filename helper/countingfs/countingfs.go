@@ -0,0 +1,349 @@
+// Package countingfs provides a billy filesystem wrapper which counts the
+// bytes read and written and the operations made over any billy.Filesystem.
+//
+// Unlike recordfs, it keeps no log but only atomic counters, so it is cheap
+// enough to be always on, such as to export metrics from a server.
+package countingfs
+
+import (
+	"io/fs"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// Stats is a snapshot of the counters of a Counting filesystem. Operations
+// are counted whether they succeed or not.
+type Stats struct {
+	// BytesRead and BytesWritten are the number of bytes read from and
+	// written to files.
+	BytesRead    int64
+	BytesWritten int64
+
+	// Reads and Writes are the number of calls to Read and ReadAt, and to
+	// Write and WriteAt, on files.
+	Reads  int64
+	Writes int64
+
+	// Opens is the number of files opened, including the ones created by
+	// Create, TempFile and CreateTemp.
+	Opens int64
+	// Stats is the number of calls to Stat and Lstat.
+	Stats int64
+	// Renames is the number of calls to Rename, RenameNoReplace and
+	// RenameExchange.
+	Renames int64
+	// Removes is the number of calls to Remove.
+	Removes int64
+	// ReadDirs is the number of calls to ReadDir, ReadDirNames,
+	// ReadDirEntries and OpenDir, and to ReadDir on files.
+	ReadDirs int64
+	// MkdirAlls is the number of calls to MkdirAll, TempDir and MkdirTemp.
+	MkdirAlls int64
+	// Symlinks is the number of calls to Symlink and Readlink.
+	Symlinks int64
+	// Changes is the number of calls to Chmod, Chown, Lchown and Chtimes,
+	// and to Chmod and Chtimes on files.
+	Changes int64
+}
+
+// counters is shared by a Counting and all the filesystems returned by its
+// Chroot method, so the whole tree is counted.
+type counters struct {
+	bytesRead    atomic.Int64
+	bytesWritten atomic.Int64
+	reads        atomic.Int64
+	writes       atomic.Int64
+	opens        atomic.Int64
+	stats        atomic.Int64
+	renames      atomic.Int64
+	removes      atomic.Int64
+	readDirs     atomic.Int64
+	mkdirAlls    atomic.Int64
+	symlinks     atomic.Int64
+	changes      atomic.Int64
+}
+
+// Counting is a helper that counts the operations made over any
+// billy.Filesystem.
+type Counting struct {
+	billy.Filesystem
+	c *counters
+}
+
+// New creates a new filesystem wrapping up fs, counting the operations made
+// over it.
+func New(fs billy.Filesystem) billy.Filesystem {
+	return &Counting{Filesystem: fs, c: &counters{}}
+}
+
+// Layers implements billy.Composite.
+func (h *Counting) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *Counting) String() string {
+	return "countingfs"
+}
+
+// Stats returns the current value of the counters. Each counter is read
+// atomically, but not all of them at once, so they may be slightly out of
+// sync with each other under concurrent use.
+func (h *Counting) Stats() Stats {
+	return Stats{
+		BytesRead:    h.c.bytesRead.Load(),
+		BytesWritten: h.c.bytesWritten.Load(),
+		Reads:        h.c.reads.Load(),
+		Writes:       h.c.writes.Load(),
+		Opens:        h.c.opens.Load(),
+		Stats:        h.c.stats.Load(),
+		Renames:      h.c.renames.Load(),
+		Removes:      h.c.removes.Load(),
+		ReadDirs:     h.c.readDirs.Load(),
+		MkdirAlls:    h.c.mkdirAlls.Load(),
+		Symlinks:     h.c.symlinks.Load(),
+		Changes:      h.c.changes.Load(),
+	}
+}
+
+func (h *Counting) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Counting) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (h *Counting) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	h.c.opens.Add(1)
+	f, err := h.Filesystem.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), c: h.c}, nil
+}
+
+func (h *Counting) TempFile(dir, prefix string) (billy.File, error) {
+	h.c.opens.Add(1)
+	f, err := h.Filesystem.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), c: h.c}, nil
+}
+
+func (h *Counting) Stat(filename string) (os.FileInfo, error) {
+	h.c.stats.Add(1)
+	return h.Filesystem.Stat(filename)
+}
+
+func (h *Counting) Lstat(filename string) (os.FileInfo, error) {
+	h.c.stats.Add(1)
+	return h.Filesystem.Lstat(filename)
+}
+
+func (h *Counting) Rename(from, to string) error {
+	h.c.renames.Add(1)
+	return h.Filesystem.Rename(from, to)
+}
+
+func (h *Counting) Remove(filename string) error {
+	h.c.removes.Add(1)
+	return h.Filesystem.Remove(filename)
+}
+
+func (h *Counting) ReadDir(path string) ([]os.FileInfo, error) {
+	h.c.readDirs.Add(1)
+	return h.Filesystem.ReadDir(path)
+}
+
+func (h *Counting) MkdirAll(filename string, perm fs.FileMode) error {
+	h.c.mkdirAlls.Add(1)
+	return h.Filesystem.MkdirAll(filename, perm)
+}
+
+func (h *Counting) TempDir(dir, prefix string) (string, error) {
+	h.c.mkdirAlls.Add(1)
+	return h.Filesystem.TempDir(dir, prefix)
+}
+
+func (h *Counting) CreateTemp(dir, pattern string) (billy.File, error) {
+	h.c.opens.Add(1)
+	f, err := h.Filesystem.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), c: h.c}, nil
+}
+
+func (h *Counting) MkdirTemp(dir, pattern string) (string, error) {
+	h.c.mkdirAlls.Add(1)
+	return h.Filesystem.MkdirTemp(dir, pattern)
+}
+
+func (h *Counting) Symlink(target, link string) error {
+	h.c.symlinks.Add(1)
+	return h.Filesystem.Symlink(target, link)
+}
+
+func (h *Counting) Readlink(link string) (string, error) {
+	h.c.symlinks.Add(1)
+	return h.Filesystem.Readlink(link)
+}
+
+func (h *Counting) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Counting{Filesystem: fs, c: h.c}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Counting) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Counting) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Counting) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (h *Counting) ReadDirNames(path string, n int) ([]string, error) {
+	h.c.readDirs.Add(1)
+	return util.ReadDirNames(h.Filesystem, path, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (h *Counting) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	h.c.readDirs.Add(1)
+	return util.ReadDirEntries(h.Filesystem, path)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (h *Counting) OpenDir(path string) (billy.DirIter, error) {
+	h.c.readDirs.Add(1)
+	return util.OpenDir(h.Filesystem, path)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (h *Counting) SyncDir(path string) error {
+	return util.SyncDir(h.Filesystem, path)
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (h *Counting) RenameNoReplace(from, to string) error {
+	h.c.renames.Add(1)
+	return util.RenameNoReplace(h.Filesystem, from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (h *Counting) RenameExchange(from, to string) error {
+	h.c.renames.Add(1)
+	return util.RenameExchange(h.Filesystem, from, to)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Counting) Chmod(name string, mode fs.FileMode) error {
+	h.c.changes.Add(1)
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Counting) Lchown(name string, uid, gid int) error {
+	h.c.changes.Add(1)
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Counting) Chown(name string, uid, gid int) error {
+	h.c.changes.Add(1)
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Counting) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	h.c.changes.Add(1)
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+type file struct {
+	*wrappedfile.File
+	c *counters
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.c.read(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.c.read(n)
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.c.write(n)
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	f.c.write(n)
+	return n, err
+}
+
+// Chmod implements the billy.FileChange interface, if the wrapped file does.
+func (f *file) Chmod(mode fs.FileMode) error {
+	f.c.changes.Add(1)
+	return f.File.Chmod(mode)
+}
+
+// Chtimes implements the billy.FileChange interface, if the wrapped file
+// does.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	f.c.changes.Add(1)
+	return f.File.Chtimes(atime, mtime)
+}
+
+// ReadDir implements the billy.ReadDirFile interface, if the wrapped file
+// does.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.c.readDirs.Add(1)
+	return f.File.ReadDir(n)
+}
+
+// RawFile implements the billy.RawFile interface. It always fails with an
+// error wrapping billy.ErrNotSupported, as the reads and writes made on the
+// *os.File could not be counted.
+func (f *file) RawFile() (*os.File, error) {
+	return nil, &os.PathError{Op: "rawfile", Path: f.Name(), Err: billy.ErrNotSupported}
+}
+
+func (c *counters) read(n int) {
+	c.reads.Add(1)
+	c.bytesRead.Add(int64(n))
+}
+
+func (c *counters) write(n int) {
+	c.writes.Add(1)
+	c.bytesWritten.Add(int64(n))
+}
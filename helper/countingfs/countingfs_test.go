@@ -0,0 +1,105 @@
+package countingfs
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestStats(t *testing.T) {
+	fs := New(memfs.New())
+
+	require.NoError(t, fs.MkdirAll("dir", 0o755))
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("foo"), 0o644))
+
+	content, err := util.ReadFile(fs, "dir/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	_, err = fs.Stat("dir/missing")
+	require.Error(t, err)
+
+	require.NoError(t, fs.Rename("dir/foo", "dir/bar"))
+	_, err = fs.ReadDir("dir")
+	require.NoError(t, err)
+	require.NoError(t, fs.Symlink("bar", "dir/link"))
+	require.NoError(t, fs.Remove("dir/link"))
+	require.NoError(t, util.Chmod(fs, "dir/bar", 0o600))
+
+	stats := fs.(*Counting).Stats()
+	assert.Equal(t, int64(3), stats.BytesRead)
+	assert.Equal(t, int64(3), stats.BytesWritten)
+	assert.Equal(t, int64(1), stats.Writes)
+	assert.Equal(t, int64(2), stats.Opens)
+	assert.Equal(t, int64(1), stats.Renames)
+	assert.Equal(t, int64(1), stats.ReadDirs)
+	assert.Equal(t, int64(1), stats.Symlinks)
+	assert.Equal(t, int64(1), stats.Removes)
+	assert.Equal(t, int64(1), stats.MkdirAlls)
+	assert.Equal(t, int64(1), stats.Changes)
+	assert.GreaterOrEqual(t, stats.Stats, int64(1))
+	assert.GreaterOrEqual(t, stats.Reads, int64(1))
+}
+
+func TestCopyCounted(t *testing.T) {
+	fs := New(osfs.New(t.TempDir()))
+	content := make([]byte, 100000)
+	require.NoError(t, util.WriteFile(fs, "src", content, 0o644))
+
+	src, err := fs.Open("src")
+	require.NoError(t, err)
+	defer src.Close()
+	dst, err := fs.Create("dst")
+	require.NoError(t, err)
+	defer dst.Close()
+
+	before := fs.(*Counting).Stats()
+	n, err := util.Copy(dst, src)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), n)
+
+	stats := fs.(*Counting).Stats()
+	assert.Equal(t, int64(len(content)), stats.BytesRead-before.BytesRead)
+	assert.Equal(t, int64(len(content)), stats.BytesWritten-before.BytesWritten)
+}
+
+func TestChrootShared(t *testing.T) {
+	fs := New(memfs.New())
+
+	sub, err := fs.Chroot("sub")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(sub, "foo", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+
+	assert.Equal(t, int64(6), fs.(*Counting).Stats().BytesWritten)
+	assert.Equal(t, fs.(*Counting).Stats(), sub.(*Counting).Stats())
+}
+
+func TestConcurrent(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			f, err := fs.Open("foo")
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			_, _ = io.ReadAll(f)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(30), fs.(*Counting).Stats().BytesRead)
+}
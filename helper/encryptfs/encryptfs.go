@@ -0,0 +1,214 @@
+// Package encryptfs provides a billy filesystem wrapper which encrypts the
+// contents of the files stored in the filesystem it wraps, with an AEAD
+// supplied by the caller, such as AES-GCM.
+package encryptfs
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// ErrInvalid is wrapped by the errors of the operations on files which are
+// not valid encrypted files, or fail to be authenticated, such as the ones
+// modified outside of the Encrypt filesystem or encrypted with another key.
+var ErrInvalid = errors.New("invalid encrypted file")
+
+// Encrypt is a helper that encrypts the contents of the files of any
+// billy.Filesystem.
+//
+// Files are encrypted in chunks of 64 KiB, each of them sealed on its own
+// with a random nonce, so they can be read and written at any offset by
+// only decrypting the chunks involved. Every file starts with a header
+// holding a random file ID, which is authenticated along with the index of
+// each chunk and whether it is the last one, so chunks can't be reordered,
+// moved between files or dropped from their end without being detected.
+// Truncating a file to its bare header, or replacing it with another file
+// encrypted with the same key, is not detected.
+//
+// Only the contents are encrypted: the names of the files, their metadata
+// and the targets of symlinks are stored as is. The sizes reported by Stat,
+// Lstat and ReadDir are the ones of the decrypted contents.
+type Encrypt struct {
+	billy.Filesystem
+	aead cipher.AEAD
+}
+
+// New creates a new filesystem wrapping up fs, which encrypts the contents
+// of its files with aead. The nonce size of aead must be large enough for
+// random nonces, as the 12 bytes of AES-GCM are.
+func New(fs billy.Filesystem, aead cipher.AEAD) billy.Filesystem {
+	return &Encrypt{Filesystem: fs, aead: aead}
+}
+
+// Layers implements billy.Composite.
+func (h *Encrypt) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *Encrypt) String() string {
+	return "encryptfs"
+}
+
+func (h *Encrypt) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Encrypt) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file. The files open for writing are opened for
+// reading too in the wrapped filesystem, as writing part of a chunk needs
+// reading it first, and without os.O_APPEND, which is handled by the file.
+func (h *Encrypt) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	uflag := flag
+	if openflag.Writable(flag) {
+		uflag = flag&^(os.O_WRONLY|os.O_APPEND) | os.O_RDWR
+	}
+
+	f, err := h.Filesystem.OpenFile(filename, uflag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f, h.aead, flag)
+}
+
+func (h *Encrypt) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := h.Filesystem.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f, h.aead, os.O_RDWR)
+}
+
+func (h *Encrypt) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := h.Filesystem.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f, h.aead, os.O_RDWR)
+}
+
+func (h *Encrypt) Stat(filename string) (os.FileInfo, error) {
+	fi, err := h.Filesystem.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(fi, h.aead), nil
+}
+
+func (h *Encrypt) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := h.Filesystem.Lstat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(fi, h.aead), nil
+}
+
+func (h *Encrypt) ReadDir(path string) ([]os.FileInfo, error) {
+	entries, err := h.Filesystem.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, fi := range entries {
+		entries[i] = newFileInfo(fi, h.aead)
+	}
+
+	return entries, nil
+}
+
+// Chroot returns a new Encrypt over the chroot of the wrapped filesystem,
+// encrypting with the same AEAD as h.
+func (h *Encrypt) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Encrypt{Filesystem: fs, aead: h.aead}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Encrypt) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Encrypt) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Encrypt) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Encrypt) Chmod(name string, mode fs.FileMode) error {
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Encrypt) Lchown(name string, uid, gid int) error {
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Encrypt) Chown(name string, uid, gid int) error {
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Encrypt) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+// fileInfo reports the size of the decrypted contents of a file.
+type fileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+// newFileInfo returns fi, reporting the decrypted size if it describes a
+// regular file.
+func newFileInfo(fi os.FileInfo, aead cipher.AEAD) os.FileInfo {
+	if !fi.Mode().IsRegular() {
+		return fi
+	}
+
+	size, _ := plainSize(fi.Size(), aead)
+	return &fileInfo{FileInfo: fi, size: size}
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
+
+// dirEntry reports the size of the decrypted contents of a file in its info.
+type dirEntry struct {
+	fs.DirEntry
+	aead cipher.AEAD
+}
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	fi, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(fi, e.aead), nil
+}
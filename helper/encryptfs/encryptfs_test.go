@@ -0,0 +1,254 @@
+package encryptfs
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func newAEAD(t *testing.T, key byte) cipher.AEAD {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{key}, 32))
+	require.NoError(t, err)
+
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return aead
+}
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+func TestReadWrite(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying, newAEAD(t, 1))
+
+	data := randomData(3*chunkSize + 123)
+	require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), fi.Size())
+
+	raw, err := util.ReadFile(underlying, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, headerSize+int64(len(data))+4*overhead(newAEAD(t, 1)), int64(len(raw)))
+	assert.False(t, bytes.Contains(raw, data[:32]))
+	assert.Equal(t, magic, raw[:len(magic)])
+}
+
+func TestEmpty(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying, newAEAD(t, 1))
+
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+
+	f, err := fs.OpenFile("foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(0))
+	require.NoError(t, f.Close())
+
+	fi, err := underlying.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, headerSize, fi.Size())
+
+	content, err = util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+// TestRandomAccess checks the reads, writes and truncations made at random
+// offsets against the same ones made on a plain file.
+func TestRandomAccess(t *testing.T) {
+	fs := New(memfs.New(), newAEAD(t, 1))
+	plain := memfs.New()
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+	want, err := plain.Create("foo")
+	require.NoError(t, err)
+	defer want.Close()
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		off := r.Int63n(4 * chunkSize)
+		switch r.Intn(3) {
+		case 0:
+			p := randomData(r.Intn(2 * chunkSize))
+			n, err := f.WriteAt(p, off)
+			require.NoError(t, err)
+			assert.Equal(t, len(p), n)
+
+			_, err = want.WriteAt(p, off)
+			require.NoError(t, err)
+		case 1:
+			require.NoError(t, f.Truncate(off))
+			require.NoError(t, want.Truncate(off))
+		case 2:
+			p := make([]byte, r.Intn(2*chunkSize))
+			n, err := f.ReadAt(p, off)
+
+			wp := make([]byte, len(p))
+			wn, werr := want.ReadAt(wp, off)
+			require.Equal(t, wn, n)
+			assert.Equal(t, werr, err)
+			assert.Equal(t, wp[:wn], p[:n])
+		}
+	}
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	wfi, err := want.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, wfi.Size(), fi.Size())
+
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	wcontent, err := util.ReadFile(plain, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, wcontent, content)
+}
+
+func TestAppend(t *testing.T) {
+	fs := New(memfs.New(), newAEAD(t, 1))
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	f, err := fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("bar"))
+	require.NoError(t, err)
+
+	_, err = f.WriteAt([]byte("baz"), 0)
+	assert.ErrorIs(t, err, openflag.ErrWriteAtInAppendMode)
+
+	_, err = f.Read(make([]byte, 3))
+	assert.Error(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foobar", string(content))
+}
+
+func TestSizes(t *testing.T) {
+	fs := New(memfs.New(), newAEAD(t, 1))
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("foo", "dir/link"))
+
+	entries, err := fs.ReadDir("dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(3), entries[0].Size())
+
+	fi, err := fs.Lstat("dir/foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+
+	fi, err = fs.Lstat("dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+
+	fi, err = fs.Stat("dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+}
+
+func TestWrongKey(t *testing.T) {
+	underlying := memfs.New()
+	require.NoError(t, util.WriteFile(New(underlying, newAEAD(t, 1)), "foo", []byte("foo"), 0o644))
+
+	_, err := util.ReadFile(New(underlying, newAEAD(t, 2)), "foo")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestTampered(t *testing.T) {
+	data := randomData(2*chunkSize + 10)
+	sealed := chunkSize + overhead(newAEAD(t, 1))
+
+	tests := map[string]func(t *testing.T, fs billy.Filesystem, raw []byte){
+		"flipped": func(t *testing.T, fs billy.Filesystem, raw []byte) {
+			raw[len(raw)-1] ^= 1
+			require.NoError(t, util.WriteFile(fs, "foo", raw, 0o644))
+		},
+		"truncated": func(t *testing.T, fs billy.Filesystem, raw []byte) {
+			require.NoError(t, util.WriteFile(fs, "foo", raw[:headerSize+2*sealed], 0o644))
+		},
+		"swapped": func(t *testing.T, fs billy.Filesystem, raw []byte) {
+			first := append([]byte(nil), raw[headerSize:headerSize+sealed]...)
+			copy(raw[headerSize:], raw[headerSize+sealed:headerSize+2*sealed])
+			copy(raw[headerSize+sealed:], first)
+			require.NoError(t, util.WriteFile(fs, "foo", raw, 0o644))
+		},
+		"moved": func(t *testing.T, fs billy.Filesystem, raw []byte) {
+			other, err := util.ReadFile(fs, "bar")
+			require.NoError(t, err)
+			copy(raw[headerSize:], other[headerSize:headerSize+sealed])
+			require.NoError(t, util.WriteFile(fs, "foo", raw, 0o644))
+		},
+		"header": func(t *testing.T, fs billy.Filesystem, raw []byte) {
+			raw[0] = 'X'
+			require.NoError(t, util.WriteFile(fs, "foo", raw, 0o644))
+		},
+	}
+
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			underlying := memfs.New()
+			fs := New(underlying, newAEAD(t, 1))
+			require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+			require.NoError(t, util.WriteFile(fs, "bar", data, 0o644))
+
+			raw, err := util.ReadFile(underlying, "foo")
+			require.NoError(t, err)
+			tamper(t, underlying, raw)
+
+			_, err = util.ReadFile(fs, "foo")
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestChroot(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying, newAEAD(t, 1))
+
+	sub, err := fs.Chroot("sub")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(sub, "foo", []byte("foo"), 0o644))
+
+	content, err := util.ReadFile(fs, "sub/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	raw, err := util.ReadFile(underlying, "sub/foo")
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "foo")
+}
@@ -0,0 +1,497 @@
+package encryptfs
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+)
+
+const (
+	// chunkSize is the size of the decrypted contents of every chunk of a
+	// file but the last one.
+	chunkSize = 64 << 10
+	idSize    = 16
+)
+
+// magic starts the header of the encrypted files, its last byte being the
+// version of the format. It is followed by the ID of the file.
+var magic = []byte("BENC\x01")
+
+var headerSize = int64(len(magic) + idSize)
+
+// overhead returns the number of bytes each chunk takes on top of its
+// contents once sealed with aead: its nonce and its tag.
+func overhead(aead cipher.AEAD) int64 {
+	return int64(aead.NonceSize() + aead.Overhead())
+}
+
+// plainSize returns the size of the decrypted contents of a file whose
+// encrypted size is raw, reporting false if no valid file has that size.
+func plainSize(raw int64, aead cipher.AEAD) (int64, bool) {
+	if raw == 0 {
+		return 0, true
+	}
+	if raw < headerSize {
+		return 0, false
+	}
+
+	sealed := chunkSize + overhead(aead)
+	n, rest := (raw-headerSize)/sealed, (raw-headerSize)%sealed
+	size := n * chunkSize
+	if rest == 0 {
+		return size, true
+	}
+	if rest <= overhead(aead) {
+		return size, false
+	}
+
+	return size + rest - overhead(aead), true
+}
+
+// chunks returns the number of chunks of a file of the given size.
+func chunks(size int64) int64 {
+	return (size + chunkSize - 1) / chunkSize
+}
+
+// file encrypts the contents written to the wrapped file, and decrypts the
+// ones read from it. It keeps its own position, as the wrapped file is only
+// read and written with ReadAt and WriteAt.
+type file struct {
+	*wrappedfile.File
+	aead cipher.AEAD
+	flag int
+
+	mu sync.Mutex
+	// id is the ID of the file, nil until the header is written to a file
+	// created empty.
+	id       []byte
+	position int64
+	closed   bool
+}
+
+func newFile(f billy.File, aead cipher.AEAD, flag int) (billy.File, error) {
+	ef := &file{File: wrappedfile.Wrap(f), aead: aead, flag: flag}
+	if err := ef.readHeader(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return ef, nil
+}
+
+// readHeader reads the ID of the file from its header, unless it is empty or
+// not a regular file, such as a dir opened to read its entries.
+func (f *file) readHeader() error {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return nil
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := f.File.ReadAt(header, 0); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if string(header[:len(magic)]) != string(magic) {
+		return f.pathError("open", ErrInvalid)
+	}
+
+	f.id = header[len(magic):]
+	return nil
+}
+
+// writeHeader writes the header of a file created empty, with a new ID.
+func (f *file) writeHeader() error {
+	if f.id != nil {
+		return nil
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return err
+	}
+	if _, err := f.File.WriteAt(header, 0); err != nil {
+		return err
+	}
+
+	f.id = header[len(magic):]
+	return nil
+}
+
+// size returns the size of the decrypted contents of the file.
+func (f *file) size() (int64, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size, ok := plainSize(fi.Size(), f.aead)
+	if !ok {
+		return 0, ErrInvalid
+	}
+
+	return size, nil
+}
+
+// chunkOffset returns the offset of chunk i in the wrapped file.
+func (f *file) chunkOffset(i int64) int64 {
+	return headerSize + i*(chunkSize+overhead(f.aead))
+}
+
+// additionalData returns the data authenticated along with chunk i, binding
+// it to its file and its position.
+func (f *file) additionalData(i int64, last bool) []byte {
+	ad := make([]byte, idSize+9)
+	copy(ad, f.id)
+	binary.BigEndian.PutUint64(ad[idSize:], uint64(i))
+	if last {
+		ad[idSize+8] = 1
+	}
+
+	return ad
+}
+
+// readChunk returns the decrypted contents of chunk i of a file made of
+// count chunks.
+func (f *file) readChunk(i, count int64) ([]byte, error) {
+	buf := make([]byte, chunkSize+overhead(f.aead))
+	n, err := f.File.ReadAt(buf, f.chunkOffset(i))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if int64(n) <= overhead(f.aead) {
+		return nil, ErrInvalid
+	}
+
+	nonce, sealed := buf[:f.aead.NonceSize()], buf[f.aead.NonceSize():n]
+	plain, err := f.aead.Open(sealed[:0], nonce, sealed, f.additionalData(i, i == count-1))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	return plain, nil
+}
+
+// writeChunk seals plain as chunk i, with a new nonce, so rewriting a chunk
+// never reuses the nonce of its previous contents.
+func (f *file) writeChunk(i int64, plain []byte, last bool) error {
+	buf := make([]byte, f.aead.NonceSize(), int64(len(plain))+overhead(f.aead))
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+
+	buf = f.aead.Seal(buf, buf, plain, f.additionalData(i, last))
+	_, err := f.File.WriteAt(buf, f.chunkOffset(i))
+	return err
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.readAt(p, f.position)
+	f.position += int64(n)
+
+	if errors.Is(err, io.EOF) && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.readAt(p, off)
+}
+
+func (f *file) readAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.pathError("read", os.ErrClosed)
+	}
+	if !openflag.Readable(f.flag) {
+		return 0, f.pathError("read", errno.EBADF)
+	}
+
+	size, err := f.size()
+	if err != nil {
+		return 0, f.pathError("read", err)
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), size)
+	count := chunks(size)
+	for pos := off; pos < end; {
+		i, co := pos/chunkSize, pos%chunkSize
+		chunk, err := f.readChunk(i, count)
+		if err != nil {
+			return int(pos - off), f.pathError("read", err)
+		}
+
+		// Every chunk but the last one is full, as the size of the file
+		// tells, so a shorter chunk was tampered with.
+		if co >= int64(len(chunk)) {
+			return int(pos - off), f.pathError("read", ErrInvalid)
+		}
+
+		pos += int64(copy(p[pos-off:end-off], chunk[co:]))
+	}
+
+	read := int(end - off)
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pos := f.position
+	if openflag.Append(f.flag) && !f.closed {
+		size, err := f.size()
+		if err != nil {
+			return 0, f.pathError("write", err)
+		}
+		pos = size
+	}
+
+	n, err := f.writeAt(p, pos)
+	f.position = pos + int64(n)
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if openflag.Append(f.flag) {
+		return 0, f.pathError("writeat", openflag.ErrWriteAtInAppendMode)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.writeAt(p, off)
+}
+
+func (f *file) writeAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.pathError("write", os.ErrClosed)
+	}
+	if !openflag.Writable(f.flag) {
+		return 0, f.pathError("write", errno.EBADF)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	size, err := f.size()
+	if err == nil && off > size {
+		err = f.grow(size, off)
+		size = off
+	}
+	if err == nil {
+		err = f.writeHeader()
+	}
+	if err != nil {
+		return 0, f.pathError("write", err)
+	}
+
+	end := off + int64(len(p))
+	count, prev := chunks(max(size, end)), chunks(size)
+
+	// The last chunk of the file is sealed as such, so it has to be sealed
+	// again if the file grows past it without it being written.
+	if last := prev - 1; last >= 0 && count > prev && off/chunkSize > last {
+		chunk, err := f.readChunk(last, prev)
+		if err == nil {
+			err = f.writeChunk(last, chunk, false)
+		}
+		if err != nil {
+			return 0, f.pathError("write", err)
+		}
+	}
+
+	for pos := off; pos < end; {
+		i, co := pos/chunkSize, pos%chunkSize
+		want := min(chunkSize-co, end-pos)
+
+		var chunk []byte
+		if i < prev {
+			if chunk, err = f.readChunk(i, prev); err != nil {
+				return int(pos - off), f.pathError("write", err)
+			}
+		}
+
+		if need := co + want; int64(len(chunk)) < need {
+			chunk = append(chunk, make([]byte, need-int64(len(chunk)))...)
+		}
+		copy(chunk[co:], p[pos-off:pos-off+want])
+
+		if err := f.writeChunk(i, chunk, i == count-1); err != nil {
+			return int(pos - off), f.pathError("write", err)
+		}
+
+		pos += want
+	}
+
+	return len(p), nil
+}
+
+// grow extends the file from size to the given size with zeros, one chunk
+// at a time.
+func (f *file) grow(size, to int64) error {
+	zeros := make([]byte, chunkSize)
+	for size < to {
+		n := min(chunkSize-size%chunkSize, to-size)
+		if _, err := f.writeAt(zeros[:n], size); err != nil {
+			return err
+		}
+
+		size += n
+	}
+
+	return nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, f.pathError("seek", os.ErrClosed)
+	}
+
+	pos := offset
+	switch whence {
+	case io.SeekCurrent:
+		pos += f.position
+	case io.SeekEnd:
+		size, err := f.size()
+		if err != nil {
+			return 0, f.pathError("seek", err)
+		}
+		pos += size
+	}
+
+	if pos < 0 {
+		return 0, f.pathError("seek", syscall.EINVAL)
+	}
+
+	f.position = pos
+	return pos, nil
+}
+
+// Truncate changes the size of the file. Like os.File, it fails with EINVAL
+// if the file is not open for writing.
+func (f *file) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.pathError("truncate", os.ErrClosed)
+	}
+	if size < 0 || !openflag.Writable(f.flag) {
+		return f.pathError("truncate", syscall.EINVAL)
+	}
+
+	cur, err := f.size()
+	if err == nil {
+		switch {
+		case size > cur:
+			err = f.grow(cur, size)
+		case size < cur:
+			err = f.shrink(cur, size)
+		}
+	}
+	if err != nil {
+		return f.pathError("truncate", err)
+	}
+
+	return nil
+}
+
+// shrink truncates the file from size to the given smaller size, sealing
+// again its new last chunk.
+func (f *file) shrink(size, to int64) error {
+	count := chunks(to)
+	if count == 0 {
+		return f.File.Truncate(headerSize)
+	}
+
+	last := count - 1
+	chunk, err := f.readChunk(last, chunks(size))
+	if err != nil {
+		return err
+	}
+
+	chunk = chunk[:to-last*chunkSize]
+	if err := f.writeChunk(last, chunk, true); err != nil {
+		return err
+	}
+
+	return f.File.Truncate(f.chunkOffset(last) + overhead(f.aead) + int64(len(chunk)))
+}
+
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return f.File.Close()
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(fi, f.aead), nil
+}
+
+// ReadDir implements the billy.ReadDirFile interface, if the wrapped file
+// does, reporting the decrypted size of the entries.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := f.File.ReadDir(n)
+	for i, e := range entries {
+		entries[i] = &dirEntry{DirEntry: e, aead: f.aead}
+	}
+
+	return entries, err
+}
+
+// RawFile implements the billy.RawFile interface. It always fails with an
+// error wrapping billy.ErrNotSupported, as the *os.File holds the encrypted
+// contents.
+func (f *file) RawFile() (*os.File, error) {
+	return nil, f.pathError("rawfile", billy.ErrNotSupported)
+}
+
+// pathError returns err wrapped in an *os.PathError for the file, unless it
+// already is one, as the errors of the wrapped file are.
+func (f *file) pathError(op string, err error) error {
+	var perr *os.PathError
+	if errors.As(err, &perr) {
+		return err
+	}
+
+	return &os.PathError{Op: op, Path: f.Name(), Err: err}
+}
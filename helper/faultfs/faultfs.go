@@ -0,0 +1,291 @@
+// Package faultfs provides a billy filesystem wrapper which injects faults
+// into the operations of the filesystem it wraps, such as errors, latency or
+// short writes, to test how code copes with failing filesystems.
+package faultfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/interceptfs"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+)
+
+// ErrNoSpace is the error of a full filesystem, being syscall.ENOSPC on the
+// OSes which define it.
+var ErrNoSpace = errno.ENOSPC
+
+// Fault describes a fault to inject into the operations it matches.
+type Fault struct {
+	// Op is the name of the operations to match, as reported by
+	// interceptfs.Op, such as "open", "write" or "rename". Empty matches
+	// every operation.
+	Op string
+	// Path is a pattern matched with filepath.Match against the path of the
+	// operations, relative to the root of the filesystem, and against the
+	// destination of renames. Empty matches every path.
+	Path string
+	// Mutating restricts the fault to the operations which modify the
+	// filesystem.
+	Mutating bool
+	// Nth restricts the fault to the Nth operation it matches, counting from
+	// one. Zero applies it to all of them.
+	Nth int
+
+	// Err is the error the matched operations fail with, without being
+	// performed. It is returned wrapped in an *os.PathError.
+	Err error
+	// Latency delays the matched operations.
+	Latency time.Duration
+	// ShortWrite makes the matched writes only write half of their data,
+	// and fail with io.ErrShortWrite.
+	ShortWrite bool
+}
+
+// FailNth returns a fault failing the nth operation named op with err.
+func FailNth(op string, n int, err error) Fault {
+	return Fault{Op: op, Nth: n, Err: err}
+}
+
+// NoSpace returns a fault failing every operation modifying the paths
+// matching pattern with ErrNoSpace.
+func NoSpace(pattern string) Fault {
+	return Fault{Path: pattern, Mutating: true, Err: ErrNoSpace}
+}
+
+// faultSet holds the faults shared by a filesystem and its chroots, along with
+// the number of operations each of them matched.
+type faultSet struct {
+	mu      sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	Fault
+	matched int
+}
+
+// match returns the faults applying to op, ignoring the short writes unless
+// write is true, and the short writes only otherwise.
+func (s *faultSet) match(op interceptfs.Op, write bool) []Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []Fault
+	for _, e := range s.entries {
+		if e.ShortWrite != write || !e.matches(op) {
+			continue
+		}
+
+		e.matched++
+		if e.Nth == 0 || e.matched == e.Nth {
+			matched = append(matched, e.Fault)
+		}
+	}
+
+	return matched
+}
+
+func (e *entry) matches(op interceptfs.Op) bool {
+	if e.Op != "" && e.Op != op.Name {
+		return false
+	}
+	if e.Mutating && !op.Mutating {
+		return false
+	}
+	if e.Path == "" {
+		return true
+	}
+
+	if op.Name == "rename" && e.matchPath(op.NewPath) {
+		return true
+	}
+
+	return e.matchPath(op.Path)
+}
+
+func (e *entry) matchPath(path string) bool {
+	path = strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator))
+	ok, _ := filepath.Match(filepath.FromSlash(e.Path), path)
+	return ok
+}
+
+// before is the interceptfs hook applying the latency and errors of the
+// faults matching op.
+func (s *faultSet) before(op interceptfs.Op) error {
+	var err error
+	for _, f := range s.match(op, false) {
+		time.Sleep(f.Latency)
+		if err == nil && f.Err != nil {
+			err = &os.PathError{Op: op.Name, Path: op.Path, Err: f.Err}
+		}
+	}
+
+	return err
+}
+
+// FaultFS is a helper that injects faults into the operations made over any
+// billy.Filesystem, including the ones made on the files it opens.
+type FaultFS struct {
+	billy.Filesystem
+	faults *faultSet
+}
+
+// New creates a new filesystem wrapping up fs which injects the given faults.
+func New(fs billy.Filesystem, faults ...Fault) *FaultFS {
+	s := &faultSet{}
+	for _, f := range faults {
+		s.entries = append(s.entries, &entry{Fault: f})
+	}
+
+	return &FaultFS{
+		Filesystem: interceptfs.New(fs, interceptfs.Hooks{Before: s.before}),
+		faults:     s,
+	}
+}
+
+// Layers implements billy.Composite.
+func (h *FaultFS) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *FaultFS) String() string {
+	return "faultfs"
+}
+
+// Inject adds f to the faults injected from now on, including into the
+// operations of the chroots of the filesystem.
+func (h *FaultFS) Inject(f Fault) {
+	h.faults.mu.Lock()
+	defer h.faults.mu.Unlock()
+
+	h.faults.entries = append(h.faults.entries, &entry{Fault: f})
+}
+
+// Reset removes all the faults, so operations are performed normally again.
+func (h *FaultFS) Reset() {
+	h.faults.mu.Lock()
+	defer h.faults.mu.Unlock()
+
+	h.faults.entries = nil
+}
+
+func (h *FaultFS) Create(filename string) (billy.File, error) {
+	return h.wrapFile(h.Filesystem.Create(filename))
+}
+
+func (h *FaultFS) Open(filename string) (billy.File, error) {
+	return h.wrapFile(h.Filesystem.Open(filename))
+}
+
+func (h *FaultFS) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	return h.wrapFile(h.Filesystem.OpenFile(filename, flag, perm))
+}
+
+func (h *FaultFS) TempFile(dir, prefix string) (billy.File, error) {
+	return h.wrapFile(h.Filesystem.TempFile(dir, prefix))
+}
+
+func (h *FaultFS) CreateTemp(dir, pattern string) (billy.File, error) {
+	return h.wrapFile(h.Filesystem.CreateTemp(dir, pattern))
+}
+
+// Chroot returns a new FaultFS over the chroot of the wrapped filesystem,
+// sharing the faults of h. Their paths are matched relative to the new root.
+func (h *FaultFS) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FaultFS{Filesystem: fs, faults: h.faults}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *FaultFS) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *FaultFS) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *FaultFS) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *FaultFS) Chmod(name string, mode fs.FileMode) error {
+	return h.Filesystem.(billy.Change).Chmod(name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *FaultFS) Lchown(name string, uid, gid int) error {
+	return h.Filesystem.(billy.Change).Lchown(name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *FaultFS) Chown(name string, uid, gid int) error {
+	return h.Filesystem.(billy.Change).Chown(name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *FaultFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return h.Filesystem.(billy.Change).Chtimes(name, atime, mtime)
+}
+
+func (h *FaultFS) wrapFile(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), faults: h.faults}, nil
+}
+
+// file applies the short write faults to the writes of the wrapped file.
+type file struct {
+	*wrappedfile.File
+	faults *faultSet
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return f.write(p, f.File.Write)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return f.write(p, func(p []byte) (int, error) {
+		return f.File.WriteAt(p, off)
+	})
+}
+
+// RawFile implements the billy.RawFile interface. It always fails with an
+// error wrapping billy.ErrNotSupported, as the writes made on the *os.File
+// could not be hit by the faults.
+func (f *file) RawFile() (*os.File, error) {
+	return nil, &os.PathError{Op: "rawfile", Path: f.Name(), Err: billy.ErrNotSupported}
+}
+
+// write writes p with fn, or only half of it if a short write fault matches.
+func (f *file) write(p []byte, fn func([]byte) (int, error)) (int, error) {
+	op := interceptfs.Op{Name: "write", Path: f.Name(), Mutating: true}
+	if len(f.faults.match(op, true)) == 0 {
+		return fn(p)
+	}
+
+	n, err := fn(p[:len(p)/2])
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+
+	return n, err
+}
@@ -0,0 +1,126 @@
+package faultfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+var errInjected = errors.New("injected")
+
+func TestFailNth(t *testing.T) {
+	fs := New(memfs.New(), FailNth("open", 2, errInjected))
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	_, err := util.ReadFile(fs, "foo")
+	assert.ErrorIs(t, err, errInjected)
+
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "open", perr.Op)
+	assert.Equal(t, "foo", perr.Path)
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+}
+
+func TestNoSpace(t *testing.T) {
+	fs := New(memfs.New(), NoSpace("full/*"))
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.MkdirAll("full", 0o755))
+
+	err := util.WriteFile(fs, "full/foo", []byte("foo"), 0o644)
+	assert.ErrorIs(t, err, ErrNoSpace)
+
+	err = fs.Rename("foo", "/full/foo")
+	assert.ErrorIs(t, err, ErrNoSpace)
+
+	_, err = fs.Stat("full/foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestShortWrite(t *testing.T) {
+	fs := New(memfs.New(), Fault{Op: "write", Path: "foo", ShortWrite: true})
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+
+	n, err := f.Write([]byte("abcd"))
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, 2, n)
+
+	n, err = f.WriteAt([]byte("ef"), 0)
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, 1, n)
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "eb", string(content))
+
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("abcd"), 0o644))
+}
+
+func TestShortWriteCopy(t *testing.T) {
+	fs := New(osfs.New(t.TempDir()), Fault{Path: "dst", ShortWrite: true})
+	require.NoError(t, util.WriteFile(fs, "src", []byte("abcd"), 0o644))
+
+	src, err := fs.Open("src")
+	require.NoError(t, err)
+	defer src.Close()
+	dst, err := fs.Create("dst")
+	require.NoError(t, err)
+	defer dst.Close()
+
+	_, err = util.Copy(dst, src)
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+}
+
+func TestLatency(t *testing.T) {
+	fs := New(memfs.New(), Fault{Op: "stat", Latency: 20 * time.Millisecond})
+
+	start := time.Now()
+	_, err := fs.Stat("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestInjectReset(t *testing.T) {
+	fs := New(memfs.New())
+	require.NoError(t, fs.MkdirAll("dir", 0o755))
+
+	sub, err := fs.Chroot("dir")
+	require.NoError(t, err)
+
+	fs.Inject(Fault{Op: "remove", Err: errInjected})
+	assert.ErrorIs(t, fs.Remove("dir"), errInjected)
+
+	err = util.WriteFile(sub, "foo", nil, 0o644)
+	require.NoError(t, err)
+	assert.ErrorIs(t, sub.Remove("foo"), errInjected)
+
+	fs.Reset()
+	require.NoError(t, sub.Remove("foo"))
+	require.NoError(t, fs.Remove("dir"))
+}
+
+func TestChange(t *testing.T) {
+	fs := New(memfs.New(), Fault{Op: "chmod", Err: errInjected})
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+
+	var c billy.Change = fs
+	assert.ErrorIs(t, c.Chmod("foo", 0o600), errInjected)
+	require.NoError(t, c.Chown("foo", 1, 1))
+}
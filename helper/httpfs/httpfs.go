@@ -0,0 +1,152 @@
+// Package httpfs provides an adapter from billy.Filesystem to the
+// http.FileSystem interface of the net/http package, so that billy
+// filesystems can be served with http.FileServer.
+package httpfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/polyfill"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// New returns an http.FileSystem serving the files of fs. Unlike going
+// through iofs and http.FS, the modification times reported by fs are kept,
+// and directories are listed incrementally when fs supports it.
+func New(fs billy.Basic) http.FileSystem {
+	return &adapter{fs: polyfill.New(fs)}
+}
+
+type adapter struct {
+	fs billy.Filesystem
+}
+
+// Open opens the named file, implementing http.FileSystem. As with http.Dir,
+// name is a slash-separated path, and names holding the separator of the OS
+// are rejected.
+func (a *adapter) Open(name string) (http.File, error) {
+	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	name = filepath.FromSlash(path.Clean("/" + name))
+	fi, err := a.fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return &dir{fs: a.fs, name: name, info: fi}, nil
+	}
+
+	f, err := a.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, info: fi}, nil
+}
+
+type file struct {
+	billy.File
+	info fs.FileInfo
+}
+
+// Readdir implements http.File, failing as f is not a directory.
+func (f *file) Readdir(_ int) ([]fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.Name(), Err: syscall.ENOTDIR}
+}
+
+// Stat implements http.File, returning the FileInfo read when f was opened.
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// dir is an open directory. Its entries are read through a billy.DirIter
+// once Readdir is first called.
+type dir struct {
+	fs   billy.Filesystem
+	name string
+	info fs.FileInfo
+	iter billy.DirIter
+}
+
+// Close implements http.File, releasing the iterator of the directory.
+func (d *dir) Close() error {
+	if d.iter == nil {
+		return nil
+	}
+
+	err := d.iter.Close()
+	d.iter = nil
+	return err
+}
+
+// Read implements http.File, failing as directories cannot be read.
+func (d *dir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+// Seek implements http.File. Only seeking to the start is supported, which
+// restarts the listing of the directory, as with os.File.
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, &fs.PathError{Op: "seek", Path: d.name, Err: fs.ErrInvalid}
+	}
+
+	return 0, d.Close()
+}
+
+// Readdir implements http.File, with the semantics of os.File.Readdir: if
+// count > 0, at most count entries are returned, and io.EOF once there are
+// no more of them. Otherwise, all the remaining entries are returned.
+func (d *dir) Readdir(count int) ([]fs.FileInfo, error) {
+	if d.iter == nil {
+		iter, err := util.OpenDir(d.fs, d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.iter = iter
+	}
+
+	infos := []fs.FileInfo{}
+	for count <= 0 || len(infos) < count {
+		e, err := d.iter.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return infos, err
+		}
+
+		fi, err := e.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed since it was listed.
+			continue
+		}
+		if err != nil {
+			return infos, err
+		}
+
+		infos = append(infos, fi)
+	}
+
+	if count > 0 && len(infos) == 0 {
+		return nil, io.EOF
+	}
+
+	return infos, nil
+}
+
+// Stat implements http.File, returning the FileInfo read when d was opened.
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
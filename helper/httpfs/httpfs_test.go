@@ -0,0 +1,124 @@
+package httpfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func setup(t *testing.T) (billy.Filesystem, *httptest.Server) {
+	t.Helper()
+
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo.txt", []byte("hello, world"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "dir/bar.txt", []byte("bar"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "dir/qux.txt", []byte("qux"), 0o644))
+
+	srv := httptest.NewServer(http.FileServer(New(fs)))
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func get(t *testing.T, url string, header ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestServeFile(t *testing.T) {
+	fs, srv := setup(t)
+
+	fi, err := fs.Stat("foo.txt")
+	require.NoError(t, err)
+	mtime := fi.ModTime().UTC()
+
+	resp, body := get(t, srv.URL+"/foo.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello, world", body)
+	assert.Equal(t, mtime.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+
+	resp, _ = get(t, srv.URL+"/foo.txt", "If-Modified-Since", mtime.Format(http.TimeFormat))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = get(t, srv.URL+"/foo.txt", "Range", "bytes=7-")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "world", body)
+
+	resp, _ = get(t, srv.URL+"/missing")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServeDir(t *testing.T) {
+	_, srv := setup(t)
+
+	resp, body := get(t, srv.URL+"/dir/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `<a href="bar.txt">bar.txt</a>`)
+	assert.Contains(t, body, `<a href="qux.txt">qux.txt</a>`)
+}
+
+func TestReaddir(t *testing.T) {
+	fs := memfs.New()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, util.WriteFile(fs, fmt.Sprintf("dir/file%d", i), nil, 0o644))
+	}
+
+	d, err := New(fs).Open("/dir")
+	require.NoError(t, err)
+	defer d.Close()
+
+	var names []string
+	for {
+		infos, err := d.Readdir(2)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(infos), 2)
+		for _, fi := range infos {
+			names = append(names, fi.Name())
+		}
+	}
+	assert.Len(t, names, 5)
+
+	_, err = d.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	infos, err := d.Readdir(0)
+	require.NoError(t, err)
+	assert.Len(t, infos, 5)
+
+	_, err = d.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	f, err := New(fs).Open("dir/file0")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Readdir(0)
+	assert.Error(t, err)
+
+	_, err = New(fs).Open("missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,501 @@
+// Package interceptfs provides a billy filesystem wrapper which calls a set of
+// hooks around every operation, to audit them, to report the changes they
+// would make without performing them, or to inject faults.
+package interceptfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+)
+
+// ErrSkip can be returned by a Before hook to skip an operation which
+// modifies the filesystem. The operation then succeeds without being
+// performed. Operations which don't modify the filesystem can not be skipped
+// and fail with ErrSkip instead.
+var ErrSkip = errors.New("operation skipped")
+
+// Op describes an operation intercepted by the hooks.
+type Op struct {
+	// Name is the name of the operation, as used in the errors returned by
+	// the filesystem, such as "open", "rename" or "write".
+	Name string
+	// Path is the path the operation applies to. The operations on files
+	// report the name of the file.
+	Path string
+	// NewPath is the destination of a rename, or the target of a symlink.
+	NewPath string
+	// Mutating reports whether the operation modifies the filesystem. Opens
+	// are mutating when they can create or write to the file.
+	Mutating bool
+}
+
+// Hooks are the functions called around every operation. Both are optional.
+type Hooks struct {
+	// Before is called before every operation. If it returns an error other
+	// than ErrSkip, the operation is not performed and fails with it.
+	Before func(op Op) error
+	// After is called after every performed operation with the error it
+	// returned, io.EOF included, and returns the error to report instead.
+	After func(op Op, err error) error
+}
+
+// DryRun returns the hooks of a dry run, which skip every operation
+// modifying the filesystem after passing it to report. Skipped opens return
+// an empty file which discards its writes.
+func DryRun(report func(op Op)) Hooks {
+	return Hooks{
+		Before: func(op Op) error {
+			if !op.Mutating {
+				return nil
+			}
+
+			report(op)
+			return ErrSkip
+		},
+	}
+}
+
+// before calls the Before hook, reporting whether op must be skipped.
+func (h Hooks) before(op Op) (bool, error) {
+	if h.Before == nil {
+		return false, nil
+	}
+
+	err := h.Before(op)
+	if op.Mutating && errors.Is(err, ErrSkip) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// after calls the After hook, returning the error to report for op.
+func (h Hooks) after(op Op, err error) error {
+	if h.After == nil {
+		return err
+	}
+
+	return h.After(op, err)
+}
+
+// call performs op with fn, between the hooks.
+func (h Hooks) call(op Op, fn func() error) error {
+	skip, err := h.before(op)
+	if skip || err != nil {
+		return err
+	}
+
+	return h.after(op, fn())
+}
+
+// Intercept is a helper that calls a set of Hooks around every operation made
+// over any billy.Filesystem, including the ones made on the files it opens.
+type Intercept struct {
+	underlying billy.Filesystem
+	hooks      Hooks
+}
+
+// New creates a new filesystem wrapping up fs which calls hooks around every
+// operation. Wrapping the result again composes the hooks, the outermost
+// being called first.
+func New(fs billy.Filesystem, hooks Hooks) billy.Filesystem {
+	return &Intercept{underlying: fs, hooks: hooks}
+}
+
+// Layers implements billy.Composite.
+func (h *Intercept) Layers() []billy.Basic {
+	return []billy.Basic{h.underlying}
+}
+
+func (h *Intercept) String() string {
+	return "interceptfs"
+}
+
+func (h *Intercept) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Intercept) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (h *Intercept) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	op := Op{
+		Name:     "open",
+		Path:     filename,
+		Mutating: openflag.Writable(flag) || openflag.Create(flag),
+	}
+
+	return h.open(op, func() (billy.File, error) {
+		return h.underlying.OpenFile(filename, flag, perm)
+	})
+}
+
+func (h *Intercept) Stat(filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := h.hooks.call(Op{Name: "stat", Path: filename}, func() (err error) {
+		fi, err = h.underlying.Stat(filename)
+		return err
+	})
+
+	return fi, err
+}
+
+func (h *Intercept) Rename(from, to string) error {
+	return h.hooks.call(Op{Name: "rename", Path: from, NewPath: to, Mutating: true}, func() error {
+		return h.underlying.Rename(from, to)
+	})
+}
+
+func (h *Intercept) Remove(filename string) error {
+	return h.hooks.call(Op{Name: "remove", Path: filename, Mutating: true}, func() error {
+		return h.underlying.Remove(filename)
+	})
+}
+
+func (h *Intercept) Join(elem ...string) string {
+	return h.underlying.Join(elem...)
+}
+
+// TempFile creates a temp file like the wrapped filesystem. When skipped, the
+// returned file is named after dir and prefix.
+func (h *Intercept) TempFile(dir, prefix string) (billy.File, error) {
+	op := Op{Name: "tempfile", Path: h.underlying.Join(dir, prefix), Mutating: true}
+	return h.open(op, func() (billy.File, error) {
+		return h.underlying.TempFile(dir, prefix)
+	})
+}
+
+// TempDir creates a temp dir like the wrapped filesystem. When skipped, the
+// returned name is made of dir and prefix.
+func (h *Intercept) TempDir(dir, prefix string) (string, error) {
+	op := Op{Name: "tempdir", Path: h.underlying.Join(dir, prefix), Mutating: true}
+	skip, err := h.hooks.before(op)
+	if err != nil {
+		return "", err
+	}
+	if skip {
+		return op.Path, nil
+	}
+
+	name, err := h.underlying.TempDir(dir, prefix)
+	return name, h.hooks.after(op, err)
+}
+
+// CreateTemp creates a temp file like the wrapped filesystem. When skipped,
+// the returned file is named after dir and pattern.
+func (h *Intercept) CreateTemp(dir, pattern string) (billy.File, error) {
+	op := Op{Name: "createtemp", Path: h.underlying.Join(dir, pattern), Mutating: true}
+	return h.open(op, func() (billy.File, error) {
+		return h.underlying.CreateTemp(dir, pattern)
+	})
+}
+
+// MkdirTemp creates a temp dir like the wrapped filesystem. When skipped,
+// the returned name is made of dir and pattern.
+func (h *Intercept) MkdirTemp(dir, pattern string) (string, error) {
+	op := Op{Name: "mkdirtemp", Path: h.underlying.Join(dir, pattern), Mutating: true}
+	skip, err := h.hooks.before(op)
+	if err != nil {
+		return "", err
+	}
+	if skip {
+		return op.Path, nil
+	}
+
+	name, err := h.underlying.MkdirTemp(dir, pattern)
+	return name, h.hooks.after(op, err)
+}
+
+func (h *Intercept) ReadDir(path string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	err := h.hooks.call(Op{Name: "readdir", Path: path}, func() (err error) {
+		infos, err = h.underlying.ReadDir(path)
+		return err
+	})
+
+	return infos, err
+}
+
+func (h *Intercept) MkdirAll(filename string, perm fs.FileMode) error {
+	return h.hooks.call(Op{Name: "mkdir", Path: filename, Mutating: true}, func() error {
+		return h.underlying.MkdirAll(filename, perm)
+	})
+}
+
+func (h *Intercept) Lstat(filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := h.hooks.call(Op{Name: "lstat", Path: filename}, func() (err error) {
+		fi, err = h.underlying.Lstat(filename)
+		return err
+	})
+
+	return fi, err
+}
+
+func (h *Intercept) Symlink(target, link string) error {
+	return h.hooks.call(Op{Name: "symlink", Path: link, NewPath: target, Mutating: true}, func() error {
+		return h.underlying.Symlink(target, link)
+	})
+}
+
+func (h *Intercept) Readlink(link string) (string, error) {
+	var target string
+	err := h.hooks.call(Op{Name: "readlink", Path: link}, func() (err error) {
+		target, err = h.underlying.Readlink(link)
+		return err
+	})
+
+	return target, err
+}
+
+// Chroot returns a new Intercept filesystem over the result of the Chroot
+// method of the wrapped filesystem, calling the same hooks.
+func (h *Intercept) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.underlying.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(fs, h.hooks), nil
+}
+
+func (h *Intercept) Root() string {
+	return h.underlying.Root()
+}
+
+// Capabilities implements the Capable interface.
+func (h *Intercept) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.underlying))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Intercept) Supports(f billy.Feature) bool {
+	return billy.Supports(h.underlying, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Intercept) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.underlying)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Intercept) Chmod(name string, mode fs.FileMode) error {
+	c, ok := h.underlying.(billy.Change)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return h.hooks.call(Op{Name: "chmod", Path: name, Mutating: true}, func() error {
+		return c.Chmod(name, mode)
+	})
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Intercept) Lchown(name string, uid, gid int) error {
+	c, ok := h.underlying.(billy.Change)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return h.hooks.call(Op{Name: "lchown", Path: name, Mutating: true}, func() error {
+		return c.Lchown(name, uid, gid)
+	})
+}
+
+// Chown implements the billy.Change interface.
+func (h *Intercept) Chown(name string, uid, gid int) error {
+	c, ok := h.underlying.(billy.Change)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return h.hooks.call(Op{Name: "chown", Path: name, Mutating: true}, func() error {
+		return c.Chown(name, uid, gid)
+	})
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Intercept) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	c, ok := h.underlying.(billy.Change)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return h.hooks.call(Op{Name: "chtimes", Path: name, Mutating: true}, func() error {
+		return c.Chtimes(name, atime, mtime)
+	})
+}
+
+// open performs op with fn, wrapping the file it returns. When op is skipped,
+// an empty file named after its path is returned instead. The file is closed
+// if the After hook reports an error.
+func (h *Intercept) open(op Op, fn func() (billy.File, error)) (billy.File, error) {
+	skip, err := h.hooks.before(op)
+	if err != nil {
+		return nil, err
+	}
+	if skip {
+		return &file{File: wrappedfile.Wrap(&discardFile{name: op.Path}), hooks: h.hooks}, nil
+	}
+
+	f, err := fn()
+	if err = h.hooks.after(op, err); err != nil {
+		if f != nil {
+			_ = f.Close()
+		}
+
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), hooks: h.hooks}, nil
+}
+
+// file calls the hooks around every operation on the wrapped file but
+// Name, Seek, Stat and the locks.
+type file struct {
+	*wrappedfile.File
+	hooks Hooks
+}
+
+func (f *file) op(name string, mutating bool) Op {
+	return Op{Name: name, Path: f.Name(), Mutating: mutating}
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	var n int
+	err := f.hooks.call(f.op("read", false), func() (err error) {
+		n, err = f.File.Read(p)
+		return err
+	})
+
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	err := f.hooks.call(f.op("read", false), func() (err error) {
+		n, err = f.File.ReadAt(p, off)
+		return err
+	})
+
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return f.write(p, f.File.Write)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return f.write(p, func(p []byte) (int, error) {
+		return f.File.WriteAt(p, off)
+	})
+}
+
+// write performs a write of p with fn, between the hooks. When skipped, p is
+// reported as written.
+func (f *file) write(p []byte, fn func([]byte) (int, error)) (int, error) {
+	op := f.op("write", true)
+	skip, err := f.hooks.before(op)
+	if err != nil {
+		return 0, err
+	}
+	if skip {
+		return len(p), nil
+	}
+
+	n, err := fn(p)
+	return n, f.hooks.after(op, err)
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.hooks.call(f.op("truncate", true), func() error {
+		return f.File.Truncate(size)
+	})
+}
+
+func (f *file) Sync() error {
+	return f.hooks.call(f.op("sync", false), f.File.Sync)
+}
+
+func (f *file) Close() error {
+	return f.hooks.call(f.op("close", false), f.File.Close)
+}
+
+// Chmod implements the billy.FileChange interface, if the wrapped file does.
+func (f *file) Chmod(mode fs.FileMode) error {
+	return f.hooks.call(f.op("chmod", true), func() error {
+		return f.File.Chmod(mode)
+	})
+}
+
+// Chtimes implements the billy.FileChange interface, if the wrapped file
+// does.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return f.hooks.call(f.op("chtimes", true), func() error {
+		return f.File.Chtimes(atime, mtime)
+	})
+}
+
+// ReadDir implements the billy.ReadDirFile interface, if the wrapped file
+// does.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	err := f.hooks.call(f.op("readdir", false), func() (err error) {
+		entries, err = f.File.ReadDir(n)
+		return err
+	})
+
+	return entries, err
+}
+
+// RawFile implements the billy.RawFile interface, if the wrapped file does.
+// As the operations made on the *os.File can't be intercepted, getting it is
+// a mutating operation, and fails with an error wrapping
+// billy.ErrNotSupported when skipped.
+func (f *file) RawFile() (*os.File, error) {
+	op := f.op("rawfile", true)
+	skip, err := f.hooks.before(op)
+	if err != nil {
+		return nil, err
+	}
+	if skip {
+		return nil, &os.PathError{Op: op.Name, Path: op.Path, Err: billy.ErrNotSupported}
+	}
+
+	raw, err := f.File.RawFile()
+	return raw, f.hooks.after(op, err)
+}
+
+// discardFile is the empty file returned by skipped opens. It discards its
+// writes, and does not exist for Stat.
+type discardFile struct {
+	name string
+}
+
+func (f *discardFile) Name() string                           { return f.name }
+func (f *discardFile) OpenedPath() string                     { return f.name }
+func (f *discardFile) Read([]byte) (int, error)               { return 0, io.EOF }
+func (f *discardFile) ReadAt([]byte, int64) (int, error)      { return 0, io.EOF }
+func (f *discardFile) Write(p []byte) (int, error)            { return len(p), nil }
+func (f *discardFile) WriteAt(p []byte, _ int64) (int, error) { return len(p), nil }
+func (f *discardFile) Seek(int64, int) (int64, error)         { return 0, nil }
+func (f *discardFile) Truncate(int64) error                   { return nil }
+func (f *discardFile) Sync() error                            { return nil }
+func (f *discardFile) Lock() error                            { return nil }
+func (f *discardFile) Unlock() error                          { return nil }
+func (f *discardFile) Close() error                           { return nil }
+
+func (f *discardFile) Stat() (os.FileInfo, error) {
+	return nil, &os.PathError{Op: "stat", Path: f.name, Err: os.ErrNotExist}
+}
+
+var _ billy.File = (*discardFile)(nil)
@@ -0,0 +1,201 @@
+package interceptfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestAudit(t *testing.T) {
+	var ops []string
+	fs := New(memfs.New(), Hooks{
+		Before: func(op Op) error {
+			ops = append(ops, "before "+op.Name+" "+op.Path)
+			return nil
+		},
+		After: func(op Op, err error) error {
+			if err != nil && !errors.Is(err, io.EOF) {
+				ops = append(ops, "failed "+op.Name+" "+op.Path)
+			}
+			return err
+		},
+	})
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.Rename("foo", "bar"))
+	_, err := fs.Stat("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Equal(t, []string{
+		"before open foo",
+		"before write foo",
+		"before close foo",
+		"before rename foo",
+		"before stat foo",
+		"failed stat foo",
+	}, ops)
+}
+
+func TestFaultInjection(t *testing.T) {
+	errDiskFull := errors.New("disk full")
+	fs := New(memfs.New(), Hooks{
+		Before: func(op Op) error {
+			if op.Name == "write" {
+				return errDiskFull
+			}
+			return nil
+		},
+	})
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	n, err := f.Write([]byte("foo"))
+	assert.ErrorIs(t, err, errDiskFull)
+	assert.Equal(t, 0, n)
+	require.NoError(t, f.Close())
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), fi.Size())
+}
+
+func TestAfterReplacesError(t *testing.T) {
+	errInjected := errors.New("injected")
+	var closed bool
+	underlying := memfs.New()
+	fs := New(underlying, Hooks{
+		After: func(op Op, err error) error {
+			switch op.Name {
+			case "open":
+				return errInjected
+			case "close":
+				closed = true
+			}
+			return err
+		},
+	})
+
+	_, err := fs.Create("foo")
+	assert.ErrorIs(t, err, errInjected)
+	assert.False(t, closed, "the file is closed without calling the hooks")
+
+	_, err = underlying.Stat("foo")
+	require.NoError(t, err)
+}
+
+func TestDryRun(t *testing.T) {
+	underlying := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "foo", []byte("foo"), 0o644))
+
+	var mutations []Op
+	fs := New(underlying, DryRun(func(op Op) {
+		mutations = append(mutations, op)
+	}))
+
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+	require.NoError(t, fs.Rename("foo", "qux"))
+	require.NoError(t, fs.MkdirAll("dir", 0o755))
+	require.NoError(t, fs.Symlink("foo", "link"))
+	require.NoError(t, util.RemoveAll(fs, "foo"))
+
+	name, err := fs.TempDir("tmp", "pre")
+	require.NoError(t, err)
+	assert.Equal(t, fs.Join("tmp", "pre"), name)
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	names, err := util.ReadDirNames(underlying, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, names)
+
+	assert.Equal(t, []Op{
+		{Name: "open", Path: "bar", Mutating: true},
+		{Name: "write", Path: "bar", Mutating: true},
+		{Name: "rename", Path: "foo", NewPath: "qux", Mutating: true},
+		{Name: "mkdir", Path: "dir", Mutating: true},
+		{Name: "symlink", Path: "link", NewPath: "foo", Mutating: true},
+		{Name: "remove", Path: "foo", Mutating: true},
+		{Name: "tempdir", Path: fs.Join("tmp", "pre"), Mutating: true},
+	}, mutations)
+}
+
+func TestSkipNotMutating(t *testing.T) {
+	fs := New(memfs.New(), Hooks{
+		Before: func(Op) error {
+			return ErrSkip
+		},
+	})
+
+	_, err := fs.Stat("foo")
+	assert.ErrorIs(t, err, ErrSkip)
+
+	_, err = fs.Open("foo")
+	assert.ErrorIs(t, err, ErrSkip)
+}
+
+func TestDiscardFile(t *testing.T) {
+	fs := New(memfs.New(), DryRun(func(Op) {}))
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", f.Name())
+
+	n, err := f.WriteAt([]byte("foo"), 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	_, err = f.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = f.Stat()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	require.NoError(t, f.Close())
+}
+
+func TestChroot(t *testing.T) {
+	var paths []string
+	fs := New(memfs.New(), Hooks{
+		Before: func(op Op) error {
+			paths = append(paths, op.Path)
+			return nil
+		},
+	})
+
+	sub, err := fs.Chroot("dir")
+	require.NoError(t, err)
+	require.NoError(t, sub.MkdirAll("foo", 0o755))
+	assert.Equal(t, []string{"foo"}, paths)
+
+	_, err = fs.Stat("dir/foo")
+	require.NoError(t, err)
+}
+
+func TestChange(t *testing.T) {
+	var ops []string
+	fs := New(memfs.New(), Hooks{
+		Before: func(op Op) error {
+			ops = append(ops, op.Name)
+			return nil
+		},
+	})
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	ops = nil
+
+	c := fs.(billy.Change)
+	require.NoError(t, c.Chmod("foo", 0o600))
+	require.NoError(t, c.Chown("foo", 1, 1))
+	assert.Equal(t, []string{"chmod", "chown"}, ops)
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode())
+}
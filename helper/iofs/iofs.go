@@ -3,12 +3,16 @@
 package iofs
 
 import (
+	"errors"
 	"io"
 	"io/fs"
 	"path/filepath"
+	"syscall"
 
 	billyfs "github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
 	"github.com/go-git/go-billy/v6/helper/polyfill"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 // Wrap adapts a billy.Filesystem to a io.fs.FS.
@@ -25,6 +29,7 @@ var _ fs.FS = (*adapterFs)(nil)
 var _ fs.ReadDirFS = (*adapterFs)(nil)
 var _ fs.StatFS = (*adapterFs)(nil)
 var _ fs.ReadFileFS = (*adapterFs)(nil)
+var _ fs.SubFS = (*adapterFs)(nil)
 
 // TODO: implement fs.GlobFS, which will be a fair bit more code.
 
@@ -40,11 +45,7 @@ func (a *adapterFs) Open(name string) (fs.File, error) {
 		return nil, err
 	}
 	if stat.IsDir() {
-		entries, err := a.ReadDir(name)
-		if err != nil {
-			return nil, err
-		}
-		return makeDir(stat, entries), nil
+		return &adapterDirFile{fs: a.fs, name: name, info: stat}, nil
 	}
 	file, err := a.fs.Open(name)
 	return &adapterFile{file: file, info: stat}, err
@@ -84,6 +85,28 @@ func (a *adapterFs) ReadFile(name string) ([]byte, error) {
 	return b, err
 }
 
+// Sub returns an FS corresponding to the subtree rooted at dir, implementing
+// fs.SubFS. The Chroot method of the underlying filesystem is used when
+// supported, otherwise the subtree is chrooted with the chroot helper.
+func (a *adapterFs) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return a, nil
+	}
+
+	sub, err := a.fs.Chroot(dir)
+	if errors.Is(err, billyfs.ErrNotSupported) {
+		sub, err = chroot.New(a.fs, dir), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &adapterFs{fs: polyfill.New(sub)}, nil
+}
+
 type adapterFile struct {
 	file billyfs.File
 	info fs.FileInfo
@@ -101,40 +124,94 @@ func (a *adapterFile) Read(b []byte) (int, error) {
 	return a.file.Read(b)
 }
 
+// Seek sets the offset for the next Read, implementing io.Seeker, as required
+// by http.FS to serve ranges.
+func (a *adapterFile) Seek(offset int64, whence int) (int64, error) {
+	return a.file.Seek(offset, whence)
+}
+
+// ReadAt reads bytes from the file at the given offset, implementing
+// io.ReaderAt, as required by archive/zip.
+func (a *adapterFile) ReadAt(b []byte, off int64) (int, error) {
+	return a.file.ReadAt(b, off)
+}
+
 // Stat returns file information, implementing fs.File (returning FileInfo or error).
 func (a *adapterFile) Stat() (fs.FileInfo, error) {
 	return a.info, nil
 }
 
+// adapterDirFile is an open directory. Its entries are not listed until
+// ReadDir is first called, and are then read through a billy.DirIter, so
+// paging through a large directory keeps a bounded number of entries in
+// memory when the underlying filesystem supports it.
 type adapterDirFile struct {
-	adapterFile
-	entries []fs.DirEntry
+	fs   billyfs.Filesystem
+	name string
+	info fs.FileInfo
+	iter billyfs.DirIter
 }
 
 var _ fs.ReadDirFile = (*adapterDirFile)(nil)
 
-func makeDir(stat fs.FileInfo, entries []fs.DirEntry) *adapterDirFile {
-	return &adapterDirFile{
-		adapterFile: adapterFile{info: stat},
-		entries:     entries,
+// Close closes the directory, implementing fs.File (and io.Closer).
+func (a *adapterDirFile) Close() error {
+	if a.iter == nil {
+		return nil
 	}
+
+	err := a.iter.Close()
+	a.iter = nil
+	return err
 }
 
-// Close closes the directory, implementing fs.File (and io.Closer).
-// Subtle: note that this is shadowing adapterFile.Close.
-func (a *adapterDirFile) Close() error {
-	return nil
+// Read implements fs.File, failing as directories cannot be read.
+func (a *adapterDirFile) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: a.name, Err: syscall.EISDIR}
+}
+
+// Seek implements io.Seeker. Only seeking to the start is supported, which
+// restarts the listing of the directory, as with os.File.
+func (a *adapterDirFile) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, &fs.PathError{Op: "seek", Path: a.name, Err: fs.ErrInvalid}
+	}
+
+	return 0, a.Close()
+}
+
+// Stat returns file information, implementing fs.File (returning FileInfo or error).
+func (a *adapterDirFile) Stat() (fs.FileInfo, error) {
+	return a.info, nil
 }
 
 // ReadDir reads the directory contents, implementing fs.ReadDirFile (returning directory listing or error).
 func (a *adapterDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
-	if len(a.entries) == 0 && n > 0 {
+	if a.iter == nil {
+		iter, err := util.OpenDir(a.fs, a.name)
+		if err != nil {
+			return nil, err
+		}
+		a.iter = iter
+	}
+
+	var entries []fs.DirEntry
+	for n <= 0 || len(entries) < n {
+		e, err := a.iter.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+
+	if n > 0 && len(entries) == 0 {
 		return nil, io.EOF
 	}
-	if n <= 0 || n > len(a.entries) {
-		n = len(a.entries)
+	if entries == nil {
+		entries = []fs.DirEntry{}
 	}
-	entries := a.entries[:n]
-	a.entries = a.entries[n:]
 	return entries, nil
 }
@@ -1,7 +1,11 @@
 package iofs
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"path/filepath"
 	"runtime"
@@ -34,6 +38,11 @@ func TestWithFSTest(t *testing.T) {
 		createdFiles = append(createdFiles, filename)
 	}
 
+	if err := memfs.Symlink("foo.txt", "link.txt"); err != nil {
+		t.Fatal(err)
+	}
+	createdFiles = append(createdFiles, "link.txt")
+
 	if runtime.GOOS == "windows" {
 		t.Skip("fstest.TestFS is not yet windows path aware")
 	}
@@ -73,6 +82,130 @@ func TestDeletes(t *testing.T) {
 	}
 }
 
+func TestSub(t *testing.T) {
+	t.Parallel()
+	for name, underlying := range map[string]billyfs.Basic{
+		"chroot":   memfs.New(),
+		"fallback": &basicOnly{memfs.New()},
+	} {
+		makeFile(underlying, t, filepath.Join("dir", "sub", "foo.txt"), "hello, world")
+
+		sub, err := fs.Sub(New(underlying), "dir/sub")
+		if err != nil {
+			t.Fatalf("%s: failed to get sub fs: %v", name, err)
+		}
+		if _, ok := sub.(*adapterFs); !ok {
+			t.Errorf("%s: expected the fs returned by Sub to be an adapter, got %T", name, sub)
+		}
+
+		data, err := fs.ReadFile(sub, "foo.txt")
+		if err != nil {
+			t.Fatalf("%s: failed to read file: %v", name, err)
+		}
+		if string(data) != "hello, world" {
+			t.Errorf("%s: unexpected contents: %q", name, data)
+		}
+
+		if _, err := sub.Open("../sub/foo.txt"); err == nil {
+			t.Errorf("%s: expected error opening a path outside of the sub fs", name)
+		}
+	}
+
+	if _, err := New(memfs.New()).(fs.SubFS).Sub("/dir"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected ErrInvalid for an invalid dir, got %v", err)
+	}
+}
+
+func TestReadDirPaging(t *testing.T) {
+	t.Parallel()
+	memfs := memfs.New()
+	for i := 0; i < 5; i++ {
+		makeFile(memfs, t, filepath.Join("dir", fmt.Sprintf("file%d", i)), "")
+	}
+
+	f, err := New(memfs).Open("dir")
+	if err != nil {
+		t.Fatalf("failed to open dir: %v", err)
+	}
+	defer f.Close()
+
+	dir := f.(fs.ReadDirFile)
+	var names []string
+	for {
+		entries, err := dir.ReadDir(2)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read dir: %v", err)
+		}
+		if len(entries) > 2 {
+			t.Fatalf("expected at most 2 entries, got %d", len(entries))
+		}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) != 5 {
+		t.Errorf("expected 5 entries, got %v", names)
+	}
+
+	if _, err := f.(io.Seeker).Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("failed to rewind dir: %v", err)
+	}
+	entries, err := dir.ReadDir(-1)
+	if err != nil || len(entries) != 5 {
+		t.Errorf("expected 5 entries after rewinding, got %d (%v)", len(entries), err)
+	}
+
+	if _, err := f.Read(make([]byte, 1)); err == nil {
+		t.Errorf("expected error reading a dir")
+	}
+}
+
+func TestSeekAndReadAt(t *testing.T) {
+	t.Parallel()
+	memfs := memfs.New()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("foo.txt")
+	if err != nil {
+		t.Fatalf("failed to create zip entry: %v", err)
+	}
+	if _, err := w.Write([]byte("hello, world")); err != nil {
+		t.Fatalf("failed to write zip entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	makeFile(memfs, t, "archive.zip", buf.String())
+
+	f, err := New(memfs).Open("archive.zip")
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	size, err := f.(io.Seeker).Seek(0, io.SeekEnd)
+	if err != nil || size != int64(buf.Len()) {
+		t.Fatalf("unexpected seek result: %d, %v", size, err)
+	}
+
+	zr, err := zip.NewReader(f.(io.ReaderAt), size)
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "foo.txt" {
+		t.Errorf("unexpected zip entries: %v", zr.File)
+	}
+}
+
+// basicOnly hides every method of the filesystem but those of billy.Basic.
+type basicOnly struct {
+	billyfs.Basic
+}
+
 func makeFile(fs billyfs.Basic, t *testing.T, filename string, contents string) {
 	t.Helper()
 	file, err := fs.Create(filename)
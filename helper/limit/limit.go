@@ -0,0 +1,560 @@
+// Package limit provides a billy filesystem wrapper which enforces limits on
+// the amount of data written, the number of files created and the read and
+// write throughput of any billy.Filesystem.
+package limit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// ErrLimitExceeded is wrapped by all the errors returned when an operation
+// would exceed one of the configured limits.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Error describes an operation rejected because it would exceed a limit.
+type Error struct {
+	Op    string
+	Path  string
+	Limit string
+	Max   int64
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %s limit of %d exceeded", e.Op, e.Path, e.Limit, e.Max)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *Error) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Option configures the limits of a Limit filesystem.
+type Option func(*state)
+
+// WithMaxBytesWritten limits the total number of bytes written to files
+// through the filesystem. Growing a file with Truncate counts as writing.
+func WithMaxBytesWritten(n int64) Option {
+	return func(s *state) {
+		s.maxBytes = n
+	}
+}
+
+// WithMaxFiles limits the number of files created through the filesystem.
+// Removing one of them through the filesystem releases its slot.
+func WithMaxFiles(n int64) Option {
+	return func(s *state) {
+		s.maxFiles = n
+	}
+}
+
+// WithReadRate limits the read throughput to bytesPerSecond, allowing bursts
+// of up to one second worth of data. Reads exceeding the rate are delayed.
+func WithReadRate(bytesPerSecond int64) Option {
+	return func(s *state) {
+		s.read = newBucket(bytesPerSecond)
+	}
+}
+
+// WithWriteRate limits the write throughput to bytesPerSecond, allowing
+// bursts of up to one second worth of data. Writes exceeding the rate are
+// delayed.
+func WithWriteRate(bytesPerSecond int64) Option {
+	return func(s *state) {
+		s.write = newBucket(bytesPerSecond)
+	}
+}
+
+// state is shared by a Limit and all the filesystems returned by its Chroot
+// method, so the limits apply to the whole tree.
+type state struct {
+	maxBytes int64
+	maxFiles int64
+	written  atomic.Int64
+	files    atomic.Int64
+
+	// created holds the paths of the files created, as returned by key, so
+	// that removing them releases their slots.
+	mu      sync.Mutex
+	created map[string]struct{}
+
+	read  *bucket
+	write *bucket
+}
+
+// Limit is a helper that enforces limits on the operations done over any
+// billy.Filesystem. Limits which are not configured are not enforced.
+type Limit struct {
+	billy.Filesystem
+	s *state
+}
+
+// New creates a new filesystem wrapping up fs, which enforces the limits set
+// by the given options.
+func New(fs billy.Filesystem, opts ...Option) billy.Filesystem {
+	s := &state{created: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return &Limit{Filesystem: fs, s: s}
+}
+
+// Layers implements billy.Composite.
+func (h *Limit) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *Limit) String() string {
+	return "limit"
+}
+
+// BytesWritten returns the number of bytes written so far.
+func (h *Limit) BytesWritten() int64 {
+	return h.s.written.Load()
+}
+
+// FilesCreated returns the number of files created so far, not counting the
+// ones removed since.
+func (h *Limit) FilesCreated() int64 {
+	return h.s.files.Load()
+}
+
+func (h *Limit) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Limit) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file. When flag allows creating it, the slot of
+// a new file is reserved first, and the file is created with os.O_EXCL, so
+// that concurrent creates of the same file take a single slot. If it
+// already exists, the slot is released and the file is opened without
+// os.O_CREATE.
+func (h *Limit) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	if !openflag.Create(flag) {
+		return h.wrapFile(h.Filesystem.OpenFile(filename, flag, perm))
+	}
+
+	for {
+		if err := h.s.reserveFile("open", filename); err != nil {
+			// Opening an existing file needs no slot.
+			if flag&os.O_EXCL != 0 {
+				return nil, err
+			}
+
+			f, oerr := h.Filesystem.OpenFile(filename, flag&^os.O_CREATE, perm)
+			if errors.Is(oerr, os.ErrNotExist) {
+				return nil, err
+			}
+
+			return h.wrapFile(f, oerr)
+		}
+
+		f, err := h.Filesystem.OpenFile(filename, flag|os.O_EXCL, perm)
+		if err == nil {
+			h.s.track(h.key(filename))
+			return h.wrapFile(f, nil)
+		}
+
+		h.s.files.Add(-1)
+		if flag&os.O_EXCL != 0 || !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		// The file exists, unless it is removed before being opened, in
+		// which case creating it is tried again.
+		f, err = h.Filesystem.OpenFile(filename, flag&^os.O_CREATE, perm)
+		if !errors.Is(err, os.ErrNotExist) {
+			return h.wrapFile(f, err)
+		}
+
+		// Or it is a dangling symlink, whose target is created with the
+		// original flag, taking a slot which is never released.
+		if _, lerr := h.Filesystem.Lstat(filename); lerr == nil {
+			if err := h.s.reserveFile("open", filename); err != nil {
+				return nil, err
+			}
+
+			f, err := h.Filesystem.OpenFile(filename, flag, perm)
+			if err != nil {
+				h.s.files.Add(-1)
+			}
+
+			return h.wrapFile(f, err)
+		}
+	}
+}
+
+func (h *Limit) wrapFile(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), s: h.s}, nil
+}
+
+func (h *Limit) TempFile(dir, prefix string) (billy.File, error) {
+	return h.createTemp("tempfile", dir, func() (billy.File, error) {
+		return h.Filesystem.TempFile(dir, prefix)
+	})
+}
+
+func (h *Limit) CreateTemp(dir, pattern string) (billy.File, error) {
+	return h.createTemp("createtemp", dir, func() (billy.File, error) {
+		return h.Filesystem.CreateTemp(dir, pattern)
+	})
+}
+
+func (h *Limit) createTemp(op, dir string, create func() (billy.File, error)) (billy.File, error) {
+	if err := h.s.reserveFile(op, dir); err != nil {
+		return nil, err
+	}
+
+	f, err := create()
+	if err != nil {
+		h.s.files.Add(-1)
+		return nil, err
+	}
+
+	h.s.track(h.key(f.Name()))
+	return h.wrapFile(f, nil)
+}
+
+// Remove removes the named file, releasing its slot if it was created
+// through the filesystem.
+func (h *Limit) Remove(filename string) error {
+	key := h.key(filename)
+	if err := h.Filesystem.Remove(filename); err != nil {
+		return err
+	}
+
+	h.s.release(key)
+	return nil
+}
+
+// Rename renames from to to, moving the slots of the files created through
+// the filesystem, and releasing the one of the file replaced, if any.
+func (h *Limit) Rename(from, to string) error {
+	if err := h.Filesystem.Rename(from, to); err != nil {
+		return err
+	}
+
+	h.s.move(h.key(from), h.key(to))
+	return nil
+}
+
+func (h *Limit) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Limit{Filesystem: fs, s: h.s}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Limit) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Limit) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Limit) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (h *Limit) ReadDirNames(path string, n int) ([]string, error) {
+	return util.ReadDirNames(h.Filesystem, path, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (h *Limit) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	return util.ReadDirEntries(h.Filesystem, path)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (h *Limit) OpenDir(path string) (billy.DirIter, error) {
+	return util.OpenDir(h.Filesystem, path)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (h *Limit) SyncDir(path string) error {
+	return util.SyncDir(h.Filesystem, path)
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (h *Limit) RenameNoReplace(from, to string) error {
+	if err := util.RenameNoReplace(h.Filesystem, from, to); err != nil {
+		return err
+	}
+
+	h.s.move(h.key(from), h.key(to))
+	return nil
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (h *Limit) RenameExchange(from, to string) error {
+	if err := util.RenameExchange(h.Filesystem, from, to); err != nil {
+		return err
+	}
+
+	h.s.exchange(h.key(from), h.key(to))
+	return nil
+}
+
+// key returns the key of path in the created files, shared with the chroots
+// of h as it is made from the root of the wrapped filesystem.
+func (h *Limit) key(path string) string {
+	return h.Filesystem.Join(h.Filesystem.Root(), path)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Limit) Chmod(name string, mode fs.FileMode) error {
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Limit) Lchown(name string, uid, gid int) error {
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Limit) Chown(name string, uid, gid int) error {
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Limit) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+func (s *state) reserveFile(op, path string) error {
+	if n := s.files.Add(1); s.maxFiles > 0 && n > s.maxFiles {
+		s.files.Add(-1)
+		return &Error{Op: op, Path: path, Limit: "files created", Max: s.maxFiles}
+	}
+
+	return nil
+}
+
+func (s *state) track(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.created[key] = struct{}{}
+}
+
+func (s *state) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.created[key]; ok {
+		delete(s.created, key)
+		s.files.Add(-1)
+	}
+}
+
+// move moves the created files at from or under it to to, releasing the
+// slot of the created file at to, replaced by the rename.
+func (s *state) move(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := make(map[string]struct{})
+	for key := range s.created {
+		if rel, ok := under(key, from); ok {
+			delete(s.created, key)
+			moved[to+rel] = struct{}{}
+		}
+	}
+
+	if _, ok := s.created[to]; ok {
+		delete(s.created, to)
+		s.files.Add(-1)
+	}
+
+	for key := range moved {
+		s.created[key] = struct{}{}
+	}
+}
+
+// exchange swaps the created files at or under a and b.
+func (s *state) exchange(a, b string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	swapped := make(map[string]struct{})
+	for key := range s.created {
+		if rel, ok := under(key, a); ok {
+			delete(s.created, key)
+			swapped[b+rel] = struct{}{}
+		} else if rel, ok := under(key, b); ok {
+			delete(s.created, key)
+			swapped[a+rel] = struct{}{}
+		}
+	}
+
+	for key := range swapped {
+		s.created[key] = struct{}{}
+	}
+}
+
+// under reports whether key is dir or a path under it, returning the rest
+// of key.
+func under(key, dir string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, dir)
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return "", false
+	}
+
+	return rest, true
+}
+
+func (s *state) reserveBytes(op, path string, n int64) error {
+	if w := s.written.Add(n); s.maxBytes > 0 && w > s.maxBytes {
+		s.written.Add(-n)
+		return &Error{Op: op, Path: path, Limit: "bytes written", Max: s.maxBytes}
+	}
+
+	return nil
+}
+
+type file struct {
+	*wrappedfile.File
+	s *state
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.s.read.wait(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.s.read.wait(n)
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if err := f.s.reserveBytes("write", f.Name(), int64(len(p))); err != nil {
+		return 0, err
+	}
+
+	f.s.write.wait(len(p))
+	n, err := f.File.Write(p)
+	f.s.written.Add(int64(n - len(p)))
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.s.reserveBytes("write", f.Name(), int64(len(p))); err != nil {
+		return 0, err
+	}
+
+	f.s.write.wait(len(p))
+	n, err := f.File.WriteAt(p, off)
+	f.s.written.Add(int64(n - len(p)))
+	return n, err
+}
+
+func (f *file) Truncate(size int64) error {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return err
+	}
+
+	grow := size - fi.Size()
+	if grow <= 0 {
+		return f.File.Truncate(size)
+	}
+
+	if err := f.s.reserveBytes("truncate", f.Name(), grow); err != nil {
+		return err
+	}
+
+	if err := f.File.Truncate(size); err != nil {
+		f.s.written.Add(-grow)
+		return err
+	}
+
+	return nil
+}
+
+// RawFile implements the billy.RawFile interface. It always fails with an
+// error wrapping billy.ErrNotSupported, as the reads and writes made on the
+// *os.File could not be limited.
+func (f *file) RawFile() (*os.File, error) {
+	return nil, &os.PathError{Op: "rawfile", Path: f.Name(), Err: billy.ErrNotSupported}
+}
+
+// bucket is a token bucket holding up to one second worth of tokens. Taking
+// more tokens than available makes the balance negative, and the caller
+// waits until it is paid back.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newBucket(bytesPerSecond int64) *bucket {
+	return &bucket{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// wait takes n tokens from the bucket, blocking until they are available.
+// A nil bucket does not limit anything.
+func (b *bucket) wait(n int) {
+	if b == nil || n <= 0 || b.rate <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= float64(n)
+
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if d > 0 {
+		b.sleep(d)
+	}
+}
@@ -0,0 +1,241 @@
+package limit
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestMaxBytesWritten(t *testing.T) {
+	fs := New(memfs.New(), WithMaxBytesWritten(5))
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+
+	n, err := f.Write([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	n, err = f.Write([]byte("bar"))
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, 0, n)
+
+	var lerr *Error
+	require.ErrorAs(t, err, &lerr)
+	assert.Equal(t, "bytes written", lerr.Limit)
+	assert.Equal(t, int64(5), lerr.Max)
+
+	_, err = f.WriteAt([]byte("ba"), 3)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, int64(5), fs.(*Limit).BytesWritten())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "fooba", string(content))
+}
+
+func TestMaxBytesWrittenTruncate(t *testing.T) {
+	fs := New(memfs.New(), WithMaxBytesWritten(10))
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, f.Truncate(8))
+	assert.ErrorIs(t, f.Truncate(1<<30), ErrLimitExceeded)
+	require.NoError(t, f.Truncate(2))
+	assert.Equal(t, int64(8), fs.(*Limit).BytesWritten())
+}
+
+func TestMaxFiles(t *testing.T) {
+	fs := New(memfs.New(), WithMaxFiles(2))
+
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	require.NoError(t, util.WriteFile(fs, "bar", nil, 0o644))
+
+	// Opening existing files does not count.
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	err := util.WriteFile(fs, "qux", nil, 0o644)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = fs.TempFile("", "qux")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+
+	_, err = fs.Stat("qux")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, int64(2), fs.(*Limit).FilesCreated())
+}
+
+func TestMaxFilesFailedCreate(t *testing.T) {
+	fs := New(memfs.New(), WithMaxFiles(1))
+
+	_, err := fs.OpenFile("foo", os.O_RDONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	require.Error(t, err)
+	assert.Equal(t, int64(0), fs.(*Limit).FilesCreated())
+}
+
+// barrierFS holds the opens creating files until all the ones expected are
+// waiting, so they run concurrently.
+type barrierFS struct {
+	billy.Filesystem
+	wg sync.WaitGroup
+}
+
+func (fs *barrierFS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		fs.wg.Done()
+		fs.wg.Wait()
+	}
+
+	return fs.Filesystem.OpenFile(filename, flag, perm)
+}
+
+func TestMaxFilesConcurrentCreate(t *testing.T) {
+	base := &barrierFS{Filesystem: memfs.New()}
+	base.wg.Add(2)
+	fs := New(base)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := fs.Create("foo")
+			if assert.NoError(t, err) {
+				assert.NoError(t, f.Close())
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int64(1), fs.(*Limit).FilesCreated())
+}
+
+func TestMaxFilesRemoveReleases(t *testing.T) {
+	fs := New(memfs.New(), WithMaxFiles(2))
+
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	require.NoError(t, util.WriteFile(fs, "dir/bar", nil, 0o644))
+	assert.ErrorIs(t, util.WriteFile(fs, "qux", nil, 0o644), ErrLimitExceeded)
+
+	require.NoError(t, fs.Remove("foo"))
+	assert.Equal(t, int64(1), fs.(*Limit).FilesCreated())
+	require.NoError(t, util.WriteFile(fs, "qux", nil, 0o644))
+
+	// The slots follow the renames, and the replaced files release theirs.
+	require.NoError(t, fs.Rename("dir", "moved"))
+	require.NoError(t, fs.Rename("qux", "moved/bar"))
+	assert.Equal(t, int64(1), fs.(*Limit).FilesCreated())
+
+	chroot, err := fs.Chroot("moved")
+	require.NoError(t, err)
+	require.NoError(t, util.RemoveAll(chroot, "bar"))
+	assert.Equal(t, int64(0), fs.(*Limit).FilesCreated())
+
+	// Removing files not created through the filesystem releases nothing.
+	base := memfs.New()
+	require.NoError(t, util.WriteFile(base, "foo", nil, 0o644))
+	fs = New(base, WithMaxFiles(1))
+	require.NoError(t, util.WriteFile(fs, "bar", nil, 0o644))
+	require.NoError(t, fs.Remove("foo"))
+	assert.Equal(t, int64(1), fs.(*Limit).FilesCreated())
+}
+
+func TestChrootSharesLimits(t *testing.T) {
+	fs := New(memfs.New(), WithMaxFiles(1))
+
+	chroot, err := fs.Chroot("foo")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(chroot, "bar", nil, 0o644))
+
+	err = util.WriteFile(fs, "qux", nil, 0o644)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestCapabilities(t *testing.T) {
+	base := memfs.New()
+	fs := New(base)
+	assert.Equal(t, billy.Capabilities(base)&^billy.RemoveAllCapability, billy.Capabilities(fs))
+}
+
+// fakeClock makes b use a clock which only advances when sleeping, and
+// returns a pointer to the total time slept.
+func fakeClock(b *bucket) *time.Duration {
+	now := time.Unix(0, 0)
+	slept := new(time.Duration)
+
+	b.last = now
+	b.now = func() time.Time { return now }
+	b.sleep = func(d time.Duration) {
+		*slept += d
+		now = now.Add(d)
+	}
+
+	return slept
+}
+
+func TestBucket(t *testing.T) {
+	b := newBucket(100)
+	slept := fakeClock(b)
+
+	// The initial burst is served without waiting.
+	b.wait(100)
+	assert.Zero(t, *slept)
+
+	b.wait(50)
+	assert.Equal(t, 500*time.Millisecond, *slept)
+
+	// Tokens refill over time, up to the burst size.
+	b.sleep(10 * time.Second)
+	*slept = 0
+	b.wait(100)
+	assert.Zero(t, *slept)
+
+	b.wait(200)
+	assert.Equal(t, 2*time.Second, *slept)
+}
+
+func TestWriteRate(t *testing.T) {
+	fs := New(memfs.New(), WithWriteRate(1<<20))
+
+	slept := fakeClock(fs.(*Limit).s.write)
+
+	require.NoError(t, util.WriteFile(fs, "foo", make([]byte, 3<<20), 0o644))
+	assert.InDelta(t, float64(2*time.Second), float64(*slept), float64(100*time.Millisecond))
+}
+
+func TestReadRate(t *testing.T) {
+	base := memfs.New()
+	require.NoError(t, util.WriteFile(base, "foo", make([]byte, 3<<20), 0o644))
+
+	fs := New(base, WithReadRate(1<<20))
+
+	slept := fakeClock(fs.(*Limit).s.read)
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Len(t, content, 3<<20)
+	assert.InDelta(t, float64(2*time.Second), float64(*slept), float64(100*time.Millisecond))
+}
+
+func TestMaxFilesDanglingSymlink(t *testing.T) {
+	fs := New(memfs.New(), WithMaxFiles(1))
+	require.NoError(t, fs.Symlink("target", "link"))
+
+	require.NoError(t, util.WriteFile(fs, "link", []byte("foo"), 0o644))
+	assert.Equal(t, int64(1), fs.(*Limit).FilesCreated())
+
+	content, err := util.ReadFile(fs, "target")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+}
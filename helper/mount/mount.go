@@ -1,20 +1,25 @@
 package mount
 
 import (
-	"io"
+	"crypto"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"fmt"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/helper/polyfill"
+	"github.com/go-git/go-billy/v6/internal/pathutil"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 var separator = string(filepath.Separator)
 
+var errCrossingFilesystems = fmt.Errorf("invalid symlink, target is crossing filesystems: %w", billy.ErrCrossedBoundary)
+
 // Mount is a helper that allows to emulate the behavior of mount in memory.
 // Very usufull to create a temporal dir, on filesystem where is a performance
 // penalty in doing so.
@@ -22,46 +27,63 @@ type Mount struct {
 	underlying billy.Filesystem
 	source     billy.Filesystem
 	mountpoint string
+	mapper     billy.PathMapper
+}
+
+// Option configures a Mount.
+type Option func(*Mount)
+
+// WithPathMapper maps the paths given to the filesystem with m before they
+// are cleaned, and matched against the mountpoint, which is mapped as well.
+func WithPathMapper(m billy.PathMapper) Option {
+	return func(h *Mount) {
+		h.mapper = m
+	}
 }
 
 // New creates a new filesystem wrapping up 'fs' the intercepts all the calls
 // made to `mountpoint` path and redirecting it to `source` filesystem.
-func New(fs billy.Basic, mountpoint string, source billy.Basic) *Mount {
-	return &Mount{
+func New(fs billy.Basic, mountpoint string, source billy.Basic, opts ...Option) *Mount {
+	h := &Mount{
 		underlying: polyfill.New(fs),
 		source:     polyfill.New(source),
-		mountpoint: cleanPath(mountpoint),
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mountpoint = h.cleanPath(mountpoint)
+	return h
 }
 
 func (h *Mount) Create(path string) (billy.File, error) {
 	fs, fullpath := h.getBasicAndPath(path)
 	if fullpath == "." {
-		return nil, os.ErrInvalid
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
 	}
 
 	f, err := fs.Create(fullpath)
-	return wrapFile(f, path), err
+	return wrappedfile.New(f, h.cleanPath(path), path), err
 }
 
 func (h *Mount) Open(path string) (billy.File, error) {
 	fs, fullpath := h.getBasicAndPath(path)
 	if fullpath == "." {
-		return nil, os.ErrInvalid
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
 	}
 
 	f, err := fs.Open(fullpath)
-	return wrapFile(f, path), err
+	return wrappedfile.New(f, h.cleanPath(path), path), err
 }
 
 func (h *Mount) OpenFile(path string, flag int, mode fs.FileMode) (billy.File, error) {
 	fs, fullpath := h.getBasicAndPath(path)
 	if fullpath == "." {
-		return nil, os.ErrInvalid
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
 	}
 
 	f, err := fs.OpenFile(fullpath, flag, mode)
-	return wrapFile(f, path), err
+	return wrappedfile.New(f, h.cleanPath(path), path), err
 }
 
 func (h *Mount) Rename(from, to string) error {
@@ -81,10 +103,10 @@ func (h *Mount) Rename(from, to string) error {
 		fromFS = h.source
 		from = h.mustRelToMountpoint(from)
 		toFS = h.underlying
-		to = cleanPath(to)
+		to = h.cleanPath(to)
 	case !fromInSource && toInSource:
 		fromFS = h.underlying
-		from = cleanPath(from)
+		from = h.cleanPath(from)
 		toFS = h.source
 		to = h.mustRelToMountpoint(to)
 	}
@@ -96,6 +118,44 @@ func (h *Mount) Rename(from, to string) error {
 	return fromFS.Remove(from)
 }
 
+// RenameNoReplace implements the billy.Renamer interface. Both paths must be
+// on the same filesystem, as a rename across them cannot be atomic.
+func (h *Mount) RenameNoReplace(from, to string) error {
+	fs, from, to, err := h.renameFS(from, to)
+	if err != nil {
+		return err
+	}
+
+	return util.RenameNoReplace(fs, from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface. Both paths must be
+// on the same filesystem, as a rename across them cannot be atomic.
+func (h *Mount) RenameExchange(from, to string) error {
+	fs, from, to, err := h.renameFS(from, to)
+	if err != nil {
+		return err
+	}
+
+	return util.RenameExchange(fs, from, to)
+}
+
+// renameFS returns the filesystem holding both from and to, along with their
+// paths within it.
+func (h *Mount) renameFS(from, to string) (billy.Basic, string, string, error) {
+	fromInSource := h.isMountpoint(from)
+	toInSource := h.isMountpoint(to)
+
+	switch {
+	case fromInSource && toInSource:
+		return h.source, h.mustRelToMountpoint(from), h.mustRelToMountpoint(to), nil
+	case !fromInSource && !toInSource:
+		return h.underlying, from, to, nil
+	default:
+		return nil, "", "", &os.LinkError{Op: "rename", Old: from, New: to, Err: billy.ErrNotSupported}
+	}
+}
+
 func (h *Mount) Stat(path string) (os.FileInfo, error) {
 	fs, fullpath := h.getBasicAndPath(path)
 	return fs.Stat(fullpath)
@@ -104,12 +164,57 @@ func (h *Mount) Stat(path string) (os.FileInfo, error) {
 func (h *Mount) Remove(path string) error {
 	fs, fullpath := h.getBasicAndPath(path)
 	if fullpath == "." {
-		return os.ErrInvalid
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrInvalid}
 	}
 
 	return fs.Remove(fullpath)
 }
 
+// RemoveAll removes path and any children it contains from the filesystem
+// it belongs to, using its RemoveAll when available. See util.RemoveAll.
+//
+// The mountpoint itself cannot be removed, so removing it, or one of its
+// parents, empties the source filesystem instead. As with
+// os.RemoveAll("."), the root cannot be removed.
+func (h *Mount) RemoveAll(path string) error {
+	clean := h.cleanPath(path)
+	if clean == "." {
+		return &os.PathError{Op: "removeall", Path: path, Err: os.ErrInvalid}
+	}
+
+	path = clean
+	if !h.isParentOfMountpoint(path) {
+		fs, fullpath := h.getBasicAndPath(path)
+		return util.RemoveAll(fs, fullpath)
+	}
+
+	if err := h.removeSourceContents(); err != nil {
+		return err
+	}
+
+	if path == h.mountpoint {
+		return nil
+	}
+
+	return util.RemoveAll(h.underlying, path)
+}
+
+// removeSourceContents removes everything in the source filesystem.
+func (h *Mount) removeSourceContents() error {
+	names, err := util.ReadDirNames(h.source, ".", 0)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := util.RemoveAll(h.source, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (h *Mount) ReadDir(path string) ([]os.FileInfo, error) {
 	fs, fullpath, err := h.getDirAndPath(path)
 	if err != nil {
@@ -119,6 +224,54 @@ func (h *Mount) ReadDir(path string) ([]os.FileInfo, error) {
 	return fs.ReadDir(fullpath)
 }
 
+// ReadDirNames implements the billy.DirNames interface.
+func (h *Mount) ReadDirNames(path string, n int) ([]string, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	return util.ReadDirNames(fs, fullpath, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (h *Mount) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	return util.ReadDirEntries(fs, fullpath)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (h *Mount) SyncDir(path string) error {
+	fs, fullpath := h.getBasicAndPath(path)
+	return util.SyncDir(fs, fullpath)
+}
+
+// HashFile implements the billy.Hasher interface.
+func (h *Mount) HashFile(path string, hash crypto.Hash) ([]byte, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	return util.HashFile(fs, fullpath, hash)
+}
+
+// Mmap implements the billy.Mapper interface.
+func (h *Mount) Mmap(path string) (billy.Mapping, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	return util.Mmap(fs, fullpath)
+}
+
+// CopyFile implements the billy.Copier interface. Files are copied natively
+// only within the same filesystem.
+func (h *Mount) CopyFile(src, dst string) error {
+	srcFS, srcPath := h.getBasicAndPath(src)
+	dstFS, dstPath := h.getBasicAndPath(dst)
+	if h.isMountpoint(src) == h.isMountpoint(dst) {
+		return util.CopyFile(srcFS, srcPath, dstPath)
+	}
+
+	return copyPath(srcFS, dstFS, srcPath, dstPath)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (h *Mount) OpenDir(path string) (billy.DirIter, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	return util.OpenDir(fs, fullpath)
+}
+
 func (h *Mount) MkdirAll(filename string, perm fs.FileMode) error {
 	fs, fullpath, err := h.getDirAndPath(filename)
 	if err != nil {
@@ -128,20 +281,65 @@ func (h *Mount) MkdirAll(filename string, perm fs.FileMode) error {
 	return fs.MkdirAll(fullpath, perm)
 }
 
+// TempDir creates a new temporary directory, in the filesystem dir belongs
+// to, as MkdirTemp does with the pattern prefix+"*".
+func (h *Mount) TempDir(dir, prefix string) (string, error) {
+	return h.MkdirTemp(dir, prefix+"*")
+}
+
+// MkdirTemp creates a new temporary directory, in the filesystem dir belongs
+// to. See util.MkdirTemp for details.
+func (h *Mount) MkdirTemp(dir, pattern string) (string, error) {
+	return util.MkdirTemp(h, dir, pattern)
+}
+
 func (h *Mount) Symlink(target, link string) error {
 	fs, fullpath, err := h.getSymlinkAndPath(link)
 	if err != nil {
 		return err
 	}
 
-	resolved := filepath.Join(filepath.Dir(link), target)
-	if h.isMountpoint(resolved) != h.isMountpoint(link) {
-		return fmt.Errorf("invalid symlink, target is crossing filesystems")
+	resolved := target
+	if !pathutil.IsAbs(target) {
+		resolved = filepath.Join(filepath.Dir(h.cleanPath(link)), target)
+	}
+
+	inSource := h.isMountpoint(link)
+	if h.isMountpoint(resolved) != inSource {
+		err := &billy.BoundaryError{Base: h.mountpoint, Path: resolved, Err: errCrossingFilesystems}
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: err}
+	}
+
+	if inSource {
+		target = h.sourceTarget(target, resolved, fullpath)
 	}
 
 	return fs.Symlink(target, fullpath)
 }
 
+// sourceTarget translates target, a symlink target in the virtual tree
+// resolving to resolved, to the one of the symlink at fullpath in source.
+// Absolute targets are made relative to the root of source, and relative
+// ones going up through the mountpoint are recomputed from the link, as they
+// would otherwise escape source. The other ones are kept as they are.
+func (h *Mount) sourceTarget(target, resolved, fullpath string) string {
+	rel := h.mustRelToMountpoint(resolved)
+	if pathutil.IsAbs(target) {
+		return filepath.Join(separator, rel)
+	}
+
+	if !escapes(filepath.Join(filepath.Dir(fullpath), target)) {
+		return target
+	}
+
+	target, err := filepath.Rel(filepath.Dir(fullpath), rel)
+	if err != nil {
+		panic(err)
+	}
+
+	return target
+}
+
 func (h *Mount) Join(elem ...string) string {
 	return h.underlying.Join(elem...)
 }
@@ -152,7 +350,14 @@ func (h *Mount) Readlink(link string) (string, error) {
 		return "", err
 	}
 
-	return fs.Readlink(fullpath)
+	target, err := fs.Readlink(fullpath)
+	if err != nil || !h.isMountpoint(link) || !pathutil.IsAbs(target) {
+		return target, err
+	}
+
+	// The absolute targets of the symlinks in source are relative to its
+	// root, which is the mountpoint in the virtual tree.
+	return filepath.Join(separator, h.mountpoint, target), nil
 }
 
 func (h *Mount) Lstat(path string) (os.FileInfo, error) {
@@ -164,17 +369,144 @@ func (h *Mount) Lstat(path string) (os.FileInfo, error) {
 	return fs.Lstat(fullpath)
 }
 
+// GetXattr implements the billy.Xattr interface.
+func (h *Mount) GetXattr(path, name string) ([]byte, error) {
+	fs, fullpath, err := h.getXattrAndPath("getxattr", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.GetXattr(fullpath, name)
+}
+
+// SetXattr implements the billy.Xattr interface.
+func (h *Mount) SetXattr(path, name string, value []byte) error {
+	fs, fullpath, err := h.getXattrAndPath("setxattr", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.SetXattr(fullpath, name, value)
+}
+
+// ListXattr implements the billy.Xattr interface.
+func (h *Mount) ListXattr(path string) ([]string, error) {
+	fs, fullpath, err := h.getXattrAndPath("listxattr", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.ListXattr(fullpath)
+}
+
+// RemoveXattr implements the billy.Xattr interface.
+func (h *Mount) RemoveXattr(path, name string) error {
+	fs, fullpath, err := h.getXattrAndPath("removexattr", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.RemoveXattr(fullpath, name)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Mount) Chmod(path string, mode fs.FileMode) error {
+	fs, fullpath, err := h.getChangeAndPath("chmod", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.Chmod(fullpath, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Mount) Lchown(path string, uid, gid int) error {
+	fs, fullpath, err := h.getChangeAndPath("lchown", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.Lchown(fullpath, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Mount) Chown(path string, uid, gid int) error {
+	fs, fullpath, err := h.getChangeAndPath("chown", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.Chown(fullpath, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Mount) Chtimes(path string, atime time.Time, mtime time.Time) error {
+	fs, fullpath, err := h.getChangeAndPath("chtimes", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.Chtimes(fullpath, atime, mtime)
+}
+
 func (h *Mount) Underlying() billy.Basic {
 	return h.underlying
 }
 
+// Layers implements billy.Composite, returning the underlying filesystem
+// followed by the one mounted on it.
+func (h *Mount) Layers() []billy.Basic {
+	return []billy.Basic{h.underlying, h.source}
+}
+
+func (h *Mount) String() string {
+	return "mount(" + h.mountpoint + ")"
+}
+
 // Capabilities implements the Capable interface.
 func (h *Mount) Capabilities() billy.Capability {
-	return billy.Capabilities(h.underlying) & billy.Capabilities(h.source)
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.underlying)&billy.Capabilities(h.source))
+}
+
+// Supports implements the billy.FeatureReporter interface. The
+// features are the ones supported by both filesystems.
+func (h *Mount) Supports(f billy.Feature) bool {
+	return billy.Supports(h.underlying, f) && billy.Supports(h.source, f)
+}
+
+// PathProperties implements the Introspectable interface. The returned
+// properties are the most restrictive combination of both filesystems.
+func (h *Mount) PathProperties() billy.PathProperties {
+	u := billy.Introspect(h.underlying)
+	s := billy.Introspect(h.source)
+
+	p := billy.PathProperties{
+		CaseSensitive: u.CaseSensitive && s.CaseSensitive,
+		MaxPathLength: minLength(u.MaxPathLength, s.MaxPathLength),
+		MaxNameLength: minLength(u.MaxNameLength, s.MaxNameLength),
+		InvalidChars:  u.InvalidChars,
+	}
+
+	for _, c := range s.InvalidChars {
+		if !strings.ContainsRune(p.InvalidChars, c) {
+			p.InvalidChars += string(c)
+		}
+	}
+
+	return p
+}
+
+// minLength returns the smallest non-zero length, where zero means no limit.
+func minLength(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+
+	return a
 }
 
 func (h *Mount) getBasicAndPath(path string) (billy.Basic, string) {
-	path = cleanPath(path)
+	path = h.cleanPath(path)
 	if !h.isMountpoint(path) {
 		return h.underlying, path
 	}
@@ -183,7 +515,7 @@ func (h *Mount) getBasicAndPath(path string) (billy.Basic, string) {
 }
 
 func (h *Mount) getDirAndPath(path string) (billy.Dir, string, error) {
-	path = cleanPath(path)
+	path = h.cleanPath(path)
 	if !h.isMountpoint(path) {
 		return h.underlying.(billy.Dir), path, nil
 	}
@@ -192,7 +524,7 @@ func (h *Mount) getDirAndPath(path string) (billy.Dir, string, error) {
 }
 
 func (h *Mount) getSymlinkAndPath(path string) (billy.Symlink, string, error) {
-	path = cleanPath(path)
+	path = h.cleanPath(path)
 	if !h.isMountpoint(path) {
 		return h.underlying.(billy.Symlink), path, nil
 	}
@@ -200,8 +532,28 @@ func (h *Mount) getSymlinkAndPath(path string) (billy.Symlink, string, error) {
 	return h.source.(billy.Symlink), h.mustRelToMountpoint(path), nil
 }
 
+func (h *Mount) getXattrAndPath(op, path string) (billy.Xattr, string, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	x, ok := fs.(billy.Xattr)
+	if !ok {
+		return nil, "", &os.PathError{Op: op, Path: path, Err: billy.ErrNotSupported}
+	}
+
+	return x, fullpath, nil
+}
+
+func (h *Mount) getChangeAndPath(op, path string) (billy.Change, string, error) {
+	fs, fullpath := h.getBasicAndPath(path)
+	c, ok := fs.(billy.Change)
+	if !ok {
+		return nil, "", &os.PathError{Op: op, Path: path, Err: billy.ErrNotSupported}
+	}
+
+	return c, fullpath, nil
+}
+
 func (h *Mount) mustRelToMountpoint(path string) string {
-	path = cleanPath(path)
+	path = h.cleanPath(path)
 	fullpath, err := filepath.Rel(h.mountpoint, path)
 	if err != nil {
 		panic(err)
@@ -210,63 +562,46 @@ func (h *Mount) mustRelToMountpoint(path string) string {
 	return fullpath
 }
 
+// isParentOfMountpoint reports whether path is the mountpoint or one of its
+// parent directories.
+func (h *Mount) isParentOfMountpoint(path string) bool {
+	path = h.cleanPath(path)
+	return path == "." || path == h.mountpoint ||
+		strings.HasPrefix(h.mountpoint, path+separator)
+}
+
 func (h *Mount) isMountpoint(path string) bool {
-	path = cleanPath(path)
-	return strings.HasPrefix(path, h.mountpoint)
+	path = h.cleanPath(path)
+	return path == h.mountpoint || strings.HasPrefix(path, h.mountpoint+separator)
 }
 
-func cleanPath(path string) string {
-	path = filepath.FromSlash(path)
-	rel, err := filepath.Rel(separator, path)
-	if err == nil {
-		path = rel
-	}
+// escapes reports whether the clean relative path goes up its root.
+func escapes(path string) bool {
+	return path == ".." || strings.HasPrefix(path, ".."+separator)
+}
 
-	return filepath.Clean(path)
+// cleanPath maps path with the PathMapper of h, if any, and cleans it.
+func (h *Mount) cleanPath(path string) string {
+	return pathutil.Clean(pathutil.Map(h.mapper, path))
 }
 
-// copyPath copies a file across filesystems.
+// copyPath copies a file across filesystems, see util.Copy.
 func copyPath(src, dst billy.Basic, srcPath, dstPath string) error {
-	dstFile, err := dst.Create(dstPath)
-	if err != nil {
-		return err
-	}
-
 	srcFile, err := src.Open(srcPath)
 	if err != nil {
 		return err
 	}
+	defer srcFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	dstFile, err := dst.Create(dstPath)
 	if err != nil {
 		return err
 	}
 
-	err = dstFile.Close()
-	if err != nil {
-		_ = srcFile.Close()
+	if _, err := util.Copy(dstFile, srcFile); err != nil {
+		_ = dstFile.Close()
 		return err
 	}
 
-	return srcFile.Close()
-}
-
-type file struct {
-	billy.File
-	name string
-}
-
-func wrapFile(f billy.File, filename string) billy.File {
-	if f == nil {
-		return nil
-	}
-
-	return &file{
-		File: f,
-		name: cleanPath(filename),
-	}
-}
-
-func (f *file) Name() string {
-	return f.name
+	return dstFile.Close()
 }
@@ -3,14 +3,16 @@ package mount
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/internal/test"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
 	"github.com/go-git/go-billy/v6/memfs"
 	"github.com/go-git/go-billy/v6/util"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
 type mock struct {
@@ -65,6 +67,35 @@ func TestCreateInMount(t *testing.T) {
 	assert.Equal(t, filepath.Join("bar", "qux"), source.CreateArgs[0])
 }
 
+func TestWithPathMapper(t *testing.T) {
+	underlying := &mock{}
+	source := &mock{}
+
+	// The trailing dots and spaces of the elements are trimmed, as Windows
+	// does.
+	trim := billy.PathMapperFunc(func(path string) string {
+		elems := strings.Split(filepath.ToSlash(path), "/")
+		for i, elem := range elems {
+			if elem != "." && elem != ".." {
+				elems[i] = strings.TrimRight(elem, ". ")
+			}
+		}
+		return strings.Join(elems, "/")
+	})
+
+	helper := New(underlying, "/foo.", source, WithPathMapper(trim))
+	f, err := helper.Create("foo ./bar.")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("foo", "bar"), f.Name())
+	assert.Equal(t, "foo ./bar.", f.OpenedPath())
+
+	_, err = helper.Create("qux..")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bar"}, source.CreateArgs)
+	assert.Equal(t, []string{"qux"}, underlying.CreateArgs)
+}
+
 func TestOpen(t *testing.T) {
 	helper, underlying, source := setup()
 	f, err := helper.Open("bar/qux")
@@ -178,7 +209,7 @@ func TestRenameCross(t *testing.T) {
 	require.NoError(t, err)
 
 	_, err = underlying.Stat("file")
-	assert.Equal(t, err, os.ErrNotExist)
+	assert.ErrorIs(t, err, os.ErrNotExist)
 
 	_, err = source.Stat("file")
 	require.NoError(t, err)
@@ -190,7 +221,58 @@ func TestRenameCross(t *testing.T) {
 	require.NoError(t, err)
 
 	_, err = source.Stat("file")
-	assert.Equal(t, err, os.ErrNotExist)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCopyFile(t *testing.T) {
+	underlying := memfs.New()
+	source := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "file", []byte("foo"), 0o644))
+
+	fs := New(underlying, "/foo", source)
+	require.NoError(t, util.CopyFile(fs, "file", "foo/file"))
+	require.NoError(t, util.CopyFile(fs, "foo/file", "foo/copy"))
+	require.NoError(t, util.CopyFile(fs, "foo/copy", "copy"))
+
+	for _, tc := range []struct {
+		fs   billy.Filesystem
+		name string
+	}{
+		{underlying, "file"},
+		{source, "file"},
+		{source, "copy"},
+		{underlying, "copy"},
+	} {
+		content, err := util.ReadFile(tc.fs, tc.name)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+	}
+
+	err := util.CopyFile(fs, "missing", "foo/missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = source.Stat("missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRenameNoReplaceInMount(t *testing.T) {
+	source := memfs.New()
+	h := New(memfs.New(), "/foo", source)
+	require.NoError(t, util.WriteFile(h, "foo/bar", []byte("bar"), 0o644))
+	require.NoError(t, util.WriteFile(h, "foo/qux", []byte("qux"), 0o644))
+
+	err := h.RenameNoReplace("foo/bar", "foo/qux")
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	require.NoError(t, h.RenameExchange("foo/bar", "foo/qux"))
+	data, err := util.ReadFile(source, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, "qux", string(data))
+
+	err = h.RenameNoReplace("foo/bar", "bar")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+
+	err = h.RenameExchange("bar", "foo/bar")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
 }
 
 func TestRemove(t *testing.T) {
@@ -219,6 +301,77 @@ func TestRemoveInMount(t *testing.T) {
 	assert.Equal(t, source.RemoveArgs[0], filepath.Join("bar", "qux"))
 }
 
+func TestRemoveAll(t *testing.T) {
+	underlying := memfs.New()
+	source := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "bar/qux", nil, 0o644))
+	require.NoError(t, util.WriteFile(source, "bar/qux", nil, 0o644))
+
+	h := New(underlying, "/foo", source)
+	require.NoError(t, util.RemoveAll(h, "bar"))
+
+	_, err := underlying.Stat("bar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = source.Stat("bar/qux")
+	require.NoError(t, err)
+}
+
+func TestRemoveAllInMount(t *testing.T) {
+	underlying := memfs.New()
+	source := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "foo/bar/qux", nil, 0o644))
+	require.NoError(t, util.WriteFile(source, "bar/qux", nil, 0o644))
+
+	h := New(underlying, "/foo", source)
+	require.NoError(t, util.RemoveAll(h, "foo/bar"))
+
+	_, err := source.Stat("bar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = underlying.Stat("foo/bar/qux")
+	require.NoError(t, err)
+}
+
+func TestRemoveAllMountPoint(t *testing.T) {
+	underlying := memfs.New()
+	source := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "qux", nil, 0o644))
+	require.NoError(t, util.WriteFile(source, "bar/qux", nil, 0o644))
+	require.NoError(t, util.WriteFile(source, "qux", nil, 0o644))
+
+	h := New(underlying, "/foo/bar", source)
+	require.NoError(t, util.RemoveAll(h, "foo/bar"))
+
+	names, err := util.ReadDirNames(source, ".", 0)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	require.NoError(t, util.WriteFile(source, "qux", nil, 0o644))
+	require.NoError(t, util.RemoveAll(h, "foo"))
+
+	names, err = util.ReadDirNames(source, ".", 0)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+	_, err = underlying.Stat("qux")
+	require.NoError(t, err)
+}
+
+func TestRemoveAllRoot(t *testing.T) {
+	underlying := memfs.New()
+	source := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "qux", nil, 0o644))
+	require.NoError(t, util.WriteFile(source, "qux", nil, 0o644))
+
+	h := New(underlying, "/foo", source)
+	for _, path := range []string{".", "", "/"} {
+		assert.ErrorIs(t, h.RemoveAll(path), os.ErrInvalid, path)
+	}
+
+	_, err := underlying.Stat("qux")
+	require.NoError(t, err)
+	_, err = source.Stat("qux")
+	require.NoError(t, err)
+}
+
 func TestReadDir(t *testing.T) {
 	helper, underlying, source := setup()
 	_, err := helper.ReadDir("bar/qux")
@@ -314,6 +467,11 @@ func TestSymlinkCrossMount(t *testing.T) {
 
 	err = helper.Symlink("../../../foo", "foo/bar/qux")
 	assert.Error(t, err)
+
+	var berr *billy.BoundaryError
+	require.ErrorAs(t, err, &berr)
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+	assert.Equal(t, "foo", berr.Base)
 }
 
 func TestSymlinkInMount(t *testing.T) {
@@ -347,12 +505,85 @@ func TestReadlinkInMount(t *testing.T) {
 	assert.Equal(t, source.ReadlinkArgs[0], filepath.Join("bar", "qux"))
 }
 
+func TestSymlinkTargetInMount(t *testing.T) {
+	helper, _, source := setup()
+	require.NoError(t, helper.Symlink("/foo/baz", "foo/bar/qux"))
+	require.NoError(t, helper.Symlink("../../foo/baz", "foo/bar/qux"))
+	require.NoError(t, helper.Symlink("./baz", "foo/bar/qux"))
+
+	assert.Equal(t, [][2]string{
+		{string(filepath.Separator) + "baz", filepath.Join("bar", "qux")},
+		{filepath.Join("..", "baz"), filepath.Join("bar", "qux")},
+		{"./baz", filepath.Join("bar", "qux")},
+	}, source.SymlinkArgs)
+}
+
+func TestSymlinkNextToMountpoint(t *testing.T) {
+	helper, underlying, source := setup()
+	require.NoError(t, helper.Symlink("foobar", "qux"))
+	require.NoError(t, helper.Symlink("../baz", "foobar/qux"))
+
+	assert.Len(t, underlying.SymlinkArgs, 2)
+	assert.Empty(t, source.SymlinkArgs)
+
+	err := helper.Symlink("/foo", "foobar/qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+	err = helper.Symlink("/foobar", "foo/qux")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
+
+func TestSymlinkRoundTrip(t *testing.T) {
+	underlying := memfs.New()
+	source := memfs.New()
+	h := New(underlying, "/foo", source)
+
+	require.NoError(t, util.WriteFile(h, "foo/bar/baz", []byte("baz"), 0o644))
+	require.NoError(t, util.WriteFile(h, "qux", []byte("qux"), 0o644))
+
+	for link, target := range map[string]string{
+		"foo/bar/abs":    "/foo/bar/baz",
+		"foo/bar/rel":    "baz",
+		"foo/bar/up":     "../../foo/bar/baz",
+		"foo/root":       "/foo",
+		"foobar":         "qux",
+		"outside/to-qux": "/qux",
+	} {
+		if dir := filepath.Dir(link); dir != "." {
+			require.NoError(t, h.MkdirAll(dir, 0o755))
+		}
+		require.NoError(t, h.Symlink(target, link))
+
+		got, err := h.Readlink(link)
+		require.NoError(t, err)
+
+		want := filepath.FromSlash(target)
+		if target == "../../foo/bar/baz" {
+			want = "baz"
+		}
+		assert.Equal(t, want, got, link)
+	}
+
+	for _, link := range []string{"foo/bar/abs", "foo/bar/rel", "foo/bar/up"} {
+		content, err := util.ReadFile(h, link)
+		require.NoError(t, err)
+		assert.Equal(t, "baz", string(content), link)
+	}
+
+	content, err := util.ReadFile(source, "bar/up")
+	require.NoError(t, err)
+	assert.Equal(t, "baz", string(content))
+
+	content, err = util.ReadFile(h, "foobar")
+	require.NoError(t, err)
+	assert.Equal(t, "qux", string(content))
+}
+
 func TestUnderlyingNotSupported(t *testing.T) {
 	h := New(&test.BasicMock{}, "/foo", &test.BasicMock{})
 	_, err := h.ReadDir("qux")
 	assert.Equal(t, err, billy.ErrNotSupported)
 	_, err = h.Readlink("qux")
-	assert.Equal(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, err, os.ErrInvalid)
 }
 
 func TestSourceNotSupported(t *testing.T) {
@@ -361,7 +592,7 @@ func TestSourceNotSupported(t *testing.T) {
 	_, err := h.ReadDir("foo")
 	assert.Equal(t, err, billy.ErrNotSupported)
 	_, err = h.Readlink("foo")
-	assert.Equal(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, err, os.ErrInvalid)
 }
 
 func TestCapabilities(t *testing.T) {
@@ -383,14 +614,110 @@ func testCapabilities(t *testing.T, a, b billy.Basic) {
 	fs := New(a, "/foo", b)
 	capabilities := billy.Capabilities(fs)
 
-	unionCapabilities := aCapabilities & bCapabilities
+	unionCapabilities := aCapabilities&bCapabilities | billy.RemoveAllCapability
 
 	assert.Equal(t, capabilities, unionCapabilities)
 
 	fs = New(b, "/foo", a)
 	capabilities = billy.Capabilities(fs)
 
-	unionCapabilities = aCapabilities & bCapabilities
+	unionCapabilities = aCapabilities&bCapabilities | billy.RemoveAllCapability
 
 	assert.Equal(t, capabilities, unionCapabilities)
 }
+
+func TestPathProperties(t *testing.T) {
+	fs := New(new(test.BasicMock), "/foo", new(test.BasicMock))
+	assert.Equal(t, billy.DefaultPathProperties, billy.Introspect(fs))
+
+	fs = New(new(test.BasicMock), "/foo", new(test.CaseInsensitiveFs))
+	props := billy.Introspect(fs)
+	assert.False(t, props.CaseSensitive)
+	assert.Equal(t, 260, props.MaxPathLength)
+	assert.Equal(t, 255, props.MaxNameLength)
+	assert.Equal(t, "\x00<>:\"\\|?*", props.InvalidChars)
+}
+
+func TestReadDirNamesInMount(t *testing.T) {
+	helper, underlying, source := setup()
+	_, err := helper.ReadDirNames("foo/bar/qux", 0)
+	require.NoError(t, err)
+
+	assert.Empty(t, underlying.ReadDirArgs)
+	assert.Len(t, source.ReadDirArgs, 1)
+	assert.Equal(t, source.ReadDirArgs[0], filepath.Join("bar", "qux"))
+}
+
+func TestOpenDirInMount(t *testing.T) {
+	helper, underlying, source := setup()
+	_, err := helper.OpenDir("foo/bar/qux")
+	require.NoError(t, err)
+
+	assert.Empty(t, underlying.ReadDirArgs)
+	assert.Len(t, source.ReadDirArgs, 1)
+	assert.Equal(t, source.ReadDirArgs[0], filepath.Join("bar", "qux"))
+}
+
+func TestReadDirEntriesInMount(t *testing.T) {
+	helper, underlying, source := setup()
+	_, err := helper.ReadDirEntries("foo/bar/qux")
+	require.NoError(t, err)
+
+	assert.Empty(t, underlying.ReadDirArgs)
+	assert.Len(t, source.ReadDirArgs, 1)
+	assert.Equal(t, source.ReadDirArgs[0], filepath.Join("bar", "qux"))
+}
+
+func TestXattrInMount(t *testing.T) {
+	source := memfs.New()
+	h := New(memfs.New(), "/foo", source)
+	require.NoError(t, util.WriteFile(h, "foo/bar", nil, 0o644))
+
+	require.NoError(t, h.SetXattr("foo/bar", "user.foo", []byte("bar")))
+	v, err := source.(billy.Xattr).GetXattr("bar", "user.foo")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), v)
+
+	h = New(&test.BasicMock{}, "/foo", &test.BasicMock{})
+	_, err = h.ListXattr("qux")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+func TestChangeInMount(t *testing.T) {
+	source := memfs.New()
+	h := New(memfs.New(), "/foo", source)
+	require.NoError(t, util.WriteFile(h, "foo/bar", nil, 0o644))
+
+	require.NoError(t, h.Chmod("foo/bar", 0o600))
+	fi, err := source.Stat("bar")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	h = New(&test.BasicMock{}, "/foo", &test.BasicMock{})
+	err = h.Chtimes("qux", time.Now(), time.Now())
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+func TestTempDirInMount(t *testing.T) {
+	source := memfs.New()
+	h := New(memfs.New(), "/foo", source)
+
+	name, err := h.TempDir("foo/bar", "qux")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, filepath.Join("foo", "bar", "qux")))
+
+	fi, err := source.Stat(filepath.Join("bar", filepath.Base(name)))
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+}
+
+func TestSyncInMount(t *testing.T) {
+	source := memfs.New()
+	h := New(memfs.New(), "/foo", source)
+
+	f, err := h.Create("foo/bar")
+	require.NoError(t, err)
+	require.NoError(t, f.Sync())
+	require.NoError(t, f.Close())
+	assert.ErrorIs(t, f.Sync(), os.ErrClosed)
+}
@@ -0,0 +1,444 @@
+// Package policyfs provides a billy filesystem wrapper which restricts the
+// paths that can be read, written or deleted according to a set of rules.
+package policyfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// Access is a set of operations which a rule allows or denies.
+type Access uint8
+
+const (
+	// Read covers opening files for reading, and statting, listing and
+	// reading links.
+	Read Access = 1 << iota
+	// Write covers creating and modifying files, dirs and links, as well as
+	// being the destination of a rename.
+	Write
+	// Delete covers removing files and dirs, as well as being the source of
+	// a rename.
+	Delete
+
+	// All covers every operation.
+	All = Read | Write | Delete
+)
+
+// Rule allows or denies Access to the paths matching Pattern.
+//
+// Pattern is matched with filepath.Match against the path, relative to the
+// root of the filesystem, and against each of its parent dirs, so that a
+// rule for a dir applies to everything below it.
+type Rule struct {
+	Pattern string
+	Access  Access
+	Deny    bool
+}
+
+// Allow returns a rule allowing access to the paths matching pattern.
+func Allow(pattern string, access Access) Rule {
+	return Rule{Pattern: pattern, Access: access}
+}
+
+// Deny returns a rule denying access to the paths matching pattern.
+func Deny(pattern string, access Access) Rule {
+	return Rule{Pattern: pattern, Access: access, Deny: true}
+}
+
+// Policy is a helper that enforces a set of rules over any billy.Filesystem.
+// Operations on disallowed paths fail with an error wrapping
+// os.ErrPermission.
+//
+// Rules are evaluated in order and the last one matching a path decides
+// whether an operation is allowed, so specific rules must follow generic
+// ones. Paths not matched by any rule are denied.
+//
+// Symlinks are evaluated before checking the rules, so a link can not be
+// used to reach a path which is not otherwise allowed.
+type Policy struct {
+	underlying billy.Filesystem
+	base       string
+	rules      []Rule
+}
+
+// New creates a new filesystem wrapping up fs which only allows the
+// operations granted by rules.
+func New(fs billy.Filesystem, rules ...Rule) billy.Filesystem {
+	return &Policy{underlying: fs, rules: rules}
+}
+
+// Layers implements billy.Composite.
+func (p *Policy) Layers() []billy.Basic {
+	return []billy.Basic{p.underlying}
+}
+
+// String describes the policy along with the dir it was chrooted to, if any.
+func (p *Policy) String() string {
+	if p.base == "" {
+		return "policyfs"
+	}
+
+	return "policyfs(" + p.base + ")"
+}
+
+// Allowed reports whether the given access to path is allowed by the rules.
+// It does not evaluate symlinks.
+func (p *Policy) Allowed(path string, access Access) bool {
+	path = p.relative(path)
+
+	for _, a := range []Access{Read, Write, Delete} {
+		if access&a == 0 {
+			continue
+		}
+
+		allowed := false
+		for _, r := range p.rules {
+			if r.Access&a != 0 && match(r.Pattern, path) {
+				allowed = !r.Deny
+			}
+		}
+
+		if !allowed {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Policy) Create(filename string) (billy.File, error) {
+	return p.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (p *Policy) Open(filename string) (billy.File, error) {
+	return p.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (p *Policy) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	var access Access
+	if openflag.Readable(flag) {
+		access |= Read
+	}
+	if openflag.Writable(flag) || openflag.Create(flag) {
+		access |= Write
+	}
+
+	if err := p.check("open", filename, access, true); err != nil {
+		return nil, err
+	}
+
+	return p.underlying.OpenFile(filename, flag, perm)
+}
+
+func (p *Policy) Stat(filename string) (os.FileInfo, error) {
+	if err := p.check("stat", filename, Read, true); err != nil {
+		return nil, err
+	}
+
+	return p.underlying.Stat(filename)
+}
+
+func (p *Policy) Rename(from, to string) error {
+	if err := p.check("rename", from, Delete, false); err != nil {
+		return err
+	}
+	if err := p.check("rename", to, Write, false); err != nil {
+		return err
+	}
+
+	return p.underlying.Rename(from, to)
+}
+
+func (p *Policy) Remove(filename string) error {
+	if err := p.check("remove", filename, Delete, false); err != nil {
+		return err
+	}
+
+	return p.underlying.Remove(filename)
+}
+
+func (p *Policy) Join(elem ...string) string {
+	return p.underlying.Join(elem...)
+}
+
+func (p *Policy) TempFile(dir, prefix string) (billy.File, error) {
+	if err := p.check("tempfile", dir, Write, true); err != nil {
+		return nil, err
+	}
+
+	return p.underlying.TempFile(dir, prefix)
+}
+
+func (p *Policy) TempDir(dir, prefix string) (string, error) {
+	if err := p.check("tempdir", dir, Write, true); err != nil {
+		return "", err
+	}
+
+	return p.underlying.TempDir(dir, prefix)
+}
+
+func (p *Policy) CreateTemp(dir, pattern string) (billy.File, error) {
+	if err := p.check("createtemp", dir, Write, true); err != nil {
+		return nil, err
+	}
+
+	return p.underlying.CreateTemp(dir, pattern)
+}
+
+func (p *Policy) MkdirTemp(dir, pattern string) (string, error) {
+	if err := p.check("mkdirtemp", dir, Write, true); err != nil {
+		return "", err
+	}
+
+	return p.underlying.MkdirTemp(dir, pattern)
+}
+
+// ReadDir returns the entries of the named dir which are readable.
+func (p *Policy) ReadDir(path string) ([]os.FileInfo, error) {
+	if err := p.check("readdir", path, Read, true); err != nil {
+		return nil, err
+	}
+
+	infos, err := p.underlying.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := infos[:0]
+	for _, fi := range infos {
+		if p.Allowed(p.underlying.Join(path, fi.Name()), Read) {
+			allowed = append(allowed, fi)
+		}
+	}
+
+	return allowed, nil
+}
+
+func (p *Policy) MkdirAll(filename string, perm fs.FileMode) error {
+	if err := p.check("mkdir", filename, Write, true); err != nil {
+		return err
+	}
+
+	return p.underlying.MkdirAll(filename, perm)
+}
+
+func (p *Policy) Lstat(filename string) (os.FileInfo, error) {
+	if err := p.check("lstat", filename, Read, false); err != nil {
+		return nil, err
+	}
+
+	return p.underlying.Lstat(filename)
+}
+
+func (p *Policy) Symlink(target, link string) error {
+	if err := p.check("symlink", link, Write, false); err != nil {
+		return err
+	}
+
+	return p.underlying.Symlink(target, link)
+}
+
+func (p *Policy) Readlink(link string) (string, error) {
+	if err := p.check("readlink", link, Read, false); err != nil {
+		return "", err
+	}
+
+	return p.underlying.Readlink(link)
+}
+
+func (p *Policy) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := p.underlying.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Policy{
+		underlying: fs,
+		base:       filepath.Join(p.base, p.relative(path)),
+		rules:      p.rules,
+	}, nil
+}
+
+func (p *Policy) Root() string {
+	return p.underlying.Root()
+}
+
+// Capabilities implements the Capable interface.
+func (p *Policy) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(p, billy.Capabilities(p.underlying))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (p *Policy) Supports(f billy.Feature) bool {
+	return billy.Supports(p.underlying, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (p *Policy) PathProperties() billy.PathProperties {
+	return billy.Introspect(p.underlying)
+}
+
+// Chmod implements the billy.Change interface.
+func (p *Policy) Chmod(name string, mode fs.FileMode) error {
+	c, err := p.change("chmod", name, true)
+	if err != nil {
+		return err
+	}
+
+	return c.Chmod(name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (p *Policy) Lchown(name string, uid, gid int) error {
+	c, err := p.change("lchown", name, false)
+	if err != nil {
+		return err
+	}
+
+	return c.Lchown(name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (p *Policy) Chown(name string, uid, gid int) error {
+	c, err := p.change("chown", name, true)
+	if err != nil {
+		return err
+	}
+
+	return c.Chown(name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (p *Policy) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	c, err := p.change("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+
+	return c.Chtimes(name, atime, mtime)
+}
+
+func (p *Policy) change(op, name string, follow bool) (billy.Change, error) {
+	c, ok := p.underlying.(billy.Change)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return c, p.check(op, name, Write, follow)
+}
+
+// check returns an error if access to path is not allowed. Both path and
+// the path it resolves to once symlinks are evaluated must be allowed. If
+// follow is false, the last element of path is not evaluated. Paths which
+// cannot be resolved, such as the ones going through cyclic links, are
+// rejected.
+func (p *Policy) check(op, path string, access Access, follow bool) error {
+	if !p.Allowed(path, access) {
+		return &os.PathError{Op: op, Path: path, Err: os.ErrPermission}
+	}
+
+	resolved, err := p.resolve(path, follow, 0)
+	if err != nil {
+		return &os.PathError{Op: op, Path: path, Err: err}
+	}
+	if !p.Allowed(resolved, access) {
+		return &os.PathError{Op: op, Path: path, Err: os.ErrPermission}
+	}
+
+	return nil
+}
+
+// maxSymlinkHops is the number of symlinks followed by resolve before giving
+// up, as util.EvalSymlinks does.
+const maxSymlinkHops = 255
+
+// resolve evaluates the symlinks of path. Paths which do not exist yet are
+// resolved through their parent dir, and dangling symlinks through their
+// target, as creating a file through them creates their target. hops is the
+// number of symlinks followed so far.
+func (p *Policy) resolve(path string, follow bool, hops int) (string, error) {
+	if hops > maxSymlinkHops {
+		return "", errno.ELOOP
+	}
+
+	path = filepath.Clean(path)
+	if follow {
+		resolved, err := util.EvalSymlinks(p.underlying, path)
+		if err == nil {
+			return resolved, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	dir, name := filepath.Split(path)
+	if name == "" {
+		// The root, which has no parent to resolve it through.
+		return path, nil
+	}
+	if dir != "" {
+		var err error
+		dir, err = p.resolve(dir, true, hops)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	resolved := filepath.Join(dir, name)
+	if !follow {
+		return resolved, nil
+	}
+
+	fi, err := p.underlying.Lstat(resolved)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return resolved, nil
+	}
+
+	target, err := p.underlying.Readlink(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) && !strings.HasPrefix(target, string(filepath.Separator)) {
+		target = filepath.Join(dir, target)
+	}
+
+	return p.resolve(target, true, hops+1)
+}
+
+// relative returns path relative to the root the rules were defined for.
+func (p *Policy) relative(path string) string {
+	path = filepath.Join(string(filepath.Separator), p.base, path)
+	return strings.TrimPrefix(path, string(filepath.Separator))
+}
+
+// match reports whether pattern matches path or any of its parent dirs.
+func match(pattern, path string) bool {
+	pattern = strings.Trim(filepath.FromSlash(pattern), string(filepath.Separator))
+	if pattern == "" {
+		return true
+	}
+
+	for {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+
+		dir := filepath.Dir(path)
+		if dir == path || dir == "." {
+			return false
+		}
+		path = dir
+	}
+}
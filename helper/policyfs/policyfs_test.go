@@ -0,0 +1,168 @@
+package policyfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func setup(t *testing.T, rules ...Rule) (billy.Filesystem, billy.Filesystem) {
+	t.Helper()
+
+	base := memfs.New()
+	for _, name := range []string{"docs/readme.md", "docs/secret/key", "src/main.go", "foo.md"} {
+		require.NoError(t, util.WriteFile(base, name, []byte(name), 0o644))
+	}
+
+	return New(base, rules...), base
+}
+
+func TestDeniedByDefault(t *testing.T) {
+	fs, _ := setup(t)
+
+	_, err := fs.Open("docs/readme.md")
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	_, err = fs.Stat("src")
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestReadOnlyPrefix(t *testing.T) {
+	fs, _ := setup(t, Allow("docs", Read))
+
+	content, err := util.ReadFile(fs, "docs/readme.md")
+	require.NoError(t, err)
+	assert.Equal(t, "docs/readme.md", string(content))
+
+	_, err = fs.OpenFile("docs/readme.md", os.O_RDWR, 0)
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	err = util.WriteFile(fs, "docs/new", nil, 0o644)
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	assert.ErrorIs(t, fs.Remove("docs/readme.md"), os.ErrPermission)
+
+	_, err = fs.Open("src/main.go")
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestDenyOverridesAllow(t *testing.T) {
+	fs, _ := setup(t, Allow("docs", All), Deny("docs/secret", Read))
+
+	_, err := fs.Open("docs/secret/key")
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	// Only reading was denied.
+	require.NoError(t, fs.Remove("docs/secret/key"))
+
+	infos, err := fs.ReadDir("docs")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "readme.md", infos[0].Name())
+}
+
+func TestGlob(t *testing.T) {
+	fs, _ := setup(t, Allow("*.md", Read))
+
+	_, err := fs.Stat("foo.md")
+	require.NoError(t, err)
+
+	_, err = fs.Stat("docs/readme.md")
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestRename(t *testing.T) {
+	fs, _ := setup(t, Allow("docs", All), Allow("src", Read))
+
+	err := fs.Rename("src/main.go", "docs/main.go")
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	err = fs.Rename("docs/readme.md", "src/readme.md")
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	require.NoError(t, fs.Rename("docs/readme.md", "docs/README.md"))
+}
+
+func TestSymlinkEscape(t *testing.T) {
+	fs, base := setup(t, Allow("docs", All))
+	require.NoError(t, base.Symlink("../src", "docs/src"))
+
+	_, err := fs.Open("docs/src/main.go")
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	err = util.WriteFile(fs, "docs/src/new.go", nil, 0o644)
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	// The link itself can be inspected and removed.
+	_, err = fs.Lstat("docs/src")
+	require.NoError(t, err)
+	require.NoError(t, fs.Remove("docs/src"))
+}
+
+func TestDanglingSymlinkEscape(t *testing.T) {
+	for name, base := range map[string]billy.Filesystem{
+		"memfs": memfs.New(),
+		"osfs":  osfs.New(t.TempDir()),
+	} {
+		require.NoError(t, base.MkdirAll("ok", 0o755), name)
+		require.NoError(t, base.MkdirAll("secret", 0o755), name)
+		require.NoError(t, base.Symlink("../secret/pwn", filepath.Join("ok", "l")), name)
+		require.NoError(t, base.Symlink("../secret/dir/pwn", filepath.Join("ok", "deep")), name)
+
+		fs := New(base, Allow("ok", All), Deny("secret", All))
+		_, err := fs.OpenFile(filepath.Join("ok", "l"), os.O_CREATE|os.O_WRONLY, 0o644)
+		assert.ErrorIs(t, err, os.ErrPermission, name)
+		err = fs.MkdirAll(filepath.Join("ok", "deep"), 0o755)
+		assert.ErrorIs(t, err, os.ErrPermission, name)
+
+		_, err = base.Lstat(filepath.Join("secret", "pwn"))
+		assert.ErrorIs(t, err, os.ErrNotExist, name)
+
+		// Missing paths without links are still resolved lexically.
+		require.NoError(t, util.WriteFile(fs, filepath.Join("ok", "new", "file"), nil, 0o644), name)
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	fs, base := setup(t, Allow("docs", All))
+	require.NoError(t, base.Symlink("b", "docs/a"))
+	require.NoError(t, base.Symlink("a", "docs/b"))
+
+	_, err := fs.OpenFile("docs/a", os.O_CREATE|os.O_WRONLY, 0o644)
+	assert.ErrorIs(t, err, errno.ELOOP)
+	_, err = fs.Stat("docs/a/file")
+	assert.Error(t, err)
+
+	// The links themselves can still be removed.
+	require.NoError(t, fs.Remove("docs/a"))
+}
+
+func TestChroot(t *testing.T) {
+	fs, _ := setup(t, Allow("docs", Read), Deny("docs/secret", Read))
+
+	docs, err := fs.Chroot("docs")
+	require.NoError(t, err)
+
+	_, err = docs.Stat("readme.md")
+	require.NoError(t, err)
+
+	_, err = docs.Stat(filepath.Join("secret", "key"))
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestMatch(t *testing.T) {
+	assert.True(t, match("docs", filepath.FromSlash("docs/a/b")))
+	assert.True(t, match("/docs/", "docs"))
+	assert.True(t, match("*.md", "foo.md"))
+	assert.True(t, match("", "foo"))
+	assert.False(t, match("*.md", filepath.FromSlash("docs/foo.md")))
+	assert.False(t, match("doc", "docs"))
+}
@@ -1,11 +1,14 @@
 package polyfill
 
 import (
+	"crypto"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 // Polyfill is a helper that implements all missing method from billy.Filesystem.
@@ -14,7 +17,7 @@ type Polyfill struct {
 	c capabilities
 }
 
-type capabilities struct{ tempfile, dir, symlink, chroot bool }
+type capabilities struct{ tempfile, dir, symlink, chroot, xattr, change bool }
 
 // New creates a new filesystem wrapping up 'fs' the intercepts all the calls
 // made and errors if fs doesn't implement any of the billy interfaces.
@@ -29,6 +32,8 @@ func New(fs billy.Basic) billy.Filesystem {
 	_, h.c.dir = h.Basic.(billy.Dir)
 	_, h.c.symlink = h.Basic.(billy.Symlink)
 	_, h.c.chroot = h.Basic.(billy.Chroot)
+	_, h.c.xattr = h.Basic.(billy.Xattr)
+	_, h.c.change = h.Basic.(billy.Change)
 	return h
 }
 
@@ -40,6 +45,30 @@ func (h *Polyfill) TempFile(dir, prefix string) (billy.File, error) {
 	return h.Basic.(billy.TempFile).TempFile(dir, prefix)
 }
 
+func (h *Polyfill) TempDir(dir, prefix string) (string, error) {
+	if !h.c.tempfile {
+		return "", billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.TempFile).TempDir(dir, prefix)
+}
+
+func (h *Polyfill) CreateTemp(dir, pattern string) (billy.File, error) {
+	if !h.c.tempfile {
+		return nil, billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.TempFile).CreateTemp(dir, pattern)
+}
+
+func (h *Polyfill) MkdirTemp(dir, pattern string) (string, error) {
+	if !h.c.tempfile {
+		return "", billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.TempFile).MkdirTemp(dir, pattern)
+}
+
 func (h *Polyfill) ReadDir(path string) ([]os.FileInfo, error) {
 	if !h.c.dir {
 		return nil, billy.ErrNotSupported
@@ -48,6 +77,73 @@ func (h *Polyfill) ReadDir(path string) ([]os.FileInfo, error) {
 	return h.Basic.(billy.Dir).ReadDir(path)
 }
 
+// ReadDirNames implements the billy.DirNames interface, using the underlying
+// implementation when available and falling back to ReadDir otherwise.
+func (h *Polyfill) ReadDirNames(path string, n int) ([]string, error) {
+	return util.ReadDirNames(h.Basic, path, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface, using the
+// underlying implementation when available and falling back to ReadDir
+// otherwise.
+func (h *Polyfill) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	return util.ReadDirEntries(h.Basic, path)
+}
+
+// OpenDir implements the billy.DirOpener interface, using the underlying
+// implementation when available and falling back to ReadDir otherwise.
+func (h *Polyfill) OpenDir(path string) (billy.DirIter, error) {
+	return util.OpenDir(h.Basic, path)
+}
+
+// SyncDir implements the billy.DirSyncer interface, using the underlying
+// implementation when available and doing nothing otherwise.
+func (h *Polyfill) SyncDir(path string) error {
+	return util.SyncDir(h.Basic, path)
+}
+
+// HashFile implements the billy.Hasher interface, using the underlying
+// implementation when available and reading the file otherwise.
+func (h *Polyfill) HashFile(path string, hash crypto.Hash) ([]byte, error) {
+	return util.HashFile(h.Basic, path, hash)
+}
+
+// StatMany implements the billy.BatchStater interface, using the underlying
+// implementation when available and calling Stat for each file otherwise.
+func (h *Polyfill) StatMany(paths []string) ([]os.FileInfo, []error) {
+	return util.StatMany(h.Basic, paths)
+}
+
+// Mmap implements the billy.Mapper interface, using the underlying
+// implementation when available and reading the file otherwise.
+func (h *Polyfill) Mmap(path string) (billy.Mapping, error) {
+	return util.Mmap(h.Basic, path)
+}
+
+// CopyFile implements the billy.Copier interface, using the underlying
+// implementation when available and copying the content otherwise.
+func (h *Polyfill) CopyFile(src, dst string) error {
+	return util.CopyFile(h.Basic, src, dst)
+}
+
+// RemoveAll removes path and any children it contains, using the underlying
+// implementation when available. See util.RemoveAll.
+func (h *Polyfill) RemoveAll(path string) error {
+	return util.RemoveAll(h.Basic, path)
+}
+
+// RenameNoReplace implements the billy.Renamer interface, returning
+// billy.ErrNotSupported if the underlying filesystem does not implement it.
+func (h *Polyfill) RenameNoReplace(from, to string) error {
+	return util.RenameNoReplace(h.Basic, from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface, returning
+// billy.ErrNotSupported if the underlying filesystem does not implement it.
+func (h *Polyfill) RenameExchange(from, to string) error {
+	return util.RenameExchange(h.Basic, from, to)
+}
+
 func (h *Polyfill) MkdirAll(filename string, perm fs.FileMode) error {
 	if !h.c.dir {
 		return billy.ErrNotSupported
@@ -56,25 +152,37 @@ func (h *Polyfill) MkdirAll(filename string, perm fs.FileMode) error {
 	return h.Basic.(billy.Dir).MkdirAll(filename, perm)
 }
 
+// Symlink creates a symlink with the underlying implementation. If there is
+// none, it fails with os.ErrInvalid.
 func (h *Polyfill) Symlink(target, link string) error {
 	if !h.c.symlink {
-		return billy.ErrNotSupported
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrInvalid}
 	}
 
 	return h.Basic.(billy.Symlink).Symlink(target, link)
 }
 
+// Readlink returns the target of link with the underlying implementation. If
+// there is none, no file is a symlink, so it fails with os.ErrInvalid, as
+// readlink does on files which are not symlinks.
 func (h *Polyfill) Readlink(link string) (string, error) {
 	if !h.c.symlink {
-		return "", billy.ErrNotSupported
+		if _, err := h.Basic.Stat(link); err != nil {
+			return "", err
+		}
+
+		return "", &os.PathError{Op: "readlink", Path: link, Err: os.ErrInvalid}
 	}
 
 	return h.Basic.(billy.Symlink).Readlink(link)
 }
 
+// Lstat returns the info of path with the underlying implementation. If there
+// is none, no file is a symlink, so Lstat is the same as Stat, and walking
+// the filesystem with util.Walk works over backends without symlinks.
 func (h *Polyfill) Lstat(path string) (os.FileInfo, error) {
 	if !h.c.symlink {
-		return nil, billy.ErrNotSupported
+		return h.Basic.Stat(path)
 	}
 
 	return h.Basic.(billy.Symlink).Lstat(path)
@@ -96,11 +204,108 @@ func (h *Polyfill) Root() string {
 	return h.Basic.(billy.Chroot).Root()
 }
 
+func (h *Polyfill) GetXattr(path, name string) ([]byte, error) {
+	if !h.c.xattr {
+		return nil, billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Xattr).GetXattr(path, name)
+}
+
+func (h *Polyfill) SetXattr(path, name string, value []byte) error {
+	if !h.c.xattr {
+		return billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Xattr).SetXattr(path, name, value)
+}
+
+func (h *Polyfill) ListXattr(path string) ([]string, error) {
+	if !h.c.xattr {
+		return nil, billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Xattr).ListXattr(path)
+}
+
+func (h *Polyfill) RemoveXattr(path, name string) error {
+	if !h.c.xattr {
+		return billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Xattr).RemoveXattr(path, name)
+}
+
+func (h *Polyfill) Chmod(name string, mode fs.FileMode) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Change).Chmod(name, mode)
+}
+
+func (h *Polyfill) Lchown(name string, uid, gid int) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Change).Lchown(name, uid, gid)
+}
+
+func (h *Polyfill) Chown(name string, uid, gid int) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Change).Chown(name, uid, gid)
+}
+
+func (h *Polyfill) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	return h.Basic.(billy.Change).Chtimes(name, atime, mtime)
+}
+
+// Lchmod implements the billy.SymlinkChange interface, using the underlying
+// implementation when available and the Change interface for the files
+// which are not symlinks otherwise. See util.Lchmod.
+func (h *Polyfill) Lchmod(name string, mode fs.FileMode) error {
+	return util.Lchmod(h.Basic, name, mode)
+}
+
+// Lchtimes implements the billy.SymlinkChange interface, as Lchmod does. See
+// util.Lchtimes.
+func (h *Polyfill) Lchtimes(name string, atime time.Time, mtime time.Time) error {
+	return util.Lchtimes(h.Basic, name, atime, mtime)
+}
+
 func (h *Polyfill) Underlying() billy.Basic {
 	return h.Basic
 }
 
+// Layers implements billy.Composite.
+func (h *Polyfill) Layers() []billy.Basic {
+	return []billy.Basic{h.Basic}
+}
+
+func (h *Polyfill) String() string {
+	return "polyfill"
+}
+
 // Capabilities implements the Capable interface.
 func (h *Polyfill) Capabilities() billy.Capability {
-	return billy.Capabilities(h.Basic)
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Basic))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Polyfill) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Basic, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Polyfill) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Basic)
 }
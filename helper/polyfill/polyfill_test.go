@@ -1,12 +1,16 @@
 package polyfill
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/internal/test"
-	"github.com/stretchr/testify/assert"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 var (
@@ -30,17 +34,19 @@ func TestMkdirAll(t *testing.T) {
 
 func TestSymlink(t *testing.T) {
 	err := helper.Symlink("", "")
-	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, err, os.ErrInvalid)
 }
 
 func TestReadlink(t *testing.T) {
 	_, err := helper.Readlink("")
-	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, err, os.ErrInvalid)
 }
 
 func TestLstat(t *testing.T) {
-	_, err := helper.Lstat("")
-	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	m := &test.BasicMock{}
+	_, err := New(m).Lstat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, m.StatArgs)
 }
 
 func TestChroot(t *testing.T) {
@@ -63,5 +69,70 @@ func testCapabilities(t *testing.T, basic billy.Basic) {
 
 	fs := New(basic)
 	capabilities := billy.Capabilities(fs)
-	assert.Equal(t, baseCapabilities, capabilities)
+	assert.Equal(t, baseCapabilities|billy.RemoveAllCapability, capabilities)
+}
+
+func TestReadDirNames(t *testing.T) {
+	_, err := helper.(billy.DirNames).ReadDirNames("", 0)
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+
+	fs := New(&test.DirMock{})
+	names, err := fs.(billy.DirNames).ReadDirNames("foo", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestOpenDir(t *testing.T) {
+	_, err := helper.(billy.DirOpener).OpenDir("")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+func TestReadDirEntries(t *testing.T) {
+	_, err := helper.(billy.DirEntries).ReadDirEntries("")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+
+	fs := New(&test.DirMock{})
+	entries, err := fs.(billy.DirEntries).ReadDirEntries("foo")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRenamer(t *testing.T) {
+	r := helper.(billy.Renamer)
+	assert.ErrorIs(t, r.RenameNoReplace("foo", "bar"), billy.ErrNotSupported)
+	assert.ErrorIs(t, r.RenameExchange("foo", "bar"), billy.ErrNotSupported)
+}
+
+func TestXattr(t *testing.T) {
+	x := helper.(billy.Xattr)
+	_, err := x.GetXattr("", "")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, x.SetXattr("", "", nil), billy.ErrNotSupported)
+	_, err = x.ListXattr("")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	assert.ErrorIs(t, x.RemoveXattr("", ""), billy.ErrNotSupported)
+}
+
+func TestChange(t *testing.T) {
+	c := helper.(billy.Change)
+	assert.ErrorIs(t, c.Chmod("", 0), billy.ErrNotSupported)
+	assert.ErrorIs(t, c.Lchown("", 0, 0), billy.ErrNotSupported)
+	assert.ErrorIs(t, c.Chown("", 0, 0), billy.ErrNotSupported)
+	assert.ErrorIs(t, c.Chtimes("", time.Time{}, time.Time{}), billy.ErrNotSupported)
+}
+
+type removeAllMock struct {
+	test.BasicMock
+	removeAllArgs []string
+}
+
+func (m *removeAllMock) RemoveAll(path string) error {
+	m.removeAllArgs = append(m.removeAllArgs, path)
+	return nil
+}
+
+func TestRemoveAll(t *testing.T) {
+	m := &removeAllMock{}
+	require.NoError(t, util.RemoveAll(New(m), "foo"))
+	assert.Equal(t, []string{"foo"}, m.removeAllArgs)
 }
@@ -0,0 +1,502 @@
+// Package prefixfs provides a billy filesystem wrapper which exposes a whole
+// filesystem under a virtual prefix, the inverse of a chroot.
+package prefixfs
+
+import (
+	"crypto"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/helper/polyfill"
+	"github.com/go-git/go-billy/v6/internal/pathutil"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+var separator = string(filepath.Separator)
+
+// Prefix is a helper that exposes a filesystem under a virtual prefix, so
+// that, for example, an osfs bound to /repo appears at /virtual/repo. Along
+// with mount, it allows to compose complete virtual trees out of several
+// filesystems.
+//
+// The parents of the prefix are virtual directories, which can be stat'ed
+// and listed, listing only the next element of the prefix, but not opened
+// nor modified. Any other path out of the prefix does not exist, and cannot
+// be created.
+type Prefix struct {
+	underlying billy.Filesystem
+	prefix     string
+	mapper     billy.PathMapper
+}
+
+// Option configures a Prefix.
+type Option func(*Prefix)
+
+// WithPathMapper maps the paths given to the filesystem with m before they
+// are cleaned, and matched against the prefix, which is mapped as well.
+func WithPathMapper(m billy.PathMapper) Option {
+	return func(h *Prefix) {
+		h.mapper = m
+	}
+}
+
+// New creates a new filesystem wrapping up fs, whose root is exposed at
+// prefix.
+func New(fs billy.Basic, prefix string, opts ...Option) billy.Filesystem {
+	h := &Prefix{underlying: polyfill.New(fs)}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.prefix = h.cleanPath(prefix)
+	return h
+}
+
+func (h *Prefix) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Prefix) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (h *Prefix) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	fullpath, err := h.underlyingPath("open", filename, flag&os.O_CREATE != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := h.underlying.OpenFile(fullpath, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrappedfile.New(f, h.cleanPath(filename), filename), nil
+}
+
+func (h *Prefix) Stat(filename string) (os.FileInfo, error) {
+	return h.stat("stat", filename, h.underlying.Stat)
+}
+
+func (h *Prefix) Lstat(filename string) (os.FileInfo, error) {
+	return h.stat("lstat", filename, h.underlying.Lstat)
+}
+
+func (h *Prefix) stat(op, filename string, stat func(string) (os.FileInfo, error)) (os.FileInfo, error) {
+	path := h.cleanPath(filename)
+	if h.isVirtual(path) {
+		return &dirInfo{name: filepath.Base(path)}, nil
+	}
+
+	fullpath, err := h.underlyingPath(op, filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := stat(fullpath)
+	if err != nil || fullpath != "." {
+		return fi, err
+	}
+
+	return &namedInfo{FileInfo: fi, name: filepath.Base(h.prefix)}, nil
+}
+
+func (h *Prefix) Rename(from, to string) error {
+	fromPath, err := h.underlyingPath("rename", from, true)
+	if err != nil {
+		return err
+	}
+
+	toPath, err := h.underlyingPath("rename", to, true)
+	if err != nil {
+		return err
+	}
+
+	return h.underlying.Rename(fromPath, toPath)
+}
+
+func (h *Prefix) Remove(filename string) error {
+	fullpath, err := h.underlyingPath("remove", filename, true)
+	if err != nil {
+		return err
+	}
+	if fullpath == "." {
+		return &os.PathError{Op: "remove", Path: filename, Err: os.ErrInvalid}
+	}
+
+	return h.underlying.Remove(fullpath)
+}
+
+// RemoveAll removes path and any children it contains, using the RemoveAll
+// of the wrapped filesystem when available. See util.RemoveAll.
+//
+// The prefix and its parents cannot be removed, so removing any of them
+// empties the wrapped filesystem instead.
+func (h *Prefix) RemoveAll(path string) error {
+	if !h.isVirtual(h.cleanPath(path)) {
+		fullpath, err := h.underlyingPath("removeall", path, true)
+		if err != nil {
+			return err
+		}
+		if fullpath != "." {
+			return util.RemoveAll(h.underlying, fullpath)
+		}
+	}
+
+	names, err := util.ReadDirNames(h.underlying, ".", 0)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := util.RemoveAll(h.underlying, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Prefix) Join(elem ...string) string {
+	return h.underlying.Join(elem...)
+}
+
+func (h *Prefix) TempFile(dir, prefix string) (billy.File, error) {
+	return h.createTemp("tempfile", dir, func(fullpath string) (billy.File, error) {
+		return h.underlying.TempFile(fullpath, prefix)
+	})
+}
+
+func (h *Prefix) CreateTemp(dir, pattern string) (billy.File, error) {
+	return h.createTemp("createtemp", dir, func(fullpath string) (billy.File, error) {
+		return h.underlying.CreateTemp(fullpath, pattern)
+	})
+}
+
+func (h *Prefix) createTemp(op, dir string, create func(string) (billy.File, error)) (billy.File, error) {
+	fullpath, err := h.underlyingPath(op, dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := create(fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Temp files have no path presented by the caller, so both names are
+	// the path within the virtual tree.
+	name := filepath.Join(h.cleanPath(dir), filepath.Base(f.Name()))
+	return wrappedfile.New(f, name, name), nil
+}
+
+func (h *Prefix) TempDir(dir, prefix string) (string, error) {
+	return h.mkdirTemp("tempdir", dir, func(fullpath string) (string, error) {
+		return h.underlying.TempDir(fullpath, prefix)
+	})
+}
+
+func (h *Prefix) MkdirTemp(dir, pattern string) (string, error) {
+	return h.mkdirTemp("mkdirtemp", dir, func(fullpath string) (string, error) {
+		return h.underlying.MkdirTemp(fullpath, pattern)
+	})
+}
+
+func (h *Prefix) mkdirTemp(op, dir string, mkdir func(string) (string, error)) (string, error) {
+	fullpath, err := h.underlyingPath(op, dir, true)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := mkdir(fullpath)
+	if err != nil {
+		return "", err
+	}
+
+	return h.Join(dir, filepath.Base(name)), nil
+}
+
+func (h *Prefix) ReadDir(path string) ([]os.FileInfo, error) {
+	clean := h.cleanPath(path)
+	if h.isVirtual(clean) {
+		return []os.FileInfo{&dirInfo{name: h.virtualChild(clean)}}, nil
+	}
+
+	fullpath, err := h.underlyingPath("readdir", path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.underlying.ReadDir(fullpath)
+}
+
+func (h *Prefix) MkdirAll(filename string, perm fs.FileMode) error {
+	if h.isVirtual(h.cleanPath(filename)) {
+		return nil
+	}
+
+	fullpath, err := h.underlyingPath("mkdir", filename, true)
+	if err != nil {
+		return err
+	}
+
+	return h.underlying.MkdirAll(fullpath, perm)
+}
+
+// HashFile implements the billy.Hasher interface.
+func (h *Prefix) HashFile(path string, hash crypto.Hash) ([]byte, error) {
+	fullpath, err := h.underlyingPath("hash", path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.HashFile(h.underlying, fullpath, hash)
+}
+
+// Mmap implements the billy.Mapper interface.
+func (h *Prefix) Mmap(path string) (billy.Mapping, error) {
+	fullpath, err := h.underlyingPath("mmap", path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.Mmap(h.underlying, fullpath)
+}
+
+// CopyFile implements the billy.Copier interface.
+func (h *Prefix) CopyFile(src, dst string) error {
+	srcPath, err := h.underlyingPath("copy", src, false)
+	if err != nil {
+		return err
+	}
+
+	dstPath, err := h.underlyingPath("copy", dst, false)
+	if err != nil {
+		return err
+	}
+
+	return util.CopyFile(h.underlying, srcPath, dstPath)
+}
+
+// Chmod implements the billy.Change interface. As the other methods of
+// billy.Change, it fails with os.ErrPermission out of the prefix.
+func (h *Prefix) Chmod(name string, mode fs.FileMode) error {
+	fullpath, err := h.underlyingPath("chmod", name, true)
+	if err != nil {
+		return err
+	}
+
+	return util.Chmod(h.underlying, fullpath, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Prefix) Lchown(name string, uid, gid int) error {
+	fullpath, err := h.underlyingPath("lchown", name, true)
+	if err != nil {
+		return err
+	}
+
+	return util.Lchown(h.underlying, fullpath, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Prefix) Chown(name string, uid, gid int) error {
+	fullpath, err := h.underlyingPath("chown", name, true)
+	if err != nil {
+		return err
+	}
+
+	return util.Chown(h.underlying, fullpath, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Prefix) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fullpath, err := h.underlyingPath("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+
+	return util.Chtimes(h.underlying, fullpath, atime, mtime)
+}
+
+// Symlink creates a symlink in the wrapped filesystem. Absolute targets are
+// paths within the virtual tree, and are rewritten to the wrapped
+// filesystem. Targets out of the prefix are refused with
+// billy.ErrCrossedBoundary, as they cannot be expressed in it.
+func (h *Prefix) Symlink(target, link string) error {
+	fullpath, err := h.underlyingPath("symlink", link, true)
+	if err != nil {
+		return err
+	}
+
+	target = filepath.FromSlash(target)
+	abs := pathutil.IsAbs(target)
+
+	resolved := target
+	if !abs {
+		resolved = filepath.Join(filepath.Dir(h.cleanPath(link)), target)
+	}
+
+	rel, ok := h.rel(pathutil.Clean(resolved))
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: billy.ErrCrossedBoundary}
+	}
+
+	if abs {
+		target = filepath.Join(separator, rel)
+	}
+
+	return h.underlying.Symlink(target, fullpath)
+}
+
+// Readlink returns the target of link. Absolute targets are rewritten to
+// paths within the virtual tree.
+func (h *Prefix) Readlink(link string) (string, error) {
+	if h.isVirtual(h.cleanPath(link)) {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: os.ErrInvalid}
+	}
+
+	fullpath, err := h.underlyingPath("readlink", link, false)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := h.underlying.Readlink(fullpath)
+	if err != nil {
+		return "", err
+	}
+
+	if !pathutil.IsAbs(target) {
+		return target, nil
+	}
+
+	return separator + filepath.Join(h.prefix, pathutil.Clean(target)), nil
+}
+
+func (h *Prefix) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(h, path), nil
+}
+
+// Root returns the root of the virtual tree.
+func (h *Prefix) Root() string {
+	return separator
+}
+
+func (h *Prefix) Underlying() billy.Basic {
+	return h.underlying
+}
+
+// Layers implements billy.Composite.
+func (h *Prefix) Layers() []billy.Basic {
+	return []billy.Basic{h.underlying}
+}
+
+// String describes the filesystem along with the prefix its root is exposed
+// at.
+func (h *Prefix) String() string {
+	return "prefixfs(" + h.prefix + ")"
+}
+
+// Capabilities implements the Capable interface.
+func (h *Prefix) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.underlying))
+}
+
+// Supports implements the billy.FeatureReporter interface. Chroot is
+// always supported, and the other features are the ones of the wrapped
+// filesystem.
+func (h *Prefix) Supports(f billy.Feature) bool {
+	return f == billy.ChrootFeature || billy.Supports(h.underlying, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Prefix) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.underlying)
+}
+
+// underlyingPath returns the path of filename in the wrapped filesystem. If
+// filename is out of the prefix, the error returned for op is
+// os.ErrPermission if the operation modifies the filesystem, or if filename
+// is a virtual directory, and os.ErrNotExist otherwise.
+func (h *Prefix) underlyingPath(op, filename string, mutating bool) (string, error) {
+	path := h.cleanPath(filename)
+	if rel, ok := h.rel(path); ok {
+		return rel, nil
+	}
+
+	err := os.ErrNotExist
+	if mutating || h.isVirtual(path) {
+		err = os.ErrPermission
+	}
+
+	return "", &os.PathError{Op: op, Path: filename, Err: err}
+}
+
+// rel returns path relative to the prefix, if it is within it.
+func (h *Prefix) rel(path string) (string, bool) {
+	switch {
+	case h.prefix == ".":
+		return path, true
+	case path == h.prefix:
+		return ".", true
+	case strings.HasPrefix(path, h.prefix+separator):
+		return path[len(h.prefix)+1:], true
+	default:
+		return "", false
+	}
+}
+
+// isVirtual reports whether path is one of the parents of the prefix.
+func (h *Prefix) isVirtual(path string) bool {
+	if h.prefix == "." {
+		return false
+	}
+
+	return path == "." || strings.HasPrefix(h.prefix, path+separator)
+}
+
+// virtualChild returns the element of the prefix following the virtual
+// directory path.
+func (h *Prefix) virtualChild(path string) string {
+	rest := h.prefix
+	if path != "." {
+		rest = rest[len(path)+1:]
+	}
+
+	child, _, _ := strings.Cut(rest, separator)
+	return child
+}
+
+// cleanPath maps path with the PathMapper of h, if any, and cleans it.
+func (h *Prefix) cleanPath(path string) string {
+	return pathutil.Clean(pathutil.Map(h.mapper, path))
+}
+
+// dirInfo describes a virtual directory.
+type dirInfo struct {
+	name string
+}
+
+func (fi *dirInfo) Name() string       { return fi.name }
+func (fi *dirInfo) Size() int64        { return 0 }
+func (fi *dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (fi *dirInfo) ModTime() time.Time { return time.Time{} }
+func (fi *dirInfo) IsDir() bool        { return true }
+func (fi *dirInfo) Sys() interface{}   { return nil }
+
+// namedInfo renames the info of the root of the wrapped filesystem after the
+// last element of the prefix.
+type namedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *namedInfo) Name() string {
+	return fi.name
+}
@@ -0,0 +1,211 @@
+package prefixfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/mount"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func setup(t *testing.T) (billy.Filesystem, billy.Filesystem) {
+	t.Helper()
+
+	underlying := memfs.New()
+	require.NoError(t, util.WriteFile(underlying, "foo", []byte("foo"), 0o644))
+
+	return New(underlying, "/virtual/repo"), underlying
+}
+
+func TestOpen(t *testing.T) {
+	fs, _ := setup(t)
+
+	f, err := fs.Open("/virtual/repo/foo")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("virtual", "repo", "foo"), f.Name())
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "virtual/repo/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+}
+
+func TestCreate(t *testing.T) {
+	fs, underlying := setup(t)
+
+	require.NoError(t, util.WriteFile(fs, "virtual/repo/bar", []byte("bar"), 0o644))
+
+	content, err := util.ReadFile(underlying, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(content))
+}
+
+func TestWithPathMapper(t *testing.T) {
+	underlying := memfs.New()
+	fs := New(underlying, "/Virtual/Repo", WithPathMapper(billy.PathMapperFunc(strings.ToLower)))
+
+	require.NoError(t, util.WriteFile(fs, "/VIRTUAL/repo/Bar", []byte("bar"), 0o644))
+
+	content, err := util.ReadFile(underlying, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(content))
+
+	entries, err := fs.ReadDir("/")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "virtual", entries[0].Name())
+}
+
+func TestVirtualDirs(t *testing.T) {
+	fs, _ := setup(t)
+
+	for _, path := range []string{"/", "virtual"} {
+		fi, err := fs.Stat(path)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		_, err = fs.Open(path)
+		require.ErrorIs(t, err, os.ErrPermission)
+
+		require.NoError(t, fs.MkdirAll(path, 0o755))
+	}
+
+	infos, err := fs.ReadDir("/")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "virtual", infos[0].Name())
+
+	infos, err = fs.ReadDir("virtual")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "repo", infos[0].Name())
+	assert.True(t, infos[0].IsDir())
+
+	fi, err := fs.Stat("virtual/repo")
+	require.NoError(t, err)
+	assert.Equal(t, "repo", fi.Name())
+	assert.True(t, fi.IsDir())
+
+	infos, err = fs.ReadDir("virtual/repo")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "foo", infos[0].Name())
+}
+
+func TestOutsidePrefix(t *testing.T) {
+	fs, _ := setup(t)
+
+	_, err := fs.Stat("foo")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = fs.ReadDir("virtual/other")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = fs.Create("virtual/bar")
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	err = fs.MkdirAll("other", 0o755)
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	err = fs.Rename("virtual/repo/foo", "virtual/foo")
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	err = fs.Remove("virtual")
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	err = fs.Remove("virtual/repo")
+	require.ErrorIs(t, err, os.ErrInvalid)
+
+	err = util.Chmod(fs, "virtual", 0o700)
+	require.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestChange(t *testing.T) {
+	fs, underlying := setup(t)
+
+	require.NoError(t, util.Chmod(fs, "/virtual/repo/foo", 0o600))
+	fi, err := underlying.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+}
+
+func TestRemoveAll(t *testing.T) {
+	fs, underlying := setup(t)
+
+	require.NoError(t, util.RemoveAll(fs, "virtual"))
+
+	names, err := util.ReadDirNames(underlying, "/", 0)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	_, err = fs.Stat("virtual/repo")
+	require.NoError(t, err)
+}
+
+func TestSymlink(t *testing.T) {
+	fs, underlying := setup(t)
+
+	require.NoError(t, fs.Symlink("/virtual/repo/foo", "virtual/repo/abs"))
+	require.NoError(t, fs.Symlink("foo", "virtual/repo/rel"))
+
+	target, err := underlying.Readlink("abs")
+	require.NoError(t, err)
+	assert.Equal(t, string(filepath.Separator)+"foo", target)
+
+	target, err = fs.Readlink("virtual/repo/abs")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(string(filepath.Separator), "virtual", "repo", "foo"), target)
+
+	target, err = fs.Readlink("virtual/repo/rel")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", target)
+
+	content, err := util.ReadFile(fs, "virtual/repo/abs")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	err = fs.Symlink("/other", "virtual/repo/out")
+	require.ErrorIs(t, err, billy.ErrCrossedBoundary)
+	err = fs.Symlink("../../foo", "virtual/repo/out")
+	require.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+	_, err = fs.Readlink("virtual")
+	require.ErrorIs(t, err, os.ErrInvalid)
+}
+
+func TestTempFile(t *testing.T) {
+	fs, _ := setup(t)
+
+	f, err := fs.TempFile("virtual/repo", "tmp")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("virtual", "repo"), filepath.Dir(f.Name()))
+	require.NoError(t, f.Close())
+
+	_, err = fs.Stat(f.Name())
+	require.NoError(t, err)
+
+	_, err = fs.TempFile("virtual", "tmp")
+	require.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestMount(t *testing.T) {
+	a, b := memfs.New(), memfs.New()
+	require.NoError(t, util.WriteFile(a, "foo", []byte("a"), 0o644))
+	require.NoError(t, util.WriteFile(b, "foo", []byte("b"), 0o644))
+
+	fs := mount.New(New(a, "repos/a"), "repos/b", b)
+
+	content, err := util.ReadFile(fs, "repos/a/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+
+	content, err = util.ReadFile(fs, "repos/b/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+}
@@ -0,0 +1,553 @@
+// Package recordfs provides a billy filesystem wrapper which records the
+// operations made over the filesystem it wraps, along with their results,
+// and a way to replay them over another filesystem, reporting where both
+// filesystems behave differently.
+package recordfs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// Entry is an operation recorded in the log, one per line in JSON.
+type Entry struct {
+	// Op is the name of the operation, such as "open", "rename" or "write".
+	Op string `json:"op"`
+	// Path is the path the operation applies to, relative to the root of
+	// the recorded filesystem.
+	Path string `json:"path,omitempty"`
+	// NewPath is the destination of a rename, the target of a symlink, or
+	// the prefix of a temp file or dir.
+	NewPath string `json:"newpath,omitempty"`
+	// File identifies the file opened by an open, or the file the
+	// operations on files are made on.
+	File int `json:"file,omitempty"`
+	// Flag is the flag an open was made with.
+	Flag int `json:"flag,omitempty"`
+	// Perm is the permission an open or mkdirall was made with, or the mode
+	// set by a chmod or fchmod.
+	Perm fs.FileMode `json:"perm,omitempty"`
+	// UID and GID are the ids set by a chown or lchown.
+	UID int `json:"uid,omitempty"`
+	GID int `json:"gid,omitempty"`
+	// Atime and Mtime are the times set by a chtimes or fchtimes.
+	Atime time.Time `json:"atime,omitzero"`
+	Mtime time.Time `json:"mtime,omitzero"`
+	// Offset is the offset of a readat, writeat or seek, and the size of a
+	// truncate.
+	Offset int64 `json:"offset,omitempty"`
+	// Whence is the whence of a seek.
+	Whence int `json:"whence,omitempty"`
+	// Size is the size of the buffer of a read or readat, and the count of
+	// a freaddir.
+	Size int `json:"size,omitempty"`
+	// Data is the data written by a write, or the data read by a read.
+	Data []byte `json:"data,omitempty"`
+
+	// N is the number of bytes read or written, the position returned by a
+	// seek, or the size of a regular file returned by a stat.
+	N int64 `json:"n,omitempty"`
+	// Mode is the mode of a file returned by a stat.
+	Mode fs.FileMode `json:"mode,omitempty"`
+	// Names are the names of the entries returned by a readdir or freaddir.
+	Names []string `json:"names,omitempty"`
+	// Target is the target returned by a readlink, or the name returned by a
+	// tempfile, tempdir, createtemp or mkdirtemp.
+	Target string `json:"target,omitempty"`
+	// Err is the kind of the error returned by the operation, if any, such
+	// as "notexist" or "eof", and ErrMessage its message.
+	Err        string `json:"err,omitempty"`
+	ErrMessage string `json:"errmsg,omitempty"`
+}
+
+// errorKind returns the kind of err recorded in the log, which is portable
+// across filesystems, unlike their messages.
+func errorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, io.EOF):
+		return "eof"
+	case errors.Is(err, os.ErrNotExist):
+		return "notexist"
+	case errors.Is(err, os.ErrExist):
+		return "exist"
+	case errors.Is(err, os.ErrPermission):
+		return "permission"
+	case errors.Is(err, os.ErrClosed):
+		return "closed"
+	case errors.Is(err, os.ErrInvalid):
+		return "invalid"
+	case errors.Is(err, billy.ErrNotSupported):
+		return "notsupported"
+	default:
+		return "error"
+	}
+}
+
+// setResult sets the result of an operation returning err, and the info of
+// a file, if not nil.
+func (e *Entry) setResult(fi os.FileInfo, err error) {
+	if fi != nil {
+		e.Mode = fi.Mode()
+		if fi.Mode().IsRegular() {
+			e.N = fi.Size()
+		}
+	}
+
+	e.Err = errorKind(err)
+	if err != nil {
+		e.ErrMessage = err.Error()
+	}
+}
+
+// log is the log shared by a Recorder and its chroots.
+type log struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	err   error
+	files int
+}
+
+func (l *log) record(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err == nil {
+		l.err = l.enc.Encode(e)
+	}
+}
+
+func (l *log) nextFile() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.files++
+	return l.files
+}
+
+// Recorder is a helper that records the operations made over any
+// billy.Filesystem, including the ones made on the files it opens, to be
+// replayed later with Replay.
+type Recorder struct {
+	underlying billy.Filesystem
+	base       string
+	log        *log
+}
+
+// New creates a new filesystem wrapping up fs which records its operations
+// to w, one Entry per line.
+func New(fs billy.Filesystem, w io.Writer) *Recorder {
+	return &Recorder{
+		underlying: fs,
+		log:        &log{enc: json.NewEncoder(w)},
+	}
+}
+
+// Layers implements billy.Composite.
+func (r *Recorder) Layers() []billy.Basic {
+	return []billy.Basic{r.underlying}
+}
+
+// String describes the recorder along with the dir it was chrooted to, if
+// any.
+func (r *Recorder) String() string {
+	if r.base == "" {
+		return "recordfs"
+	}
+
+	return "recordfs(" + r.base + ")"
+}
+
+// Err returns the first error writing the log, if any. Operations are not
+// recorded after it.
+func (r *Recorder) Err() error {
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+
+	return r.log.err
+}
+
+func (r *Recorder) Create(filename string) (billy.File, error) {
+	return r.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (r *Recorder) Open(filename string) (billy.File, error) {
+	return r.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (r *Recorder) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	f, err := r.underlying.OpenFile(filename, flag, perm)
+	e := Entry{Op: "open", Path: r.path(filename), Flag: flag, Perm: perm}
+	return r.recordFile(e, f, err)
+}
+
+func (r *Recorder) Stat(filename string) (os.FileInfo, error) {
+	fi, err := r.underlying.Stat(filename)
+	e := Entry{Op: "stat", Path: r.path(filename)}
+	e.setResult(fi, err)
+	r.log.record(e)
+
+	return fi, err
+}
+
+func (r *Recorder) Rename(from, to string) error {
+	err := r.underlying.Rename(from, to)
+	e := Entry{Op: "rename", Path: r.path(from), NewPath: r.path(to)}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+func (r *Recorder) Remove(filename string) error {
+	err := r.underlying.Remove(filename)
+	e := Entry{Op: "remove", Path: r.path(filename)}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+func (r *Recorder) Join(elem ...string) string {
+	return r.underlying.Join(elem...)
+}
+
+// TempFile creates a temp file like the wrapped filesystem. It is replayed
+// as the exclusive creation of a file with the same name.
+func (r *Recorder) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := r.underlying.TempFile(dir, prefix)
+	e := Entry{Op: "tempfile", Path: r.path(dir), NewPath: prefix}
+	if f != nil {
+		e.Target = r.path(f.Name())
+	}
+
+	return r.recordFile(e, f, err)
+}
+
+// TempDir creates a temp dir like the wrapped filesystem. It is replayed as
+// the creation of a dir with the same name.
+func (r *Recorder) TempDir(dir, prefix string) (string, error) {
+	name, err := r.underlying.TempDir(dir, prefix)
+	e := Entry{Op: "tempdir", Path: r.path(dir), NewPath: prefix}
+	if err == nil {
+		e.Target = r.path(name)
+	}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return name, err
+}
+
+// CreateTemp creates a temp file like the wrapped filesystem, recording the
+// pattern as the new path. It is replayed as TempFile is.
+func (r *Recorder) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := r.underlying.CreateTemp(dir, pattern)
+	e := Entry{Op: "createtemp", Path: r.path(dir), NewPath: pattern}
+	if f != nil {
+		e.Target = r.path(f.Name())
+	}
+
+	return r.recordFile(e, f, err)
+}
+
+// MkdirTemp creates a temp dir like the wrapped filesystem, recording the
+// pattern as the new path. It is replayed as TempDir is.
+func (r *Recorder) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := r.underlying.MkdirTemp(dir, pattern)
+	e := Entry{Op: "mkdirtemp", Path: r.path(dir), NewPath: pattern}
+	if err == nil {
+		e.Target = r.path(name)
+	}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return name, err
+}
+
+func (r *Recorder) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := r.underlying.ReadDir(path)
+	e := Entry{Op: "readdir", Path: r.path(path), Names: names(infos)}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return infos, err
+}
+
+func (r *Recorder) MkdirAll(filename string, perm fs.FileMode) error {
+	err := r.underlying.MkdirAll(filename, perm)
+	e := Entry{Op: "mkdirall", Path: r.path(filename), Perm: perm}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+func (r *Recorder) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := r.underlying.Lstat(filename)
+	e := Entry{Op: "lstat", Path: r.path(filename)}
+	e.setResult(fi, err)
+	r.log.record(e)
+
+	return fi, err
+}
+
+func (r *Recorder) Symlink(target, link string) error {
+	err := r.underlying.Symlink(target, link)
+	e := Entry{Op: "symlink", Path: r.path(link), NewPath: target}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+func (r *Recorder) Readlink(link string) (string, error) {
+	target, err := r.underlying.Readlink(link)
+	e := Entry{Op: "readlink", Path: r.path(link), Target: target}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return target, err
+}
+
+// Chmod implements the billy.Change interface.
+func (r *Recorder) Chmod(name string, mode fs.FileMode) error {
+	err := util.Chmod(r.underlying, name, mode)
+	e := Entry{Op: "chmod", Path: r.path(name), Perm: mode}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+// Lchown implements the billy.Change interface.
+func (r *Recorder) Lchown(name string, uid, gid int) error {
+	err := util.Lchown(r.underlying, name, uid, gid)
+	e := Entry{Op: "lchown", Path: r.path(name), UID: uid, GID: gid}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+// Chown implements the billy.Change interface.
+func (r *Recorder) Chown(name string, uid, gid int) error {
+	err := util.Chown(r.underlying, name, uid, gid)
+	e := Entry{Op: "chown", Path: r.path(name), UID: uid, GID: gid}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+// Chtimes implements the billy.Change interface.
+func (r *Recorder) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	err := util.Chtimes(r.underlying, name, atime, mtime)
+	e := Entry{Op: "chtimes", Path: r.path(name), Atime: atime, Mtime: mtime}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	return err
+}
+
+// Chroot returns a new Recorder over the result of the Chroot method of the
+// wrapped filesystem, recording to the same log. The paths it records are
+// relative to the root of r, so the log can be replayed as a whole.
+func (r *Recorder) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := r.underlying.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{underlying: fs, base: r.path(path), log: r.log}, nil
+}
+
+func (r *Recorder) Root() string {
+	return r.underlying.Root()
+}
+
+// Capabilities implements the Capable interface.
+func (r *Recorder) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(r, billy.Capabilities(r.underlying))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (r *Recorder) Supports(f billy.Feature) bool {
+	return billy.Supports(r.underlying, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (r *Recorder) PathProperties() billy.PathProperties {
+	return billy.Introspect(r.underlying)
+}
+
+// path returns path relative to the root of the recorded filesystem.
+func (r *Recorder) path(path string) string {
+	path = filepath.Join(string(filepath.Separator), r.base, path)
+	return filepath.ToSlash(strings.TrimPrefix(path, string(filepath.Separator)))
+}
+
+// recordFile records e as the operation which opened f, returning the file
+// wrapped so its operations are recorded too.
+func (r *Recorder) recordFile(e Entry, f billy.File, err error) (billy.File, error) {
+	if err == nil {
+		e.File = r.log.nextFile()
+	}
+	e.setResult(nil, err)
+	r.log.record(e)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), id: e.File, log: r.log}, nil
+}
+
+func names(infos []os.FileInfo) []string {
+	if len(infos) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
+
+func entryNames(entries []fs.DirEntry) []string {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
+
+// file records the operations made on the wrapped file.
+type file struct {
+	*wrappedfile.File
+	id  int
+	log *log
+}
+
+func (f *file) record(e Entry, err error) {
+	e.File = f.id
+	e.setResult(nil, err)
+	f.log.record(e)
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.record(Entry{Op: "read", Size: len(p), Data: p[:n], N: int64(n)}, err)
+
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.record(Entry{Op: "readat", Offset: off, Size: len(p), Data: p[:n], N: int64(n)}, err)
+
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.record(Entry{Op: "write", Data: p, N: int64(n)}, err)
+
+	return n, err
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	f.record(Entry{Op: "writeat", Offset: off, Data: p, N: int64(n)}, err)
+
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.File.Seek(offset, whence)
+	f.record(Entry{Op: "seek", Offset: offset, Whence: whence, N: n}, err)
+
+	return n, err
+}
+
+func (f *file) Truncate(size int64) error {
+	err := f.File.Truncate(size)
+	f.record(Entry{Op: "truncate", Offset: size}, err)
+
+	return err
+}
+
+func (f *file) Sync() error {
+	err := f.File.Sync()
+	f.record(Entry{Op: "sync"}, err)
+
+	return err
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	e := Entry{Op: "fstat", File: f.id}
+	e.setResult(fi, err)
+	f.log.record(e)
+
+	return fi, err
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.record(Entry{Op: "close"}, err)
+
+	return err
+}
+
+// Chmod implements the billy.FileChange interface, if the wrapped file does.
+func (f *file) Chmod(mode fs.FileMode) error {
+	err := f.File.Chmod(mode)
+	f.record(Entry{Op: "fchmod", Perm: mode}, err)
+
+	return err
+}
+
+// Chtimes implements the billy.FileChange interface, if the wrapped file
+// does.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	err := f.File.Chtimes(atime, mtime)
+	f.record(Entry{Op: "fchtimes", Atime: atime, Mtime: mtime}, err)
+
+	return err
+}
+
+// ReadDir implements the billy.ReadDirFile interface, if the wrapped file
+// does.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := f.File.ReadDir(n)
+	f.record(Entry{Op: "freaddir", Size: n, Names: entryNames(entries)}, err)
+
+	return entries, err
+}
+
+// RawFile implements the billy.RawFile interface. It always fails with an
+// error wrapping billy.ErrNotSupported, as the operations made on the
+// *os.File could not be recorded.
+func (f *file) RawFile() (*os.File, error) {
+	return nil, &os.PathError{Op: "rawfile", Path: f.Name(), Err: billy.ErrNotSupported}
+}
@@ -0,0 +1,126 @@
+package recordfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// scenario runs a bit of everything over fs.
+func scenario(t *testing.T, fs billy.Filesystem) {
+	t.Helper()
+
+	require.NoError(t, fs.MkdirAll("dir/sub", 0o755))
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("foo"), 0o644))
+
+	f, err := fs.OpenFile("dir/foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("bar"), 3)
+	require.NoError(t, err)
+	_, err = f.Seek(1, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(2))
+	_, err = f.Stat()
+	require.NoError(t, err)
+	require.NoError(t, util.FileChmod(f, 0o640))
+	require.NoError(t, f.Close())
+
+	d, err := fs.Open("dir")
+	require.NoError(t, err)
+	_, err = util.FileReadDir(d, -1)
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+
+	require.NoError(t, fs.Rename("dir/foo", "dir/sub/bar"))
+	_, err = fs.Stat("dir/foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = fs.ReadDir("dir")
+	require.NoError(t, err)
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, util.Chmod(fs, "dir/sub/bar", 0o600))
+	require.NoError(t, util.Chtimes(fs, "dir/sub/bar", mtime, mtime))
+
+	sub, err := fs.Chroot("dir")
+	require.NoError(t, err)
+	require.NoError(t, sub.Remove("sub/bar"))
+}
+
+func TestRecordReplay(t *testing.T) {
+	var log bytes.Buffer
+	fs := New(memfs.New(), &log)
+	scenario(t, fs)
+	require.NoError(t, fs.Err())
+
+	assert.Contains(t, log.String(), `{"op":"remove","path":"dir/sub/bar"}`)
+	assert.Contains(t, log.String(), `{"op":"chmod","path":"dir/sub/bar","perm":384}`)
+	assert.Contains(t, log.String(), `"op":"fchmod","file":2,"perm":416}`)
+	assert.Contains(t, log.String(), `"op":"freaddir","file":3,"size":-1,"names":["foo","sub"]}`)
+
+	for name, replay := range map[string]billy.Filesystem{
+		"memfs": memfs.New(),
+		"osfs":  osfs.New(t.TempDir()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			divergences, err := Replay(replay, bytes.NewReader(log.Bytes()))
+			require.NoError(t, err)
+			assert.Empty(t, divergences)
+
+			_, err = replay.Stat("dir/sub")
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	var log bytes.Buffer
+	fs := New(memfs.New(), &log)
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	_, err := fs.Stat("bar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	replay := memfs.New()
+	require.NoError(t, util.WriteFile(replay, "bar", nil, 0o644))
+
+	divergences, err := Replay(replay, &log)
+	require.NoError(t, err)
+	require.Len(t, divergences, 1)
+
+	d := divergences[0]
+	assert.Equal(t, 4, d.Line)
+	assert.Equal(t, "notexist", d.Recorded.Err)
+	assert.Empty(t, d.Replayed.Err)
+	assert.True(t, strings.HasPrefix(d.String(), "line 4: recorded"))
+}
+
+func TestReplayUnknownOp(t *testing.T) {
+	_, err := Replay(memfs.New(), strings.NewReader(`{"op":"stat","path":"foo","err":"notexist"}
+{"op":"format"}
+`))
+	assert.ErrorContains(t, err, `line 2: unknown operation "format"`)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestErr(t *testing.T) {
+	fs := New(memfs.New(), failingWriter{})
+	require.NoError(t, fs.MkdirAll("foo", 0o755))
+	assert.ErrorContains(t, fs.Err(), "failed")
+}
@@ -0,0 +1,214 @@
+package recordfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// Divergence is an operation whose result on replay differs from the
+// recorded one.
+type Divergence struct {
+	// Line is the line of the log holding the operation, counting from one.
+	Line int
+	// Recorded is the operation as recorded, and Replayed as replayed.
+	Recorded, Replayed Entry
+}
+
+func (d Divergence) String() string {
+	recorded, _ := json.Marshal(d.Recorded)
+	replayed, _ := json.Marshal(d.Replayed)
+	return fmt.Sprintf("line %d: recorded %s, replayed %s", d.Line, recorded, replayed)
+}
+
+// Replay applies the operations recorded in r to fs, in order, and returns
+// the ones whose results differ from the recorded ones. The results compared
+// are the kind of the errors, the data read and written, the positions, the
+// names listed, the link targets, and the type and size of the files.
+//
+// The files left open by the log are closed once it is replayed.
+func Replay(fs billy.Filesystem, r io.Reader) ([]Divergence, error) {
+	files := make(map[int]billy.File)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	var divergences []Divergence
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 64<<20)
+	for line := 1; s.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return divergences, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		replayed, err := apply(fs, files, e)
+		if err != nil {
+			return divergences, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if !sameResult(e, replayed) {
+			divergences = append(divergences, Divergence{Line: line, Recorded: e, Replayed: replayed})
+		}
+	}
+
+	return divergences, s.Err()
+}
+
+// apply performs the operation recorded in e over fs, returning the entry
+// recording its result. It fails if the operation is unknown.
+func apply(fs billy.Filesystem, files map[int]billy.File, e Entry) (Entry, error) {
+	r := Entry{
+		Op:      e.Op,
+		Path:    e.Path,
+		NewPath: e.NewPath,
+		File:    e.File,
+		Flag:    e.Flag,
+		Perm:    e.Perm,
+		UID:     e.UID,
+		GID:     e.GID,
+		Atime:   e.Atime,
+		Mtime:   e.Mtime,
+		Offset:  e.Offset,
+		Whence:  e.Whence,
+		Size:    e.Size,
+	}
+
+	path := filepath.FromSlash(e.Path)
+	switch e.Op {
+	case "open", "tempfile", "createtemp":
+		name, flag := path, e.Flag
+		if e.Op != "open" {
+			name, flag = filepath.FromSlash(e.Target), os.O_RDWR|os.O_CREATE|os.O_EXCL
+			r.Target = e.Target
+		}
+
+		f, err := fs.OpenFile(name, flag, e.Perm)
+		if err == nil && e.File != 0 {
+			files[e.File] = f
+		} else if err == nil {
+			_ = f.Close()
+			r.File = 0
+		}
+		r.setResult(nil, err)
+	case "stat":
+		fi, err := fs.Stat(path)
+		r.setResult(fi, err)
+	case "lstat":
+		fi, err := fs.Lstat(path)
+		r.setResult(fi, err)
+	case "rename":
+		r.setResult(nil, fs.Rename(path, filepath.FromSlash(e.NewPath)))
+	case "remove":
+		r.setResult(nil, fs.Remove(path))
+	case "tempdir", "mkdirtemp":
+		r.Target = e.Target
+		r.setResult(nil, fs.MkdirAll(filepath.FromSlash(e.Target), 0o700))
+	case "readdir":
+		infos, err := fs.ReadDir(path)
+		r.Names = names(infos)
+		r.setResult(nil, err)
+	case "mkdirall":
+		r.setResult(nil, fs.MkdirAll(path, e.Perm))
+	case "symlink":
+		r.setResult(nil, fs.Symlink(e.NewPath, path))
+	case "readlink":
+		target, err := fs.Readlink(path)
+		r.Target = target
+		r.setResult(nil, err)
+	case "chmod":
+		r.setResult(nil, util.Chmod(fs, path, e.Perm))
+	case "lchown":
+		r.setResult(nil, util.Lchown(fs, path, e.UID, e.GID))
+	case "chown":
+		r.setResult(nil, util.Chown(fs, path, e.UID, e.GID))
+	case "chtimes":
+		r.setResult(nil, util.Chtimes(fs, path, e.Atime, e.Mtime))
+	case "read", "readat", "write", "writeat", "seek", "truncate", "sync", "fstat",
+		"fchmod", "fchtimes", "freaddir", "close":
+		f, ok := files[e.File]
+		if !ok {
+			r.setResult(nil, os.ErrClosed)
+			break
+		}
+
+		applyFile(f, e, &r)
+		if e.Op == "close" {
+			delete(files, e.File)
+		}
+	default:
+		return r, fmt.Errorf("unknown operation %q", e.Op)
+	}
+
+	return r, nil
+}
+
+// applyFile performs the operation on f recorded in e, recording its result
+// in r.
+func applyFile(f billy.File, e Entry, r *Entry) {
+	var n int
+	var err error
+	switch e.Op {
+	case "read":
+		p := make([]byte, e.Size)
+		n, err = f.Read(p)
+		r.Data, r.N = p[:n], int64(n)
+	case "readat":
+		p := make([]byte, e.Size)
+		n, err = f.ReadAt(p, e.Offset)
+		r.Data, r.N = p[:n], int64(n)
+	case "write":
+		n, err = f.Write(e.Data)
+		r.Data, r.N = e.Data, int64(n)
+	case "writeat":
+		n, err = f.WriteAt(e.Data, e.Offset)
+		r.Data, r.N = e.Data, int64(n)
+	case "seek":
+		r.N, err = f.Seek(e.Offset, e.Whence)
+	case "truncate":
+		err = f.Truncate(e.Offset)
+	case "sync":
+		err = f.Sync()
+	case "fstat":
+		var fi os.FileInfo
+		fi, err = f.Stat()
+		r.setResult(fi, err)
+		return
+	case "fchmod":
+		err = util.FileChmod(f, e.Perm)
+	case "fchtimes":
+		err = util.FileChtimes(f, e.Atime, e.Mtime)
+	case "freaddir":
+		var entries []fs.DirEntry
+		entries, err = util.FileReadDir(f, e.Size)
+		r.Names = entryNames(entries)
+	case "close":
+		err = f.Close()
+	}
+
+	r.setResult(nil, err)
+}
+
+// sameResult reports whether the recorded and the replayed entries have the
+// same result. The messages of the errors and the permissions of the files
+// are not compared, as they are specific to each filesystem.
+func sameResult(recorded, replayed Entry) bool {
+	return recorded.Err == replayed.Err &&
+		recorded.File == replayed.File &&
+		recorded.N == replayed.N &&
+		bytes.Equal(recorded.Data, replayed.Data) &&
+		recorded.Mode.Type() == replayed.Mode.Type() &&
+		slices.Equal(recorded.Names, replayed.Names) &&
+		recorded.Target == replayed.Target
+}
@@ -0,0 +1,467 @@
+// Package retryfs provides a billy filesystem wrapper which retries the
+// operations failing with transient errors, such as the timeouts of network
+// backed filesystems.
+package retryfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+const (
+	// DefaultMaxAttempts is the number of attempts made at an operation,
+	// unless set with WithMaxAttempts.
+	DefaultMaxAttempts = 3
+
+	defaultBackoffBase = 10 * time.Millisecond
+	defaultBackoffMax  = time.Second
+)
+
+// Option configures the retry policy of a Retry filesystem.
+type Option func(*policy)
+
+// WithMaxAttempts sets the number of attempts made at an operation, the
+// first one included, before returning its error. Values lower than one
+// are ignored.
+func WithMaxAttempts(n int) Option {
+	return func(p *policy) {
+		if n > 0 {
+			p.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff sets the delay before each retry, backoff being called with
+// the number of attempts made so far, starting from one. By default the
+// delay is ExponentialBackoff(10ms, 1s).
+func WithBackoff(backoff func(attempt int) time.Duration) Option {
+	return func(p *policy) {
+		p.backoff = backoff
+	}
+}
+
+// WithRetryable sets the classifier deciding whether the error of the
+// operation named op is retried. op is the name reported in the
+// *os.PathError of the operations, such as "open", "rename" or "write".
+// By default Transient is used.
+func WithRetryable(retryable func(op string, err error) bool) Option {
+	return func(p *policy) {
+		p.retryable = retryable
+	}
+}
+
+// ExponentialBackoff returns a backoff waiting base before the first retry,
+// and doubling the delay on each of the next ones, up to max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+
+		return min(d, max)
+	}
+}
+
+// Transient reports whether err is a temporary failure worth retrying, being
+// a timeout or an error reporting itself as temporary, such as EINTR, EAGAIN
+// or ECONNRESET. Context cancellations and deadlines are never retried.
+func Transient(_ string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// policy is shared by a Retry and all the filesystems returned by its Chroot
+// method.
+type policy struct {
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	retryable   func(op string, err error) bool
+}
+
+// retry reports whether the operation named op, which failed with err after
+// the given number of attempts, should be retried, waiting for the backoff
+// delay first if so.
+func (p *policy) retry(op string, err error, attempt int) bool {
+	if err == nil || attempt >= p.maxAttempts || !p.retryable(op, err) {
+		return false
+	}
+
+	time.Sleep(p.backoff(attempt))
+	return true
+}
+
+// do calls fn until it succeeds, fails with an error not to be retried, or
+// the attempts run out.
+func (p *policy) do(op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !p.retry(op, err, attempt) {
+			return err
+		}
+	}
+}
+
+// Retry is a helper that retries the operations made over any
+// billy.Filesystem, including the ones made on the files it opens, when they
+// fail with an error classified as retryable.
+//
+// Operations are retried as a whole, so the ones not being idempotent, such
+// as Rename, Remove or creating a file with os.O_EXCL, may fail on a retry
+// when the failed attempt took effect anyway. The classifier receives the
+// name of the operation to leave them out if needed. Reads and writes are
+// resumed from where the failed attempt stopped.
+type Retry struct {
+	billy.Filesystem
+	p *policy
+}
+
+// New creates a new filesystem wrapping up fs, which retries its operations
+// following the policy set by the given options.
+func New(fs billy.Filesystem, opts ...Option) billy.Filesystem {
+	p := &policy{
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     ExponentialBackoff(defaultBackoffBase, defaultBackoffMax),
+		retryable:   Transient,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return &Retry{Filesystem: fs, p: p}
+}
+
+// Layers implements billy.Composite.
+func (h *Retry) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+func (h *Retry) String() string {
+	return "retryfs"
+}
+
+func (h *Retry) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *Retry) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (h *Retry) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	var f billy.File
+	err := h.p.do("open", func() (err error) {
+		f, err = h.Filesystem.OpenFile(filename, flag, perm)
+		return err
+	})
+
+	return h.wrapFile(f, err)
+}
+
+func (h *Retry) TempFile(dir, prefix string) (billy.File, error) {
+	var f billy.File
+	err := h.p.do("tempfile", func() (err error) {
+		f, err = h.Filesystem.TempFile(dir, prefix)
+		return err
+	})
+
+	return h.wrapFile(f, err)
+}
+
+func (h *Retry) CreateTemp(dir, pattern string) (billy.File, error) {
+	var f billy.File
+	err := h.p.do("createtemp", func() (err error) {
+		f, err = h.Filesystem.CreateTemp(dir, pattern)
+		return err
+	})
+
+	return h.wrapFile(f, err)
+}
+
+func (h *Retry) Stat(filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := h.p.do("stat", func() (err error) {
+		fi, err = h.Filesystem.Stat(filename)
+		return err
+	})
+
+	return fi, err
+}
+
+func (h *Retry) Lstat(filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := h.p.do("lstat", func() (err error) {
+		fi, err = h.Filesystem.Lstat(filename)
+		return err
+	})
+
+	return fi, err
+}
+
+func (h *Retry) Rename(from, to string) error {
+	return h.p.do("rename", func() error {
+		return h.Filesystem.Rename(from, to)
+	})
+}
+
+func (h *Retry) Remove(filename string) error {
+	return h.p.do("remove", func() error {
+		return h.Filesystem.Remove(filename)
+	})
+}
+
+func (h *Retry) ReadDir(path string) ([]os.FileInfo, error) {
+	var entries []os.FileInfo
+	err := h.p.do("readdir", func() (err error) {
+		entries, err = h.Filesystem.ReadDir(path)
+		return err
+	})
+
+	return entries, err
+}
+
+func (h *Retry) MkdirAll(filename string, perm fs.FileMode) error {
+	return h.p.do("mkdir", func() error {
+		return h.Filesystem.MkdirAll(filename, perm)
+	})
+}
+
+func (h *Retry) Symlink(target, link string) error {
+	return h.p.do("symlink", func() error {
+		return h.Filesystem.Symlink(target, link)
+	})
+}
+
+func (h *Retry) Readlink(link string) (string, error) {
+	var target string
+	err := h.p.do("readlink", func() (err error) {
+		target, err = h.Filesystem.Readlink(link)
+		return err
+	})
+
+	return target, err
+}
+
+// Chroot returns a new Retry over the chroot of the wrapped filesystem,
+// sharing the retry policy of h.
+func (h *Retry) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Retry{Filesystem: fs, p: h.p}, nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Retry) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface, reporting the
+// features of the wrapped filesystem.
+func (h *Retry) Supports(f billy.Feature) bool {
+	return billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Retry) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (h *Retry) ReadDirNames(path string, n int) ([]string, error) {
+	var names []string
+	err := h.p.do("readdir", func() (err error) {
+		names, err = util.ReadDirNames(h.Filesystem, path, n)
+		return err
+	})
+
+	return names, err
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (h *Retry) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	err := h.p.do("readdir", func() (err error) {
+		entries, err = util.ReadDirEntries(h.Filesystem, path)
+		return err
+	})
+
+	return entries, err
+}
+
+// OpenDir implements the billy.DirOpener interface. Only opening the dir is
+// retried, not reading its entries.
+func (h *Retry) OpenDir(path string) (billy.DirIter, error) {
+	var it billy.DirIter
+	err := h.p.do("open", func() (err error) {
+		it, err = util.OpenDir(h.Filesystem, path)
+		return err
+	})
+
+	return it, err
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (h *Retry) SyncDir(path string) error {
+	return h.p.do("syncdir", func() error {
+		return util.SyncDir(h.Filesystem, path)
+	})
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (h *Retry) RenameNoReplace(from, to string) error {
+	return h.p.do("rename", func() error {
+		return util.RenameNoReplace(h.Filesystem, from, to)
+	})
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (h *Retry) RenameExchange(from, to string) error {
+	return h.p.do("rename", func() error {
+		return util.RenameExchange(h.Filesystem, from, to)
+	})
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Retry) Chmod(name string, mode fs.FileMode) error {
+	return h.p.do("chmod", func() error {
+		return util.Chmod(h.Filesystem, name, mode)
+	})
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Retry) Lchown(name string, uid, gid int) error {
+	return h.p.do("lchown", func() error {
+		return util.Lchown(h.Filesystem, name, uid, gid)
+	})
+}
+
+// Chown implements the billy.Change interface.
+func (h *Retry) Chown(name string, uid, gid int) error {
+	return h.p.do("chown", func() error {
+		return util.Chown(h.Filesystem, name, uid, gid)
+	})
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Retry) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return h.p.do("chtimes", func() error {
+		return util.Chtimes(h.Filesystem, name, atime, mtime)
+	})
+}
+
+func (h *Retry) wrapFile(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: wrappedfile.Wrap(f), p: h.p}, nil
+}
+
+// file retries the operations of the wrapped file. Close, Seek, the locks and
+// the operations made through its RawFile are not retried, as a failed attempt leaves the file in an unknown state.
+type file struct {
+	*wrappedfile.File
+	p *policy
+}
+
+// Read retries the reads failing before reading anything. Once some data is
+// read it is returned, leaving the error to the next read.
+func (f *file) Read(p []byte) (int, error) {
+	for attempt := 1; ; attempt++ {
+		n, err := f.File.Read(p)
+		if n > 0 || err == io.EOF || !f.p.retry("read", err, attempt) {
+			return n, err
+		}
+	}
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	var read int
+	for attempt := 1; ; attempt++ {
+		n, err := f.File.ReadAt(p[read:], off+int64(read))
+		read += n
+		if err == io.EOF || !f.p.retry("read", err, attempt) {
+			return read, err
+		}
+	}
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	var written int
+	for attempt := 1; ; attempt++ {
+		n, err := f.File.Write(p[written:])
+		written += n
+		if !f.p.retry("write", err, attempt) {
+			return written, err
+		}
+	}
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	var written int
+	for attempt := 1; ; attempt++ {
+		n, err := f.File.WriteAt(p[written:], off+int64(written))
+		written += n
+		if !f.p.retry("write", err, attempt) {
+			return written, err
+		}
+	}
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.p.do("truncate", func() error {
+		return f.File.Truncate(size)
+	})
+}
+
+func (f *file) Sync() error {
+	return f.p.do("sync", func() error {
+		return f.File.Sync()
+	})
+}
+
+// Chmod implements the billy.FileChange interface, if the wrapped file does.
+func (f *file) Chmod(mode fs.FileMode) error {
+	return f.p.do("chmod", func() error {
+		return f.File.Chmod(mode)
+	})
+}
+
+// Chtimes implements the billy.FileChange interface, if the wrapped file
+// does.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return f.p.do("chtimes", func() error {
+		return f.File.Chtimes(atime, mtime)
+	})
+}
+
+// ReadDir implements the billy.ReadDirFile interface, if the wrapped file
+// does. Like Read, it retries the reads failing before returning any entry.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	for attempt := 1; ; attempt++ {
+		entries, err := f.File.ReadDir(n)
+		if len(entries) > 0 || err == io.EOF || !f.p.retry("readdir", err, attempt) {
+			return entries, err
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package retryfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/faultfs"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/internal/wrappedfile"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var (
+	errTimeout   = timeoutError{}
+	errPermanent = errors.New("permanent")
+)
+
+func noBackoff(int) time.Duration { return 0 }
+
+func TestRetry(t *testing.T) {
+	faults := faultfs.New(memfs.New(),
+		faultfs.FailNth("stat", 1, errTimeout),
+		faultfs.FailNth("stat", 2, errTimeout),
+	)
+	fs := New(faults, WithBackoff(noBackoff))
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", fi.Name())
+}
+
+func TestMaxAttempts(t *testing.T) {
+	faults := faultfs.New(memfs.New(), faultfs.Fault{Op: "open", Err: errTimeout})
+	fs := New(faults, WithMaxAttempts(2), WithBackoff(noBackoff))
+
+	_, err := fs.Open("foo")
+	assert.ErrorIs(t, err, errTimeout)
+
+	faults.Reset()
+	faults.Inject(faultfs.FailNth("open", 1, errTimeout))
+	faults.Inject(faultfs.FailNth("open", 2, errTimeout))
+
+	_, err = fs.Open("foo")
+	assert.ErrorIs(t, err, errTimeout)
+
+	_, err = fs.Open("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNotRetryable(t *testing.T) {
+	faults := faultfs.New(memfs.New(), faultfs.FailNth("mkdir", 1, errPermanent))
+	fs := New(faults, WithBackoff(noBackoff))
+
+	err := fs.MkdirAll("foo", 0o755)
+	assert.ErrorIs(t, err, errPermanent)
+
+	_, err = fs.Stat("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWithRetryable(t *testing.T) {
+	faults := faultfs.New(memfs.New(), faultfs.FailNth("rename", 1, errTimeout))
+
+	var ops []string
+	fs := New(faults, WithBackoff(noBackoff), WithRetryable(func(op string, err error) bool {
+		ops = append(ops, op)
+		return op != "rename" && Transient(op, err)
+	}))
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	err := fs.Rename("foo", "bar")
+	assert.ErrorIs(t, err, errTimeout)
+	assert.Equal(t, []string{"rename"}, ops)
+
+	require.NoError(t, fs.Rename("foo", "bar"))
+}
+
+func TestWrite(t *testing.T) {
+	faults := faultfs.New(memfs.New(), faultfs.Fault{Op: "write", Nth: 1, ShortWrite: true})
+	fs := New(faults, WithBackoff(noBackoff), WithRetryable(func(_ string, err error) bool {
+		return errors.Is(err, io.ErrShortWrite)
+	}))
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+
+	n, err := f.Write([]byte("abcd"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	faults.Inject(faultfs.Fault{Op: "write", Nth: 1, ShortWrite: true})
+	n, err = f.WriteAt([]byte("efgh"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	require.NoError(t, f.Close())
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "abefgh", string(content))
+}
+
+func TestRead(t *testing.T) {
+	fs := New(memfs.New(), WithBackoff(noBackoff))
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	f, err := fs.Open("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ff := &flakyFile{File: f.(*file).File, fail: 2}
+	flaky := &file{File: wrappedfile.Wrap(ff), p: f.(*file).p}
+
+	b := make([]byte, 3)
+	n, err := flaky.ReadAt(b, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b[:n]))
+
+	ff.fail = 2
+	n, err = flaky.Read(b)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b[:n]))
+
+	_, err = flaky.Read(b)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// flakyFile fails the given number of reads with errTimeout, its ReadAt
+// reading a single byte before failing.
+type flakyFile struct {
+	billy.File
+	fail int
+}
+
+func (f *flakyFile) Read(p []byte) (int, error) {
+	if f.fail > 0 {
+		f.fail--
+		return 0, errTimeout
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *flakyFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.fail > 0 {
+		f.fail--
+		n, _ := f.File.ReadAt(p[:1], off)
+		return n, errTimeout
+	}
+
+	return f.File.ReadAt(p, off)
+}
+
+func TestTransient(t *testing.T) {
+	assert.True(t, Transient("open", &os.PathError{Op: "open", Path: "foo", Err: errTimeout}))
+	assert.False(t, Transient("open", &os.PathError{Op: "open", Path: "foo", Err: os.ErrNotExist}))
+	assert.False(t, Transient("open", errPermanent))
+	assert.False(t, Transient("open", context.DeadlineExceeded))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, backoff(1))
+	assert.Equal(t, 20*time.Millisecond, backoff(2))
+	assert.Equal(t, 40*time.Millisecond, backoff(3))
+	assert.Equal(t, 50*time.Millisecond, backoff(4))
+	assert.Equal(t, 50*time.Millisecond, backoff(100))
+}
+
+func TestChrootSharesPolicy(t *testing.T) {
+	faults := faultfs.New(memfs.New(), faultfs.FailNth("mkdir", 1, errTimeout))
+	fs := New(faults, WithBackoff(noBackoff))
+
+	sub, err := fs.Chroot("sub")
+	require.NoError(t, err)
+	require.NoError(t, sub.MkdirAll("foo", 0o755))
+
+	_, err = fs.Stat("sub/foo")
+	assert.NoError(t, err)
+}
+
+func TestCapabilities(t *testing.T) {
+	fs := New(memfs.New())
+	assert.Equal(t, billy.Capabilities(memfs.New())&^billy.RemoveAllCapability, billy.Capabilities(fs))
+	assert.True(t, billy.Supports(fs, billy.ChangeFeature))
+	assert.False(t, billy.Supports(fs, billy.HasherFeature))
+}
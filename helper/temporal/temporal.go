@@ -1,30 +1,206 @@
 package temporal
 
 import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/util"
 )
 
 // Temporal is a helper that implements billy.TempFile over any filesystem.
+// The temp files and dirs it creates are tracked until they are removed or
+// renamed, so they can be removed at once with Cleanup.
 type Temporal struct {
 	billy.Filesystem
 	defaultDir string
+
+	mu    sync.Mutex
+	temps map[string]struct{}
 }
 
 // New creates a new filesystem wrapping up 'fs' the intercepts the calls to
-// the TempFile method. The param defaultDir is used as default directory were
+// the TempFile and CreateTemp methods. The param defaultDir is used as default directory were
 // the tempfiles are created.
 func New(fs billy.Filesystem, defaultDir string) billy.Filesystem {
 	return &Temporal{
 		Filesystem: fs,
 		defaultDir: defaultDir,
+		temps:      make(map[string]struct{}),
 	}
 }
 
+// Layers implements billy.Composite.
+func (h *Temporal) Layers() []billy.Basic {
+	return []billy.Basic{h.Filesystem}
+}
+
+// String describes the filesystem along with the dir holding the temporal
+// files.
+func (h *Temporal) String() string {
+	return "temporal(" + h.defaultDir + ")"
+}
+
 func (h *Temporal) TempFile(dir, prefix string) (billy.File, error) {
+	return h.CreateTemp(dir, prefix+"*")
+}
+
+func (h *Temporal) TempDir(dir, prefix string) (string, error) {
+	return h.MkdirTemp(dir, prefix+"*")
+}
+
+func (h *Temporal) CreateTemp(dir, pattern string) (billy.File, error) {
 	if dir == "" {
 		dir = h.defaultDir
 	}
 
-	return util.TempFile(h.Filesystem, dir, prefix)
+	f, err := util.CreateTemp(h.Filesystem, dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	h.track(f.Name())
+	return f, nil
+}
+
+func (h *Temporal) MkdirTemp(dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = h.defaultDir
+	}
+
+	name, err := util.MkdirTemp(h.Filesystem, dir, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	h.track(name)
+	return name, nil
+}
+
+// Rename renames from to to. A temp file or dir renamed this way is promoted
+// to a regular one: it keeps its mode, and it is not removed by Cleanup.
+func (h *Temporal) Rename(from, to string) error {
+	if err := h.Filesystem.Rename(from, to); err != nil {
+		return err
+	}
+
+	h.untrack(from)
+	return nil
+}
+
+func (h *Temporal) Remove(path string) error {
+	if err := h.Filesystem.Remove(path); err != nil {
+		return err
+	}
+
+	h.untrack(path)
+	return nil
+}
+
+// RemoveAll removes path and any children it contains, as util.RemoveAll
+// does, so whole temp trees can be removed at once.
+func (h *Temporal) RemoveAll(path string) error {
+	if err := util.RemoveAll(h.Filesystem, path); err != nil {
+		return err
+	}
+
+	h.untrack(path)
+	return nil
+}
+
+// Cleanup removes all the temp files and dirs created through h which were
+// not removed or renamed since. It removes everything it can but returns the
+// first error it encounters.
+func (h *Temporal) Cleanup() error {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.temps))
+	for name := range h.temps {
+		names = append(names, name)
+	}
+	h.mu.Unlock()
+
+	sort.Strings(names)
+
+	var firstErr error
+	for _, name := range names {
+		if err := h.RemoveAll(name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Chroot returns a new Temporal filesystem over the result of the Chroot
+// method of the wrapped filesystem, using the same default directory
+// relative to the new root.
+func (h *Temporal) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := h.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(fs, h.defaultDir), nil
+}
+
+// Capabilities implements the Capable interface.
+func (h *Temporal) Capabilities() billy.Capability {
+	return billy.WrapperCapabilities(h, billy.Capabilities(h.Filesystem))
+}
+
+// Supports implements the billy.FeatureReporter interface. TempFile is
+// always supported, and the other features are the ones of the wrapped
+// filesystem.
+func (h *Temporal) Supports(f billy.Feature) bool {
+	return f == billy.TempFileFeature || billy.Supports(h.Filesystem, f)
+}
+
+// PathProperties implements the Introspectable interface.
+func (h *Temporal) PathProperties() billy.PathProperties {
+	return billy.Introspect(h.Filesystem)
+}
+
+// Chmod implements the billy.Change interface.
+func (h *Temporal) Chmod(name string, mode fs.FileMode) error {
+	return util.Chmod(h.Filesystem, name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (h *Temporal) Lchown(name string, uid, gid int) error {
+	return util.Lchown(h.Filesystem, name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (h *Temporal) Chown(name string, uid, gid int) error {
+	return util.Chown(h.Filesystem, name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (h *Temporal) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return util.Chtimes(h.Filesystem, name, atime, mtime)
+}
+
+func (h *Temporal) track(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.temps[filepath.Clean(name)] = struct{}{}
+}
+
+// untrack stops tracking path and any temp file or dir under it.
+func (h *Temporal) untrack(path string) {
+	path = filepath.Clean(path)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name := range h.temps {
+		if name == path || strings.HasPrefix(name, path+string(filepath.Separator)) {
+			delete(h.temps, name)
+		}
+	}
 }
@@ -1,12 +1,15 @@
 package temporal
 
 import (
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
 	"github.com/go-git/go-billy/v6/memfs"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 func TestTempFileDefaultPath(t *testing.T) {
@@ -18,3 +21,87 @@ func TestTempFileDefaultPath(t *testing.T) {
 
 	assert.True(t, strings.HasPrefix(f.Name(), fs.Join("foo", "bar")))
 }
+
+func TestTempDir(t *testing.T) {
+	fs := New(memfs.New(), "foo")
+
+	name, err := fs.TempDir("", "bar")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, fs.Join("foo", "bar")))
+}
+
+func TestCapabilities(t *testing.T) {
+	fs := New(memfs.New(), "foo")
+	assert.Equal(t, billy.Capabilities(memfs.New()), billy.Capabilities(fs))
+}
+
+func TestChroot(t *testing.T) {
+	fs := New(memfs.New(), "foo")
+
+	sub, err := fs.Chroot("sub")
+	require.NoError(t, err)
+	assert.IsType(t, &Temporal{}, sub)
+
+	f, err := sub.TempFile("", "bar")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = fs.Stat(fs.Join("sub", f.Name()))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(f.Name(), fs.Join("foo", "bar")))
+}
+
+func TestCleanup(t *testing.T) {
+	fs := New(memfs.New(), "foo")
+
+	f, err := fs.TempFile("", "file")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	dir, err := fs.TempDir("", "dir")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(fs, fs.Join(dir, "nested"), nil, 0o644))
+
+	require.NoError(t, fs.(*Temporal).Cleanup())
+
+	_, err = fs.Stat(f.Name())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = fs.Stat(dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	names, err := util.ReadDirNames(fs, "foo", 0)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestRenamePromotesTempFile(t *testing.T) {
+	fs := New(memfs.New(), "foo")
+
+	f, err := fs.TempFile("", "file")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	before, err := fs.Stat(f.Name())
+	require.NoError(t, err)
+
+	require.NoError(t, fs.Rename(f.Name(), "promoted"))
+	require.NoError(t, fs.(*Temporal).Cleanup())
+
+	after, err := fs.Stat("promoted")
+	require.NoError(t, err)
+	assert.Equal(t, before.Mode(), after.Mode())
+}
+
+func TestRemoveAll(t *testing.T) {
+	fs := New(memfs.New(), "foo")
+
+	dir, err := fs.TempDir("", "dir")
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(fs, fs.Join(dir, "a", "b"), nil, 0o644))
+
+	require.NoError(t, util.RemoveAll(fs, dir))
+
+	_, err = fs.Stat(dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Empty(t, fs.(*Temporal).temps)
+}
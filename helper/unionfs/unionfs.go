@@ -0,0 +1,249 @@
+// Package unionfs provides a billy filesystem which merges several
+// filesystems into a single read-only view.
+package unionfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+)
+
+var separator = string(filepath.Separator)
+
+// Union is a helper that presents several filesystems, its layers, as a
+// single read-only tree, such as to overlay a template tree on embedded
+// defaults. Unlike mount, every layer is rooted at the root of the union.
+//
+// A path is looked up in each layer in order, and the first layer where it
+// exists shadows the others: files are opened and stat'ed from it. Dirs are
+// merged instead, listing the entries of every layer holding the dir, the
+// first one found for each name shadowing the others, down to the first
+// layer where the path is not a dir.
+//
+// All the operations modifying the tree fail with billy.ErrReadOnly.
+type Union struct {
+	layers []billy.Filesystem
+}
+
+// New creates a new filesystem merging layers, the first ones shadowing the
+// next ones.
+func New(layers ...billy.Filesystem) billy.Filesystem {
+	return &Union{layers: layers}
+}
+
+// Layers implements billy.Composite, returning the layers in the order they
+// shadow each other.
+func (h *Union) Layers() []billy.Basic {
+	layers := make([]billy.Basic, len(h.layers))
+	for i, l := range h.layers {
+		layers[i] = l
+	}
+
+	return layers
+}
+
+func (h *Union) String() string {
+	return "unionfs"
+}
+
+func (h *Union) Create(filename string) (billy.File, error) {
+	return nil, readOnly("open", filename)
+}
+
+func (h *Union) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens filename from the first layer holding it. Only read-only
+// flags are accepted.
+func (h *Union) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	if openflag.Writable(flag) || openflag.Create(flag) || openflag.Truncate(flag) {
+		return nil, readOnly("open", filename)
+	}
+
+	for _, l := range h.layers {
+		f, err := l.OpenFile(filename, flag, perm)
+		if !errors.Is(err, os.ErrNotExist) {
+			return f, err
+		}
+	}
+
+	return nil, notExist("open", filename)
+}
+
+func (h *Union) Stat(filename string) (os.FileInfo, error) {
+	for _, l := range h.layers {
+		fi, err := l.Stat(filename)
+		if !errors.Is(err, os.ErrNotExist) {
+			return fi, err
+		}
+	}
+
+	return nil, notExist("stat", filename)
+}
+
+func (h *Union) Lstat(filename string) (os.FileInfo, error) {
+	for _, l := range h.layers {
+		fi, err := l.Lstat(filename)
+		if !errors.Is(err, os.ErrNotExist) {
+			return fi, err
+		}
+	}
+
+	return nil, notExist("lstat", filename)
+}
+
+func (h *Union) Readlink(link string) (string, error) {
+	for _, l := range h.layers {
+		target, err := l.Readlink(link)
+		if !errors.Is(err, os.ErrNotExist) {
+			return target, err
+		}
+	}
+
+	return "", notExist("readlink", link)
+}
+
+// ReadDir returns the entries of path merged from the layers holding it, in
+// order by name.
+func (h *Union) ReadDir(path string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	seen := make(map[string]bool)
+	found := false
+
+	for _, l := range h.layers {
+		fi, err := l.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// A file shadows the dirs of the next layers.
+		if !fi.IsDir() {
+			if !found {
+				return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+
+		entries, err := l.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		found = true
+
+		for _, e := range entries {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				infos = append(infos, e)
+			}
+		}
+	}
+
+	if !found {
+		return nil, notExist("readdir", path)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
+
+func (h *Union) Rename(from, to string) error {
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: billy.ErrReadOnly}
+}
+
+func (h *Union) Remove(filename string) error {
+	return readOnly("remove", filename)
+}
+
+func (h *Union) MkdirAll(filename string, _ fs.FileMode) error {
+	return readOnly("mkdir", filename)
+}
+
+func (h *Union) Symlink(target, link string) error {
+	return &os.LinkError{Op: "symlink", Old: target, New: link, Err: billy.ErrReadOnly}
+}
+
+func (h *Union) Chmod(name string, _ fs.FileMode) error {
+	return readOnly("chmod", name)
+}
+
+func (h *Union) Lchown(name string, _, _ int) error {
+	return readOnly("lchown", name)
+}
+
+func (h *Union) Chown(name string, _, _ int) error {
+	return readOnly("chown", name)
+}
+
+func (h *Union) Chtimes(name string, _, _ time.Time) error {
+	return readOnly("chtimes", name)
+}
+
+func (h *Union) TempFile(dir, _ string) (billy.File, error) {
+	return nil, readOnly("tempfile", dir)
+}
+
+func (h *Union) TempDir(dir, _ string) (string, error) {
+	return "", readOnly("tempdir", dir)
+}
+
+func (h *Union) CreateTemp(dir, _ string) (billy.File, error) {
+	return nil, readOnly("createtemp", dir)
+}
+
+func (h *Union) MkdirTemp(dir, _ string) (string, error) {
+	return "", readOnly("mkdirtemp", dir)
+}
+
+func (h *Union) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (h *Union) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(h, path), nil
+}
+
+// Root returns the root of the union.
+func (h *Union) Root() string {
+	return separator
+}
+
+// Capabilities implements the Capable interface. They are the read
+// capabilities shared by all the layers.
+func (h *Union) Capabilities() billy.Capability {
+	c := billy.AllCapabilities
+	for _, l := range h.layers {
+		c &= billy.Capabilities(l)
+	}
+
+	return c &^ (billy.WriteCapability | billy.ReadAndWriteCapability | billy.TruncateCapability |
+		billy.ChangeCapability | billy.RemoveAllCapability)
+}
+
+// Supports implements the billy.FeatureReporter interface. TempFile and
+// Change are never supported, as they only modify the tree.
+func (h *Union) Supports(f billy.Feature) bool {
+	return f != billy.TempFileFeature && f != billy.ChangeFeature
+}
+
+func readOnly(op, path string) error {
+	return &os.PathError{Op: op, Path: path, Err: billy.ErrReadOnly}
+}
+
+func notExist(op, path string) error {
+	return &os.PathError{Op: op, Path: path, Err: os.ErrNotExist}
+}
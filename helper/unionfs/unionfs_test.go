@@ -0,0 +1,109 @@
+package unionfs
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func setup(t *testing.T) billy.Filesystem {
+	t.Helper()
+
+	top, bottom := memfs.New(), memfs.New()
+	for name, content := range map[string]string{
+		"config":        "top",
+		"hooks/pre":     "top",
+		"shadowed/file": "top",
+	} {
+		require.NoError(t, util.WriteFile(top, name, []byte(content), 0o644))
+	}
+	for name, content := range map[string]string{
+		"config":      "bottom",
+		"hooks/post":  "bottom",
+		"defaults":    "bottom",
+		"shadowed":    "bottom",
+		"hooks/pre/x": "bottom",
+	} {
+		require.NoError(t, util.WriteFile(bottom, name, []byte(content), 0o644))
+	}
+
+	return New(top, bottom)
+}
+
+func TestOpenFirstHit(t *testing.T) {
+	fs := setup(t)
+
+	for name, want := range map[string]string{
+		"config":     "top",
+		"defaults":   "bottom",
+		"hooks/post": "bottom",
+	} {
+		content, err := util.ReadFile(fs, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, string(content), name)
+	}
+
+	_, err := fs.Open("missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	fi, err := fs.Stat("shadowed")
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+}
+
+func TestReadDirMerged(t *testing.T) {
+	fs := setup(t)
+
+	names, err := util.ReadDirNames(fs, "/", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config", "defaults", "hooks", "shadowed"}, names)
+
+	names, err = util.ReadDirNames(fs, "hooks", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"post", "pre"}, names)
+
+	infos, err := fs.ReadDir("hooks")
+	require.NoError(t, err)
+	assert.False(t, infos[1].IsDir(), "the file of the top layer shadows the dir")
+
+	_, err = fs.ReadDir("config")
+	require.ErrorIs(t, err, syscall.ENOTDIR)
+
+	_, err = fs.ReadDir("missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadOnly(t *testing.T) {
+	fs := setup(t)
+
+	_, err := fs.Create("foo")
+	require.ErrorIs(t, err, billy.ErrReadOnly)
+	_, err = fs.OpenFile("config", os.O_RDWR, 0)
+	require.ErrorIs(t, err, billy.ErrReadOnly)
+	require.ErrorIs(t, fs.Remove("config"), billy.ErrReadOnly)
+	require.ErrorIs(t, fs.Rename("config", "foo"), billy.ErrReadOnly)
+	require.ErrorIs(t, fs.MkdirAll("foo", 0o755), billy.ErrReadOnly)
+	require.ErrorIs(t, fs.Symlink("config", "foo"), billy.ErrReadOnly)
+	require.ErrorIs(t, util.Chmod(fs, "config", 0o600), billy.ErrReadOnly)
+	require.ErrorIs(t, util.Chtimes(fs, "config", time.Now(), time.Now()), billy.ErrReadOnly)
+
+	assert.Zero(t, billy.Capabilities(fs)&billy.WriteCapability)
+	assert.Zero(t, billy.Capabilities(fs)&(billy.ChangeCapability|billy.RemoveAllCapability))
+	assert.NotZero(t, billy.Capabilities(fs)&billy.ReadCapability)
+}
+
+func TestChroot(t *testing.T) {
+	fs, err := setup(t).Chroot("hooks")
+	require.NoError(t, err)
+
+	content, err := util.ReadFile(fs, "post")
+	require.NoError(t, err)
+	assert.Equal(t, "bottom", string(content))
+}
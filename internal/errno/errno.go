@@ -0,0 +1,19 @@
+//go:build !plan9
+// +build !plan9
+
+// Package errno holds the errno values used by the filesystems of this module
+// which the syscall package does not define on every OS.
+package errno
+
+import "syscall"
+
+var (
+	// EBADF is returned when operating on a file not opened for it.
+	EBADF error = syscall.EBADF
+	// ELOOP is returned when too many symlinks are found resolving a path.
+	ELOOP error = syscall.ELOOP
+	// ENOSPC is returned when there is no space left to write.
+	ENOSPC error = syscall.ENOSPC
+	// ENOTEMPTY is returned when removing a dir which has entries.
+	ENOTEMPTY error = syscall.ENOTEMPTY
+)
@@ -0,0 +1,15 @@
+package errno
+
+import "errors"
+
+// Plan 9 errors are strings, so these match the messages of the other OSes.
+var (
+	// EBADF is returned when operating on a file not opened for it.
+	EBADF = errors.New("bad file descriptor")
+	// ELOOP is returned when too many symlinks are found resolving a path.
+	ELOOP = errors.New("too many levels of symbolic links")
+	// ENOSPC is returned when there is no space left to write.
+	ENOSPC = errors.New("no space left on device")
+	// ENOTEMPTY is returned when removing a dir which has entries.
+	ENOTEMPTY = errors.New("directory not empty")
+)
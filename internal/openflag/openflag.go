@@ -0,0 +1,65 @@
+// Package openflag implements the semantics of the os.OpenFile flags, so
+// that every billy filesystem interprets them the same way.
+package openflag
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrWriteAtInAppendMode is returned by WriteAt on files opened with
+// O_APPEND, mirroring the behaviour of os.File.
+var ErrWriteAtInAppendMode = errors.New("invalid use of WriteAt on file opened with O_APPEND")
+
+const accessMode = os.O_RDONLY | os.O_WRONLY | os.O_RDWR
+
+// Validate returns os.ErrInvalid if flag holds a combination which
+// POSIX leaves unspecified:
+//   - more than one access mode (e.g. O_WRONLY|O_RDWR);
+//   - O_TRUNC without write access.
+func Validate(flag int) error {
+	switch flag & accessMode {
+	case os.O_RDONLY, os.O_WRONLY, os.O_RDWR:
+	default:
+		return os.ErrInvalid
+	}
+
+	if Truncate(flag) && !Writable(flag) {
+		return os.ErrInvalid
+	}
+
+	return nil
+}
+
+// Readable reports whether a file opened with flag can be read.
+func Readable(flag int) bool {
+	m := flag & accessMode
+	return m == os.O_RDONLY || m == os.O_RDWR
+}
+
+// Writable reports whether a file opened with flag can be written.
+func Writable(flag int) bool {
+	m := flag & accessMode
+	return m == os.O_WRONLY || m == os.O_RDWR
+}
+
+// Create reports whether flag asks for the file to be created.
+func Create(flag int) bool {
+	return flag&os.O_CREATE != 0
+}
+
+// Exclusive reports whether flag asks for the file to be created, failing
+// if it already exists. O_EXCL has no effect without O_CREATE.
+func Exclusive(flag int) bool {
+	return Create(flag) && flag&os.O_EXCL != 0
+}
+
+// Append reports whether writes must always go to the end of the file.
+func Append(flag int) bool {
+	return flag&os.O_APPEND != 0
+}
+
+// Truncate reports whether flag asks for the file to be truncated on open.
+func Truncate(flag int) bool {
+	return flag&os.O_TRUNC != 0
+}
@@ -0,0 +1,35 @@
+package openflag
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(os.O_RDONLY))
+	assert.NoError(t, Validate(os.O_RDONLY|os.O_APPEND))
+	assert.NoError(t, Validate(os.O_WRONLY|os.O_TRUNC))
+	assert.NoError(t, Validate(os.O_RDWR|os.O_CREATE|os.O_EXCL|os.O_TRUNC))
+
+	assert.ErrorIs(t, Validate(os.O_RDONLY|os.O_TRUNC), os.ErrInvalid)
+	assert.ErrorIs(t, Validate(os.O_WRONLY|os.O_RDWR), os.ErrInvalid)
+}
+
+func TestAccessMode(t *testing.T) {
+	assert.True(t, Readable(os.O_RDONLY))
+	assert.True(t, Readable(os.O_RDONLY|os.O_APPEND))
+	assert.True(t, Readable(os.O_RDWR))
+	assert.False(t, Readable(os.O_WRONLY))
+
+	assert.True(t, Writable(os.O_WRONLY))
+	assert.True(t, Writable(os.O_RDWR|os.O_APPEND))
+	assert.False(t, Writable(os.O_RDONLY))
+}
+
+func TestExclusive(t *testing.T) {
+	assert.True(t, Exclusive(os.O_CREATE|os.O_EXCL))
+	assert.False(t, Exclusive(os.O_EXCL))
+	assert.False(t, Exclusive(os.O_CREATE))
+}
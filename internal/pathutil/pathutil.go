@@ -0,0 +1,73 @@
+// Package pathutil implements the normalization of the paths given to the
+// wrappers resolving them within a tree of their own, such as mount and
+// prefixfs, so that all of them clean the paths in the same way.
+package pathutil
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+var separator = string(filepath.Separator)
+
+// Clean returns path relative to the root of the tree, using the separator
+// of the OS, with every "." and ".." element resolved, as filepath.Clean
+// does. It is "." for the root itself. The ".." elements of absolute paths
+// stay at the root, while the leading ones of relative paths are kept.
+func Clean(path string) string {
+	path = filepath.FromSlash(path)
+	rel, err := filepath.Rel(separator, path)
+	if err == nil {
+		path = rel
+	}
+
+	return filepath.Clean(path)
+}
+
+// IsRoot reports whether path names the root of the tree. As billy treats ""
+// as ".", this holds for the empty path, along with ".", "/" and every other
+// path cleaned to ".", such as "./" or "foo/..".
+func IsRoot(path string) bool {
+	return Clean(path) == "."
+}
+
+// IsAbs reports whether path is absolute, as the paths starting with a
+// separator are on every OS, including Windows, where filepath.IsAbs also
+// requires a volume name.
+func IsAbs(path string) bool {
+	return strings.HasPrefix(filepath.FromSlash(path), separator) || filepath.IsAbs(path)
+}
+
+// Map returns path as mapped by m, or path itself if m is nil.
+func Map(m billy.PathMapper, path string) string {
+	if m == nil {
+		return path
+	}
+
+	return m.MapPath(path)
+}
+
+// CheckLimits returns a *billy.NameError if path, once cleaned, or one of its
+// elements is longer than allowed by l.
+func CheckLimits(l billy.NameLimits, path string) error {
+	if l.MaxName <= 0 && l.MaxPath <= 0 {
+		return nil
+	}
+
+	clean := Clean(path)
+	if l.MaxPath > 0 && len(clean) > l.MaxPath {
+		return &billy.NameError{Path: path, Max: l.MaxPath}
+	}
+
+	if l.MaxName > 0 {
+		for _, name := range strings.Split(clean, separator) {
+			if len(name) > l.MaxName {
+				return &billy.NameError{Path: path, Name: name, Max: l.MaxName}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package pathutil
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+)
+
+func TestClean(t *testing.T) {
+	for path, want := range map[string]string{
+		"":             ".",
+		"/":            ".",
+		".":            ".",
+		"foo":          "foo",
+		"/foo/bar/":    filepath.Join("foo", "bar"),
+		"./foo/../bar": "bar",
+		"/../foo":      "foo",
+		"../foo":       filepath.Join("..", "foo"),
+	} {
+		assert.Equal(t, want, Clean(path), path)
+	}
+}
+
+func TestIsRoot(t *testing.T) {
+	for _, path := range []string{"", ".", "/", "./", "//", "foo/..", "/.."} {
+		assert.True(t, IsRoot(path), path)
+	}
+	for _, path := range []string{"foo", "/foo", "..", "../foo"} {
+		assert.False(t, IsRoot(path), path)
+	}
+}
+
+func TestIsAbs(t *testing.T) {
+	assert.True(t, IsAbs("/foo"))
+	assert.True(t, IsAbs(string(filepath.Separator)+"foo"))
+	assert.False(t, IsAbs("foo"))
+	assert.False(t, IsAbs("./foo"))
+}
+
+func TestMap(t *testing.T) {
+	assert.Equal(t, "Foo", Map(nil, "Foo"))
+	assert.Equal(t, "foo", Map(billy.PathMapperFunc(strings.ToLower), "Foo"))
+}
+
+func TestCheckLimits(t *testing.T) {
+	long := strings.Repeat("a", 256)
+	assert.NoError(t, CheckLimits(billy.NameLimits{}, long))
+	assert.NoError(t, CheckLimits(billy.DefaultNameLimits, "/foo/"+long[1:]))
+	assert.NoError(t, CheckLimits(billy.NameLimits{MaxPath: 3}, "/foo/../bar"))
+
+	err := CheckLimits(billy.DefaultNameLimits, "foo/"+long)
+	var nerr *billy.NameError
+	assert.ErrorAs(t, err, &nerr)
+	assert.Equal(t, long, nerr.Name)
+	assert.Equal(t, 255, nerr.Max)
+	assert.ErrorIs(t, err, billy.ErrNameTooLong)
+
+	err = CheckLimits(billy.NameLimits{MaxPath: 6}, "foo/bar")
+	assert.ErrorAs(t, err, &nerr)
+	assert.Equal(t, "foo/bar", nerr.Path)
+	assert.Empty(t, nerr.Name)
+	assert.Equal(t, 6, nerr.Max)
+}
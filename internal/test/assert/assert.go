@@ -0,0 +1,570 @@
+// Package assert provides the assertions used by the tests of this module.
+//
+// It follows the API of github.com/stretchr/testify/assert, covering only the
+// assertions the tests use, so that the module has no dependencies for them.
+// Every assertion reports a failure with t.Errorf and returns whether it
+// passed.
+package assert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// TestingT is the interface of *testing.T used by the assertions.
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+type tHelper interface {
+	Helper()
+}
+
+// Fail reports a failure, described by failure and, if given, the message
+// built from msgAndArgs.
+func Fail(t TestingT, failure string, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if msg := message(msgAndArgs...); msg != "" {
+		t.Errorf("%s\nMessages: %s", failure, msg)
+	} else {
+		t.Errorf("%s", failure)
+	}
+
+	return false
+}
+
+func message(msgAndArgs ...any) string {
+	switch len(msgAndArgs) {
+	case 0:
+		return ""
+	case 1:
+		if s, ok := msgAndArgs[0].(string); ok {
+			return s
+		}
+		return fmt.Sprintf("%+v", msgAndArgs[0])
+	default:
+		return fmt.Sprintf(msgAndArgs[0].(string), msgAndArgs[1:]...)
+	}
+}
+
+// ObjectsAreEqual reports whether expected and actual are deeply equal, byte
+// slices being compared by their contents.
+func ObjectsAreEqual(expected, actual any) bool {
+	if expected == nil || actual == nil {
+		return expected == actual
+	}
+
+	exp, ok := expected.([]byte)
+	if !ok {
+		return reflect.DeepEqual(expected, actual)
+	}
+
+	act, ok := actual.([]byte)
+	if !ok {
+		return false
+	}
+	if exp == nil || act == nil {
+		return exp == nil && act == nil
+	}
+
+	return bytes.Equal(exp, act)
+}
+
+// Equal asserts that expected and actual are equal.
+func Equal(t TestingT, expected, actual any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !ObjectsAreEqual(expected, actual) {
+		return Fail(t, fmt.Sprintf("Not equal:\nexpected: %#v\nactual  : %#v", expected, actual), msgAndArgs...)
+	}
+
+	return true
+}
+
+// NotEqual asserts that expected and actual are not equal.
+func NotEqual(t TestingT, expected, actual any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if ObjectsAreEqual(expected, actual) {
+		return Fail(t, fmt.Sprintf("Should not be: %#v", actual), msgAndArgs...)
+	}
+
+	return true
+}
+
+// Same asserts that expected and actual are pointers to the same object.
+func Same(t TestingT, expected, actual any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	e, a := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if e.Kind() != reflect.Ptr || a.Kind() != reflect.Ptr || e.Type() != a.Type() || e.Pointer() != a.Pointer() {
+		return Fail(t, fmt.Sprintf("Not same:\nexpected: %p %#v\nactual  : %p %#v", expected, expected, actual, actual), msgAndArgs...)
+	}
+
+	return true
+}
+
+// IsType asserts that object has the same type as expectedType.
+func IsType(t TestingT, expectedType, object any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if reflect.TypeOf(expectedType) != reflect.TypeOf(object) {
+		return Fail(t, fmt.Sprintf("Object expected to be of type %T, but was %T", expectedType, object), msgAndArgs...)
+	}
+
+	return true
+}
+
+// True asserts that value is true.
+func True(t TestingT, value bool, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !value {
+		return Fail(t, "Should be true", msgAndArgs...)
+	}
+
+	return true
+}
+
+// False asserts that value is false.
+func False(t TestingT, value bool, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if value {
+		return Fail(t, "Should be false", msgAndArgs...)
+	}
+
+	return true
+}
+
+func isNil(object any) bool {
+	if object == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(object)
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// Nil asserts that object is nil.
+func Nil(t TestingT, object any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !isNil(object) {
+		return Fail(t, fmt.Sprintf("Expected nil, but got: %#v", object), msgAndArgs...)
+	}
+
+	return true
+}
+
+// NotNil asserts that object is not nil.
+func NotNil(t TestingT, object any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if isNil(object) {
+		return Fail(t, "Expected value not to be nil.", msgAndArgs...)
+	}
+
+	return true
+}
+
+func isZero(object any) bool {
+	return object == nil || reflect.ValueOf(object).IsZero()
+}
+
+// Zero asserts that object is the zero value of its type.
+func Zero(t TestingT, object any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !isZero(object) {
+		return Fail(t, fmt.Sprintf("Should be zero, but was %#v", object), msgAndArgs...)
+	}
+
+	return true
+}
+
+// NotZero asserts that object is not the zero value of its type.
+func NotZero(t TestingT, object any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if isZero(object) {
+		return Fail(t, fmt.Sprintf("Should not be zero, but was %#v", object), msgAndArgs...)
+	}
+
+	return true
+}
+
+func isEmpty(object any) bool {
+	if object == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(object)
+	switch v.Kind() {
+	case reflect.Chan, reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	case reflect.Ptr:
+		if v.IsNil() {
+			return true
+		}
+		return isEmpty(v.Elem().Interface())
+	}
+
+	return v.IsZero()
+}
+
+// Empty asserts that object is nil, has no elements or is the zero value of
+// its type.
+func Empty(t TestingT, object any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !isEmpty(object) {
+		return Fail(t, fmt.Sprintf("Should be empty, but was %#v", object), msgAndArgs...)
+	}
+
+	return true
+}
+
+// Len asserts that object has length elements.
+func Len(t TestingT, object any, length int, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	v := reflect.ValueOf(object)
+	switch v.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
+	default:
+		return Fail(t, fmt.Sprintf("\"%v\" could not be applied builtin len()", object), msgAndArgs...)
+	}
+
+	if v.Len() != length {
+		return Fail(t, fmt.Sprintf("\"%v\" should have %d item(s), but has %d", object, length, v.Len()), msgAndArgs...)
+	}
+
+	return true
+}
+
+// containsElement reports whether list, a string, array, slice or map,
+// contains element. ok is false if list can not contain elements.
+func containsElement(list, element any) (ok, found bool) {
+	v := reflect.ValueOf(list)
+	switch v.Kind() {
+	case reflect.String:
+		e := reflect.ValueOf(element)
+		if e.Kind() != reflect.String {
+			return false, false
+		}
+		return true, strings.Contains(v.String(), e.String())
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if ObjectsAreEqual(k.Interface(), element) {
+				return true, true
+			}
+		}
+		return true, false
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if ObjectsAreEqual(v.Index(i).Interface(), element) {
+				return true, true
+			}
+		}
+		return true, false
+	}
+
+	return false, false
+}
+
+// Contains asserts that s, a string, array, slice or map, contains contains.
+func Contains(t TestingT, s, contains any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	ok, found := containsElement(s, contains)
+	if !ok {
+		return Fail(t, fmt.Sprintf("%#v could not be applied builtin len()", s), msgAndArgs...)
+	}
+	if !found {
+		return Fail(t, fmt.Sprintf("%#v does not contain %#v", s, contains), msgAndArgs...)
+	}
+
+	return true
+}
+
+// NotContains asserts that s, a string, array, slice or map, does not contain
+// contains.
+func NotContains(t TestingT, s, contains any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	ok, found := containsElement(s, contains)
+	if !ok {
+		return Fail(t, fmt.Sprintf("%#v could not be applied builtin len()", s), msgAndArgs...)
+	}
+	if found {
+		return Fail(t, fmt.Sprintf("%#v should not contain %#v", s, contains), msgAndArgs...)
+	}
+
+	return true
+}
+
+// ElementsMatch asserts that the lists listA and listB hold the same
+// elements, regardless of their order.
+func ElementsMatch(t TestingT, listA, listB any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if isEmpty(listA) && isEmpty(listB) {
+		return true
+	}
+
+	a, b := reflect.ValueOf(listA), reflect.ValueOf(listB)
+	for _, v := range []reflect.Value{a, b} {
+		if v.Kind() != reflect.Array && v.Kind() != reflect.Slice {
+			return Fail(t, fmt.Sprintf("%#v is not a list", v.Interface()), msgAndArgs...)
+		}
+	}
+
+	matched := make([]bool, b.Len())
+	for i := 0; i < a.Len(); i++ {
+		found := false
+		for j := 0; j < b.Len(); j++ {
+			if !matched[j] && ObjectsAreEqual(a.Index(i).Interface(), b.Index(j).Interface()) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			return Fail(t, fmt.Sprintf("Elements differ:\nlistA: %#v\nlistB: %#v", listA, listB), msgAndArgs...)
+		}
+	}
+
+	if a.Len() != b.Len() {
+		return Fail(t, fmt.Sprintf("Elements differ:\nlistA: %#v\nlistB: %#v", listA, listB), msgAndArgs...)
+	}
+
+	return true
+}
+
+// compare returns the order of e1 and e2, which must be numbers or strings
+// of the same type. ok is false if they can not be compared.
+func compare(e1, e2 any) (cmp int, ok bool) {
+	v1, v2 := reflect.ValueOf(e1), reflect.ValueOf(e2)
+	if !v1.IsValid() || !v2.IsValid() || v1.Type() != v2.Type() {
+		return 0, false
+	}
+
+	order := func(less, greater bool) int {
+		switch {
+		case less:
+			return -1
+		case greater:
+			return 1
+		}
+		return 0
+	}
+
+	switch v1.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return order(v1.Int() < v2.Int(), v1.Int() > v2.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return order(v1.Uint() < v2.Uint(), v1.Uint() > v2.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return order(v1.Float() < v2.Float(), v1.Float() > v2.Float()), true
+	case reflect.String:
+		return order(v1.String() < v2.String(), v1.String() > v2.String()), true
+	}
+
+	return 0, false
+}
+
+func compareTwo(t TestingT, e1, e2 any, allowed []int, failure string, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	cmp, ok := compare(e1, e2)
+	if !ok {
+		return Fail(t, fmt.Sprintf("Can not compare %#v and %#v", e1, e2), msgAndArgs...)
+	}
+
+	for _, a := range allowed {
+		if cmp == a {
+			return true
+		}
+	}
+
+	return Fail(t, fmt.Sprintf(failure, e1, e2), msgAndArgs...)
+}
+
+// GreaterOrEqual asserts that e1 is greater than or equal to e2.
+func GreaterOrEqual(t TestingT, e1, e2 any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return compareTwo(t, e1, e2, []int{1, 0}, "\"%v\" is not greater than or equal to \"%v\"", msgAndArgs...)
+}
+
+// Less asserts that e1 is less than e2.
+func Less(t TestingT, e1, e2 any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return compareTwo(t, e1, e2, []int{-1}, "\"%v\" is not less than \"%v\"", msgAndArgs...)
+}
+
+// LessOrEqual asserts that e1 is less than or equal to e2.
+func LessOrEqual(t TestingT, e1, e2 any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return compareTwo(t, e1, e2, []int{-1, 0}, "\"%v\" is not less than or equal to \"%v\"", msgAndArgs...)
+}
+
+// InDelta asserts that expected and actual are within delta of each other.
+func InDelta(t TestingT, expected, actual any, delta float64, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	e, a := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if !e.CanConvert(reflect.TypeOf(0.0)) || !a.CanConvert(reflect.TypeOf(0.0)) {
+		return Fail(t, "Parameters must be numerical", msgAndArgs...)
+	}
+
+	diff := e.Convert(reflect.TypeOf(0.0)).Float() - a.Convert(reflect.TypeOf(0.0)).Float()
+	if diff < -delta || diff > delta {
+		return Fail(t, fmt.Sprintf("Max difference between %v and %v allowed is %v, but difference was %v", expected, actual, delta, diff), msgAndArgs...)
+	}
+
+	return true
+}
+
+// Regexp asserts that str matches rx, a *regexp.Regexp or a string holding a
+// regular expression.
+func Regexp(t TestingT, rx, str any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	r, ok := rx.(*regexp.Regexp)
+	if !ok {
+		r = regexp.MustCompile(fmt.Sprint(rx))
+	}
+
+	if !r.MatchString(fmt.Sprint(str)) {
+		return Fail(t, fmt.Sprintf("Expect \"%v\" to match \"%v\"", str, r), msgAndArgs...)
+	}
+
+	return true
+}
+
+// NoError asserts that err is nil.
+func NoError(t TestingT, err error, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if err != nil {
+		return Fail(t, fmt.Sprintf("Received unexpected error:\n%+v", err), msgAndArgs...)
+	}
+
+	return true
+}
+
+// Error asserts that err is not nil.
+func Error(t TestingT, err error, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if err == nil {
+		return Fail(t, "An error is expected but got nil.", msgAndArgs...)
+	}
+
+	return true
+}
+
+// ErrorContains asserts that err is not nil and that its message contains
+// contains.
+func ErrorContains(t TestingT, err error, contains string, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !Error(t, err, msgAndArgs...) {
+		return false
+	}
+
+	if !strings.Contains(err.Error(), contains) {
+		return Fail(t, fmt.Sprintf("Error %q does not contain %q", err, contains), msgAndArgs...)
+	}
+
+	return true
+}
+
+// ErrorIs asserts that errors.Is(err, target) is true.
+func ErrorIs(t TestingT, err, target error, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !errors.Is(err, target) {
+		return Fail(t, fmt.Sprintf("Target error should be in err chain:\nexpected: %q\nin chain: %v", target, err), msgAndArgs...)
+	}
+
+	return true
+}
+
+// ErrorAs asserts that errors.As(err, target) is true.
+func ErrorAs(t TestingT, err error, target any, msgAndArgs ...any) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !errors.As(err, target) {
+		return Fail(t, fmt.Sprintf("Should be in error chain:\nexpected: %T\nin chain: %v", target, err), msgAndArgs...)
+	}
+
+	return true
+}
@@ -0,0 +1,241 @@
+package assert
+
+// Assertions provides the assertions of the package bound to a TestingT.
+type Assertions struct {
+	t TestingT
+}
+
+// New returns the Assertions bound to t.
+func New(t TestingT) *Assertions {
+	return &Assertions{t: t}
+}
+
+// Equal asserts that expected and actual are equal.
+func (a *Assertions) Equal(expected, actual any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Equal(a.t, expected, actual, msgAndArgs...)
+}
+
+// NotEqual asserts that expected and actual are not equal.
+func (a *Assertions) NotEqual(expected, actual any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return NotEqual(a.t, expected, actual, msgAndArgs...)
+}
+
+// Same asserts that expected and actual are pointers to the same object.
+func (a *Assertions) Same(expected, actual any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Same(a.t, expected, actual, msgAndArgs...)
+}
+
+// IsType asserts that object has the same type as expectedType.
+func (a *Assertions) IsType(expectedType, object any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return IsType(a.t, expectedType, object, msgAndArgs...)
+}
+
+// True asserts that value is true.
+func (a *Assertions) True(value bool, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return True(a.t, value, msgAndArgs...)
+}
+
+// False asserts that value is false.
+func (a *Assertions) False(value bool, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return False(a.t, value, msgAndArgs...)
+}
+
+// Nil asserts that object is nil.
+func (a *Assertions) Nil(object any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Nil(a.t, object, msgAndArgs...)
+}
+
+// NotNil asserts that object is not nil.
+func (a *Assertions) NotNil(object any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return NotNil(a.t, object, msgAndArgs...)
+}
+
+// Zero asserts that object is the zero value of its type.
+func (a *Assertions) Zero(object any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Zero(a.t, object, msgAndArgs...)
+}
+
+// NotZero asserts that object is not the zero value of its type.
+func (a *Assertions) NotZero(object any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return NotZero(a.t, object, msgAndArgs...)
+}
+
+// Empty asserts that object is nil, has no elements or is the zero value of
+// its type.
+func (a *Assertions) Empty(object any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Empty(a.t, object, msgAndArgs...)
+}
+
+// Len asserts that object has length elements.
+func (a *Assertions) Len(object any, length int, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Len(a.t, object, length, msgAndArgs...)
+}
+
+// Contains asserts that s, a string, array, slice or map, contains contains.
+func (a *Assertions) Contains(s, contains any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Contains(a.t, s, contains, msgAndArgs...)
+}
+
+// NotContains asserts that s, a string, array, slice or map, does not contain
+// contains.
+func (a *Assertions) NotContains(s, contains any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return NotContains(a.t, s, contains, msgAndArgs...)
+}
+
+// ElementsMatch asserts that the lists listA and listB hold the same
+// elements, regardless of their order.
+func (a *Assertions) ElementsMatch(listA, listB any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return ElementsMatch(a.t, listA, listB, msgAndArgs...)
+}
+
+// GreaterOrEqual asserts that e1 is greater than or equal to e2.
+func (a *Assertions) GreaterOrEqual(e1, e2 any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return GreaterOrEqual(a.t, e1, e2, msgAndArgs...)
+}
+
+// Less asserts that e1 is less than e2.
+func (a *Assertions) Less(e1, e2 any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Less(a.t, e1, e2, msgAndArgs...)
+}
+
+// LessOrEqual asserts that e1 is less than or equal to e2.
+func (a *Assertions) LessOrEqual(e1, e2 any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return LessOrEqual(a.t, e1, e2, msgAndArgs...)
+}
+
+// InDelta asserts that expected and actual are within delta of each other.
+func (a *Assertions) InDelta(expected, actual any, delta float64, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return InDelta(a.t, expected, actual, delta, msgAndArgs...)
+}
+
+// Regexp asserts that str matches rx, a *regexp.Regexp or a string holding a
+// regular expression.
+func (a *Assertions) Regexp(rx, str any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Regexp(a.t, rx, str, msgAndArgs...)
+}
+
+// NoError asserts that err is nil.
+func (a *Assertions) NoError(err error, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return NoError(a.t, err, msgAndArgs...)
+}
+
+// Error asserts that err is not nil.
+func (a *Assertions) Error(err error, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return Error(a.t, err, msgAndArgs...)
+}
+
+// ErrorContains asserts that err is not nil and that its message contains
+// contains.
+func (a *Assertions) ErrorContains(err error, contains string, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return ErrorContains(a.t, err, contains, msgAndArgs...)
+}
+
+// ErrorIs asserts that errors.Is(err, target) is true.
+func (a *Assertions) ErrorIs(err, target error, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return ErrorIs(a.t, err, target, msgAndArgs...)
+}
+
+// ErrorAs asserts that errors.As(err, target) is true.
+func (a *Assertions) ErrorAs(err error, target any, msgAndArgs ...any) bool {
+	if h, ok := a.t.(tHelper); ok {
+		h.Helper()
+	}
+
+	return ErrorAs(a.t, err, target, msgAndArgs...)
+}
@@ -57,7 +57,10 @@ func (fs *BasicMock) Join(elem ...string) string {
 
 type TempFileMock struct {
 	BasicMock
-	TempFileArgs [][2]string
+	TempFileArgs   [][2]string
+	TempDirArgs    [][2]string
+	CreateTempArgs [][2]string
+	MkdirTempArgs  [][2]string
 }
 
 func (fs *TempFileMock) TempFile(dir, prefix string) (billy.File, error) {
@@ -65,6 +68,21 @@ func (fs *TempFileMock) TempFile(dir, prefix string) (billy.File, error) {
 	return &FileMock{name: "/tmp/hardcoded/mock/temp"}, nil
 }
 
+func (fs *TempFileMock) TempDir(dir, prefix string) (string, error) {
+	fs.TempDirArgs = append(fs.TempDirArgs, [2]string{dir, prefix})
+	return "/tmp/hardcoded/mock/tempdir", nil
+}
+
+func (fs *TempFileMock) CreateTemp(dir, pattern string) (billy.File, error) {
+	fs.CreateTempArgs = append(fs.CreateTempArgs, [2]string{dir, pattern})
+	return &FileMock{name: "/tmp/hardcoded/mock/temp"}, nil
+}
+
+func (fs *TempFileMock) MkdirTemp(dir, pattern string) (string, error) {
+	fs.MkdirTempArgs = append(fs.MkdirTempArgs, [2]string{dir, pattern})
+	return "/tmp/hardcoded/mock/tempdir", nil
+}
+
 type DirMock struct {
 	BasicMock
 	ReadDirArgs  []string
@@ -112,6 +130,10 @@ func (f *FileMock) Name() string {
 	return f.name
 }
 
+func (f *FileMock) OpenedPath() string {
+	return f.name
+}
+
 func (*FileMock) ReadAt(_ []byte, _ int64) (int, error) {
 	return 0, nil
 }
@@ -144,6 +166,10 @@ func (*FileMock) Truncate(_ int64) error {
 	return nil
 }
 
+func (*FileMock) Sync() error {
+	return nil
+}
+
 type OnlyReadCapFs struct {
 	BasicMock
 }
@@ -163,3 +189,15 @@ func (o *NoLockCapFs) Capabilities() billy.Capability {
 		billy.SeekCapability |
 		billy.TruncateCapability
 }
+
+type CaseInsensitiveFs struct {
+	BasicMock
+}
+
+func (o *CaseInsensitiveFs) PathProperties() billy.PathProperties {
+	return billy.PathProperties{
+		MaxPathLength: 260,
+		MaxNameLength: 255,
+		InvalidChars:  "\x00<>:\"\\|?*",
+	}
+}
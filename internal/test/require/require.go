@@ -0,0 +1,298 @@
+// Package require provides the same assertions as the assert package of
+// this module, which stop the test when they fail.
+//
+// It follows the API of github.com/stretchr/testify/require, covering only
+// the assertions the tests use.
+package require
+
+import "github.com/go-git/go-billy/v6/internal/test/assert"
+
+// TestingT is the interface of *testing.T used by the assertions.
+type TestingT interface {
+	Errorf(format string, args ...any)
+	FailNow()
+}
+
+type tHelper interface {
+	Helper()
+}
+
+// Equal requires that expected and actual are equal.
+func Equal(t TestingT, expected, actual any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Equal(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// NotEqual requires that expected and actual are not equal.
+func NotEqual(t TestingT, expected, actual any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.NotEqual(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Same requires that expected and actual are pointers to the same object.
+func Same(t TestingT, expected, actual any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Same(t, expected, actual, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// IsType requires that object has the same type as expectedType.
+func IsType(t TestingT, expectedType, object any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.IsType(t, expectedType, object, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// True requires that value is true.
+func True(t TestingT, value bool, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.True(t, value, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// False requires that value is false.
+func False(t TestingT, value bool, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.False(t, value, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Nil requires that object is nil.
+func Nil(t TestingT, object any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Nil(t, object, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// NotNil requires that object is not nil.
+func NotNil(t TestingT, object any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.NotNil(t, object, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Zero requires that object is the zero value of its type.
+func Zero(t TestingT, object any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Zero(t, object, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// NotZero requires that object is not the zero value of its type.
+func NotZero(t TestingT, object any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.NotZero(t, object, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Empty requires that object is nil, has no elements or is the zero value of
+// its type.
+func Empty(t TestingT, object any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Empty(t, object, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Len requires that object has length elements.
+func Len(t TestingT, object any, length int, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Len(t, object, length, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Contains requires that s, a string, array, slice or map, contains contains.
+func Contains(t TestingT, s, contains any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Contains(t, s, contains, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// NotContains requires that s, a string, array, slice or map, does not contain
+// contains.
+func NotContains(t TestingT, s, contains any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.NotContains(t, s, contains, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// ElementsMatch requires that the lists listA and listB hold the same
+// elements, regardless of their order.
+func ElementsMatch(t TestingT, listA, listB any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.ElementsMatch(t, listA, listB, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// GreaterOrEqual requires that e1 is greater than or equal to e2.
+func GreaterOrEqual(t TestingT, e1, e2 any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.GreaterOrEqual(t, e1, e2, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Less requires that e1 is less than e2.
+func Less(t TestingT, e1, e2 any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Less(t, e1, e2, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// LessOrEqual requires that e1 is less than or equal to e2.
+func LessOrEqual(t TestingT, e1, e2 any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.LessOrEqual(t, e1, e2, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// InDelta requires that expected and actual are within delta of each other.
+func InDelta(t TestingT, expected, actual any, delta float64, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.InDelta(t, expected, actual, delta, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Regexp requires that str matches rx, a *regexp.Regexp or a string holding a
+// regular expression.
+func Regexp(t TestingT, rx, str any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Regexp(t, rx, str, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// NoError requires that err is nil.
+func NoError(t TestingT, err error, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.NoError(t, err, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// Error requires that err is not nil.
+func Error(t TestingT, err error, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.Error(t, err, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// ErrorContains requires that err is not nil and that its message contains
+// contains.
+func ErrorContains(t TestingT, err error, contains string, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.ErrorContains(t, err, contains, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// ErrorIs requires that errors.Is(err, target) is true.
+func ErrorIs(t TestingT, err, target error, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.ErrorIs(t, err, target, msgAndArgs...) {
+		t.FailNow()
+	}
+}
+
+// ErrorAs requires that errors.As(err, target) is true.
+func ErrorAs(t TestingT, err error, target any, msgAndArgs ...any) {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+
+	if !assert.ErrorAs(t, err, target, msgAndArgs...) {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,130 @@
+package wrappedfile_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/bufferfs"
+	"github.com/go-git/go-billy/v6/helper/cachefs"
+	"github.com/go-git/go-billy/v6/helper/countingfs"
+	"github.com/go-git/go-billy/v6/helper/encryptfs"
+	"github.com/go-git/go-billy/v6/helper/faultfs"
+	"github.com/go-git/go-billy/v6/helper/interceptfs"
+	"github.com/go-git/go-billy/v6/helper/limit"
+	"github.com/go-git/go-billy/v6/helper/recordfs"
+	"github.com/go-git/go-billy/v6/helper/retryfs"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+)
+
+// recordingFS opens files recording the calls to the methods of the optional
+// file interfaces.
+type recordingFS struct {
+	billy.Filesystem
+	calls *[]string
+}
+
+func (fs *recordingFS) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	f, err := fs.Filesystem.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordingFile{File: f, calls: fs.calls}, nil
+}
+
+type recordingFile struct {
+	billy.File
+	calls *[]string
+}
+
+func (f *recordingFile) record(name string) { *f.calls = append(*f.calls, name) }
+
+func (f *recordingFile) Chmod(fs.FileMode) error            { f.record("Chmod"); return nil }
+func (f *recordingFile) Chtimes(time.Time, time.Time) error { f.record("Chtimes"); return nil }
+func (f *recordingFile) RawFile() (*os.File, error)         { f.record("RawFile"); return nil, nil }
+func (f *recordingFile) ReadDir(int) ([]fs.DirEntry, error) { f.record("ReadDir"); return nil, nil }
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	block, err := aes.NewCipher(make([]byte, 32))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	return aead
+}
+
+func TestHelpersForwarding(t *testing.T) {
+	helpers := []struct {
+		name string
+		wrap func(billy.Filesystem) billy.Filesystem
+		// noRawFile is set for the helpers which can't hand out the
+		// *os.File, as they need to see every operation on the file.
+		noRawFile bool
+	}{
+		{name: "interceptfs", wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return interceptfs.New(fs, interceptfs.Hooks{})
+		}},
+		{name: "recordfs", noRawFile: true, wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return recordfs.New(fs, io.Discard)
+		}},
+		{name: "faultfs", noRawFile: true, wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return faultfs.New(fs)
+		}},
+		{name: "retryfs", wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return retryfs.New(fs)
+		}},
+		{name: "limit", noRawFile: true, wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return limit.New(fs)
+		}},
+		{name: "countingfs", noRawFile: true, wrap: countingfs.New},
+		{name: "bufferfs", wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return bufferfs.New(fs)
+		}},
+		{name: "cachefs", noRawFile: true, wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return cachefs.New(fs)
+		}},
+		{name: "encryptfs", noRawFile: true, wrap: func(fs billy.Filesystem) billy.Filesystem {
+			return encryptfs.New(fs, newAEAD(t))
+		}},
+	}
+
+	for _, h := range helpers {
+		t.Run(h.name, func(t *testing.T) {
+			var calls []string
+			fs := h.wrap(&recordingFS{Filesystem: memfs.New(), calls: &calls})
+
+			f, err := fs.OpenFile("foo", os.O_RDWR|os.O_CREATE, 0o644)
+			require.NoError(t, err)
+			defer f.Close()
+
+			fc, ok := f.(billy.FileChange)
+			require.True(t, ok, "FileChange")
+			assert.NoError(t, fc.Chmod(0o600))
+			assert.NoError(t, fc.Chtimes(time.Time{}, time.Time{}))
+
+			rdf, ok := f.(billy.ReadDirFile)
+			require.True(t, ok, "ReadDirFile")
+			_, err = rdf.ReadDir(-1)
+			assert.NoError(t, err)
+
+			raw, ok := f.(billy.RawFile)
+			require.True(t, ok, "RawFile")
+			_, err = raw.RawFile()
+			if h.noRawFile {
+				assert.ErrorIs(t, err, billy.ErrNotSupported)
+				assert.Equal(t, []string{"Chmod", "Chtimes", "ReadDir"}, calls)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"Chmod", "Chtimes", "ReadDir", "RawFile"}, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package wrappedfile implements the files returned by the wrappers
+// resolving paths within a tree of their own, such as chroot, mount and
+// prefixfs, which report the files under their own names while forwarding
+// everything else to the files of the filesystems they wrap, so that all of
+// them forward the same methods. The helpers changing the behaviour of some
+// methods of their files, such as limit or retryfs, embed it to forward the
+// rest.
+package wrappedfile
+
+import (
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// File is a billy.File forwarding every method to the file it wraps, except
+// for Name and OpenedPath, which it can override. The methods of the
+// optional interfaces, such as billy.FileChange, fail with an error wrapping
+// billy.ErrNotSupported when the wrapped file does not implement them.
+type File struct {
+	billy.File
+	name       string
+	openedPath string
+}
+
+// New returns f, opened as openedPath and named name, or keeping the name of
+// f if name is empty. As billy treats "" as ".", openedPath is reported as
+// given, even if empty. New returns nil if f is nil, so the results of
+// failed opens can be wrapped as they are.
+func New(f billy.File, name, openedPath string) billy.File {
+	if f == nil {
+		return nil
+	}
+
+	return &File{File: f, name: name, openedPath: openedPath}
+}
+
+// Wrap returns a File forwarding every method to f, including Name and
+// OpenedPath, to be embedded by the files overriding some of them.
+func Wrap(f billy.File) *File {
+	return &File{File: f, openedPath: f.OpenedPath()}
+}
+
+// Name returns the name given to New, or else the name of the wrapped file.
+func (f *File) Name() string {
+	if f.name == "" {
+		return f.File.Name()
+	}
+
+	return f.name
+}
+
+// OpenedPath returns the opened path given to New.
+func (f *File) OpenedPath() string {
+	return f.openedPath
+}
+
+// Chmod implements the billy.FileChange interface, if the wrapped file does.
+func (f *File) Chmod(mode fs.FileMode) error {
+	return util.FileChmod(f.File, mode)
+}
+
+// Chtimes implements the billy.FileChange interface, if the wrapped file
+// does.
+func (f *File) Chtimes(atime time.Time, mtime time.Time) error {
+	return util.FileChtimes(f.File, atime, mtime)
+}
+
+// RawFile implements the billy.RawFile interface, if the wrapped file does.
+func (f *File) RawFile() (*os.File, error) {
+	return util.RawFile(f.File)
+}
+
+// ReadDir implements the billy.ReadDirFile interface, if the wrapped file
+// does.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	return util.FileReadDir(f.File, n)
+}
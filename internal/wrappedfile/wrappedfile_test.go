@@ -0,0 +1,113 @@
+package wrappedfile
+
+import (
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+)
+
+// recordingFile implements billy.File and every optional file interface,
+// recording the methods called.
+type recordingFile struct {
+	calls []string
+}
+
+func (f *recordingFile) record(name string) { f.calls = append(f.calls, name) }
+
+func (f *recordingFile) Name() string                       { f.record("Name"); return "inner" }
+func (f *recordingFile) OpenedPath() string                 { f.record("OpenedPath"); return "inner" }
+func (f *recordingFile) Read([]byte) (int, error)           { f.record("Read"); return 0, nil }
+func (f *recordingFile) ReadAt([]byte, int64) (int, error)  { f.record("ReadAt"); return 0, nil }
+func (f *recordingFile) Write([]byte) (int, error)          { f.record("Write"); return 0, nil }
+func (f *recordingFile) WriteAt([]byte, int64) (int, error) { f.record("WriteAt"); return 0, nil }
+func (f *recordingFile) Seek(int64, int) (int64, error)     { f.record("Seek"); return 0, nil }
+func (f *recordingFile) Close() error                       { f.record("Close"); return nil }
+func (f *recordingFile) Lock() error                        { f.record("Lock"); return nil }
+func (f *recordingFile) Unlock() error                      { f.record("Unlock"); return nil }
+func (f *recordingFile) Truncate(int64) error               { f.record("Truncate"); return nil }
+func (f *recordingFile) Sync() error                        { f.record("Sync"); return nil }
+func (f *recordingFile) Stat() (fs.FileInfo, error)         { f.record("Stat"); return nil, nil }
+func (f *recordingFile) Chmod(fs.FileMode) error            { f.record("Chmod"); return nil }
+func (f *recordingFile) Chtimes(time.Time, time.Time) error { f.record("Chtimes"); return nil }
+func (f *recordingFile) RawFile() (*os.File, error)         { f.record("RawFile"); return nil, nil }
+func (f *recordingFile) ReadDir(int) ([]fs.DirEntry, error) { f.record("ReadDir"); return nil, nil }
+
+// plainFile implements only billy.File.
+type plainFile struct {
+	billy.File
+}
+
+// optional lists the optional interfaces of the files, which File forwards.
+var optional = []reflect.Type{
+	reflect.TypeOf((*billy.FileChange)(nil)).Elem(),
+	reflect.TypeOf((*billy.RawFile)(nil)).Elem(),
+	reflect.TypeOf((*billy.ReadDirFile)(nil)).Elem(),
+}
+
+// call calls the method named name of v with zero arguments, returning its
+// results.
+func call(v interface{}, name string) []reflect.Value {
+	m := reflect.ValueOf(v).MethodByName(name)
+	args := make([]reflect.Value, m.Type().NumIn())
+	for i := range args {
+		args[i] = reflect.Zero(m.Type().In(i))
+	}
+
+	return m.Call(args)
+}
+
+func TestForwarding(t *testing.T) {
+	types := append([]reflect.Type{reflect.TypeOf((*billy.File)(nil)).Elem()}, optional...)
+	for _, typ := range types {
+		for i := 0; i < typ.NumMethod(); i++ {
+			name := typ.Method(i).Name
+			if name == "Name" || name == "OpenedPath" {
+				continue
+			}
+
+			inner := &recordingFile{}
+			f := New(inner, "outer", "opened")
+			require.True(t, reflect.TypeOf(f).Implements(typ), "%s.%s", typ, name)
+
+			call(f, name)
+			assert.Equal(t, []string{name}, inner.calls, "%s.%s", typ, name)
+		}
+	}
+}
+
+func TestOptionalNotSupported(t *testing.T) {
+	file := reflect.TypeOf((*billy.File)(nil)).Elem()
+	f := New(plainFile{&recordingFile{}}, "outer", "opened")
+	for _, typ := range optional {
+		for i := 0; i < typ.NumMethod(); i++ {
+			name := typ.Method(i).Name
+			if _, ok := file.MethodByName(name); ok {
+				continue
+			}
+
+			out := call(f, name)
+			err, _ := out[len(out)-1].Interface().(error)
+			assert.ErrorIs(t, err, billy.ErrNotSupported, "%s.%s", typ, name)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	inner := &recordingFile{}
+
+	f := New(inner, "outer", "opened")
+	assert.Equal(t, "outer", f.Name())
+	assert.Equal(t, "opened", f.OpenedPath())
+
+	f = New(inner, "", "")
+	assert.Equal(t, "inner", f.Name())
+	assert.Equal(t, "", f.OpenedPath())
+
+	assert.Nil(t, New(nil, "outer", "opened"))
+}
@@ -0,0 +1,259 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"unique"
+)
+
+// chunkSize is the size of the chunks the content of the files is split into.
+const chunkSize = 32 << 10
+
+// zeroChunk is a sealed chunk full of zeros, shared by the files grown with
+// Truncate or by writing past their end.
+var zeroChunk = sync.OnceValue(func() chunk {
+	return chunk{handle: unique.Make(string(make([]byte, chunkSize)))}
+})
+
+// chunk holds up to chunkSize bytes of the content of a file. Chunks are
+// mutable while the file is being written, and sealed when it is closed.
+// Sealed chunks are interned with the unique package, so identical chunks
+// share the same memory across any number of files. They are immutable, and
+// copied back to a mutable chunk before being written to.
+type chunk struct {
+	handle  unique.Handle[string]
+	data    []byte
+	mutable bool
+}
+
+func (ch *chunk) len() int {
+	if ch.mutable {
+		return len(ch.data)
+	}
+
+	return len(ch.handle.Value())
+}
+
+// copyTo copies the data of the chunk starting at off to b.
+func (ch *chunk) copyTo(b []byte, off int) int {
+	if ch.mutable {
+		return copy(b, ch.data[off:])
+	}
+
+	return copy(b, ch.handle.Value()[off:])
+}
+
+// mutableData returns the data of the chunk, which can be modified in
+// place, resized to n bytes.
+func (ch *chunk) mutableData(n int) []byte {
+	if !ch.mutable {
+		ch.data = []byte(ch.handle.Value())
+		ch.handle = unique.Handle[string]{}
+		ch.mutable = true
+	}
+
+	if n <= len(ch.data) {
+		ch.data = ch.data[:n]
+	} else {
+		ch.data = append(ch.data, make([]byte, n-len(ch.data))...)
+	}
+
+	return ch.data
+}
+
+func (ch *chunk) seal() {
+	if !ch.mutable {
+		return
+	}
+
+	ch.handle = unique.Make(string(ch.data))
+	ch.data = nil
+	ch.mutable = false
+}
+
+// content is the data and the extended attributes of a file, shared by all
+// its open handles. Every chunk but the last one holds chunkSize bytes.
+type content struct {
+	name   string
+	chunks []chunk
+	size   int64
+	xattrs map[string][]byte
+
+	m sync.RWMutex
+}
+
+func (c *content) Len() int {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return int(c.size)
+}
+
+// Bytes returns a copy of the data of the file.
+func (c *content) Bytes() []byte {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	b := make([]byte, c.size)
+	for i := range c.chunks {
+		c.chunks[i].copyTo(b[i*chunkSize:], 0)
+	}
+
+	return b
+}
+
+// Truncate changes the size of the file. Growing it fills the new space
+// with zeros.
+func (c *content) Truncate(size int64) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.resize(size)
+}
+
+// Seal interns the chunks modified since the last call, so they can be
+// shared with the identical chunks of other files.
+func (c *content) Seal() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for i := range c.chunks {
+		c.chunks[i].seal()
+	}
+}
+
+// resize changes the size of the file. The caller must hold the write lock.
+func (c *content) resize(size int64) {
+	if size == c.size {
+		return
+	}
+
+	n := int((size + chunkSize - 1) / chunkSize)
+	if size < c.size {
+		c.chunks = c.chunks[:n]
+	} else if last := len(c.chunks) - 1; last >= 0 {
+		c.chunks[last].mutableData(chunkSize)
+	}
+
+	for len(c.chunks) < n {
+		c.chunks = append(c.chunks, zeroChunk())
+	}
+
+	if n > 0 {
+		if tail := int(size - int64(n-1)*chunkSize); c.chunks[n-1].len() != tail {
+			c.chunks[n-1].mutableData(tail)
+		}
+	}
+
+	c.size = size
+}
+
+func (c *content) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &os.PathError{
+			Op:   "writeat",
+			Path: c.name,
+			Err:  errors.New("negative offset"),
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if end := off + int64(len(p)); end > c.size {
+		c.resize(end)
+	}
+
+	for n := 0; n < len(p); {
+		i, lo := int(off/chunkSize), int(off%chunkSize)
+		ch := &c.chunks[i]
+		w := copy(ch.mutableData(ch.len())[lo:], p[n:])
+
+		n += w
+		off += int64(w)
+	}
+
+	return len(p), nil
+}
+
+func (c *content) ReadAt(b []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, &os.PathError{
+			Op:   "readat",
+			Path: c.name,
+			Err:  errors.New("negative offset"),
+		}
+	}
+
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	if off >= c.size {
+		return 0, io.EOF
+	}
+
+	for n < len(b) && off < c.size {
+		i, lo := int(off/chunkSize), int(off%chunkSize)
+		r := c.chunks[i].copyTo(b[n:], lo)
+
+		n += r
+		off += int64(r)
+	}
+
+	if n < len(b) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+func (c *content) GetXattr(name string) ([]byte, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	v, ok := c.xattrs[name]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]byte(nil), v...), true
+}
+
+func (c *content) SetXattr(name string, value []byte) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.xattrs == nil {
+		c.xattrs = make(map[string][]byte)
+	}
+
+	c.xattrs[name] = append([]byte(nil), value...)
+}
+
+func (c *content) ListXattr() []string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	names := make([]string, 0, len(c.xattrs))
+	for name := range c.xattrs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (c *content) RemoveXattr(name string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if _, ok := c.xattrs[name]; !ok {
+		return false
+	}
+
+	delete(c.xattrs, name)
+	return true
+}
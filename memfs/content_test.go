@@ -0,0 +1,115 @@
+package memfs
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestContentMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	c := &content{name: "foo"}
+	var ref []byte
+
+	for i := 0; i < 500; i++ {
+		switch r.Intn(4) {
+		case 0, 1:
+			p := make([]byte, r.Intn(3*chunkSize))
+			r.Read(p)
+			off := int64(r.Intn(len(ref) + chunkSize))
+
+			_, err := c.WriteAt(p, off)
+			require.NoError(t, err)
+
+			if end := int(off) + len(p); end > len(ref) {
+				ref = append(ref, make([]byte, end-len(ref))...)
+			}
+			copy(ref[off:], p)
+		case 2:
+			size := int64(r.Intn(len(ref) + 2*chunkSize))
+			c.Truncate(size)
+
+			if int(size) < len(ref) {
+				ref = ref[:size]
+			} else {
+				ref = append(ref, make([]byte, int(size)-len(ref))...)
+			}
+		case 3:
+			c.Seal()
+		}
+
+		require.Equal(t, len(ref), c.Len())
+		require.True(t, bytes.Equal(ref, c.Bytes()), "iteration %d", i)
+	}
+
+	b := make([]byte, chunkSize+10)
+	off := int64(len(ref) - chunkSize)
+	if off < 0 {
+		off = 0
+	}
+
+	n, err := c.ReadAt(b, off)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, ref[off:], b[:n])
+}
+
+func TestContentDeduplication(t *testing.T) {
+	fs := New()
+	data := bytes.Repeat([]byte("0123456789abcdef"), chunkSize/4)
+
+	require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+	require.NoError(t, util.WriteFile(fs, "bar", data, 0o644))
+
+	m, _ := unwrap(fs)
+	foo := m.s.MustGet("/foo").content
+	bar := m.s.MustGet("/bar").content
+
+	require.Len(t, foo.chunks, 4)
+	for i := range foo.chunks {
+		assert.False(t, foo.chunks[i].mutable)
+		assert.Equal(t, foo.chunks[i].handle, bar.chunks[i].handle)
+	}
+
+	// Writing to one of the files does not change the other.
+	f, err := fs.OpenFile("foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("XXXX"), chunkSize)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	b, err := util.ReadFile(fs, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	b, err = util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "XXXX", string(b[chunkSize:chunkSize+4]))
+}
+
+func TestTruncateSharedByHandles(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foobar"), 0o644))
+
+	r, err := fs.Open("foo")
+	require.NoError(t, err)
+	defer r.Close()
+
+	w, err := fs.OpenFile("foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+	require.NoError(t, w.Truncate(3))
+	require.NoError(t, w.Close())
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	assert.ErrorIs(t, r.Truncate(1), syscall.EINVAL)
+	assert.ErrorIs(t, w.Truncate(1), os.ErrClosed)
+}
@@ -0,0 +1,165 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+)
+
+var _ billy.ReadDirFile = (*dir)(nil)
+
+// dir is a directory opened read-only, implementing billy.ReadDirFile. Its
+// entries are read through a billy.DirIter once ReadDir is first called, so
+// entries added or removed in the meantime are taken into account.
+type dir struct {
+	fs         *Memory
+	node       *file
+	name       string
+	openedPath string
+	iter       billy.DirIter
+	isClosed   bool
+}
+
+func (d *dir) Name() string {
+	return d.name
+}
+
+func (d *dir) OpenedPath() string {
+	return d.openedPath
+}
+
+// ReadDir implements the billy.ReadDirFile interface.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.isClosed {
+		return nil, d.pathError("readdir", os.ErrClosed)
+	}
+
+	if d.iter == nil {
+		iter, err := d.fs.OpenDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.iter = iter
+	}
+
+	var entries []fs.DirEntry
+	for n <= 0 || len(entries) < n {
+		e, err := d.iter.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if entries == nil {
+		entries = []fs.DirEntry{}
+	}
+	return entries, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return d.ReadAt(nil, 0)
+}
+
+func (d *dir) ReadAt([]byte, int64) (int, error) {
+	if d.isClosed {
+		return 0, d.pathError("read", os.ErrClosed)
+	}
+
+	return 0, d.pathError("read", syscall.EISDIR)
+}
+
+func (d *dir) Write([]byte) (int, error) {
+	return d.WriteAt(nil, 0)
+}
+
+func (d *dir) WriteAt([]byte, int64) (int, error) {
+	if d.isClosed {
+		return 0, d.pathError("write", os.ErrClosed)
+	}
+
+	return 0, d.pathError("write", errno.EBADF)
+}
+
+// Seek only supports seeking to the start, which restarts the listing of the
+// directory, as with os.File.
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	if d.isClosed {
+		return 0, d.pathError("seek", os.ErrClosed)
+	}
+
+	if offset != 0 || whence != io.SeekStart {
+		return 0, d.pathError("seek", syscall.EINVAL)
+	}
+
+	d.closeIter()
+	return 0, nil
+}
+
+func (d *dir) Truncate(int64) error {
+	if d.isClosed {
+		return d.pathError("truncate", os.ErrClosed)
+	}
+
+	return d.pathError("truncate", syscall.EINVAL)
+}
+
+// Sync is a no-op in memfs, as there is no stable storage to commit to.
+func (d *dir) Sync() error {
+	if d.isClosed {
+		return d.pathError("sync", os.ErrClosed)
+	}
+
+	return nil
+}
+
+// Lock is a no-op in memfs.
+func (d *dir) Lock() error {
+	return nil
+}
+
+// Unlock is a no-op in memfs.
+func (d *dir) Unlock() error {
+	return nil
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	if d.isClosed {
+		return nil, d.pathError("stat", os.ErrClosed)
+	}
+
+	return d.node.Stat()
+}
+
+func (d *dir) Close() error {
+	if d.isClosed {
+		return d.pathError("close", os.ErrClosed)
+	}
+
+	d.isClosed = true
+	d.closeIter()
+	return nil
+}
+
+func (d *dir) closeIter() {
+	if d.iter != nil {
+		_ = d.iter.Close()
+		d.iter = nil
+	}
+}
+
+// pathError returns err wrapped in an *os.PathError for the dir.
+func (d *dir) pathError(op string, err error) error {
+	return &os.PathError{Op: op, Path: d.name, Err: err}
+}
@@ -0,0 +1,256 @@
+package memfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// xattrPrefix is the prefix of the PAX records holding extended attributes,
+// as used by GNU tar and the archive/tar package.
+const xattrPrefix = "SCHILY.xattr."
+
+// ErrNotMemory is returned by Dump when the given filesystem is not backed by
+// a Memory filesystem.
+var ErrNotMemory = errors.New("not a memfs filesystem")
+
+// Dump writes the contents of fs, which must have been returned by New, Load
+// or the Chroot method of one of them, to w as a tar archive. Only the tree
+// under the root of fs is written.
+func Dump(fs billy.Filesystem, w io.Writer) error {
+	m, ok := unwrap(fs)
+	if !ok {
+		return ErrNotMemory
+	}
+
+	root := m.s.paths.Root()
+	if ch, ok := fs.(billy.Chroot); ok {
+		root = ch.Root()
+	}
+
+	_, err := m.writeTree(w, root)
+	return err
+}
+
+// unwrap returns the Memory filesystem underlying fs, going through the
+// helpers wrapping it.
+func unwrap(fs billy.Basic) (*Memory, bool) {
+	for {
+		switch v := fs.(type) {
+		case *Memory:
+			return v, true
+		case interface{ Underlying() billy.Basic }:
+			fs = v.Underlying()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// Load returns a new Memory filesystem holding the contents of the tar
+// archive read from r, as written by Dump.
+func Load(r io.Reader, opts ...Option) (billy.Filesystem, error) {
+	m := newMemory(opts...)
+	if _, err := m.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	return chroot.New(m, m.s.paths.Root(), chroot.WithNameLimits(m.nameLimits)), nil
+}
+
+// FromFS returns a new Memory filesystem holding a copy of the tree found at
+// root in src, such as a directory of the OS opened with osfs, copied by
+// util.CopyTree with copyOpts, which can filter the files copied.
+func FromFS(src billy.Filesystem, root string, copyOpts util.CopyTreeOptions, opts ...Option) (billy.Filesystem, error) {
+	fs := New(opts...)
+	if err := util.CopyTree(fs, src, root, copyOpts); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// WriteTo implements the io.WriterTo interface. The whole filesystem is
+// written to w as a tar archive, preserving the modes, owners, modification
+// times, symlinks and extended attributes of every entry. Entries modified while
+// the archive is being written may or may not have their changes included.
+func (fs *Memory) WriteTo(w io.Writer) (int64, error) {
+	return fs.writeTree(w, fs.s.paths.Root())
+}
+
+// ReadFrom implements the io.ReaderFrom interface. The entries of the tar
+// archive read from r are added to the filesystem, replacing any existing
+// file with the same path. Dirs, regular files, symlinks, devices and named
+// pipes are supported.
+func (fs *Memory) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return cr.n, nil
+		}
+		if err != nil {
+			return cr.n, err
+		}
+
+		if err := fs.load(hdr, tr); err != nil {
+			return cr.n, err
+		}
+	}
+}
+
+func (fs *Memory) writeTree(w io.Writer, root string) (int64, error) {
+	root = fs.s.paths.Clean(root)
+	paths, files := fs.s.Tree(root)
+
+	cw := &countingWriter{w: w}
+	tw := tar.NewWriter(cw)
+	for i, path := range paths {
+		if path == root {
+			continue
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(path, root), fs.s.paths.Root())
+		if err := writeEntry(tw, fs.s.paths.ToSlash(rel), files[i]); err != nil {
+			return cw.n, err
+		}
+	}
+
+	err := tw.Close()
+	return cw.n, err
+}
+
+func writeEntry(tw *tar.Writer, name string, f *file) error {
+	data := f.content.Bytes()
+
+	fi, _ := f.Stat()
+
+	var link string
+	if isSymlink(f.mode) {
+		link = f.target
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	hdr.Name = name
+	hdr.Format = tar.FormatPAX
+	hdr.Uid, hdr.Gid = f.owner()
+	if f.mode.IsDir() {
+		hdr.Name += "/"
+	}
+
+	for _, attr := range f.content.ListXattr() {
+		v, _ := f.content.GetXattr(attr)
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = make(map[string]string)
+		}
+		hdr.PAXRecords[xattrPrefix+attr] = string(v)
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+// load adds the entry described by hdr to the filesystem, reading its
+// content from r.
+func (fs *Memory) load(hdr *tar.Header, r io.Reader) error {
+	// Joining with the root before cleaning the name prevents entries from
+	// being placed outside of the filesystem.
+	path := fs.s.paths.Clean(fs.s.paths.Root() + hdr.Name)
+	if path == fs.s.paths.Root() {
+		return nil
+	}
+
+	mode := hdr.FileInfo().Mode()
+
+	var data []byte
+	var target string
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+	case tar.TypeReg:
+		var err error
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		target = hdr.Linkname
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+	default:
+		return &os.PathError{Op: "load", Path: hdr.Name, Err: billy.ErrNotSupported}
+	}
+
+	if existing, ok := fs.s.Get(path); ok && !(existing.mode.IsDir() && mode.IsDir()) {
+		if err := fs.s.Remove(path); err != nil {
+			return billy.WrapPathError("load", hdr.Name, err)
+		}
+	}
+
+	var f *file
+	var err error
+	if hdr.Typeflag == tar.TypeSymlink {
+		f, err = fs.s.NewSymlink(path, target)
+	} else {
+		f, err = fs.s.New(path, mode, 0)
+	}
+	if err != nil {
+		return billy.WrapPathError("load", hdr.Name, err)
+	}
+	if f == nil {
+		// New returns no file when the dir already exists.
+		f, _ = fs.s.Get(path)
+	}
+
+	f.chmod(mode)
+	f.setModTime(hdr.ModTime)
+	f.chown(hdr.Uid, hdr.Gid)
+	f.content.Truncate(0)
+	if _, err := f.content.WriteAt(data, 0); err != nil {
+		return err
+	}
+	f.content.Seal()
+	for key, value := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(key, xattrPrefix); ok {
+			f.content.SetXattr(name, []byte(value))
+		}
+	}
+
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,185 @@
+package memfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestDumpLoad(t *testing.T) {
+	fs := New()
+	require.NoError(t, fs.MkdirAll("dir/sub", 0o700))
+	require.NoError(t, util.WriteFile(fs, "dir/file", []byte("foo"), 0o640))
+	require.NoError(t, util.WriteFile(fs, "empty", nil, 0o600))
+	require.NoError(t, fs.Symlink("dir/file", "link"))
+	require.NoError(t, fs.(billy.Xattr).SetXattr("dir/file", "user.foo", []byte("bar")))
+	require.NoError(t, fs.(billy.Change).Lchown("link", 1000, 100))
+
+	var buf bytes.Buffer
+	require.NoError(t, Dump(fs, &buf))
+
+	loaded, err := Load(&buf)
+	require.NoError(t, err)
+
+	for _, name := range []string{"dir", "dir/sub", "dir/file", "empty", "link"} {
+		want, err := fs.Lstat(name)
+		require.NoError(t, err)
+		got, err := loaded.Lstat(name)
+		require.NoError(t, err)
+
+		assert.Equal(t, want.Mode(), got.Mode(), name)
+		assert.Equal(t, want.Size(), got.Size(), name)
+		assert.True(t, want.ModTime().Equal(got.ModTime()), name)
+		assert.Equal(t, want.Sys(), got.Sys(), name)
+	}
+
+	b, err := util.ReadFile(loaded, "link")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	target, err := loaded.Readlink("link")
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file", target)
+
+	v, err := loaded.(billy.Xattr).GetXattr("dir/file", "user.foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(v))
+}
+
+func TestDumpChroot(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "dir/file", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "other", []byte("bar"), 0o644))
+
+	sub, err := fs.Chroot("dir")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Dump(sub, &buf))
+
+	loaded, err := Load(&buf)
+	require.NoError(t, err)
+
+	names, err := loaded.(billy.DirNames).ReadDirNames("/", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file"}, names)
+}
+
+func TestFromFS(t *testing.T) {
+	src := New()
+	require.NoError(t, util.WriteFile(src, "repo/file", []byte("foo"), 0o640))
+	require.NoError(t, util.WriteFile(src, "repo/.git/HEAD", []byte("bar"), 0o644))
+
+	fs, err := FromFS(src, "repo", util.CopyTreeOptions{Exclude: []string{".git"}}, WithUmask(0o077))
+	require.NoError(t, err)
+
+	content, err := util.ReadFile(fs, "file")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	fi, err := fs.Stat("file")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	_, err = fs.Stat(".git")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDumpNotMemory(t *testing.T) {
+	err := Dump(nil, &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrNotMemory)
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	m := newMemory()
+	_, err := m.Create("/file")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	loaded := newMemory()
+	require.NoError(t, util.WriteFile(loaded, "/file", []byte("old"), 0o644))
+
+	size := buf.Len()
+	n, err = loaded.ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+
+	b, err := util.ReadFile(loaded, "/file")
+	require.NoError(t, err)
+	assert.Empty(t, b)
+}
+
+func TestLoadPathTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "../../escaped",
+		Typeflag: tar.TypeReg,
+		Mode:     0o644,
+		Size:     3,
+		ModTime:  time.Now(),
+	}))
+	_, err := tw.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	fs, err := Load(&buf)
+	require.NoError(t, err)
+
+	b, err := util.ReadFile(fs, "escaped")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+}
+
+func TestLoadUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "link",
+		Typeflag: tar.TypeLink,
+		Linkname: "file",
+		Mode:     0o644,
+	}))
+	require.NoError(t, tw.Close())
+
+	_, err := Load(&buf)
+	assert.True(t, errors.Is(err, billy.ErrNotSupported))
+
+	var pathErr *os.PathError
+	assert.ErrorAs(t, err, &pathErr)
+}
+
+func TestDumpLoadSpecialFiles(t *testing.T) {
+	fs := New()
+	require.NoError(t, Mknod(fs, "fifo", os.ModeNamedPipe|0o644))
+	require.NoError(t, Mknod(fs, "tty", os.ModeDevice|os.ModeCharDevice|0o620))
+	require.NoError(t, Mknod(fs, "sda", os.ModeDevice|0o660))
+
+	var buf bytes.Buffer
+	require.NoError(t, Dump(fs, &buf))
+
+	loaded, err := Load(&buf)
+	require.NoError(t, err)
+
+	for name, mode := range map[string]os.FileMode{
+		"fifo": os.ModeNamedPipe | 0o644,
+		"tty":  os.ModeDevice | os.ModeCharDevice | 0o620,
+		"sda":  os.ModeDevice | 0o660,
+	} {
+		fi, err := loaded.Lstat(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, mode, fi.Mode(), name)
+	}
+}
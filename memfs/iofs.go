@@ -0,0 +1,256 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// AsFS returns a view of fs as an io/fs filesystem, reading the Memory
+// filesystem underlying fs directly rather than through an adapter such as
+// helper/iofs. It returns false if fs was not returned by New, Load or the
+// Chroot method of one of them.
+//
+// The returned filesystem implements fs.ReadDirFS, fs.ReadFileFS, fs.StatFS
+// and fs.SubFS. Its files implement io.ReaderAt and io.Seeker.
+func AsFS(fs billy.Filesystem) (fs.FS, bool) {
+	m, ok := unwrap(fs)
+	if !ok {
+		return nil, false
+	}
+
+	root := m.s.paths.Root()
+	if ch, ok := fs.(billy.Chroot); ok {
+		root = ch.Root()
+	}
+
+	return &ioFS{m: m, root: root}, true
+}
+
+type ioFS struct {
+	m    *Memory
+	root string
+}
+
+var (
+	_ fs.ReadDirFS  = (*ioFS)(nil)
+	_ fs.ReadFileFS = (*ioFS)(nil)
+	_ fs.StatFS     = (*ioFS)(nil)
+	_ fs.SubFS      = (*ioFS)(nil)
+)
+
+// path returns the path of name in the Memory filesystem.
+func (f *ioFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return f.m.s.paths.Join(f.root, f.m.s.paths.FromSlash(name)), nil
+}
+
+func (f *ioFS) Open(name string) (fs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.stat(name, p)
+	if err != nil {
+		return nil, ioError("open", name, err)
+	}
+
+	if fi.IsDir() {
+		return &ioDir{fs: f, name: name, info: fi}, nil
+	}
+
+	bf, err := f.m.Open(p)
+	if err != nil {
+		return nil, ioError("open", name, err)
+	}
+
+	return &ioFile{file: bf.(*file), mode: fi.Mode()}, nil
+}
+
+func (f *ioFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.stat(name, p)
+	if err != nil {
+		return nil, ioError("stat", name, err)
+	}
+
+	return fi, nil
+}
+
+func (f *ioFS) stat(name, p string) (fs.FileInfo, error) {
+	fi, err := f.m.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// The root is named after the name it is opened with.
+	if name == "." {
+		fi.(*fileInfo).name = name
+	}
+
+	return fi, nil
+}
+
+// ReadDir returns the entries of the dir name, always sorted by name as
+// required by fs.ReadDirFS.
+func (f *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := f.m.ReadDirEntries(p)
+	if err != nil {
+		return nil, ioError("readdir", name, err)
+	}
+
+	if f.m.unsortedReadDir {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+
+	return entries, nil
+}
+
+func (f *ioFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, ok := file.(*ioDir); ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+
+	return io.ReadAll(file)
+}
+
+func (f *ioFS) Sub(dir string) (fs.FS, error) {
+	p, err := f.path("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.stat(dir, p)
+	if err != nil {
+		return nil, ioError("sub", dir, err)
+	}
+
+	if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: syscall.ENOTDIR}
+	}
+
+	return &ioFS{m: f.m, root: p}, nil
+}
+
+// ioError reports err, returned by the Memory filesystem, for the io/fs name
+// rather than for the path in the Memory filesystem.
+func ioError(op, name string, err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// ioFile is a file opened through ioFS. The handles of the Memory filesystem
+// report the permissions they were opened with, so it reports the mode of the
+// file instead.
+type ioFile struct {
+	*file
+	mode fs.FileMode
+}
+
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	fi, err := f.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	fi.(*fileInfo).mode = f.mode
+	return fi, nil
+}
+
+// ioDir is a dir opened through ioFS, implementing fs.ReadDirFile. Its
+// entries are read on the first call to ReadDir.
+type ioDir struct {
+	fs      *ioFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+	closed  bool
+}
+
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "stat", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	return d.info, nil
+}
+
+func (d *ioDir) Read([]byte) (int, error) {
+	if d.closed {
+		return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	if !d.read {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *ioDir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	d.closed = true
+	return nil
+}
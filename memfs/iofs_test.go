@@ -0,0 +1,47 @@
+package memfs
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestAsFS(t *testing.T) {
+	mfs := New(WithUnsortedReadDir())
+	require.NoError(t, util.WriteFile(mfs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(mfs, "dir/b", []byte("b"), 0o644))
+	require.NoError(t, util.WriteFile(mfs, "dir/a", []byte("a"), 0o644))
+	require.NoError(t, util.WriteFile(mfs, "dir/sub/c", []byte("c"), 0o644))
+
+	fsys, ok := AsFS(mfs)
+	require.True(t, ok)
+	require.NoError(t, fstest.TestFS(fsys, "foo", "dir/a", "dir/b", "dir/sub/c"))
+
+	content, err := fs.ReadFile(fsys, "dir/sub/c")
+	require.NoError(t, err)
+	assert.Equal(t, "c", string(content))
+
+	_, err = fsys.Open("/foo")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+	_, err = fsys.Open("missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestAsFSChroot(t *testing.T) {
+	mfs := New()
+	require.NoError(t, util.WriteFile(mfs, "dir/sub/c", []byte("c"), 0o644))
+
+	chroot, err := mfs.Chroot("dir")
+	require.NoError(t, err)
+
+	fsys, ok := AsFS(chroot)
+	require.True(t, ok)
+	require.NoError(t, fstest.TestFS(fsys, "sub/c"))
+
+	_, ok = AsFS(nil)
+	assert.False(t, ok)
+}
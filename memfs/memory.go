@@ -1,39 +1,77 @@
 // Package memfs provides a billy filesystem base on memory.
+//
+// By default the filesystem tree is safe for concurrent use by multiple
+// goroutines, with locks sharded by parent dir so that operations on
+// different dirs do not serialize on a single mutex. File handles are not
+// safe for concurrent use, except for ReadAt and WriteAt which, as with
+// *os.File, can be called concurrently on the same handle. Locking can be
+// disabled with WithoutMutex.
 package memfs // import "github.com/go-git/go-billy/v6/memfs"
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
-	"path/filepath"
 	"sort"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
 	"github.com/go-git/go-billy/v6/util"
 )
 
-const separator = filepath.Separator
-
 // Memory a very convenient filesystem based on memory files.
 type Memory struct {
-	s *storage
+	s               *storage
+	maxSymlinkDepth int
+	unsortedReadDir bool
+	umask           fs.FileMode
+	nameLimits      billy.NameLimits
+}
+
+// New returns a new Memory filesystem. It implements billy.Chroot: Chroot
+// returns views sharing its storage, nested or not, whose Root reports their
+// base within it.
+func New(opts ...Option) billy.Filesystem {
+	m := newMemory(opts...)
+	return chroot.New(m, m.s.paths.Root(), chroot.WithNameLimits(m.nameLimits))
 }
 
-// New returns a new Memory filesystem.
-func New() billy.Filesystem {
-	fs := &Memory{s: newStorage()}
-	_, err := fs.s.New("/", 0755|os.ModeDir, 0)
+func newMemory(opts ...Option) *Memory {
+	o := &options{
+		locking:         true,
+		maxSymlinkDepth: defaultMaxSymlinkDepth,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	fs := &Memory{
+		s:               newStorage(o.locking),
+		maxSymlinkDepth: o.maxSymlinkDepth,
+		unsortedReadDir: o.unsortedReadDir,
+		umask:           o.umask,
+		nameLimits:      o.nameLimits,
+	}
+	fs.s.maxDirEntries = o.maxDirEntries
+	fs.s.explicitDirs = o.explicitDirs
+	fs.s.paths = pathOps{slash: o.slashSeparator}
+	_, err := fs.s.New(fs.s.paths.Root(), 0755|os.ModeDir, 0)
 	if err != nil {
 		log.Printf("failed to create root dir: %v", err)
 	}
-	return chroot.New(fs, string(separator))
+
+	return fs
+}
+
+func (fs *Memory) String() string {
+	return "memfs"
 }
 
 func (fs *Memory) Create(filename string) (billy.File, error) {
@@ -45,83 +83,146 @@ func (fs *Memory) Open(filename string) (billy.File, error) {
 }
 
 func (fs *Memory) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	if err := openflag.Validate(flag); err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
 	f, has := fs.s.Get(filename)
 	if !has {
-		if !isCreate(flag) {
-			return nil, os.ErrNotExist
+		if !openflag.Create(flag) {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: fs.s.NotExistError(filename)}
 		}
 
+		// Symlinks can only be created through Symlink, as they need a
+		// target.
 		var err error
-		f, err = fs.s.New(filename, perm, flag)
+		f, err = fs.s.New(filename, perm&^os.ModeSymlink&^fs.umask, flag)
 		if err != nil {
-			return nil, err
+			return nil, billy.WrapPathError("open", filename, err)
 		}
 	} else {
-		if isExclusive(flag) {
-			return nil, os.ErrExist
+		if openflag.Exclusive(flag) {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
 		}
 
-		if target, isLink := fs.resolveLink(filename, f); isLink {
-			if target != filename {
-				return fs.OpenFile(target, flag, perm)
+		if isSymlink(f.mode) {
+			target, _, err := fs.follow("open", filename)
+			if err != nil {
+				return nil, err
 			}
+
+			return fs.openLink(filename, target, flag, perm)
 		}
 	}
 
 	if f.mode.IsDir() {
-		return nil, fmt.Errorf("cannot open directory: %s", filename)
+		// As with open(2), dirs can only be opened read-only.
+		if openflag.Writable(flag) || openflag.Create(flag) {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EISDIR}
+		}
+
+		return &dir{fs: fs, node: f, name: filename, openedPath: filename}, nil
+	}
+
+	if util.IsSpecial(f.mode) {
+		// Special files, created by Mknod, are placeholders with nothing
+		// behind them to read from or write to.
+		return nil, &os.PathError{Op: "open", Path: filename, Err: billy.ErrNotSupported}
 	}
 
 	return f.Duplicate(filename, perm, flag), nil
 }
 
+// openLink opens the target of the symlink link, reporting link as the path
+// the file was opened with.
+func (fs *Memory) openLink(link, target string, flag int, perm fs.FileMode) (billy.File, error) {
+	f, err := fs.OpenFile(target, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f := f.(type) {
+	case *file:
+		f.openedPath = link
+	case *dir:
+		f.openedPath = link
+	}
+	return f, nil
+}
+
 func (fs *Memory) resolveLink(fullpath string, f *file) (target string, isLink bool) {
 	if !isSymlink(f.mode) {
 		return fullpath, false
 	}
 
-	target = string(f.content.bytes)
-	if !isAbs(target) {
-		target = fs.Join(filepath.Dir(fullpath), target)
+	target = f.target
+	if !fs.s.paths.IsAbs(target) {
+		target = fs.Join(fs.s.paths.Dir(fullpath), target)
 	}
 
 	return target, true
 }
 
-// On Windows OS, IsAbs validates if a path is valid based on if stars with a
-// unit (eg.: `C:\`)  to assert that is absolute, but in this mem implementation
-// any path starting by `separator` is also considered absolute.
-func isAbs(path string) bool {
-	return filepath.IsAbs(path) || strings.HasPrefix(path, string(separator))
+// follow resolves path, following symlinks until reaching a file which is
+// not a symlink, or the max symlink depth of fs. It returns the path the
+// resolution ended on, along with its file, which is nil if it does not
+// exist.
+func (fs *Memory) follow(op, path string) (string, *file, error) {
+	current := path
+	for hops := 0; ; hops++ {
+		f, has := fs.s.Get(current)
+		if !has {
+			return current, nil, nil
+		}
+
+		target, isLink := fs.resolveLink(current, f)
+		if !isLink {
+			return current, f, nil
+		}
+
+		if hops == fs.maxSymlinkDepth {
+			return "", nil, &os.PathError{Op: op, Path: path, Err: errno.ELOOP}
+		}
+
+		current = target
+	}
 }
 
 func (fs *Memory) Stat(filename string) (os.FileInfo, error) {
-	f, has := fs.s.Get(filename)
-	if !has {
-		return nil, os.ErrNotExist
+	target, f, err := fs.follow("stat", filename)
+	if err != nil {
+		return nil, err
 	}
 
-	fi, _ := f.Stat()
-
-	var err error
-	if target, isLink := fs.resolveLink(filename, f); isLink {
-		fi, err = fs.Stat(target)
-		if err != nil {
-			return nil, err
-		}
+	if f == nil {
+		return nil, &os.PathError{Op: "stat", Path: target, Err: fs.s.NotExistError(target)}
 	}
 
+	fi, _ := f.Stat()
+
 	// the name of the file should always the name of the stated file, so we
 	// overwrite the Stat returned from the storage with it, since the
 	// filename may belong to a link.
-	fi.(*fileInfo).name = filepath.Base(filename)
+	fi.(*fileInfo).name = fs.s.paths.Base(filename)
 	return fi, nil
 }
 
+// StatMany implements the billy.BatchStater interface. Lookups are cheap in
+// memory, so the files are simply stated one at a time.
+func (fs *Memory) StatMany(paths []string) ([]os.FileInfo, []error) {
+	infos := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+	for i, path := range paths {
+		infos[i], errs[i] = fs.Stat(path)
+	}
+
+	return infos, errs
+}
+
 func (fs *Memory) Lstat(filename string) (os.FileInfo, error) {
 	f, has := fs.s.Get(filename)
 	if !has {
-		return nil, os.ErrNotExist
+		return nil, &os.PathError{Op: "lstat", Path: filename, Err: fs.s.NotExistError(filename)}
 	}
 
 	return f.Stat()
@@ -134,14 +235,13 @@ func (a ByName) Less(i, j int) bool { return a[i].Name() < a[j].Name() }
 func (a ByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
 func (fs *Memory) ReadDir(path string) ([]os.FileInfo, error) {
-	if f, has := fs.s.Get(path); has {
-		if target, isLink := fs.resolveLink(path, f); isLink {
-			if target != path {
-				return fs.ReadDir(target)
-			}
-		}
-	} else {
-		return nil, &os.PathError{Op: "open", Path: path, Err: syscall.ENOENT}
+	path, err := fs.resolveDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if f, _ := fs.s.Get(path); f.mode.IsRegular() {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
 	}
 
 	var entries []os.FileInfo
@@ -150,62 +250,397 @@ func (fs *Memory) ReadDir(path string) ([]os.FileInfo, error) {
 		entries = append(entries, fi)
 	}
 
-	sort.Sort(ByName(entries))
+	if !fs.unsortedReadDir {
+		sort.Sort(ByName(entries))
+	}
 
 	return entries, nil
 }
 
+// ReadDirNames implements the billy.DirNames interface. The names are
+// returned sorted.
+func (fs *Memory) ReadDirNames(path string, n int) ([]string, error) {
+	path, err := fs.resolveDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if f, _ := fs.s.Get(path); f.mode.IsRegular() {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	children := fs.s.Children(path)
+	names := make([]string, 0, len(children))
+	for _, f := range children {
+		names = append(names, f.Name())
+	}
+
+	sort.Strings(names)
+	if n > 0 && n < len(names) {
+		names = names[:n]
+	}
+
+	return names, nil
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (fs *Memory) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	infos, err := fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDirEntries(infos), nil
+}
+
+func toDirEntries(infos []os.FileInfo) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, fi := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(fi))
+	}
+
+	return entries
+}
+
+// OpenDir implements the billy.DirOpener interface. Only the names of the
+// entries are loaded when the directory is opened, the entries themselves are
+// looked up as the iteration advances. Entries removed during the iteration
+// are skipped.
+func (fs *Memory) OpenDir(path string) (billy.DirIter, error) {
+	names, err := fs.ReadDirNames(path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, _ := fs.resolveDir(path)
+	return &dirIter{fs: fs, dir: dir, names: names}, nil
+}
+
+type dirIter struct {
+	fs    *Memory
+	dir   string
+	names []string
+}
+
+func (it *dirIter) Next() (fs.DirEntry, error) {
+	for len(it.names) > 0 {
+		name := it.names[0]
+		it.names = it.names[1:]
+
+		f, has := it.fs.s.Get(it.fs.Join(it.dir, name))
+		if !has {
+			continue
+		}
+
+		fi, _ := f.Stat()
+		return fs.FileInfoToDirEntry(fi), nil
+	}
+
+	return nil, io.EOF
+}
+
+func (it *dirIter) Close() error {
+	it.names = nil
+	return nil
+}
+
+// resolveDir returns the path of the dir to be listed, following path if it
+// is a symlink.
+func (fs *Memory) resolveDir(path string) (string, error) {
+	target, f, err := fs.follow("open", path)
+	if err != nil {
+		return "", err
+	}
+
+	if f == nil {
+		return "", &os.PathError{Op: "open", Path: target, Err: fs.s.NotExistError(target)}
+	}
+
+	return target, nil
+}
+
+// SyncDir implements the billy.DirSyncer interface. It only checks that
+// path is a directory, as there is no stable storage to commit to.
+func (fs *Memory) SyncDir(path string) error {
+	path, err := fs.resolveDir(path)
+	if err != nil {
+		return err
+	}
+
+	if f, _ := fs.s.Get(path); !f.mode.IsDir() {
+		return &os.PathError{Op: "sync", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	return nil
+}
+
 func (fs *Memory) MkdirAll(path string, perm fs.FileMode) error {
-	_, err := fs.s.New(path, perm|os.ModeDir, 0)
-	return err
+	_, err := fs.s.New(path, perm&^fs.umask|os.ModeDir, 0)
+	return billy.WrapPathError("mkdir", path, err)
+}
+
+// Umask implements the billy.Umasker interface, returning the umask set with
+// WithUmask.
+func (fs *Memory) Umask() fs.FileMode {
+	return fs.umask
 }
 
 func (fs *Memory) TempFile(dir, prefix string) (billy.File, error) {
-	return util.TempFile(fs, dir, prefix)
+	return fs.CreateTemp(dir, prefix+"*")
+}
+
+func (fs *Memory) TempDir(dir, prefix string) (string, error) {
+	return fs.MkdirTemp(dir, prefix+"*")
+}
+
+func (fs *Memory) CreateTemp(dir, pattern string) (billy.File, error) {
+	return util.CreateTemp(fs, dir, pattern)
+}
+
+func (fs *Memory) MkdirTemp(dir, pattern string) (string, error) {
+	return util.MkdirTemp(fs, dir, pattern)
 }
 
 func (fs *Memory) Rename(from, to string) error {
-	return fs.s.Rename(from, to)
+	return fs.renameError(from, to, fs.s.Rename(from, to))
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (fs *Memory) RenameNoReplace(from, to string) error {
+	return fs.renameError(from, to, fs.s.RenameNoReplace(from, to))
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (fs *Memory) RenameExchange(from, to string) error {
+	return fs.renameError(from, to, fs.s.Exchange(from, to))
 }
 
+func (fs *Memory) renameError(from, to string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, os.ErrNotExist) {
+		err = fs.s.NotExistError(from)
+	}
+
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+}
+
+// Remove removes the named file or empty dir. Removing a dir which has
+// entries fails with an error wrapping ENOTEMPTY, and removing the root with
+// os.ErrInvalid.
 func (fs *Memory) Remove(filename string) error {
-	return fs.s.Remove(filename)
+	err := fs.s.Remove(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		err = fs.s.NotExistError(filename)
+	}
+
+	return billy.WrapPathError("remove", filename, err)
 }
 
-// Falls back to Go's filepath.Join, which works differently depending on the
-// OS where the code is being executed.
+// Join joins the path elements with the separator of the OS, as
+// filepath.Join does, or with a forward slash if the filesystem was created
+// with WithSlashSeparator.
 func (fs *Memory) Join(elem ...string) string {
-	return filepath.Join(elem...)
+	return fs.s.paths.Join(elem...)
 }
 
 func (fs *Memory) Symlink(target, link string) error {
 	_, err := fs.Lstat(link)
 	if err == nil {
-		return os.ErrExist
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrExist}
 	}
 
 	if !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
 
-	return util.WriteFile(fs, link, []byte(target), 0777|os.ModeSymlink)
+	f, err := fs.s.NewSymlink(link, target)
+	if err == nil && f == nil {
+		// New returns no file when a dir already exists at link.
+		err = os.ErrExist
+	}
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: err}
+	}
+
+	return nil
 }
 
 func (fs *Memory) Readlink(link string) (string, error) {
 	f, has := fs.s.Get(link)
 	if !has {
-		return "", os.ErrNotExist
+		return "", &os.PathError{Op: "readlink", Path: link, Err: os.ErrNotExist}
 	}
 
 	if !isSymlink(f.mode) {
-		return "", &os.PathError{
-			Op:   "readlink",
-			Path: link,
-			Err:  fmt.Errorf("not a symlink"),
-		}
+		return "", &os.PathError{Op: "readlink", Path: link, Err: syscall.EINVAL}
+	}
+
+	return f.target, nil
+}
+
+// Mmap implements the billy.Mapper interface. The mapping holds a copy of the
+// content of the file, so it is not affected by the changes made to the file
+// afterwards.
+func (fs *Memory) Mmap(path string) (billy.Mapping, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, ok := f.(*dir); ok {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: syscall.EISDIR}
 	}
 
-	return string(f.content.bytes), nil
+	return util.NewMapping(f.(*file).content.Bytes(), nil), nil
+}
+
+// GetXattr implements the billy.Xattr interface.
+func (fs *Memory) GetXattr(path, name string) ([]byte, error) {
+	f, err := fs.resolveFile("getxattr", path)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := f.content.GetXattr(name)
+	if !ok {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: billy.ErrNoXattr}
+	}
+
+	return v, nil
+}
+
+// SetXattr implements the billy.Xattr interface.
+func (fs *Memory) SetXattr(path, name string, value []byte) error {
+	f, err := fs.resolveFile("setxattr", path)
+	if err != nil {
+		return err
+	}
+
+	f.content.SetXattr(name, value)
+	return nil
+}
+
+// ListXattr implements the billy.Xattr interface.
+func (fs *Memory) ListXattr(path string) ([]string, error) {
+	f, err := fs.resolveFile("listxattr", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.content.ListXattr(), nil
+}
+
+// RemoveXattr implements the billy.Xattr interface.
+func (fs *Memory) RemoveXattr(path, name string) error {
+	f, err := fs.resolveFile("removexattr", path)
+	if err != nil {
+		return err
+	}
+
+	if !f.content.RemoveXattr(name) {
+		return &os.PathError{Op: "removexattr", Path: path, Err: billy.ErrNoXattr}
+	}
+
+	return nil
+}
+
+// chmodMask holds the mode bits which can be changed by Chmod, as in os.Chmod.
+const chmodMask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Chmod implements the billy.Change interface.
+func (fs *Memory) Chmod(name string, mode fs.FileMode) error {
+	f, err := fs.resolveFile("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	f.chmod(mode)
+	return nil
+}
+
+// Lchown implements the billy.Change interface. The owner is reported by the
+// Sys method of the FileInfo of the file, as a *billy.FileStat.
+func (fs *Memory) Lchown(name string, uid, gid int) error {
+	f, has := fs.s.Get(name)
+	if !has {
+		return &os.PathError{Op: "lchown", Path: name, Err: fs.s.NotExistError(name)}
+	}
+
+	f.chown(uid, gid)
+	return nil
+}
+
+// Lchmod implements the billy.SymlinkChange interface, changing the mode of
+// symlinks themselves, which only matters to the callers reading it back, as
+// memfs does not check permissions.
+func (fs *Memory) Lchmod(name string, mode fs.FileMode) error {
+	f, has := fs.s.Get(name)
+	if !has {
+		return &os.PathError{Op: "lchmod", Path: name, Err: fs.s.NotExistError(name)}
+	}
+
+	f.chmod(mode)
+	return nil
+}
+
+// Chown implements the billy.Change interface. The owner is reported by the
+// Sys method of the FileInfo of the file, as a *billy.FileStat.
+func (fs *Memory) Chown(name string, uid, gid int) error {
+	f, err := fs.resolveFile("chown", name)
+	if err != nil {
+		return err
+	}
+
+	f.chown(uid, gid)
+	return nil
+}
+
+// Chtimes implements the billy.Change interface. Only the modification time
+// is kept, and it is left unchanged if mtime is the zero time.
+func (fs *Memory) Chtimes(name string, _ time.Time, mtime time.Time) error {
+	f, err := fs.resolveFile("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	if !mtime.IsZero() {
+		f.setModTime(mtime)
+	}
+
+	return nil
+}
+
+// Lchtimes implements the billy.SymlinkChange interface. As Chtimes, only the
+// modification time is kept.
+func (fs *Memory) Lchtimes(name string, _ time.Time, mtime time.Time) error {
+	f, has := fs.s.Get(name)
+	if !has {
+		return &os.PathError{Op: "lchtimes", Path: name, Err: fs.s.NotExistError(name)}
+	}
+
+	if !mtime.IsZero() {
+		f.setModTime(mtime)
+	}
+
+	return nil
+}
+
+// resolveFile returns the file stored at path, following symlinks.
+func (fs *Memory) resolveFile(op, path string) (*file, error) {
+	target, f, err := fs.follow(op, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if f == nil {
+		return nil, &os.PathError{Op: op, Path: target, Err: os.ErrNotExist}
+	}
+
+	return f, nil
 }
 
 // Capabilities implements the Capable interface.
@@ -214,24 +649,108 @@ func (fs *Memory) Capabilities() billy.Capability {
 		billy.ReadCapability |
 		billy.ReadAndWriteCapability |
 		billy.SeekCapability |
-		billy.TruncateCapability
+		billy.TruncateCapability |
+		billy.SymlinkCapability |
+		billy.ChangeCapability
+}
+
+// PathProperties implements the Introspectable interface.
+func (fs *Memory) PathProperties() billy.PathProperties {
+	p := billy.DefaultPathProperties
+	p.ExplicitDirs = fs.s.explicitDirs
+	if fs.s.paths.slash {
+		p.Separator = '/'
+	}
+	return p
 }
 
 type file struct {
-	name     string
-	content  *content
+	name       string
+	openedPath string
+	paths      pathOps
+	content    *content
+	// target is the path the file points to, if it is a symlink. Symlinks
+	// have no content, like on POSIX filesystems. It is set before the
+	// file is stored, and never changed.
+	target   string
 	position int64
 	flag     int
-	mode     os.FileMode
-	modTime  time.Time
+	// mode holds the type of the file, which never changes, while the bits
+	// which Chmod can change are held in perm.
+	mode    os.FileMode
+	perm    os.FileMode
+	modTime time.Time
+	uid     int
+	gid     int
+	// node is the file stored in the filesystem, if this is one of its open
+	// handles, so that its metadata can be changed through the handle.
+	node *file
 
 	isClosed bool
+
+	// mu guards modTime, so that WriteAt can be called concurrently, name,
+	// which is changed by renames while the storage is being read, and
+	// perm, uid and gid, which are changed in place on the stored files.
+	mu sync.Mutex
+}
+
+// getMode returns the mode of the file, along with its permission bits.
+func (f *file) getMode() os.FileMode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.mode | f.perm
+}
+
+// chmod changes the bits of the mode of the file which can be changed by
+// Chmod, as in os.Chmod.
+func (f *file) chmod(mode fs.FileMode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.perm = mode & chmodMask
+}
+
+// owner returns the ids of the user and group owning the file.
+func (f *file) owner() (uid, gid int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.uid, f.gid
+}
+
+// chown changes the owner of the file, leaving the ids which are -1
+// unchanged, as os.Chown does.
+func (f *file) chown(uid, gid int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if uid != -1 {
+		f.uid = uid
+	}
+	if gid != -1 {
+		f.gid = gid
+	}
 }
 
 func (f *file) Name() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	return f.name
 }
 
+func (f *file) setName(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.name = name
+}
+
+func (f *file) OpenedPath() string {
+	return f.openedPath
+}
+
 func (f *file) Read(b []byte) (int, error) {
 	n, err := f.ReadAt(b, f.position)
 	f.position += int64(n)
@@ -245,11 +764,11 @@ func (f *file) Read(b []byte) (int, error) {
 
 func (f *file) ReadAt(b []byte, off int64) (int, error) {
 	if f.isClosed {
-		return 0, os.ErrClosed
+		return 0, f.pathError("read", os.ErrClosed)
 	}
 
-	if !isReadAndWrite(f.flag) && !isReadOnly(f.flag) {
-		return 0, errors.New("read not supported")
+	if !openflag.Readable(f.flag) {
+		return 0, f.pathError("read", errno.EBADF)
 	}
 
 	n, err := f.content.ReadAt(b, off)
@@ -259,7 +778,7 @@ func (f *file) ReadAt(b []byte, off int64) (int, error) {
 
 func (f *file) Seek(offset int64, whence int) (int64, error) {
 	if f.isClosed {
-		return 0, os.ErrClosed
+		return 0, f.pathError("seek", os.ErrClosed)
 	}
 
 	switch whence {
@@ -275,73 +794,182 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (f *file) Write(p []byte) (int, error) {
-	return f.WriteAt(p, f.position)
+	if openflag.Append(f.flag) {
+		// Writes always go to the end of the file, even if it was
+		// extended by another handle since the last write.
+		f.position = int64(f.content.Len())
+	}
+
+	n, err := f.writeAt(p, f.position)
+	f.position += int64(n)
+
+	return n, err
 }
 
 func (f *file) WriteAt(p []byte, off int64) (int, error) {
-	if f.isClosed {
-		return 0, os.ErrClosed
+	if openflag.Append(f.flag) {
+		return 0, f.pathError("writeat", openflag.ErrWriteAtInAppendMode)
 	}
 
-	if !isReadAndWrite(f.flag) && !isWriteOnly(f.flag) {
-		return 0, errors.New("write not supported")
+	return f.writeAt(p, off)
+}
+
+func (f *file) writeAt(p []byte, off int64) (int, error) {
+	if f.isClosed {
+		return 0, f.pathError("write", os.ErrClosed)
 	}
 
-	f.modTime = time.Now()
-	n, err := f.content.WriteAt(p, off)
-	f.position = off + int64(n)
+	if !openflag.Writable(f.flag) {
+		return 0, f.pathError("write", errno.EBADF)
+	}
 
-	return n, err
+	f.setModTime(time.Now())
+	return f.content.WriteAt(p, off)
 }
 
 func (f *file) Close() error {
 	if f.isClosed {
-		return os.ErrClosed
+		return f.pathError("close", os.ErrClosed)
 	}
 
 	f.isClosed = true
+	if openflag.Writable(f.flag) {
+		f.content.Seal()
+	}
+
 	return nil
 }
 
+// Truncate changes the size of the file, which is shared by all its open
+// handles. Like os.File, it fails with EINVAL if the handle is not open for
+// writing.
 func (f *file) Truncate(size int64) error {
-	if size < int64(len(f.content.bytes)) {
-		f.content.bytes = f.content.bytes[:size]
-	} else if more := int(size) - len(f.content.bytes); more > 0 {
-		f.content.bytes = append(f.content.bytes, make([]byte, more)...)
+	if f.isClosed {
+		return f.pathError("truncate", os.ErrClosed)
+	}
+
+	if size < 0 || !openflag.Writable(f.flag) {
+		return f.pathError("truncate", syscall.EINVAL)
+	}
+
+	f.setModTime(time.Now())
+	f.content.Truncate(size)
+
+	return nil
+}
+
+// Sync is a no-op in memfs, as there is no stable storage to commit to.
+func (f *file) Sync() error {
+	if f.isClosed {
+		return f.pathError("sync", os.ErrClosed)
 	}
 
 	return nil
 }
 
+// pathError returns err wrapped in an *os.PathError for the file.
+func (f *file) pathError(op string, err error) error {
+	return &os.PathError{Op: op, Path: f.Name(), Err: err}
+}
+
 func (f *file) Duplicate(filename string, mode fs.FileMode, flag int) billy.File {
+	uid, gid := f.owner()
 	nf := &file{
-		name:    filename,
-		content: f.content,
-		mode:    mode,
-		flag:    flag,
-		modTime: f.modTime,
+		name:       filename,
+		openedPath: filename,
+		paths:      f.paths,
+		content:    f.content,
+		mode:       mode &^ chmodMask,
+		perm:       mode & chmodMask,
+		flag:       flag,
+		modTime:    f.getModTime(),
+		uid:        uid,
+		gid:        gid,
+		node:       f,
 	}
-
-	if isTruncate(flag) {
-		nf.content.Truncate()
+	if f.node != nil {
+		nf.node = f.node
 	}
 
-	if isAppend(flag) {
-		nf.position = int64(nf.content.Len())
+	if openflag.Truncate(flag) {
+		nf.content.Truncate(0)
 	}
 
 	return nf
 }
 
 func (f *file) Stat() (os.FileInfo, error) {
+	size := f.content.Len()
+	if isSymlink(f.mode) {
+		// Like lstat(2), the size of a symlink is the length of its target.
+		size = len(f.target)
+	}
+
+	uid, gid := f.owner()
 	return &fileInfo{
-		name:    f.Name(),
-		mode:    f.mode,
-		size:    f.content.Len(),
-		modTime: f.modTime,
+		name:    f.paths.Base(f.Name()),
+		mode:    f.getMode(),
+		size:    size,
+		modTime: f.getModTime(),
+		sys:     &billy.FileStat{UID: uid, GID: gid, Nlink: 1},
 	}, nil
 }
 
+// Chmod implements the billy.FileChange interface, changing the mode of the
+// file as stored in the filesystem, even if it was renamed since it was
+// opened.
+func (f *file) Chmod(mode fs.FileMode) error {
+	if f.isClosed {
+		return f.pathError("chmod", os.ErrClosed)
+	}
+
+	for _, n := range f.nodes() {
+		n.chmod(mode)
+	}
+
+	return nil
+}
+
+// Chtimes implements the billy.FileChange interface. As Memory.Chtimes, only
+// the modification time is kept.
+func (f *file) Chtimes(_ time.Time, mtime time.Time) error {
+	if f.isClosed {
+		return f.pathError("chtimes", os.ErrClosed)
+	}
+
+	if !mtime.IsZero() {
+		for _, n := range f.nodes() {
+			n.setModTime(mtime)
+		}
+	}
+
+	return nil
+}
+
+func (f *file) getModTime() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.modTime
+}
+
+func (f *file) setModTime(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.modTime = t
+}
+
+// nodes returns the file along with the file stored in the filesystem, if it
+// is one of its handles.
+func (f *file) nodes() []*file {
+	if f.node == nil {
+		return []*file{f}
+	}
+
+	return []*file{f, f.node}
+}
+
 // Lock is a no-op in memfs.
 func (f *file) Lock() error {
 	return nil
@@ -357,6 +985,7 @@ type fileInfo struct {
 	size    int
 	mode    os.FileMode
 	modTime time.Time
+	sys     *billy.FileStat
 }
 
 func (fi *fileInfo) Name() string {
@@ -379,44 +1008,10 @@ func (fi *fileInfo) IsDir() bool {
 	return fi.mode.IsDir()
 }
 
-func (*fileInfo) Sys() interface{} {
-	return nil
-}
-
-func (c *content) Truncate() {
-	c.bytes = make([]byte, 0)
-}
-
-func (c *content) Len() int {
-	return len(c.bytes)
-}
-
-func isCreate(flag int) bool {
-	return flag&os.O_CREATE != 0
-}
-
-func isExclusive(flag int) bool {
-	return flag&os.O_EXCL != 0
-}
-
-func isAppend(flag int) bool {
-	return flag&os.O_APPEND != 0
-}
-
-func isTruncate(flag int) bool {
-	return flag&os.O_TRUNC != 0
-}
-
-func isReadAndWrite(flag int) bool {
-	return flag&os.O_RDWR != 0
-}
-
-func isReadOnly(flag int) bool {
-	return flag == os.O_RDONLY
-}
-
-func isWriteOnly(flag int) bool {
-	return flag&os.O_WRONLY != 0
+// Sys returns the owner of the file as a *billy.FileStat. Nlink is always 1,
+// as memfs does not support hard links.
+func (fi *fileInfo) Sys() interface{} {
+	return fi.sys
 }
 
 func isSymlink(m fs.FileMode) bool {
@@ -1,21 +1,28 @@
 package memfs
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
 	"github.com/go-git/go-billy/v6/util"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
-var _ fs.File = &file{}
+var _ billy.File = &file{}
 
 func TestRootExists(t *testing.T) {
 	fs := New()
@@ -29,8 +36,43 @@ func TestCapabilities(t *testing.T) {
 	_, ok := fs.(billy.Capable)
 	assert.True(t, ok)
 
+	// The chroot returned by New has a RemoveAll of its own.
 	caps := billy.Capabilities(fs)
-	assert.Equal(t, billy.DefaultCapabilities&^billy.LockCapability, caps)
+	assert.Equal(t, billy.AllCapabilities&^billy.LockCapability, caps)
+}
+
+func TestPathProperties(t *testing.T) {
+	fs := New()
+	assert.Equal(t, billy.DefaultPathProperties, billy.Introspect(fs))
+}
+
+func TestWithSlashSeparator(t *testing.T) {
+	fs := New(WithSlashSeparator())
+	assert.Equal(t, byte('/'), billy.Introspect(fs).Separator)
+
+	// Backslashes are part of the names on every OS.
+	require.NoError(t, util.WriteFile(fs, "dir/a\\b", []byte("foo"), 0o644))
+
+	names, err := util.ReadDirNames(fs, "dir", -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\\b"}, names)
+
+	fi, err := fs.Stat("dir/a\\b")
+	require.NoError(t, err)
+	assert.Equal(t, "a\\b", fi.Name())
+
+	f, err := fs.Open(fs.Join("dir", "a\\b"))
+	require.NoError(t, err)
+	assert.Equal(t, "dir/a\\b", f.Name())
+	require.NoError(t, f.Close())
+
+	var buf bytes.Buffer
+	require.NoError(t, Dump(fs, &buf))
+	loaded, err := Load(&buf, WithSlashSeparator())
+	require.NoError(t, err)
+	data, err := util.ReadFile(loaded, "dir/a\\b")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(data))
 }
 
 func TestModTime(t *testing.T) {
@@ -120,6 +162,208 @@ func TestOrder(t *testing.T) {
 	}
 }
 
+func TestReadDirNames(t *testing.T) {
+	fs := New()
+	for _, name := range []string{"c", "a", "b"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+	require.NoError(t, fs.Symlink("dir", "link"))
+
+	names, err := fs.(billy.DirNames).ReadDirNames("dir", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	names, err = fs.(billy.DirNames).ReadDirNames("link", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+func TestReadDirEntries(t *testing.T) {
+	fs := New()
+	for _, name := range []string{"c", "a", "b"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+	require.NoError(t, fs.MkdirAll(fs.Join("dir", "d"), 0o755))
+	require.NoError(t, fs.Symlink("dir", "link"))
+
+	entries, err := fs.(billy.DirEntries).ReadDirEntries("link")
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, name, entries[i].Name())
+		assert.Equal(t, name == "d", entries[i].IsDir())
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	fs := New()
+	for _, name := range []string{"c", "a", "b"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+
+	it, err := fs.(billy.DirOpener).OpenDir("dir")
+	require.NoError(t, err)
+	defer it.Close()
+
+	e, err := it.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "a", e.Name())
+
+	require.NoError(t, fs.Remove(fs.Join("dir", "b")))
+
+	e, err = it.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "c", e.Name())
+	assert.False(t, e.IsDir())
+
+	_, err = it.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestOpenDirHandle(t *testing.T) {
+	fs := New()
+	for _, name := range []string{"c", "a", "b"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+	require.NoError(t, fs.Symlink("dir", "link"))
+
+	for _, path := range []string{"dir", "link"} {
+		f, err := fs.Open(path)
+		require.NoError(t, err)
+		assert.Equal(t, path, f.OpenedPath())
+
+		d, ok := f.(billy.ReadDirFile)
+		require.True(t, ok)
+
+		fi, err := d.Stat()
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		_, err = d.Read(make([]byte, 1))
+		require.ErrorIs(t, err, syscall.EISDIR)
+
+		entries, err := d.ReadDir(2)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "a", entries[0].Name())
+		assert.Equal(t, "b", entries[1].Name())
+
+		entries, err = d.ReadDir(0)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "c", entries[0].Name())
+
+		_, err = d.ReadDir(1)
+		require.ErrorIs(t, err, io.EOF)
+
+		// Seeking to the start restarts the listing.
+		_, err = d.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		entries, err = d.ReadDir(-1)
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+
+		require.NoError(t, d.Close())
+		_, err = d.ReadDir(0)
+		require.ErrorIs(t, err, os.ErrClosed)
+	}
+
+	for _, flag := range []int{os.O_RDWR, os.O_WRONLY, os.O_RDONLY | os.O_CREATE} {
+		_, err := fs.OpenFile("dir", flag, 0)
+		require.ErrorIs(t, err, syscall.EISDIR)
+	}
+}
+
+func TestXattr(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	require.NoError(t, fs.Symlink("foo", "link"))
+
+	x := fs.(billy.Xattr)
+	_, err := x.GetXattr("foo", "user.checksum")
+	assert.ErrorIs(t, err, billy.ErrNoXattr)
+
+	require.NoError(t, x.SetXattr("foo", "user.checksum", []byte("abc")))
+	require.NoError(t, x.SetXattr("link", "user.origin", []byte("def")))
+
+	v, err := x.GetXattr("link", "user.checksum")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), v)
+
+	names, err := x.ListXattr("foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user.checksum", "user.origin"}, names)
+
+	require.NoError(t, x.RemoveXattr("foo", "user.checksum"))
+	assert.ErrorIs(t, x.RemoveXattr("foo", "user.checksum"), billy.ErrNoXattr)
+
+	_, err = x.ListXattr("missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestChown(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	require.NoError(t, fs.Symlink("foo", "link"))
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	st, ok := fi.Sys().(*billy.FileStat)
+	require.True(t, ok)
+	assert.Equal(t, max(os.Getuid(), 0), st.UID)
+	assert.Equal(t, max(os.Getgid(), 0), st.GID)
+	assert.Equal(t, uint64(1), st.Nlink)
+
+	c := fs.(billy.Change)
+	require.NoError(t, c.Chown("link", 1000, 100))
+	require.NoError(t, c.Lchown("link", 2000, -1))
+
+	fi, err = fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, &billy.FileStat{UID: 1000, GID: 100, Nlink: 1}, fi.Sys())
+
+	fi, err = fs.Lstat("link")
+	require.NoError(t, err)
+	assert.Equal(t, 2000, fi.Sys().(*billy.FileStat).UID)
+	assert.Equal(t, max(os.Getgid(), 0), fi.Sys().(*billy.FileStat).GID)
+
+	f, err := fs.Open("foo")
+	require.NoError(t, err)
+	defer f.Close()
+	fi, err = f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, 1000, fi.Sys().(*billy.FileStat).UID)
+
+	assert.ErrorIs(t, c.Chown("missing", 0, 0), os.ErrNotExist)
+	assert.ErrorIs(t, c.Lchown("missing", 0, 0), os.ErrNotExist)
+}
+
+func TestChmodChtimes(t *testing.T) {
+	fs := New()
+	require.NoError(t, fs.MkdirAll("dir", 0o755))
+	require.NoError(t, fs.Symlink("dir", "link"))
+
+	c := fs.(billy.Change)
+	require.NoError(t, c.Chmod("link", 0o700|os.ModeSticky))
+
+	fi, err := fs.Stat("dir")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeDir|os.ModeSticky|0o700, fi.Mode())
+
+	fi, err = fs.Lstat("link")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, fi.Mode().Type())
+
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, c.Chtimes("dir", time.Time{}, mtime))
+	fi, err = fs.Stat("dir")
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(fi.ModTime()))
+
+	assert.ErrorIs(t, c.Chmod("missing", 0o644), os.ErrNotExist)
+	assert.ErrorIs(t, c.Chtimes("missing", mtime, mtime), os.ErrNotExist)
+}
+
 func TestNotFound(t *testing.T) {
 	fs := New()
 	files, err := fs.ReadDir("asdf")
@@ -181,33 +425,51 @@ func TestReadlink(t *testing.T) {
 		},
 	}
 
-	// Cater for memfs not being os-agnostic.
-	if runtime.GOOS == "windows" {
-		tests[1].want = "\\self"
-		tests[2].want = "\\foo"
-		tests[3].want = "\\c:\\test\\123"
+	// Cater for memfs not being os-agnostic, unless created with
+	// WithSlashSeparator.
+	windows := map[int]string{
+		1: "\\self",
+		2: "\\foo",
+		3: "\\c:\\test\\123",
 	}
 
-	fs := New()
+	eachSeparator(t, func(t *testing.T, fs billy.Filesystem, slash bool) {
+		// arrange fs for tests.
+		require.NoError(t, fs.Symlink("/self", "/self"))
+		require.NoError(t, fs.Symlink("/foo", "/bar"))
+		require.NoError(t, fs.Symlink("c:\\test\\123", "/win"))
+		require.NoError(t, fs.Symlink("\\test\\123", "/net"))
+
+		for i, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				got, err := fs.Readlink(tc.link)
 
-	// arrange fs for tests.
-	require.NoError(t, fs.Symlink("/self", "/self"))
-	require.NoError(t, fs.Symlink("/foo", "/bar"))
-	require.NoError(t, fs.Symlink("c:\\test\\123", "/win"))
-	require.NoError(t, fs.Symlink("\\test\\123", "/net"))
+				if tc.wantErr == nil {
+					require.NoError(t, err)
+					assert.Equal(t, osWant(tc.want, windows[i], slash), got)
+				} else {
+					assert.ErrorIs(t, err, *tc.wantErr)
+				}
+			})
+		}
+	})
+}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			got, err := fs.Readlink(tc.link)
+// eachSeparator runs fn against a new filesystem using the separator of the
+// OS, and another created with WithSlashSeparator.
+func eachSeparator(t *testing.T, fn func(t *testing.T, fs billy.Filesystem, slash bool)) {
+	t.Run("os", func(t *testing.T) { fn(t, New(), false) })
+	t.Run("slash", func(t *testing.T) { fn(t, New(WithSlashSeparator()), true) })
+}
 
-			if tc.wantErr == nil {
-				require.NoError(t, err)
-				assert.Equal(t, tc.want, got)
-			} else {
-				assert.ErrorIs(t, err, *tc.wantErr)
-			}
-		})
+// osWant returns windows, if not empty, when running on Windows with the
+// separator of the OS, and want otherwise.
+func osWant(want, windows string, slash bool) string {
+	if windows != "" && !slash && runtime.GOOS == "windows" {
+		return windows
 	}
+
+	return want
 }
 
 func TestSymlink2(t *testing.T) {
@@ -275,36 +537,37 @@ func TestSymlink2(t *testing.T) {
 		},
 	}
 
-	// Cater for memfs not being os-agnostic.
-	if runtime.GOOS == "windows" {
-		tests[0].want = "\\bar"
-		tests[1].want = "\\self"
-		tests[2].want = "\\file"
-		tests[3].want = "\\dir"
-		tests[4].want = "\\c:\\foor\\bar"
+	// Cater for memfs not being os-agnostic, unless created with
+	// WithSlashSeparator.
+	windows := map[int]string{
+		0: "\\bar",
+		1: "\\self",
+		2: "\\file",
+		3: "\\dir",
+		4: "\\c:\\foor\\bar",
 	}
 
-	fs := New()
-
-	// arrange fs for tests.
-	err := fs.MkdirAll("/dir", 0o600)
-	require.NoError(t, err)
-	_, err = fs.Create("/file")
-	require.NoError(t, err)
+	eachSeparator(t, func(t *testing.T, fs billy.Filesystem, slash bool) {
+		// arrange fs for tests.
+		err := fs.MkdirAll("/dir", 0o600)
+		require.NoError(t, err)
+		_, err = fs.Create("/file")
+		require.NoError(t, err)
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			err := fs.Symlink(tc.target, tc.link)
+		for i, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				err := fs.Symlink(tc.target, tc.link)
 
-			if tc.wantErr == "" {
-				got, err := fs.Readlink(tc.link)
-				require.NoError(t, err)
-				assert.Equal(t, tc.want, got)
-			} else {
-				assert.ErrorContains(t, err, tc.wantErr)
-			}
-		})
-	}
+				if tc.wantErr == "" {
+					got, err := fs.Readlink(tc.link)
+					require.NoError(t, err)
+					assert.Equal(t, osWant(tc.want, windows[i], slash), got)
+				} else {
+					assert.ErrorContains(t, err, tc.wantErr)
+				}
+			})
+		}
+	})
 }
 
 func TestJoin(t *testing.T) {
@@ -324,24 +587,27 @@ func TestJoin(t *testing.T) {
 		{name: "\\ abs", elem: []string{"/\\\\", "a", "b", "c"}, want: "/\\\\/a/b/c"},
 	}
 
-	// Cater for memfs not being os-agnostic.
-	if runtime.GOOS == "windows" {
-		tests[1].want = "C:."
-		tests[2].want = "a\\b\\c"
-		tests[3].want = "\\a\\b\\c"
-		tests[4].want = "\\a\\b\\c"
-		tests[5].want = "C:\\a\\b\\c"
-		tests[6].want = "\\C:\\a\\b\\c"
-		tests[7].want = "\\\\a\\b\\c"
-		tests[8].want = "\\\\\\a\\b\\c"
+	// Cater for memfs not being os-agnostic, unless created with
+	// WithSlashSeparator.
+	windows := map[int]string{
+		1: "C:.",
+		2: "a\\b\\c",
+		3: "\\a\\b\\c",
+		4: "\\a\\b\\c",
+		5: "C:\\a\\b\\c",
+		6: "\\C:\\a\\b\\c",
+		7: "\\\\a\\b\\c",
+		8: "\\\\\\a\\b\\c",
 	}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			got := New().Join(tc.elem...)
-			assert.Equal(t, tc.want, got)
-		})
-	}
+	eachSeparator(t, func(t *testing.T, fs billy.Filesystem, slash bool) {
+		for i, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				got := fs.Join(tc.elem...)
+				assert.Equal(t, osWant(tc.want, windows[i], slash), got)
+			})
+		}
+	})
 }
 
 func TestSymlink(t *testing.T) {
@@ -349,11 +615,623 @@ func TestSymlink(t *testing.T) {
 	err := fs.Symlink("test", "test")
 	require.NoError(t, err)
 
-	f, err := fs.Open("test")
+	_, err = fs.Open("test")
+	assert.ErrorIs(t, err, errno.ELOOP)
+
+	_, err = fs.ReadDir("test")
+	assert.ErrorIs(t, err, errno.ELOOP)
+}
+
+func TestRenameTree(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "foo/bar/qux", []byte("qux"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "foobar/baz", []byte("baz"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "foo.txt", []byte("txt"), 0o644))
+
+	// Only the entries under the renamed dir move, not the ones sharing
+	// its name as a prefix.
+	require.NoError(t, fs.Rename("foo", "moved/foo"))
+
+	for name, want := range map[string]string{
+		"moved/foo/bar/qux": "qux",
+		"foobar/baz":        "baz",
+		"foo.txt":           "txt",
+	} {
+		data, err := util.ReadFile(fs, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, string(data), name)
+	}
+
+	_, err := fs.Stat("foo/bar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	names, err := util.ReadDirNames(fs, "moved/foo", -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bar"}, names)
+
+	names, err = util.ReadDirNames(fs, "/", -1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"foobar", "foo.txt", "moved"}, names)
+
+	// The moved dir keeps working as a dir.
+	require.NoError(t, util.WriteFile(fs, "moved/foo/bar/new", nil, 0o644))
+	require.NoError(t, fs.Remove("moved/foo/bar/qux"))
+	require.NoError(t, fs.Remove("moved/foo/bar/new"))
+	require.NoError(t, fs.Remove("moved/foo/bar"))
+}
+
+func TestRenameExisting(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "file", []byte("file"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "other", []byte("other"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "dir/foo", nil, 0o644))
+	require.NoError(t, fs.MkdirAll("empty", 0o755))
+
+	for _, tc := range []struct {
+		from, to string
+		err      error
+	}{
+		// Like os.Rename, an existing dir is never replaced, even if empty.
+		{"file", "dir", syscall.EEXIST},
+		{"file", "empty", syscall.EEXIST},
+		{"dir", "empty", syscall.EEXIST},
+		{"empty", "dir", syscall.EEXIST},
+		{"dir", "dir", syscall.EEXIST},
+		// A dir cannot replace a file.
+		{"dir", "file", syscall.ENOTDIR},
+		{"empty", "file", syscall.ENOTDIR},
+		{"file", "other/foo", syscall.ENOTDIR},
+		{"dir", "dir/sub", syscall.EINVAL},
+	} {
+		err := fs.Rename(tc.from, tc.to)
+		require.ErrorIs(t, err, tc.err, "%s to %s", tc.from, tc.to)
+		var lerr *os.LinkError
+		require.ErrorAs(t, err, &lerr)
+		assert.Equal(t, "rename", lerr.Op)
+	}
+
+	names, err := util.ReadDirNames(fs, "/", -1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"dir", "empty", "file", "other"}, names)
+
+	// A file replaces another file.
+	require.NoError(t, fs.Rename("file", "other"))
+	data, err := util.ReadFile(fs, "other")
+	require.NoError(t, err)
+	assert.Equal(t, "file", string(data))
+
+	// Once the target dir is removed, a dir takes its place.
+	require.NoError(t, fs.Remove("empty"))
+	require.NoError(t, fs.Rename("dir", "empty"))
+	_, err = fs.Stat("empty/foo")
+	require.NoError(t, err)
+}
+
+func TestRenameExchangeTree(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "a/foo/bar", []byte("bar"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "b/qux", []byte("qux"), 0o644))
+
+	require.NoError(t, fs.(billy.Renamer).RenameExchange("a", "b"))
+
+	data, err := util.ReadFile(fs, "b/foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+
+	data, err = util.ReadFile(fs, "a/qux")
+	require.NoError(t, err)
+	assert.Equal(t, "qux", string(data))
+
+	names, err := util.ReadDirNames(fs, "/", -1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, names)
+
+	err = fs.(billy.Renamer).RenameExchange("a", "a/qux")
+	assert.ErrorIs(t, err, syscall.EINVAL)
+}
+
+func TestSymlinkLstat(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "file", []byte("hello world!"), 0o644))
+	require.NoError(t, fs.Symlink("file", "link"))
+	require.NoError(t, fs.Symlink("missing/target", "dangling"))
+
+	fi, err := fs.Lstat("link")
+	require.NoError(t, err)
+	assert.Equal(t, 0777|os.ModeSymlink, fi.Mode())
+	assert.Equal(t, int64(len("file")), fi.Size())
+
+	fi, err = fs.Stat("link")
 	require.NoError(t, err)
-	assert.NotNil(t, f)
+	assert.Equal(t, os.FileMode(0o644), fi.Mode())
+	assert.Equal(t, int64(12), fi.Size())
 
-	fi, err := fs.ReadDir("test")
+	fi, err = fs.Lstat("dangling")
 	require.NoError(t, err)
-	assert.Nil(t, fi)
+	assert.Equal(t, 0777|os.ModeSymlink, fi.Mode())
+	assert.Equal(t, int64(len("missing/target")), fi.Size())
+
+	_, err = fs.Stat("dangling")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	target, err := fs.Readlink("dangling")
+	require.NoError(t, err)
+	assert.Equal(t, "missing/target", target)
+}
+
+func TestOpenFileModeSymlink(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "file", []byte("foo"), 0o777|os.ModeSymlink))
+
+	fi, err := fs.Lstat("file")
+	require.NoError(t, err)
+	assert.True(t, fi.Mode().IsRegular())
+
+	_, err = fs.Readlink("file")
+	assert.ErrorIs(t, err, syscall.EINVAL)
+}
+
+func TestWithMaxSymlinkDepth(t *testing.T) {
+	fs := New(WithMaxSymlinkDepth(2))
+	require.NoError(t, util.WriteFile(fs, "file", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("file", "link1"))
+	require.NoError(t, fs.Symlink("link1", "link2"))
+	require.NoError(t, fs.Symlink("link2", "link3"))
+
+	_, err := fs.Stat("link2")
+	require.NoError(t, err)
+
+	_, err = fs.Stat("link3")
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "link3", filepath.Base(perr.Path))
+	assert.ErrorIs(t, err, errno.ELOOP)
+
+	_, err = fs.Open("link3")
+	assert.ErrorIs(t, err, errno.ELOOP)
+
+	_, err = fs.Lstat("link3")
+	assert.NoError(t, err)
+}
+
+func TestWithUnsortedReadDir(t *testing.T) {
+	fs := New(WithUnsortedReadDir())
+	names := []string{"qux", "foo", "bar", "baz"}
+	for _, name := range names {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+
+	infos, err := fs.ReadDir("dir")
+	require.NoError(t, err)
+
+	got := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		got = append(got, fi.Name())
+	}
+	assert.ElementsMatch(t, names, got)
+}
+
+func TestWithMaxDirEntries(t *testing.T) {
+	fs := New(WithMaxDirEntries(2))
+	require.NoError(t, util.WriteFile(fs, "dir/foo", nil, 0o644))
+	require.NoError(t, fs.MkdirAll("dir/bar", 0o755))
+
+	_, err := fs.Create("dir/qux")
+	require.ErrorIs(t, err, errno.ENOSPC)
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+
+	require.ErrorIs(t, fs.Symlink("foo", "dir/qux"), errno.ENOSPC)
+	require.ErrorIs(t, fs.MkdirAll("dir/qux/baz", 0o755), errno.ENOSPC)
+
+	f, err := fs.Create("dir/foo")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, fs.Rename("dir/foo", "dir/qux"))
+
+	require.NoError(t, util.WriteFile(fs, "qux", nil, 0o644))
+	require.ErrorIs(t, fs.Rename("qux", "dir/baz"), errno.ENOSPC)
+
+	require.NoError(t, fs.Remove("dir/qux"))
+	require.NoError(t, fs.Rename("qux", "dir/baz"))
+}
+
+func TestWithUmask(t *testing.T) {
+	fs := New(WithUmask(0o022))
+	require.NoError(t, util.WriteFile(fs, "dir/foo", nil, 0o666))
+	require.NoError(t, fs.MkdirAll("qux", 0o777))
+
+	for name, perm := range map[string]os.FileMode{
+		"dir":     0o644,
+		"dir/foo": 0o644,
+		"qux":     0o755,
+	} {
+		fi, err := fs.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, perm, fi.Mode().Perm(), name)
+	}
+
+	require.NoError(t, fs.(billy.Change).Chmod("dir/foo", 0o777))
+	fi, err := fs.Stat("dir/foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o777), fi.Mode().Perm())
+
+	mask, ok := util.Umask(fs)
+	assert.True(t, ok)
+	assert.Equal(t, os.FileMode(0o022), mask)
+}
+
+func TestFileChange(t *testing.T) {
+	fs := New()
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+
+	// The file is changed through the handle even once renamed.
+	require.NoError(t, fs.Rename("foo", "bar"))
+
+	fc, ok := f.(billy.FileChange)
+	require.True(t, ok)
+	require.NoError(t, fc.Chmod(0o600))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, fc.Chtimes(time.Time{}, mtime))
+
+	for _, stat := range []func() (os.FileInfo, error){
+		f.Stat,
+		func() (os.FileInfo, error) { return fs.Stat("bar") },
+	} {
+		fi, err := stat()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+		assert.True(t, mtime.Equal(fi.ModTime()))
+	}
+
+	require.NoError(t, f.Close())
+	require.ErrorIs(t, fc.Chmod(0o644), os.ErrClosed)
+	require.ErrorIs(t, fc.Chtimes(mtime, mtime), os.ErrClosed)
+}
+
+func TestMmap(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("foo", "link"))
+
+	m, err := util.Mmap(fs, "link")
+	require.NoError(t, err)
+	defer m.Close()
+
+	// The mapping holds a copy, unaffected by later writes.
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("bar"), 0o644))
+	assert.Equal(t, []byte("foo"), m.Bytes())
+
+	require.NoError(t, fs.MkdirAll("dir", 0o755))
+	_, err = util.Mmap(fs, "dir")
+	require.ErrorIs(t, err, syscall.EISDIR)
+}
+
+func TestRemoveDir(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "dir/sub/foo", nil, 0o644))
+
+	for _, path := range []string{"dir", "dir/sub"} {
+		err := fs.Remove(path)
+		require.ErrorIs(t, err, errno.ENOTEMPTY)
+		var perr *os.PathError
+		require.ErrorAs(t, err, &perr)
+		assert.Equal(t, "remove", perr.Op)
+	}
+
+	require.NoError(t, fs.Rename("dir/sub", "moved"))
+	require.NoError(t, fs.Remove("dir"))
+	require.ErrorIs(t, fs.Remove("moved"), errno.ENOTEMPTY)
+	require.NoError(t, fs.Remove("moved/foo"))
+	require.NoError(t, fs.Remove("moved"))
+}
+
+func TestRemoveRoot(t *testing.T) {
+	fs := New()
+	require.ErrorIs(t, fs.Remove("/"), os.ErrInvalid)
+
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	require.ErrorIs(t, fs.Remove("/"), os.ErrInvalid)
+
+	fi, err := fs.Stat("/")
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+}
+
+func TestConcurrentCreateRemove(t *testing.T) {
+	fs := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dir := fmt.Sprintf("dir%d", i%4)
+			for j := 0; j < 50; j++ {
+				name := fs.Join(dir, fmt.Sprintf("file-%d-%d", i, j))
+				assert.NoError(t, util.WriteFile(fs, name, []byte("foo"), 0o644))
+				_, err := fs.Stat(name)
+				assert.NoError(t, err)
+				_, err = fs.ReadDir(dir)
+				assert.NoError(t, err)
+				assert.NoError(t, fs.Remove(name))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		entries, err := fs.ReadDir(fmt.Sprintf("dir%d", i))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	}
+}
+
+func TestConcurrentTempFile(t *testing.T) {
+	fs := New()
+	require.NoError(t, fs.MkdirAll("tmp", 0o755))
+
+	var mu sync.Mutex
+	names := map[string]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				f, err := fs.TempFile("tmp", "foo")
+				if !assert.NoError(t, err) {
+					return
+				}
+				dir, err := fs.TempDir("tmp", "bar")
+				assert.NoError(t, err)
+				assert.NoError(t, f.Close())
+
+				mu.Lock()
+				names[f.Name()] = true
+				names[dir] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := fs.ReadDir("tmp")
+	require.NoError(t, err)
+	assert.Len(t, entries, 8*50*2)
+	assert.Len(t, names, 8*50*2)
+}
+
+// TestConcurrentMetadata checks, when run with -race, that the metadata of
+// the files can be changed while being read through the lock-free snapshots.
+func TestConcurrentMetadata(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "file", nil, 0o644))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			link := fmt.Sprintf("link%d", i)
+			assert.NoError(t, fs.Symlink("file", link))
+			assert.NoError(t, fs.(billy.Change).Chmod("file", os.FileMode(0o600+i%2)))
+			assert.NoError(t, fs.(billy.Change).Chown("file", i, i))
+			assert.NoError(t, fs.(billy.SymlinkChange).Lchmod(link, 0o700))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			link := fmt.Sprintf("link%d", i)
+			if fi, err := fs.Stat(link); err == nil {
+				assert.True(t, fi.Mode().IsRegular())
+			}
+			_, _ = fs.Lstat(link)
+			_, _ = fs.Readlink(link)
+			_, err := fs.Stat("file")
+			assert.NoError(t, err)
+		}
+	}()
+	wg.Wait()
+
+	fi, err := fs.Lstat("link99")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink|0o700, fi.Mode())
+}
+
+func TestConcurrentStatDuringRename(t *testing.T) {
+	fs := New()
+	require.NoError(t, util.WriteFile(fs, "a/foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.MkdirAll("b", 0o755))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		from, to := "a/foo", "b/foo"
+		for i := 0; i < 500; i++ {
+			assert.NoError(t, fs.Rename(from, to))
+			from, to = to, from
+		}
+	}()
+
+	// The file is moved between shards, yet it is always found in exactly
+	// one of the dirs, as a rename is published at once.
+	for {
+		select {
+		case <-done:
+			wg.Wait()
+			return
+		default:
+		}
+
+		_, errA := fs.Lstat("a/foo")
+		_, errB := fs.Lstat("b/foo")
+		require.True(t, (errA == nil) != (errB == nil), "a: %v, b: %v", errA, errB)
+		runtime.Gosched()
+	}
+}
+
+func TestWithoutMutex(t *testing.T) {
+	fs := New(WithoutMutex())
+	require.NoError(t, util.WriteFile(fs, "foo/bar", []byte("foo"), 0o644))
+
+	entries, err := fs.ReadDir("foo")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func BenchmarkConcurrentCreate(b *testing.B) {
+	fs := New(WithMutex())
+	var dirs atomic.Int64
+
+	b.RunParallel(func(pb *testing.PB) {
+		dir := fmt.Sprintf("dir%d", dirs.Add(1))
+		i := 0
+		for pb.Next() {
+			f, err := fs.Create(fs.Join(dir, strconv.Itoa(i)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = f.Close()
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelStat(b *testing.B) {
+	fs := New(WithMutex())
+	for i := 0; i < 100; i++ {
+		if err := util.WriteFile(fs, fs.Join("dir", strconv.Itoa(i)), nil, 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := fs.Stat(fs.Join("dir", strconv.Itoa(i%100))); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelReadDir(b *testing.B) {
+	fs := New(WithMutex())
+	for i := 0; i < 100; i++ {
+		if err := util.WriteFile(fs, fs.Join("dir", strconv.Itoa(i)), nil, 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := fs.ReadDir("dir"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestSymlinkOpenedPath(t *testing.T) {
+	fs := newMemory()
+	require.NoError(t, util.WriteFile(fs, "/target", nil, 0o644))
+	require.NoError(t, fs.Symlink("target", "/link"))
+
+	f, err := fs.Open("/link")
+	require.NoError(t, err)
+	assert.Equal(t, "/link", f.OpenedPath())
+	require.NoError(t, f.Close())
+}
+
+func TestMknod(t *testing.T) {
+	fs := New()
+	require.NoError(t, fs.MkdirAll("dev", 0o755))
+	require.NoError(t, Mknod(fs, "dev/null", os.ModeDevice|os.ModeCharDevice|0o666))
+	require.NoError(t, Mknod(fs, "fifo", os.ModeNamedPipe|0o644))
+	require.NoError(t, Mknod(fs, "sock", os.ModeSocket|0o755))
+
+	fi, err := fs.Stat("dev/null")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeDevice|os.ModeCharDevice|0o666, fi.Mode())
+	assert.True(t, util.IsSpecial(fi.Mode()))
+
+	fi, err = fs.Lstat("sock")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSocket|0o755, fi.Mode())
+
+	infos, err := fs.ReadDir("/")
+	require.NoError(t, err)
+	modes := map[string]os.FileMode{}
+	for _, fi := range infos {
+		modes[fi.Name()] = fi.Mode().Type()
+	}
+	assert.Equal(t, map[string]os.FileMode{
+		"dev":  os.ModeDir,
+		"fifo": os.ModeNamedPipe,
+		"sock": os.ModeSocket,
+	}, modes)
+
+	_, err = fs.Open("fifo")
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+	_, err = fs.OpenFile("dev/null", os.O_RDWR|os.O_CREATE, 0o666)
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+
+	assert.ErrorIs(t, Mknod(fs, "fifo", os.ModeNamedPipe|0o644), os.ErrExist)
+	assert.ErrorIs(t, Mknod(fs, "file", 0o644), os.ErrInvalid)
+	assert.ErrorIs(t, Mknod(fs, "dir", os.ModeDir|0o755), os.ErrInvalid)
+}
+
+func TestMknodChroot(t *testing.T) {
+	fs := New()
+	require.NoError(t, fs.MkdirAll("foo", 0o755))
+	chrooted, err := fs.Chroot("foo")
+	require.NoError(t, err)
+
+	require.NoError(t, Mknod(chrooted, "../fifo", os.ModeNamedPipe|0o644))
+
+	fi, err := fs.Lstat("foo/fifo")
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeNamedPipe|0o644, fi.Mode())
+
+	assert.Equal(t, ErrNotMemory, Mknod(nil, "fifo", os.ModeNamedPipe))
+}
+
+func TestChrootRoot(t *testing.T) {
+	fs := New()
+	assert.Equal(t, string(filepath.Separator), fs.(billy.Chroot).Root())
+
+	foo, err := fs.Chroot("foo")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(string(filepath.Separator), "foo"), foo.(billy.Chroot).Root())
+
+	bar, err := foo.Chroot("/bar")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(string(filepath.Separator), "foo", "bar"), bar.(billy.Chroot).Root())
+
+	// Chroots are views sharing the storage of fs.
+	require.NoError(t, util.WriteFile(bar, "qux", []byte("qux"), 0o644))
+	b, err := util.ReadFile(fs, "foo/bar/qux")
+	require.NoError(t, err)
+	assert.Equal(t, "qux", string(b))
+}
+
+func TestWithNameLimits(t *testing.T) {
+	fs := New(WithNameLimits(billy.DefaultNameLimits))
+	long := strings.Repeat("a", 256)
+
+	require.NoError(t, util.WriteFile(fs, long[1:], nil, 0o644))
+	err := util.WriteFile(fs, long, nil, 0o644)
+	assert.ErrorIs(t, err, billy.ErrNameTooLong)
+	assert.ErrorIs(t, fs.MkdirAll("foo/"+long, 0o755), billy.ErrNameTooLong)
+	assert.ErrorIs(t, fs.Rename(long[1:], long), billy.ErrNameTooLong)
+
+	_, err = fs.Stat("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
 }
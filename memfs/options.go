@@ -0,0 +1,113 @@
+package memfs
+
+import (
+	"io/fs"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// Option configures a Memory filesystem.
+type Option func(*options)
+
+type options struct {
+	locking         bool
+	maxSymlinkDepth int
+	unsortedReadDir bool
+	maxDirEntries   int
+	umask           fs.FileMode
+	explicitDirs    bool
+	slashSeparator  bool
+	nameLimits      billy.NameLimits
+}
+
+// defaultMaxSymlinkDepth is the number of symlinks followed by default while
+// resolving a path, the same limit as util.EvalSymlinks and osfs.BoundOS.
+const defaultMaxSymlinkDepth = 255
+
+// WithMutex makes the filesystem safe for concurrent use, which is the
+// default. Lookups never take a lock, as they read an immutable snapshot of
+// the tree, while changes lock the shards of the dirs they touch, so changes
+// to different dirs rarely contend with each other.
+func WithMutex() Option {
+	return func(o *options) {
+		o.locking = true
+	}
+}
+
+// WithoutMutex disables the locking of the filesystem storage, reducing the
+// overhead of each change. The resulting filesystem must not be used
+// concurrently, and is mostly useful for single threaded workloads and
+// benchmarks.
+func WithoutMutex() Option {
+	return func(o *options) {
+		o.locking = false
+	}
+}
+
+// WithMaxSymlinkDepth sets the maximum number of symlinks followed while
+// resolving a path, after which the operation fails with an error wrapping
+// ELOOP, as it does on the OS. It defaults to 255.
+func WithMaxSymlinkDepth(n int) Option {
+	return func(o *options) {
+		o.maxSymlinkDepth = n
+	}
+}
+
+// WithUnsortedReadDir makes ReadDir return the entries in no particular order,
+// saving the cost of sorting them, for callers which do not depend on the
+// order, such as those building a set of names.
+func WithUnsortedReadDir() Option {
+	return func(o *options) {
+		o.unsortedReadDir = true
+	}
+}
+
+// WithMaxDirEntries limits the number of entries of each dir to n. Creating
+// an entry in a full dir, or renaming an entry into it, fails with an error
+// wrapping ENOSPC, as it does on the OS when a dir cannot grow any further.
+// It defaults to zero, meaning no limit.
+func WithMaxDirEntries(n int) Option {
+	return func(o *options) {
+		o.maxDirEntries = n
+	}
+}
+
+// WithUmask makes the filesystem clear the permission bits of mask from the
+// mode of the files and dirs it creates, as the OS does with the umask of the
+// process, so the modes match the ones osfs would create. Chmod is not
+// affected. It defaults to zero, keeping the modes as given.
+func WithUmask(mask fs.FileMode) Option {
+	return func(o *options) {
+		o.umask = mask & fs.ModePerm
+	}
+}
+
+// WithExplicitDirs makes the filesystem require the parent dir of a file to
+// exist to create it, failing with os.ErrNotExist otherwise, as on POSIX.
+// By default Create, OpenFile, Symlink and Rename create the missing
+// parents, as MkdirAll does.
+func WithExplicitDirs() Option {
+	return func(o *options) {
+		o.explicitDirs = true
+	}
+}
+
+// WithSlashSeparator makes the filesystem always use a forward slash as the
+// path separator, as the path package does, so it behaves the same on every
+// OS. Backslashes are then part of the names, even on Windows. By default the
+// separator is the one of the OS, and the paths are handled as the
+// path/filepath package does.
+func WithSlashSeparator() Option {
+	return func(o *options) {
+		o.slashSeparator = true
+	}
+}
+
+// WithNameLimits makes the filesystem reject the paths exceeding l with a
+// *billy.NameError, as the OS would, billy.DefaultNameLimits holding the
+// usual limits. By default any length is accepted.
+func WithNameLimits(l billy.NameLimits) Option {
+	return func(o *options) {
+		o.nameLimits = l
+	}
+}
@@ -0,0 +1,92 @@
+package memfs
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathOps manipulates the paths of a filesystem, using the separator of the
+// OS, as the path/filepath package does, or with WithSlashSeparator, always a
+// forward slash, as the path package does.
+type pathOps struct {
+	slash bool
+}
+
+// Separator returns the separator of the path elements.
+func (p pathOps) Separator() byte {
+	if p.slash {
+		return '/'
+	}
+
+	return filepath.Separator
+}
+
+// Root returns the path of the root dir.
+func (p pathOps) Root() string {
+	return string(p.Separator())
+}
+
+// Clean returns the shortest path equivalent to path, converting the forward
+// slashes to the separator of the OS unless it always is a forward slash.
+func (p pathOps) Clean(name string) string {
+	if p.slash {
+		return path.Clean(name)
+	}
+
+	return filepath.Clean(filepath.FromSlash(name))
+}
+
+func (p pathOps) Join(elem ...string) string {
+	if p.slash {
+		return path.Join(elem...)
+	}
+
+	return filepath.Join(elem...)
+}
+
+func (p pathOps) Dir(name string) string {
+	if p.slash {
+		return path.Dir(name)
+	}
+
+	return filepath.Dir(name)
+}
+
+func (p pathOps) Base(name string) string {
+	if p.slash {
+		return path.Base(name)
+	}
+
+	return filepath.Base(name)
+}
+
+// IsAbs reports whether name is absolute. Any path starting with the
+// separator is, even on Windows, where filepath.IsAbs also requires a volume
+// name, such as `C:\`.
+func (p pathOps) IsAbs(name string) bool {
+	if p.slash {
+		return strings.HasPrefix(name, "/")
+	}
+
+	return filepath.IsAbs(name) || strings.HasPrefix(name, string(filepath.Separator))
+}
+
+// FromSlash converts the slash separated name, such as the ones of io/fs and
+// tar archives, to a path.
+func (p pathOps) FromSlash(name string) string {
+	if p.slash {
+		return name
+	}
+
+	return filepath.FromSlash(name)
+}
+
+// ToSlash converts name to a slash separated path.
+func (p pathOps) ToSlash(name string) string {
+	if p.slash {
+		return name
+	}
+
+	return filepath.ToSlash(name)
+}
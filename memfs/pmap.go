@@ -0,0 +1,266 @@
+package memfs
+
+import (
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+const (
+	pmapBits = 5
+	pmapMask = 1<<pmapBits - 1
+	// pmapMaxShift is the shift after which the bits of the hashes are
+	// exhausted, and the nodes hold the colliding entries in a plain list.
+	pmapMaxShift = 60
+)
+
+// pmap is a persistent map from strings to V, implemented as a hash array
+// mapped trie. A pmap is never modified once built: Set and Delete return a
+// new map sharing all the nodes but the ones on the path to the key, so any
+// number of readers can use a map while its successors are being built. The
+// nil *pmap is a valid empty map.
+type pmap[V any] struct {
+	root *pnode[V]
+	size int
+}
+
+type pnode[V any] struct {
+	// bitmap has a bit set for each of the slots of the node in use, which
+	// are stored in order in entries. It is unused by collision nodes.
+	bitmap  uint32
+	entries []pentry[V]
+}
+
+// pentry is either a leaf holding a key and its value, or, if child is not
+// nil, the subtrie of the keys whose hashes share the prefix of the slot.
+type pentry[V any] struct {
+	key   string
+	value V
+	child *pnode[V]
+}
+
+// Len returns the number of keys in m.
+func (m *pmap[V]) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.size
+}
+
+// Get returns the value of key, and whether it was found.
+func (m *pmap[V]) Get(key string) (V, bool) {
+	var zero V
+	if m == nil {
+		return zero, false
+	}
+
+	h := pmapHash(key)
+	n := m.root
+	for shift := uint(0); n != nil; shift += pmapBits {
+		if shift >= pmapMaxShift {
+			for _, e := range n.entries {
+				if e.key == key {
+					return e.value, true
+				}
+			}
+
+			return zero, false
+		}
+
+		bit := uint32(1) << (h >> shift & pmapMask)
+		if n.bitmap&bit == 0 {
+			return zero, false
+		}
+
+		e := &n.entries[bits.OnesCount32(n.bitmap&(bit-1))]
+		if e.child == nil {
+			if e.key == key {
+				return e.value, true
+			}
+
+			return zero, false
+		}
+
+		n = e.child
+	}
+
+	return zero, false
+}
+
+// Set returns a copy of m with key set to v.
+func (m *pmap[V]) Set(key string, v V) *pmap[V] {
+	var root *pnode[V]
+	if m != nil {
+		root = m.root
+	}
+
+	root, added := root.set(pmapHash(key), pentry[V]{key: key, value: v}, 0)
+	size := m.Len()
+	if added {
+		size++
+	}
+
+	return &pmap[V]{root: root, size: size}
+}
+
+// Delete returns a copy of m without key, or m itself if key is missing.
+func (m *pmap[V]) Delete(key string) *pmap[V] {
+	if m == nil {
+		return nil
+	}
+
+	root, removed := m.root.delete(pmapHash(key), key, 0)
+	if !removed {
+		return m
+	}
+
+	return &pmap[V]{root: root, size: m.size - 1}
+}
+
+// All returns an iterator over the keys and values of m, in no particular
+// order.
+func (m *pmap[V]) All() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		if m != nil {
+			m.root.all(yield)
+		}
+	}
+}
+
+// set returns a copy of n with the leaf e, whose key hashes to h, added or
+// replacing the one with the same key, and whether the key was added. n can
+// be nil.
+func (n *pnode[V]) set(h uint64, e pentry[V], shift uint) (*pnode[V], bool) {
+	if n == nil {
+		n = &pnode[V]{}
+	}
+
+	if shift >= pmapMaxShift {
+		c := &pnode[V]{entries: slices.Clone(n.entries)}
+		for i := range c.entries {
+			if c.entries[i].key == e.key {
+				c.entries[i] = e
+				return c, false
+			}
+		}
+
+		c.entries = append(c.entries, e)
+		return c, true
+	}
+
+	bit := uint32(1) << (h >> shift & pmapMask)
+	i := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		entries := make([]pentry[V], 0, len(n.entries)+1)
+		entries = append(entries, n.entries[:i]...)
+		entries = append(entries, e)
+		entries = append(entries, n.entries[i:]...)
+		return &pnode[V]{bitmap: n.bitmap | bit, entries: entries}, true
+	}
+
+	c := &pnode[V]{bitmap: n.bitmap, entries: slices.Clone(n.entries)}
+	cur := &c.entries[i]
+	switch {
+	case cur.child != nil:
+		child, added := cur.child.set(h, e, shift+pmapBits)
+		cur.child = child
+		return c, added
+	case cur.key == e.key:
+		*cur = e
+		return c, false
+	default:
+		// The slot is taken by another key, so both are moved down to a
+		// new node, where their hashes are told apart by the next bits.
+		var child *pnode[V]
+		child, _ = child.set(pmapHash(cur.key), *cur, shift+pmapBits)
+		child, _ = child.set(h, e, shift+pmapBits)
+		*cur = pentry[V]{child: child}
+		return c, true
+	}
+}
+
+// delete returns a copy of n without key, which is nil if n is left empty,
+// and whether the key was found.
+func (n *pnode[V]) delete(h uint64, key string, shift uint) (*pnode[V], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	if shift >= pmapMaxShift {
+		i := slices.IndexFunc(n.entries, func(e pentry[V]) bool { return e.key == key })
+		if i < 0 {
+			return n, false
+		}
+
+		if len(n.entries) == 1 {
+			return nil, true
+		}
+
+		return &pnode[V]{entries: slices.Delete(slices.Clone(n.entries), i, i+1)}, true
+	}
+
+	bit := uint32(1) << (h >> shift & pmapMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	i := bits.OnesCount32(n.bitmap & (bit - 1))
+	cur := n.entries[i]
+	var child *pnode[V]
+	if cur.child != nil {
+		var removed bool
+		child, removed = cur.child.delete(h, key, shift+pmapBits)
+		if !removed {
+			return n, false
+		}
+	} else if cur.key != key {
+		return n, false
+	}
+
+	c := &pnode[V]{bitmap: n.bitmap, entries: slices.Clone(n.entries)}
+	switch {
+	case child == nil:
+		c.bitmap &^= bit
+		c.entries = slices.Delete(c.entries, i, i+1)
+		if len(c.entries) == 0 {
+			return nil, true
+		}
+	case len(child.entries) == 1 && child.entries[0].child == nil:
+		// A subtrie left with a single leaf is replaced by the leaf.
+		c.entries[i] = child.entries[0]
+	default:
+		c.entries[i].child = child
+	}
+
+	return c, true
+}
+
+func (n *pnode[V]) all(yield func(string, V) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	for _, e := range n.entries {
+		if e.child != nil {
+			if !e.child.all(yield) {
+				return false
+			}
+		} else if !yield(e.key, e.value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pmapHash hashes key using FNV-1a, like shardIndex but on 64 bits.
+func pmapHash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+
+	return h
+}
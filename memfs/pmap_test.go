@@ -0,0 +1,69 @@
+package memfs
+
+import (
+	"maps"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+)
+
+func TestPmapMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	var m *pmap[int]
+	ref := map[string]int{}
+
+	for i := 0; i < 5000; i++ {
+		key := strconv.Itoa(r.Intn(1000))
+		if r.Intn(3) == 0 {
+			m = m.Delete(key)
+			delete(ref, key)
+		} else {
+			m = m.Set(key, i)
+			ref[key] = i
+		}
+
+		require.Equal(t, len(ref), m.Len())
+	}
+
+	for key, want := range ref {
+		v, ok := m.Get(key)
+		require.True(t, ok, key)
+		assert.Equal(t, want, v)
+	}
+
+	_, ok := m.Get("missing")
+	assert.False(t, ok)
+	assert.Equal(t, ref, maps.Collect(m.All()))
+}
+
+func TestPmapPersistent(t *testing.T) {
+	var empty *pmap[string]
+	assert.Zero(t, empty.Len())
+	assert.Nil(t, empty.Delete("foo"))
+
+	a := empty.Set("foo", "a")
+	for i := 0; i < 100; i++ {
+		a = a.Set(strconv.Itoa(i), "a")
+	}
+
+	b := a.Set("foo", "b").Delete("0").Set("bar", "b")
+
+	v, _ := a.Get("foo")
+	assert.Equal(t, "a", v)
+	_, ok := a.Get("0")
+	assert.True(t, ok)
+	_, ok = a.Get("bar")
+	assert.False(t, ok)
+	assert.Equal(t, 101, a.Len())
+
+	v, _ = b.Get("foo")
+	assert.Equal(t, "b", v)
+	_, ok = b.Get("0")
+	assert.False(t, ok)
+	assert.Equal(t, 101, b.Len())
+
+	assert.Same(t, a, a.Delete("missing"))
+}
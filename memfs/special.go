@@ -0,0 +1,52 @@
+package memfs
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// Mknod creates a special file named path in fs, which must have been
+// returned by New, Load or the Chroot method of one of them. The type of
+// mode must be a block or char device, a named pipe or a socket.
+//
+// The special files are placeholders, meant to test the tools having to
+// handle them: they are reported by Stat, Lstat and ReadDir with their mode,
+// and are kept by Dump, except for the sockets, which tar archives cannot
+// hold, but opening them fails with an error wrapping billy.ErrNotSupported.
+func Mknod(fs billy.Filesystem, path string, mode fs.FileMode) error {
+	m, ok := unwrap(fs)
+	if !ok {
+		return ErrNotMemory
+	}
+
+	root := m.s.paths.Root()
+	if ch, ok := fs.(billy.Chroot); ok {
+		root = ch.Root()
+	}
+
+	// As in load, joining with the root before cleaning path prevents the
+	// file from being placed outside of fs.
+	full := m.s.paths.Join(root, m.s.paths.Clean(m.s.paths.Root()+path))
+	return m.mknod(path, full, mode)
+}
+
+func (fs *Memory) mknod(name, path string, mode fs.FileMode) error {
+	switch mode.Type() {
+	case os.ModeDevice, os.ModeDevice | os.ModeCharDevice, os.ModeNamedPipe, os.ModeSocket:
+	default:
+		return &os.PathError{Op: "mknod", Path: name, Err: os.ErrInvalid}
+	}
+
+	f, err := fs.s.New(path, mode&^fs.umask, 0)
+	if err == nil && f == nil {
+		// New returns no file when a dir already exists.
+		err = os.ErrExist
+	}
+	if err != nil {
+		return billy.WrapPathError("mknod", name, err)
+	}
+
+	return nil
+}
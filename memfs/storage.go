@@ -1,58 +1,174 @@
 package memfs
 
 import (
-	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/go-git/go-billy/v6/internal/errno"
 )
 
+// shardCount is the number of shards the storage is split into. Entries are
+// assigned to a shard based on their parent dir, so operations on different
+// dirs are unlikely to contend for the same lock.
+const shardCount = 32
+
+// storage holds the tree of a Memory filesystem. The tree is never modified
+// in place: readers load the current snapshot without taking any lock, while
+// writers take the locks of the shards they touch, build the next version of
+// those shards in a txn, and publish it as a new snapshot.
 type storage struct {
-	files    map[string]*file
-	children map[string]map[string]*file
+	root atomic.Pointer[snapshot]
+	// mus holds the locks of the shards, which are only taken by writers.
+	mus [shardCount]rwLocker
+	// maxDirEntries is the max number of entries of a dir, or zero if
+	// unlimited.
+	maxDirEntries int
+	// explicitDirs disables the creation of the missing parents of the
+	// files and symlinks, which are only created by MkdirAll.
+	explicitDirs bool
+	paths        pathOps
 }
 
-func newStorage() *storage {
-	return &storage{
-		files:    make(map[string]*file, 0),
-		children: make(map[string]map[string]*file, 0),
+// snapshot is an immutable version of the whole tree. Files are only
+// reachable through the children of their parent dir, except for the root.
+type snapshot struct {
+	top *file
+	// dirs holds, for each shard, the children by name of the dirs which
+	// hash to the shard index.
+	dirs [shardCount]*pmap[*pmap[*file]]
+}
+
+func newStorage(locking bool) *storage {
+	s := &storage{}
+	for i := range s.mus {
+		s.mus[i] = noLocker{}
+		if locking {
+			s.mus[i] = &sync.RWMutex{}
+		}
 	}
+
+	s.root.Store(&snapshot{})
+	return s
 }
 
-func (s *storage) Has(path string) bool {
-	path = clean(path)
+// newFile returns a new empty file, owned by the user and group of the
+// current process, as it would be on the OS, or by root on the OSes without
+// numeric ids, such as Windows.
+func (s *storage) newFile(name string, mode fs.FileMode, flag int) *file {
+	return &file{
+		name:    name,
+		paths:   s.paths,
+		content: &content{name: name},
+		mode:    mode &^ chmodMask,
+		perm:    mode & chmodMask,
+		flag:    flag,
+		modTime: time.Now(),
+		uid:     max(os.Getuid(), 0),
+		gid:     max(os.Getgid(), 0),
+	}
+}
 
-	_, ok := s.files[path]
+func (s *storage) Has(path string) bool {
+	_, ok := s.Get(path)
 	return ok
 }
 
 func (s *storage) New(path string, mode fs.FileMode, flag int) (*file, error) {
-	path = clean(path)
-	if s.Has(path) {
-		if !s.MustGet(path).mode.IsDir() {
-			return nil, fmt.Errorf("file already exists %q", path)
+	return s.newNode(path, mode, flag, "")
+}
+
+// NewSymlink creates a symlink to target at path, as New does for the other
+// files. The target is set before the symlink is stored, as it is read
+// without locks while resolving paths.
+func (s *storage) NewSymlink(path, target string) (*file, error) {
+	return s.newNode(path, 0o777|os.ModeSymlink, 0, target)
+}
+
+func (s *storage) newNode(path string, mode fs.FileMode, flag int, target string) (*file, error) {
+	path = s.paths.Clean(path)
+	name := s.paths.Base(path)
+	base := s.paths.Dir(path)
+
+	f := s.newFile(name, mode, flag)
+	f.target = target
+
+	for {
+		unlock := s.lock([]string{base}, []string{s.paths.Dir(base)})
+		t := s.begin()
+		if existing, ok := t.get(path); ok {
+			unlock()
+			if !existing.mode.IsDir() {
+				if mode.IsDir() {
+					return nil, syscall.ENOTDIR
+				}
+				return nil, os.ErrExist
+			}
+
+			return nil, nil
+		}
+
+		if name == s.paths.Root() {
+			t.setTop(f)
+			t.commit()
+			unlock()
+			return f, nil
+		}
+
+		parent, ok := t.get(base)
+		if ok && parent.mode.IsDir() {
+			if t.isFull(base) {
+				unlock()
+				return nil, errno.ENOSPC
+			}
+
+			t.insert(path, f)
+			t.commit()
+			unlock()
+			return f, nil
+		}
+		unlock()
+
+		if ok {
+			return nil, fmt.Errorf("failed to create parent: %w", syscall.ENOTDIR)
+		}
+
+		if s.explicitDirs && !mode.IsDir() {
+			return nil, os.ErrNotExist
+		}
+
+		if _, err := s.New(base, mode.Perm()|os.ModeDir, 0); err != nil {
+			return nil, fmt.Errorf("failed to create parent: %w", err)
+		}
+	}
+}
+
+// newLocked is the equivalent of New for callers already holding the locks
+// of every shard.
+func (t *txn) newLocked(path string, mode fs.FileMode, flag int) (*file, error) {
+	if f, ok := t.get(path); ok {
+		if !f.mode.IsDir() {
+			return nil, os.ErrExist
 		}
 
 		return nil, nil
 	}
 
-	name := filepath.Base(path)
+	name := t.s.paths.Base(path)
 
-	f := &file{
-		name:    name,
-		content: &content{name: name},
-		mode:    mode,
-		flag:    flag,
-		modTime: time.Now(),
+	f := t.s.newFile(name, mode, flag)
+	if name == t.s.paths.Root() {
+		t.setTop(f)
+		return f, nil
 	}
 
-	s.files[path] = f
-	err := s.createParent(path, mode, f)
+	err := t.createParent(path, mode, f)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create parent: %w", err)
 	}
@@ -60,36 +176,92 @@ func (s *storage) New(path string, mode fs.FileMode, flag int) (*file, error) {
 	return f, nil
 }
 
-func (s *storage) createParent(path string, mode fs.FileMode, f *file) error {
-	base := filepath.Dir(path)
-	base = clean(base)
-	if f.Name() == string(separator) {
-		return nil
-	}
+func (t *txn) createParent(path string, mode fs.FileMode, f *file) error {
+	base := t.s.paths.Dir(path)
+	base = t.s.paths.Clean(base)
 
-	if _, err := s.New(base, mode.Perm()|os.ModeDir, 0); err != nil {
+	if _, err := t.newLocked(base, mode.Perm()|os.ModeDir, 0); err != nil {
 		return err
 	}
 
-	if _, ok := s.children[base]; !ok {
-		s.children[base] = make(map[string]*file, 0)
+	t.insert(path, f)
+	return nil
+}
+
+// insert adds f to the storage as path. The caller must hold the write lock
+// of the shard of the parent dir of path.
+func (t *txn) insert(path string, f *file) {
+	base := t.s.paths.Dir(path)
+	dirs := t.shard(base)
+
+	children, _ := (*dirs).Get(base)
+	*dirs = (*dirs).Set(base, children.Set(f.Name(), f))
+}
+
+// removeChild removes the entry called name from the children of dir. The
+// caller must hold the write lock of the shard of dir.
+func (t *txn) removeChild(dir, name string) {
+	dirs := t.shard(dir)
+	if children, ok := (*dirs).Get(dir); ok {
+		*dirs = (*dirs).Set(dir, children.Delete(name))
 	}
+}
 
-	s.children[base][f.Name()] = f
-	return nil
+// isFull reports whether the dir at path has as many entries as allowed. The
+// caller must hold the lock of the shard of path.
+func (t *txn) isFull(path string) bool {
+	return t.s.maxDirEntries > 0 && t.children(path).Len() >= t.s.maxDirEntries
 }
 
 func (s *storage) Children(path string) []*file {
-	path = clean(path)
+	path = s.paths.Clean(path)
 
-	l := make([]*file, 0)
-	for _, f := range s.children[path] {
+	children := s.root.Load().children(path)
+	l := make([]*file, 0, children.Len())
+	for _, f := range children.All() {
 		l = append(l, f)
 	}
 
 	return l
 }
 
+// Tree returns the paths of root and every entry under it, along with the
+// matching files. Dirs come before their children, which are sorted by name.
+// As the whole tree is read from a single snapshot, the result is consistent
+// even if it is changed concurrently.
+func (s *storage) Tree(root string) ([]string, []*file) {
+	var paths []string
+	var files []*file
+	s.walk(s.root.Load(), root, func(path string, f *file) {
+		paths = append(paths, path)
+		files = append(files, f)
+	})
+
+	return paths, files
+}
+
+// walk calls fn for the entry at path in sn and, if it is a dir, for every
+// entry under it, following the children of each dir.
+func (s *storage) walk(sn *snapshot, path string, fn func(path string, f *file)) {
+	f, ok := sn.get(s.paths, path)
+	if !ok {
+		return
+	}
+
+	fn(path, f)
+
+	children := sn.children(path)
+	names := make([]string, 0, children.Len())
+	for name := range children.All() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s.walk(sn, s.paths.Join(path, name), fn)
+	}
+}
+
 func (s *storage) MustGet(path string) *file {
 	f, ok := s.Get(path)
 	if !ok {
@@ -99,148 +271,366 @@ func (s *storage) MustGet(path string) *file {
 	return f
 }
 
+// Get returns the file stored as path in the current snapshot. It takes no
+// locks, so it never waits for writers.
 func (s *storage) Get(path string) (*file, bool) {
-	path = clean(path)
-	if !s.Has(path) {
-		return nil, false
+	path = s.paths.Clean(path)
+	return s.root.Load().get(s.paths, path)
+}
+
+// NotExistError returns the error to report when path does not exist. Like
+// the os package, it is ENOTDIR if one of the parents of path is a file, and
+// os.ErrNotExist otherwise.
+func (s *storage) NotExistError(path string) error {
+	for dir := s.paths.Dir(s.paths.Clean(path)); dir != s.paths.Dir(dir); dir = s.paths.Dir(dir) {
+		if f, ok := s.Get(dir); ok {
+			if f.mode.IsRegular() {
+				return syscall.ENOTDIR
+			}
+			break
+		}
 	}
 
-	file, ok := s.files[path]
-	return file, ok
+	return os.ErrNotExist
+}
+
+// get returns the file stored as path, including the changes made by t. The
+// caller must hold at least the read lock of the shard of the parent dir of
+// path.
+func (t *txn) get(path string) (*file, bool) {
+	return t.snapshot.get(t.s.paths, path)
 }
 
 func (s *storage) Rename(from, to string) error {
-	from = clean(from)
-	to = clean(to)
+	return s.rename(from, to, false)
+}
 
-	if !s.Has(from) {
+// RenameNoReplace is like Rename, but fails with os.ErrExist if to already
+// exists.
+func (s *storage) RenameNoReplace(from, to string) error {
+	return s.rename(from, to, true)
+}
+
+func (s *storage) rename(from, to string, noReplace bool) error {
+	from = s.paths.Clean(from)
+	to = s.paths.Clean(to)
+
+	unlock := s.lockAll()
+	defer unlock()
+
+	t := s.begin()
+	defer t.commit()
+
+	f, ok := t.get(from)
+	if !ok {
 		return os.ErrNotExist
 	}
 
-	move := [][2]string{{from, to}}
+	if _, ok := t.get(to); ok && noReplace {
+		return os.ErrExist
+	}
 
-	for pathFrom := range s.files {
-		if pathFrom == from || !strings.HasPrefix(pathFrom, from) {
-			continue
-		}
+	if err := t.checkRename(f, from, to); err != nil {
+		return err
+	}
+
+	if from == to {
+		return nil
+	}
+
+	if _, err := t.newLocked(s.paths.Dir(to), f.getMode().Perm()|os.ModeDir, 0); err != nil {
+		return fmt.Errorf("failed to create parent: %w", err)
+	}
+
+	t.move(from, to)
+	return nil
+}
+
+// Exchange atomically swaps the entries at a and b, along with their
+// children. Both must exist, and neither can be an ancestor of the other.
+func (s *storage) Exchange(a, b string) error {
+	a = s.paths.Clean(a)
+	b = s.paths.Clean(b)
+
+	unlock := s.lockAll()
+	defer unlock()
+
+	t := s.begin()
+	defer t.commit()
+
+	if _, ok := t.get(a); !ok {
+		return os.ErrNotExist
+	}
+
+	if _, ok := t.get(b); !ok {
+		return os.ErrNotExist
+	}
 
-		rel, _ := filepath.Rel(from, pathFrom)
-		pathTo := filepath.Join(to, rel)
+	if a == b {
+		return nil
+	}
+
+	sep := string(s.paths.Separator())
+	if strings.HasPrefix(b, a+sep) || strings.HasPrefix(a, b+sep) {
+		return syscall.EINVAL
+	}
+
+	// The entries are swapped through a temporary path next to a, which
+	// cannot be observed by others as the changes are published at once.
+	tmp := a + "\x00exchange"
+	for _, m := range [][2]string{{a, tmp}, {b, a}, {tmp, b}} {
+		t.move(m[0], m[1])
+	}
+
+	return nil
+}
 
-		move = append(move, [2]string{pathFrom, pathTo})
+// checkRename reports whether f can be renamed from from to to, following
+// the semantics of os.Rename: an existing dir is never replaced, whether it
+// is empty or not, a dir can only replace a missing entry, and a file can
+// replace another file. The caller must hold all the locks.
+func (t *txn) checkRename(f *file, from, to string) error {
+	paths := t.s.paths
+	if f.mode.IsDir() && strings.HasPrefix(to, from+string(paths.Separator())) {
+		return syscall.EINVAL
 	}
 
-	for _, ops := range move {
-		from := ops[0]
-		to := ops[1]
+	existing, exists := t.get(to)
+	if exists && existing.mode.IsDir() {
+		return syscall.EEXIST
+	}
 
-		if err := s.move(from, to); err != nil {
-			return err
+	if from == to {
+		return nil
+	}
+
+	for dir := paths.Dir(to); ; dir = paths.Dir(dir) {
+		if parent, ok := t.get(dir); ok {
+			if !parent.mode.IsDir() {
+				return syscall.ENOTDIR
+			}
+			break
+		}
+		if t.s.explicitDirs {
+			return os.ErrNotExist
 		}
+		if dir == paths.Dir(dir) {
+			break
+		}
+	}
+
+	if exists && f.mode.IsDir() {
+		return syscall.ENOTDIR
+	}
+
+	if !exists && paths.Dir(from) != paths.Dir(to) && t.isFull(paths.Dir(to)) {
+		return errno.ENOSPC
 	}
 
 	return nil
 }
 
-func (s *storage) move(from, to string) error {
-	s.files[to] = s.files[from]
-	s.files[to].name = filepath.Base(to)
-	s.children[to] = s.children[from]
+// move moves the entry at from, along with everything under it, to to,
+// replacing the file at to if any. The parent of to must exist. The caller
+// must hold all the locks.
+func (t *txn) move(from, to string) {
+	f, _ := t.get(from)
 
-	defer func() {
-		delete(s.children, from)
-		delete(s.files, from)
-		delete(s.children[filepath.Dir(from)], filepath.Base(from))
-	}()
+	t.removeChild(t.s.paths.Dir(from), t.s.paths.Base(from))
 
-	return s.createParent(to, 0644, s.files[to])
+	f.setName(t.s.paths.Base(to))
+	t.insert(to, f)
+	t.moveChildren(from, to)
+}
+
+// moveChildren moves the children of the dir at from, and theirs in turn, to
+// the dir at to. Only the paths change, as the entries themselves are kept.
+func (t *txn) moveChildren(from, to string) {
+	children, ok := t.dirs[shardIndex(from)].Get(from)
+	if !ok {
+		return
+	}
+
+	dirs := t.shard(from)
+	*dirs = (*dirs).Delete(from)
+	dirs = t.shard(to)
+	*dirs = (*dirs).Set(to, children)
+
+	for name := range children.All() {
+		t.moveChildren(t.s.paths.Join(from, name), t.s.paths.Join(to, name))
+	}
 }
 
 func (s *storage) Remove(path string) error {
-	path = clean(path)
+	path = s.paths.Clean(path)
+	base := s.paths.Dir(path)
+
+	unlock := s.lock([]string{base}, []string{path})
+	defer unlock()
 
-	f, has := s.Get(path)
+	t := s.begin()
+	defer t.commit()
+
+	f, has := t.get(path)
 	if !has {
 		return os.ErrNotExist
 	}
 
-	if f.mode.IsDir() && len(s.children[path]) != 0 {
-		return fmt.Errorf("dir: %s contains files", path)
+	// The root is never removed, whether it has entries or not.
+	if f.Name() == s.paths.Root() {
+		return os.ErrInvalid
 	}
 
-	base, file := filepath.Split(path)
-	base = filepath.Clean(base)
+	if f.mode.IsDir() && t.children(path).Len() != 0 {
+		return errno.ENOTEMPTY
+	}
 
-	delete(s.children[base], file)
-	delete(s.files, path)
+	t.removeChild(base, s.paths.Base(path))
 	return nil
 }
 
-func clean(path string) string {
-	return filepath.Clean(filepath.FromSlash(path))
+// get returns the file stored as path, which must be clean.
+func (sn *snapshot) get(paths pathOps, path string) (*file, bool) {
+	name := paths.Base(path)
+	if name == paths.Root() {
+		return sn.top, sn.top != nil
+	}
+
+	return sn.children(paths.Dir(path)).Get(name)
 }
 
-type content struct {
-	name  string
-	bytes []byte
+// children returns the entries of the dir at path, by name.
+func (sn *snapshot) children(path string) *pmap[*file] {
+	children, _ := sn.dirs[shardIndex(path)].Get(path)
+	return children
+}
 
-	m sync.RWMutex
+// txn builds the next snapshot of a storage. It starts as a copy of the
+// current one, and only the shards changed through it are published by
+// commit. Writers must hold the write locks of the shards they change, and
+// at least the read locks of the ones they read, so that the shards of the
+// copy stay up to date until the commit.
+type txn struct {
+	snapshot
+	s     *storage
+	dirty uint32
+	// topChanged is set if the root itself was created.
+	topChanged bool
 }
 
-func (c *content) WriteAt(p []byte, off int64) (int, error) {
-	if off < 0 {
-		return 0, &os.PathError{
-			Op:   "writeat",
-			Path: c.name,
-			Err:  errors.New("negative offset"),
-		}
+func (s *storage) begin() *txn {
+	return &txn{snapshot: *s.root.Load(), s: s}
+}
+
+// shard returns the dirs of the shard of dir for them to be changed.
+func (t *txn) shard(dir string) **pmap[*pmap[*file]] {
+	i := shardIndex(dir)
+	t.dirty |= 1 << i
+	return &t.dirs[i]
+}
+
+func (t *txn) setTop(f *file) {
+	t.top = f
+	t.topChanged = true
+}
+
+// commit publishes the changed shards. Other writers may have published
+// changes to other shards since t was started, so those are merged with the
+// latest snapshot rather than replacing it.
+func (t *txn) commit() {
+	if t.dirty == 0 && !t.topChanged {
+		return
 	}
 
-	c.m.Lock()
-	prev := len(c.bytes)
+	for {
+		cur := t.s.root.Load()
+		next := *cur
+		for i := range next.dirs {
+			if t.dirty&(1<<i) != 0 {
+				next.dirs[i] = t.dirs[i]
+			}
+		}
+		if t.topChanged {
+			next.top = t.top
+		}
 
-	diff := int(off) - prev
-	if diff > 0 {
-		c.bytes = append(c.bytes, make([]byte, diff)...)
+		if t.s.root.CompareAndSwap(cur, &next) {
+			t.dirty = 0
+			t.topChanged = false
+			return
+		}
 	}
+}
 
-	c.bytes = append(c.bytes[:off], p...)
-	if len(c.bytes) < prev {
-		c.bytes = c.bytes[:prev]
+// shardIndex hashes dir using FNV-1a, inlined to avoid allocations.
+func shardIndex(dir string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(dir); i++ {
+		h ^= uint32(dir[i])
+		h *= 16777619
 	}
-	c.m.Unlock()
 
-	return len(p), nil
+	return int(h % shardCount)
 }
 
-func (c *content) ReadAt(b []byte, off int64) (n int, err error) {
-	if off < 0 {
-		return 0, &os.PathError{
-			Op:   "readat",
-			Path: c.name,
-			Err:  errors.New("negative offset"),
-		}
+// lock acquires the write locks of the shards of the dirs in w, and the read
+// locks of the shards of the dirs in r. Shards are always locked in index
+// order, so that concurrent callers cannot deadlock. The returned func
+// releases all the acquired locks.
+func (s *storage) lock(w, r []string) func() {
+	var modes [shardCount]int8 // 0: unlocked, 1: read, 2: write
+	for _, dir := range r {
+		modes[shardIndex(dir)] = 1
+	}
+	for _, dir := range w {
+		modes[shardIndex(dir)] = 2
 	}
 
-	c.m.RLock()
-	size := int64(len(c.bytes))
-	if off >= size {
-		c.m.RUnlock()
-		return 0, io.EOF
+	for i, m := range modes {
+		switch m {
+		case 1:
+			s.mus[i].RLock()
+		case 2:
+			s.mus[i].Lock()
+		}
 	}
 
-	l := int64(len(b))
-	if off+l > size {
-		l = size - off
+	return func() {
+		for i := len(modes) - 1; i >= 0; i-- {
+			switch modes[i] {
+			case 1:
+				s.mus[i].RUnlock()
+			case 2:
+				s.mus[i].Unlock()
+			}
+		}
 	}
+}
 
-	btr := c.bytes[off : off+l]
-	n = copy(b, btr)
+// lockAll acquires the write locks of all shards, for operations which can
+// affect any number of dirs, such as Rename.
+func (s *storage) lockAll() func() {
+	for _, mu := range s.mus {
+		mu.Lock()
+	}
 
-	if len(btr) < len(b) {
-		err = io.EOF
+	return func() {
+		for i := len(s.mus) - 1; i >= 0; i-- {
+			s.mus[i].Unlock()
+		}
 	}
-	c.m.RUnlock()
+}
 
-	return
+type rwLocker interface {
+	sync.Locker
+	RLock()
+	RUnlock()
 }
+
+// noLocker is used by storages created with WithoutMutex.
+type noLocker struct{}
+
+func (noLocker) Lock()    {}
+func (noLocker) Unlock()  {}
+func (noLocker) RLock()   {}
+func (noLocker) RUnlock() {}
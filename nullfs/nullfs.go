@@ -0,0 +1,280 @@
+// Package nullfs provides a billy filesystem which discards everything
+// written to it, like /dev/null.
+//
+// It is useful to benchmark the code writing to a filesystem without
+// measuring the cost of the storage, or for dry runs which only need the
+// writes to succeed.
+package nullfs // import "github.com/go-git/go-billy/v6/nullfs"
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/openflag"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+const separator = string(filepath.Separator)
+
+// tempDirs counts the temp dirs named by MkdirTemp, so that their names are
+// unique.
+var tempDirs atomic.Uint64
+
+// deviceMode is the mode reported for every path but the root, the mode of
+// /dev/null.
+const deviceMode = fs.ModeDevice | fs.ModeCharDevice | 0o666
+
+// Null is a filesystem which keeps nothing. Files can always be opened and
+// created, even exclusively, and writing to them succeeds but discards the
+// data, while reading from them returns io.EOF, as with /dev/null.
+//
+// Every path but the root is reported by Stat as a character device, as
+// /dev/null is, and the root as an empty directory. Operations changing the
+// tree, such as MkdirAll, Rename or Remove, succeed without effect.
+type Null struct{}
+
+// New returns a new Null filesystem.
+func New() billy.Filesystem {
+	return &Null{}
+}
+
+func (fs *Null) String() string {
+	return "nullfs"
+}
+
+func (fs *Null) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *Null) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *Null) OpenFile(filename string, flag int, _ fs.FileMode) (billy.File, error) {
+	if err := openflag.Validate(flag); err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	if isRoot(filename) {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EISDIR}
+	}
+
+	return &file{name: clean(filename), openedPath: filename, flag: flag}, nil
+}
+
+func (fs *Null) Stat(filename string) (os.FileInfo, error) {
+	return stat(filename), nil
+}
+
+func (fs *Null) Lstat(filename string) (os.FileInfo, error) {
+	return stat(filename), nil
+}
+
+// Rename does nothing, as there is nothing to rename.
+func (fs *Null) Rename(_, _ string) error {
+	return nil
+}
+
+// Remove does nothing, as there is nothing to remove.
+func (fs *Null) Remove(_ string) error {
+	return nil
+}
+
+func (fs *Null) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *Null) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.CreateTemp(dir, prefix+"*")
+}
+
+func (fs *Null) CreateTemp(dir, pattern string) (billy.File, error) {
+	return util.CreateTemp(fs, dir, pattern)
+}
+
+func (fs *Null) TempDir(dir, prefix string) (string, error) {
+	return fs.MkdirTemp(dir, prefix+"*")
+}
+
+// MkdirTemp returns a new name for a temp dir, which is not created. As every
+// path exists, util.MkdirTemp would never find one.
+func (fs *Null) MkdirTemp(dir, pattern string) (string, error) {
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndexByte(pattern, '*'); i != -1 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	n := tempDirs.Add(1)
+	return filepath.Join(dir, prefix+strconv.FormatUint(n, 10)+suffix), nil
+}
+
+// ReadDir returns no entries, as the filesystem is empty.
+func (fs *Null) ReadDir(_ string) ([]os.FileInfo, error) {
+	return nil, nil
+}
+
+// MkdirAll does nothing, as directories are not kept.
+func (fs *Null) MkdirAll(_ string, _ fs.FileMode) error {
+	return nil
+}
+
+// Symlink does nothing, as symlinks are not kept.
+func (fs *Null) Symlink(_, _ string) error {
+	return nil
+}
+
+// Readlink fails with an error wrapping os.ErrInvalid, as no path is a
+// symlink.
+func (fs *Null) Readlink(link string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: link, Err: os.ErrInvalid}
+}
+
+// Chmod implements the billy.Change interface. It does nothing.
+func (fs *Null) Chmod(_ string, _ fs.FileMode) error {
+	return nil
+}
+
+// Lchown implements the billy.Change interface. It does nothing.
+func (fs *Null) Lchown(_ string, _, _ int) error {
+	return nil
+}
+
+// Chown implements the billy.Change interface. It does nothing.
+func (fs *Null) Chown(_ string, _, _ int) error {
+	return nil
+}
+
+// Chtimes implements the billy.Change interface. It does nothing.
+func (fs *Null) Chtimes(_ string, _, _ time.Time) error {
+	return nil
+}
+
+func (fs *Null) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *Null) Root() string {
+	return separator
+}
+
+// Capabilities implements the Capable interface. Symlinks are not supported,
+// as they are not kept.
+func (fs *Null) Capabilities() billy.Capability {
+	return billy.DefaultCapabilities | billy.ChangeCapability
+}
+
+func stat(filename string) os.FileInfo {
+	if isRoot(filename) {
+		return &fileInfo{name: separator, mode: fs.ModeDir | 0o755}
+	}
+
+	return &fileInfo{name: filepath.Base(clean(filename)), mode: deviceMode}
+}
+
+func isRoot(filename string) bool {
+	return clean(filename) == "."
+}
+
+// clean returns filename cleaned and relative to the root.
+func clean(filename string) string {
+	filename = filepath.Clean(filepath.FromSlash(filename))
+	if rel, err := filepath.Rel(separator, filename); err == nil {
+		return rel
+	}
+
+	return filename
+}
+
+type file struct {
+	name       string
+	openedPath string
+	flag       int
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) OpenedPath() string {
+	return f.openedPath
+}
+
+func (f *file) Read(_ []byte) (int, error) {
+	return f.ReadAt(nil, 0)
+}
+
+func (f *file) ReadAt(_ []byte, _ int64) (int, error) {
+	if !openflag.Readable(f.flag) {
+		return 0, f.pathError("read", errno.EBADF)
+	}
+
+	return 0, io.EOF
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return f.WriteAt(p, 0)
+}
+
+func (f *file) WriteAt(p []byte, _ int64) (int, error) {
+	if !openflag.Writable(f.flag) {
+		return 0, f.pathError("write", errno.EBADF)
+	}
+
+	return len(p), nil
+}
+
+// Seek always returns 0, as the file is always empty.
+func (f *file) Seek(_ int64, _ int) (int64, error) {
+	return 0, nil
+}
+
+func (f *file) Truncate(_ int64) error {
+	return nil
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: filepath.Base(f.name), mode: deviceMode}, nil
+}
+
+// pathError returns err wrapped in an *os.PathError for the file.
+func (f *file) pathError(op string, err error) error {
+	return &os.PathError{Op: op, Path: f.name, Err: err}
+}
+
+type fileInfo struct {
+	name string
+	mode fs.FileMode
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return 0 }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,106 @@
+package nullfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestWriteDiscards(t *testing.T) {
+	fs := New()
+
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("foo"), 0o644))
+
+	f, err := fs.OpenFile("dir/foo", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("dir", "foo"), f.Name())
+
+	n, err := f.Write([]byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Empty(t, content)
+	require.NoError(t, f.Close())
+
+	content, err = util.ReadFile(fs, "dir/foo")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+func TestAccessMode(t *testing.T) {
+	fs := New()
+
+	f, err := fs.Open("foo")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("foo"))
+	require.ErrorIs(t, err, errno.EBADF)
+	require.NoError(t, f.Close())
+
+	f, err = fs.OpenFile("foo", os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errno.EBADF)
+	require.NoError(t, f.Close())
+}
+
+func TestStat(t *testing.T) {
+	fs := New()
+
+	for _, path := range []string{"", ".", "/"} {
+		fi, err := fs.Stat(path)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir(), path)
+	}
+
+	fi, err := fs.Stat("dir/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", fi.Name())
+	assert.Equal(t, os.ModeDevice|os.ModeCharDevice|0o666, fi.Mode())
+	assert.Zero(t, fi.Size())
+
+	infos, err := fs.ReadDir("dir")
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	_, err = fs.Open("/")
+	require.ErrorIs(t, err, syscall.EISDIR)
+
+	_, err = fs.Readlink("foo")
+	require.ErrorIs(t, err, os.ErrInvalid)
+}
+
+func TestTemp(t *testing.T) {
+	fs := New()
+
+	f, err := fs.TempFile("dir", "tmp")
+	require.NoError(t, err)
+	assert.Equal(t, "dir", filepath.Dir(f.Name()))
+	require.NoError(t, f.Close())
+
+	a, err := fs.TempDir("dir", "tmp")
+	require.NoError(t, err)
+	b, err := fs.TempDir("dir", "tmp")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}
+
+func TestChroot(t *testing.T) {
+	fs, err := New().Chroot("dir")
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFileExact(fs, "foo", []byte("foo"), 0o755))
+
+	_, err = fs.Stat("../foo")
+	require.ErrorIs(t, err, billy.ErrCrossedBoundary)
+}
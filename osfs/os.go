@@ -5,12 +5,19 @@
 package osfs
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 
 	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/internal/openflag"
 )
 
 const (
@@ -21,22 +28,69 @@ const (
 // Default Filesystem representing the root of the os filesystem.
 var Default = &ChrootOS{}
 
-// New returns a new OS filesystem.
+// New returns a new OS filesystem bound to baseDir.
+//
+// By default it is a BoundOS filesystem, which refuses any path resolving
+// out of baseDir, symlinks included. Use NewLegacyChroot, or WithChrootOS,
+// for the ChrootOS filesystem, which only confines paths lexically.
+//
 // By default paths are deduplicated, but still enforced
 // under baseDir. For more info refer to WithDeduplicatePath.
 func New(baseDir string, opts ...Option) billy.Filesystem {
 	o := &options{
+		Type:            BoundOSFS,
 		deduplicatePath: true,
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	if o.longPaths {
+		baseDir = extendedPath(baseDir)
+	}
+
 	if o.Type == BoundOSFS {
-		return newBoundOS(baseDir, o.deduplicatePath)
+		return &BoundOS{
+			baseDir:         baseDir,
+			deduplicatePath: o.deduplicatePath,
+			longPaths:       o.longPaths,
+			unsortedReadDir: o.unsortedReadDir,
+			fileMode:        o.fileMode,
+			dirMode:         o.dirMode,
+			anonymousTemp:   o.anonymousTemp,
+			explicitDirs:    o.explicitDirs,
+			nameLimits:      o.nameLimits,
+		}
+	}
+
+	c := &ChrootOS{
+		fileMode:        o.fileMode,
+		dirMode:         o.dirMode,
+		longPaths:       o.longPaths,
+		unsortedReadDir: o.unsortedReadDir,
+		anonymousTemp:   o.anonymousTemp,
+		explicitDirs:    o.explicitDirs,
+	}
+
+	var underlying billy.Basic = c
+	if o.escapeHandler != nil {
+		underlying = newEscapeChecker(c, baseDir, o.escapeHandler)
 	}
+	limits := chroot.WithNameLimits(o.nameLimits)
+	if o.strict {
+		return chroot.New(underlying, baseDir, limits, chroot.WithBoundaryError(ErrPathEscapesParent))
+	}
+
+	return chroot.New(underlying, baseDir, limits)
+}
 
-	return newChrootOS(baseDir)
+// NewLegacyChroot returns a new ChrootOS filesystem with baseDir as its root,
+// as New did by default before BoundOS became the default. Its paths are
+// only confined lexically, so symlinks can lead out of baseDir. Use
+// WithEscapeHandler to find the operations relying on it while migrating to
+// New.
+func NewLegacyChroot(baseDir string, opts ...Option) billy.Filesystem {
+	return New(baseDir, append(opts, WithChrootOS())...)
 }
 
 // WithBoundOS returns the option of using a Bound filesystem OS.
@@ -60,6 +114,10 @@ func WithChrootOS() Option {
 // With DeduplicatePath (default): /base/dir/target
 // Without DeduplicatePath: /base/dir/base/dir/target
 //
+// No other billy filesystem resolves paths this way. Callers holding paths
+// of the host should convert them with util.Rel rather than rely on the
+// deduplication, so they work with any filesystem.
+//
 // This option is only used by the BoundOS OS type.
 func WithDeduplicatePath(enabled bool) Option {
 	return func(o *options) {
@@ -67,9 +125,100 @@ func WithDeduplicatePath(enabled bool) Option {
 	}
 }
 
+// WithStrictChroot makes the ChrootOS filesystem report paths escaping the
+// base dir with ErrPathEscapesParent, the same error used by BoundOS, instead
+// of billy.ErrCrossedBoundary. Since ErrPathEscapesParent wraps
+// billy.ErrCrossedBoundary, errors.Is checks against either of them work
+// with both OS types.
+//
+// This option is only used by the ChrootOS OS type.
+func WithStrictChroot() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithEscapeHandler makes the ChrootOS filesystem call handler for the
+// operations over a path which is within the base dir lexically, but resolves
+// out of it through symlinks, which a BoundOS filesystem would refuse with
+// ErrPathEscapesParent. The operation is performed if handler returns nil,
+// so it can be used to log them, and fails with the error it returns
+// otherwise, which can be the given *EscapeError.
+//
+// Only the operations of billy.Filesystem are checked. This option is only
+// used by the ChrootOS OS type.
+func WithEscapeHandler(handler func(*EscapeError) error) Option {
+	return func(o *options) {
+		o.escapeHandler = handler
+	}
+}
+
+// WithDefaultFileMode sets the permission bits used for the files created
+// by Create. By default they are created with 0o666, before the umask is
+// applied.
+func WithDefaultFileMode(mode fs.FileMode) Option {
+	return func(o *options) {
+		o.fileMode = mode & fs.ModePerm
+	}
+}
+
+// WithDefaultDirMode sets the permission bits used for the directories
+// implicitly created by the filesystem, e.g. the parents of a file being
+// created or renamed. By default they are created with 0o755, before the
+// umask is applied.
+func WithDefaultDirMode(mode fs.FileMode) Option {
+	return func(o *options) {
+		o.dirMode = mode & fs.ModePerm
+	}
+}
+
+// WithLongPaths makes the filesystem address files through the
+// extended-length form of baseDir on Windows, e.g. \\?\C:\repo, or
+// \\?\UNC\server\share\repo for a UNC path. This lifts the MAX_PATH limit
+// of 260 characters, regardless of whether long paths are enabled on the
+// system, so deep trees such as those of large monorepos can be used.
+//
+// baseDir is made absolute, as required by the extended-length form, and Root
+// returns it in that form. Use util.Rel to convert paths of the host in either
+// form to paths of the filesystem. The option has no effect on other OSes.
+func WithLongPaths() Option {
+	return func(o *options) {
+		o.longPaths = true
+	}
+}
+
+// WithExplicitDirs makes the filesystem require the parent dir of a file to
+// exist to create it, failing with os.ErrNotExist otherwise, as the OS does.
+// By default Create, OpenFile, Symlink and Rename create the missing
+// parents, as MkdirAll does.
+func WithExplicitDirs() Option {
+	return func(o *options) {
+		o.explicitDirs = true
+	}
+}
+
+// WithNameLimits makes the filesystem reject the paths exceeding l, relative
+// to baseDir, with a *billy.NameError, before reaching the OS, whose limits
+// and errors differ between platforms. billy.DefaultNameLimits holds the
+// usual limits.
+func WithNameLimits(l billy.NameLimits) Option {
+	return func(o *options) {
+		o.nameLimits = l
+	}
+}
+
 type options struct {
 	Type
 	deduplicatePath bool
+	strict          bool
+	escapeHandler   func(*EscapeError) error
+	longPaths       bool
+	unsortedReadDir bool
+	fileMode        fs.FileMode
+	dirMode         fs.FileMode
+	anonymousTemp   bool
+	explicitDirs    bool
+	nameLimits      billy.NameLimits
 }
 
 type Type int
@@ -79,7 +228,111 @@ const (
 	BoundOSFS
 )
 
-func readDir(dir string) ([]os.FileInfo, error) {
+// WithUnsortedReadDir makes ReadDir return the entries in the order of the
+// OS, saving the cost of sorting them, for callers which do not depend on the
+// order, such as those building a set of names.
+func WithUnsortedReadDir() Option {
+	return func(o *options) {
+		o.unsortedReadDir = true
+	}
+}
+
+// WithAnonymousTempFiles makes TempFile create the files unnamed, with
+// O_TMPFILE on Linux, and link them to their name only once closed, so no
+// stray temp file is left behind if the process crashes while writing them.
+// Until then, the name returned by their Name method does not exist, so they
+// cannot be renamed into place before being closed.
+//
+// On other OSes, or filesystems without support for O_TMPFILE, TempFile
+// falls back to creating the files with their name upfront.
+func WithAnonymousTempFiles() Option {
+	return func(o *options) {
+		o.anonymousTemp = true
+	}
+}
+
+// maxExtendedPathLength is the length limit of the extended-length paths on
+// Windows, used with WithLongPaths.
+const maxExtendedPathLength = 32767
+
+// pathProperties returns the naming rules of the default filesystem used by
+// the current OS. Filesystems mounted with non-default settings (e.g. a case
+// insensitive ext4 dir) are not detected.
+func pathProperties(longPaths bool) billy.PathProperties {
+	switch runtime.GOOS {
+	case "windows":
+		maxPath := 260
+		if longPaths {
+			maxPath = maxExtendedPathLength
+		}
+
+		return billy.PathProperties{
+			MaxPathLength: maxPath,
+			MaxNameLength: 255,
+			InvalidChars:  "\x00<>:\"\\|?*",
+		}
+	case "darwin", "ios":
+		return billy.PathProperties{
+			MaxPathLength: 1024,
+			MaxNameLength: 255,
+			InvalidChars:  "\x00",
+		}
+	case "plan9":
+		return billy.PathProperties{
+			CaseSensitive: true,
+			InvalidChars:  "\x00",
+		}
+	default:
+		return billy.PathProperties{
+			CaseSensitive: true,
+			MaxPathLength: 4096,
+			MaxNameLength: 255,
+			InvalidChars:  "\x00",
+		}
+	}
+}
+
+// extendedPath returns the extended-length form of path on Windows, making it
+// absolute first. Paths are returned as is on other OSes.
+func extendedPath(path string) string {
+	if runtime.GOOS != "windows" {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	return toExtended(abs)
+}
+
+// toExtended prefixes the absolute Windows path abs with \\?\, turning UNC
+// paths such as \\server\share into \\?\UNC\server\share. Paths already
+// in the extended-length or device namespace are returned as is.
+func toExtended(abs string) string {
+	switch {
+	case strings.HasPrefix(abs, `\\?\`), strings.HasPrefix(abs, `\\.\`):
+		return abs
+	case strings.HasPrefix(abs, `\\`):
+		return `\\?\UNC\` + abs[2:]
+	default:
+		return `\\?\` + abs
+	}
+}
+
+// readDir lists dir, sorting the entries by name unless unsorted is set.
+func readDir(dir string, unsorted bool) ([]os.FileInfo, error) {
+	if unsorted {
+		f, err := os.Open(dir)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return f.Readdir(-1)
+	}
+
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
@@ -95,16 +348,127 @@ func readDir(dir string) ([]os.FileInfo, error) {
 	return infos, nil
 }
 
-func tempFile(dir, prefix string) (billy.File, error) {
-	f, err := os.CreateTemp(dir, prefix)
+// readDirEntries lists dir relying on the type bits returned by the OS along
+// with the names, so no Lstat is issued until the Info method of an entry is
+// called.
+func readDirEntries(dir string) ([]fs.DirEntry, error) {
+	return os.ReadDir(dir)
+}
+
+func readDirNames(dir string, n int) ([]string, error) {
+	f, err := os.Open(dir)
 	if err != nil {
 		return nil, err
 	}
-	return &file{File: f}, nil
+	defer f.Close()
+
+	names, err := f.Readdirnames(n)
+	if errors.Is(err, io.EOF) {
+		return names, nil
+	}
+	return names, err
+}
+
+// dirIterBatch is the number of entries read at once by dirIter.
+const dirIterBatch = 256
+
+func openDir(dir string) (billy.DirIter, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirIter{f: f}, nil
+}
+
+// dirIter iterates over a directory, reading dirIterBatch entries at a time.
+type dirIter struct {
+	f   *os.File
+	buf []fs.DirEntry
+}
+
+func (it *dirIter) Next() (fs.DirEntry, error) {
+	if len(it.buf) == 0 {
+		entries, err := it.f.ReadDir(dirIterBatch)
+		if len(entries) == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		it.buf = entries
+	}
+
+	e := it.buf[0]
+	it.buf = it.buf[1:]
+	return e, nil
+}
+
+func (it *dirIter) Close() error {
+	it.buf = nil
+	return it.f.Close()
+}
+
+func tempDir(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
+// syncDir opens dir and commits its entries to stable storage. Directories
+// cannot be synced on windows, where renames are durable once they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// orDefault returns mode, or def if mode was not set.
+func orDefault(mode, def fs.FileMode) fs.FileMode {
+	if mode == 0 {
+		return def
+	}
+	return mode
+}
+
+// newTempFile creates a temp file in dir named after pattern, as
+// os.CreateTemp does, unnamed until closed if anonymous is set and the OS
+// supports it. See WithAnonymousTempFiles.
+func newTempFile(dir, pattern string, anonymous bool) (billy.File, error) {
+	if anonymous {
+		f, err := anonymousTempFile(dir, pattern)
+		if !errors.Is(err, billy.ErrNotSupported) {
+			return f, err
+		}
+	}
+
+	return tempFile(dir, pattern)
+}
+
+func tempFile(dir, pattern string) (billy.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: f.Name(), openedPath: f.Name()}, nil
 }
 
 func openFile(fn string, flag int, perm fs.FileMode, createDir func(string) error) (billy.File, error) {
-	if flag&os.O_CREATE != 0 {
+	if err := openflag.Validate(flag); err != nil {
+		return nil, &os.PathError{Op: "open", Path: fn, Err: err}
+	}
+
+	if openflag.Create(flag) {
 		if createDir == nil {
 			return nil, fmt.Errorf("createDir func cannot be nil if file needs to be opened in create mode")
 		}
@@ -117,11 +481,48 @@ func openFile(fn string, flag int, perm fs.FileMode, createDir func(string) erro
 	if err != nil {
 		return nil, err
 	}
-	return &file{File: f}, err
+	return &file{File: f, name: fn, openedPath: fn}, err
 }
 
 // file is a wrapper for an os.File which adds support for file locking.
+// The names it reports can differ from the path of the os.File, as BoundOS
+// reports them relative to its base dir.
 type file struct {
 	*os.File
 	m sync.Mutex
+
+	name       string
+	openedPath string
+	// linkPath is the path an unnamed temp file is linked to when closed.
+	// See WithAnonymousTempFiles.
+	linkPath string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+// RawFile implements the billy.RawFile interface, returning the *os.File the
+// file wraps.
+func (f *file) RawFile() (*os.File, error) {
+	return f.File, nil
+}
+
+// Close closes the file, linking it to its name first if it is an unnamed
+// temp file. It is closed even if it cannot be linked.
+func (f *file) Close() error {
+	if f.linkPath != "" {
+		path := f.linkPath
+		f.linkPath = ""
+		if err := linkAnonymous(f.File, path); err != nil {
+			_ = f.File.Close()
+			return err
+		}
+	}
+
+	return f.File.Close()
+}
+
+func (f *file) OpenedPath() string {
+	return f.openedPath
 }
@@ -0,0 +1,107 @@
+//go:build !wasm
+// +build !wasm
+
+package osfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// BenchmarkReadDir compares listing a dir holding 10k files through ReadDir,
+// which issues an lstat per entry, with ReadDirEntries, which only relies on
+// the type bits returned along with the names.
+func BenchmarkReadDir(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 10000; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%05d", i)), nil, 0o600); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		fs   billy.Filesystem
+	}{
+		{"ChrootOS", New(dir, WithChrootOS())},
+		{"BoundOS", New(dir, WithBoundOS())},
+	} {
+		b.Run(tc.name+"/ReadDir", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tc.fs.ReadDir("."); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(tc.name+"/ReadDirEntries", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tc.fs.(billy.DirEntries).ReadDirEntries("."); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCopy compares copying a 256 MiB file with util.Copy, which lets the
+// runtime use copy_file_range or sendfile on the files of the OS, with copying
+// it through a buffer in user space, as io.Copy does for the files of the
+// wrappers hiding the io.ReaderFrom of *os.File. The gap widens with the size
+// of the file, and on filesystems sharing the extents of copied files.
+func BenchmarkCopy(b *testing.B) {
+	const size = 256 << 20
+
+	dir := b.TempDir()
+	fs := New(dir)
+	f, err := fs.Create("src")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		copy func(dst, src billy.File) (int64, error)
+	}{
+		{"util.Copy", util.Copy},
+		{"io.Copy", func(dst, src billy.File) (int64, error) {
+			return io.Copy(struct{ io.Writer }{dst}, struct{ io.Reader }{src})
+		}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				src, err := fs.Open("src")
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				dst, err := fs.Create("dst")
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if _, err := tc.copy(dst, src); err != nil {
+					b.Fatal(err)
+				}
+
+				_ = src.Close()
+				if err := dst.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
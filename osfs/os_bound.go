@@ -26,14 +26,25 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 
-	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/pathutil"
 )
 
 var (
-	ErrBaseDirCannotBeRemoved = errors.New("base dir cannot be removed")
-	ErrBaseDirCannotBeRenamed = errors.New("base dir cannot be renamed")
+	// ErrBaseDirCannotBeRemoved and ErrBaseDirCannotBeRenamed are returned
+	// when removing or renaming the base dir, whether named by its absolute
+	// path or as the root, such as "" or ".". They wrap os.ErrInvalid, as the
+	// errors of the other filesystems in this case do.
+	ErrBaseDirCannotBeRemoved = fmt.Errorf("base dir cannot be removed: %w", os.ErrInvalid)
+	ErrBaseDirCannotBeRenamed = fmt.Errorf("base dir cannot be renamed: %w", os.ErrInvalid)
+
+	// ErrPathEscapesParent is returned when a path resolves to a location
+	// outside of the base dir. It wraps billy.ErrCrossedBoundary, so both
+	// sentinels can be used with errors.Is regardless of the OS type.
+	ErrPathEscapesParent = fmt.Errorf("path escapes from parent: %w", billy.ErrCrossedBoundary)
 )
 
 // BoundOS is a fs implementation based on the OS filesystem which is bound to
@@ -50,6 +61,16 @@ var (
 type BoundOS struct {
 	baseDir         string
 	deduplicatePath bool
+	longPaths       bool
+	unsortedReadDir bool
+	fileMode        fs.FileMode
+	dirMode         fs.FileMode
+	anonymousTemp   bool
+	explicitDirs    bool
+	nameLimits      billy.NameLimits
+
+	rootMu sync.Mutex
+	root   *os.Root
 }
 
 func newBoundOS(d string, deduplicatePath bool) billy.Filesystem {
@@ -57,16 +78,21 @@ func newBoundOS(d string, deduplicatePath bool) billy.Filesystem {
 }
 
 func (fs *BoundOS) Create(filename string) (billy.File, error) {
-	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, defaultCreateMode)
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, orDefault(fs.fileMode, defaultCreateMode))
 }
 
 func (fs *BoundOS) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
-	filename = fs.expandDot(filename)
-	fn, err := fs.abs(filename)
+	fn, err := fs.abs(fs.expandDot(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openFile(fn, flag, perm, fs.createDir)
 	if err != nil {
 		return nil, err
 	}
-	return openFile(fn, flag, perm, fs.createDir)
+
+	return fs.withNames(f, filename), nil
 }
 
 func (fs *BoundOS) ReadDir(path string) ([]os.FileInfo, error) {
@@ -76,21 +102,55 @@ func (fs *BoundOS) ReadDir(path string) ([]os.FileInfo, error) {
 		return nil, err
 	}
 
-	return readDir(dir)
+	return readDir(dir, fs.unsortedReadDir)
 }
 
-func (fs *BoundOS) Rename(from, to string) error {
-	if from == "." || from == fs.baseDir {
-		return ErrBaseDirCannotBeRenamed
+// ReadDirNames implements the billy.DirNames interface.
+func (fs *BoundOS) ReadDirNames(path string, n int) ([]string, error) {
+	path = fs.expandDot(path)
+	dir, err := fs.abs(path)
+	if err != nil {
+		return nil, err
 	}
 
-	from = fs.expandDot(from)
-	to = fs.expandDot(to)
-	f, err := fs.abs(from)
+	return readDirNames(dir, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (fs *BoundOS) ReadDirEntries(path string) ([]fs.DirEntry, error) {
+	path = fs.expandDot(path)
+	dir, err := fs.abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return readDirEntries(dir)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (fs *BoundOS) OpenDir(path string) (billy.DirIter, error) {
+	path = fs.expandDot(path)
+	dir, err := fs.abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return openDir(dir)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (fs *BoundOS) SyncDir(path string) error {
+	path = fs.expandDot(path)
+	dir, err := fs.abs(path)
 	if err != nil {
 		return err
 	}
-	t, err := fs.abs(to)
+
+	return syncDir(dir)
+}
+
+func (fs *BoundOS) Rename(from, to string) error {
+	f, t, err := fs.renamePaths(from, to)
 	if err != nil {
 		return err
 	}
@@ -103,13 +163,108 @@ func (fs *BoundOS) Rename(from, to string) error {
 	return os.Rename(f, t)
 }
 
+// RenameNoReplace implements the billy.Renamer interface. It is not supported
+// on platforms other than Linux and macOS.
+func (fs *BoundOS) RenameNoReplace(from, to string) error {
+	f, t, err := fs.renamePaths(from, to)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.createDir(t); err != nil {
+		return err
+	}
+
+	return renameNoReplace(f, t)
+}
+
+// RenameExchange implements the billy.Renamer interface. It is not supported
+// on platforms other than Linux and macOS.
+func (fs *BoundOS) RenameExchange(from, to string) error {
+	if fs.isBaseDir(to) {
+		return ErrBaseDirCannotBeRenamed
+	}
+
+	f, t, err := fs.renamePaths(from, to)
+	if err != nil {
+		return err
+	}
+
+	return renameExchange(f, t)
+}
+
+// renamePaths returns the absolute paths of from and to, checking that from
+// can be renamed to to.
+func (fs *BoundOS) renamePaths(from, to string) (string, string, error) {
+	if fs.isBaseDir(from) {
+		return "", "", ErrBaseDirCannotBeRenamed
+	}
+
+	from = fs.expandDot(from)
+	to = fs.expandDot(to)
+	f, err := fs.abs(from)
+	if err != nil {
+		return "", "", err
+	}
+	t, err := fs.abs(to)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Check the source before creating the parent dirs of the target, so
+	// a failed rename leaves no trace behind.
+	fi, err := os.Lstat(f)
+	if err != nil {
+		return "", "", &os.LinkError{Op: "rename", Old: from, New: to, Err: errors.Unwrap(err)}
+	}
+	if fi.IsDir() && strings.HasPrefix(t, f+string(filepath.Separator)) {
+		return "", "", &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EINVAL}
+	}
+
+	return f, t, nil
+}
+
+// MkdirAll creates the dir path along with its missing parents, like
+// os.MkdirAll, but with exactly the permission bits of perm, regardless of
+// the umask. The other bits of perm, such as fs.ModeSticky, are ignored.
 func (fs *BoundOS) MkdirAll(path string, perm fs.FileMode) error {
 	path = fs.expandDot(path)
 	dir, err := fs.abs(path)
 	if err != nil {
 		return err
 	}
-	return os.MkdirAll(dir, perm)
+	return mkdirAll(dir, perm)
+}
+
+// mkdirAll works like os.MkdirAll, but sets the permission bits of each
+// directory it creates to perm, regardless of the process umask.
+func mkdirAll(path string, perm fs.FileMode) error {
+	perm &= fs.ModePerm
+
+	fi, err := os.Stat(path)
+	if err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	parent := filepath.Dir(path)
+	if parent != path {
+		if err := mkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Mkdir(path, perm); err != nil {
+		// Another process may have created it in the meantime.
+		if fi, serr := os.Lstat(path); serr == nil && fi.IsDir() {
+			return nil
+		}
+		return err
+	}
+
+	return os.Chmod(path, perm)
 }
 
 func (fs *BoundOS) Open(filename string) (billy.File, error) {
@@ -126,8 +281,31 @@ func (fs *BoundOS) Stat(filename string) (os.FileInfo, error) {
 	return os.Stat(filename)
 }
 
+// StatMany implements the billy.BatchStater interface. The dir of the files
+// is resolved within the base dir once, however many of the files it holds.
+func (fs *BoundOS) StatMany(paths []string) ([]os.FileInfo, []error) {
+	dirs := make(map[string]string)
+	return statMany(paths, func(path string) (string, string, bool) {
+		dir, name, ok := splitStatPath(filepath.Clean(fs.expandDot(path)))
+		if !ok {
+			return "", "", false
+		}
+
+		abs, seen := dirs[dir]
+		if !seen {
+			var err error
+			if abs, err = fs.abs(dir); err != nil {
+				abs = ""
+			}
+			dirs[dir] = abs
+		}
+
+		return abs, name, abs != ""
+	}, fs.Stat)
+}
+
 func (fs *BoundOS) Remove(filename string) error {
-	if filename == "." || filename == fs.baseDir {
+	if fs.isBaseDir(filename) {
 		return ErrBaseDirCannotBeRemoved
 	}
 
@@ -138,19 +316,66 @@ func (fs *BoundOS) Remove(filename string) error {
 	return os.Remove(fn)
 }
 
-// TempFile creates a temporary file. If dir is empty, the file
-// will be created within the OS Temporary dir. If dir is provided
-// it must descend from the current base dir.
+// TempFile creates a temporary file, as CreateTemp does with the pattern
+// prefix+"*".
 func (fs *BoundOS) TempFile(dir, prefix string) (billy.File, error) {
-	if dir != "" {
-		var err error
-		dir, err = fs.abs(dir)
-		if err != nil {
-			return nil, err
-		}
+	return fs.CreateTemp(dir, prefix+"*")
+}
+
+// CreateTemp creates a temporary file in dir, which must descend from the
+// current base dir, or in the base dir if dir is empty, so temp files never
+// land outside of it, such as in the temp dir of the OS.
+func (fs *BoundOS) CreateTemp(dir, pattern string) (billy.File, error) {
+	dir, err := fs.abs(dir)
+	if err != nil {
+		return nil, err
 	}
 
-	return tempFile(dir, prefix)
+	f, err := newTempFile(dir, pattern, fs.anonymousTemp)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.withNames(f, ""), nil
+}
+
+// withNames makes f report its path relative to the base dir as its name,
+// unless it is outside of it, and openedPath as the path it was opened
+// with. An empty openedPath is replaced by the name.
+func (fs *BoundOS) withNames(f billy.File, openedPath string) billy.File {
+	of := f.(*file)
+	if rel, err := filepath.Rel(fs.baseDir, of.name); err == nil &&
+		rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		of.name = rel
+	}
+
+	of.openedPath = openedPath
+	if openedPath == "" {
+		of.openedPath = of.name
+	}
+
+	return of
+}
+
+// TempDir creates a temporary dir, as MkdirTemp does with the pattern
+// prefix+"*".
+func (fs *BoundOS) TempDir(dir, prefix string) (string, error) {
+	return fs.MkdirTemp(dir, prefix+"*")
+}
+
+// MkdirTemp creates a temporary dir in dir, or in the base dir if dir is
+// empty, like CreateTemp.
+func (fs *BoundOS) MkdirTemp(dir, pattern string) (string, error) {
+	dir, err := fs.abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, orDefault(fs.dirMode, defaultDirectoryMode)); err != nil {
+		return "", err
+	}
+
+	return tempDir(dir, pattern)
 }
 
 func (fs *BoundOS) Join(elem ...string) string {
@@ -158,7 +383,7 @@ func (fs *BoundOS) Join(elem ...string) string {
 }
 
 func (fs *BoundOS) RemoveAll(path string) error {
-	if path == "." || path == fs.baseDir {
+	if fs.isBaseDir(path) {
 		return ErrBaseDirCannotBeRemoved
 	}
 
@@ -183,6 +408,12 @@ func (fs *BoundOS) Symlink(target, link string) error {
 	return os.Symlink(target, ln)
 }
 
+// isBaseDir reports whether filename names the base dir, either by its
+// absolute path or as the root of the filesystem, such as "", "." or "/".
+func (fs *BoundOS) isBaseDir(filename string) bool {
+	return pathutil.IsRoot(filename) || filepath.Clean(filename) == fs.baseDir
+}
+
 func (fs *BoundOS) expandDot(p string) string {
 	if p == "." {
 		return fs.baseDir
@@ -218,14 +449,65 @@ func (fs *BoundOS) Readlink(link string) (string, error) {
 	return os.Readlink(link)
 }
 
+// GetXattr implements the billy.Xattr interface.
+func (fs *BoundOS) GetXattr(path, name string) ([]byte, error) {
+	fn, err := fs.abs(fs.expandDot(path))
+	if err != nil {
+		return nil, err
+	}
+	return getXattr(fn, name)
+}
+
+// SetXattr implements the billy.Xattr interface.
+func (fs *BoundOS) SetXattr(path, name string, value []byte) error {
+	fn, err := fs.abs(fs.expandDot(path))
+	if err != nil {
+		return err
+	}
+	return setXattr(fn, name, value)
+}
+
+// ListXattr implements the billy.Xattr interface.
+func (fs *BoundOS) ListXattr(path string) ([]string, error) {
+	fn, err := fs.abs(fs.expandDot(path))
+	if err != nil {
+		return nil, err
+	}
+	return listXattr(fn)
+}
+
+// RemoveXattr implements the billy.Xattr interface.
+func (fs *BoundOS) RemoveXattr(path, name string) error {
+	fn, err := fs.abs(fs.expandDot(path))
+	if err != nil {
+		return err
+	}
+	return removeXattr(fn, name)
+}
+
 // Chroot returns a new BoundOS filesystem, with the base dir set to the
 // result of joining the provided path with the underlying base dir.
 func (fs *BoundOS) Chroot(path string) (billy.Filesystem, error) {
-	joined, err := securejoin.SecureJoin(fs.baseDir, path)
+	joined, err := secureJoin(fs.baseDir, path)
 	if err != nil {
 		return nil, err
 	}
-	return New(joined, WithBoundOS()), nil
+	if fs.longPaths {
+		joined = extendedPath(joined)
+	}
+
+	// Only the base dir changes, the chroot keeps every option of fs.
+	return &BoundOS{
+		baseDir:         joined,
+		deduplicatePath: fs.deduplicatePath,
+		longPaths:       fs.longPaths,
+		unsortedReadDir: fs.unsortedReadDir,
+		fileMode:        fs.fileMode,
+		dirMode:         fs.dirMode,
+		anonymousTemp:   fs.anonymousTemp,
+		explicitDirs:    fs.explicitDirs,
+		nameLimits:      fs.nameLimits,
+	}, nil
 }
 
 // Root returns the current base dir of the billy.Filesystem.
@@ -235,10 +517,32 @@ func (fs *BoundOS) Root() string {
 	return fs.baseDir
 }
 
+// String describes the filesystem along with its base dir.
+func (fs *BoundOS) String() string {
+	return "osfs.BoundOS(" + fs.baseDir + ")"
+}
+
+// Capabilities implements the Capable interface. The capabilities depend on
+// the OS, see the capabilities const of each of them.
+func (fs *BoundOS) Capabilities() billy.Capability {
+	return capabilities
+}
+
+// PathProperties implements the Introspectable interface.
+func (fs *BoundOS) PathProperties() billy.PathProperties {
+	p := pathProperties(fs.longPaths)
+	p.ExplicitDirs = fs.explicitDirs
+	return p
+}
+
 func (fs *BoundOS) createDir(fullpath string) error {
+	if fs.explicitDirs {
+		return nil
+	}
+
 	dir := filepath.Dir(fullpath)
 	if dir != "." {
-		if err := os.MkdirAll(dir, defaultDirectoryMode); err != nil {
+		if err := os.MkdirAll(dir, orDefault(fs.dirMode, defaultDirectoryMode)); err != nil {
 			return err
 		}
 	}
@@ -257,7 +561,13 @@ func (fs *BoundOS) abs(filename string) (string, error) {
 		filename = string(filepath.Separator)
 	}
 
-	path, err := securejoin.SecureJoin(fs.baseDir, filename)
+	// The limits are checked before resolving filename, which fails with
+	// the errors of the OS on overlong names.
+	if err := fs.checkLimits(filename); err != nil {
+		return "", err
+	}
+
+	path, err := secureJoin(fs.baseDir, filename)
 	if err != nil {
 		return "", err
 	}
@@ -272,6 +582,17 @@ func (fs *BoundOS) abs(filename string) (string, error) {
 	return path, nil
 }
 
+// checkLimits returns a *billy.NameError if filename exceeds the name limits
+// of fs, relative to baseDir when it is given as a path of the host below
+// it.
+func (fs *BoundOS) checkLimits(filename string) error {
+	if rel, ok := strings.CutPrefix(filename, fs.baseDir+string(filepath.Separator)); ok {
+		filename = rel
+	}
+
+	return pathutil.CheckLimits(fs.nameLimits, filename)
+}
+
 // insideBaseDirEval checks whether filename is contained within
 // a dir that is within the fs.baseDir, by first evaluating any symlinks
 // that either filename or fs.baseDir may contain.
@@ -288,7 +609,29 @@ func (fs *BoundOS) insideBaseDirEval(filename string) (bool, error) {
 		wd = fs.baseDir
 	}
 	if filename != wd && dir != wd && !strings.HasPrefix(dir, wd+string(filepath.Separator)) {
-		return false, fmt.Errorf("%q: path outside base dir %q: %w", filename, fs.baseDir, os.ErrNotExist)
+		return false, &pathEscapeError{
+			err:  fmt.Errorf("%q: path outside base dir %q: %w", filename, fs.baseDir, os.ErrNotExist),
+			base: fs.baseDir,
+			path: filename,
+		}
 	}
 	return true, nil
 }
+
+// pathEscapeError is returned by BoundOS when a path is outside the base dir.
+// For backwards compatibility it matches both os.ErrNotExist and
+// ErrPathEscapesParent, the latter through a *billy.BoundaryError naming the
+// base dir and the path.
+type pathEscapeError struct {
+	err  error
+	base string
+	path string
+}
+
+func (e *pathEscapeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *pathEscapeError) Unwrap() []error {
+	return []error{e.err, &billy.BoundaryError{Base: e.base, Path: e.path, Err: ErrPathEscapesParent}}
+}
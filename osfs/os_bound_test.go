@@ -21,6 +21,7 @@ package osfs
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -28,8 +29,10 @@ import (
 	"testing"
 
 	"github.com/go-git/go-billy/v6"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
 )
 
 func TestOpen(t *testing.T) {
@@ -266,9 +269,16 @@ func TestTempFile(t *testing.T) {
 	f, err := fs.TempFile("", "prefix")
 	require.NoError(t, err)
 	assert.NotNil(f)
-	assert.Contains(f.Name(), os.TempDir())
+	assert.Equal(".", filepath.Dir(f.Name()))
 	require.NoError(t, f.Close())
 
+	_, err = os.Stat(filepath.Join(dir, f.Name()))
+	require.NoError(t, err)
+
+	name, err := fs.TempDir("", "prefix")
+	require.NoError(t, err)
+	assert.Equal(dir, filepath.Dir(name))
+
 	f, err = fs.TempFile("/above/cwd", "prefix")
 	require.ErrorContains(t, err, fmt.Sprint(dir, filepath.FromSlash("/above/cwd/prefix")))
 	assert.Nil(f)
@@ -296,6 +306,114 @@ func TestChroot(t *testing.T) {
 	assert.IsType(&BoundOS{}, f)
 }
 
+func TestChrootOptions(t *testing.T) {
+	long := strings.Repeat("a", 256)
+	tests := []struct {
+		name  string
+		opt   Option
+		check func(t *testing.T, sub billy.Filesystem)
+	}{
+		{
+			name: "ExplicitDirs",
+			opt:  WithExplicitDirs(),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				assert.True(t, billy.Introspect(sub).ExplicitDirs)
+				_, err := sub.Create("missing/file")
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			},
+		},
+		{
+			name: "AnonymousTempFiles",
+			opt:  WithAnonymousTempFiles(),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				if runtime.GOOS != "linux" {
+					t.Skip("O_TMPFILE is only supported on linux")
+				}
+				f, err := sub.TempFile(".", "foo")
+				require.NoError(t, err)
+				defer f.Close()
+
+				names, err := util.ReadDirNames(sub, ".", 0)
+				require.NoError(t, err)
+				assert.Empty(t, names)
+			},
+		},
+		{
+			name: "DeduplicatePath",
+			opt:  WithDeduplicatePath(false),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				require.NoError(t, util.WriteFile(sub, "foo", nil, 0o644))
+				_, err := sub.Stat(filepath.Join(sub.Root(), "foo"))
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			},
+		},
+		{
+			name: "DefaultFileMode",
+			opt:  WithDefaultFileMode(0o600),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				if runtime.GOOS == "windows" {
+					t.Skip("permission bits are not supported on windows")
+				}
+				defer umask(0)()
+				f, err := sub.Create("foo")
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+
+				fi, err := sub.Stat("foo")
+				require.NoError(t, err)
+				assert.Equal(t, fs.FileMode(0o600), fi.Mode().Perm())
+			},
+		},
+		{
+			name: "DefaultDirMode",
+			opt:  WithDefaultDirMode(0o700),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				if runtime.GOOS == "windows" {
+					t.Skip("permission bits are not supported on windows")
+				}
+				defer umask(0)()
+				require.NoError(t, util.WriteFile(sub, "dir/foo", nil, 0o644))
+
+				fi, err := sub.Stat("dir")
+				require.NoError(t, err)
+				assert.Equal(t, fs.FileMode(0o700), fi.Mode().Perm())
+			},
+		},
+		{
+			name: "NameLimits",
+			opt:  WithNameLimits(billy.DefaultNameLimits),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				assert.ErrorIs(t, sub.MkdirAll(long, 0o755), billy.ErrNameTooLong)
+			},
+		},
+		{
+			name: "LongPaths",
+			opt:  WithLongPaths(),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				assert.True(t, sub.(*BoundOS).longPaths)
+			},
+		},
+		{
+			name: "UnsortedReadDir",
+			opt:  WithUnsortedReadDir(),
+			check: func(t *testing.T, sub billy.Filesystem) {
+				assert.True(t, sub.(*BoundOS).unsortedReadDir)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := New(t.TempDir(), WithBoundOS(), tc.opt)
+			require.NoError(t, fs.MkdirAll("sub", 0o755))
+
+			sub, err := fs.Chroot("sub")
+			require.NoError(t, err)
+			tc.check(t, sub)
+		})
+	}
+}
+
 func TestRoot(t *testing.T) {
 	assert := assert.New(t)
 	dir := t.TempDir()
@@ -724,6 +842,22 @@ func TestStat(t *testing.T) {
 	}
 }
 
+func TestSymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Symlink("self", filepath.Join(dir, "self")))
+	require.NoError(t, os.Symlink("b", filepath.Join(dir, "a")))
+	require.NoError(t, os.Symlink("a", filepath.Join(dir, "b")))
+
+	fs := newBoundOS(dir, true)
+	for _, name := range []string{"self", "a", "a/file"} {
+		_, err := fs.Stat(name)
+		assert.ErrorIs(t, err, errno.ELOOP, name)
+
+		_, err = fs.Open(name)
+		assert.ErrorIs(t, err, errno.ELOOP, name)
+	}
+}
+
 func TestRemove(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -758,6 +892,16 @@ func TestRemove(t *testing.T) {
 			filename: ".",
 			wantErr:  "base dir cannot be removed",
 		},
+		{
+			name:     "empty path",
+			filename: "",
+			wantErr:  "base dir cannot be removed",
+		},
+		{
+			name:     "root",
+			filename: "/",
+			wantErr:  "base dir cannot be removed",
+		},
 		{
 			name: "same dir file",
 			before: func(dir string) billy.Filesystem {
@@ -1268,6 +1412,50 @@ func TestMkdirAll(t *testing.T) {
 	mustExist(filepath.Join(cwd, root, "outside", "new-dir"))
 }
 
+func TestMkdirAllPerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on windows")
+	}
+
+	// Both BoundOS and ChrootOS give the dirs created exactly the
+	// permission bits requested, regardless of the umask.
+	for name, opt := range map[string]Option{"BoundOS": WithBoundOS(), "ChrootOS": WithChrootOS()} {
+		for _, mask := range []int{0o000, 0o022, 0o077} {
+			for _, perm := range []fs.FileMode{0o700, 0o750, 0o755, 0o777} {
+				t.Run(fmt.Sprintf("%s umask %03o perm %03o", name, mask, perm), func(t *testing.T) {
+					defer umask(mask)()
+
+					dir := t.TempDir()
+					bfs := New(dir, opt)
+
+					err := bfs.MkdirAll(filepath.Join("a", "b", "c"), perm|fs.ModeSticky)
+					require.NoError(t, err)
+
+					for _, name := range []string{"a", filepath.Join("a", "b"), filepath.Join("a", "b", "c")} {
+						fi, err := os.Stat(filepath.Join(dir, name))
+						require.NoError(t, err)
+						assert.Equal(t, perm, fi.Mode().Perm(), name)
+						assert.Zero(t, fi.Mode()&fs.ModeSticky, name)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestMkdirAllExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	bfs := newBoundOS(dir, true)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0o600))
+
+	err := bfs.MkdirAll("file", 0o755)
+	require.Error(t, err)
+
+	err = bfs.MkdirAll(filepath.Join("file", "dir"), 0o755)
+	require.Error(t, err)
+}
+
 func TestRename(t *testing.T) {
 	assert := assert.New(t)
 	dir := t.TempDir()
@@ -1314,3 +1502,40 @@ func notFoundError() string {
 		return "no such file or directory"
 	}
 }
+
+func TestFileNames(t *testing.T) {
+	dir := t.TempDir()
+	fs := newBoundOS(dir, true)
+
+	f, err := fs.Create(filepath.Join(dir, "foo", "bar"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("foo", "bar"), f.Name())
+	assert.Equal(t, filepath.Join(dir, "foo", "bar"), f.OpenedPath())
+	require.NoError(t, f.Close())
+
+	f, err = fs.TempFile("", "prefix")
+	require.NoError(t, err)
+	assert.False(t, filepath.IsAbs(f.Name()))
+	assert.Equal(t, f.Name(), f.OpenedPath())
+	require.NoError(t, f.Close())
+}
+
+func TestExplicitDirs(t *testing.T) {
+	fs := New(t.TempDir(), WithBoundOS(), WithExplicitDirs())
+	assert.True(t, billy.Introspect(fs).ExplicitDirs)
+
+	_, err := fs.Create("dir/foo")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.ErrorIs(t, fs.Symlink("foo", "dir/link"), os.ErrNotExist)
+
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.ErrorIs(t, fs.Rename("foo", "dir/foo"), os.ErrNotExist)
+
+	_, err = fs.Stat("dir")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, fs.MkdirAll("dir", 0o755))
+	require.NoError(t, fs.Rename("foo", "dir/foo"))
+}
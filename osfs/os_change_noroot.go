@@ -0,0 +1,50 @@
+//go:build !go1.25 && !js
+// +build !go1.25,!js
+
+package osfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Chmod implements the billy.Change interface.
+func (fs *BoundOS) Chmod(name string, mode fs.FileMode) error {
+	fn, err := fs.abs(fs.expandDot(name))
+	if err != nil {
+		return err
+	}
+	return os.Chmod(fn, mode)
+}
+
+// Lchown implements the billy.Change interface. Only the parent dirs of name
+// are resolved, so a symlink is changed itself, as long as it is within the
+// base dir.
+func (fs *BoundOS) Lchown(name string, uid, gid int) error {
+	name = filepath.Clean(fs.expandDot(name))
+	dir, err := fs.abs(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	return os.Lchown(filepath.Join(dir, filepath.Base(name)), uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (fs *BoundOS) Chown(name string, uid, gid int) error {
+	fn, err := fs.abs(fs.expandDot(name))
+	if err != nil {
+		return err
+	}
+	return os.Chown(fn, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (fs *BoundOS) Chtimes(name string, atime, mtime time.Time) error {
+	fn, err := fs.abs(fs.expandDot(name))
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(fn, atime, mtime)
+}
@@ -0,0 +1,84 @@
+//go:build go1.25 && !js
+// +build go1.25,!js
+
+package osfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Chmod implements the billy.Change interface. The change is made through
+// the os.Root of the base dir, so a symlink swapped in while it is made can
+// not make it escape the base dir.
+func (fs *BoundOS) Chmod(name string, mode fs.FileMode) error {
+	root, rel, err := fs.rootPath(name, true)
+	if err != nil {
+		return err
+	}
+	return root.Chmod(rel, mode)
+}
+
+// Lchown implements the billy.Change interface. Only the parent dirs of name
+// are resolved, so a symlink is changed itself, as long as it is within the
+// base dir.
+func (fs *BoundOS) Lchown(name string, uid, gid int) error {
+	root, rel, err := fs.rootPath(name, false)
+	if err != nil {
+		return err
+	}
+	return root.Lchown(rel, uid, gid)
+}
+
+// Chown implements the billy.Change interface. As Chmod, it is made through
+// the os.Root of the base dir.
+func (fs *BoundOS) Chown(name string, uid, gid int) error {
+	root, rel, err := fs.rootPath(name, true)
+	if err != nil {
+		return err
+	}
+	return root.Chown(rel, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface. As Chmod, it is made through
+// the os.Root of the base dir.
+func (fs *BoundOS) Chtimes(name string, atime, mtime time.Time) error {
+	root, rel, err := fs.rootPath(name, true)
+	if err != nil {
+		return err
+	}
+	return root.Chtimes(rel, atime, mtime)
+}
+
+// rootPath returns the os.Root of the base dir along with the path of name
+// relative to it. The symlinks among the parents of name are resolved, and
+// name itself too if follow is set.
+func (fs *BoundOS) rootPath(name string, follow bool) (*os.Root, string, error) {
+	name = filepath.Clean(fs.expandDot(name))
+
+	var fn string
+	var err error
+	if follow {
+		fn, err = fs.abs(name)
+	} else {
+		fn, err = fs.abs(filepath.Dir(name))
+		fn = filepath.Join(fn, filepath.Base(name))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	rel, err := filepath.Rel(fs.baseDir, fn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	root, err := fs.openRoot()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return root, rel, nil
+}
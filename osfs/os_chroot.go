@@ -7,13 +7,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/helper/chroot"
 )
 
 // ChrootOS is a legacy filesystem based on a "soft chroot" of the os filesystem.
-// Although this is still the default os filesystem, consider using BoundOS instead.
+// It is no longer the default os filesystem, see NewLegacyChroot, and BoundOS
+// should be preferred.
 //
 // Behaviours of note:
 //  1. A "soft chroot" translates the base dir to "/" for the purposes of the
@@ -22,14 +24,27 @@ import (
 //  3. Some file modes does not pass-through the fs abstraction.
 //  4. The combination of 1 and 2 may cause go-git to think that a Git repository
 //     is dirty, when in fact it isn't.
-type ChrootOS struct{}
+type ChrootOS struct {
+	fileMode        fs.FileMode
+	dirMode         fs.FileMode
+	longPaths       bool
+	unsortedReadDir bool
+	anonymousTemp   bool
+	explicitDirs    bool
+}
 
 func newChrootOS(baseDir string) billy.Filesystem {
 	return chroot.New(&ChrootOS{}, baseDir)
 }
 
+// String names the filesystem. A ChrootOS has no base dir of its own, it is
+// set by the chroot wrapping it.
+func (fs *ChrootOS) String() string {
+	return "osfs.ChrootOS"
+}
+
 func (fs *ChrootOS) Create(filename string) (billy.File, error) {
-	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, defaultCreateMode)
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, orDefault(fs.fileMode, defaultCreateMode))
 }
 
 func (fs *ChrootOS) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
@@ -37,9 +52,13 @@ func (fs *ChrootOS) OpenFile(filename string, flag int, perm fs.FileMode) (billy
 }
 
 func (fs *ChrootOS) createDir(fullpath string) error {
+	if fs.explicitDirs {
+		return nil
+	}
+
 	dir := filepath.Dir(fullpath)
 	if dir != "." {
-		if err := os.MkdirAll(dir, defaultDirectoryMode); err != nil {
+		if err := os.MkdirAll(dir, orDefault(fs.dirMode, defaultDirectoryMode)); err != nil {
 			return err
 		}
 	}
@@ -48,7 +67,27 @@ func (fs *ChrootOS) createDir(fullpath string) error {
 }
 
 func (fs *ChrootOS) ReadDir(dir string) ([]os.FileInfo, error) {
-	return readDir(dir)
+	return readDir(dir, fs.unsortedReadDir)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (fs *ChrootOS) ReadDirNames(dir string, n int) ([]string, error) {
+	return readDirNames(dir, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (fs *ChrootOS) ReadDirEntries(dir string) ([]fs.DirEntry, error) {
+	return readDirEntries(dir)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (fs *ChrootOS) OpenDir(dir string) (billy.DirIter, error) {
+	return openDir(dir)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (fs *ChrootOS) SyncDir(dir string) error {
+	return syncDir(dir)
 }
 
 func (fs *ChrootOS) Rename(from, to string) error {
@@ -59,8 +98,28 @@ func (fs *ChrootOS) Rename(from, to string) error {
 	return rename(from, to)
 }
 
-func (fs *ChrootOS) MkdirAll(path string, _ os.FileMode) error {
-	return os.MkdirAll(path, defaultDirectoryMode)
+// RenameNoReplace implements the billy.Renamer interface. It is not supported
+// on platforms other than Linux and macOS.
+func (fs *ChrootOS) RenameNoReplace(from, to string) error {
+	if err := fs.createDir(to); err != nil {
+		return err
+	}
+
+	return renameNoReplace(from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface. It is not supported
+// on platforms other than Linux and macOS.
+func (fs *ChrootOS) RenameExchange(from, to string) error {
+	return renameExchange(from, to)
+}
+
+// MkdirAll creates the dir path along with its missing parents, like
+// os.MkdirAll, but with exactly the permission bits of perm, regardless of
+// the umask, as BoundOS does. The other bits of perm, such as
+// fs.ModeSticky, are ignored.
+func (fs *ChrootOS) MkdirAll(path string, perm os.FileMode) error {
+	return mkdirAll(path, perm)
 }
 
 func (fs *ChrootOS) Open(filename string) (billy.File, error) {
@@ -71,16 +130,39 @@ func (fs *ChrootOS) Stat(filename string) (os.FileInfo, error) {
 	return os.Stat(filename)
 }
 
+// StatMany implements the billy.BatchStater interface.
+func (fs *ChrootOS) StatMany(paths []string) ([]os.FileInfo, []error) {
+	return statMany(paths, func(path string) (string, string, bool) {
+		return splitStatPath(filepath.Clean(path))
+	}, fs.Stat)
+}
+
 func (fs *ChrootOS) Remove(filename string) error {
 	return os.Remove(filename)
 }
 
 func (fs *ChrootOS) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.CreateTemp(dir, prefix+"*")
+}
+
+func (fs *ChrootOS) TempDir(dir, prefix string) (string, error) {
+	return fs.MkdirTemp(dir, prefix+"*")
+}
+
+func (fs *ChrootOS) CreateTemp(dir, pattern string) (billy.File, error) {
 	if err := fs.createDir(dir + string(os.PathSeparator)); err != nil {
 		return nil, err
 	}
 
-	return tempFile(dir, prefix)
+	return newTempFile(dir, pattern, fs.anonymousTemp)
+}
+
+func (fs *ChrootOS) MkdirTemp(dir, pattern string) (string, error) {
+	if err := fs.createDir(dir + string(os.PathSeparator)); err != nil {
+		return "", err
+	}
+
+	return tempDir(dir, pattern)
 }
 
 func (fs *ChrootOS) Join(elem ...string) string {
@@ -107,7 +189,55 @@ func (fs *ChrootOS) Readlink(link string) (string, error) {
 	return os.Readlink(link)
 }
 
-// Capabilities implements the Capable interface.
+// Chmod implements the billy.Change interface.
+func (fs *ChrootOS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (fs *ChrootOS) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (fs *ChrootOS) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (fs *ChrootOS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// GetXattr implements the billy.Xattr interface.
+func (fs *ChrootOS) GetXattr(path, name string) ([]byte, error) {
+	return getXattr(path, name)
+}
+
+// SetXattr implements the billy.Xattr interface.
+func (fs *ChrootOS) SetXattr(path, name string, value []byte) error {
+	return setXattr(path, name, value)
+}
+
+// ListXattr implements the billy.Xattr interface.
+func (fs *ChrootOS) ListXattr(path string) ([]string, error) {
+	return listXattr(path)
+}
+
+// RemoveXattr implements the billy.Xattr interface.
+func (fs *ChrootOS) RemoveXattr(path, name string) error {
+	return removeXattr(path, name)
+}
+
+// Capabilities implements the Capable interface. The capabilities depend on
+// the OS, see the capabilities const of each of them.
 func (fs *ChrootOS) Capabilities() billy.Capability {
-	return billy.DefaultCapabilities
+	return capabilities
+}
+
+// PathProperties implements the Introspectable interface.
+func (fs *ChrootOS) PathProperties() billy.PathProperties {
+	p := pathProperties(fs.longPaths)
+	p.ExplicitDirs = fs.explicitDirs
+	return p
 }
@@ -4,14 +4,18 @@
 package osfs
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v6"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
 )
 
 func setup(t *testing.T) (billy.Filesystem, string) {
@@ -40,10 +44,280 @@ func TestOpenDoesNotCreateDir(t *testing.T) {
 }
 
 func TestCapabilities(t *testing.T) {
+	want := billy.AllCapabilities
+	if runtime.GOOS == "plan9" {
+		want &^= billy.SymlinkCapability | billy.LockCapability
+	}
+
+	for _, opt := range []Option{WithChrootOS(), WithBoundOS()} {
+		fs := New(t.TempDir(), opt)
+		_, ok := fs.(billy.Capable)
+		assert.True(t, ok)
+
+		caps := billy.Capabilities(fs)
+		assert.Equal(t, want, caps)
+	}
+}
+
+func TestPathProperties(t *testing.T) {
 	fs, _ := setup(t)
-	_, ok := fs.(billy.Capable)
+	_, ok := fs.(billy.Introspectable)
 	assert.True(t, ok)
 
-	caps := billy.Capabilities(fs)
-	assert.Equal(t, billy.AllCapabilities, caps)
+	props := billy.Introspect(fs)
+	assert.Equal(t, runtime.GOOS != "windows" && runtime.GOOS != "darwin", props.CaseSensitive)
+}
+
+func TestStrictChrootBoundaryErrors(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "outside"), nil, 0o600)
+	require.NoError(t, err)
+	base := filepath.Join(dir, "base")
+	require.NoError(t, os.Mkdir(base, 0o700))
+
+	tests := []struct {
+		name string
+		fs   billy.Filesystem
+		path string
+	}{
+		{name: "ChrootOS", fs: New(base, WithChrootOS(), WithStrictChroot()), path: "../outside"},
+		{name: "BoundOS", fs: New(base, WithBoundOS()), path: filepath.Join(dir, "outside")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.fs.Lstat(tt.path)
+			require.ErrorIs(t, err, ErrPathEscapesParent)
+			require.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+			_, err = tt.fs.Readlink(tt.path)
+			require.ErrorIs(t, err, ErrPathEscapesParent)
+			require.ErrorIs(t, err, billy.ErrCrossedBoundary)
+		})
+	}
+}
+
+func TestChrootBoundaryErrors(t *testing.T) {
+	fs, _ := setup(t)
+	_, err := fs.Stat("../outside")
+	assert.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+	strict, err := NewLegacyChroot(t.TempDir(), WithStrictChroot()).Chroot("foo")
+	require.NoError(t, err)
+	_, err = strict.Stat("../outside")
+	assert.ErrorIs(t, err, ErrPathEscapesParent)
+}
+
+func TestEscapeHandler(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires privileges on windows")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outside"), []byte("foo"), 0o600))
+	base := filepath.Join(dir, "base")
+	require.NoError(t, os.Mkdir(base, 0o700))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "outside"), filepath.Join(base, "link")))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "inside"), nil, 0o600))
+
+	var escapes []*EscapeError
+	fs := NewLegacyChroot(base, WithEscapeHandler(func(e *EscapeError) error {
+		escapes = append(escapes, e)
+		return nil
+	}))
+
+	_, err := fs.Stat("inside")
+	require.NoError(t, err)
+	_, err = fs.Lstat("link")
+	require.NoError(t, err)
+	assert.Empty(t, escapes)
+
+	fi, err := fs.Stat("link")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+	require.Len(t, escapes, 1)
+	assert.Equal(t, "stat", escapes[0].Op)
+	assert.Equal(t, filepath.Join(base, "link"), escapes[0].Path)
+	assert.Equal(t, "outside", filepath.Base(escapes[0].Resolved))
+
+	fs = NewLegacyChroot(base, WithEscapeHandler(func(e *EscapeError) error {
+		return e
+	}))
+	_, err = fs.Open("link")
+	require.ErrorIs(t, err, ErrPathEscapesParent)
+	require.ErrorIs(t, err, billy.ErrCrossedBoundary)
+
+	var escape *EscapeError
+	require.ErrorAs(t, err, &escape)
+	assert.Equal(t, "open", escape.Op)
+}
+
+func TestEscapeHandlerChecksEveryPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires privileges on windows")
+	}
+
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.Mkdir(outside, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "file"), []byte("foo"), 0o600))
+	base := filepath.Join(dir, "base")
+	require.NoError(t, os.Mkdir(base, 0o700))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "file"), filepath.Join(base, "link")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(base, "dirlink")))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "inside"), nil, 0o600))
+
+	var escapes []*EscapeError
+	fs := NewLegacyChroot(base, WithEscapeHandler(func(e *EscapeError) error {
+		escapes = append(escapes, e)
+		return e
+	}))
+
+	now := time.Now()
+	tests := []struct {
+		op   string
+		call func() error
+	}{
+		{"chmod", func() error { return fs.(billy.Change).Chmod("link", 0o600) }},
+		{"chown", func() error { return fs.(billy.Change).Chown("link", os.Getuid(), os.Getgid()) }},
+		{"lchown", func() error { return fs.(billy.Change).Lchown("dirlink/file", os.Getuid(), os.Getgid()) }},
+		{"chtimes", func() error { return fs.(billy.Change).Chtimes("link", now, now) }},
+		{"lchmod", func() error { return fs.(billy.SymlinkChange).Lchmod("dirlink/file", 0o600) }},
+		{"lchtimes", func() error { return fs.(billy.SymlinkChange).Lchtimes("dirlink/file", now, now) }},
+		{"rename", func() error { return fs.(billy.Renamer).RenameNoReplace("dirlink/file", "moved") }},
+		{"rename", func() error { return fs.(billy.Renamer).RenameExchange("inside", "dirlink/file") }},
+		{"getxattr", func() error {
+			_, err := fs.(billy.Xattr).GetXattr("link", "user.foo")
+			return err
+		}},
+		{"setxattr", func() error { return fs.(billy.Xattr).SetXattr("link", "user.foo", []byte("bar")) }},
+		{"listxattr", func() error {
+			_, err := fs.(billy.Xattr).ListXattr("link")
+			return err
+		}},
+		{"removexattr", func() error { return fs.(billy.Xattr).RemoveXattr("link", "user.foo") }},
+		{"readdir", func() error {
+			_, err := fs.(billy.DirNames).ReadDirNames("dirlink", 0)
+			return err
+		}},
+		{"readdir", func() error {
+			_, err := fs.(billy.DirEntries).ReadDirEntries("dirlink")
+			return err
+		}},
+		{"opendir", func() error {
+			_, err := fs.(billy.DirOpener).OpenDir("dirlink")
+			return err
+		}},
+		{"syncdir", func() error { return fs.(billy.DirSyncer).SyncDir("dirlink") }},
+		{"stat", func() error {
+			_, errs := fs.(billy.BatchStater).StatMany([]string{"inside", "link"})
+			require.NoError(t, errs[0])
+			return errs[1]
+		}},
+		{"open", func() error { return fs.(billy.Copier).CopyFile("link", "copy") }},
+	}
+
+	for _, tt := range tests {
+		escapes = nil
+		err := tt.call()
+		require.ErrorIs(t, err, ErrPathEscapesParent, tt.op)
+		require.Len(t, escapes, 1, tt.op)
+		assert.Equal(t, tt.op, escapes[0].Op)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outside, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+	_, err = os.Lstat(filepath.Join(base, "moved"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Lstat(filepath.Join(base, "copy"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadDirNames(t *testing.T) {
+	for _, fs := range []billy.Filesystem{
+		New(t.TempDir(), WithChrootOS()),
+		New(t.TempDir(), WithBoundOS()),
+	} {
+		for _, name := range []string{"foo", "bar", "qux"} {
+			f, err := fs.Create(fs.Join("dir", name))
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		}
+
+		names, err := fs.(billy.DirNames).ReadDirNames("dir", 0)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"foo", "bar", "qux"}, names)
+
+		names, err = fs.(billy.DirNames).ReadDirNames("dir", 1)
+		require.NoError(t, err)
+		assert.Len(t, names, 1)
+
+		_, err = fs.(billy.DirNames).ReadDirNames("missing", 0)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	for _, fs := range []billy.Filesystem{
+		New(t.TempDir(), WithChrootOS()),
+		New(t.TempDir(), WithBoundOS()),
+	} {
+		var want []string
+		for i := 0; i < dirIterBatch+10; i++ {
+			name := fmt.Sprintf("file%03d", i)
+			f, err := fs.Create(fs.Join("dir", name))
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			want = append(want, name)
+		}
+
+		it, err := fs.(billy.DirOpener).OpenDir("dir")
+		require.NoError(t, err)
+
+		var got []string
+		for {
+			e, err := it.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			got = append(got, e.Name())
+		}
+		require.NoError(t, it.Close())
+		assert.ElementsMatch(t, want, got)
+
+		_, err = fs.(billy.DirOpener).OpenDir("missing")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func TestReadDirEntries(t *testing.T) {
+	for _, fs := range []billy.Filesystem{
+		New(t.TempDir(), WithChrootOS()),
+		New(t.TempDir(), WithBoundOS()),
+	} {
+		for _, name := range []string{"foo", "bar"} {
+			f, err := fs.Create(fs.Join("dir", name))
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+		}
+		require.NoError(t, fs.MkdirAll(fs.Join("dir", "qux"), 0o755))
+
+		entries, err := fs.(billy.DirEntries).ReadDirEntries("dir")
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+
+		for i, name := range []string{"bar", "foo", "qux"} {
+			assert.Equal(t, name, entries[i].Name())
+			assert.Equal(t, name == "qux", entries[i].IsDir())
+
+			fi, err := entries[i].Info()
+			require.NoError(t, err)
+			assert.Equal(t, name, fi.Name())
+		}
+
+		_, err = fs.(billy.DirEntries).ReadDirEntries("missing")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
 }
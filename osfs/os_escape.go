@@ -0,0 +1,465 @@
+//go:build !js
+// +build !js
+
+package osfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// maxEscapeSymlinks is the number of symlinks followed when checking whether
+// a path escapes the base dir, past which the path is not checked.
+const maxEscapeSymlinks = 255
+
+// EscapeError is reported by WithEscapeHandler for the operations of a
+// ChrootOS filesystem over a path which is within the base dir lexically,
+// but resolves out of it through symlinks.
+type EscapeError struct {
+	// Op is the operation, such as "open" or "rename".
+	Op string
+	// Path is the path the operation was made over, joined to the base dir.
+	Path string
+	// Resolved is the path it resolves to, out of the base dir.
+	Resolved string
+}
+
+func (e *EscapeError) Error() string {
+	return fmt.Sprintf("%s %s: resolves to %s: %v", e.Op, e.Path, e.Resolved, ErrPathEscapesParent)
+}
+
+// Unwrap returns ErrPathEscapesParent, the error BoundOS would have returned.
+func (e *EscapeError) Unwrap() error {
+	return ErrPathEscapesParent
+}
+
+// escapeChecker wraps a ChrootOS, reporting the paths resolving out of
+// baseDir to a handler before operating on them. Every method taking a path
+// is checked, so none of them can reach the ChrootOS unchecked.
+type escapeChecker struct {
+	fs      *ChrootOS
+	baseDir string
+	handler func(*EscapeError) error
+}
+
+func newEscapeChecker(fs *ChrootOS, baseDir string, handler func(*EscapeError) error) *escapeChecker {
+	return &escapeChecker{fs: fs, baseDir: baseDir, handler: handler}
+}
+
+func (fs *escapeChecker) String() string {
+	return fs.fs.String()
+}
+
+func (fs *escapeChecker) Create(filename string) (billy.File, error) {
+	if err := fs.check("open", filename, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Create(filename)
+}
+
+func (fs *escapeChecker) Open(filename string) (billy.File, error) {
+	if err := fs.check("open", filename, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Open(filename)
+}
+
+func (fs *escapeChecker) OpenFile(filename string, flag int, perm fs.FileMode) (billy.File, error) {
+	if err := fs.check("open", filename, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.OpenFile(filename, flag, perm)
+}
+
+// Mmap implements the billy.Mapper interface.
+func (fs *escapeChecker) Mmap(path string) (billy.Mapping, error) {
+	if err := fs.check("mmap", path, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Mmap(path)
+}
+
+func (fs *escapeChecker) Stat(filename string) (os.FileInfo, error) {
+	if err := fs.check("stat", filename, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Stat(filename)
+}
+
+// StatMany implements the billy.BatchStater interface. Only the paths
+// accepted by the handler are statted.
+func (fs *escapeChecker) StatMany(paths []string) ([]os.FileInfo, []error) {
+	infos := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	var checked []string
+	var indexes []int
+	for i, path := range paths {
+		if err := fs.check("stat", path, true); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		checked = append(checked, path)
+		indexes = append(indexes, i)
+	}
+
+	checkedInfos, checkedErrs := fs.fs.StatMany(checked)
+	for j, i := range indexes {
+		infos[i], errs[i] = checkedInfos[j], checkedErrs[j]
+	}
+
+	return infos, errs
+}
+
+func (fs *escapeChecker) Lstat(filename string) (os.FileInfo, error) {
+	if err := fs.check("lstat", filename, false); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Lstat(filename)
+}
+
+func (fs *escapeChecker) Rename(from, to string) error {
+	if err := fs.checkRename("rename", from, to); err != nil {
+		return err
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+// RenameNoReplace implements the billy.Renamer interface.
+func (fs *escapeChecker) RenameNoReplace(from, to string) error {
+	if err := fs.checkRename("rename", from, to); err != nil {
+		return err
+	}
+
+	return fs.fs.RenameNoReplace(from, to)
+}
+
+// RenameExchange implements the billy.Renamer interface.
+func (fs *escapeChecker) RenameExchange(from, to string) error {
+	if err := fs.checkRename("rename", from, to); err != nil {
+		return err
+	}
+
+	return fs.fs.RenameExchange(from, to)
+}
+
+func (fs *escapeChecker) Remove(filename string) error {
+	if err := fs.check("remove", filename, false); err != nil {
+		return err
+	}
+
+	return fs.fs.Remove(filename)
+}
+
+func (fs *escapeChecker) RemoveAll(path string) error {
+	if err := fs.check("removeall", path, false); err != nil {
+		return err
+	}
+
+	return fs.fs.RemoveAll(path)
+}
+
+func (fs *escapeChecker) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+func (fs *escapeChecker) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.check("tempfile", dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.TempFile(dir, prefix)
+}
+
+func (fs *escapeChecker) TempDir(dir, prefix string) (string, error) {
+	if err := fs.check("tempdir", dir, true); err != nil {
+		return "", err
+	}
+
+	return fs.fs.TempDir(dir, prefix)
+}
+
+func (fs *escapeChecker) CreateTemp(dir, pattern string) (billy.File, error) {
+	if err := fs.check("createtemp", dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.CreateTemp(dir, pattern)
+}
+
+func (fs *escapeChecker) MkdirTemp(dir, pattern string) (string, error) {
+	if err := fs.check("mkdirtemp", dir, true); err != nil {
+		return "", err
+	}
+
+	return fs.fs.MkdirTemp(dir, pattern)
+}
+
+func (fs *escapeChecker) ReadDir(dir string) ([]os.FileInfo, error) {
+	if err := fs.check("readdir", dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.ReadDir(dir)
+}
+
+// ReadDirNames implements the billy.DirNames interface.
+func (fs *escapeChecker) ReadDirNames(dir string, n int) ([]string, error) {
+	if err := fs.check("readdir", dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.ReadDirNames(dir, n)
+}
+
+// ReadDirEntries implements the billy.DirEntries interface.
+func (fs *escapeChecker) ReadDirEntries(dir string) ([]fs.DirEntry, error) {
+	if err := fs.check("readdir", dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.ReadDirEntries(dir)
+}
+
+// OpenDir implements the billy.DirOpener interface.
+func (fs *escapeChecker) OpenDir(dir string) (billy.DirIter, error) {
+	if err := fs.check("opendir", dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.OpenDir(dir)
+}
+
+// SyncDir implements the billy.DirSyncer interface.
+func (fs *escapeChecker) SyncDir(dir string) error {
+	if err := fs.check("syncdir", dir, true); err != nil {
+		return err
+	}
+
+	return fs.fs.SyncDir(dir)
+}
+
+func (fs *escapeChecker) MkdirAll(path string, perm fs.FileMode) error {
+	if err := fs.check("mkdir", path, true); err != nil {
+		return err
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+func (fs *escapeChecker) Symlink(target, link string) error {
+	if err := fs.check("symlink", link, false); err != nil {
+		return err
+	}
+
+	return fs.fs.Symlink(target, link)
+}
+
+func (fs *escapeChecker) Readlink(link string) (string, error) {
+	if err := fs.check("readlink", link, false); err != nil {
+		return "", err
+	}
+
+	return fs.fs.Readlink(link)
+}
+
+// Chmod implements the billy.Change interface.
+func (fs *escapeChecker) Chmod(name string, mode fs.FileMode) error {
+	if err := fs.check("chmod", name, true); err != nil {
+		return err
+	}
+
+	return fs.fs.Chmod(name, mode)
+}
+
+// Lchown implements the billy.Change interface.
+func (fs *escapeChecker) Lchown(name string, uid, gid int) error {
+	if err := fs.check("lchown", name, false); err != nil {
+		return err
+	}
+
+	return fs.fs.Lchown(name, uid, gid)
+}
+
+// Chown implements the billy.Change interface.
+func (fs *escapeChecker) Chown(name string, uid, gid int) error {
+	if err := fs.check("chown", name, true); err != nil {
+		return err
+	}
+
+	return fs.fs.Chown(name, uid, gid)
+}
+
+// Chtimes implements the billy.Change interface.
+func (fs *escapeChecker) Chtimes(name string, atime, mtime time.Time) error {
+	if err := fs.check("chtimes", name, true); err != nil {
+		return err
+	}
+
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Lchmod implements the billy.SymlinkChange interface.
+func (fs *escapeChecker) Lchmod(name string, mode fs.FileMode) error {
+	if err := fs.check("lchmod", name, false); err != nil {
+		return err
+	}
+
+	return fs.fs.Lchmod(name, mode)
+}
+
+// Lchtimes implements the billy.SymlinkChange interface.
+func (fs *escapeChecker) Lchtimes(name string, atime, mtime time.Time) error {
+	if err := fs.check("lchtimes", name, false); err != nil {
+		return err
+	}
+
+	return fs.fs.Lchtimes(name, atime, mtime)
+}
+
+// GetXattr implements the billy.Xattr interface.
+func (fs *escapeChecker) GetXattr(path, name string) ([]byte, error) {
+	if err := fs.check("getxattr", path, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.GetXattr(path, name)
+}
+
+// SetXattr implements the billy.Xattr interface.
+func (fs *escapeChecker) SetXattr(path, name string, value []byte) error {
+	if err := fs.check("setxattr", path, true); err != nil {
+		return err
+	}
+
+	return fs.fs.SetXattr(path, name, value)
+}
+
+// ListXattr implements the billy.Xattr interface.
+func (fs *escapeChecker) ListXattr(path string) ([]string, error) {
+	if err := fs.check("listxattr", path, true); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.ListXattr(path)
+}
+
+// RemoveXattr implements the billy.Xattr interface.
+func (fs *escapeChecker) RemoveXattr(path, name string) error {
+	if err := fs.check("removexattr", path, true); err != nil {
+		return err
+	}
+
+	return fs.fs.RemoveXattr(path, name)
+}
+
+// Capabilities implements the Capable interface.
+func (fs *escapeChecker) Capabilities() billy.Capability {
+	return fs.fs.Capabilities()
+}
+
+// PathProperties implements the Introspectable interface.
+func (fs *escapeChecker) PathProperties() billy.PathProperties {
+	return fs.fs.PathProperties()
+}
+
+// checkRename checks both paths of a rename, neither of which is followed.
+func (fs *escapeChecker) checkRename(op, from, to string) error {
+	if err := fs.check(op, from, false); err != nil {
+		return err
+	}
+
+	return fs.check(op, to, false)
+}
+
+// check calls the handler if path resolves out of the base dir, returning the
+// error it returns. If follow is false, the last element of path is not
+// evaluated.
+func (fs *escapeChecker) check(op, path string, follow bool) error {
+	base, ok := evalExisting(fs.baseDir)
+	if !ok {
+		return nil
+	}
+
+	resolved, ok := resolve(path, follow)
+	if !ok || isWithin(base, resolved) {
+		return nil
+	}
+
+	return fs.handler(&EscapeError{Op: op, Path: path, Resolved: resolved})
+}
+
+// resolve returns path once its symlinks are evaluated, as far as they
+// exist. If follow is false, the last element of path is not evaluated.
+func resolve(path string, follow bool) (string, bool) {
+	for i := 0; i < maxEscapeSymlinks; i++ {
+		dir, name := filepath.Split(filepath.Clean(path))
+		dir, ok := evalExisting(dir)
+		if !ok {
+			return "", false
+		}
+
+		resolved := filepath.Join(dir, name)
+		if !follow {
+			return resolved, true
+		}
+
+		target, err := os.Readlink(resolved)
+		if err != nil {
+			return resolved, true
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		path = target
+	}
+
+	return "", false
+}
+
+// evalExisting evaluates the symlinks of path, whose last elements may not
+// exist yet.
+func evalExisting(path string) (string, bool) {
+	path = filepath.Clean(path)
+
+	var rest string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), true
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", false
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", false
+		}
+
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// isWithin reports whether path is dir or one of its descendants.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
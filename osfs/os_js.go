@@ -4,6 +4,9 @@
 package osfs
 
 import (
+	"io/fs"
+	"os"
+
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/helper/chroot"
 	"github.com/go-git/go-billy/v6/memfs"
@@ -21,5 +24,17 @@ func New(baseDir string, _ ...Option) billy.Filesystem {
 	return chroot.New(Default, Default.Join("/", baseDir))
 }
 
+// AsRoot always returns false, as the js filesystem is not backed by the os
+// filesystem.
+func AsRoot(_ billy.Filesystem) (*os.Root, bool) {
+	return nil, false
+}
+
+// AsFS returns a view of fs as an io/fs filesystem, as memfs.AsFS does for
+// the in-memory filesystem backing the js filesystem.
+func AsFS(fs billy.Filesystem) (fs.FS, bool) {
+	return memfs.AsFS(fs)
+}
+
 type options struct {
 }
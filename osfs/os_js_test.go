@@ -11,7 +11,7 @@ import (
 
 	"github.com/go-git/go-billy/v6"
 	"github.com/go-git/go-billy/v6/helper/chroot"
-	"github.com/stretchr/testify/assert"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
 )
 
 func TestOpenDoesNotCreateDir(t *testing.T) {
@@ -30,7 +30,7 @@ func TestCapabilities(t *testing.T) {
 	assert.True(t, ok)
 
 	caps := billy.Capabilities(fs)
-	assert.Equal(t, billy.DefaultCapabilities&^billy.LockCapability, caps)
+	assert.Equal(t, billy.AllCapabilities&^billy.LockCapability, caps)
 }
 
 func TestDefault(t *testing.T) {
@@ -0,0 +1,99 @@
+//go:build !js
+// +build !js
+
+package osfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Lchmod implements the billy.SymlinkChange interface. Changing the mode of
+// a symlink is only supported on macOS.
+func (fs *ChrootOS) Lchmod(name string, mode fs.FileMode) error {
+	return lchmod(name, mode)
+}
+
+// Lchtimes implements the billy.SymlinkChange interface. Changing the times
+// of a symlink is supported on Linux and macOS.
+func (fs *ChrootOS) Lchtimes(name string, atime, mtime time.Time) error {
+	return lchtimes(name, atime, mtime)
+}
+
+// Lchmod implements the billy.SymlinkChange interface. As Lchown, only the
+// parent dirs of name are resolved. Changing the mode of a symlink is only
+// supported on macOS.
+func (fs *BoundOS) Lchmod(name string, mode fs.FileMode) error {
+	fn, err := fs.linkPath(name)
+	if err != nil {
+		return err
+	}
+	return lchmod(fn, mode)
+}
+
+// Lchtimes implements the billy.SymlinkChange interface. As Lchown, only the
+// parent dirs of name are resolved. Changing the times of a symlink is
+// supported on Linux and macOS.
+func (fs *BoundOS) Lchtimes(name string, atime, mtime time.Time) error {
+	fn, err := fs.linkPath(name)
+	if err != nil {
+		return err
+	}
+	return lchtimes(fn, atime, mtime)
+}
+
+// linkPath returns the absolute path of name, resolving its parent dirs but
+// not name itself, so that a symlink is not followed.
+func (fs *BoundOS) linkPath(name string) (string, error) {
+	name = filepath.Clean(fs.expandDot(name))
+	dir, err := fs.abs(filepath.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(name)), nil
+}
+
+// lchmod changes the mode of the named file, or of the symlink itself if it
+// is one and the platform allows it.
+func lchmod(name string, mode fs.FileMode) error {
+	link, err := isLink(name)
+	if err != nil {
+		return err
+	}
+	if !link {
+		return os.Chmod(name, mode)
+	}
+
+	if err := lchmodLink(name, mode); err != nil {
+		return &os.PathError{Op: "lchmod", Path: name, Err: err}
+	}
+	return nil
+}
+
+// lchtimes changes the times of the named file, or of the symlink itself if
+// it is one and the platform allows it.
+func lchtimes(name string, atime, mtime time.Time) error {
+	link, err := isLink(name)
+	if err != nil {
+		return err
+	}
+	if !link {
+		return os.Chtimes(name, atime, mtime)
+	}
+
+	if err := lchtimesLink(name, atime, mtime); err != nil {
+		return &os.PathError{Op: "lchtimes", Path: name, Err: err}
+	}
+	return nil
+}
+
+// isLink reports whether the named file is a symlink.
+func isLink(name string) (bool, error) {
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return false, err
+	}
+	return fi.Mode()&os.ModeSymlink != 0, nil
+}
@@ -0,0 +1,15 @@
+package osfs
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// utimeOmit is UTIME_OMIT, which x/sys does not define on darwin.
+const utimeOmit = -0x2
+
+// lchmodLink changes the mode of the symlink name itself.
+func lchmodLink(name string, mode fs.FileMode) error {
+	return unix.Fchmodat(unix.AT_FDCWD, name, uint32(mode.Perm()), unix.AT_SYMLINK_NOFOLLOW)
+}
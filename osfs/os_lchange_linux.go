@@ -0,0 +1,15 @@
+package osfs
+
+import (
+	"io/fs"
+
+	"github.com/go-git/go-billy/v6"
+	"golang.org/x/sys/unix"
+)
+
+const utimeOmit = unix.UTIME_OMIT
+
+// lchmodLink fails, as symlinks have no mode of their own on Linux.
+func lchmodLink(_ string, _ fs.FileMode) error {
+	return billy.ErrNotSupported
+}
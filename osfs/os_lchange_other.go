@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !js
+// +build !linux,!darwin,!js
+
+package osfs
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// lchmodLink fails, as changing the mode of symlinks is not supported on
+// this platform.
+func lchmodLink(_ string, _ fs.FileMode) error {
+	return billy.ErrNotSupported
+}
+
+// lchtimesLink fails, as changing the times of symlinks is not supported on
+// this platform.
+func lchtimesLink(_ string, _, _ time.Time) error {
+	return billy.ErrNotSupported
+}
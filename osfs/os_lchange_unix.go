@@ -0,0 +1,25 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package osfs
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lchtimesLink changes the times of the symlink name itself.
+func lchtimesLink(name string, atime, mtime time.Time) error {
+	ts := []unix.Timespec{timespec(atime), timespec(mtime)}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, name, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// timespec returns t as a unix.Timespec, leaving the time unchanged if t is
+// the zero time, as os.Chtimes does.
+func timespec(t time.Time) unix.Timespec {
+	if t.IsZero() {
+		return unix.Timespec{Nsec: utimeOmit}
+	}
+	return unix.NsecToTimespec(t.UnixNano())
+}
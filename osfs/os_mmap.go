@@ -0,0 +1,71 @@
+//go:build !js
+// +build !js
+
+package osfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+// errMapTooLarge is returned when mapping a file larger than the address
+// space, which can only happen on 32-bit platforms.
+var errMapTooLarge = errors.New("file too large to map")
+
+// Mmap implements the billy.Mapper interface, mapping the file in memory
+// with mmap, or MapViewOfFile on Windows. On the OSes without them, such as
+// Plan 9 and WASI, the file is read fully in memory instead.
+func (fs *BoundOS) Mmap(path string) (billy.Mapping, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return mmapFile(f.(*file).File)
+}
+
+// Mmap implements the billy.Mapper interface. See BoundOS.Mmap.
+func (fs *ChrootOS) Mmap(path string) (billy.Mapping, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return mmapFile(f.(*file).File)
+}
+
+// mmapFile maps the content of f in memory. The mapping outlives f, which
+// can be closed right away.
+func mmapFile(f *os.File) (billy.Mapping, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: syscall.EISDIR}
+	}
+
+	// Empty files cannot be mapped.
+	size := fi.Size()
+	if size == 0 {
+		return util.NewMapping(nil, nil), nil
+	}
+
+	if int64(int(size)) != size {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: errMapTooLarge}
+	}
+
+	data, release, err := mmap(f, int(size))
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+
+	return util.NewMapping(data, release), nil
+}
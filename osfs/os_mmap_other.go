@@ -0,0 +1,22 @@
+//go:build !unix && !windows && !js
+// +build !unix,!windows,!js
+
+package osfs
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// mmap reads the first size bytes of f, as the OS cannot map files in
+// memory.
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	n, err := f.ReadAt(data, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+
+	return data[:n], nil, nil
+}
@@ -0,0 +1,21 @@
+//go:build unix
+// +build unix
+
+package osfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmap maps the first size bytes of f in memory, read-only, returning the
+// func unmapping them.
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return unix.Munmap(data) }, nil
+}
@@ -0,0 +1,34 @@
+//go:build windows
+// +build windows
+
+package osfs
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// mmap maps the first size bytes of f in memory, read-only, returning the
+// func unmapping them. The file mapping object is closed right away, as the
+// view keeps it alive.
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	h, err := windows.CreateFileMapping(windows.Handle(f.Fd()), nil, windows.PAGE_READONLY,
+		uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer windows.CloseHandle(h)
+
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The view is not managed by the Go runtime, so its address is
+	// converted through a pointer to it, which go vet accepts.
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	data := unsafe.Slice((*byte)(ptr), size)
+	return data, func() error { return windows.UnmapViewOfFile(addr) }, nil
+}
@@ -8,8 +8,24 @@ import (
 	"os"
 	"path/filepath"
 	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
 )
 
+// capabilities exclude symlinks, which do not exist on Plan 9, and locking,
+// see Lock.
+const capabilities = billy.WriteCapability | billy.ReadCapability |
+	billy.ReadAndWriteCapability | billy.SeekCapability |
+	billy.TruncateCapability | billy.ChangeCapability |
+	billy.RemoveAllCapability
+
+// secureJoin joins root and unsafePath lexically, which is enough to keep the
+// result inside root as there are no symlinks to evaluate on Plan 9.
+func secureJoin(root, unsafePath string) (string, error) {
+	return filepath.Join(root, filepath.Clean(string(filepath.Separator)+unsafePath)), nil
+}
+
 func (f *file) Lock() error {
 	// Plan 9 uses a mode bit instead of explicit lock/unlock syscalls.
 	//
@@ -27,28 +43,52 @@ func (f *file) Unlock() error {
 	return nil
 }
 
+// Chtimes implements the billy.FileChange interface, using fwstat. As with
+// os.Chtimes, zero times are left unchanged.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	var d syscall.Dir
+	d.Null()
+	if !atime.IsZero() {
+		d.Atime = uint32(atime.Unix())
+	}
+	if !mtime.IsZero() {
+		d.Mtime = uint32(mtime.Unix())
+	}
+
+	var buf [syscall.STATFIXLEN]byte
+	n, err := d.Marshal(buf[:])
+	if err == nil {
+		err = syscall.Fwstat(int(f.File.Fd()), buf[:n])
+	}
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: f.File.Name(), Err: err}
+	}
+
+	return nil
+}
+
 func rename(from, to string) error {
 	// If from and to are in different directories, copy the file
 	// since Plan 9 does not support cross-directory rename.
 	if filepath.Dir(from) != filepath.Dir(to) {
 		fi, err := os.Stat(from)
 		if err != nil {
-			return &os.LinkError{"rename", from, to, err}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
 		}
 		if fi.Mode().IsDir() {
-			return &os.LinkError{"rename", from, to, syscall.EISDIR}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EISDIR}
 		}
 		fromFile, err := os.Open(from)
 		if err != nil {
-			return &os.LinkError{"rename", from, to, err}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
 		}
 		toFile, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
 		if err != nil {
-			return &os.LinkError{"rename", from, to, err}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
 		}
 		_, err = io.Copy(toFile, fromFile)
 		if err != nil {
-			return &os.LinkError{"rename", from, to, err}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
 		}
 
 		// Copy mtime and mode from original file.
@@ -59,13 +99,13 @@ func rename(from, to string) error {
 		d.Mtime = dir.Mtime
 		d.Mode = dir.Mode
 		if err = dirwstat(to, &d); err != nil {
-			return &os.LinkError{"rename", from, to, err}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
 		}
 
 		// Remove original file.
 		err = os.Remove(from)
 		if err != nil {
-			return &os.LinkError{"rename", from, to, err}
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
 		}
 		return nil
 	}
@@ -77,10 +117,10 @@ func dirwstat(name string, d *syscall.Dir) error {
 
 	n, err := d.Marshal(buf[:])
 	if err != nil {
-		return &os.PathError{"dirwstat", name, err}
+		return &os.PathError{Op: "dirwstat", Path: name, Err: err}
 	}
 	if err = syscall.Wstat(name, buf[:n]); err != nil {
-		return &os.PathError{"dirwstat", name, err}
+		return &os.PathError{Op: "dirwstat", Path: name, Err: err}
 	}
 	return nil
 }
@@ -6,10 +6,20 @@ package osfs
 import (
 	"os"
 	"syscall"
+	"time"
 
+	"github.com/go-git/go-billy/v6"
 	"golang.org/x/sys/unix"
 )
 
+// capabilities are listed one by one, so the ones added to billy are only
+// reported once supported.
+const capabilities = billy.WriteCapability | billy.ReadCapability |
+	billy.ReadAndWriteCapability | billy.SeekCapability |
+	billy.TruncateCapability | billy.LockCapability |
+	billy.SymlinkCapability | billy.ChangeCapability |
+	billy.RemoveAllCapability
+
 func (f *file) Lock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
@@ -24,6 +34,20 @@ func (f *file) Unlock() error {
 	return unix.Flock(int(f.File.Fd()), unix.LOCK_UN)
 }
 
+// Chtimes implements the billy.FileChange interface, using futimes. Unlike
+// os.Chtimes, zero times are not left unchanged.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	tv := []unix.Timeval{
+		unix.NsecToTimeval(atime.UnixNano()),
+		unix.NsecToTimeval(mtime.UnixNano()),
+	}
+	if err := unix.Futimes(int(f.File.Fd()), tv); err != nil {
+		return &os.PathError{Op: "chtimes", Path: f.File.Name(), Err: err}
+	}
+
+	return nil
+}
+
 func rename(from, to string) error {
 	return os.Rename(from, to)
 }
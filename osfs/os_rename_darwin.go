@@ -0,0 +1,23 @@
+package osfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func renameNoReplace(from, to string) error {
+	return renamexNp(from, to, unix.RENAME_EXCL)
+}
+
+func renameExchange(from, to string) error {
+	return renamexNp(from, to, unix.RENAME_SWAP)
+}
+
+func renamexNp(from, to string, flags uint32) error {
+	if err := unix.RenamexNp(from, to, flags); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	return nil
+}
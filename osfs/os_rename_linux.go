@@ -0,0 +1,30 @@
+package osfs
+
+import (
+	"errors"
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+	"golang.org/x/sys/unix"
+)
+
+func renameNoReplace(from, to string) error {
+	return renameat2(from, to, unix.RENAME_NOREPLACE)
+}
+
+func renameExchange(from, to string) error {
+	return renameat2(from, to, unix.RENAME_EXCHANGE)
+}
+
+func renameat2(from, to string, flags uint) error {
+	err := unix.Renameat2(unix.AT_FDCWD, from, unix.AT_FDCWD, to, flags)
+	if errors.Is(err, unix.ENOSYS) {
+		// renameat2 is only available since Linux 3.15.
+		err = billy.ErrNotSupported
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !js
+// +build !linux,!darwin,!js
+
+package osfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+func renameNoReplace(from, to string) error {
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: billy.ErrNotSupported}
+}
+
+func renameExchange(from, to string) error {
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: billy.ErrNotSupported}
+}
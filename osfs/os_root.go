@@ -0,0 +1,87 @@
+//go:build !js
+// +build !js
+
+package osfs
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// FromRoot returns a new BoundOS filesystem bound to the directory of the
+// given root. The root is kept by the filesystem and can be retrieved with
+// AsRoot, so it must not be closed while the filesystem is in use.
+func FromRoot(root *os.Root, opts ...Option) billy.Filesystem {
+	o := &options{
+		deduplicatePath: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &BoundOS{
+		baseDir:         root.Name(),
+		deduplicatePath: o.deduplicatePath,
+		fileMode:        o.fileMode,
+		dirMode:         o.dirMode,
+		root:            root,
+	}
+}
+
+// AsRoot returns the *os.Root backing fs, so that a billy sandbox can be
+// handed over to APIs which consume *os.Root directly. It returns false if
+// fs is not a BoundOS filesystem, or if its base dir cannot be opened as a
+// root.
+//
+// Filesystems created with FromRoot return the root they were created with.
+// Other BoundOS filesystems open their base dir as a root on the first call
+// and keep it open for the subsequent ones. In both cases the returned root
+// is owned by the filesystem and must not be closed by the caller.
+func AsRoot(fs billy.Filesystem) (*os.Root, bool) {
+	b, ok := fs.(*BoundOS)
+	if !ok {
+		return nil, false
+	}
+
+	root, err := b.openRoot()
+	if err != nil {
+		return nil, false
+	}
+
+	return root, true
+}
+
+// AsFS returns a view of fs as an io/fs filesystem, reading the os filesystem
+// directly rather than through an adapter such as helper/iofs. It returns
+// false if fs is not a BoundOS filesystem, or if its base dir cannot be
+// opened as a root.
+//
+// The returned filesystem is the one of the root returned by AsRoot, so it
+// is bound to the base dir of fs in the same way.
+func AsFS(fs billy.Filesystem) (fs.FS, bool) {
+	root, ok := AsRoot(fs)
+	if !ok {
+		return nil, false
+	}
+
+	return root.FS(), true
+}
+
+func (fs *BoundOS) openRoot() (*os.Root, error) {
+	fs.rootMu.Lock()
+	defer fs.rootMu.Unlock()
+
+	if fs.root != nil {
+		return fs.root, nil
+	}
+
+	root, err := os.OpenRoot(fs.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.root = root
+	return root, nil
+}
@@ -0,0 +1,89 @@
+//go:build !wasm
+// +build !wasm
+
+package osfs
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestFromRoot(t *testing.T) {
+	dir := t.TempDir()
+	root, err := os.OpenRoot(dir)
+	require.NoError(t, err)
+	defer root.Close()
+
+	fs := FromRoot(root)
+	assert.Equal(t, dir, fs.Root())
+
+	err = util.WriteFile(fs, "foo", []byte("bar"), 0o600)
+	require.NoError(t, err)
+
+	f, err := root.Open("foo")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "bar", string(data))
+
+	got, ok := AsRoot(fs)
+	require.True(t, ok)
+	assert.Same(t, root, got)
+}
+
+func TestAsRoot(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "foo"), []byte("bar"), 0o600)
+	require.NoError(t, err)
+
+	fs := New(dir, WithBoundOS())
+	root, ok := AsRoot(fs)
+	require.True(t, ok)
+	assert.Equal(t, dir, root.Name())
+
+	f, err := root.Open("foo")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "bar", string(data))
+
+	again, ok := AsRoot(fs)
+	require.True(t, ok)
+	assert.Same(t, root, again)
+}
+
+func TestAsRootNotBound(t *testing.T) {
+	_, ok := AsRoot(New(t.TempDir(), WithChrootOS()))
+	assert.False(t, ok)
+
+	_, ok = AsRoot(New(filepath.Join(t.TempDir(), "missing"), WithBoundOS()))
+	assert.False(t, ok)
+}
+
+func TestAsFS(t *testing.T) {
+	fs := New(t.TempDir(), WithBoundOS())
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("bar"), 0o600))
+	require.NoError(t, fs.Symlink("/etc", "escape"))
+
+	fsys, ok := AsFS(fs)
+	require.True(t, ok)
+
+	data, err := iofs.ReadFile(fsys, "dir/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+
+	_, err = iofs.ReadDir(fsys, "escape")
+	require.Error(t, err)
+
+	_, ok = AsFS(New(t.TempDir(), WithChrootOS()))
+	assert.False(t, ok)
+}
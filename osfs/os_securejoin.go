@@ -0,0 +1,12 @@
+//go:build !js && !plan9
+// +build !js,!plan9
+
+package osfs
+
+import securejoin "github.com/cyphar/filepath-securejoin"
+
+// secureJoin joins root and unsafePath, evaluating the symlinks found in
+// unsafePath so that the result is always inside root.
+func secureJoin(root, unsafePath string) (string, error) {
+	return securejoin.SecureJoin(root, unsafePath)
+}
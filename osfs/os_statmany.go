@@ -0,0 +1,73 @@
+//go:build !js
+// +build !js
+
+package osfs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// splitStatPath splits the clean path into its dir and the name of the file,
+// or returns false for the paths without a name of their own, such as the
+// root or "..", which are left to Stat.
+func splitStatPath(path string) (dir, name string, ok bool) {
+	dir, name = filepath.Dir(path), filepath.Base(path)
+	if dir == path || name == "." || name == ".." {
+		return "", "", false
+	}
+
+	return dir, name, true
+}
+
+// statMany stats the named files as stat does, looking up the files of the
+// same dir through a single handle of the dir, which spares resolving the
+// whole path of each of them. split returns the dir to open for a path, along
+// with the name of the file in it, or false if the path is to be stated on
+// its own. Symlinks, and any failure, are left to stat, so that the results
+// are always the same as stat's.
+func statMany(paths []string, split func(path string) (dir, name string, ok bool),
+	stat func(path string) (os.FileInfo, error)) ([]os.FileInfo, []error) {
+	infos := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+	names := make([]string, len(paths))
+
+	// The paths are grouped by dir, so that only one dir is open at a time
+	// no matter how many of them there are.
+	var dirs []string
+	groups := make(map[string][]int)
+	for i, path := range paths {
+		dir, name, ok := split(path)
+		if !ok {
+			infos[i], errs[i] = stat(path)
+			continue
+		}
+
+		if _, seen := groups[dir]; !seen {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], i)
+		names[i] = name
+	}
+
+	for _, dir := range dirs {
+		root, _ := os.OpenRoot(dir)
+		for _, i := range groups[dir] {
+			if root != nil {
+				fi, err := root.Lstat(names[i])
+				if err == nil && fi.Mode()&os.ModeSymlink == 0 {
+					infos[i] = fi
+					continue
+				}
+			}
+
+			infos[i], errs[i] = stat(paths[i])
+		}
+
+		if root != nil {
+			_ = root.Close()
+		}
+	}
+
+	return infos, errs
+}
@@ -4,12 +4,25 @@
 package osfs
 
 import (
-	"io/fs"
+	iofs "io/fs"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
 )
 
-var _ fs.File = &file{}
+var _ billy.File = &file{}
 
 func TestDefault(t *testing.T) {
 	want := &ChrootOS{}
@@ -25,4 +38,469 @@ var (
 	_ = New("/")
 	_ = New("/", WithBoundOS())
 	_ = New("/", WithChrootOS())
+	_ = NewLegacyChroot("/")
+	_ = NewLegacyChroot("/", WithEscapeHandler(func(*EscapeError) error { return nil }))
 )
+
+func TestWithDefaultModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on windows")
+	}
+	defer umask(0)()
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt, WithDefaultFileMode(0o600), WithDefaultDirMode(0o700))
+
+		f, err := fs.Create(filepath.Join("foo", "bar"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		fi, err := os.Stat(filepath.Join(dir, "foo", "bar"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o600), fi.Mode().Perm())
+
+		fi, err = os.Stat(filepath.Join(dir, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o700), fi.Mode().Perm())
+
+		chroot, err := fs.Chroot("qux")
+		require.NoError(t, err)
+		f, err = chroot.Create(filepath.Join("baz", "qux"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		fi, err = os.Stat(filepath.Join(dir, "qux", "baz", "qux"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o600), fi.Mode().Perm())
+
+		fi, err = os.Stat(filepath.Join(dir, "qux", "baz"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o700), fi.Mode().Perm())
+	}
+}
+
+func TestUmask(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on windows")
+	}
+	defer umask(0o022)()
+
+	for name, fs := range map[string]billy.Filesystem{
+		"BoundOS":  New(t.TempDir(), WithBoundOS()),
+		"ChrootOS": New(t.TempDir(), WithChrootOS()),
+		"memfs":    memfs.New(memfs.WithUmask(0o022)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, util.WriteFile(fs, "foo", nil, 0o777))
+			fi, err := fs.Stat("foo")
+			require.NoError(t, err)
+			assert.Equal(t, iofs.FileMode(0o755), fi.Mode().Perm())
+
+			require.NoError(t, util.WriteFileExact(fs, "bar", nil, 0o777))
+			fi, err = fs.Stat("bar")
+			require.NoError(t, err)
+			assert.Equal(t, iofs.FileMode(0o777), fi.Mode().Perm())
+		})
+	}
+}
+
+func TestChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits and ownership are not supported on windows")
+	}
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt)
+		require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+		require.NoError(t, fs.Symlink("foo", "link"))
+
+		c, ok := fs.(billy.Change)
+		require.True(t, ok)
+
+		require.NoError(t, c.Chmod("foo", 0o600))
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, c.Chtimes("foo", mtime, mtime))
+
+		fi, err := os.Stat(filepath.Join(dir, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o600), fi.Mode().Perm())
+		assert.True(t, mtime.Equal(fi.ModTime()))
+
+		require.NoError(t, c.Lchown("link", os.Getuid(), os.Getgid()))
+		require.NoError(t, c.Chown("link", os.Getuid(), os.Getgid()))
+
+		err = c.Chmod("../outside", 0o600)
+		require.Error(t, err)
+	}
+}
+
+func TestSymlinkChange(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("changing symlinks is only supported on linux and darwin")
+	}
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt)
+		require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+		require.NoError(t, fs.Symlink("foo", "link"))
+
+		c, ok := fs.(billy.SymlinkChange)
+		require.True(t, ok)
+
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, c.Lchtimes("link", mtime, mtime))
+
+		fi, err := os.Lstat(filepath.Join(dir, "link"))
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()))
+		fi, err = os.Stat(filepath.Join(dir, "foo"))
+		require.NoError(t, err)
+		assert.False(t, mtime.Equal(fi.ModTime()))
+
+		// The zero time leaves the time unchanged.
+		require.NoError(t, c.Lchtimes("link", time.Time{}, time.Time{}))
+		fi, err = os.Lstat(filepath.Join(dir, "link"))
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()))
+
+		err = c.Lchmod("link", 0o700)
+		if runtime.GOOS == "linux" {
+			require.ErrorIs(t, err, billy.ErrNotSupported)
+		} else {
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, c.Lchmod("foo", 0o600))
+		fi, err = os.Stat(filepath.Join(dir, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o600), fi.Mode().Perm())
+	}
+}
+
+func TestFileChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on windows")
+	}
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt)
+		f, err := fs.Create("foo")
+		require.NoError(t, err)
+
+		// The file is changed through the handle even once renamed.
+		require.NoError(t, fs.Rename("foo", "bar"))
+
+		require.NoError(t, util.FileChmod(f, 0o600))
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, util.FileChtimes(f, mtime, mtime))
+		require.NoError(t, f.Close())
+
+		fi, err := os.Stat(filepath.Join(dir, "bar"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(0o600), fi.Mode().Perm())
+		assert.True(t, mtime.Equal(fi.ModTime()))
+	}
+}
+
+func TestRawFile(t *testing.T) {
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(t.TempDir(), opt)
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		f, err := fs.Open("foo")
+		require.NoError(t, err)
+
+		raw, err := util.RawFile(f)
+		require.NoError(t, err)
+
+		b := make([]byte, 3)
+		_, err = raw.ReadAt(b, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(b))
+
+		// Closing the file closes the *os.File it owns.
+		require.NoError(t, f.Close())
+		_, err = raw.ReadAt(b, 0)
+		require.ErrorIs(t, err, os.ErrClosed)
+	}
+}
+
+func TestOpenDirHandle(t *testing.T) {
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(t.TempDir(), opt)
+		for _, name := range []string{"a", "b"} {
+			require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+		}
+
+		f, err := fs.Open("dir")
+		require.NoError(t, err)
+
+		d, ok := f.(billy.ReadDirFile)
+		require.True(t, ok)
+
+		entries, err := d.ReadDir(0)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+		require.NoError(t, d.Close())
+
+		_, err = fs.OpenFile("dir", os.O_RDWR, 0)
+		require.ErrorIs(t, err, syscall.EISDIR)
+	}
+}
+
+func TestMmap(t *testing.T) {
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(t.TempDir(), opt)
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+		require.NoError(t, util.WriteFile(fs, "empty", nil, 0o644))
+		require.NoError(t, fs.MkdirAll("dir", 0o755))
+
+		m, err := util.Mmap(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("foo"), m.Bytes())
+
+		b := make([]byte, 2)
+		_, err = m.ReadAt(b, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "oo", string(b))
+		require.NoError(t, m.Close())
+
+		m, err = util.Mmap(fs, "empty")
+		require.NoError(t, err)
+		assert.Empty(t, m.Bytes())
+		require.NoError(t, m.Close())
+
+		_, err = util.Mmap(fs, "dir")
+		require.ErrorIs(t, err, syscall.EISDIR)
+		_, err = util.Mmap(fs, "missing")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func TestStatMany(t *testing.T) {
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt)
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+		require.NoError(t, util.WriteFile(fs, "dir/bar", []byte("bar!"), 0o644))
+		require.NoError(t, util.WriteFile(fs, "dir/sub/baz", nil, 0o644))
+		require.NoError(t, fs.Symlink("../foo", "dir/link"))
+
+		paths := []string{
+			"foo", "dir/bar", "dir", "dir/sub/baz", "dir/link", "dir/missing",
+			"missing/foo", "foo/bar", ".", "./dir/bar", "../outside",
+			filepath.Join(dir, "dir", "bar"),
+		}
+		b, ok := fs.(billy.BatchStater)
+		require.True(t, ok)
+
+		infos, errs := b.StatMany(paths)
+		require.Len(t, infos, len(paths))
+		require.Len(t, errs, len(paths))
+		for i, path := range paths {
+			want, wantErr := fs.Stat(path)
+			if wantErr != nil {
+				assert.Equal(t, wantErr, errs[i], path)
+				assert.Nil(t, infos[i], path)
+				continue
+			}
+
+			require.NoError(t, errs[i], path)
+			assert.Equal(t, want.Name(), infos[i].Name(), path)
+			assert.Equal(t, want.Mode(), infos[i].Mode(), path)
+			assert.Equal(t, want.Size(), infos[i].Size(), path)
+			assert.True(t, os.SameFile(want, infos[i]), path)
+		}
+	}
+}
+
+func TestChrootTempFileEmptyDir(t *testing.T) {
+	dir := t.TempDir()
+	fs := New(dir, WithChrootOS())
+
+	f, err := fs.TempFile("", "foo")
+	require.NoError(t, err)
+	assert.Equal(t, ".", filepath.Dir(f.Name()))
+	require.NoError(t, f.Close())
+
+	_, err = os.Stat(filepath.Join(dir, f.Name()))
+	require.NoError(t, err)
+
+	name, err := util.TempDir(fs, "", "bar")
+	require.NoError(t, err)
+	assert.False(t, filepath.IsAbs(name))
+
+	_, err = os.Stat(filepath.Join(dir, name))
+	require.NoError(t, err)
+}
+
+func TestAnonymousTempFiles(t *testing.T) {
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt, WithAnonymousTempFiles())
+		require.NoError(t, fs.MkdirAll("tmp", 0o755))
+
+		f, err := fs.TempFile("tmp", "foo")
+		require.NoError(t, err)
+		assert.Equal(t, "tmp", filepath.Dir(f.Name()))
+		assert.Contains(t, filepath.Base(f.Name()), "foo")
+
+		_, err = f.Write([]byte("foo"))
+		require.NoError(t, err)
+
+		if runtime.GOOS == "linux" {
+			// The file is only linked to its name once closed.
+			names, err := util.ReadDirNames(fs, "tmp", 0)
+			require.NoError(t, err)
+			assert.Empty(t, names)
+		}
+
+		require.NoError(t, f.Close())
+		require.ErrorIs(t, f.Close(), os.ErrClosed)
+
+		require.NoError(t, fs.Rename(f.Name(), "bar"))
+		content, err := util.ReadFile(fs, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+	}
+}
+
+func TestDefaultModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on windows")
+	}
+	defer umask(0)()
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		fs := New(dir, opt)
+
+		f, err := fs.Create(filepath.Join("foo", "bar"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		fi, err := os.Stat(filepath.Join(dir, "foo", "bar"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(defaultCreateMode), fi.Mode().Perm())
+
+		fi, err = os.Stat(filepath.Join(dir, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, iofs.FileMode(defaultDirectoryMode), fi.Mode().Perm())
+	}
+}
+
+func TestToExtended(t *testing.T) {
+	for abs, want := range map[string]string{
+		`C:\repo`:                 `\\?\C:\repo`,
+		`C:\`:                     `\\?\C:\`,
+		`\\server\share\repo`:     `\\?\UNC\server\share\repo`,
+		`\\?\C:\repo`:             `\\?\C:\repo`,
+		`\\?\UNC\server\share`:    `\\?\UNC\server\share`,
+		`\\.\PhysicalDrive0`:      `\\.\PhysicalDrive0`,
+		`\\?\Volume{1234}\folder`: `\\?\Volume{1234}\folder`,
+	} {
+		assert.Equal(t, want, toExtended(abs), abs)
+	}
+}
+
+func TestWithLongPaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("see TestLongPaths")
+	}
+
+	dir := t.TempDir()
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(dir, opt, WithLongPaths())
+		assert.Equal(t, dir, fs.Root())
+		assert.Equal(t, pathProperties(false), billy.Introspect(fs))
+	}
+}
+
+func TestWithUnsortedReadDir(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"qux", "foo", "bar", "baz"}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(dir, opt, WithUnsortedReadDir())
+		infos, err := fs.ReadDir("/")
+		require.NoError(t, err)
+
+		got := make([]string, 0, len(infos))
+		for _, fi := range infos {
+			got = append(got, fi.Name())
+		}
+		assert.ElementsMatch(t, names, got)
+
+		sub, err := fs.Chroot("/")
+		require.NoError(t, err)
+		infos, err = sub.ReadDir("/")
+		require.NoError(t, err)
+		assert.Len(t, infos, len(names))
+	}
+}
+
+func TestSpecialFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on " + runtime.GOOS)
+	}
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		dir := t.TempDir()
+		l, err := net.Listen("unix", filepath.Join(dir, "sock"))
+		if err != nil {
+			t.Skipf("cannot listen on a unix socket: %v", err)
+		}
+		defer l.Close()
+
+		fs := New(dir, opt)
+		fi, err := fs.Stat("sock")
+		require.NoError(t, err)
+		assert.Equal(t, iofs.ModeSocket, fi.Mode().Type())
+
+		fi, err = fs.Lstat("sock")
+		require.NoError(t, err)
+		assert.Equal(t, iofs.ModeSocket, fi.Mode().Type())
+
+		infos, err := fs.ReadDir(".")
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.Equal(t, iofs.ModeSocket, infos[0].Mode().Type())
+
+		var walked []string
+		err = util.WalkWithOptions(fs, ".", util.WalkOptions{SpecialFiles: util.SkipSpecialFiles},
+			func(path string, _ iofs.FileInfo, err error) error {
+				walked = append(walked, path)
+				return err
+			})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"."}, walked)
+	}
+}
+
+func TestWithNameLimits(t *testing.T) {
+	long := strings.Repeat("a", 256)
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(t.TempDir(), opt, WithNameLimits(billy.DefaultNameLimits))
+
+		require.NoError(t, util.WriteFile(fs, long[1:], nil, 0o644))
+		err := util.WriteFile(fs, long, nil, 0o644)
+		assert.ErrorIs(t, err, billy.ErrNameTooLong)
+		var nerr *billy.NameError
+		require.ErrorAs(t, err, &nerr)
+		assert.Equal(t, long, nerr.Name)
+
+		_, err = fs.Stat("foo/" + long)
+		assert.ErrorIs(t, err, billy.ErrNameTooLong)
+
+		sub, err := fs.Chroot("foo")
+		require.NoError(t, err)
+		assert.ErrorIs(t, sub.MkdirAll(long, 0o755), billy.ErrNameTooLong)
+	}
+}
@@ -0,0 +1,59 @@
+package osfs
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+	"golang.org/x/sys/unix"
+)
+
+// anonymousTempFile creates an unnamed temp file in dir with O_TMPFILE, to be
+// linked when closed to a name made from pattern, as os.CreateTemp does. It
+// fails with billy.ErrNotSupported if the kernel or the filesystem of dir do
+// not support O_TMPFILE.
+func anonymousTempFile(dir, pattern string) (billy.File, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	if strings.ContainsRune(pattern, os.PathSeparator) {
+		return nil, &os.PathError{Op: "createtemp", Path: pattern, Err: errPatternHasSeparator}
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndexByte(pattern, '*'); i != -1 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	f, err := os.OpenFile(dir, os.O_RDWR|unix.O_TMPFILE, 0o600)
+	if err != nil {
+		// Old kernels fail with EISDIR, as O_TMPFILE includes O_DIRECTORY.
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EISDIR) {
+			return nil, billy.ErrNotSupported
+		}
+		return nil, err
+	}
+
+	name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+	return &file{File: f, name: name, openedPath: name, linkPath: name}, nil
+}
+
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+// linkAnonymous links the unnamed file f to path, through its entry in
+// /proc, as linking it by its descriptor with AT_EMPTY_PATH requires
+// privileges.
+func linkAnonymous(f *os.File, path string) error {
+	proc := "/proc/self/fd/" + strconv.Itoa(int(f.Fd()))
+	err := unix.Linkat(unix.AT_FDCWD, proc, unix.AT_FDCWD, path, unix.AT_SYMLINK_FOLLOW)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: proc, New: path, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !js
+// +build !linux,!js
+
+package osfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+func anonymousTempFile(_, _ string) (billy.File, error) {
+	return nil, billy.ErrNotSupported
+}
+
+func linkAnonymous(f *os.File, path string) error {
+	return &os.LinkError{Op: "link", Old: f.Name(), New: path, Err: billy.ErrNotSupported}
+}
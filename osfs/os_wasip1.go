@@ -6,8 +6,18 @@ package osfs
 import (
 	"os"
 	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
 )
 
+// capabilities exclude locking, as WASI has no file locks and Lock does
+// nothing.
+const capabilities = billy.WriteCapability | billy.ReadCapability |
+	billy.ReadAndWriteCapability | billy.SeekCapability |
+	billy.TruncateCapability | billy.SymlinkCapability |
+	billy.ChangeCapability | billy.RemoveAllCapability
+
 func (f *file) Lock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
@@ -20,6 +30,12 @@ func (f *file) Unlock() error {
 	return nil
 }
 
+// Chtimes implements the billy.FileChange interface. WASI preview 1 has no
+// way to change the times of an open file exposed by Go, so it always fails.
+func (f *file) Chtimes(_ time.Time, _ time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: f.File.Name(), Err: billy.ErrNotSupported}
+}
+
 func rename(from, to string) error {
 	return os.Rename(from, to)
 }
@@ -6,11 +6,21 @@ package osfs
 import (
 	"os"
 	"runtime"
+	"time"
 	"unsafe"
 
+	"github.com/go-git/go-billy/v6"
 	"golang.org/x/sys/windows"
 )
 
+// capabilities include symlinks, although creating them requires either
+// admin rights or the developer mode to be enabled.
+const capabilities = billy.WriteCapability | billy.ReadCapability |
+	billy.ReadAndWriteCapability | billy.SeekCapability |
+	billy.TruncateCapability | billy.LockCapability |
+	billy.SymlinkCapability | billy.ChangeCapability |
+	billy.RemoveAllCapability
+
 var (
 	kernel32DLL    = windows.NewLazySystemDLL("kernel32.dll")
 	lockFileExProc = kernel32DLL.NewProc("LockFileEx")
@@ -48,6 +58,26 @@ func (f *file) Unlock() error {
 	return nil
 }
 
+// Chtimes implements the billy.FileChange interface, using SetFileTime. As
+// with os.Chtimes, zero times are left unchanged.
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	var a, m *windows.Filetime
+	if !atime.IsZero() {
+		ft := windows.NsecToFiletime(atime.UnixNano())
+		a = &ft
+	}
+	if !mtime.IsZero() {
+		ft := windows.NsecToFiletime(mtime.UnixNano())
+		m = &ft
+	}
+
+	if err := windows.SetFileTime(windows.Handle(f.File.Fd()), nil, a, m); err != nil {
+		return &os.PathError{Op: "chtimes", Path: f.File.Name(), Err: err}
+	}
+
+	return nil
+}
+
 func rename(from, to string) error {
 	return os.Rename(from, to)
 }
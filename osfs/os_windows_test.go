@@ -0,0 +1,62 @@
+//go:build windows
+// +build windows
+
+package osfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestLongPaths(t *testing.T) {
+	dir := t.TempDir()
+
+	// A path of ~400 characters, well past MAX_PATH.
+	deep := strings.Repeat("abcdefghijklmnopqrstuvwxyz"+string(filepath.Separator), 15)
+	name := filepath.Join(deep, "file")
+
+	for _, opt := range []Option{WithBoundOS(), WithChrootOS()} {
+		fs := New(dir, opt, WithLongPaths())
+		assert.True(t, strings.HasPrefix(fs.Root(), `\\?\`), fs.Root())
+		assert.Equal(t, maxExtendedPathLength, billy.Introspect(fs).MaxPathLength)
+
+		require.NoError(t, util.WriteFile(fs, name, []byte("foo"), 0o644))
+
+		b, err := util.ReadFile(fs, name)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(b))
+
+		entries, err := fs.ReadDir(deep)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		renamed := filepath.Join(deep, "renamed")
+		require.NoError(t, fs.Rename(name, renamed))
+		_, err = fs.Stat(renamed)
+		require.NoError(t, err)
+
+		rel, err := util.Rel(fs, filepath.Join(dir, renamed))
+		require.NoError(t, err)
+		assert.Equal(t, renamed, rel)
+
+		require.NoError(t, util.RemoveAll(fs, strings.Split(deep, string(filepath.Separator))[0]))
+		_, err = os.Stat(dir)
+		require.NoError(t, err)
+	}
+}
+
+func TestLongPathsUNC(t *testing.T) {
+	fs := New(`\\server\share\repo`, WithBoundOS(), WithLongPaths())
+	assert.Equal(t, `\\?\UNC\server\share\repo`, fs.Root())
+
+	rel, err := util.Rel(fs, `\\server\share\repo\foo`)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", rel)
+}
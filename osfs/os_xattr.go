@@ -0,0 +1,85 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package osfs
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+	"golang.org/x/sys/unix"
+)
+
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, xattrError("getxattr", path, err)
+		}
+
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The attribute grew in between calls.
+			continue
+		}
+		if err != nil {
+			return nil, xattrError("getxattr", path, err)
+		}
+
+		return buf[:n], nil
+	}
+}
+
+func setXattr(path, name string, value []byte) error {
+	if err := unix.Setxattr(path, name, value, 0); err != nil {
+		return xattrError("setxattr", path, err)
+	}
+
+	return nil
+}
+
+func listXattr(path string) ([]string, error) {
+	for {
+		size, err := unix.Listxattr(path, nil)
+		if err != nil {
+			return nil, xattrError("listxattr", path, err)
+		}
+
+		if size == 0 {
+			return []string{}, nil
+		}
+
+		buf := make([]byte, size)
+		n, err := unix.Listxattr(path, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, xattrError("listxattr", path, err)
+		}
+
+		return strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00"), nil
+	}
+}
+
+func removeXattr(path, name string) error {
+	if err := unix.Removexattr(path, name); err != nil {
+		return xattrError("removexattr", path, err)
+	}
+
+	return nil
+}
+
+func xattrError(op, path string, err error) error {
+	switch {
+	case errors.Is(err, errNoXattr):
+		err = billy.ErrNoXattr
+	case errors.Is(err, unix.ENOTSUP):
+		err = billy.ErrNotSupported
+	}
+
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
@@ -0,0 +1,6 @@
+package osfs
+
+import "golang.org/x/sys/unix"
+
+// errNoXattr is the error returned by the OS when an attribute is not found.
+const errNoXattr = unix.ENODATA
@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !js
+// +build !linux,!darwin,!js
+
+package osfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+func getXattr(path, _ string) ([]byte, error) {
+	return nil, &os.PathError{Op: "getxattr", Path: path, Err: billy.ErrNotSupported}
+}
+
+func setXattr(path, _ string, _ []byte) error {
+	return &os.PathError{Op: "setxattr", Path: path, Err: billy.ErrNotSupported}
+}
+
+func listXattr(path string) ([]string, error) {
+	return nil, &os.PathError{Op: "listxattr", Path: path, Err: billy.ErrNotSupported}
+}
+
+func removeXattr(path, _ string) error {
+	return &os.PathError{Op: "removexattr", Path: path, Err: billy.ErrNotSupported}
+}
@@ -0,0 +1,45 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package osfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestXattr(t *testing.T) {
+	for _, fs := range []billy.Filesystem{
+		New(t.TempDir(), WithChrootOS()),
+		New(t.TempDir(), WithBoundOS()),
+	} {
+		require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+
+		x, ok := fs.(billy.Xattr)
+		require.True(t, ok)
+
+		err := x.SetXattr("foo", "user.checksum", []byte("abc"))
+		if errors.Is(err, billy.ErrNotSupported) {
+			t.Skip("extended attributes not supported by the temp dir filesystem")
+		}
+		require.NoError(t, err)
+
+		v, err := x.GetXattr("foo", "user.checksum")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("abc"), v)
+
+		names, err := x.ListXattr("foo")
+		require.NoError(t, err)
+		assert.Contains(t, names, "user.checksum")
+
+		require.NoError(t, x.RemoveXattr("foo", "user.checksum"))
+
+		_, err = x.GetXattr("foo", "user.checksum")
+		assert.ErrorIs(t, err, billy.ErrNoXattr)
+	}
+}
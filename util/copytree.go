@@ -0,0 +1,227 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// CopyTreeOptions configures the behaviour of CopyTree. Its zero value copies
+// the whole tree.
+//
+// The patterns of Include and Exclude use forward slashes and are matched
+// with filepath.Match. A pattern holding no slash is matched against the
+// name of each entry, such as "*.go" or ".git", the other ones against its
+// path relative to the root being copied, such as "docs/*.md".
+type CopyTreeOptions struct {
+	// Include, if not empty, restricts the files and symlinks copied to the
+	// ones matching one of its patterns. Directories are only created to
+	// hold them.
+	Include []string
+	// Exclude skips the entries matching one of its patterns, along with
+	// all the entries of the directories matching one.
+	Exclude []string
+	// MaxFileSize skips the files larger than it. Zero or less means no
+	// limit.
+	MaxFileSize int64
+}
+
+// CopyTree copies the tree found at root in src to the root of dst, such as
+// a directory of the OS to a memfs filesystem. Files are copied along with
+// their permission bits and modification times, directories with their
+// permission bits, and symlinks as symlinks, without following them. The
+// modification times are left as is if dst does not support changing them.
+func CopyTree(dst billy.Filesystem, src billy.Filesystem, root string, opts CopyTreeOptions) error {
+	c := &treeCopier{ctx: context.Background(), dst: dst, src: src, opts: opts}
+	return c.copyTree(root)
+}
+
+// CopyTreeWithProgress works like CopyTree, but calls progress after every
+// entry copied, and while copying the content of the files, so the callers
+// copying large trees, or to slow filesystems, can give some feedback. The
+// copy stops once ctx is done, returning the error of the context, and
+// leaving in dst the entries copied so far.
+func CopyTreeWithProgress(ctx context.Context, dst billy.Filesystem, src billy.Filesystem, root string, opts CopyTreeOptions, progress ProgressFunc) error {
+	c := &treeCopier{ctx: ctx, dst: dst, src: src, opts: opts, progress: progress}
+	return c.copyTree(root)
+}
+
+type treeCopier struct {
+	ctx      context.Context
+	dst      billy.Filesystem
+	src      billy.Filesystem
+	opts     CopyTreeOptions
+	progress ProgressFunc
+	p        Progress
+}
+
+func (c *treeCopier) copyTree(root string) error {
+	return Walk(c.src, root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." && !fi.IsDir() {
+			rel = fi.Name()
+		}
+
+		return c.copy(path, filepath.ToSlash(rel), fi)
+	})
+}
+
+// copy copies the entry at path in src, described by fi, to rel in dst.
+func (c *treeCopier) copy(path, rel string, fi os.FileInfo) error {
+	if rel != "." && matchAny(c.opts.Exclude, rel) {
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	name := filepath.FromSlash(rel)
+	switch {
+	case fi.IsDir():
+		if len(c.opts.Include) > 0 {
+			return nil
+		}
+		return c.done(path, c.dst.MkdirAll(name, fi.Mode().Perm()))
+	case len(c.opts.Include) > 0 && !matchAny(c.opts.Include, rel):
+		return nil
+	case fi.Mode()&os.ModeSymlink != 0:
+		return c.done(path, c.copySymlink(path, name))
+	case !fi.Mode().IsRegular():
+		return nil
+	case c.opts.MaxFileSize > 0 && fi.Size() > c.opts.MaxFileSize:
+		return nil
+	default:
+		if err := c.copyFile(path, name, fi); err != nil {
+			return err
+		}
+	}
+
+	err := Chtimes(c.dst, name, fi.ModTime(), fi.ModTime())
+	if errors.Is(err, billy.ErrNotSupported) {
+		err = nil
+	}
+
+	return c.done(path, err)
+}
+
+// done reports the entry at path as copied, unless err is not nil, and
+// returns err.
+func (c *treeCopier) done(path string, err error) error {
+	if err == nil && c.progress != nil {
+		c.p.Entries++
+		c.p.Path = path
+		c.progress(c.p)
+	}
+
+	return err
+}
+
+func (c *treeCopier) copyFile(path, name string, fi os.FileInfo) error {
+	if err := c.mkdirParent(name); err != nil {
+		return err
+	}
+
+	srcFile, err := c.src.Open(path)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := c.dst.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if c.progress != nil {
+		_, err = io.Copy(&progressWriter{c: c, path: path, w: dstFile}, srcFile)
+	} else {
+		_, err = Copy(dstFile, srcFile)
+	}
+	if err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+
+	return dstFile.Close()
+}
+
+// progressWriter writes to w the content of the file at path, reporting the
+// bytes written to the progress of c, and failing once its context is done.
+type progressWriter struct {
+	c    *treeCopier
+	path string
+	w    io.Writer
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	if err := w.c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := w.w.Write(p)
+	w.c.p.Bytes += int64(n)
+	w.c.p.Path = w.path
+	w.c.progress(w.c.p)
+	return n, err
+}
+
+func (c *treeCopier) copySymlink(path, name string) error {
+	if err := c.mkdirParent(name); err != nil {
+		return err
+	}
+
+	target, err := c.src.Readlink(path)
+	if err != nil {
+		return err
+	}
+
+	return c.dst.Symlink(target, name)
+}
+
+// mkdirParent creates the parent directories of name, which are not copied
+// from src when only some files are included.
+func (c *treeCopier) mkdirParent(name string) error {
+	if len(c.opts.Include) == 0 {
+		return nil
+	}
+
+	dir := filepath.Dir(name)
+	if dir == "." {
+		return nil
+	}
+
+	return c.dst.MkdirAll(dir, 0o755)
+}
+
+// matchAny reports whether the slash separated path rel matches one of
+// patterns, as described by CopyTreeOptions.
+func matchAny(patterns []string, rel string) bool {
+	name := rel[strings.LastIndex(rel, "/")+1:]
+	for _, p := range patterns {
+		target := rel
+		if !strings.Contains(p, "/") {
+			target = name
+		}
+
+		if ok, _ := filepath.Match(filepath.FromSlash(p), filepath.FromSlash(target)); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package util_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func newCopyTreeSource(t *testing.T) billy.Filesystem {
+	src := osfs.New(t.TempDir())
+	require.NoError(t, src.MkdirAll("repo/empty", 0o755))
+	require.NoError(t, util.WriteFile(src, "repo/main.go", []byte("package main"), 0o644))
+	require.NoError(t, util.WriteFile(src, "repo/run.sh", []byte("#!/bin/sh"), 0o755))
+	require.NoError(t, util.WriteFile(src, "repo/docs/README.md", []byte("# docs"), 0o644))
+	require.NoError(t, util.WriteFile(src, "repo/.git/HEAD", []byte("ref: refs/heads/main"), 0o644))
+	require.NoError(t, util.WriteFile(src, "repo/big.bin", make([]byte, 1024), 0o644))
+	require.NoError(t, util.WriteFile(src, "outside", []byte("outside"), 0o644))
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, util.Chtimes(src, "repo/main.go", mtime, mtime))
+	return src
+}
+
+func TestCopyTree(t *testing.T) {
+	src := newCopyTreeSource(t)
+	if err := src.Symlink("main.go", "repo/link"); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	dst := memfs.New()
+	require.NoError(t, util.CopyTree(dst, src, "repo", util.CopyTreeOptions{}))
+
+	content, err := util.ReadFile(dst, "docs/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# docs", string(content))
+
+	fi, err := dst.Stat("main.go")
+	require.NoError(t, err)
+	assert.True(t, fi.ModTime().Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	fi, err = dst.Stat("empty")
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+
+	target, err := dst.Readlink("link")
+	require.NoError(t, err)
+	assert.Equal(t, "main.go", target)
+
+	_, err = dst.Stat("outside")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCopyTreeFilter(t *testing.T) {
+	src := newCopyTreeSource(t)
+
+	dst := memfs.New()
+	require.NoError(t, util.CopyTree(dst, src, "repo", util.CopyTreeOptions{
+		Include:     []string{"*.go", "*.bin", "docs/*.md"},
+		Exclude:     []string{".git"},
+		MaxFileSize: 512,
+	}))
+
+	var copied []string
+	require.NoError(t, util.Walk(dst, "", func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			copied = append(copied, path)
+		}
+		return err
+	}))
+	assert.ElementsMatch(t, []string{"main.go", dst.Join("docs", "README.md")}, copied)
+
+	_, err := dst.Stat("empty")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	dst = memfs.New()
+	require.NoError(t, util.CopyTree(dst, src, "repo", util.CopyTreeOptions{Exclude: []string{".git", "docs/*"}}))
+
+	_, err = dst.Stat(".git")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = dst.Stat("docs/README.md")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = dst.Stat("big.bin")
+	assert.NoError(t, err)
+}
+
+func TestCopyTreeWithProgress(t *testing.T) {
+	src := newCopyTreeSource(t)
+
+	var last util.Progress
+	dst := memfs.New()
+	err := util.CopyTreeWithProgress(context.Background(), dst, src, "repo", util.CopyTreeOptions{},
+		func(p util.Progress) {
+			assert.GreaterOrEqual(t, p.Entries, last.Entries)
+			assert.GreaterOrEqual(t, p.Bytes, last.Bytes)
+			last = p
+		})
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), last.Entries)
+	assert.Equal(t, int64(1071), last.Bytes)
+
+	content, err := util.ReadFile(dst, "big.bin")
+	require.NoError(t, err)
+	assert.Len(t, content, 1024)
+}
+
+func TestCopyTreeWithProgressCancel(t *testing.T) {
+	src := newCopyTreeSource(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var entries int64
+	err := util.CopyTreeWithProgress(ctx, memfs.New(), src, "repo", util.CopyTreeOptions{},
+		func(p util.Progress) {
+			entries = p.Entries
+			cancel()
+		})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(1), entries)
+}
@@ -0,0 +1,230 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// DiffReason is a set of reasons for which an entry differs between two
+// trees.
+type DiffReason uint8
+
+const (
+	// DiffType reports entries of different types, such as a file and a
+	// directory.
+	DiffType DiffReason = 1 << iota
+	// DiffSize reports files of different sizes.
+	DiffSize
+	// DiffMode reports entries with different permission bits.
+	DiffMode
+	// DiffModTime reports files with different modification times. It is
+	// only checked if DiffTreesOptions.ModTime is set.
+	DiffModTime
+	// DiffContent reports files of the same size with different contents.
+	// It is only checked if DiffTreesOptions.Content is set.
+	DiffContent
+	// DiffTarget reports symlinks pointing to different targets.
+	DiffTarget
+)
+
+var diffReasonNames = []string{"type", "size", "mode", "mtime", "content", "target"}
+
+func (r DiffReason) String() string {
+	var names []string
+	for i, name := range diffReasonNames {
+		if r&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+// DiffTreesOptions configures the comparisons made by DiffTrees. Types,
+// sizes, permission bits and symlink targets are always compared.
+type DiffTreesOptions struct {
+	// ModTime compares the modification times of the files.
+	ModTime bool
+	// Content compares the contents of the files of the same size, hashing
+	// them.
+	Content bool
+}
+
+// Modification is an entry found in both trees compared by DiffTrees, which
+// differs between them.
+type Modification struct {
+	Path   string
+	Reason DiffReason
+}
+
+// TreeDiff holds the differences between two trees, found by DiffTrees. The
+// paths are relative to the roots of the trees, use forward slashes, and are
+// sorted.
+type TreeDiff struct {
+	// Added holds the entries only found in the second tree.
+	Added []string
+	// Modified holds the entries found in both trees which differ.
+	Modified []Modification
+	// Deleted holds the entries only found in the first tree.
+	Deleted []string
+}
+
+// Empty reports whether both trees are the same.
+func (d *TreeDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Deleted) == 0
+}
+
+// DiffTrees compares the trees of a and b, reporting the entries added,
+// modified or deleted to go from a to b. The entries of the directories
+// added or deleted are reported along with them. Symlinks are compared
+// without following them.
+func DiffTrees(a, b billy.Filesystem, opts DiffTreesOptions) (*TreeDiff, error) {
+	entriesA, err := treeEntries(a, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	entriesB, err := treeEntries(b, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &TreeDiff{}
+	for rel, fa := range entriesA {
+		fb, ok := entriesB[rel]
+		if !ok {
+			diff.Deleted = append(diff.Deleted, rel)
+			continue
+		}
+
+		reason, err := diffEntry(a, b, rel, fa, fb, opts)
+		if err != nil {
+			return nil, err
+		}
+		if reason != 0 {
+			diff.Modified = append(diff.Modified, Modification{Path: rel, Reason: reason})
+		}
+	}
+
+	for rel := range entriesB {
+		if _, ok := entriesA[rel]; !ok {
+			diff.Added = append(diff.Added, rel)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Deleted)
+	sort.Slice(diff.Modified, func(i, j int) bool {
+		return diff.Modified[i].Path < diff.Modified[j].Path
+	})
+
+	return diff, nil
+}
+
+// treeEntries returns the entries of the tree of fs at root by their slash
+// separated path relative to root, which is excluded.
+func treeEntries(fs billy.Filesystem, root string) (map[string]os.FileInfo, error) {
+	entries := make(map[string]os.FileInfo)
+	err := Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			entries[filepath.ToSlash(rel)] = fi
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// diffEntry returns the reasons for which the entry at rel differs between a
+// and b, described by fa and fb.
+func diffEntry(a, b billy.Filesystem, rel string, fa, fb os.FileInfo, opts DiffTreesOptions) (DiffReason, error) {
+	if fa.Mode().Type() != fb.Mode().Type() {
+		return DiffType, nil
+	}
+
+	var reason DiffReason
+	if fa.Mode().Perm() != fb.Mode().Perm() {
+		reason |= DiffMode
+	}
+
+	name := filepath.FromSlash(rel)
+	switch {
+	case fa.Mode()&os.ModeSymlink != 0:
+		ta, err := a.Readlink(name)
+		if err != nil {
+			return 0, err
+		}
+		tb, err := b.Readlink(name)
+		if err != nil {
+			return 0, err
+		}
+		if ta != tb {
+			reason |= DiffTarget
+		}
+	case fa.Mode().IsRegular():
+		if opts.ModTime && !fa.ModTime().Equal(fb.ModTime()) {
+			reason |= DiffModTime
+		}
+		if fa.Size() != fb.Size() {
+			return reason | DiffSize, nil
+		}
+		if opts.Content {
+			same, err := sameContent(a, b, name)
+			if err != nil {
+				return 0, err
+			}
+			if !same {
+				reason |= DiffContent
+			}
+		}
+	}
+
+	return reason, nil
+}
+
+// sameContent reports whether the file at name has the same contents in a
+// and b.
+func sameContent(a, b billy.Filesystem, name string) (bool, error) {
+	ha, err := hashFile(a, name)
+	if err != nil {
+		return false, err
+	}
+
+	hb, err := hashFile(b, name)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(ha, hb), nil
+}
+
+func hashFile(fs billy.Filesystem, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,60 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestDiffTrees(t *testing.T) {
+	a := memfs.New()
+	require.NoError(t, util.WriteFile(a, "same", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(a, "size", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(a, "content", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(a, "mode", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(a, "deleted/file", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(a, "type", []byte("foo"), 0o644))
+	require.NoError(t, a.Symlink("same", "link"))
+
+	b := memfs.New()
+	require.NoError(t, util.CopyTree(b, a, "", util.CopyTreeOptions{}))
+
+	diff, err := util.DiffTrees(a, b, util.DiffTreesOptions{ModTime: true, Content: true})
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	require.NoError(t, util.WriteFile(b, "size", []byte("foobar"), 0o644))
+	require.NoError(t, util.WriteFile(b, "content", []byte("bar"), 0o644))
+	require.NoError(t, util.Chmod(b, "mode", 0o600))
+	require.NoError(t, util.RemoveAll(b, "deleted"))
+	require.NoError(t, b.Remove("type"))
+	require.NoError(t, b.MkdirAll("type", 0o755))
+	require.NoError(t, b.Remove("link"))
+	require.NoError(t, b.Symlink("size", "link"))
+	require.NoError(t, util.WriteFile(b, "added/file", []byte("foo"), 0o644))
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, util.Chtimes(b, "same", mtime, mtime))
+	require.NoError(t, util.Chtimes(b, "content", mtime, mtime))
+
+	diff, err = util.DiffTrees(a, b, util.DiffTreesOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"added", "added/file"}, diff.Added)
+	assert.Equal(t, []string{"deleted", "deleted/file"}, diff.Deleted)
+	assert.Equal(t, []util.Modification{
+		{Path: "link", Reason: util.DiffTarget},
+		{Path: "mode", Reason: util.DiffMode},
+		{Path: "size", Reason: util.DiffSize},
+		{Path: "type", Reason: util.DiffType},
+	}, diff.Modified)
+
+	diff, err = util.DiffTrees(a, b, util.DiffTreesOptions{ModTime: true, Content: true})
+	require.NoError(t, err)
+	assert.Contains(t, diff.Modified, util.Modification{Path: "same", Reason: util.DiffModTime})
+	assert.Contains(t, diff.Modified, util.Modification{Path: "content", Reason: util.DiffModTime | util.DiffContent})
+	assert.Equal(t, "mtime|content", (util.DiffModTime | util.DiffContent).String())
+}
@@ -97,15 +97,11 @@ func hasMeta(path string) bool {
 }
 
 func readdirnames(fs billy.Filesystem, dir string) ([]string, error) {
-	files, err := fs.ReadDir(dir)
+	names, err := ReadDirNames(fs, dir, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	names := make([]string, 0, len(files))
-	for _, file := range files {
-		names = append(names, file.Name())
-	}
-
+	sort.Strings(names)
 	return names, nil
 }
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// Abs returns the absolute path in the underlying storage of the file named
+// by path in fs. Paths given to a billy filesystem are relative to its root,
+// even when they start with a separator, so for a filesystem rooted at
+// "/srv/repo" both "/foo" and "foo" resolve to "/srv/repo/foo". Filesystems
+// not implementing the Chroot interface are assumed to be rooted at the
+// separator.
+//
+// An error wrapping a *billy.BoundaryError is returned if path escapes the
+// root of fs through "..".
+func Abs(fs billy.Basic, path string) (string, error) {
+	rel, err := rootRel("abs", root(fs), path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root(fs), rel), nil
+}
+
+// SecureJoin joins base and the untrusted path unsafe, such as a path
+// received by a server, so that the result never leaves base. The path is
+// normalized lexically, without resolving symlinks, and interpreted against
+// base as billy filesystems interpret paths against their root: a leading
+// separator refers to base, and ".." stays at base in such rooted paths.
+//
+// An error wrapping a *billy.BoundaryError is returned if unsafe goes above
+// base, as "../foo" or "foo/../../bar" do, or if it holds a volume name on
+// Windows, such as C: or \\server\share.
+func SecureJoin(base, unsafe string) (string, error) {
+	if filepath.VolumeName(unsafe) != "" {
+		return "", boundaryError("securejoin", base, unsafe)
+	}
+
+	rel, err := rootRel("securejoin", base, unsafe)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(base, rel), nil
+}
+
+// Rel is the inverse of Abs: it returns the path, relative to the root of
+// fs, of the file named by the absolute path in the underlying storage. The
+// root itself is returned as ".". A relative path is taken as already being
+// relative to the root of fs, and is only cleaned. On Windows, the regular and
+// the extended-length forms of a path, e.g. C:\repo and \\?\C:\repo, or
+// \\server\share and \\?\UNC\server\share, are considered equal.
+//
+// An error wrapping a *billy.BoundaryError is returned if path is outside
+// the root of fs.
+func Rel(fs billy.Basic, path string) (string, error) {
+	path = filepath.Clean(filepath.FromSlash(path))
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, string(filepath.Separator)) {
+		return rootRel("rel", root(fs), path)
+	}
+
+	rel, err := filepath.Rel(trimExtended(root(fs)), trimExtended(path))
+	if err != nil || isEscaping(rel) {
+		return "", boundaryError("rel", root(fs), path)
+	}
+
+	return rel, nil
+}
+
+// rootRel returns path relative to base, the root it is interpreted against.
+func rootRel(op, base, path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if isEscaping(clean) {
+		return "", boundaryError(op, base, path)
+	}
+
+	clean = strings.TrimLeft(clean, string(filepath.Separator))
+	if clean == "" {
+		return ".", nil
+	}
+
+	return clean, nil
+}
+
+func boundaryError(op, base, path string) error {
+	err := &billy.BoundaryError{Base: base, Path: path, Err: billy.ErrCrossedBoundary}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
+
+func root(fs billy.Basic) string {
+	if ch, ok := fs.(billy.Chroot); ok && ch.Root() != "" {
+		return ch.Root()
+	}
+
+	return string(filepath.Separator)
+}
+
+// trimExtended returns the regular form of the Windows extended-length path,
+// so it can be compared with paths in either form. Paths are returned as is
+// on other OSes.
+func trimExtended(path string) string {
+	if runtime.GOOS != "windows" {
+		return path
+	}
+
+	if rest, ok := strings.CutPrefix(path, `\\?\UNC\`); ok {
+		return `\\` + rest
+	}
+
+	return strings.TrimPrefix(path, `\\?\`)
+}
+
+func isEscaping(path string) bool {
+	return path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,123 @@
+package util_test
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestAbs(t *testing.T) {
+	fs, err := memfs.New().Chroot("/srv/repo")
+	require.NoError(t, err)
+
+	for path, want := range map[string]string{
+		"":            "/srv/repo",
+		"/":           "/srv/repo",
+		"foo":         "/srv/repo/foo",
+		"/foo/bar":    "/srv/repo/foo/bar",
+		"foo/../bar":  "/srv/repo/bar",
+		"/../foo":     "/srv/repo/foo",
+		"foo/./bar/.": "/srv/repo/foo/bar",
+	} {
+		got, err := util.Abs(fs, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, filepath.FromSlash(want), got, path)
+	}
+
+	for _, path := range []string{"..", "../foo", "foo/../../bar"} {
+		_, err := util.Abs(fs, path)
+		assert.ErrorIs(t, err, billy.ErrCrossedBoundary, path)
+
+		var berr *billy.BoundaryError
+		require.ErrorAs(t, err, &berr, path)
+		assert.Equal(t, fs.Root(), berr.Base, path)
+		assert.Equal(t, path, berr.Path, path)
+	}
+}
+
+func TestAbsWithoutChroot(t *testing.T) {
+	got, err := util.Abs(memfs.New(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/foo"), got)
+}
+
+func TestSecureJoin(t *testing.T) {
+	base := filepath.FromSlash("/srv/repo")
+	for path, want := range map[string]string{
+		"":                 "/srv/repo",
+		"/":                "/srv/repo",
+		"foo":              "/srv/repo/foo",
+		"/foo/bar":         "/srv/repo/foo/bar",
+		"foo/../bar":       "/srv/repo/bar",
+		"/../foo":          "/srv/repo/foo",
+		"/foo/../../bar":   "/srv/repo/bar",
+		"foo//./bar/.":     "/srv/repo/foo/bar",
+		"..foo/bar..":      "/srv/repo/..foo/bar..",
+		"foo/../bar/../..": "",
+	} {
+		got, err := util.SecureJoin(base, filepath.FromSlash(path))
+		if want == "" {
+			assert.ErrorIs(t, err, billy.ErrCrossedBoundary, path)
+			continue
+		}
+
+		require.NoError(t, err, path)
+		assert.Equal(t, filepath.FromSlash(want), got, path)
+	}
+
+	for _, path := range []string{"..", "../foo", "foo/../../bar", "./.."} {
+		_, err := util.SecureJoin(base, filepath.FromSlash(path))
+		assert.ErrorIs(t, err, billy.ErrCrossedBoundary, path)
+
+		var berr *billy.BoundaryError
+		require.ErrorAs(t, err, &berr, path)
+		assert.Equal(t, base, berr.Base, path)
+	}
+
+	if runtime.GOOS == "windows" {
+		for _, path := range []string{`C:\foo`, `C:foo`, `\\server\share\foo`} {
+			_, err := util.SecureJoin(base, path)
+			assert.ErrorIs(t, err, billy.ErrCrossedBoundary, path)
+		}
+	}
+}
+
+func TestRel(t *testing.T) {
+	fs, err := memfs.New().Chroot("/srv/repo")
+	require.NoError(t, err)
+
+	for path, want := range map[string]string{
+		"/srv/repo":         ".",
+		"/srv/repo/foo":     "foo",
+		"/srv/repo/foo/bar": "foo/bar",
+		"foo/bar":           "foo/bar",
+		"foo/../bar":        "bar",
+	} {
+		got, err := util.Rel(fs, filepath.FromSlash(path))
+		require.NoError(t, err, path)
+		assert.Equal(t, filepath.FromSlash(want), got, path)
+	}
+
+	for _, path := range []string{"/srv", "/srv/other", "/srv/repository", "../foo"} {
+		_, err := util.Rel(fs, filepath.FromSlash(path))
+		assert.ErrorIs(t, err, billy.ErrCrossedBoundary, path)
+	}
+}
+
+func TestRelAbsRoundTrip(t *testing.T) {
+	fs, err := memfs.New().Chroot("/srv/repo")
+	require.NoError(t, err)
+
+	abs, err := util.Abs(fs, "/foo/bar")
+	require.NoError(t, err)
+
+	rel, err := util.Rel(fs, abs)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("foo/bar"), rel)
+}
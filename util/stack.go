@@ -0,0 +1,45 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// DumpStack returns the composition tree of fs, one filesystem per line,
+// each one indented below the filesystem built on it. For example, the stack
+// of a memfs.New filesystem is dumped as:
+//
+//	chroot(/)
+//	  polyfill
+//	    memfs
+//
+// The layers are found through the billy.Composite interface. Filesystems
+// implementing fmt.Stringer are described by their String method, the other
+// ones by their type.
+func DumpStack(fs billy.Basic) string {
+	var b strings.Builder
+	dumpStack(&b, fs, 0)
+
+	return b.String()
+}
+
+func dumpStack(b *strings.Builder, fs billy.Basic, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if s, ok := fs.(fmt.Stringer); ok {
+		b.WriteString(s.String())
+	} else {
+		fmt.Fprintf(b, "%T", fs)
+	}
+	b.WriteByte('\n')
+
+	c, ok := fs.(billy.Composite)
+	if !ok {
+		return
+	}
+
+	for _, l := range c.Layers() {
+		dumpStack(b, l, depth+1)
+	}
+}
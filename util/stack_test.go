@@ -0,0 +1,35 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/chroot"
+	"github.com/go-git/go-billy/v6/helper/mount"
+	"github.com/go-git/go-billy/v6/helper/unionfs"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestDumpStack(t *testing.T) {
+	mem := func() billy.Filesystem { return memfs.New(memfs.WithSlashSeparator()) }
+	fs := chroot.New(mount.New(mem(), "/tmp", unionfs.New(mem())), "/repo")
+
+	assert.Equal(t, `chroot(/repo)
+  polyfill
+    mount(tmp)
+      chroot(/)
+        polyfill
+          memfs
+      unionfs
+        chroot(/)
+          polyfill
+            memfs
+`, util.DumpStack(fs))
+}
+
+func TestDumpStackType(t *testing.T) {
+	fs := &zeroSizeFs{Filesystem: memfs.New()}
+	assert.Equal(t, "*util_test.zeroSizeFs\n", util.DumpStack(fs))
+}
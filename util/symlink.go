@@ -0,0 +1,155 @@
+package util
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+)
+
+// maxSymlinkHops is the maximum number of symlinks followed while resolving
+// a single path, mimicking SYMLOOP_MAX on Linux.
+const maxSymlinkHops = 255
+
+// EvalSymlinks returns the path name after the evaluation of any symbolic
+// links, using only the methods of the given filesystem. If path is relative
+// the result will be relative to the filesystem working dir, unless one of
+// the components is an absolute symbolic link. Absolute link targets are
+// resolved against the root of the filesystem.
+//
+// EvalSymlinks returns a *fs.PathError wrapping syscall.ELOOP if too many
+// symbolic links are followed, as it happens with cyclic links. If the
+// filesystem does not support symlinks, the cleaned path is returned.
+func EvalSymlinks(fs billy.Filesystem, path string) (string, error) {
+	sep := string(filepath.Separator)
+	abs := isAbs(path)
+	pending := splitPath(path)
+	resolved := make([]string, 0, len(pending))
+
+	hops := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+
+		switch name {
+		case ".":
+			continue
+		case "..":
+			if len(resolved) > 0 && resolved[len(resolved)-1] != ".." {
+				resolved = resolved[:len(resolved)-1]
+			} else if !abs {
+				resolved = append(resolved, "..")
+			}
+			continue
+		}
+
+		resolved = append(resolved, name)
+		current := joinPath(abs, resolved)
+
+		fi, err := fs.Lstat(current)
+		if errors.Is(err, billy.ErrNotSupported) {
+			return filepath.Clean(filepath.FromSlash(path)), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			if !fi.IsDir() && len(pending) > 0 {
+				return "", &os.PathError{Op: "evalsymlinks", Path: current, Err: syscall.ENOTDIR}
+			}
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", &os.PathError{Op: "evalsymlinks", Path: path, Err: errno.ELOOP}
+		}
+
+		target, err := fs.Readlink(current)
+		if err != nil {
+			return "", err
+		}
+
+		resolved = resolved[:len(resolved)-1]
+		if isAbs(target) {
+			resolved = resolved[:0]
+			abs = true
+		}
+
+		pending = append(splitPath(target), pending...)
+	}
+
+	if len(resolved) == 0 && abs {
+		return sep, nil
+	}
+
+	return joinPath(abs, resolved), nil
+}
+
+// StatFollow returns a FileInfo describing the named file, following any
+// symbolic links with EvalSymlinks. Unlike the Stat method of the
+// filesystems, which resolves links in an implementation specific way,
+// StatFollow behaves the same way on every billy filesystem. The name of
+// the returned FileInfo is the base name of the given path.
+func StatFollow(fs billy.Filesystem, path string) (fs.FileInfo, error) {
+	resolved, err := EvalSymlinks(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.Lstat(resolved)
+	if errors.Is(err, billy.ErrNotSupported) {
+		fi, err = fs.Stat(resolved)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &namedFileInfo{FileInfo: fi, name: filepath.Base(path)}, nil
+}
+
+type namedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi *namedFileInfo) Name() string {
+	return fi.name
+}
+
+func isAbs(path string) bool {
+	return filepath.IsAbs(path) || strings.HasPrefix(filepath.FromSlash(path), string(filepath.Separator))
+}
+
+func splitPath(path string) []string {
+	path = filepath.FromSlash(path)
+	path = path[len(filepath.VolumeName(path)):]
+
+	var parts []string
+	for _, p := range strings.Split(path, string(filepath.Separator)) {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	return parts
+}
+
+func joinPath(abs bool, parts []string) string {
+	p := filepath.Join(parts...)
+	if abs {
+		return string(filepath.Separator) + p
+	}
+
+	if p == "" {
+		return "."
+	}
+
+	return p
+}
@@ -0,0 +1,77 @@
+package util_test
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestEvalSymlinks(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "dir/file", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("dir", "rel"))
+	require.NoError(t, fs.Symlink("/dir/file", "abs"))
+	require.NoError(t, fs.Symlink("../rel/file", "dir/nested"))
+	require.NoError(t, fs.Symlink("rel", "chain"))
+	require.NoError(t, fs.Symlink("missing", "dangling"))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"dir/file", filepath.FromSlash("dir/file")},
+		{"rel", "dir"},
+		{"rel/file", filepath.FromSlash("dir/file")},
+		{"abs", filepath.FromSlash("/dir/file")},
+		{"dir/nested", filepath.FromSlash("dir/file")},
+		{"chain/file", filepath.FromSlash("dir/file")},
+		{"/chain/../dir", filepath.FromSlash("/dir")},
+		{".", "."},
+		{"/", string(filepath.Separator)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			got, err := util.EvalSymlinks(fs, tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := util.EvalSymlinks(fs, "dangling")
+	require.Error(t, err)
+
+	_, err = util.EvalSymlinks(fs, "dir/file/foo")
+	require.ErrorIs(t, err, syscall.ENOTDIR)
+}
+
+func TestEvalSymlinksLoop(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, fs.Symlink("b", "a"))
+	require.NoError(t, fs.Symlink("a", "b"))
+	require.NoError(t, fs.Symlink("self", "self"))
+
+	_, err := util.EvalSymlinks(fs, "a")
+	require.ErrorIs(t, err, errno.ELOOP)
+
+	_, err = util.EvalSymlinks(fs, "self")
+	require.ErrorIs(t, err, errno.ELOOP)
+}
+
+func TestStatFollow(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "dir/file", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("file", "dir/link"))
+
+	fi, err := util.StatFollow(fs, "dir/link")
+	require.NoError(t, err)
+	assert.Equal(t, "link", fi.Name())
+	assert.Equal(t, int64(3), fi.Size())
+	assert.True(t, fi.Mode().IsRegular())
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-billy/v6"
+)
+
+// TreeHashOptions configures what TreeHash includes in the hash. The names,
+// types, permission bits, sizes and modification times of the entries, and
+// the targets of the symlinks, are always included.
+type TreeHashOptions struct {
+	// Content includes the contents of the files, hashing each of them.
+	// This is much more expensive, unless the filesystem implements
+	// billy.Hasher natively.
+	Content bool
+}
+
+// TreeHash returns a hash of the tree of fs at root, which changes whenever
+// an entry under root is added, removed or changed, so that callers can tell
+// whether anything changed between two points in time by comparing hashes,
+// on any filesystem. The hash is deterministic, regardless of the order in
+// which the filesystem lists the entries, but is only meant to be compared
+// with others computed with the same options. Symlinks are not followed.
+func TreeHash(fs billy.Filesystem, root string, opts TreeHashOptions) ([]byte, error) {
+	entries, err := treeEntries(fs, root)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for rel := range entries {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, rel := range paths {
+		fi := entries[rel]
+		name := fs.Join(root, filepath.FromSlash(rel))
+
+		writeTreeHashField(h, []byte(rel))
+		var meta [20]byte
+		binary.BigEndian.PutUint32(meta[0:], uint32(fi.Mode()))
+		binary.BigEndian.PutUint64(meta[4:], uint64(fi.Size()))
+		binary.BigEndian.PutUint64(meta[12:], uint64(fi.ModTime().UnixNano()))
+		h.Write(meta[:])
+
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := fs.Readlink(name)
+			if err != nil {
+				return nil, err
+			}
+			writeTreeHashField(h, []byte(target))
+		case fi.Mode().IsRegular() && opts.Content:
+			sum, err := HashFile(fs, name, crypto.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			writeTreeHashField(h, sum)
+		}
+	}
+
+	return h.Sum(nil), nil
+}
+
+// writeTreeHashField writes b to h prefixed by its length, so that the
+// boundaries between the fields are part of the hash.
+func writeTreeHashField(h hash.Hash, b []byte) {
+	var n [binary.MaxVarintLen64]byte
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+	h.Write(b)
+}
@@ -0,0 +1,97 @@
+package util_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
+)
+
+func TestTreeHash(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	build := func(names ...string) billy.Filesystem {
+		fs := memfs.New()
+		for _, name := range names {
+			require.NoError(t, util.WriteFile(fs, name, []byte("foo"), 0o644))
+		}
+		for _, name := range append(names, "dir", "dir/sub") {
+			require.NoError(t, util.Chtimes(fs, name, mtime, mtime))
+		}
+		return fs
+	}
+
+	hash := func(fs billy.Filesystem, opts util.TreeHashOptions) []byte {
+		sum, err := util.TreeHash(fs, ".", opts)
+		require.NoError(t, err)
+		return sum
+	}
+
+	fs := build("foo", "dir/bar", "dir/sub/baz")
+	sum := hash(fs, util.TreeHashOptions{})
+	assert.Equal(t, sum, hash(fs, util.TreeHashOptions{}))
+	// The order in which the entries were created does not matter.
+	assert.Equal(t, sum, hash(build("dir/sub/baz", "dir/bar", "foo"), util.TreeHashOptions{}))
+
+	for name, change := range map[string]func(fs billy.Filesystem){
+		"size": func(fs billy.Filesystem) {
+			require.NoError(t, util.WriteFile(fs, "foo", []byte("foo!"), 0o644))
+			require.NoError(t, util.Chtimes(fs, "foo", mtime, mtime))
+		},
+		"mode": func(fs billy.Filesystem) {
+			require.NoError(t, util.Chmod(fs, "dir/bar", 0o600))
+		},
+		"mtime": func(fs billy.Filesystem) {
+			require.NoError(t, util.Chtimes(fs, "dir/sub/baz", mtime, mtime.Add(time.Second)))
+		},
+		"rename": func(fs billy.Filesystem) {
+			require.NoError(t, fs.Rename("dir/bar", "dir/qux"))
+		},
+		"added": func(fs billy.Filesystem) {
+			require.NoError(t, fs.MkdirAll("new", 0o755))
+		},
+	} {
+		fs := build("foo", "dir/bar", "dir/sub/baz")
+		change(fs)
+		assert.NotEqual(t, sum, hash(fs, util.TreeHashOptions{}), name)
+	}
+
+	// Symlinks are not followed, but their targets are part of the hash.
+	link := build("foo", "dir/bar", "dir/sub/baz")
+	require.NoError(t, link.Symlink("foo", "dir/link"))
+	linkSum := hash(link, util.TreeHashOptions{})
+	assert.NotEqual(t, sum, linkSum)
+	require.NoError(t, link.Remove("dir/link"))
+	require.NoError(t, link.Symlink("bar", "dir/link"))
+	require.NoError(t, util.Chtimes(link, "dir", mtime, mtime))
+	assert.NotEqual(t, linkSum, hash(link, util.TreeHashOptions{}))
+
+	// A change of content keeping the size and the mtime is only seen when
+	// hashing the contents.
+	content := hash(fs, util.TreeHashOptions{Content: true})
+	require.NoError(t, util.WriteFile(fs, "dir/bar", []byte("bar"), 0o644))
+	require.NoError(t, util.Chtimes(fs, "dir/bar", mtime, mtime))
+	assert.Equal(t, sum, hash(fs, util.TreeHashOptions{}))
+	assert.NotEqual(t, content, hash(fs, util.TreeHashOptions{Content: true}))
+}
+
+func TestTreeHashRoot(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("foo"), 0o644))
+
+	sum, err := util.TreeHash(fs, "dir", util.TreeHashOptions{})
+	require.NoError(t, err)
+
+	// Changes outside of the root are not part of the hash.
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+	got, err := util.TreeHash(fs, "dir", util.TreeHashOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, sum, got)
+
+	_, err = util.TreeHash(fs, "missing", util.TreeHashOptions{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
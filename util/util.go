@@ -1,6 +1,10 @@
 package util
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -8,16 +12,25 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
 )
 
 // RemoveAll removes path and any children it contains. It removes everything it
 // can but returns the first error it encounters. If the path does not exist,
 // RemoveAll returns nil (no error).
+//
+// The RemoveAll method of fs, or else of the filesystem it wraps, is used
+// when available, so whole trees are removed natively.
 func RemoveAll(fs billy.Basic, path string) error {
+	if r, ok := fs.(removerAll); ok {
+		return r.RemoveAll(path)
+	}
+
 	fs, path = getUnderlyingAndPath(fs, path)
 
 	if r, ok := fs.(removerAll); ok {
@@ -117,71 +130,102 @@ func WriteFile(fs billy.Basic, filename string, data []byte, perm fs.FileMode) (
 		err = io.ErrShortWrite
 	}
 
-	return nil
+	return err
 }
 
-// Random number state.
-// We generate random temporary file names so that there's a good
-// chance the file doesn't exist yet - keeps the number of tries in
-// TempFile to a minimum.
-var rand uint32
-var randmu sync.Mutex
+// WriteFileNoFollow works like WriteFile, but fails with an error wrapping
+// syscall.ELOOP if filename is a symbolic link, instead of writing to its
+// target. Filesystems not implementing billy.Symlink have no links to
+// follow, so WriteFileNoFollow behaves like WriteFile on them.
+//
+// The check is not atomic: a link created concurrently between the check
+// and the write is still followed.
+func WriteFileNoFollow(fs billy.Basic, filename string, data []byte, perm fs.FileMode) error {
+	if sl, ok := fs.(billy.Symlink); ok {
+		fi, err := sl.Lstat(filename)
+		if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return &os.PathError{Op: "open", Path: filename, Err: errno.ELOOP}
+		}
+	}
 
-func reseed() uint32 {
-	return uint32(time.Now().UnixNano() + int64(os.Getpid()))
+	return WriteFile(fs, filename, data, perm)
 }
 
+// readChunkSize is the maximum number of bytes read at once by
+// ReadFileContext.
+const readChunkSize = 1 << 20
+
+// nextSuffix returns a random string to build the names of temporary files
+// from. It is read from crypto/rand, so that the callers running
+// concurrently, in this process or in others, are unlikely to choose the
+// same name, keeping the number of tries in CreateTemp to a minimum.
 func nextSuffix() string {
-	randmu.Lock()
-	r := rand
-	if r == 0 {
-		r = reseed()
-	}
-	r = r*1664525 + 1013904223 // constants from Numerical Recipes
-	rand = r
-	randmu.Unlock()
-	return strconv.Itoa(int(1e9 + r%1e9))[1:]
+	var b [4]byte
+	// Read never fails, crashing the program irrecoverably instead.
+	_, _ = rand.Read(b[:])
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(b[:])), 10)
 }
 
+// maxTempTries is the number of names tried by CreateTemp and MkdirTemp
+// before giving up, when every one of them already exists.
+const maxTempTries = 10000
+
 // TempFile creates a new temporary file in the directory dir with a name
-// beginning with prefix, opens the file for reading and writing, and returns
-// the resulting *os.File. If dir is the empty string, TempFile uses the default
-// directory for temporary files (see os.TempDir). Multiple programs calling
-// TempFile simultaneously will not choose the same file. The caller can use
-// f.Name() to find the pathname of the file. It is the caller's responsibility
-// to remove the file when no longer needed.
-func TempFile(fs billy.Basic, dir, prefix string) (f billy.File, err error) {
-	// This implementation is based on stdlib ioutil.TempFile.
+// beginning with prefix, as CreateTemp does with the pattern prefix+"*".
+//
+// Deprecated: use CreateTemp, which also allows a suffix.
+func TempFile(fs billy.Basic, dir, prefix string) (billy.File, error) {
+	return CreateTemp(fs, dir, prefix+"*")
+}
+
+// CreateTemp creates a new temporary file in the directory dir, opens the
+// file for reading and writing, and returns the resulting file. The filename
+// is generated by taking pattern and adding a random string to the end. If
+// pattern includes a "*", the random string replaces the last "*". If dir is
+// the empty string, CreateTemp uses the default directory for temporary files
+// (see os.TempDir). Multiple programs calling CreateTemp simultaneously will
+// not choose the same file. The caller can use f.Name() to find the pathname
+// of the file. It is the caller's responsibility to remove the file when no
+// longer needed.
+func CreateTemp(fs billy.Basic, dir, pattern string) (f billy.File, err error) {
+	// This implementation is based on stdlib os.CreateTemp.
 	if dir == "" {
 		dir = getTempDir(fs)
 	}
 
-	nconflict := 0
-	for i := 0; i < 10000; i++ {
-		name := filepath.Join(dir, prefix+nextSuffix())
+	prefix, suffix, err := prefixAndSuffix(pattern)
+	if err != nil {
+		return nil, &os.PathError{Op: "createtemp", Path: pattern, Err: err}
+	}
+
+	for i := 0; i < maxTempTries; i++ {
+		name := filepath.Join(dir, prefix+nextSuffix()+suffix)
 		f, err = fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
-		if errors.Is(err, os.ErrExist) {
-			if nconflict++; nconflict > 10 {
-				randmu.Lock()
-				rand = reseed()
-				randmu.Unlock()
-			}
-			continue
+		if !errors.Is(err, os.ErrExist) {
+			break
 		}
-		break
 	}
 	return
 }
 
-// TempDir creates a new temporary directory in the directory dir
-// with a name beginning with prefix and returns the path of the
-// new directory. If dir is the empty string, TempDir uses the
-// default directory for temporary files (see os.TempDir).
-// Multiple programs calling TempDir simultaneously
-// will not choose the same directory. It is the caller's responsibility
-// to remove the directory when no longer needed.
-func TempDir(fs billy.Dir, dir, prefix string) (name string, err error) {
-	// This implementation is based on stdlib ioutil.TempDir
+// TempDir creates a new temporary directory in the directory dir with a name
+// beginning with prefix, as MkdirTemp does with the pattern prefix+"*".
+//
+// Deprecated: use MkdirTemp, which also allows a suffix.
+func TempDir(fs billy.Dir, dir, prefix string) (string, error) {
+	return MkdirTemp(fs, dir, prefix+"*")
+}
+
+// MkdirTemp creates a new temporary directory in the directory dir and
+// returns the path of the new directory. The new directory's name is
+// generated by adding a random string to the end of pattern. If pattern
+// includes a "*", the random string replaces the last "*" instead. If dir is
+// the empty string, MkdirTemp uses the default directory for temporary files
+// (see os.TempDir). Multiple programs calling MkdirTemp simultaneously will
+// not choose the same directory. It is the caller's responsibility to remove
+// the directory when no longer needed.
+func MkdirTemp(fs billy.Dir, dir, pattern string) (name string, err error) {
+	// This implementation is based on stdlib os.MkdirTemp.
 	if dir == "" {
 		base, ok := fs.(billy.Basic)
 		if !ok {
@@ -190,20 +234,39 @@ func TempDir(fs billy.Dir, dir, prefix string) (name string, err error) {
 		dir = getTempDir(base)
 	}
 
-	nconflict := 0
-	for i := 0; i < 10000; i++ {
-		try := filepath.Join(dir, prefix+nextSuffix())
-		err = fs.MkdirAll(try, 0700)
-		if errors.Is(err, os.ErrExist) {
-			if nconflict++; nconflict > 10 {
-				randmu.Lock()
-				rand = reseed()
-				randmu.Unlock()
+	prefix, suffix, err := prefixAndSuffix(pattern)
+	if err != nil {
+		return "", &os.PathError{Op: "mkdirtemp", Path: pattern, Err: err}
+	}
+
+	// MkdirAll succeeds on existing dirs, so on filesystems implementing
+	// Basic the candidate is checked beforehand to detect conflicts.
+	base, _ := fs.(billy.Basic)
+
+	// MkdirAll would create dir, which must exist on filesystems with
+	// explicit dirs, as with the other files they create.
+	if base != nil && billy.Introspect(base).ExplicitDirs {
+		if _, err := base.Stat(dir); err != nil {
+			return "", err
+		}
+	}
+
+	for i := 0; i < maxTempTries; i++ {
+		try := filepath.Join(dir, prefix+nextSuffix()+suffix)
+		err = nil
+		if base != nil {
+			if _, serr := base.Stat(try); serr == nil {
+				err = os.ErrExist
 			}
+		}
+		if err == nil {
+			err = fs.MkdirAll(try, 0700)
+		}
+		if errors.Is(err, os.ErrExist) {
 			continue
 		}
-		if errors.Is(err, os.ErrNotExist) {
-			if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if errors.Is(err, os.ErrNotExist) && base != nil {
+			if _, err := base.Stat(dir); errors.Is(err, os.ErrNotExist) {
 				return "", err
 			}
 		}
@@ -215,6 +278,26 @@ func TempDir(fs billy.Dir, dir, prefix string) (name string, err error) {
 	return
 }
 
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+// prefixAndSuffix splits pattern by the last wildcard "*", if applicable,
+// returning prefix as the part before "*" and suffix as the part after "*".
+func prefixAndSuffix(pattern string) (prefix, suffix string, err error) {
+	for i := 0; i < len(pattern); i++ {
+		if os.IsPathSeparator(pattern[i]) {
+			return "", "", errPatternHasSeparator
+		}
+	}
+
+	if pos := strings.LastIndexByte(pattern, '*'); pos != -1 {
+		prefix, suffix = pattern[:pos], pattern[pos+1:]
+	} else {
+		prefix = pattern
+	}
+
+	return prefix, suffix, nil
+}
+
 func getTempDir(fs billy.Basic) string {
 	ch, ok := fs.(billy.Chroot)
 	if !ok || ch.Root() == "" || ch.Root() == "/" || ch.Root() == string(filepath.Separator) {
@@ -244,7 +327,21 @@ func getUnderlyingAndPath(fs billy.Basic, path string) (billy.Basic, string) {
 // A successful call returns err == nil, not err == EOF.
 // Because ReadFile reads the whole file, it does not treat an EOF from Read
 // as an error to be reported.
+//
+// The size reported by the file is only used as a hint, so files which
+// change size while being read, or which report a size of 0 as the ones in
+// Linux's /proc, are read completely.
 func ReadFile(fs billy.Basic, name string) ([]byte, error) {
+	return ReadFileContext(context.Background(), fs, name)
+}
+
+// ReadFileContext works like ReadFile, but stops reading once ctx is done,
+// returning the error of the context.
+func ReadFileContext(ctx context.Context, fs billy.Basic, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	f, err := fs.Open(name)
 	if err != nil {
 		return nil, err
@@ -253,7 +350,7 @@ func ReadFile(fs billy.Basic, name string) ([]byte, error) {
 	defer f.Close()
 
 	var size int
-	if info, err := fs.Stat(name); err == nil {
+	if info, err := f.Stat(); err == nil {
 		size64 := info.Size()
 		if int64(int(size64)) == size64 {
 			size = int(size64)
@@ -272,12 +369,22 @@ func ReadFile(fs billy.Basic, name string) ([]byte, error) {
 
 	data := make([]byte, 0, size)
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		if len(data) >= cap(data) {
 			d := append(data[:cap(data)], 0)
 			data = d[:len(data)]
 		}
 
-		n, err := f.Read(data[len(data):cap(data)])
+		// Large files are read in chunks, so that ctx is checked regularly.
+		end := cap(data)
+		if end-len(data) > readChunkSize {
+			end = len(data) + readChunkSize
+		}
+
+		n, err := f.Read(data[len(data):end])
 		data = data[:len(data)+n]
 
 		if err != nil {
@@ -289,3 +396,551 @@ func ReadFile(fs billy.Basic, name string) ([]byte, error) {
 		}
 	}
 }
+
+// ReadDirNames reads the directory named by path and returns up to n names of
+// its entries, or all of them if n <= 0. It uses the DirNames interface when
+// supported by the filesystem, falling back to ReadDir otherwise. The names
+// are not guaranteed to be sorted.
+func ReadDirNames(fs billy.Basic, path string, n int) ([]string, error) {
+	if d, ok := fs.(billy.DirNames); ok {
+		return d.ReadDirNames(path, n)
+	}
+
+	d, ok := fs.(billy.Dir)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	infos, err := d.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > 0 && n < len(infos) {
+		infos = infos[:n]
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names, nil
+}
+
+// ReadDirEntries reads the directory named by path and returns its entries
+// sorted by filename. It uses the DirEntries interface when supported by the
+// filesystem, falling back to ReadDir otherwise.
+func ReadDirEntries(fs billy.Basic, path string) ([]fs.DirEntry, error) {
+	if d, ok := fs.(billy.DirEntries); ok {
+		return d.ReadDirEntries(path)
+	}
+
+	d, ok := fs.(billy.Dir)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	infos, err := d.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDirEntries(infos), nil
+}
+
+func toDirEntries(infos []fs.FileInfo) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, fi := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(fi))
+	}
+
+	return entries
+}
+
+// StatMany returns the FileInfo of each of the named files, as Stat does,
+// along with the error of each of them, at the same index. It uses the
+// BatchStater interface when supported by the filesystem, falling back to
+// calling Stat for each file otherwise.
+func StatMany(fs billy.Basic, paths []string) ([]os.FileInfo, []error) {
+	if b, ok := fs.(billy.BatchStater); ok {
+		return b.StatMany(paths)
+	}
+
+	infos := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+	for i, path := range paths {
+		infos[i], errs[i] = fs.Stat(path)
+	}
+
+	return infos, errs
+}
+
+// Exists reports whether the named file exists, following symlinks, so a
+// dangling link is reported as missing. A path with a regular file as one of
+// its parents is reported as missing too. Any other error returned by Stat is
+// returned as is.
+func Exists(fs billy.Basic, path string) (bool, error) {
+	_, err := fs.Stat(path)
+	return exists(err)
+}
+
+// IsDir reports whether the named file exists and is a directory, following
+// symlinks. It handles errors the same way as Exists.
+func IsDir(fs billy.Basic, path string) (bool, error) {
+	fi, err := fs.Stat(path)
+	if ok, err := exists(err); !ok {
+		return false, err
+	}
+
+	return fi.IsDir(), nil
+}
+
+// IsEmptyDir reports whether the directory named by path has no entries.
+// Only the first entry is read when the filesystem implements the DirNames
+// interface, so the cost does not depend on the size of the directory.
+func IsEmptyDir(fs billy.Basic, path string) (bool, error) {
+	names, err := ReadDirNames(fs, path, 1)
+	if err != nil {
+		return false, err
+	}
+
+	return len(names) == 0, nil
+}
+
+func exists(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// SyncDir commits the entries of the directory named by path to stable
+// storage. It uses the DirSyncer interface when supported by the filesystem,
+// otherwise it does nothing, as the filesystem is assumed to have no stable
+// storage to commit to.
+func SyncDir(fs billy.Basic, path string) error {
+	if d, ok := fs.(billy.DirSyncer); ok {
+		return d.SyncDir(path)
+	}
+
+	return nil
+}
+
+// HashFile returns the checksum of the content of the named file, computed
+// with h. It uses the Hasher interface when supported by the filesystem,
+// otherwise, or if the filesystem cannot compute h natively, the file is
+// read fully. h must be available, see crypto.Hash.Available.
+func HashFile(fs billy.Basic, path string, h crypto.Hash) ([]byte, error) {
+	if hs, ok := fs.(billy.Hasher); ok {
+		sum, err := hs.HashFile(path, h)
+		if !errors.Is(err, billy.ErrNotSupported) {
+			return sum, err
+		}
+	}
+
+	if !h.Available() {
+		return nil, &os.PathError{Op: "hash", Path: path, Err: billy.ErrNotSupported}
+	}
+
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := h.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// CopyFile copies the content of the file src to dst, creating dst as Create
+// does, or truncating it if it exists. It uses the billy.Copier interface
+// when supported by the filesystem, otherwise, or if the filesystem cannot
+// copy the file natively, the content is copied with Copy.
+func CopyFile(fs billy.Basic, src, dst string) error {
+	if c, ok := fs.(billy.Copier); ok {
+		err := c.CopyFile(src, dst)
+		if !errors.Is(err, billy.ErrNotSupported) {
+			return err
+		}
+	}
+
+	srcFile, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := fs.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := Copy(dstFile, srcFile); err != nil {
+		_ = dstFile.Close()
+		return err
+	}
+
+	return dstFile.Close()
+}
+
+// Copy copies from src to dst until EOF, as io.Copy does. If both files are
+// backed by files of the OS, see billy.RawFile, these are copied directly,
+// letting the runtime use copy_file_range or sendfile, which do not copy the
+// data through user space.
+func Copy(dst, src billy.File) (int64, error) {
+	if rawDst, err := RawFile(dst); err == nil {
+		if rawSrc, err := RawFile(src); err == nil {
+			return io.Copy(rawDst, rawSrc)
+		}
+	}
+
+	return io.Copy(dst, src)
+}
+
+// Mmap maps the content of the named file in memory, read-only. It uses the
+// billy.Mapper interface when supported by the filesystem, otherwise the file
+// is read fully in memory.
+func Mmap(fs billy.Basic, path string) (billy.Mapping, error) {
+	if m, ok := fs.(billy.Mapper); ok {
+		return m.Mmap(path)
+	}
+
+	data, err := ReadFile(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMapping(data, nil), nil
+}
+
+// NewMapping returns a billy.Mapping over data, calling release, if not nil,
+// when closed. It lets filesystems implement billy.Mapper over the memory they
+// mapped, or over a copy of the content of the file.
+func NewMapping(data []byte, release func() error) billy.Mapping {
+	return &mapping{data: data, release: release}
+}
+
+type mapping struct {
+	data    []byte
+	release func() error
+	closed  bool
+}
+
+func (m *mapping) Bytes() []byte {
+	return m.data
+}
+
+func (m *mapping) ReadAt(b []byte, off int64) (int, error) {
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, m.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (m *mapping) Close() error {
+	if m.closed {
+		return os.ErrClosed
+	}
+
+	m.closed = true
+	m.data = nil
+	if m.release == nil {
+		return nil
+	}
+
+	return m.release()
+}
+
+// FileChmod changes the mode of the open file f, if it implements the
+// billy.FileChange interface, failing with an error wrapping
+// billy.ErrNotSupported otherwise.
+func FileChmod(f billy.File, mode fs.FileMode) error {
+	fc, ok := f.(billy.FileChange)
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: f.Name(), Err: billy.ErrNotSupported}
+	}
+
+	return fc.Chmod(mode)
+}
+
+// FileChtimes changes the access and modification times of the open file f,
+// if it implements the billy.FileChange interface, failing with an error
+// wrapping billy.ErrNotSupported otherwise.
+func FileChtimes(f billy.File, atime, mtime time.Time) error {
+	fc, ok := f.(billy.FileChange)
+	if !ok {
+		return &os.PathError{Op: "chtimes", Path: f.Name(), Err: billy.ErrNotSupported}
+	}
+
+	return fc.Chtimes(atime, mtime)
+}
+
+// RawFile returns the *os.File backing the open file f, if it implements the
+// billy.RawFile interface, failing with an error wrapping
+// billy.ErrNotSupported otherwise.
+func RawFile(f billy.File) (*os.File, error) {
+	rf, ok := f.(billy.RawFile)
+	if !ok {
+		return nil, &os.PathError{Op: "rawfile", Path: f.Name(), Err: billy.ErrNotSupported}
+	}
+
+	return rf.RawFile()
+}
+
+// FileReadDir reads the entries of the open directory f, as
+// billy.ReadDirFile.ReadDir does, if f implements that interface, failing
+// with an error wrapping billy.ErrNotSupported otherwise.
+func FileReadDir(f billy.File, n int) ([]fs.DirEntry, error) {
+	d, ok := f.(billy.ReadDirFile)
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: f.Name(), Err: billy.ErrNotSupported}
+	}
+
+	return d.ReadDir(n)
+}
+
+// Chmod changes the mode of the named file, following symlinks. It uses the
+// Change interface when supported by the filesystem, otherwise it returns
+// billy.ErrNotSupported.
+func Chmod(fs billy.Basic, name string, mode fs.FileMode) error {
+	if c, ok := fs.(billy.Change); ok {
+		return c.Chmod(name, mode)
+	}
+
+	return &os.PathError{Op: "chmod", Path: name, Err: billy.ErrNotSupported}
+}
+
+// Chown changes the owner of the named file, following symlinks. It uses the
+// Change interface when supported by the filesystem, otherwise it returns
+// billy.ErrNotSupported.
+func Chown(fs billy.Basic, name string, uid, gid int) error {
+	if c, ok := fs.(billy.Change); ok {
+		return c.Chown(name, uid, gid)
+	}
+
+	return &os.PathError{Op: "chown", Path: name, Err: billy.ErrNotSupported}
+}
+
+// Lchown changes the owner of the named file, or of the symlink itself if it
+// is one. It uses the Change interface when supported by the filesystem,
+// otherwise it returns billy.ErrNotSupported.
+func Lchown(fs billy.Basic, name string, uid, gid int) error {
+	if c, ok := fs.(billy.Change); ok {
+		return c.Lchown(name, uid, gid)
+	}
+
+	return &os.PathError{Op: "lchown", Path: name, Err: billy.ErrNotSupported}
+}
+
+// Lchmod changes the mode of the named file, or of the symlink itself if it
+// is one. It uses the SymlinkChange interface when supported by the
+// filesystem. Otherwise, as only symlinks need it, it falls back to the
+// Change interface if name is not a symlink, and returns
+// billy.ErrNotSupported if it is.
+func Lchmod(fs billy.Basic, name string, mode fs.FileMode) error {
+	if c, ok := fs.(billy.SymlinkChange); ok {
+		return c.Lchmod(name, mode)
+	}
+
+	if err := noSymlink(fs, "lchmod", name); err != nil {
+		return err
+	}
+
+	return Chmod(fs, name, mode)
+}
+
+// Lchtimes changes the access and modification times of the named file, or
+// of the symlink itself if it is one. As Lchmod, it uses the SymlinkChange
+// interface when supported by the filesystem, and falls back to the Change
+// interface if name is not a symlink.
+func Lchtimes(fs billy.Basic, name string, atime, mtime time.Time) error {
+	if c, ok := fs.(billy.SymlinkChange); ok {
+		return c.Lchtimes(name, atime, mtime)
+	}
+
+	if err := noSymlink(fs, "lchtimes", name); err != nil {
+		return err
+	}
+
+	return Chtimes(fs, name, atime, mtime)
+}
+
+// noSymlink returns an error wrapping billy.ErrNotSupported if name is a
+// symlink, which cannot be changed without the SymlinkChange interface.
+// Filesystems not implementing billy.Symlink have no symlinks.
+func noSymlink(fs billy.Basic, op, name string) error {
+	sl, ok := fs.(billy.Symlink)
+	if !ok {
+		return nil
+	}
+
+	fi, err := sl.Lstat(name)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return &os.PathError{Op: op, Path: name, Err: billy.ErrNotSupported}
+	}
+
+	return nil
+}
+
+// Chtimes changes the access and modification times of the named file,
+// following symlinks. It uses the Change interface when supported by the
+// filesystem, otherwise it returns billy.ErrNotSupported.
+func Chtimes(fs billy.Basic, name string, atime, mtime time.Time) error {
+	if c, ok := fs.(billy.Change); ok {
+		return c.Chtimes(name, atime, mtime)
+	}
+
+	return &os.PathError{Op: "chtimes", Path: name, Err: billy.ErrNotSupported}
+}
+
+// Umask returns the umask applied by fs, or by the filesystem it wraps, to
+// the files and dirs it creates. It reports false if the umask is not known,
+// as on the OS filesystems, which apply the umask of the process.
+func Umask(fs billy.Basic) (fs.FileMode, bool) {
+	for {
+		if u, ok := fs.(billy.Umasker); ok {
+			return u.Umask(), true
+		}
+
+		u, ok := fs.(underlying)
+		if !ok {
+			return 0, false
+		}
+		fs = u.Underlying()
+	}
+}
+
+// WriteFileExact works like WriteFile, but the file ends up with exactly the
+// permission bits of perm, regardless of the umask, and even if it already
+// existed, such as the executable hooks of a repository requiring 0o755.
+//
+// The mode is set with Chmod. If the filesystem does not support it,
+// WriteFileExact fails with an error wrapping billy.ErrNotSupported, unless
+// its umask is known to leave perm as is.
+func WriteFileExact(fs billy.Basic, filename string, data []byte, perm fs.FileMode) error {
+	if err := WriteFile(fs, filename, data, perm); err != nil {
+		return err
+	}
+
+	err := billy.ErrNotSupported
+	if c, ok := fs.(billy.Change); ok {
+		err = c.Chmod(filename, perm)
+	}
+
+	if errors.Is(err, billy.ErrNotSupported) {
+		if mask, ok := Umask(fs); ok && perm&mask == 0 {
+			return nil
+		}
+	}
+
+	return billy.WrapPathError("chmod", filename, err)
+}
+
+// RenameDurable renames from to to, and then syncs the parent dirs of both
+// paths, so that the rename survives a crash once RenameDurable returns.
+func RenameDurable(fs billy.Basic, from, to string) error {
+	if err := fs.Rename(from, to); err != nil {
+		return err
+	}
+
+	toDir := filepath.Dir(to)
+	if err := SyncDir(fs, toDir); err != nil {
+		return err
+	}
+
+	if fromDir := filepath.Dir(from); fromDir != toDir {
+		return SyncDir(fs, fromDir)
+	}
+
+	return nil
+}
+
+// RenameNoReplace renames from to to, failing with an error wrapping
+// os.ErrExist if to already exists. It uses the Renamer interface when
+// supported by the filesystem, otherwise it returns billy.ErrNotSupported,
+// as the check and the rename cannot be made atomic.
+func RenameNoReplace(fs billy.Basic, from, to string) error {
+	if r, ok := fs.(billy.Renamer); ok {
+		return r.RenameNoReplace(from, to)
+	}
+
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: billy.ErrNotSupported}
+}
+
+// RenameExchange atomically swaps from and to. It uses the Renamer interface
+// when supported by the filesystem, otherwise it returns
+// billy.ErrNotSupported.
+func RenameExchange(fs billy.Basic, from, to string) error {
+	if r, ok := fs.(billy.Renamer); ok {
+		return r.RenameExchange(from, to)
+	}
+
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: billy.ErrNotSupported}
+}
+
+// OpenDir opens the directory named by path for iteration. It uses the
+// DirOpener interface when supported by the filesystem, falling back to an
+// iterator over the result of ReadDir otherwise.
+func OpenDir(fs billy.Basic, path string) (billy.DirIter, error) {
+	if d, ok := fs.(billy.DirOpener); ok {
+		return d.OpenDir(path)
+	}
+
+	d, ok := fs.(billy.Dir)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	infos, err := d.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sliceDirIter{infos: infos}, nil
+}
+
+type sliceDirIter struct {
+	infos []fs.FileInfo
+}
+
+func (it *sliceDirIter) Next() (fs.DirEntry, error) {
+	if len(it.infos) == 0 {
+		return nil, io.EOF
+	}
+
+	fi := it.infos[0]
+	it.infos = it.infos[1:]
+	return fs.FileInfoToDirEntry(fi), nil
+}
+
+func (it *sliceDirIter) Close() error {
+	it.infos = nil
+	return nil
+}
@@ -1,14 +1,27 @@
 package util_test
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/internal/errno"
+	"github.com/go-git/go-billy/v6/internal/test"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
 	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
 	"github.com/go-git/go-billy/v6/util"
-	"github.com/stretchr/testify/require"
 )
 
 func TestTempFile(t *testing.T) {
@@ -44,6 +57,44 @@ func TestTempDir_WithDir(t *testing.T) {
 	}
 }
 
+func TestCreateTemp(t *testing.T) {
+	fs := memfs.New()
+
+	f, err := util.CreateTemp(fs, "dir", "foo*.txt")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(filepath.Join("dir", "foo")) + "[0-9]+\\.txt$")
+	assert.Regexp(t, re, f.Name())
+
+	f, err = util.CreateTemp(fs, "dir", "foo")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Regexp(t, "^"+regexp.QuoteMeta(filepath.Join("dir", "foo"))+"[0-9]+$", f.Name())
+
+	_, err = util.CreateTemp(fs, "dir", "foo/*")
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "createtemp", perr.Op)
+}
+
+func TestMkdirTemp(t *testing.T) {
+	fs := memfs.New()
+
+	name, err := util.MkdirTemp(fs, "dir", "*.d")
+	require.NoError(t, err)
+	assert.Regexp(t, "^"+regexp.QuoteMeta("dir"+string(filepath.Separator))+"[0-9]+\\.d$", name)
+
+	fi, err := fs.Stat(name)
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+
+	_, err = util.MkdirTemp(fs, "dir", "foo/*")
+	var perr *os.PathError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "mkdirtemp", perr.Op)
+}
+
 func TestReadFile(t *testing.T) {
 	fs := memfs.New()
 	f, err := util.TempFile(fs, "", "")
@@ -90,3 +141,549 @@ func TestTempDir_WithNonRoot(t *testing.T) {
 		t.Errorf(`TempDir(fs, "", "") = %s, should not be relative to os.TempDir on not root filesystem`, f)
 	}
 }
+
+func TestReadDirNames(t *testing.T) {
+	fs := memfs.New()
+	for _, name := range []string{"qux", "foo", "bar"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+
+	names, err := util.ReadDirNames(fs, "dir", 0)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"bar", "foo", "qux"}, names)
+
+	names, err = util.ReadDirNames(fs, "dir", 2)
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+
+	_, err = util.ReadDirNames(fs, "missing", 0)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStatMany(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "dir/foo", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("dir/foo", "link"))
+
+	paths := []string{"dir/foo", "dir", "link", "missing", "dir/foo/bar"}
+	// The fallback, for filesystems which are not BatchStaters, gives the
+	// same results.
+	for _, fs := range []billy.Basic{fs, struct{ billy.Basic }{fs}} {
+		infos, errs := util.StatMany(fs, paths)
+		require.Len(t, infos, len(paths))
+		require.Len(t, errs, len(paths))
+
+		require.NoError(t, errs[0])
+		assert.Equal(t, int64(3), infos[0].Size())
+		require.NoError(t, errs[1])
+		assert.True(t, infos[1].IsDir())
+		require.NoError(t, errs[2])
+		assert.Equal(t, "link", infos[2].Name())
+		assert.False(t, infos[2].Mode()&os.ModeSymlink != 0)
+		assert.ErrorIs(t, errs[3], os.ErrNotExist)
+		assert.Nil(t, infos[3])
+		assert.ErrorIs(t, errs[4], syscall.ENOTDIR)
+	}
+}
+
+func TestExists(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "dir/file", nil, 0o644))
+	require.NoError(t, fs.Symlink("dir/file", "link"))
+	require.NoError(t, fs.Symlink("missing", "dangling"))
+
+	for path, want := range map[string]bool{
+		"dir":          true,
+		"dir/file":     true,
+		"link":         true,
+		"dangling":     false,
+		"missing":      false,
+		"dir/file/foo": false,
+	} {
+		ok, err := util.Exists(fs, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, ok, path)
+	}
+}
+
+func TestIsDir(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "dir/file", nil, 0o644))
+	require.NoError(t, fs.Symlink("dir", "link"))
+
+	for path, want := range map[string]bool{
+		"dir":      true,
+		"link":     true,
+		"dir/file": false,
+		"missing":  false,
+	} {
+		ok, err := util.IsDir(fs, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, ok, path)
+	}
+}
+
+func TestIsEmptyDir(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, fs.MkdirAll("empty", 0o755))
+	require.NoError(t, util.WriteFile(fs, "dir/file", nil, 0o644))
+
+	ok, err := util.IsEmptyDir(fs, "empty")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = util.IsEmptyDir(fs, "dir")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = util.IsEmptyDir(fs, "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpenDir(t *testing.T) {
+	fs := memfs.New()
+	for _, name := range []string{"qux", "foo", "bar"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+
+	it, err := util.OpenDir(fs, "dir")
+	require.NoError(t, err)
+	defer it.Close()
+
+	var names []string
+	for {
+		e, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, []string{"bar", "foo", "qux"}, names)
+
+	_, err = util.OpenDir(fs, "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestTempDir_Unique(t *testing.T) {
+	fs := memfs.New()
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		name, err := util.TempDir(fs, "dir", "foo")
+		require.NoError(t, err)
+		require.False(t, seen[name], "duplicated temp dir %q", name)
+		seen[name] = true
+	}
+}
+
+type hasher struct {
+	billy.Filesystem
+	err error
+}
+
+func (fs *hasher) HashFile(string, crypto.Hash) ([]byte, error) {
+	if fs.err != nil {
+		return nil, fs.err
+	}
+
+	return []byte("native"), nil
+}
+
+func TestHashFile(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	want := sha256.Sum256([]byte("foo"))
+	sum, err := util.HashFile(fs, "foo", crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, want[:], sum)
+
+	sum, err = util.HashFile(&hasher{Filesystem: fs}, "foo", crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("native"), sum)
+
+	sum, err = util.HashFile(&hasher{Filesystem: fs, err: billy.ErrNotSupported}, "foo", crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, want[:], sum)
+
+	_, err = util.HashFile(fs, "missing", crypto.SHA256)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = util.HashFile(fs, "foo", crypto.MD4)
+	require.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+func TestUmask(t *testing.T) {
+	_, ok := util.Umask(&test.BasicMock{})
+	assert.False(t, ok)
+
+	mask, ok := util.Umask(memfs.New(memfs.WithUmask(0o027)))
+	assert.True(t, ok)
+	assert.Equal(t, os.FileMode(0o027), mask)
+}
+
+func TestWriteFileExact(t *testing.T) {
+	fs := memfs.New(memfs.WithUmask(0o022))
+
+	require.NoError(t, util.WriteFileExact(fs, "hooks/pre-commit", []byte("foo"), 0o777))
+	fi, err := fs.Stat("hooks/pre-commit")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o777), fi.Mode().Perm())
+
+	require.NoError(t, util.WriteFileExact(fs, "hooks/pre-commit", []byte("bar"), 0o700))
+	fi, err = fs.Stat("hooks/pre-commit")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), fi.Mode().Perm())
+
+	content, err := util.ReadFile(fs, "hooks/pre-commit")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(content))
+
+	err = util.WriteFileExact(&test.BasicMock{}, "foo", nil, 0o755)
+	assert.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+func TestFileChange(t *testing.T) {
+	fs := memfs.New()
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, util.FileChmod(f, 0o600))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, util.FileChtimes(f, mtime, mtime))
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	assert.True(t, mtime.Equal(fi.ModTime()))
+
+	err = util.FileChmod(&test.FileMock{}, 0o600)
+	require.ErrorIs(t, err, billy.ErrNotSupported)
+	err = util.FileChtimes(&test.FileMock{}, mtime, mtime)
+	require.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+func TestRawFile(t *testing.T) {
+	fs := memfs.New()
+	f, err := fs.Create("foo")
+	require.NoError(t, err)
+	defer f.Close()
+
+	// The chroot wrapping memfs forwards to the file of memfs, which has
+	// no file of the OS.
+	_, err = util.RawFile(f)
+	require.ErrorIs(t, err, billy.ErrNotSupported)
+
+	_, err = util.RawFile(&test.FileMock{})
+	require.ErrorIs(t, err, billy.ErrNotSupported)
+}
+
+type copier struct {
+	billy.Filesystem
+	err    error
+	copies int
+}
+
+func (c *copier) CopyFile(_, _ string) error {
+	c.copies++
+	return c.err
+}
+
+func TestCopyFile(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("longer"), 0o644))
+
+	// Without native copy the content is copied, truncating dst.
+	c := &copier{Filesystem: fs, err: billy.ErrNotSupported}
+	require.NoError(t, util.CopyFile(c, "foo", "bar"))
+	assert.Equal(t, 1, c.copies)
+
+	content, err := util.ReadFile(fs, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	err = util.CopyFile(fs, "missing", "qux")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = fs.Stat("qux")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	c = &copier{Filesystem: fs}
+	require.NoError(t, util.CopyFile(c, "missing", "qux"))
+	assert.Equal(t, 1, c.copies)
+}
+
+func TestCopy(t *testing.T) {
+	// The files of osfs are copied directly, while memfs ones are not
+	// backed by a file of the OS.
+	for _, fs := range []billy.Filesystem{osfs.New(t.TempDir()), memfs.New()} {
+		require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+		src, err := fs.Open("foo")
+		require.NoError(t, err)
+		dst, err := fs.Create("bar")
+		require.NoError(t, err)
+
+		n, err := util.Copy(dst, src)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+		require.NoError(t, src.Close())
+		require.NoError(t, dst.Close())
+
+		content, err := util.ReadFile(fs, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(content))
+	}
+}
+
+func TestMmap(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	// Without billy.Mapper, the file is read fully.
+	for _, fs := range []billy.Basic{fs, struct{ billy.Basic }{fs}} {
+		m, err := util.Mmap(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("foo"), m.Bytes())
+
+		b := make([]byte, 2)
+		n, err := m.ReadAt(b, 2)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, "o", string(b[:n]))
+
+		require.NoError(t, m.Close())
+		require.ErrorIs(t, m.Close(), os.ErrClosed)
+		_, err = m.ReadAt(b, 0)
+		require.ErrorIs(t, err, os.ErrClosed)
+	}
+
+	_, err := util.Mmap(struct{ billy.Basic }{fs}, "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewMapping(t *testing.T) {
+	var released int
+	m := util.NewMapping([]byte("foo"), func() error {
+		released++
+		return nil
+	})
+
+	_, err := m.ReadAt(make([]byte, 1), -1)
+	require.Error(t, err)
+
+	require.NoError(t, m.Close())
+	require.ErrorIs(t, m.Close(), os.ErrClosed)
+	assert.Equal(t, 1, released)
+	assert.Nil(t, m.Bytes())
+}
+
+type syncRecorder struct {
+	billy.Filesystem
+	synced []string
+}
+
+func (fs *syncRecorder) SyncDir(path string) error {
+	fs.synced = append(fs.synced, path)
+	return nil
+}
+
+func TestRenameDurable(t *testing.T) {
+	fs := &syncRecorder{Filesystem: memfs.New()}
+	require.NoError(t, util.WriteFile(fs, "foo/bar", nil, 0o644))
+
+	require.NoError(t, util.RenameDurable(fs, "foo/bar", "qux/bar"))
+	assert.Equal(t, []string{"qux", "foo"}, fs.synced)
+
+	fs.synced = nil
+	require.NoError(t, util.RenameDurable(fs, "qux/bar", "qux/baz"))
+	assert.Equal(t, []string{"qux"}, fs.synced)
+
+	fs.synced = nil
+	assert.ErrorIs(t, util.RenameDurable(fs, "missing", "qux/missing"), os.ErrNotExist)
+	assert.Empty(t, fs.synced)
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "bar", []byte("bar"), 0o644))
+
+	err := util.RenameNoReplace(fs, "foo", "bar")
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	require.NoError(t, util.RenameExchange(fs, "foo", "bar"))
+	data, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+
+	m := &test.BasicMock{}
+	assert.ErrorIs(t, util.RenameNoReplace(m, "foo", "bar"), billy.ErrNotSupported)
+	assert.ErrorIs(t, util.RenameExchange(m, "foo", "bar"), billy.ErrNotSupported)
+	assert.Empty(t, m.RenameArgs)
+}
+
+func TestChange(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, util.Chmod(fs, "foo", 0o600))
+	require.NoError(t, util.Chtimes(fs, "foo", mtime, mtime))
+	require.NoError(t, util.Chown(fs, "foo", 1, 2))
+	require.NoError(t, util.Lchown(fs, "foo", 3, -1))
+
+	fi, err := fs.Stat("foo")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	assert.True(t, mtime.Equal(fi.ModTime()))
+	assert.Equal(t, &billy.FileStat{UID: 3, GID: 2, Nlink: 1}, fi.Sys())
+
+	m := &test.BasicMock{}
+	assert.ErrorIs(t, util.Chmod(m, "foo", 0o600), billy.ErrNotSupported)
+	assert.ErrorIs(t, util.Chown(m, "foo", 1, 2), billy.ErrNotSupported)
+	assert.ErrorIs(t, util.Lchown(m, "foo", 1, 2), billy.ErrNotSupported)
+	assert.ErrorIs(t, util.Chtimes(m, "foo", mtime, mtime), billy.ErrNotSupported)
+}
+
+func TestSymlinkChange(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", nil, 0o644))
+	require.NoError(t, fs.Symlink("foo", "link"))
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, util.Lchtimes(fs, "link", mtime, mtime))
+	require.NoError(t, util.Lchmod(fs, "link", 0o700))
+
+	fi, err := fs.Lstat("link")
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(fi.ModTime()))
+	assert.Equal(t, os.FileMode(0o700), fi.Mode().Perm())
+
+	fi, err = fs.Stat("foo")
+	require.NoError(t, err)
+	assert.False(t, mtime.Equal(fi.ModTime()))
+	assert.Equal(t, os.FileMode(0o644), fi.Mode().Perm())
+
+	// Without SymlinkChange, only the files which are not symlinks can be
+	// changed.
+	c := struct {
+		billy.Filesystem
+		billy.Change
+	}{fs, fs.(billy.Change)}
+	require.NoError(t, util.Lchmod(c, "foo", 0o600))
+	require.NoError(t, util.Lchtimes(c, "foo", mtime, mtime))
+	assert.ErrorIs(t, util.Lchmod(c, "link", 0o600), billy.ErrNotSupported)
+	assert.ErrorIs(t, util.Lchtimes(c, "link", mtime, mtime), billy.ErrNotSupported)
+
+	fi, err = fs.Stat("foo")
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(fi.ModTime()))
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	m := &test.BasicMock{}
+	assert.ErrorIs(t, util.Lchmod(m, "foo", 0o600), billy.ErrNotSupported)
+	assert.ErrorIs(t, util.Lchtimes(m, "foo", mtime, mtime), billy.ErrNotSupported)
+}
+
+type zeroSizeFs struct {
+	billy.Filesystem
+}
+
+func (fs *zeroSizeFs) Open(name string) (billy.File, error) {
+	f, err := fs.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &zeroSizeFile{File: f}, nil
+}
+
+type zeroSizeFile struct {
+	billy.File
+}
+
+func (f *zeroSizeFile) Stat() (os.FileInfo, error) {
+	return nil, errors.New("stat not supported")
+}
+
+func TestReadFileWithoutSize(t *testing.T) {
+	fs := &zeroSizeFs{Filesystem: memfs.New()}
+	data := bytes.Repeat([]byte("foo"), 1000)
+	require.NoError(t, util.WriteFile(fs, "foo", data, 0o644))
+
+	content, err := util.ReadFile(fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+}
+
+func TestReadFileContext(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0o644))
+
+	content, err := util.ReadFileContext(context.Background(), fs, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = util.ReadFileContext(ctx, fs, "foo")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWriteFileNoFollow(t *testing.T) {
+	fs := memfs.New()
+	require.NoError(t, util.WriteFile(fs, "target", []byte("foo"), 0o644))
+	require.NoError(t, fs.Symlink("target", "link"))
+
+	err := util.WriteFileNoFollow(fs, "link", []byte("bar"), 0o644)
+	assert.ErrorIs(t, err, errno.ELOOP)
+
+	content, err := util.ReadFile(fs, "target")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	require.NoError(t, util.WriteFileNoFollow(fs, "target", []byte("bar"), 0o644))
+	require.NoError(t, util.WriteFileNoFollow(fs, "new", []byte("bar"), 0o644))
+}
+
+type readOnlyFileFs struct {
+	billy.Filesystem
+}
+
+func (fs *readOnlyFileFs) OpenFile(name string, _ int, perm os.FileMode) (billy.File, error) {
+	return fs.Filesystem.OpenFile(name, os.O_RDONLY|os.O_CREATE, perm)
+}
+
+func TestWriteFileReturnsWriteError(t *testing.T) {
+	fs := &readOnlyFileFs{Filesystem: memfs.New()}
+	err := util.WriteFile(fs, "foo", []byte("foo"), 0o644)
+	assert.Error(t, err)
+}
+
+func TestReadDirEntries(t *testing.T) {
+	fs := memfs.New()
+	for _, name := range []string{"qux", "foo", "bar"} {
+		require.NoError(t, util.WriteFile(fs, fs.Join("dir", name), nil, 0o644))
+	}
+
+	entries, err := util.ReadDirEntries(fs, "dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, name := range []string{"bar", "foo", "qux"} {
+		assert.Equal(t, name, entries[i].Name())
+		assert.False(t, entries[i].IsDir())
+	}
+
+	_, err = util.ReadDirEntries(fs, "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	m := &test.DirMock{}
+	_, err = util.ReadDirEntries(m, "dir")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir"}, m.ReadDirArgs)
+
+	_, err = util.ReadDirEntries(&test.BasicMock{}, "dir")
+	require.ErrorIs(t, err, billy.ErrNotSupported)
+}
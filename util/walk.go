@@ -1,52 +1,188 @@
 package util
 
 import (
+	"context"
 	"errors"
+	iofs "io/fs"
 	"os"
 	"path/filepath"
 
 	"github.com/go-git/go-billy/v6"
 )
 
+// WalkOptions configures the behaviour of WalkWithOptions. Its zero value
+// walks the tree like Walk does.
+type WalkOptions struct {
+	// FollowSymlinks makes the walk descend into symlinked directories, and
+	// report symlinks with the FileInfo of their targets. Dangling symlinks,
+	// and symlinks to a directory being walked, which would otherwise make
+	// the walk loop forever, are reported with their own FileInfo and not
+	// descended into.
+	FollowSymlinks bool
+	// MaxDepth limits how deep below root the walk descends, root being at
+	// depth 0. Directories at MaxDepth are reported but not read. Zero or
+	// less means no limit.
+	MaxDepth int
+	// ErrorHandler, if not nil, is called with the errors reading
+	// directories or describing their entries, instead of passing them to
+	// the WalkFunc. If it returns nil, the failing directory or entry is
+	// skipped and the walk goes on, otherwise the walk stops and
+	// WalkWithOptions returns the error.
+	ErrorHandler func(path string, err error) error
+	// SpecialFiles sets how the special files, as told by IsSpecial, are
+	// walked. Its zero value reports them to the WalkFunc like regular
+	// files.
+	SpecialFiles SpecialFilePolicy
+}
+
+// SpecialFilePolicy tells WalkWithOptions what to do with the special files
+// it finds.
+type SpecialFilePolicy int
+
+const (
+	// ReportSpecialFiles reports the special files to the WalkFunc.
+	ReportSpecialFiles SpecialFilePolicy = iota
+	// SkipSpecialFiles leaves the special files out of the walk.
+	SkipSpecialFiles
+	// RejectSpecialFiles reports the special files as errors wrapping
+	// ErrSpecialFile, to the ErrorHandler if any, or to the WalkFunc
+	// otherwise.
+	RejectSpecialFiles
+)
+
+// ErrSpecialFile is returned when walking a special file with
+// RejectSpecialFiles.
+var ErrSpecialFile = errors.New("special file")
+
+// specialModes are the mode bits telling a special file.
+const specialModes = os.ModeDevice | os.ModeCharDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeIrregular
+
+// IsSpecial reports whether mode describes a special file: a device, a named
+// pipe, a socket, or a file of a type unknown to the filesystem. Symlinks
+// are not special files.
+func IsSpecial(mode os.FileMode) bool {
+	return mode&specialModes != 0
+}
+
+type walker struct {
+	fs   billy.Filesystem
+	opts WalkOptions
+	fn   filepath.WalkFunc
+}
+
 // walk recursively descends path, calling walkFn
 // adapted from https://golang.org/src/path/filepath/path.go
-func walk(fs billy.Filesystem, path string, info os.FileInfo, walkFn filepath.WalkFunc) error {
-	if !info.IsDir() {
-		return walkFn(path, info, nil)
+//
+// The filesystem is accessed through real, which differs from path once a
+// symlinked directory is followed, as not every filesystem resolves the
+// symlinks found in the middle of a path. ancestors holds the real paths of
+// the directories being walked, and is only tracked when following symlinks.
+func (w *walker) walk(path, real string, info os.FileInfo, depth int, ancestors []string) error {
+	if IsSpecial(info.Mode()) {
+		switch w.opts.SpecialFiles {
+		case SkipSpecialFiles:
+			return nil
+		case RejectSpecialFiles:
+			return w.fail(path, info, &os.PathError{Op: "walk", Path: path, Err: ErrSpecialFile})
+		}
 	}
 
-	names, err := readdirnames(fs, path)
-	err1 := walkFn(path, info, err)
-	// If err != nil, walk can't walk into this directory.
-	// err1 != nil means walkFn want walk to skip this directory or stop walking.
-	// Therefore, if one of err and err1 isn't nil, walk will return.
-	if err != nil || err1 != nil {
-		// The caller's behavior is controlled by the return value, which is decided
-		// by walkFn. walkFn may ignore err and return nil.
-		// If walkFn returns SkipDir, it will be handled by the caller.
-		// So walk should return whatever walkFn returns.
-		return err1
+	if !info.IsDir() || (w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth) {
+		return w.fn(path, info, nil)
+	}
+
+	names, err := readdirnames(w.fs, real)
+	if w.opts.ErrorHandler != nil {
+		if err1 := w.fn(path, info, nil); err1 != nil {
+			return err1
+		}
+		if err != nil {
+			return w.opts.ErrorHandler(path, err)
+		}
+	} else {
+		err1 := w.fn(path, info, err)
+		// If err != nil, walk can't walk into this directory.
+		// err1 != nil means walkFn want walk to skip this directory or stop walking.
+		// Therefore, if one of err and err1 isn't nil, walk will return.
+		if err != nil || err1 != nil {
+			// The caller's behavior is controlled by the return value, which is decided
+			// by walkFn. walkFn may ignore err and return nil.
+			// If walkFn returns SkipDir, it will be handled by the caller.
+			// So walk should return whatever walkFn returns.
+			return err1
+		}
 	}
 
 	for _, name := range names {
 		filename := filepath.Join(path, name)
-		fileInfo, err := fs.Lstat(filename)
+		realname := filepath.Join(real, name)
+		fileInfo, err := w.fs.Lstat(realname)
+		if err == nil && w.opts.FollowSymlinks {
+			fileInfo, realname, err = w.follow(realname, fileInfo, ancestors)
+		}
 		if err != nil {
-			if err := walkFn(filename, fileInfo, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+			if err := w.fail(filename, fileInfo, err); err != nil && !errors.Is(err, filepath.SkipDir) {
 				return err
 			}
+			continue
+		}
+
+		if w.opts.FollowSymlinks && fileInfo.IsDir() {
+			err = w.walk(filename, realname, fileInfo, depth+1, append(ancestors, realname))
 		} else {
-			err = walk(fs, filename, fileInfo, walkFn)
-			if err != nil {
-				if !fileInfo.IsDir() || !errors.Is(err, filepath.SkipDir) {
-					return err
-				}
+			err = w.walk(filename, realname, fileInfo, depth+1, ancestors)
+		}
+		if err != nil {
+			if !fileInfo.IsDir() || !errors.Is(err, filepath.SkipDir) {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// follow returns the FileInfo to report for the entry at real, given the one
+// returned by Lstat, along with the real path to walk it from.
+func (w *walker) follow(real string, info os.FileInfo, ancestors []string) (os.FileInfo, string, error) {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info, real, nil
+	}
+
+	target, err := StatFollow(w.fs, real)
+	if errors.Is(err, os.ErrNotExist) {
+		return info, real, nil
+	}
+	if err != nil || !target.IsDir() {
+		return target, real, err
+	}
+
+	resolved, err := EvalSymlinks(w.fs, real)
+	if err != nil {
+		return info, real, err
+	}
+
+	// Absolute targets are resolved against the root of the filesystem, so
+	// relative paths are made absolute to be comparable.
+	key := filepath.Join(string(filepath.Separator), resolved)
+	for _, dir := range ancestors {
+		if filepath.Join(string(filepath.Separator), dir) == key {
+			return info, real, nil
+		}
+	}
+
+	return target, resolved, nil
+}
+
+// fail reports err, which happened while visiting path, to the ErrorHandler
+// if any, or to the WalkFunc otherwise.
+func (w *walker) fail(path string, info os.FileInfo, err error) error {
+	if w.opts.ErrorHandler != nil {
+		return w.opts.ErrorHandler(path, err)
+	}
+
+	return w.fn(path, info, err)
+}
+
 // Walk walks the file tree rooted at root, calling fn for each file or
 // directory in the tree, including root. All errors that arise visiting files
 // and directories are filtered by fn: see the WalkFunc documentation for
@@ -54,15 +190,32 @@ func walk(fs billy.Filesystem, path string, info os.FileInfo, walkFn filepath.Wa
 //
 // The files are walked in lexical order, which makes the output deterministic
 // but requires Walk to read an entire directory into memory before proceeding
-// to walk that directory. Walk does not follow symbolic links.
+// to walk that directory. Walk does not follow symbolic links, see
+// WalkWithOptions to do so.
 //
 // Function adapted from https://github.com/golang/go/blob/3b770f2ccb1fa6fecc22ea822a19447b10b70c5c/src/path/filepath/path.go#L500
 func Walk(fs billy.Filesystem, root string, walkFn filepath.WalkFunc) error {
+	return WalkWithOptions(fs, root, WalkOptions{}, walkFn)
+}
+
+// WalkWithOptions is like Walk, but its behaviour can be configured with
+// opts, to follow symbolic links, to limit the depth of the walk or to leave
+// out the special files.
+func WalkWithOptions(fs billy.Filesystem, root string, opts WalkOptions, walkFn filepath.WalkFunc) error {
+	w := &walker{fs: fs, opts: opts, fn: walkFn}
+
 	info, err := fs.Lstat(root)
+	real := root
+	var ancestors []string
+	if err == nil && opts.FollowSymlinks {
+		info, real, err = w.follow(root, info, nil)
+		ancestors = []string{real}
+	}
+
 	if err != nil {
-		err = walkFn(root, nil, err)
+		err = w.fail(root, nil, err)
 	} else {
-		err = walk(fs, root, info, walkFn)
+		err = w.walk(root, real, info, 0, ancestors)
 	}
 
 	if errors.Is(err, filepath.SkipDir) {
@@ -71,3 +224,135 @@ func Walk(fs billy.Filesystem, root string, walkFn filepath.WalkFunc) error {
 
 	return err
 }
+
+// Progress describes how far WalkWithProgress or CopyTreeWithProgress has
+// gone.
+type Progress struct {
+	// Entries is the number of files, dirs and symlinks walked or copied.
+	Entries int64
+	// Bytes is the total size of the regular files walked, or the number of
+	// bytes copied.
+	Bytes int64
+	// Path is the path, in the filesystem walked or copied from, of the
+	// last entry processed.
+	Path string
+}
+
+// ProgressFunc is called by WalkWithProgress and CopyTreeWithProgress to
+// report their progress.
+type ProgressFunc func(Progress)
+
+// WalkWithProgress is like WalkWithOptions, but calls progress after every
+// entry given to walkFn, so the callers walking large trees, or slow
+// filesystems, can give some feedback. The walk stops once ctx is done,
+// returning the error of the context.
+func WalkWithProgress(ctx context.Context, fs billy.Filesystem, root string, opts WalkOptions, progress ProgressFunc, walkFn filepath.WalkFunc) error {
+	var p Progress
+	return WalkWithOptions(fs, root, opts, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err = walkFn(path, info, err)
+		if info != nil {
+			p.Entries++
+			if info.Mode().IsRegular() {
+				p.Bytes += info.Size()
+			}
+			p.Path = path
+			progress(p)
+		}
+
+		return err
+	})
+}
+
+// walkDir recursively descends path, calling walkDirFn.
+// adapted from https://golang.org/src/path/filepath/path.go
+func walkDir(fs billy.Filesystem, path string, d iofs.DirEntry, walkDirFn iofs.WalkDirFunc) error {
+	if err := walkDirFn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			// Successfully skipped directory.
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := readDirEntries(fs, path)
+	if err != nil {
+		// Second call, to report ReadDir error.
+		err = walkDirFn(path, d, err)
+		if err != nil {
+			if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, d1 := range entries {
+		path1 := filepath.Join(path, d1.Name())
+		if err := walkDir(fs, path1, d1, walkDirFn); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readDirEntries reads the directory named by dir and returns its entries.
+// The DirEntries interface is used when supported by the filesystem,
+// otherwise the entries are built from the FileInfo returned by ReadDir so no
+// further Lstat calls are required.
+func readDirEntries(fs billy.Filesystem, dir string) ([]iofs.DirEntry, error) {
+	if d, ok := fs.(billy.DirEntries); ok {
+		return d.ReadDirEntries(dir)
+	}
+
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]iofs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+
+		entries = append(entries, iofs.FileInfoToDirEntry(info))
+	}
+
+	return entries, nil
+}
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root. All errors that arise visiting files
+// and directories are filtered by fn: see the fs.WalkDirFunc documentation
+// for details.
+//
+// The files are walked in lexical order, which makes the output deterministic
+// but requires WalkDir to read an entire directory into memory before
+// proceeding to walk that directory. WalkDir does not follow symbolic links.
+//
+// WalkDir is more efficient than Walk, as it uses the entries returned by
+// ReadDirEntries or ReadDir instead of calling Lstat on every visited file or
+// directory.
+//
+// Function adapted from https://github.com/golang/go/blob/3b770f2ccb1fa6fecc22ea822a19447b10b70c5c/src/path/filepath/path.go#L460
+func WalkDir(fs billy.Filesystem, root string, fn iofs.WalkDirFunc) error {
+	info, err := fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(fs, root, iofs.FileInfoToDirEntry(info), fn)
+	}
+
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+
+	return err
+}
@@ -1,17 +1,23 @@
 package util_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/go-git/go-billy/v6"
+	"github.com/go-git/go-billy/v6/helper/interceptfs"
+	"github.com/go-git/go-billy/v6/helper/polyfill"
 	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/osfs"
 	"github.com/go-git/go-billy/v6/util"
 
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"github.com/go-git/go-billy/v6/internal/test/assert"
+	"github.com/go-git/go-billy/v6/internal/test/require"
 )
 
 var targetSubfolder = filepath.FromSlash("path/to/some/subfolder")
@@ -111,16 +117,14 @@ func TestWalkStopsOnError(t *testing.T) {
 }
 
 func TestWalkForwardsStatErrors(t *testing.T) {
-	memFilesystem := memfs.New()
-	filesystem := &fnFs{
-		Filesystem: memFilesystem,
-		lstat: func(path string) (os.FileInfo, error) {
-			if path == targetSubfolder {
-				return nil, errors.New("uncaught error")
+	filesystem := interceptfs.New(memfs.New(), interceptfs.Hooks{
+		Before: func(op interceptfs.Op) error {
+			if op.Name == "lstat" && op.Path == targetSubfolder {
+				return errors.New("uncaught error")
 			}
-			return memFilesystem.Lstat(path)
+			return nil
 		},
-	}
+	})
 
 	createFile(t, filesystem, "path/to/some/subfolder/that/contain/file")
 	createFile(t, filesystem, "path/to/some/file")
@@ -151,14 +155,364 @@ func createFile(t *testing.T, filesystem billy.Filesystem, path string) {
 	fd.Close()
 }
 
-type fnFs struct {
-	billy.Filesystem
-	lstat func(path string) (os.FileInfo, error)
+func symlinkTree(t *testing.T) billy.Filesystem {
+	t.Helper()
+
+	filesystem := memfs.New()
+	createFile(t, filesystem, "data/dir/file")
+	require.NoError(t, util.WriteFile(filesystem, "data/file", []byte("foo"), 0o644))
+	require.NoError(t, filesystem.Symlink("../data/dir", "root/dir"))
+	require.NoError(t, filesystem.Symlink("../data/file", "root/file"))
+	require.NoError(t, filesystem.Symlink("missing", "root/dangling"))
+	require.NoError(t, filesystem.Symlink("..", "root/parent"))
+	require.NoError(t, filesystem.Symlink("/root", "root/self"))
+
+	return filesystem
+}
+
+func TestWalkWithOptionsFollowSymlinks(t *testing.T) {
+	filesystem := symlinkTree(t)
+
+	modes := map[string]os.FileMode{}
+	err := util.WalkWithOptions(filesystem, "root", util.WalkOptions{FollowSymlinks: true},
+		func(path string, info os.FileInfo, err error) error {
+			require.NoError(t, err)
+			modes[filepath.ToSlash(path)] = info.Mode().Type()
+			return nil
+		})
+	require.NoError(t, err)
+
+	// root/parent/root is the walked root itself, reached through a symlink,
+	// so only the symlinks inside it which loop back are not followed.
+	assert.Equal(t, map[string]os.FileMode{
+		"root":                      os.ModeDir,
+		"root/dangling":             os.ModeSymlink,
+		"root/dir":                  os.ModeDir,
+		"root/dir/file":             0,
+		"root/file":                 0,
+		"root/parent":               os.ModeDir,
+		"root/parent/data":          os.ModeDir,
+		"root/parent/data/dir":      os.ModeDir,
+		"root/parent/data/dir/file": 0,
+		"root/parent/data/file":     0,
+		"root/parent/root":          os.ModeDir,
+		"root/parent/root/dangling": os.ModeSymlink,
+		"root/parent/root/dir":      os.ModeDir,
+		"root/parent/root/dir/file": 0,
+		"root/parent/root/file":     0,
+		"root/parent/root/parent":   os.ModeSymlink,
+		"root/parent/root/self":     os.ModeSymlink,
+		"root/self":                 os.ModeSymlink,
+	}, modes)
+}
+
+func TestWalkWithOptionsDoesNotFollowSymlinksByDefault(t *testing.T) {
+	filesystem := symlinkTree(t)
+
+	discoveredPaths := []string{}
+	err := util.WalkWithOptions(filesystem, "root", util.WalkOptions{},
+		func(path string, info os.FileInfo, err error) error {
+			require.NoError(t, err)
+			discoveredPaths = append(discoveredPaths, filepath.ToSlash(path))
+			if path != "root" {
+				assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"root", "root/dangling", "root/dir", "root/file", "root/parent", "root/self",
+	}, discoveredPaths)
+}
+
+func TestWalkWithoutSymlinks(t *testing.T) {
+	m := memfs.New()
+	createFile(t, m, "path/to/file")
+	filesystem := polyfill.New(struct {
+		billy.Basic
+		billy.Dir
+	}{m, m})
+
+	discoveredPaths := []string{}
+	err := util.Walk(filesystem, "path", func(path string, _ os.FileInfo, err error) error {
+		require.NoError(t, err)
+		discoveredPaths = append(discoveredPaths, filepath.ToSlash(path))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"path", "path/to", "path/to/file"}, discoveredPaths)
+}
+
+func TestWalkWithOptionsMaxDepth(t *testing.T) {
+	filesystem := memfs.New()
+	createFile(t, filesystem, "path/to/some/file")
+
+	discoveredPaths := []string{}
+	err := util.WalkWithOptions(filesystem, "path", util.WalkOptions{MaxDepth: 2},
+		func(path string, _ os.FileInfo, err error) error {
+			require.NoError(t, err)
+			discoveredPaths = append(discoveredPaths, filepath.ToSlash(path))
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"path", "path/to", "path/to/some"}, discoveredPaths)
+}
+
+func TestWalkWithOptionsErrorHandler(t *testing.T) {
+	filesystem := interceptfs.New(memfs.New(), interceptfs.Hooks{
+		Before: func(op interceptfs.Op) error {
+			if op.Name == "lstat" && op.Path == targetSubfolder {
+				return errors.New("uncaught error")
+			}
+			return nil
+		},
+	})
+
+	createFile(t, filesystem, "path/to/some/subfolder/that/contain/file")
+	createFile(t, filesystem, "path/to/some/file")
+
+	var failed []string
+	opts := util.WalkOptions{
+		ErrorHandler: func(path string, err error) error {
+			failed = append(failed, path)
+			assert.Error(t, err)
+			return nil
+		},
+	}
+
+	discoveredPaths := []string{}
+	err := util.WalkWithOptions(filesystem, "path", opts, func(path string, _ os.FileInfo, err error) error {
+		require.NoError(t, err)
+		discoveredPaths = append(discoveredPaths, path)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{targetSubfolder}, failed)
+	assert.Contains(t, discoveredPaths, filepath.FromSlash("path/to/some/file"))
+	assert.NotContains(t, discoveredPaths, targetSubfolder)
+
+	opts.ErrorHandler = func(_ string, err error) error { return err }
+	err = util.WalkWithOptions(filesystem, "path", opts, func(_ string, _ os.FileInfo, _ error) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "uncaught error")
 }
 
-func (f *fnFs) Lstat(path string) (os.FileInfo, error) {
-	if f.lstat != nil {
-		return f.lstat(path)
+func TestWalkWithOptionsSpecialFiles(t *testing.T) {
+	filesystem := memfs.New()
+	createFile(t, filesystem, "path/file")
+	require.NoError(t, memfs.Mknod(filesystem, "path/fifo", os.ModeNamedPipe|0o644))
+	require.NoError(t, memfs.Mknod(filesystem, "path/tty", os.ModeDevice|os.ModeCharDevice|0o620))
+
+	walk := func(opts util.WalkOptions) ([]string, error) {
+		var discoveredPaths []string
+		err := util.WalkWithOptions(filesystem, "path", opts, func(path string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			discoveredPaths = append(discoveredPaths, filepath.ToSlash(path))
+			return nil
+		})
+		return discoveredPaths, err
 	}
-	return nil, errors.New("not implemented")
+
+	discoveredPaths, err := walk(util.WalkOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"path", "path/fifo", "path/file", "path/tty"}, discoveredPaths)
+
+	discoveredPaths, err = walk(util.WalkOptions{SpecialFiles: util.SkipSpecialFiles})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"path", "path/file"}, discoveredPaths)
+
+	_, err = walk(util.WalkOptions{SpecialFiles: util.RejectSpecialFiles})
+	assert.ErrorIs(t, err, util.ErrSpecialFile)
+
+	var rejected []string
+	discoveredPaths, err = walk(util.WalkOptions{
+		SpecialFiles: util.RejectSpecialFiles,
+		ErrorHandler: func(path string, err error) error {
+			assert.ErrorIs(t, err, util.ErrSpecialFile)
+			rejected = append(rejected, filepath.ToSlash(path))
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"path", "path/file"}, discoveredPaths)
+	assert.Equal(t, []string{"path/fifo", "path/tty"}, rejected)
+}
+
+func TestWalkWithProgress(t *testing.T) {
+	filesystem := memfs.New()
+	require.NoError(t, util.WriteFile(filesystem, "path/to/file", []byte("foo"), 0o644))
+	require.NoError(t, util.WriteFile(filesystem, "path/other", []byte("foobar"), 0o644))
+
+	var progress []util.Progress
+	var discoveredPaths []string
+	err := util.WalkWithProgress(context.Background(), filesystem, "path", util.WalkOptions{},
+		func(p util.Progress) {
+			progress = append(progress, p)
+		},
+		func(path string, _ os.FileInfo, err error) error {
+			require.NoError(t, err)
+			discoveredPaths = append(discoveredPaths, filepath.ToSlash(path))
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"path", "path/other", "path/to", "path/to/file"}, discoveredPaths)
+	require.Len(t, progress, 4)
+	assert.Equal(t, util.Progress{Entries: 4, Bytes: 9, Path: filepath.FromSlash("path/to/file")}, progress[3])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	discoveredPaths = nil
+	err = util.WalkWithProgress(ctx, filesystem, "path", util.WalkOptions{},
+		func(util.Progress) {
+			cancel()
+		},
+		func(path string, _ os.FileInfo, _ error) error {
+			discoveredPaths = append(discoveredPaths, path)
+			return nil
+		})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"path"}, discoveredPaths)
+}
+
+func TestIsSpecial(t *testing.T) {
+	for _, mode := range []os.FileMode{
+		os.ModeDevice, os.ModeDevice | os.ModeCharDevice, os.ModeNamedPipe, os.ModeSocket, os.ModeIrregular,
+	} {
+		assert.True(t, util.IsSpecial(mode|0o644), mode.String())
+	}
+	for _, mode := range []os.FileMode{0o644, os.ModeDir | 0o755, os.ModeSymlink | 0o777} {
+		assert.False(t, util.IsSpecial(mode), mode.String())
+	}
+}
+
+func TestWalkDirOnExistingFolder(t *testing.T) {
+	filesystem := memfs.New()
+	createFile(t, filesystem, "path/to/some/subfolder/that/contain/file")
+	createFile(t, filesystem, "path/to/some/file")
+	discoveredPaths := []string{}
+	err := util.WalkDir(filesystem, "path", func(path string, _ fs.DirEntry, _ error) error {
+		discoveredPaths = append(discoveredPaths, path)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"path",
+		filepath.FromSlash("path/to"),
+		filepath.FromSlash("path/to/some"),
+		filepath.FromSlash("path/to/some/file"),
+		filepath.FromSlash("path/to/some/subfolder"),
+		filepath.FromSlash("path/to/some/subfolder/that"),
+		filepath.FromSlash("path/to/some/subfolder/that/contain"),
+		filepath.FromSlash("path/to/some/subfolder/that/contain/file"),
+	}, discoveredPaths)
+}
+
+func TestWalkDirCanSkipFolder(t *testing.T) {
+	filesystem := memfs.New()
+	createFile(t, filesystem, "path/to/some/subfolder/that/contain/file")
+	createFile(t, filesystem, "path/to/some/file")
+	discoveredPaths := []string{}
+	err := util.WalkDir(filesystem, "path", func(path string, _ fs.DirEntry, _ error) error {
+		discoveredPaths = append(discoveredPaths, path)
+		if path == targetSubfolder {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Contains(t, discoveredPaths, filepath.FromSlash("path/to/some/subfolder"))
+	assert.NotContains(t, discoveredPaths, filepath.FromSlash("path/to/some/subfolder/that"))
+}
+
+func TestWalkDirCanSkipAll(t *testing.T) {
+	filesystem := memfs.New()
+	createFile(t, filesystem, "path/to/some/subfolder/that/contain/file")
+	createFile(t, filesystem, "path/to/some/file")
+	discoveredPaths := []string{}
+	err := util.WalkDir(filesystem, "path", func(path string, _ fs.DirEntry, _ error) error {
+		discoveredPaths = append(discoveredPaths, path)
+		if path == filepath.FromSlash("path/to/some/file") {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, discoveredPaths, filepath.FromSlash("path/to/some/subfolder"))
+}
+
+func TestWalkDirReturnsAnErrorWhenRootDoesNotExist(t *testing.T) {
+	filesystem := memfs.New()
+	err := util.WalkDir(filesystem, "/root/that/does/not/exist", func(_ string, _ fs.DirEntry, err error) error { return err })
+
+	require.Error(t, err)
+}
+
+func TestWalkDirDoesNotLstatEntries(t *testing.T) {
+	lstats := 0
+	filesystem := interceptfs.New(memfs.New(), interceptfs.Hooks{
+		Before: func(op interceptfs.Op) error {
+			if op.Name == "lstat" {
+				lstats++
+			}
+			return nil
+		},
+	})
+
+	createFile(t, filesystem, "path/to/some/subfolder/that/contain/file")
+	createFile(t, filesystem, "path/to/some/file")
+	err := util.WalkDir(filesystem, "path", func(_ string, d fs.DirEntry, err error) error {
+		require.NotNil(t, d)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, lstats)
+}
+
+func BenchmarkWalk(b *testing.B) {
+	filesystem := benchmarkTree(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := util.Walk(filesystem, ".", func(_ string, _ os.FileInfo, err error) error {
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalkDir(b *testing.B) {
+	filesystem := benchmarkTree(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := util.WalkDir(filesystem, ".", func(_ string, _ fs.DirEntry, err error) error {
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkTree creates an osfs tree with 10k files spread over 100 dirs.
+func benchmarkTree(b *testing.B) billy.Filesystem {
+	b.Helper()
+
+	filesystem := osfs.New(b.TempDir())
+	for d := 0; d < 100; d++ {
+		for f := 0; f < 100; f++ {
+			name := filepath.Join(fmt.Sprintf("dir%03d", d), fmt.Sprintf("file%03d", f))
+			if err := util.WriteFile(filesystem, name, nil, 0o600); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	return filesystem
 }